@@ -0,0 +1,176 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SumTerm evaluates to the sum of the numbers at Field, a dotted path that
+// fans out over the lists it passes through: Items.Price is the price of
+// every element of Items, and Orders.Items.Price that of every item of every
+// order. A path ending at a list of numbers sums its elements. Like the
+// arithmetic terms, integers produce an int64 unless the sum overflows and
+// other numbers a float64; nil values are skipped and other values that are
+// not numbers are an error. An empty list sums to 0, while a path that does
+// not resolve evaluates to nil.
+type SumTerm struct {
+	Field string
+}
+
+func (s SumTerm) Evaluate(i interface{}, _ ...any) (interface{}, error) {
+	ns, ok, err := aggregateNumbers(i, s.Field, "sum")
+	if err != nil || !ok {
+		return nil, err
+	}
+	var total int64
+	for n, v := range ns {
+		iv, isInt := integer(v)
+		if !isInt {
+			return sumFloats(ns[n:], float64(total)), nil
+		}
+		sum := total + iv
+		if (sum > total) != (iv > 0) {
+			return sumFloats(ns[n:], float64(total)), nil
+		}
+		total = sum
+	}
+	return total, nil
+}
+
+func sumFloats(ns []interface{}, total float64) float64 {
+	for _, v := range ns {
+		f, _ := numeric[float64](v)
+		total += f
+	}
+	return total
+}
+
+// MinTerm evaluates to the smallest of the numbers at Field, found as for
+// SumTerm, or nil when there are none.
+type MinTerm struct {
+	Field string
+}
+
+func (m MinTerm) Evaluate(i interface{}, _ ...any) (interface{}, error) {
+	return extreme(i, m.Field, "min", func(a, b float64) bool { return a < b })
+}
+
+// MaxTerm evaluates to the largest of the numbers at Field, found as for
+// SumTerm, or nil when there are none.
+type MaxTerm struct {
+	Field string
+}
+
+func (m MaxTerm) Evaluate(i interface{}, _ ...any) (interface{}, error) {
+	return extreme(i, m.Field, "max", func(a, b float64) bool { return a > b })
+}
+
+// AvgTerm evaluates to the mean of the numbers at Field, found as for
+// SumTerm, as a float64, or nil when there are none.
+type AvgTerm struct {
+	Field string
+}
+
+func (a AvgTerm) Evaluate(i interface{}, _ ...any) (interface{}, error) {
+	ns, ok, err := aggregateNumbers(i, a.Field, "avg")
+	if err != nil || !ok || len(ns) == 0 {
+		return nil, err
+	}
+	return sumFloats(ns, 0) / float64(len(ns)), nil
+}
+
+// extreme returns the number at field that better prefers to all the others,
+// as an int64 when it is an integer.
+func extreme(i interface{}, field, name string, better func(a, b float64) bool) (interface{}, error) {
+	ns, ok, err := aggregateNumbers(i, field, name)
+	if err != nil || !ok || len(ns) == 0 {
+		return nil, err
+	}
+	best := ns[0]
+	bestF, _ := numeric[float64](best)
+	for _, v := range ns[1:] {
+		if f, _ := numeric[float64](v); better(f, bestF) {
+			best, bestF = v, f
+		}
+	}
+	if n, ok := integer(best); ok {
+		return n, nil
+	}
+	return bestF, nil
+}
+
+// aggregateNumbers collects the values at path for the aggregate name,
+// skipping nils and checking that the rest are numbers. ok is false when the
+// path does not resolve.
+func aggregateNumbers(i interface{}, path, name string) ([]interface{}, bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return nil, false, nil
+	}
+	segs := strings.Split(strings.NewReplacer("[", ".", "]", "").Replace(path), ".")
+	if len(segs) > DefaultMaxDepth {
+		return nil, false, nil
+	}
+	f, ok := getField(v, segs[0])
+	if !ok {
+		return nil, false, nil
+	}
+	var vals []interface{}
+	if !collectValues(f, segs[1:], &vals) {
+		return nil, false, nil
+	}
+	ns := vals[:0]
+	for _, val := range vals {
+		if val == nil {
+			continue
+		}
+		if _, ok := numeric[float64](val); !ok {
+			return nil, false, fmt.Errorf("%s of %s: %v is not a number", name, path, val)
+		}
+		ns = append(ns, val)
+	}
+	return ns, true, nil
+}
+
+// collectValues appends the values at segs below v to out, applying the
+// remaining segments to each element of the lists it meets unless the next
+// segment is an index. It reports whether any of the path resolved.
+func collectValues(v reflect.Value, segs []string, out *[]interface{}) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			*out = append(*out, nil)
+			return true
+		}
+		if _, ok := resolver(v); ok {
+			break
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		if len(segs) > 0 {
+			if n, err := strconv.Atoi(segs[0]); err == nil {
+				if n < 0 || n >= v.Len() {
+					return false
+				}
+				return collectValues(v.Index(n), segs[1:], out)
+			}
+		}
+		for n := 0; n < v.Len(); n++ {
+			collectValues(v.Index(n), segs, out)
+		}
+		return true
+	}
+	if len(segs) == 0 {
+		if v.CanInterface() {
+			*out = append(*out, v.Interface())
+		}
+		return true
+	}
+	f, ok := fieldByName(v, segs[0])
+	if !ok {
+		return false
+	}
+	return collectValues(f, segs[1:], out)
+}
@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+type aggItem struct {
+	Price float64
+	Qty   int
+}
+
+type aggOrder struct {
+	Items []*aggItem
+}
+
+func TestAggregateTerms(t *testing.T) {
+	rec := map[string]interface{}{
+		"Items": []interface{}{
+			map[string]interface{}{"Price": 120, "Qty": json.Number("2")},
+			map[string]interface{}{"Price": 80.5, "Qty": "3"},
+			map[string]interface{}{"Price": nil, "Qty": 1},
+			map[string]interface{}{"Name": "no price"},
+		},
+		"Orders": []interface{}{
+			map[string]interface{}{"Items": []interface{}{map[string]interface{}{"Price": 5}}},
+			map[string]interface{}{"Items": []interface{}{map[string]interface{}{"Price": 7}, map[string]interface{}{"Price": 9}}},
+		},
+		"Scores": []int{4, 8, 6},
+		"Big":    []int64{math.MaxInt64, 1},
+		"Empty":  []interface{}{},
+		"Total":  42,
+	}
+	cases := []struct {
+		name string
+		term Term
+		want interface{}
+	}{
+		{"sum floats", SumTerm{Field: "Items.Price"}, 200.5},
+		{"sum ints", SumTerm{Field: "Items.Qty"}, int64(6)},
+		{"sum list", SumTerm{Field: "Scores"}, int64(18)},
+		{"sum nested", SumTerm{Field: "Orders.Items.Price"}, int64(21)},
+		{"sum index", SumTerm{Field: "Orders.1.Items.Price"}, int64(16)},
+		{"sum overflow", SumTerm{Field: "Big"}, float64(math.MaxInt64) + 1},
+		{"sum empty", SumTerm{Field: "Empty"}, int64(0)},
+		{"sum scalar", SumTerm{Field: "Total"}, int64(42)},
+		{"sum missing", SumTerm{Field: "Missing.Price"}, nil},
+		{"min", MinTerm{Field: "Items.Price"}, 80.5},
+		{"min ints", MinTerm{Field: "Items.Qty"}, int64(1)},
+		{"max", MaxTerm{Field: "Items.Price"}, int64(120)},
+		{"max nested", MaxTerm{Field: "Orders.Items.Price"}, int64(9)},
+		{"max empty", MaxTerm{Field: "Empty"}, nil},
+		{"avg", AvgTerm{Field: "Scores"}, 6.0},
+		{"avg skips nil", AvgTerm{Field: "Items.Price"}, 100.25},
+		{"avg empty", AvgTerm{Field: "Empty"}, nil},
+	}
+	for _, tc := range cases {
+		got, err := tc.term.Evaluate(rec)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: expected %v (%T), got %v (%T) %v", tc.name, tc.want, tc.want, got, got, err)
+		}
+	}
+	if _, err := (SumTerm{Field: "Items.Name"}).Evaluate(rec); err == nil {
+		t.Error("expected an error for a non-numeric value")
+	}
+
+	order := &aggOrder{Items: []*aggItem{{Price: 10, Qty: 2}, nil, {Price: 2.5, Qty: 4}}}
+	if got, err := (SumTerm{Field: "Items.Price"}).Evaluate(order); err != nil || got != 12.5 {
+		t.Errorf("struct sum: got %v %v", got, err)
+	}
+	if got, err := (MaxTerm{Field: "Items.Qty"}).Evaluate(order); err != nil || got != int64(4) {
+		t.Errorf("struct max: got %v %v", got, err)
+	}
+}
+
+func TestAggregateComparison(t *testing.T) {
+	rec := map[string]interface{}{"Items": []interface{}{
+		map[string]interface{}{"Price": 300},
+		map[string]interface{}{"Price": 250},
+	}}
+	q := Query{Expression: &ComparisonExpression{LHS: SumTerm{Field: "Items.Price"}, RHS: Constant{Value: 500}, Operation: "gt"}}
+	ok, err := q.Evaluate(rec)
+	if err != nil || !ok {
+		t.Errorf("sum(Items.Price) > 500: got %v %v", ok, err)
+	}
+	ok, err = q.Evaluate(map[string]interface{}{})
+	if err != nil || ok {
+		t.Errorf("missing list: got %v %v", ok, err)
+	}
+}
@@ -35,5 +35,14 @@ type AND = evaluator.AndExpression
 // OR is a short alias for evaluator.OrExpression.
 type OR = evaluator.OrExpression
 
+// XOR is a short alias for evaluator.XorExpression.
+type XOR = evaluator.XorExpression
+
+// IMPLIES is a short alias for evaluator.ImpliesExpression.
+type IMPLIES = evaluator.ImpliesExpression
+
+// CASE is a short alias for evaluator.CaseExpression.
+type CASE = evaluator.CaseExpression
+
 // NOT is a short alias for evaluator.NotExpression.
 type NOT = evaluator.NotExpression
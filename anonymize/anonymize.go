@@ -0,0 +1,237 @@
+// Package anonymize applies redaction transforms to fields of records that
+// match a query, for producing shareable samples of sensitive data.
+package anonymize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+// Action is a transform applied to a field.
+type Action string
+
+const (
+	// Mask replaces every character of a string with '*'. Other values are
+	// replaced with evaluator.RedactedValue.
+	Mask Action = "mask"
+	// Hash replaces a value with the hex SHA-256 of the salt and its string
+	// form, so equal values stay equal across records.
+	Hash Action = "hash"
+	// Drop removes the field.
+	Drop Action = "drop"
+)
+
+// Rule applies Action to Fields of the records matching Where.
+type Rule struct {
+	Action Action
+	// Fields name top-level fields or nested paths such as Address.City
+	// and Items[0].Price.
+	Fields []string
+	// Where limits the rule to matching records; nil applies it to all.
+	Where *evaluator.Query
+}
+
+// ParseRule parses rules of the form
+//
+//	ACTION FIELD[, FIELD...] [where EXPRESSION]
+//
+// such as `hash Email where Country is not "US"`. The expression uses the
+// simple parser syntax.
+func ParseRule(s string) (Rule, error) {
+	action, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+	r := Rule{Action: Action(strings.ToLower(action))}
+	switch r.Action {
+	case Mask, Hash, Drop:
+	default:
+		return Rule{}, fmt.Errorf("unknown action %q", action)
+	}
+	fields, where, hasWhere := strings.Cut(rest, " where ")
+	for _, f := range strings.Split(fields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			r.Fields = append(r.Fields, f)
+		}
+	}
+	for _, f := range r.Fields {
+		if _, err := r.path(f); err != nil {
+			return Rule{}, fmt.Errorf("rule %q: %w", s, err)
+		}
+	}
+	if len(r.Fields) == 0 {
+		return Rule{}, fmt.Errorf("rule %q: no fields", s)
+	}
+	if hasWhere {
+		q, err := simple.Parse(where)
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %q: %w", s, err)
+		}
+		r.Where = &q
+	}
+	return r, nil
+}
+
+// ParseRules parses rules separated by semicolons.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		r, err := ParseRule(part)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Anonymizer applies a list of rules to records.
+type Anonymizer struct {
+	Rules []Rule
+	// Salt is mixed into hashed values so they cannot be recovered by
+	// hashing guesses.
+	Salt string
+}
+
+// Apply transforms rec in place. Every rule's Where is evaluated against the
+// record as it was before any transform, so the order of rules does not
+// change which records they select. opts are passed to Evaluate.
+func (a *Anonymizer) Apply(rec map[string]interface{}, opts ...any) error {
+	matched := make([]bool, len(a.Rules))
+	for i := range a.Rules {
+		r := &a.Rules[i]
+		if r.Where == nil {
+			matched[i] = true
+			continue
+		}
+		ok, err := r.Where.Evaluate(rec, opts...)
+		if err != nil {
+			return err
+		}
+		matched[i] = ok
+	}
+	for i, r := range a.Rules {
+		if !matched[i] {
+			continue
+		}
+		for _, f := range r.Fields {
+			p, err := r.path(f)
+			if err != nil {
+				return err
+			}
+			a.apply(r.Action, rec, p)
+		}
+	}
+	return nil
+}
+
+// apply transforms the value at p within rec. Missing fields, out of range
+// indexes and values of the wrong shape along the way are left alone.
+func (a *Anonymizer) apply(action Action, rec map[string]interface{}, p []pathStep) {
+	var parent interface{} = rec
+	for _, s := range p[:len(p)-1] {
+		v, ok := s.get(parent)
+		if !ok {
+			return
+		}
+		parent = v
+	}
+	last := p[len(p)-1]
+	v, ok := last.get(parent)
+	if !ok {
+		return
+	}
+	switch action {
+	case Drop:
+		delete(parent.(map[string]interface{}), last.key)
+	case Mask:
+		last.set(parent, mask(v))
+	case Hash:
+		last.set(parent, a.hash(v))
+	}
+}
+
+// path parses field f of the rule. List elements cannot be dropped, as that
+// would shift the indexes of the elements after them.
+func (r *Rule) path(f string) ([]pathStep, error) {
+	p, err := parsePath(f)
+	if err != nil {
+		return nil, err
+	}
+	if r.Action == Drop && p[len(p)-1].isIndex {
+		return nil, fmt.Errorf("cannot drop list element %q", f)
+	}
+	return p, nil
+}
+
+// pathStep is one part of a field path: a map key or a list index.
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s pathStep) get(c interface{}) (interface{}, bool) {
+	if s.isIndex {
+		l, ok := c.([]interface{})
+		if !ok || s.index >= len(l) {
+			return nil, false
+		}
+		return l[s.index], true
+	}
+	m, ok := c.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	v, ok := m[s.key]
+	return v, ok
+}
+
+func (s pathStep) set(c interface{}, v interface{}) {
+	if s.isIndex {
+		c.([]interface{})[s.index] = v
+		return
+	}
+	c.(map[string]interface{})[s.key] = v
+}
+
+// parsePath splits a field path such as Items[0].Price into its steps.
+func parsePath(f string) ([]pathStep, error) {
+	var p []pathStep
+	for _, part := range strings.Split(f, ".") {
+		name, rest, bracket := strings.Cut(part, "[")
+		if name == "" || bracket && rest == "" {
+			return nil, fmt.Errorf("invalid field path %q", f)
+		}
+		p = append(p, pathStep{key: name})
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			n, err := strconv.Atoi(idx)
+			if !ok || err != nil || n < 0 || (after != "" && after[0] != '[') {
+				return nil, fmt.Errorf("invalid field path %q", f)
+			}
+			p = append(p, pathStep{index: n, isIndex: true})
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return p, nil
+}
+
+func mask(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return strings.Repeat("*", utf8.RuneCountInString(s))
+	}
+	return evaluator.RedactedValue
+}
+
+func (a *Anonymizer) hash(v interface{}) string {
+	sum := sha256.Sum256([]byte(a.Salt + fmt.Sprint(v)))
+	return hex.EncodeToString(sum[:])
+}
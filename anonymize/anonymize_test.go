@@ -0,0 +1,102 @@
+package anonymize
+
+import (
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	r, err := ParseRule(`hash Email, Phone where Country is not "US"`)
+	if err != nil {
+		t.Fatalf("ParseRule: %v", err)
+	}
+	if r.Action != Hash || len(r.Fields) != 2 || r.Fields[1] != "Phone" || r.Where == nil {
+		t.Errorf("unexpected rule %+v", r)
+	}
+	if _, err := ParseRule("scramble Email"); err == nil {
+		t.Errorf("expected error for unknown action")
+	}
+	if _, err := ParseRule("drop"); err == nil {
+		t.Errorf("expected error for missing fields")
+	}
+	for _, bad := range []string{"mask Items[", "mask Items[x]", "mask .Name", "mask Items[0]x", "drop Items[0]"} {
+		if _, err := ParseRule(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestApplyNested(t *testing.T) {
+	rules, err := ParseRules(`mask Address.Street, Contacts[1].Phone, Missing.Field, Contacts[5].Phone; drop Address.Zip`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Anonymizer{Rules: rules}
+	rec := map[string]interface{}{
+		"Address": map[string]interface{}{"Street": "Main", "Zip": "1234"},
+		"Contacts": []interface{}{
+			map[string]interface{}{"Phone": "111"},
+			map[string]interface{}{"Phone": "2222"},
+		},
+	}
+	if err := a.Apply(rec); err != nil {
+		t.Fatal(err)
+	}
+	addr := rec["Address"].(map[string]interface{})
+	if addr["Street"] != "****" {
+		t.Errorf("street not masked: %v", addr)
+	}
+	if _, ok := addr["Zip"]; ok {
+		t.Errorf("zip not dropped: %v", addr)
+	}
+	contacts := rec["Contacts"].([]interface{})
+	if contacts[0].(map[string]interface{})["Phone"] != "111" || contacts[1].(map[string]interface{})["Phone"] != "****" {
+		t.Errorf("unexpected contacts %v", contacts)
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules, err := ParseRules(`hash Email where Country is not "US"; mask Name; drop SSN where Email is "a@example.com"`)
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	a := &Anonymizer{Rules: rules, Salt: "s"}
+
+	us := map[string]interface{}{"Email": "a@example.com", "Country": "US", "Name": "Ann", "SSN": "123", "Age": 3}
+	if err := a.Apply(us); err != nil {
+		t.Fatal(err)
+	}
+	if us["Email"] != "a@example.com" || us["Name"] != "***" {
+		t.Errorf("unexpected record %v", us)
+	}
+	if _, ok := us["SSN"]; ok {
+		t.Errorf("SSN not dropped")
+	}
+
+	nz1 := map[string]interface{}{"Email": "b@example.com", "Country": "NZ"}
+	nz2 := map[string]interface{}{"Email": "b@example.com", "Country": "NZ"}
+	_ = a.Apply(nz1)
+	_ = a.Apply(nz2)
+	if nz1["Email"] == "b@example.com" || nz1["Email"] != nz2["Email"] {
+		t.Errorf("expected stable hash, got %v and %v", nz1["Email"], nz2["Email"])
+	}
+	other := &Anonymizer{Rules: rules, Salt: "t"}
+	nz3 := map[string]interface{}{"Email": "b@example.com", "Country": "NZ"}
+	_ = other.Apply(nz3)
+	if nz3["Email"] == nz1["Email"] {
+		t.Errorf("salt not applied")
+	}
+}
+
+func TestApplyWhereSeesOriginal(t *testing.T) {
+	rules, err := ParseRules(`hash Email; drop Phone where Email is "a@example.com"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := map[string]interface{}{"Email": "a@example.com", "Phone": "555"}
+	if err := (&Anonymizer{Rules: rules}).Apply(rec); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rec["Phone"]; ok {
+		t.Errorf("later rule should match the original Email")
+	}
+}
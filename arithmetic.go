@@ -0,0 +1,164 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// AddTerm evaluates to LHS plus RHS, for example in a comparison such as
+// Price + Shipping > 100. Like the other arithmetic terms, operands are
+// coerced as comparisons coerce them, so numeric strings and json.Number
+// count as numbers; integer operands produce an int64 unless the result
+// overflows, and other numeric operands produce a float64. It evaluates to
+// nil when either operand is nil.
+type AddTerm struct {
+	LHS Term
+	RHS Term
+}
+
+func (a AddTerm) Evaluate(i interface{}, opts ...any) (interface{}, error) {
+	return arithmetic(i, opts, a.LHS, a.RHS, "addition", func(l, r int64) (int64, bool) {
+		n := l + r
+		return n, (n > l) == (r > 0)
+	}, func(l, r float64) (float64, error) {
+		return l + r, nil
+	})
+}
+
+// SubTerm evaluates to LHS minus RHS.
+type SubTerm struct {
+	LHS Term
+	RHS Term
+}
+
+func (s SubTerm) Evaluate(i interface{}, opts ...any) (interface{}, error) {
+	return arithmetic(i, opts, s.LHS, s.RHS, "subtraction", func(l, r int64) (int64, bool) {
+		n := l - r
+		return n, (n < l) == (r > 0)
+	}, func(l, r float64) (float64, error) {
+		return l - r, nil
+	})
+}
+
+// MulTerm evaluates to LHS times RHS, as in Price * Quantity > 1000.
+type MulTerm struct {
+	LHS Term
+	RHS Term
+}
+
+func (m MulTerm) Evaluate(i interface{}, opts ...any) (interface{}, error) {
+	return arithmetic(i, opts, m.LHS, m.RHS, "multiplication", func(l, r int64) (int64, bool) {
+		if l == 0 || r == 0 {
+			return 0, true
+		}
+		n := l * r
+		return n, n/r == l && !(l == math.MinInt64 && r == -1)
+	}, func(l, r float64) (float64, error) {
+		return l * r, nil
+	})
+}
+
+// DivTerm evaluates to LHS divided by RHS. Integer operands produce an
+// integer only when they divide exactly, so 7 / 2 is 3.5. Dividing by zero
+// is an error.
+type DivTerm struct {
+	LHS Term
+	RHS Term
+}
+
+func (d DivTerm) Evaluate(i interface{}, opts ...any) (interface{}, error) {
+	return arithmetic(i, opts, d.LHS, d.RHS, "division", func(l, r int64) (int64, bool) {
+		if r == 0 || l%r != 0 || (l == math.MinInt64 && r == -1) {
+			return 0, false
+		}
+		return l / r, true
+	}, func(l, r float64) (float64, error) {
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	})
+}
+
+// ModTerm evaluates to the remainder of LHS divided by RHS. Integer operands
+// produce an integer remainder; other numeric operands use math.Mod. As with
+// ModExpression the remainder is never negative, so X % 10 lies between 0
+// and 9 whatever the sign of X or of the divisor.
+type ModTerm struct {
+	LHS Term
+	RHS Term
+}
+
+func (m ModTerm) Evaluate(i interface{}, opts ...any) (interface{}, error) {
+	return arithmetic(i, opts, m.LHS, m.RHS, "modulo", func(l, r int64) (int64, bool) {
+		if r == 0 {
+			return 0, false
+		}
+		n := l % r
+		if n < 0 {
+			if r < 0 {
+				n -= r
+			} else {
+				n += r
+			}
+		}
+		return n, true
+	}, func(l, r float64) (float64, error) {
+		if r == 0 {
+			return 0, fmt.Errorf("modulo by zero")
+		}
+		n := math.Mod(l, r)
+		if n < 0 {
+			n += math.Abs(r)
+		}
+		return n, nil
+	})
+}
+
+// arithmetic evaluates lhs and rhs and combines them with ints when both are
+// integers and it succeeds, and with floats otherwise.
+func arithmetic(i interface{}, opts []any, lhs, rhs Term, name string, ints func(l, r int64) (int64, bool), floats func(l, r float64) (float64, error)) (interface{}, error) {
+	l, err := evalTerm(lhs, i, opts...)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	r, err := evalTerm(rhs, i, opts...)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if li, ok := integer(l); ok {
+		if ri, ok := integer(r); ok {
+			if n, ok := ints(li, ri); ok {
+				return n, nil
+			}
+		}
+	}
+	lf, ok1 := numeric[float64](l)
+	rf, ok2 := numeric[float64](r)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s of non-numeric values %v and %v", name, l, r)
+	}
+	n, err := floats(lf, rf)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// integer returns v as an int64 when it holds an integral value.
+func integer(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case float32, float64, string, json.Number:
+		f, ok := numeric[float64](n)
+		if !ok || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return 0, false
+		}
+		return int64(f), true
+	case uint, uint64, uintptr:
+		if u, ok := numeric[uint64](n); !ok || u > math.MaxInt64 {
+			return 0, false
+		}
+	}
+	return numeric[int64](v)
+}
@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestArithmeticTerms(t *testing.T) {
+	c := func(v interface{}) Term { return Constant{Value: v} }
+	cases := []struct {
+		name string
+		term Term
+		want interface{}
+	}{
+		{"add ints", AddTerm{LHS: c(2), RHS: c(3)}, int64(5)},
+		{"add mixed", AddTerm{LHS: c(2), RHS: c(0.5)}, 2.5},
+		{"add strings", AddTerm{LHS: c("2"), RHS: c(json.Number("3"))}, int64(5)},
+		{"add overflow", AddTerm{LHS: c(int64(math.MaxInt64)), RHS: c(1)}, float64(math.MaxInt64) + 1},
+		{"sub", SubTerm{LHS: c(2), RHS: c(5)}, int64(-3)},
+		{"sub overflow", SubTerm{LHS: c(int64(math.MinInt64)), RHS: c(1)}, float64(math.MinInt64) - 1},
+		{"mul", MulTerm{LHS: c(uint8(4)), RHS: c(int32(-3))}, int64(-12)},
+		{"mul float", MulTerm{LHS: c(float32(1.5)), RHS: c(2)}, 3.0},
+		{"mul overflow", MulTerm{LHS: c(int64(math.MinInt64)), RHS: c(-1)}, -float64(math.MinInt64)},
+		{"div exact", DivTerm{LHS: c(6), RHS: c(3)}, int64(2)},
+		{"div inexact", DivTerm{LHS: c(7), RHS: c(2)}, 3.5},
+		{"large uint", AddTerm{LHS: c(uint64(math.MaxUint64)), RHS: c(0)}, float64(math.MaxUint64)},
+		{"nil operand", AddTerm{LHS: c(nil), RHS: c(1)}, nil},
+	}
+	for _, tc := range cases {
+		got, err := tc.term.Evaluate(nil)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: expected %v (%T), got %v (%T) %v", tc.name, tc.want, tc.want, got, got, err)
+		}
+	}
+	for name, term := range map[string]Term{
+		"div by zero":   DivTerm{LHS: c(1), RHS: c(0)},
+		"float by zero": DivTerm{LHS: c(1.5), RHS: c(0.0)},
+		"non-numeric":   MulTerm{LHS: c("x"), RHS: c(2)},
+		"missing field": AddTerm{LHS: Field{Name: "Missing"}, RHS: c(1)},
+	} {
+		if _, err := term.Evaluate(map[string]interface{}{}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestArithmeticComparison(t *testing.T) {
+	q := Query{Expression: &ComparisonExpression{
+		LHS:       MulTerm{LHS: Field{Name: "Price"}, RHS: Field{Name: "Quantity"}},
+		RHS:       Constant{Value: 1000},
+		Operation: "gt",
+	}}
+	for _, tc := range []struct {
+		rec  interface{}
+		want bool
+	}{
+		{map[string]interface{}{"Price": 250.5, "Quantity": 4}, true},
+		{map[string]interface{}{"Price": "100", "Quantity": json.Number("10")}, false},
+		{struct{ Price, Quantity int }{Price: 30, Quantity: 40}, true},
+	} {
+		got, err := q.Evaluate(tc.rec)
+		if err != nil || got != tc.want {
+			t.Errorf("%v: expected %v, got %v %v", tc.rec, tc.want, got, err)
+		}
+	}
+}
+
+func TestModTerm(t *testing.T) {
+	cases := []struct {
+		l, r interface{}
+		want interface{}
+	}{
+		{7, 3, int64(1)},
+		{"7", 3, int64(1)},
+		{json.Number("9"), 4, int64(1)},
+		{7.5, 2, 1.5},
+		{-7, 3, int64(2)},
+		{7, -3, int64(1)},
+		{-7, -3, int64(2)},
+		{math.MinInt64, math.MinInt64 + 1, int64(math.MaxInt64 - 1)},
+		{-7.5, 2, 0.5},
+	}
+	for _, c := range cases {
+		got, err := ModTerm{LHS: Constant{Value: c.l}, RHS: Constant{Value: c.r}}.Evaluate(nil)
+		if err != nil || got != c.want {
+			t.Errorf("%v %% %v: expected %v, got %v (%v)", c.l, c.r, c.want, got, err)
+		}
+	}
+	if _, err := (ModTerm{LHS: Constant{Value: 1}, RHS: Constant{Value: 0}}).Evaluate(nil); err == nil {
+		t.Errorf("expected modulo by zero error")
+	}
+	if _, err := (ModTerm{LHS: Constant{Value: "x"}, RHS: Constant{Value: 2}}).Evaluate(nil); err == nil {
+		t.Errorf("expected error for non-numeric operand")
+	}
+}
@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// BetweenExpression succeeds when Field lies between Low and High. Numbers
+// are compared by value, strings lexically and time.Time fields against
+// time.Time or RFC 3339 bounds. The bounds themselves only match when
+// Inclusive is set.
+type BetweenExpression struct {
+	Field     string
+	Low       interface{}
+	High      interface{}
+	Inclusive bool
+}
+
+func (e BetweenExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || !f.IsValid() || !f.CanInterface() {
+		return false, nil
+	}
+	lo, ok, err := rangeCompare(f, e.Low, opts)
+	if !ok || err != nil {
+		return false, err
+	}
+	hi, ok, err := rangeCompare(f, e.High, opts)
+	if !ok || err != nil {
+		return false, err
+	}
+	if e.Inclusive {
+		return lo >= 0 && hi <= 0, nil
+	}
+	return lo > 0 && hi < 0, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// rangeCompare compares f with bound. It reports false when the two cannot be
+// ordered, such as a numeric field against a non-numeric bound.
+func rangeCompare(f reflect.Value, bound interface{}, opts []any) (int, bool, error) {
+	if c, ok, err := moneyCompare(f, bound, opts); ok {
+		return c, err == nil, err
+	}
+	if c, ok := decimalCompare(f, bound, opts); ok {
+		return c, true, nil
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return orderedCompare(f.Int(), bound)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return orderedCompare(f.Uint(), bound)
+	case reflect.Float32, reflect.Float64:
+		return orderedCompare(f.Float(), bound)
+	case reflect.String:
+		return strings.Compare(f.String(), stringValue(bound)), true, nil
+	case reflect.Struct:
+		if f.Type() != timeType {
+			return 0, false, nil
+		}
+		t, ok := timeBound(bound)
+		if !ok {
+			return 0, false, nil
+		}
+		return f.Interface().(time.Time).Compare(t), true, nil
+	}
+	return 0, false, nil
+}
+
+func orderedCompare[T number](f T, bound interface{}) (int, bool, error) {
+	n, ok := numeric[T](bound)
+	if !ok {
+		return 0, false, nil
+	}
+	switch {
+	case f < n:
+		return -1, true, nil
+	case f > n:
+		return 1, true, nil
+	}
+	return 0, true, nil
+}
+
+// timeBound converts a time.Time or RFC 3339 string into a time.Time.
+func timeBound(v interface{}) (time.Time, bool) {
+	return timeValue(v, nil)
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBetweenExpression(t *testing.T) {
+	type rec struct {
+		Age  int
+		Name string
+		At   time.Time
+	}
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := &rec{Age: 30, Name: "mallory", At: at}
+	tests := []struct {
+		name string
+		e    BetweenExpression
+		want bool
+	}{
+		{"inside", BetweenExpression{Field: "Age", Low: 18, High: 65}, true},
+		{"low edge exclusive", BetweenExpression{Field: "Age", Low: 30, High: 65}, false},
+		{"low edge inclusive", BetweenExpression{Field: "Age", Low: 30, High: 65, Inclusive: true}, true},
+		{"high edge inclusive", BetweenExpression{Field: "Age", Low: 18.5, High: "30", Inclusive: true}, true},
+		{"outside", BetweenExpression{Field: "Age", Low: 40, High: 65}, false},
+		{"non numeric bound", BetweenExpression{Field: "Age", Low: "x", High: 65}, false},
+		{"string", BetweenExpression{Field: "Name", Low: "m", High: "n"}, true},
+		{"string outside", BetweenExpression{Field: "Name", Low: "a", High: "m"}, false},
+		{"time", BetweenExpression{Field: "At", Low: at.Add(-time.Hour), High: at.Add(time.Hour)}, true},
+		{"time rfc3339", BetweenExpression{Field: "At", Low: "2024-06-01T12:00:00Z", High: "2024-07-01T00:00:00Z", Inclusive: true}, true},
+		{"time exclusive", BetweenExpression{Field: "At", Low: "2024-06-01T12:00:00Z", High: "2024-07-01T00:00:00Z"}, false},
+		{"time bad bound", BetweenExpression{Field: "At", Low: "yesterday", High: at}, false},
+		{"missing field", BetweenExpression{Field: "Other", Low: 0, High: 100}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBetweenExpressionJSON(t *testing.T) {
+	q := Query{Expression: &BetweenExpression{Field: "Age", Low: 18, High: 65, Inclusive: true}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"Between","Expression":{"Field":"Age","Low":18,"High":65,"Inclusive":true}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for age, want := range map[int]bool{17: false, 18: true, 65: true, 66: false} {
+		if got, err := back.Evaluate(map[string]interface{}{"Age": age}); err != nil || got != want {
+			t.Errorf("age %d: got %v, %v", age, got, err)
+		}
+	}
+}
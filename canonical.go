@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrHashMismatch is returned by VerifyHash when a query does not have the
+// expected hash.
+var ErrHashMismatch = errors.New("query hash mismatch")
+
+// Canonical returns a canonical JSON encoding of q. Nested And and Or
+// expressions of the same kind are flattened first so that equivalent
+// groupings such as (a and b) and c and a and (b and c) encode identically.
+// Metadata is not part of the canonical form. Queries carrying their own
+// OnEvalError policy are never merged into their parent.
+func Canonical(q Query) ([]byte, error) {
+	if q.Expression != nil {
+		q = Query{Expression: flatten(q.Expression), OnEvalError: q.OnEvalError}
+	}
+	return json.Marshal(Query{Expression: q.Expression, ExpressionRawJSON: q.ExpressionRawJSON, OnEvalError: q.OnEvalError})
+}
+
+func flatten(e Expression) Expression {
+	switch ex := e.(type) {
+	case *AndExpression:
+		var out []Query
+		for _, c := range ex.Expressions {
+			if c.Expression == nil {
+				out = append(out, c)
+				continue
+			}
+			c = Query{Expression: flatten(c.Expression), OnEvalError: c.OnEvalError}
+			if inner, ok := c.Expression.(*AndExpression); ok && c.OnEvalError == FailError {
+				out = append(out, inner.Expressions...)
+				continue
+			}
+			out = append(out, c)
+		}
+		return &AndExpression{Expressions: out}
+	case *OrExpression:
+		var out []Query
+		for _, c := range ex.Expressions {
+			if c.Expression == nil {
+				out = append(out, c)
+				continue
+			}
+			c = Query{Expression: flatten(c.Expression), OnEvalError: c.OnEvalError}
+			if inner, ok := c.Expression.(*OrExpression); ok && c.OnEvalError == FailError {
+				out = append(out, inner.Expressions...)
+				continue
+			}
+			out = append(out, c)
+		}
+		return &OrExpression{Expressions: out}
+	case *XorExpression:
+		// Exactly one of is not associative, so only the children flatten.
+		out := make([]Query, len(ex.Expressions))
+		for i, c := range ex.Expressions {
+			if c.Expression != nil {
+				c = Query{Expression: flatten(c.Expression), OnEvalError: c.OnEvalError}
+			}
+			out[i] = c
+		}
+		return &XorExpression{Expressions: out}
+	case *ImpliesExpression:
+		return &ImpliesExpression{If: flattenQuery(ex.If), Then: flattenQuery(ex.Then)}
+	case *CaseExpression:
+		out := &CaseExpression{Cases: make([]CaseBranch, len(ex.Cases)), Else: flattenQuery(ex.Else)}
+		for i, c := range ex.Cases {
+			out.Cases[i] = CaseBranch{When: flattenQuery(c.When), Then: flattenQuery(c.Then)}
+		}
+		return out
+	case *NotExpression:
+		if ex.Expression.Expression == nil {
+			return ex
+		}
+		return &NotExpression{Expression: Query{Expression: flatten(ex.Expression.Expression), OnEvalError: ex.Expression.OnEvalError}}
+	case *AnyExpression:
+		if ex.Query.Expression == nil {
+			return ex
+		}
+		return &AnyExpression{Field: ex.Field, Query: Query{Expression: flatten(ex.Query.Expression), OnEvalError: ex.Query.OnEvalError}}
+	case *AllExpression:
+		if ex.Query.Expression == nil {
+			return ex
+		}
+		return &AllExpression{Field: ex.Field, Query: Query{Expression: flatten(ex.Query.Expression), OnEvalError: ex.Query.OnEvalError}}
+	case *CountExpression:
+		if ex.Query.Expression == nil {
+			return ex
+		}
+		return &CountExpression{Field: ex.Field, Query: Query{Expression: flatten(ex.Query.Expression), OnEvalError: ex.Query.OnEvalError}, Op: ex.Op, Value: ex.Value}
+	case *ScoreQuery:
+		out := &ScoreQuery{Expressions: make([]WeightedExpression, len(ex.Expressions)), Threshold: ex.Threshold}
+		for i, w := range ex.Expressions {
+			out.Expressions[i] = WeightedExpression{Query: flattenQuery(w.Query), Weight: w.Weight}
+		}
+		return out
+	default:
+		return e
+	}
+}
+
+// flattenQuery flattens the expression of q, keeping its error policy.
+func flattenQuery(q Query) Query {
+	if q.Expression == nil {
+		return q
+	}
+	return Query{Expression: flatten(q.Expression), OnEvalError: q.OnEvalError}
+}
+
+// Hash returns the hex encoded SHA-256 digest of the canonical encoding of q.
+func Hash(q Query) (string, error) {
+	data, err := Canonical(q)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyHash checks that q hashes to expected. The expected value may carry
+// a "sha256:" prefix and is compared case-insensitively.
+func VerifyHash(q Query, expected string) error {
+	h, err := Hash(q)
+	if err != nil {
+		return err
+	}
+	expected = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(expected), "sha256:"))
+	if h != expected {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, expected, h)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanonicalFlattensGrouping(t *testing.T) {
+	a := Query{Expression: &IsExpression{Field: "A", Value: 1}}
+	b := Query{Expression: &IsExpression{Field: "B", Value: 2}}
+	c := Query{Expression: &IsExpression{Field: "C", Value: 3}}
+	left := Query{Expression: &AndExpression{Expressions: []Query{{Expression: &AndExpression{Expressions: []Query{a, b}}}, c}}}
+	right := Query{Expression: &AndExpression{Expressions: []Query{a, {Expression: &AndExpression{Expressions: []Query{b, c}}}}}}
+	h1, err := Hash(left)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	h2, err := Hash(right)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if h1 != h2 {
+		t.Errorf("equivalent groupings hash differently: %s vs %s", h1, h2)
+	}
+	other, _ := Hash(Query{Expression: &OrExpression{Expressions: []Query{a, b, c}}})
+	if other == h1 {
+		t.Errorf("different queries share a hash")
+	}
+}
+
+func TestVerifyHash(t *testing.T) {
+	q := Query{Expression: &IsExpression{Field: "Name", Value: "bob"}}
+	h, err := Hash(q)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if len(h) != 64 {
+		t.Errorf("unexpected hash %q", h)
+	}
+	if err := VerifyHash(q, "sha256:"+h); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	q2 := Query{Expression: &IsExpression{Field: "Name", Value: "alice"}}
+	if err := VerifyHash(q2, h); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package evaluator
+
+// CaseBranch is one When/Then pair of a CaseExpression.
+type CaseBranch struct {
+	When Query `json:"When"`
+	Then Query `json:"Then"`
+}
+
+// CaseExpression evaluates like a switch: the result is the Then of the first
+// branch whose When matches, or Else when none does. Later branches are not
+// evaluated, so each When may assume the earlier ones failed. An Else without
+// an Expression does not match.
+type CaseExpression struct {
+	Cases []CaseBranch `json:"Cases"`
+	Else  Query        `json:"Else"`
+}
+
+func (e CaseExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	for _, c := range e.Cases {
+		matched, err := c.When.Evaluate(i, opts...)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return c.Then.Evaluate(i, opts...)
+		}
+	}
+	return e.Else.Evaluate(i, opts...)
+}
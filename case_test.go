@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCaseExpression(t *testing.T) {
+	is := func(field string, v interface{}) Query {
+		return Query{Expression: &IsExpression{Field: field, Value: v}}
+	}
+	atLeast := func(n int) Query {
+		return Query{Expression: &GreaterThanOrEqualExpression{Field: "Replicas", Value: n}}
+	}
+	e := &CaseExpression{
+		Cases: []CaseBranch{
+			{When: is("Env", "prod"), Then: atLeast(3)},
+			{When: is("Env", "staging"), Then: atLeast(2)},
+		},
+		Else: atLeast(1),
+	}
+	tests := []struct {
+		env      string
+		replicas int
+		want     bool
+	}{
+		{"prod", 3, true},
+		{"prod", 2, false},
+		{"staging", 2, true},
+		{"staging", 1, false},
+		{"dev", 1, true},
+		{"dev", 0, false},
+	}
+	for _, tt := range tests {
+		rec := map[string]interface{}{"Env": tt.env, "Replicas": tt.replicas}
+		if got, err := e.Evaluate(rec); err != nil || got != tt.want {
+			t.Errorf("%s with %d: got %v %v, want %v", tt.env, tt.replicas, got, err, tt.want)
+		}
+	}
+
+	noElse := CaseExpression{Cases: e.Cases}
+	if got, err := noElse.Evaluate(map[string]interface{}{"Env": "dev"}); err != nil || got {
+		t.Errorf("no branch and no Else should not match: %v %v", got, err)
+	}
+	first := CaseExpression{Cases: []CaseBranch{
+		{When: is("Env", "prod"), Then: is("Env", "prod")},
+		{When: is("Env", "prod"), Then: Query{Expression: failingExpression{}}},
+	}}
+	if got, err := first.Evaluate(map[string]interface{}{"Env": "prod"}); err != nil || !got {
+		t.Errorf("only the first matching branch should run: %v %v", got, err)
+	}
+
+	data, err := json.Marshal(Query{Expression: e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := back.Evaluate(map[string]interface{}{"Env": "staging", "Replicas": 1}); err != nil || got {
+		t.Errorf("round trip %s: got %v %v", data, got, err)
+	}
+}
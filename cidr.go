@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"sync/atomic"
+)
+
+// CIDRContainsExpression succeeds when the IP address in Field falls within
+// the CIDR range, such as 10.0.0.0/8 or fd00::/8. The field may hold a
+// string, a netip.Addr or a net.IP; IPv4-mapped IPv6 addresses match IPv4
+// ranges. Fields that are not addresses do not match, while an invalid CIDR
+// is reported as an error. The parsed range is cached after the first
+// evaluation.
+type CIDRContainsExpression struct {
+	Field  string
+	CIDR   string
+	prefix atomic.Pointer[parsedPrefix]
+}
+
+type parsedPrefix struct {
+	cidr   string
+	prefix netip.Prefix
+}
+
+func (e *CIDRContainsExpression) parsePrefix() (netip.Prefix, error) {
+	if p := e.prefix.Load(); p != nil && p.cidr == e.CIDR {
+		return p.prefix, nil
+	}
+	prefix, err := netip.ParsePrefix(e.CIDR)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	prefix = prefix.Masked()
+	e.prefix.Store(&parsedPrefix{cidr: e.CIDR, prefix: prefix})
+	return prefix, nil
+}
+
+func (e *CIDRContainsExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	prefix, err := e.parsePrefix()
+	if err != nil {
+		return false, err
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok {
+		return false, nil
+	}
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return false, nil
+		}
+		f = f.Elem()
+	}
+	addr, ok := fieldAddr(f)
+	if !ok {
+		return false, nil
+	}
+	if prefix.Addr().Is4() {
+		addr = addr.Unmap()
+	}
+	return prefix.Contains(addr), nil
+}
+
+// fieldAddr converts a string, netip.Addr or net.IP field into an address.
+func fieldAddr(f reflect.Value) (netip.Addr, bool) {
+	if !f.CanInterface() {
+		return netip.Addr{}, false
+	}
+	switch a := f.Interface().(type) {
+	case netip.Addr:
+		return a, a.IsValid()
+	case net.IP:
+		return netip.AddrFromSlice(a)
+	}
+	if f.Kind() == reflect.String {
+		addr, err := netip.ParseAddr(f.String())
+		return addr, err == nil
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,75 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestCIDRContainsExpression(t *testing.T) {
+	type flow struct {
+		SrcIP  string
+		DstIP  netip.Addr
+		Gate   net.IP
+		Mapped string
+		Port   int
+	}
+	r := &flow{
+		SrcIP:  "10.1.2.3",
+		DstIP:  netip.MustParseAddr("fd00::1"),
+		Gate:   net.ParseIP("192.168.1.1"),
+		Mapped: "::ffff:10.0.0.9",
+		Port:   443,
+	}
+	tests := []struct {
+		name string
+		e    *CIDRContainsExpression
+		want bool
+	}{
+		{"inside", &CIDRContainsExpression{Field: "SrcIP", CIDR: "10.0.0.0/8"}, true},
+		{"outside", &CIDRContainsExpression{Field: "SrcIP", CIDR: "192.168.0.0/16"}, false},
+		{"host bits set", &CIDRContainsExpression{Field: "SrcIP", CIDR: "10.1.2.0/24"}, true},
+		{"netip ipv6", &CIDRContainsExpression{Field: "DstIP", CIDR: "fd00::/8"}, true},
+		{"family mismatch", &CIDRContainsExpression{Field: "DstIP", CIDR: "0.0.0.0/0"}, false},
+		{"net.IP", &CIDRContainsExpression{Field: "Gate", CIDR: "192.168.0.0/16"}, true},
+		{"mapped", &CIDRContainsExpression{Field: "Mapped", CIDR: "10.0.0.0/8"}, true},
+		{"not an address", &CIDRContainsExpression{Field: "Port", CIDR: "10.0.0.0/8"}, false},
+		{"missing", &CIDRContainsExpression{Field: "Other", CIDR: "10.0.0.0/8"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.Evaluate(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := (&CIDRContainsExpression{Field: "SrcIP", CIDR: "10.0.0.0/33"}).Evaluate(r); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+	if _, err := NewCIDRContainsExpression("SrcIP", "10.0.0.1"); err == nil {
+		t.Error("expected error for address without prefix length")
+	}
+}
+
+func TestCIDRContainsJSON(t *testing.T) {
+	data := []byte(`{"Expression":{"Type":"CIDRContains","Expression":{"Field":"ip","CIDR":"172.16.0.0/12"}}}`)
+	var q Query
+	if err := json.Unmarshal(data, &q); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := q.Evaluate(map[string]interface{}{"ip": "172.20.0.5"}); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	out, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(data) {
+		t.Errorf("round trip %s", out)
+	}
+}
@@ -55,7 +55,7 @@ func BenchmarkProcess(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		r := bytes.NewReader(inputData)
 		wh := true
-		if err := process(r, q, &wh); err != nil {
+		if err := process(r, q, &wh, nil); err != nil {
 			b.Fatal(err)
 		}
 	}
@@ -83,7 +83,7 @@ func TestProcess_Functional(t *testing.T) {
 
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- process(reader, q, &wh)
+		errChan <- process(reader, q, &wh, nil)
 		_ = w.Close()
 	}()
 
@@ -12,7 +12,7 @@ import (
 	"github.com/arran4/go-evaluator/parser/simple"
 )
 
-func process(r io.Reader, q evaluator.Query, writeHeader *bool) error {
+func process(r io.Reader, q evaluator.Query, writeHeader *bool, numbers *evaluator.NumberFormat) error {
 	cr := csv.NewReader(r)
 	headers, err := cr.Read()
 	if err != nil {
@@ -38,6 +38,11 @@ func process(r io.Reader, q evaluator.Query, writeHeader *bool) error {
 		for i, h := range headers {
 			if i < len(rec) {
 				m[h] = rec[i]
+				if numbers != nil {
+					if f, ok := numbers.Parse(rec[i]); ok {
+						m[h] = f
+					}
+				}
 			}
 		}
 		v, err := q.Evaluate(m)
@@ -63,6 +68,7 @@ func usage() {
 func main() {
 	flag.Usage = usage
 	expr := flag.String("e", "", "expression to apply to each row")
+	locale := flag.String("locale", "", "compare cells holding numbers in this locale (en, eu, de, fr, ch) numerically")
 	flag.Parse()
 	if *expr == "" {
 		log.Fatal("-e expression required")
@@ -71,10 +77,18 @@ func main() {
 	if err != nil {
 		log.Fatalf("parse expression: %v", err)
 	}
+	var numbers *evaluator.NumberFormat
+	if *locale != "" {
+		nf, err := evaluator.LookupNumberFormat(*locale)
+		if err != nil {
+			log.Fatal(err)
+		}
+		numbers = &nf
+	}
 	files := flag.Args()
 	writeHeader := true
 	if len(files) == 0 {
-		if err := process(os.Stdin, q, &writeHeader); err != nil {
+		if err := process(os.Stdin, q, &writeHeader, numbers); err != nil {
 			log.Fatal(err)
 		}
 		return
@@ -84,7 +98,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		if err := process(fh, q, &writeHeader); err != nil {
+		if err := process(fh, q, &writeHeader, numbers); err != nil {
 			_ = fh.Close()
 			log.Fatal(err)
 		}
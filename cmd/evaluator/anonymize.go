@@ -0,0 +1,68 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*AnonymizeCmd)(nil)
+
+type AnonymizeCmd struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	rules       string
+	salt        string
+	files       []string
+	SubCommands map[string]Cmd
+}
+
+func (c *AnonymizeCmd) Usage() {
+	err := executeUsage(os.Stderr, "anonymize_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *AnonymizeCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+	remainingArgs := c.Flags.Args()
+	// Handle vararg files
+	{
+		varArgStart := 0
+		if varArgStart > len(remainingArgs) {
+			varArgStart = len(remainingArgs)
+		}
+		varArgs := remainingArgs[varArgStart:]
+		c.files = varArgs
+	}
+
+	Anonymize(c.rules, c.salt, c.files...)
+
+	return nil
+}
+
+func (c *RootCmd) NewAnonymize() *AnonymizeCmd {
+	set := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	v := &AnonymizeCmd{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.rules, "r", "", "Rules separated by semicolons")
+	set.StringVar(&v.salt, "salt", "", "Salt for hashed values")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -14,6 +14,12 @@ type Csvfilter struct {
 	*RootCmd
 	Flags       *flag.FlagSet
 	expr        string
+	ignoreCase  bool
+	locale      string
+	inFile      string
+	notInFile   string
+	bloom       bool
+	classify    string
 	files       []string
 	SubCommands map[string]Cmd
 }
@@ -46,7 +52,7 @@ func (c *Csvfilter) Execute(args []string) error {
 		c.files = varArgs
 	}
 
-	CsvFilter(c.expr, c.files...)
+	CsvFilter(c.expr, c.ignoreCase, c.locale, c.inFile, c.notInFile, c.bloom, c.classify, c.files...)
 
 	return nil
 }
@@ -60,6 +66,12 @@ func (c *RootCmd) NewCsvfilter() *Csvfilter {
 	}
 
 	set.StringVar(&v.expr, "e", "", "Expression")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.StringVar(&v.locale, "locale", "", "Number locale (en, eu, de, fr, ch)")
+	set.StringVar(&v.inFile, "in-file", "", "Keep rows whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)")
+	set.StringVar(&v.notInFile, "not-in-file", "", "Drop rows whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)")
+	set.BoolVar(&v.bloom, "bloom", false, "Load value lists into bloom filters")
+	set.StringVar(&v.classify, "classify", "", "Append a true/false column per NAME=EXPRESSION line in this file")
 	set.Usage = v.Usage
 
 	return v
@@ -0,0 +1,63 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*DoctorCmd)(nil)
+
+type DoctorCmd struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	storeDir    string
+	keyFile     string
+	hashes      string
+	schema      string
+	samples     string
+	SubCommands map[string]Cmd
+}
+
+func (c *DoctorCmd) Usage() {
+	err := executeUsage(os.Stderr, "doctor_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *DoctorCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	Doctor(c.storeDir, c.keyFile, c.hashes, c.schema, c.samples)
+
+	return nil
+}
+
+func (c *RootCmd) NewDoctor() *DoctorCmd {
+	set := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	v := &DoctorCmd{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.storeDir, "store", "", "Query store directory")
+	set.StringVar(&v.keyFile, "key-file", "", "File holding the hex AES key for sealed queries")
+	set.StringVar(&v.hashes, "hashes", "", "JSON file mapping query names to expected hashes")
+	set.StringVar(&v.schema, "schema", "", "JSON file whose keys are the known field names")
+	set.StringVar(&v.samples, "samples", "", "JSON Lines file of sample records")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -0,0 +1,67 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Eventlogfilter)(nil)
+
+type Eventlogfilter struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	log         string
+	expr        string
+	ignoreCase  bool
+	xpath       string
+	count       int
+	newest      bool
+	stats       bool
+	SubCommands map[string]Cmd
+}
+
+func (c *Eventlogfilter) Usage() {
+	err := executeUsage(os.Stderr, "eventlogfilter_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Eventlogfilter) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	EventLogFilter(c.log, c.expr, c.ignoreCase, c.xpath, c.count, c.newest, c.stats)
+
+	return nil
+}
+
+func (c *RootCmd) NewEventlogfilter() *Eventlogfilter {
+	set := flag.NewFlagSet("eventlogfilter", flag.ContinueOnError)
+	v := &Eventlogfilter{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.log, "log", "", "Event log to read (default System)")
+	set.StringVar(&v.expr, "e", "", "Expression")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.StringVar(&v.xpath, "q", "", "XPath filter applied by the event log service")
+	set.IntVar(&v.count, "n", 0, "Read at most this many records")
+	set.BoolVar(&v.newest, "newest", false, "Read the most recent records first")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr on exit")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -0,0 +1,59 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*FmtCmd)(nil)
+
+type FmtCmd struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	expr        string
+	queryFile   string
+	simplify    bool
+	SubCommands map[string]Cmd
+}
+
+func (c *FmtCmd) Usage() {
+	err := executeUsage(os.Stderr, "fmt_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *FmtCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	Fmt(c.expr, c.queryFile, c.simplify)
+
+	return nil
+}
+
+func (c *RootCmd) NewFmt() *FmtCmd {
+	set := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	v := &FmtCmd{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.expr, "e", "", "Expression")
+	set.StringVar(&v.queryFile, "q", "", "Query JSON file")
+	set.BoolVar(&v.simplify, "simplify", false, "Push negations down to the comparisons they negate")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -8,27 +8,148 @@ import (
 // Flags:
 //
 //	expr: -e Expression
+//	ignoreCase: -i Compare strings case-insensitively
+//	locale: -locale Number locale (en, eu, de, fr, ch)
+//	inFile: -in-file Keep rows whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)
+//	notInFile: -not-in-file Drop rows whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)
+//	bloom: -bloom Load value lists into bloom filters
+//	classify: -classify Append a true/false column per NAME=EXPRESSION line in this file
 //	files: ... Files
-func CsvFilter(expr string, files ...string) {
-	lib.CsvFilter(expr, files...)
+func CsvFilter(expr string, ignoreCase bool, locale string, inFile string, notInFile string, bloom bool, classify string, files ...string) {
+	lib.CsvFilter(expr, ignoreCase, locale, inFile, notInFile, bloom, classify, files...)
 }
 
 // JsonlFilter is a subcommand `evaluator jsonlfilter`
 // Flags:
 //
 //	expr: -e Expression
+//	ignoreCase: -i Compare strings case-insensitively
+//	maxRecordSize: -max-record-size Largest record in bytes (0 for no limit)
+//	maxInFlight: -max-in-flight Records buffered in parallel mode (default 2x workers)
+//	memoryBudget: -memory-budget Total bytes of buffered records (0 for no limit)
+//	workers: -workers Records evaluated in parallel
+//	stats: -stats Print statistics to stderr
+//	post: -post POST matches as JSON Lines to this URL
+//	batch: -batch Records per POST (0 for one request per file)
+//	lookup: -lookup Join NAME=FIELD:FILE.csv tables, separated by semicolons
+//	inFile: -in-file Keep records whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)
+//	notInFile: -not-in-file Drop records whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)
+//	bloom: -bloom Load value lists into bloom filters
+//	classify: -classify Add a true/false field per NAME=EXPRESSION line in this file
 //	files: ... Files
-func JsonlFilter(expr string, files ...string) {
-	lib.JsonlFilter(expr, files...)
+func JsonlFilter(expr string, ignoreCase bool, maxRecordSize int, maxInFlight int, memoryBudget int, workers int, stats bool, post string, batch int, lookup string, inFile string, notInFile string, bloom bool, classify string, files ...string) {
+	lib.JsonlFilter(expr, ignoreCase, maxRecordSize, maxInFlight, memoryBudget, workers, stats, post, batch, lookup, inFile, notInFile, bloom, classify, files...)
+}
+
+// RedisFilter is a subcommand `evaluator redisfilter`
+// Flags:
+//
+//	addr: -addr Redis address (default localhost:6379)
+//	password: -password Redis password
+//	from: -from Read messages from channel:NAME or stream:NAME
+//	to: -to Republish matches to channel:NAME or stream:NAME
+//	field: -field Stream entry field holding the JSON payload (default data)
+//	expr: -e Expression
+//	ignoreCase: -i Compare strings case-insensitively
+//	stats: -stats Print statistics to stderr on exit
+func RedisFilter(addr string, password string, from string, to string, field string, expr string, ignoreCase bool, stats bool) {
+	lib.RedisFilter(addr, password, from, to, field, expr, ignoreCase, stats)
+}
+
+// NatsFilter is a subcommand `evaluator natsfilter`
+// Flags:
+//
+//	server: -server NATS server address or nats:// URL (default localhost:4222)
+//	subject: -subject Subject to subscribe to
+//	queue: -queue Queue group for -subject
+//	stream: -stream JetStream stream of -consumer
+//	consumer: -consumer JetStream pull consumer to fetch from instead of -subject
+//	to: -to Subject matches are republished to
+//	headers: -headers Evaluate message headers instead of the JSON payload
+//	noMatch: -no-match Acknowledge JetStream messages that do not match with ack, nak or term (default ack)
+//	expr: -e Expression
+//	ignoreCase: -i Compare strings case-insensitively
+//	stats: -stats Print statistics to stderr on exit
+func NatsFilter(server string, subject string, queue string, stream string, consumer string, to string, headers bool, noMatch string, expr string, ignoreCase bool, stats bool) {
+	lib.NatsFilter(server, subject, queue, stream, consumer, to, headers, noMatch, expr, ignoreCase, stats)
+}
+
+// MqttFilter is a subcommand `evaluator mqttfilter`
+// Flags:
+//
+//	server: -server MQTT broker address (default localhost:1883)
+//	config: -c YAML file of rules giving a topic filter, expression and destination topic
+//	clientID: -client-id Client identifier
+//	username: -username User name
+//	password: -password Password
+//	ignoreCase: -i Compare strings case-insensitively
+//	stats: -stats Print statistics to stderr on exit
+func MqttFilter(server string, config string, clientID string, username string, password string, ignoreCase bool, stats bool) {
+	lib.MqttFilter(server, config, clientID, username, password, ignoreCase, stats)
+}
+
+// WebhookRoute is a subcommand `evaluator webhookroute`
+// Flags:
+//
+//	listen: -listen Address to listen on (default :8080)
+//	config: -c JSON rules file with Routes mapping rule names to destination URLs
+//	retries: -retries Times a failed delivery is retried, or -1 for none (default 3)
+//	maxConcurrent: -max-concurrent Webhooks handled at once, or 0 for no limit
+//	queueTimeout: -queue-timeout How long a webhook waits for a free slot before a 429, e.g. 2s
+//	stats: -stats Print statistics to stderr on exit
+func WebhookRoute(listen string, config string, retries int, maxConcurrent int, queueTimeout string, stats bool) {
+	lib.WebhookRoute(listen, config, retries, maxConcurrent, queueTimeout, stats)
+}
+
+// JournalFilter is a subcommand `evaluator journalfilter`
+// Flags:
+//
+//	expr: -e Expression
+//	ignoreCase: -i Compare strings case-insensitively
+//	follow: -f Keep printing new entries until interrupted
+//	units: -u Comma-separated systemd units to read
+//	since: -since Start with entries newer than this, e.g. "1 hour ago"
+//	lines: -n Start with this many recent entries
+//	stats: -stats Print statistics to stderr on exit
+//	matches: ... Journald matches passed to journalctl, such as _PID=1
+func JournalFilter(expr string, ignoreCase bool, follow bool, units string, since string, lines int, stats bool, matches ...string) {
+	lib.JournalFilter(expr, ignoreCase, follow, units, since, lines, stats, matches...)
+}
+
+// EventLogFilter is a subcommand `evaluator eventlogfilter`
+// Flags:
+//
+//	log: -log Event log to read (default System)
+//	expr: -e Expression
+//	ignoreCase: -i Compare strings case-insensitively
+//	xpath: -q XPath filter applied by the event log service
+//	count: -n Read at most this many records
+//	newest: -newest Read the most recent records first
+//	stats: -stats Print statistics to stderr on exit
+func EventLogFilter(log string, expr string, ignoreCase bool, xpath string, count int, newest bool, stats bool) {
+	lib.EventLogFilter(log, expr, ignoreCase, xpath, count, newest, stats)
+}
+
+// PcapFilter is a subcommand `evaluator pcapfilter`
+// Flags:
+//
+//	expr: -e Expression
+//	ignoreCase: -i Compare strings case-insensitively
+//	flows: -flows Aggregate packets into flows and filter those
+//	stats: -stats Print statistics to stderr on exit
+//	files: ... Capture files, or stdin when none are given
+func PcapFilter(expr string, ignoreCase bool, flows bool, stats bool, files ...string) {
+	lib.PcapFilter(expr, ignoreCase, flows, stats, files...)
 }
 
 // JSONTest is a subcommand `evaluator jsontest`
 // Flags:
 //
 //	expr: -e Expression
+//	path: -path Stream the array at this dotted path and match any element
 //	files: ... Files
-func JSONTest(expr string, files ...string) {
-	lib.JSONTest(expr, files...)
+func JSONTest(expr string, path string, files ...string) {
+	lib.JSONTest(expr, path, files...)
 }
 
 // YamlTest is a subcommand `evaluator yamltest`
@@ -40,4 +161,58 @@ func YamlTest(expr string, files ...string) {
 	lib.YamlTest(expr, files...)
 }
 
+// Verify is a subcommand `evaluator verify`
+// Flags:
+//
+//	expr: -e Expression
+//	queryFile: -q Query JSON file
+//	expectHash: -expect-hash Expected query hash
+func Verify(expr string, queryFile string, expectHash string) {
+	lib.Verify(expr, queryFile, expectHash)
+}
+
+// Fmt is a subcommand `evaluator fmt`
+// Flags:
+//
+//	expr: -e Expression
+//	queryFile: -q Query JSON file
+//	simplify: -simplify Push negations down to the comparisons they negate
+func Fmt(expr string, queryFile string, simplify bool) {
+	lib.Fmt(expr, queryFile, simplify)
+}
+
 //go:generate go run github.com/arran4/go-subcommand/cmd/gosubc generate --dir ../..
+
+// Anonymize is a subcommand `evaluator anonymize`
+// Flags:
+//
+//	rules: -r Rules separated by semicolons
+//	salt: -salt Salt for hashed values
+//	files: ... Files
+func Anonymize(rules string, salt string, files ...string) {
+	lib.Anonymize(rules, salt, files...)
+}
+
+// SetOp is a subcommand `evaluator setop`
+// Flags:
+//
+//	op: -op Operation: union, intersection or difference
+//	key: -key Key field
+//	a: -a First expression
+//	b: -b Second expression
+//	files: ... Files
+func SetOp(op string, key string, a string, b string, files ...string) {
+	lib.SetOp(op, key, a, b, files...)
+}
+
+// Doctor is a subcommand `evaluator doctor`
+// Flags:
+//
+//	storeDir: -store Query store directory
+//	keyFile: -key-file File holding the hex AES key for sealed queries
+//	hashes: -hashes JSON file mapping query names to expected hashes
+//	schema: -schema JSON file whose keys are the known field names
+//	samples: -samples JSON Lines file of sample records
+func Doctor(storeDir string, keyFile string, hashes string, schema string, samples string) {
+	lib.Doctor(storeDir, keyFile, hashes, schema, samples)
+}
@@ -0,0 +1,78 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Journalfilter)(nil)
+
+type Journalfilter struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	expr        string
+	ignoreCase  bool
+	follow      bool
+	units       string
+	since       string
+	lines       int
+	stats       bool
+	matches     []string
+	SubCommands map[string]Cmd
+}
+
+func (c *Journalfilter) Usage() {
+	err := executeUsage(os.Stderr, "journalfilter_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Journalfilter) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+	remainingArgs := c.Flags.Args()
+	// Handle vararg matches
+	{
+		varArgStart := 0
+		if varArgStart > len(remainingArgs) {
+			varArgStart = len(remainingArgs)
+		}
+		varArgs := remainingArgs[varArgStart:]
+		c.matches = varArgs
+	}
+
+	JournalFilter(c.expr, c.ignoreCase, c.follow, c.units, c.since, c.lines, c.stats, c.matches...)
+
+	return nil
+}
+
+func (c *RootCmd) NewJournalfilter() *Journalfilter {
+	set := flag.NewFlagSet("journalfilter", flag.ContinueOnError)
+	v := &Journalfilter{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.expr, "e", "", "Expression")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.BoolVar(&v.follow, "f", false, "Keep printing new entries until interrupted")
+	set.StringVar(&v.units, "u", "", "Comma-separated systemd units to read")
+	set.StringVar(&v.since, "since", "", "Start with entries newer than this, e.g. \"1 hour ago\"")
+	set.IntVar(&v.lines, "n", 0, "Start with this many recent entries")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr on exit")
+	set.Usage = v.Usage
+
+	return v
+}
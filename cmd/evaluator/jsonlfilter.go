@@ -12,10 +12,23 @@ var _ Cmd = (*Jsonlfilter)(nil)
 
 type Jsonlfilter struct {
 	*RootCmd
-	Flags       *flag.FlagSet
-	expr        string
-	files       []string
-	SubCommands map[string]Cmd
+	Flags         *flag.FlagSet
+	expr          string
+	ignoreCase    bool
+	maxRecordSize int
+	maxInFlight   int
+	memoryBudget  int
+	workers       int
+	stats         bool
+	post          string
+	batch         int
+	lookup        string
+	inFile        string
+	notInFile     string
+	bloom         bool
+	classify      string
+	files         []string
+	SubCommands   map[string]Cmd
 }
 
 func (c *Jsonlfilter) Usage() {
@@ -46,7 +59,7 @@ func (c *Jsonlfilter) Execute(args []string) error {
 		c.files = varArgs
 	}
 
-	JsonlFilter(c.expr, c.files...)
+	JsonlFilter(c.expr, c.ignoreCase, c.maxRecordSize, c.maxInFlight, c.memoryBudget, c.workers, c.stats, c.post, c.batch, c.lookup, c.inFile, c.notInFile, c.bloom, c.classify, c.files...)
 
 	return nil
 }
@@ -60,6 +73,19 @@ func (c *RootCmd) NewJsonlfilter() *Jsonlfilter {
 	}
 
 	set.StringVar(&v.expr, "e", "", "Expression")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.IntVar(&v.maxRecordSize, "max-record-size", 0, "Largest record in bytes (0 for no limit)")
+	set.IntVar(&v.maxInFlight, "max-in-flight", 0, "Records buffered in parallel mode (default 2x workers)")
+	set.IntVar(&v.memoryBudget, "memory-budget", 0, "Total bytes of buffered records (0 for no limit)")
+	set.IntVar(&v.workers, "workers", 0, "Records evaluated in parallel")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr")
+	set.StringVar(&v.post, "post", "", "POST matches as JSON Lines to this URL")
+	set.IntVar(&v.batch, "batch", 0, "Records per POST (0 for one request per file)")
+	set.StringVar(&v.lookup, "lookup", "", "Join NAME=FIELD:FILE.csv tables, separated by semicolons")
+	set.StringVar(&v.inFile, "in-file", "", "Keep records whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)")
+	set.StringVar(&v.notInFile, "not-in-file", "", "Drop records whose FIELD value is listed in PATH (FIELD=PATH, separated by semicolons)")
+	set.BoolVar(&v.bloom, "bloom", false, "Load value lists into bloom filters")
+	set.StringVar(&v.classify, "classify", "", "Add a true/false field per NAME=EXPRESSION line in this file")
 	set.Usage = v.Usage
 
 	return v
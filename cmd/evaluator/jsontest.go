@@ -14,6 +14,7 @@ type Jsontest struct {
 	*RootCmd
 	Flags       *flag.FlagSet
 	expr        string
+	path        string
 	files       []string
 	SubCommands map[string]Cmd
 }
@@ -46,7 +47,7 @@ func (c *Jsontest) Execute(args []string) error {
 		c.files = varArgs
 	}
 
-	JSONTest(c.expr, c.files...)
+	JSONTest(c.expr, c.path, c.files...)
 
 	return nil
 }
@@ -60,6 +61,7 @@ func (c *RootCmd) NewJsontest() *Jsontest {
 	}
 
 	set.StringVar(&v.expr, "e", "", "Expression")
+	set.StringVar(&v.path, "path", "", "Stream the array at this dotted path and match any element")
 	set.Usage = v.Usage
 
 	return v
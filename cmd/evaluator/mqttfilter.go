@@ -0,0 +1,67 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Mqttfilter)(nil)
+
+type Mqttfilter struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	server      string
+	config      string
+	clientID    string
+	username    string
+	password    string
+	ignoreCase  bool
+	stats       bool
+	SubCommands map[string]Cmd
+}
+
+func (c *Mqttfilter) Usage() {
+	err := executeUsage(os.Stderr, "mqttfilter_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Mqttfilter) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	MqttFilter(c.server, c.config, c.clientID, c.username, c.password, c.ignoreCase, c.stats)
+
+	return nil
+}
+
+func (c *RootCmd) NewMqttfilter() *Mqttfilter {
+	set := flag.NewFlagSet("mqttfilter", flag.ContinueOnError)
+	v := &Mqttfilter{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.server, "server", "", "MQTT broker address (default localhost:1883)")
+	set.StringVar(&v.config, "c", "", "YAML file of rules giving a topic filter, expression and destination topic")
+	set.StringVar(&v.clientID, "client-id", "", "Client identifier")
+	set.StringVar(&v.username, "username", "", "User name")
+	set.StringVar(&v.password, "password", "", "Password")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr on exit")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -0,0 +1,75 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Natsfilter)(nil)
+
+type Natsfilter struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	server      string
+	subject     string
+	queue       string
+	stream      string
+	consumer    string
+	to          string
+	headers     bool
+	noMatch     string
+	expr        string
+	ignoreCase  bool
+	stats       bool
+	SubCommands map[string]Cmd
+}
+
+func (c *Natsfilter) Usage() {
+	err := executeUsage(os.Stderr, "natsfilter_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Natsfilter) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	NatsFilter(c.server, c.subject, c.queue, c.stream, c.consumer, c.to, c.headers, c.noMatch, c.expr, c.ignoreCase, c.stats)
+
+	return nil
+}
+
+func (c *RootCmd) NewNatsfilter() *Natsfilter {
+	set := flag.NewFlagSet("natsfilter", flag.ContinueOnError)
+	v := &Natsfilter{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.server, "server", "", "NATS server address or nats:// URL (default localhost:4222)")
+	set.StringVar(&v.subject, "subject", "", "Subject to subscribe to")
+	set.StringVar(&v.queue, "queue", "", "Queue group for -subject")
+	set.StringVar(&v.stream, "stream", "", "JetStream stream of -consumer")
+	set.StringVar(&v.consumer, "consumer", "", "JetStream pull consumer to fetch from instead of -subject")
+	set.StringVar(&v.to, "to", "", "Subject matches are republished to")
+	set.BoolVar(&v.headers, "headers", false, "Evaluate message headers instead of the JSON payload")
+	set.StringVar(&v.noMatch, "no-match", "", "Acknowledge JetStream messages that do not match with ack, nak or term (default ack)")
+	set.StringVar(&v.expr, "e", "", "Expression")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr on exit")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -0,0 +1,72 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Pcapfilter)(nil)
+
+type Pcapfilter struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	expr        string
+	ignoreCase  bool
+	flows       bool
+	stats       bool
+	files       []string
+	SubCommands map[string]Cmd
+}
+
+func (c *Pcapfilter) Usage() {
+	err := executeUsage(os.Stderr, "pcapfilter_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Pcapfilter) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+	remainingArgs := c.Flags.Args()
+	// Handle vararg files
+	{
+		varArgStart := 0
+		if varArgStart > len(remainingArgs) {
+			varArgStart = len(remainingArgs)
+		}
+		varArgs := remainingArgs[varArgStart:]
+		c.files = varArgs
+	}
+
+	PcapFilter(c.expr, c.ignoreCase, c.flows, c.stats, c.files...)
+
+	return nil
+}
+
+func (c *RootCmd) NewPcapfilter() *Pcapfilter {
+	set := flag.NewFlagSet("pcapfilter", flag.ContinueOnError)
+	v := &Pcapfilter{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.expr, "e", "", "Expression")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.BoolVar(&v.flows, "flows", false, "Aggregate packets into flows and filter those")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr on exit")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -0,0 +1,69 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Redisfilter)(nil)
+
+type Redisfilter struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	addr        string
+	password    string
+	from        string
+	to          string
+	field       string
+	expr        string
+	ignoreCase  bool
+	stats       bool
+	SubCommands map[string]Cmd
+}
+
+func (c *Redisfilter) Usage() {
+	err := executeUsage(os.Stderr, "redisfilter_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Redisfilter) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	RedisFilter(c.addr, c.password, c.from, c.to, c.field, c.expr, c.ignoreCase, c.stats)
+
+	return nil
+}
+
+func (c *RootCmd) NewRedisfilter() *Redisfilter {
+	set := flag.NewFlagSet("redisfilter", flag.ContinueOnError)
+	v := &Redisfilter{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.addr, "addr", "", "Redis address (default localhost:6379)")
+	set.StringVar(&v.password, "password", "", "Redis password")
+	set.StringVar(&v.from, "from", "", "Read messages from channel:NAME or stream:NAME")
+	set.StringVar(&v.to, "to", "", "Republish matches to channel:NAME or stream:NAME")
+	set.StringVar(&v.field, "field", "", "Stream entry field holding the JSON payload (default data)")
+	set.StringVar(&v.expr, "e", "", "Expression")
+	set.BoolVar(&v.ignoreCase, "i", false, "Compare strings case-insensitively")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr on exit")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -75,8 +75,20 @@ func NewRoot(name, version, commit, date string) (*RootCmd, error) {
 	c.FlagSet.Usage = c.Usage
 	c.Commands["csvfilter"] = c.NewCsvfilter()
 	c.Commands["jsonlfilter"] = c.NewJsonlfilter()
+	c.Commands["redisfilter"] = c.NewRedisfilter()
+	c.Commands["natsfilter"] = c.NewNatsfilter()
+	c.Commands["mqttfilter"] = c.NewMqttfilter()
+	c.Commands["webhookroute"] = c.NewWebhookroute()
+	c.Commands["journalfilter"] = c.NewJournalfilter()
+	c.Commands["eventlogfilter"] = c.NewEventlogfilter()
+	c.Commands["pcapfilter"] = c.NewPcapfilter()
 	c.Commands["jsontest"] = c.NewJsontest()
 	c.Commands["yamltest"] = c.NewYamltest()
+	c.Commands["verify"] = c.NewVerify()
+	c.Commands["fmt"] = c.NewFmt()
+	c.Commands["anonymize"] = c.NewAnonymize()
+	c.Commands["setop"] = c.NewSetop()
+	c.Commands["doctor"] = c.NewDoctor()
 	c.Commands["help"] = &InternalCommand{
 		Exec: func(_ []string) error {
 			c.Usage()
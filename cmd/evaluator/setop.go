@@ -0,0 +1,72 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Setop)(nil)
+
+type Setop struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	op          string
+	key         string
+	a           string
+	b           string
+	files       []string
+	SubCommands map[string]Cmd
+}
+
+func (c *Setop) Usage() {
+	err := executeUsage(os.Stderr, "setop_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Setop) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+	remainingArgs := c.Flags.Args()
+	// Handle vararg files
+	{
+		varArgStart := 0
+		if varArgStart > len(remainingArgs) {
+			varArgStart = len(remainingArgs)
+		}
+		varArgs := remainingArgs[varArgStart:]
+		c.files = varArgs
+	}
+
+	SetOp(c.op, c.key, c.a, c.b, c.files...)
+
+	return nil
+}
+
+func (c *RootCmd) NewSetop() *Setop {
+	set := flag.NewFlagSet("setop", flag.ContinueOnError)
+	v := &Setop{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.op, "op", "", "Operation: union, intersection or difference")
+	set.StringVar(&v.key, "key", "", "Key field")
+	set.StringVar(&v.a, "a", "", "First expression")
+	set.StringVar(&v.b, "b", "", "Second expression")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -0,0 +1,59 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*VerifyCmd)(nil)
+
+type VerifyCmd struct {
+	*RootCmd
+	Flags       *flag.FlagSet
+	expr        string
+	queryFile   string
+	expectHash  string
+	SubCommands map[string]Cmd
+}
+
+func (c *VerifyCmd) Usage() {
+	err := executeUsage(os.Stderr, "verify_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *VerifyCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	Verify(c.expr, c.queryFile, c.expectHash)
+
+	return nil
+}
+
+func (c *RootCmd) NewVerify() *VerifyCmd {
+	set := flag.NewFlagSet("verify", flag.ContinueOnError)
+	v := &VerifyCmd{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.expr, "e", "", "Expression")
+	set.StringVar(&v.queryFile, "q", "", "Query JSON file")
+	set.StringVar(&v.expectHash, "expect-hash", "", "Expected query hash")
+	set.Usage = v.Usage
+
+	return v
+}
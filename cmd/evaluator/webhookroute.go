@@ -0,0 +1,65 @@
+// Generated by github.com/arran4/go-subcommand/cmd/gosubc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var _ Cmd = (*Webhookroute)(nil)
+
+type Webhookroute struct {
+	*RootCmd
+	Flags         *flag.FlagSet
+	listen        string
+	config        string
+	retries       int
+	maxConcurrent int
+	queueTimeout  string
+	stats         bool
+	SubCommands   map[string]Cmd
+}
+
+func (c *Webhookroute) Usage() {
+	err := executeUsage(os.Stderr, "webhookroute_usage.txt", c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating usage: %s\n", err)
+	}
+}
+
+func (c *Webhookroute) Execute(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := c.SubCommands[args[0]]; ok {
+			return cmd.Execute(args[1:])
+		}
+	}
+	err := c.Flags.Parse(args)
+	if err != nil {
+		return NewUserError(err, fmt.Sprintf("flag parse error %s", err.Error()))
+	}
+
+	WebhookRoute(c.listen, c.config, c.retries, c.maxConcurrent, c.queueTimeout, c.stats)
+
+	return nil
+}
+
+func (c *RootCmd) NewWebhookroute() *Webhookroute {
+	set := flag.NewFlagSet("webhookroute", flag.ContinueOnError)
+	v := &Webhookroute{
+		RootCmd:     c,
+		Flags:       set,
+		SubCommands: make(map[string]Cmd),
+	}
+
+	set.StringVar(&v.listen, "listen", "", "Address to listen on (default :8080)")
+	set.StringVar(&v.config, "c", "", "JSON rules file with Routes mapping rule names to destination URLs")
+	set.IntVar(&v.retries, "retries", 0, "Times a failed delivery is retried, or -1 for none (default 3)")
+	set.IntVar(&v.maxConcurrent, "max-concurrent", 0, "Webhooks handled at once, or 0 for no limit")
+	set.StringVar(&v.queueTimeout, "queue-timeout", "", "How long a webhook waits for a free slot before a 429, e.g. 2s")
+	set.BoolVar(&v.stats, "stats", false, "Print statistics to stderr on exit")
+	set.Usage = v.Usage
+
+	return v
+}
@@ -10,9 +10,13 @@ import (
 
 	"github.com/arran4/go-evaluator"
 	"github.com/arran4/go-evaluator/parser/simple"
+	"github.com/arran4/go-evaluator/stream"
 )
 
-func evaluate(r io.Reader, q evaluator.Query) (bool, error) {
+func evaluate(r io.Reader, q evaluator.Query, path string) (bool, error) {
+	if path != "" {
+		return stream.AnyJSON(r, path, q)
+	}
 	dec := json.NewDecoder(r)
 	var m map[string]interface{}
 	if err := dec.Decode(&m); err != nil {
@@ -30,6 +34,7 @@ func usage() {
 func main() {
 	flag.Usage = usage
 	expr := flag.String("e", "", "expression to test against the document")
+	path := flag.String("path", "", "stream the array at this dotted path and match if any element matches")
 	flag.Parse()
 	if *expr == "" {
 		log.Fatal("-e expression required")
@@ -40,7 +45,7 @@ func main() {
 	}
 	files := flag.Args()
 	if len(files) == 0 {
-		ok, err := evaluate(os.Stdin, q)
+		ok, err := evaluate(os.Stdin, q, *path)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -54,7 +59,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		ok, err := evaluate(fh, q)
+		ok, err := evaluate(fh, q, *path)
 		_ = fh.Close()
 		if err != nil {
 			log.Fatal(err)
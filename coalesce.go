@@ -0,0 +1,23 @@
+package evaluator
+
+import "reflect"
+
+// CoalesceTerm evaluates to the first of Terms that produces a value that is
+// neither nil nor empty, where empty has the meaning of IsEmptyExpression:
+// an empty string, slice, array or map. Terms that fail, such as fields
+// missing from the record, are skipped, so a trailing Constant gives a
+// default: Region ?? "unknown". It evaluates to nil when no term produces a
+// value.
+type CoalesceTerm struct {
+	Terms []Term
+}
+
+func (c CoalesceTerm) Evaluate(i interface{}, opts ...any) (interface{}, error) {
+	for _, t := range c.Terms {
+		v, err := evalTerm(t, i, opts...)
+		if err == nil && !isEmptyValue(reflect.ValueOf(v)) {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,36 @@
+package evaluator
+
+import "testing"
+
+func TestCoalesceTerm(t *testing.T) {
+	c := CoalesceTerm{Terms: []Term{Field{Name: "Region"}, Field{Name: "Country"}, Constant{Value: "unknown"}}}
+	tests := []struct {
+		rec  map[string]interface{}
+		want interface{}
+	}{
+		{map[string]interface{}{"Region": "EU", "Country": "FR"}, "EU"},
+		{map[string]interface{}{"Region": nil, "Country": "FR"}, "FR"},
+		{map[string]interface{}{"Country": "FR"}, "FR"},
+		{map[string]interface{}{"Region": "", "Country": "FR"}, "FR"},
+		{map[string]interface{}{"Region": []interface{}{}, "Country": nil}, "unknown"},
+		{map[string]interface{}{"Region": 0}, 0},
+		{map[string]interface{}{"Region": false}, false},
+		{map[string]interface{}{}, "unknown"},
+	}
+	for _, tt := range tests {
+		got, err := c.Evaluate(tt.rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.rec, got, tt.want)
+		}
+	}
+	if v, err := (CoalesceTerm{Terms: []Term{Field{Name: "X"}}}).Evaluate(map[string]interface{}{}); v != nil || err != nil {
+		t.Errorf("got %v, %v", v, err)
+	}
+	q := Query{Expression: &ComparisonExpression{LHS: c, RHS: Constant{Value: "unknown"}, Operation: "eq"}}
+	if ok, err := q.Evaluate(map[string]interface{}{}); err != nil || !ok {
+		t.Errorf("comparison: %v %v", ok, err)
+	}
+}
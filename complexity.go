@@ -0,0 +1,60 @@
+package evaluator
+
+import "reflect"
+
+var (
+	expressionType = reflect.TypeOf((*Expression)(nil)).Elem()
+	termType       = reflect.TypeOf((*Term)(nil)).Elem()
+)
+
+// Complexity returns the number of expression and term nodes in q, a measure
+// of the work evaluating it does for each record. A service evaluating
+// queries for several tenants can charge Complexity(q) times the number of
+// records evaluated, or refuse queries above a limit before running them.
+// Queries nesting nodes more than DefaultMaxDepth deep, which Evaluate would
+// refuse, return ErrMaxDepth rather than a partial count.
+func Complexity(q Query) (int, error) {
+	n := 0
+	var walk func(v reflect.Value, depth int) error
+	walk = func(v reflect.Value, depth int) error {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			t := v.Type()
+			if t != queryType {
+				pt := reflect.PointerTo(t)
+				if pt.Implements(expressionType) || pt.Implements(termType) {
+					n++
+					if depth++; depth > DefaultMaxDepth {
+						return ErrMaxDepth
+					}
+				}
+			}
+			for i := 0; i < v.NumField(); i++ {
+				sf := t.Field(i)
+				if !sf.IsExported() || sf.Name == "ExpressionRawJSON" || sf.Name == "Metadata" {
+					continue
+				}
+				if err := walk(v.Field(i), depth); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				if err := walk(v.Index(i), depth); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(reflect.ValueOf(q), 0); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
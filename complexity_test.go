@@ -0,0 +1,49 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestComplexity(t *testing.T) {
+	leaf := func(f string) Query {
+		return Query{Expression: &IsExpression{Field: f, Value: 1}}
+	}
+	tests := []struct {
+		name string
+		q    Query
+		want int
+	}{
+		{"empty", Query{}, 0},
+		{"leaf", leaf("a"), 1},
+		{"and", Query{Expression: &AndExpression{Expressions: []Query{leaf("a"), leaf("b")}}}, 3},
+		{"not or", Query{Expression: &NotExpression{Expression: Query{Expression: &OrExpression{Expressions: []Query{leaf("a"), leaf("b"), leaf("c")}}}}}, 5},
+		{"terms", Query{Expression: &ComparisonExpression{
+			LHS:       AddTerm{LHS: Field{Name: "Price"}, RHS: SumTerm{Field: "Items.Price"}},
+			RHS:       Constant{Value: 100},
+			Operation: "gt",
+		}}, 5},
+		{"quantifier", Query{Expression: &AnyExpression{Field: "Items", Query: leaf("Qty")}}, 2},
+	}
+	for _, tt := range tests {
+		if got, err := Complexity(tt.q); err != nil || got != tt.want {
+			t.Errorf("%s: Complexity = %d, %v, want %d", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestComplexityMaxDepth(t *testing.T) {
+	nest := func(n int) Query {
+		q := Query{Expression: &IsExpression{Field: "a", Value: 1}}
+		for i := 1; i < n; i++ {
+			q = Query{Expression: &NotExpression{Expression: q}}
+		}
+		return q
+	}
+	if got, err := Complexity(nest(DefaultMaxDepth)); err != nil || got != DefaultMaxDepth {
+		t.Errorf("Complexity = %d, %v, want %d", got, err, DefaultMaxDepth)
+	}
+	if _, err := Complexity(nest(DefaultMaxDepth + 1)); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("expected ErrMaxDepth, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package evaluator
+
+// ContainsAllExpression succeeds when Field contains every one of Values, in
+// the sense of ContainsExpression: as an element of a slice, a value of a map
+// or a substring of a string. An empty Values matches any present field.
+type ContainsAllExpression struct {
+	Field  string
+	Values []interface{}
+	Fold   bool `json:",omitempty"`
+}
+
+func (e ContainsAllExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	if _, ok := getField(v, e.Field); !ok {
+		return false, nil
+	}
+	for _, want := range e.Values {
+		ok, err := ContainsExpression{Field: e.Field, Value: want, Fold: e.Fold}.Evaluate(i, opts...)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ContainsAnyExpression succeeds when Field contains at least one of Values,
+// in the sense of ContainsExpression.
+type ContainsAnyExpression struct {
+	Field  string
+	Values []interface{}
+	Fold   bool `json:",omitempty"`
+}
+
+func (e ContainsAnyExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	for _, want := range e.Values {
+		ok, err := ContainsExpression{Field: e.Field, Value: want, Fold: e.Fold}.Evaluate(i, opts...)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestContainsAllAndAny(t *testing.T) {
+	u := &testUser{Name: "bob", Tags: []string{"go", "cli", "web"}}
+	tests := []struct {
+		name string
+		e    Expression
+		want bool
+	}{
+		{"all present", ContainsAllExpression{Field: "Tags", Values: []interface{}{"go", "cli"}}, true},
+		{"all one missing", ContainsAllExpression{Field: "Tags", Values: []interface{}{"go", "rust"}}, false},
+		{"all empty", ContainsAllExpression{Field: "Tags", Values: nil}, true},
+		{"all missing field", ContainsAllExpression{Field: "Other", Values: nil}, false},
+		{"all folded", ContainsAllExpression{Field: "Tags", Values: []interface{}{"GO", "Web"}, Fold: true}, true},
+		{"all substrings", ContainsAllExpression{Field: "Name", Values: []interface{}{"b", "o"}}, true},
+		{"any present", ContainsAnyExpression{Field: "Tags", Values: []interface{}{"rust", "web"}}, true},
+		{"any none", ContainsAnyExpression{Field: "Tags", Values: []interface{}{"rust", "java"}}, false},
+		{"any empty", ContainsAnyExpression{Field: "Tags"}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(u)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContainsAllJSON(t *testing.T) {
+	q := Query{Expression: &OrExpression{Expressions: []Query{
+		{Expression: &ContainsAllExpression{Field: "tags", Values: []interface{}{"go", "cli"}}},
+		{Expression: &ContainsAnyExpression{Field: "tags", Values: []interface{}{"rust"}}},
+	}}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for tags, want := range map[string]bool{`["cli","go"]`: true, `["rust"]`: true, `["go"]`: false} {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(`{"tags":`+tags+`}`), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := back.Evaluate(rec); err != nil || ok != want {
+			t.Errorf("%s: got %v, %v, want %v", tags, ok, err, want)
+		}
+	}
+	r := Redact(q, []string{"tags"})
+	if v := r.Expression.(*OrExpression).Expressions[0].Expression.(*ContainsAllExpression).Values[1]; v != RedactedValue {
+		t.Errorf("value not redacted: %v", v)
+	}
+}
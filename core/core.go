@@ -0,0 +1,33 @@
+// Package core defines the interfaces shared by the evaluator packages:
+// expressions, terms, functions and field resolvers. It has no dependencies,
+// so extensions can implement these interfaces without importing the root
+// package and its built-in expressions.
+//
+// The root evaluator package re-exports each interface as a type alias, so
+// code written against evaluator.Expression and friends keeps compiling and
+// values satisfy both names.
+package core
+
+// Expression is a boolean condition evaluated against a record. Options are
+// passed through opts, which implementations should ignore when they do not
+// recognise an option's type.
+type Expression interface {
+	Evaluate(i interface{}, opts ...any) (bool, error)
+}
+
+// Term produces a value from a record, such as a field, a constant or a
+// function call.
+type Term interface {
+	Evaluate(i interface{}, opts ...any) (interface{}, error)
+}
+
+// Function is a named function callable from expressions.
+type Function interface {
+	Call(args ...interface{}) (interface{}, error)
+}
+
+// FieldResolver resolves fields by name for records that are not plain
+// structs or maps. An error means the field does not exist.
+type FieldResolver interface {
+	Get(name string) (interface{}, error)
+}
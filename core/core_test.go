@@ -0,0 +1,51 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/core"
+)
+
+// always is an expression written only against core.
+type always struct{}
+
+func (always) Evaluate(interface{}, ...any) (bool, error) { return true, nil }
+
+func TestAliasesAreIdentical(t *testing.T) {
+	var e core.Expression = &evaluator.IsExpression{Field: "A", Value: 1}
+	q := evaluator.Query{Expression: e}
+	if ok, err := q.Evaluate(map[string]interface{}{"A": 1}); err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	var ee evaluator.Expression = always{}
+	if _, ok := ee.(core.Expression); !ok {
+		t.Errorf("evaluator.Expression should be core.Expression")
+	}
+	var _ core.Term = evaluator.Field{Name: "A"}
+	var _ evaluator.Getter = core.FieldResolver(nil)
+}
+
+// row is a record written only against core.FieldResolver.
+type row map[string]interface{}
+
+func (r row) Get(name string) (interface{}, error) {
+	if v, ok := r[name]; ok {
+		return v, nil
+	}
+	return nil, errors.New("no such field")
+}
+
+func TestFieldResolverRecords(t *testing.T) {
+	var rec core.FieldResolver = row{"A": 1, "B": map[string]interface{}{"C": "x"}}
+	for _, q := range []evaluator.Query{
+		{Expression: &evaluator.IsExpression{Field: "A", Value: 1}},
+		{Expression: &evaluator.IsExpression{Field: "B.C", Value: "x"}},
+		{Expression: &evaluator.NotExpression{Expression: evaluator.Query{Expression: &evaluator.IsExpression{Field: "D", Value: 1}}}},
+	} {
+		if ok, err := q.Evaluate(rec); err != nil || !ok {
+			t.Errorf("%T: got %v, %v", q.Expression, ok, err)
+		}
+	}
+}
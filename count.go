@@ -0,0 +1,36 @@
+package evaluator
+
+import "fmt"
+
+// CountExpression counts the elements of the slice or array Field that match
+// Query and compares the count with Value using Op, one of eq, neq, gt, gte,
+// lt or lte, as in at least two failing checks. A missing field or one that
+// is not a list does not match.
+type CountExpression struct {
+	Field string
+	Query Query
+	Op    string
+	Value interface{}
+}
+
+func (e CountExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	n, ok := numeric[float64](e.Value)
+	if !ok {
+		return false, fmt.Errorf("count of %s: value %v is not a number", e.Field, e.Value)
+	}
+	count := 0
+	isList, err := eachElement(i, e.Field, func(el interface{}) (bool, error) {
+		ok, err := e.Query.Evaluate(el, opts...)
+		if ok {
+			count++
+		}
+		return true, err
+	})
+	if err != nil || !isList {
+		return false, err
+	}
+	if ok, known := compareCount(count, e.Op, n); known {
+		return ok, nil
+	}
+	return false, fmt.Errorf("count of %s: unknown operation %q", e.Field, e.Op)
+}
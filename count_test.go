@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCountExpression(t *testing.T) {
+	failed := Query{Expression: &IsExpression{Field: "Status", Value: "fail"}}
+	rec := map[string]interface{}{"Checks": []interface{}{
+		map[string]interface{}{"Status": "fail"},
+		map[string]interface{}{"Status": "ok"},
+		map[string]interface{}{"Status": "fail"},
+	}}
+	tests := []struct {
+		name string
+		e    CountExpression
+		want bool
+	}{
+		{"gte", CountExpression{Field: "Checks", Query: failed, Op: "gte", Value: 2}, true},
+		{"gt", CountExpression{Field: "Checks", Query: failed, Op: "gt", Value: 2}, false},
+		{"eq", CountExpression{Field: "Checks", Query: failed, Op: "eq", Value: 2.0}, true},
+		{"lt", CountExpression{Field: "Checks", Query: failed, Op: "lt", Value: 1}, false},
+		{"missing field", CountExpression{Field: "Other", Query: failed, Op: "eq", Value: 0}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(rec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := (CountExpression{Field: "Checks", Query: failed, Op: "about", Value: 1}).Evaluate(rec); err == nil {
+		t.Errorf("expected error for unknown op")
+	}
+	if _, err := (CountExpression{Field: "Checks", Query: failed, Op: "eq", Value: "two"}).Evaluate(rec); err == nil {
+		t.Errorf("expected error for non-numeric value")
+	}
+}
+
+func TestCountExpressionJSON(t *testing.T) {
+	q := Query{Expression: &CountExpression{Field: "Checks", Query: Query{Expression: &IsExpression{Field: "Status", Value: "fail"}}, Op: "gte", Value: 2}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	rec := map[string]interface{}{"Checks": []interface{}{
+		map[string]interface{}{"Status": "fail"},
+		map[string]interface{}{"Status": "fail"},
+	}}
+	if ok, err := back.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,154 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Builds with the debug tag set debugChecks and check internal invariants as
+// queries are evaluated, panicking when one is broken:
+//
+//   - a query being evaluated holds no nil expressions or terms below its
+//     root, which would otherwise make part of the tree silently false, other
+//     than the optional Else of a CaseExpression
+//   - a Getter, MapGetter or *sync.Map that reports a field missing returns
+//     no value for it
+//
+// Normal builds compile the checks out.
+
+// checkInvariants reports the first nil expression or term in the tree below
+// q. q itself may be empty.
+func checkInvariants(q *Query) error {
+	var walk func(v reflect.Value, path string, depth int) error
+	walk = func(v reflect.Value, path string, depth int) error {
+		if depth > DefaultMaxDepth {
+			return nil
+		}
+		if v.Kind() == reflect.Interface && (v.Type() == expressionType || v.Type() == termType) {
+			if v.IsNil() && !strings.HasSuffix(path, ".Else.Expression") || !v.IsNil() && v.Elem().Kind() == reflect.Ptr && v.Elem().IsNil() {
+				return fmt.Errorf("nil %s at %s", v.Type().Name(), path)
+			}
+		}
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			t := v.Type()
+			for i := 0; i < v.NumField(); i++ {
+				sf := t.Field(i)
+				if !sf.IsExported() || sf.Name == "ExpressionRawJSON" || sf.Name == "Metadata" {
+					continue
+				}
+				if err := walk(v.Field(i), path+"."+sf.Name, depth+1); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				if err := walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if q.Expression == nil {
+		return nil
+	}
+	return walk(reflect.ValueOf(q.Expression), "Expression", 0)
+}
+
+// debugCheckQuery checks the invariants of q when it is the outermost query
+// being evaluated, so that each tree is walked once per record.
+func debugCheckQuery(q *Query, opts []any) {
+	for _, o := range opts {
+		if _, nested := o.(*evalDepth); nested {
+			return
+		}
+	}
+	if err := checkInvariants(q); err != nil {
+		panic("evaluator: invariant: " + err.Error())
+	}
+}
+
+// debugCheckResolve checks that a record resolving its own fields returned
+// no value for a field it reported missing.
+func debugCheckResolve(name string, val interface{}, found bool) {
+	if !found && val != nil {
+		panic(fmt.Sprintf("evaluator: invariant: resolver returned %#v for missing field %q", val, name))
+	}
+}
+
+// DebugString returns an indented dump of every node of q with the exported
+// fields of each and the dynamic type of each value, such as
+// Value: 5 (int64). It is meant for chasing a query that does not match as
+// expected, where a value of an unexpected type is a common culprit.
+func (q Query) DebugString() string {
+	var b strings.Builder
+	var dump func(v reflect.Value, indent string, depth int)
+	dump = func(v reflect.Value, indent string, depth int) {
+		if depth > DefaultMaxDepth {
+			b.WriteString("...\n")
+			return
+		}
+		for v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				b.WriteString("nil\n")
+				return
+			}
+			v = v.Elem()
+		}
+		t := v.Type()
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				fmt.Fprintf(&b, "nil (%s)\n", t)
+				return
+			}
+			if v.Elem().Kind() != reflect.Struct || t.Implements(reflect.TypeOf((*fmt.Stringer)(nil)).Elem()) {
+				fmt.Fprintf(&b, "%v (%s)\n", v.Interface(), t)
+				return
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			if _, ok := v.Interface().(fmt.Stringer); ok {
+				fmt.Fprintf(&b, "%v (%s)\n", v.Interface(), t)
+				return
+			}
+			fmt.Fprintf(&b, "%s\n", t)
+			st := v.Type()
+			for i := 0; i < v.NumField(); i++ {
+				sf := st.Field(i)
+				if !sf.IsExported() || sf.Name == "ExpressionRawJSON" {
+					continue
+				}
+				fmt.Fprintf(&b, "%s  %s: ", indent, sf.Name)
+				dump(v.Field(i), indent+"  ", depth+1)
+			}
+		case reflect.Slice, reflect.Array:
+			if v.Kind() == reflect.Slice && v.IsNil() {
+				fmt.Fprintf(&b, "nil (%s)\n", t)
+				return
+			}
+			fmt.Fprintf(&b, "%s len %d\n", t, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				fmt.Fprintf(&b, "%s  [%d]: ", indent, i)
+				dump(v.Index(i), indent+"  ", depth+1)
+			}
+		default:
+			if !v.CanInterface() {
+				fmt.Fprintf(&b, "? (%s)\n", t)
+				return
+			}
+			fmt.Fprintf(&b, "%#v (%s)\n", v.Interface(), t)
+		}
+	}
+	dump(reflect.ValueOf(q), "", 0)
+	return b.String()
+}
@@ -0,0 +1,7 @@
+//go:build !debug
+
+package evaluator
+
+// debugChecks is false outside debug builds, so the checks guarded by it are
+// compiled out.
+const debugChecks = false
@@ -0,0 +1,6 @@
+//go:build debug
+
+package evaluator
+
+// debugChecks turns on the invariant checks described in debug.go.
+const debugChecks = true
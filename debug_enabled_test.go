@@ -0,0 +1,37 @@
+//go:build debug
+
+package evaluator
+
+import (
+	"strings"
+	"testing"
+)
+
+type badGetter struct{}
+
+func (badGetter) Get(name string) (interface{}, bool) {
+	return "stale", false
+}
+
+func TestDebugChecks(t *testing.T) {
+	for name, run := range map[string]func(){
+		"empty child": func() {
+			q := Query{Expression: &OrExpression{Expressions: []Query{{}}}}
+			q.Evaluate(map[string]interface{}{})
+		},
+		"resolver": func() {
+			q := Query{Expression: &IsExpression{Field: "A", Value: "stale"}}
+			q.Evaluate(badGetter{})
+		},
+	} {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.HasPrefix(msg, "evaluator: invariant: ") {
+					t.Errorf("%s: expected an invariant panic, got %q", name, msg)
+				}
+			}()
+			run()
+		}()
+	}
+}
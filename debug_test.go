@@ -0,0 +1,48 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	for name, tt := range map[string]struct {
+		q    Query
+		want string
+	}{
+		"empty":       {Query{}, ""},
+		"leaf":        {Query{Expression: &IsExpression{Field: "A", Value: 1}}, ""},
+		"case else":   {Query{Expression: &CaseExpression{Cases: []CaseBranch{{When: Query{Expression: &IsExpression{Field: "A"}}, Then: Query{Expression: &IsExpression{Field: "B"}}}}}}, ""},
+		"empty child": {Query{Expression: &AndExpression{Expressions: []Query{{Expression: &IsExpression{Field: "A"}}, {}}}}, "nil Expression at Expression.Expressions[1].Expression"},
+		"typed nil":   {Query{Expression: &NotExpression{Expression: Query{Expression: (*IsExpression)(nil)}}}, "nil Expression at Expression.Expression.Expression"},
+		"nil term":    {Query{Expression: &ComparisonExpression{LHS: Field{Name: "A"}, Operation: "eq"}}, "nil Term at Expression.RHS"},
+	} {
+		got := ""
+		if err := checkInvariants(&tt.q); err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", name, got, tt.want)
+		}
+	}
+}
+
+func TestDebugString(t *testing.T) {
+	q := Query{Expression: &OrExpression{Expressions: []Query{
+		{Expression: &IsExpression{Field: "Name", Value: "bob"}},
+		{Expression: &GreaterThanExpression{Field: "Age", Value: int64(5)}},
+	}}}
+	got := q.DebugString()
+	for _, want := range []string{
+		"evaluator.Query\n",
+		"  Expression: *evaluator.OrExpression\n",
+		"    Expressions: []evaluator.Query len 2\n",
+		"          Field: \"Name\" (string)\n",
+		"          Value: 5 (int64)\n",
+		"  Metadata: nil (*evaluator.Metadata)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// decimalCompare compares f with value exactly when the Context in opts sets
+// Decimal. It reports false, leaving the usual float64 comparison to the
+// caller, when Decimal is not set or either side is not a number. Strings
+// holding numbers count as numbers, but two strings are still compared as
+// text.
+func decimalCompare(f reflect.Value, value interface{}, opts []any) (int, bool) {
+	ctx := findContext(opts)
+	if ctx == nil || !ctx.Decimal || !f.IsValid() || !f.CanInterface() {
+		return 0, false
+	}
+	fv := f.Interface()
+	_, fs := fv.(string)
+	_, vs := value.(string)
+	if fs && vs {
+		return 0, false
+	}
+	if c, ok := integerCompare(fv, value); ok {
+		return c, true
+	}
+	a, ok := exactNumber(fv)
+	if !ok {
+		return 0, false
+	}
+	b, ok := exactNumber(value)
+	if !ok {
+		return 0, false
+	}
+	return a.Cmp(b), true
+}
+
+// integerCompare compares two integers of any of the built-in types without
+// allocating.
+func integerCompare(a, b interface{}) (int, bool) {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	ai, aNeg, ok := integerParts(av)
+	if !ok {
+		return 0, false
+	}
+	bi, bNeg, ok := integerParts(bv)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case aNeg && !bNeg:
+		return -1, true
+	case !aNeg && bNeg:
+		return 1, true
+	case ai < bi:
+		return -1, true
+	case ai > bi:
+		return 1, true
+	}
+	return 0, true
+}
+
+// integerParts returns the bits of the integer v as a uint64 and whether v is
+// negative. Negative values order correctly among themselves by their bits.
+func integerParts(v reflect.Value) (uint64, bool, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		return uint64(n), n < 0, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), false, true
+	}
+	return 0, false, false
+}
+
+// exactNumber returns v as an exact rational: integers and floats by value,
+// and json.Number and strings such as "12345678901234567890.01" from their
+// decimal text rather than through float64.
+func exactNumber(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case *big.Rat:
+		return n, n != nil
+	case *big.Int:
+		if n == nil {
+			return nil, false
+		}
+		return new(big.Rat).SetInt(n), true
+	case *big.Float:
+		if n == nil || n.IsInf() {
+			return nil, false
+		}
+		r, _ := n.Rat(nil)
+		return r, true
+	case json.Number:
+		return new(big.Rat).SetString(string(n))
+	case string:
+		return new(big.Rat).SetString(n)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Rat).SetUint64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		x := rv.Float()
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(x), true
+	}
+	return nil, false
+}
@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestDecimalComparisons(t *testing.T) {
+	const id = uint64(1<<63 + 1)
+	record := map[string]interface{}{
+		"ID":     id,
+		"Amount": json.Number("12345678901234567890.01"),
+		"Big":    new(big.Int).Lsh(big.NewInt(1), 80),
+		"Small":  int64(-3),
+		"Name":   "9",
+	}
+	ctx := &Context{Decimal: true}
+	tests := []struct {
+		name string
+		e    Expression
+		want bool
+	}{
+		{"uint64 above", &GreaterThanExpression{Field: "ID", Value: id - 1}, true},
+		{"uint64 equal", &GreaterThanExpression{Field: "ID", Value: id}, false},
+		{"uint64 gte", &GreaterThanOrEqualExpression{Field: "ID", Value: id}, true},
+		{"negative", &LessThanExpression{Field: "Small", Value: uint64(1)}, true},
+		{"json number", &GreaterThanExpression{Field: "Amount", Value: "12345678901234567890"}, true},
+		{"json number cents", &LessThanExpression{Field: "Amount", Value: 12345678901234567890.0}, false},
+		{"big int", &GreaterThanExpression{Field: "Big", Value: "1208925819614629174706175"}, true},
+		{"big int equal", &LessThanOrEqualExpression{Field: "Big", Value: "1208925819614629174706176"}, true},
+		{"between", &BetweenExpression{Field: "ID", Low: id, High: id + 1, Inclusive: true}, true},
+		{"strings stay text", &GreaterThanExpression{Field: "Name", Value: "10"}, true},
+		{"comparison", &ComparisonExpression{LHS: Field{Name: "ID"}, RHS: Constant{Value: id - 1}, Operation: "neq"}, true},
+	}
+	for _, tt := range tests {
+		q := Query{Expression: tt.e}
+		got, err := q.Evaluate(record, ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// Without Decimal, float64 cannot tell the IDs apart.
+	q := Query{Expression: &ComparisonExpression{LHS: Field{Name: "ID"}, RHS: Constant{Value: id - 1}, Operation: "neq"}}
+	if ok, _ := q.Evaluate(record); ok {
+		t.Errorf("expected float64 comparison to lose precision")
+	}
+}
@@ -0,0 +1,52 @@
+package evaluator
+
+import "errors"
+
+// DefaultMaxDepth is the nesting limit used when Context.MaxDepth is zero.
+// It is far deeper than hand written queries go but shallow enough that a
+// hostile query cannot exhaust the stack.
+const DefaultMaxDepth = 256
+
+// ErrMaxDepth is returned by Query.Evaluate when queries are nested more
+// deeply than the limit allows.
+var ErrMaxDepth = errors.New("query exceeds maximum nesting depth")
+
+// evalDepth counts the composite queries entered so far in one evaluation.
+// The outermost composite query appends it to the evaluation options and
+// the queries nested in it count up and down in place, so deep queries do
+// not copy the options at every level. Queries nested in one evaluation are
+// evaluated one at a time, so it needs no locking.
+type evalDepth struct {
+	n, limit int
+}
+
+// enterDepth increments the nesting depth carried in opts, adding it to
+// opts at the outermost level, and returns the options to evaluate with.
+// Callers must call leave on the returned depth once done. It returns
+// ErrMaxDepth when the depth would exceed the limit set by the Context in
+// opts.
+func enterDepth(opts []any) ([]any, *evalDepth, error) {
+	var d *evalDepth
+	for _, o := range opts {
+		if v, ok := o.(*evalDepth); ok {
+			d = v
+			break
+		}
+	}
+	if d == nil {
+		d = &evalDepth{limit: DefaultMaxDepth}
+		if ctx := findContext(opts); ctx != nil && ctx.MaxDepth != 0 {
+			d.limit = ctx.MaxDepth
+		}
+		opts = append(opts[:len(opts):len(opts)], d)
+	}
+	if d.limit > 0 && d.n >= d.limit {
+		return nil, nil, ErrMaxDepth
+	}
+	d.n++
+	return opts, d, nil
+}
+
+func (d *evalDepth) leave() {
+	d.n--
+}
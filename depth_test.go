@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func nestedNot(depth int, leaf Expression) Query {
+	q := Query{Expression: leaf}
+	for n := 0; n < depth; n++ {
+		q = Query{Expression: &NotExpression{Expression: q}}
+	}
+	return q
+}
+
+func TestMaxDepth(t *testing.T) {
+	rec := map[string]interface{}{"Status": "open"}
+	leaf := &IsExpression{Field: "Status", Value: "open"}
+	tests := []struct {
+		name    string
+		q       Query
+		ctx     *Context
+		want    bool
+		wantErr error
+	}{
+		{"within default", nestedNot(DefaultMaxDepth, leaf), nil, true, nil},
+		{"beyond default", nestedNot(DefaultMaxDepth+1, leaf), nil, false, ErrMaxDepth},
+		{"custom limit", nestedNot(3, leaf), &Context{MaxDepth: 2}, false, ErrMaxDepth},
+		{"unlimited", nestedNot(DefaultMaxDepth+2, leaf), &Context{MaxDepth: -1}, true, nil},
+		{"fail-open leaves do not hide it", Query{Expression: &NotExpression{Expression: nestedNot(2, leaf)}, OnEvalError: FailOpen}, &Context{MaxDepth: 2}, false, ErrMaxDepth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []any
+			if tt.ctx != nil {
+				opts = append(opts, tt.ctx)
+			}
+			got, err := tt.q.Evaluate(rec, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCyclicFieldPath(t *testing.T) {
+	m := map[string]interface{}{"name": "loop"}
+	m["self"] = m
+	q := IsExpression{Field: "self.self.self.name", Value: "loop"}
+	if ok, err := q.Evaluate(m); err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	deep := strings.Repeat("self.", DefaultMaxDepth) + "name"
+	if ok, _ := (IsExpression{Field: deep, Value: "loop"}).Evaluate(m); ok {
+		t.Error("path beyond DefaultMaxDepth resolved")
+	}
+}
+
+func TestMaxDepthSiblings(t *testing.T) {
+	rec := map[string]interface{}{"Status": "open"}
+	var children []Query
+	for n := 0; n < 10; n++ {
+		children = append(children, nestedNot(2, &IsExpression{Field: "Status", Value: "open"}))
+	}
+	q := Query{Expression: &AndExpression{Expressions: children}}
+	if ok, err := q.Evaluate(rec, &Context{MaxDepth: 3}); err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+}
+
+func TestDepthAllocations(t *testing.T) {
+	if debugChecks {
+		t.Skip("invariant checks allocate per node")
+	}
+	rec := map[string]interface{}{"Status": "open"}
+	leaf := &IsExpression{Field: "Status", Value: "open"}
+	allocs := func(depth int) float64 {
+		q := nestedNot(depth, leaf)
+		return testing.AllocsPerRun(20, func() { _, _ = q.Evaluate(rec) })
+	}
+	if shallow, deep := allocs(2), allocs(100); deep != shallow {
+		t.Errorf("allocations grow with depth: %v at depth 2, %v at depth 100", shallow, deep)
+	}
+}
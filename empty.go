@@ -0,0 +1,50 @@
+package evaluator
+
+import "reflect"
+
+// IsEmptyExpression succeeds when Field is missing, nil, an empty string or
+// a zero-length slice, array or map.
+type IsEmptyExpression struct {
+	Field string
+}
+
+func (e IsEmptyExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok {
+		return true, nil
+	}
+	return isEmptyValue(f), nil
+}
+
+// IsNotEmptyExpression succeeds when IsEmptyExpression would not.
+type IsNotEmptyExpression struct {
+	Field string
+}
+
+func (e IsNotEmptyExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	if _, ok := derefValue(i); !ok {
+		return false, nil
+	}
+	empty, err := IsEmptyExpression(e).Evaluate(i)
+	return !empty, err
+}
+
+func isEmptyValue(f reflect.Value) bool {
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return true
+		}
+		f = f.Elem()
+	}
+	switch f.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return f.Len() == 0
+	}
+	return false
+}
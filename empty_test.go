@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIsEmptyExpression(t *testing.T) {
+	type rec struct {
+		S   string
+		P   *string
+		L   []int
+		M   map[string]int
+		N   int
+		Any interface{}
+	}
+	s := ""
+	full := "x"
+	tests := []struct {
+		name  string
+		rec   *rec
+		field string
+		want  bool
+	}{
+		{"empty string", &rec{}, "S", true},
+		{"string", &rec{S: "x"}, "S", false},
+		{"nil pointer", &rec{}, "P", true},
+		{"pointer to empty", &rec{P: &s}, "P", true},
+		{"pointer to value", &rec{P: &full}, "P", false},
+		{"nil slice", &rec{}, "L", true},
+		{"empty slice", &rec{L: []int{}}, "L", true},
+		{"slice", &rec{L: []int{1}}, "L", false},
+		{"empty map", &rec{M: map[string]int{}}, "M", true},
+		{"map", &rec{M: map[string]int{"a": 1}}, "M", false},
+		{"zero number", &rec{}, "N", false},
+		{"nil interface", &rec{}, "Any", true},
+		{"missing field", &rec{}, "Other", true},
+	}
+	for _, tt := range tests {
+		got, err := IsEmptyExpression{Field: tt.field}.Evaluate(tt.rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: IsEmpty got %v, want %v", tt.name, got, tt.want)
+		}
+		got, _ = IsNotEmptyExpression{Field: tt.field}.Evaluate(tt.rec)
+		if got == tt.want {
+			t.Errorf("%s: IsNotEmpty got %v", tt.name, got)
+		}
+	}
+}
+
+func TestIsEmptyExpressionJSON(t *testing.T) {
+	var q Query
+	if err := json.Unmarshal([]byte(`{"Expression":{"Type":"IsNotEmpty","Expression":{"Field":"tags"}}}`), &q); err != nil {
+		t.Fatal(err)
+	}
+	for rec, want := range map[string]bool{
+		`{"tags":["a"]}`: true,
+		`{"tags":[]}`:    false,
+		`{"tags":null}`:  false,
+		`{"tags":""}`:    false,
+		`{}`:             false,
+	} {
+		var m map[string]interface{}
+		_ = json.Unmarshal([]byte(rec), &m)
+		if got, err := q.Evaluate(m); err != nil || got != want {
+			t.Errorf("%s: got %v, %v", rec, got, err)
+		}
+	}
+	data, err := json.Marshal(Query{Expression: &IsEmptyExpression{Field: "tags"}})
+	if err != nil || string(data) != `{"Expression":{"Type":"IsEmpty","Expression":{"Field":"tags"}}}` {
+		t.Errorf("marshal: %s, %v", data, err)
+	}
+	if ok, _ := (IsEmptyExpression{Field: "x"}).Evaluate(3); ok {
+		t.Errorf("non-record input should not match")
+	}
+}
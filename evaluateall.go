@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"math/bits"
+)
+
+// Bitset records which of a list of queries matched, one bit per query.
+type Bitset []uint64
+
+// NewBitset returns a Bitset able to hold n bits.
+func NewBitset(n int) Bitset {
+	return make(Bitset, (n+63)/64)
+}
+
+// Set sets bit i.
+func (b Bitset) Set(i int) {
+	b[i/64] |= 1 << (uint(i) % 64)
+}
+
+// Has reports whether bit i is set.
+func (b Bitset) Has(i int) bool {
+	return i/64 < len(b) && b[i/64]&(1<<(uint(i)%64)) != 0
+}
+
+// Count returns the number of bits set.
+func (b Bitset) Count() int {
+	n := 0
+	for _, w := range b {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Indices returns the positions of the set bits in ascending order.
+func (b Bitset) Indices() []int {
+	var out []int
+	for wi, w := range b {
+		for w != 0 {
+			tz := bits.TrailingZeros64(w)
+			out = append(out, wi*64+tz)
+			w &= w - 1
+		}
+	}
+	return out
+}
+
+// EvaluateAll evaluates every query against i and returns a Bitset with bit n
+// set when queries[n] matched. Field lookups are resolved once per record and
+// shared by all queries, which matters when many queries, such as a set of
+// classification rules, inspect the same fields. Custom Expressions and Terms
+// are given the record itself rather than the cache. Queries that fail to
+// evaluate leave their bit unset; pass an ErrorHandler in opts to observe the
+// errors. The record is prepared as by Query.Evaluate, so a struct value
+// given with Context.RequirePointer set matches nothing.
+func EvaluateAll(queries []Query, i interface{}, opts ...any) Bitset {
+	result := NewBitset(len(queries))
+	onError := getErrorHandler(opts)
+	rec, ok := prepareRecord(i, opts, true)
+	if !ok {
+		return result
+	}
+	for n := range queries {
+		if matchItem(queries[n], rec, onError, opts) {
+			result.Set(n)
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package evaluator_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Types outside the evaluator package must see the record they were given,
+// not the wrappers EvaluateAll, CacheFields and FoldFields use internally.
+type customRecord struct{ Name string }
+
+type vipExpression struct{}
+
+func (vipExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	r, ok := i.(*customRecord)
+	return ok && r.Name == "bob", nil
+}
+
+type nameTerm struct{}
+
+func (nameTerm) Evaluate(i interface{}, _ ...any) (interface{}, error) {
+	if r, ok := i.(*customRecord); ok {
+		return r.Name, nil
+	}
+	return nil, fmt.Errorf("unexpected record %T", i)
+}
+
+// isFunc reports whether its argument is want.
+type isFunc struct{ want interface{} }
+
+func (f isFunc) Call(args ...interface{}) (interface{}, error) {
+	return len(args) == 1 && args[0] == f.want, nil
+}
+
+func TestEvaluateAllCustomExpressions(t *testing.T) {
+	r := &customRecord{Name: "bob"}
+	queries := []evaluator.Query{
+		{Expression: vipExpression{}},
+		{Expression: &evaluator.ComparisonExpression{LHS: nameTerm{}, RHS: evaluator.Constant{Value: "bob"}, Operation: "eq"}},
+		{Expression: &evaluator.ComparisonExpression{
+			LHS:       evaluator.FunctionExpression{Func: isFunc{want: r}, Args: []evaluator.Term{evaluator.Self{}}},
+			RHS:       evaluator.Constant{Value: true},
+			Operation: "eq",
+		}},
+		{Expression: &evaluator.IsExpression{Field: "name", Value: "bob"}},
+	}
+	ctx := &evaluator.Context{FoldFields: true, CacheFields: true}
+	if got := fmt.Sprint(evaluator.EvaluateAll(queries, r, ctx).Indices()); got != "[0 1 2 3]" {
+		t.Errorf("got %s", got)
+	}
+}
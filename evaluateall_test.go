@@ -0,0 +1,87 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type countingGetter struct {
+	values map[string]interface{}
+	calls  map[string]int
+}
+
+func (g countingGetter) Get(name string) (interface{}, error) {
+	g.calls[name]++
+	v, ok := g.values[name]
+	if !ok {
+		return nil, errors.New("missing")
+	}
+	return v, nil
+}
+
+func TestEvaluateAll(t *testing.T) {
+	var queries []Query
+	for n := 0; n < 200; n++ {
+		queries = append(queries, Query{Expression: &GreaterThanExpression{Field: "Score", Value: n}})
+	}
+	queries = append(queries, Query{Expression: &IsExpression{Field: "Missing", Value: 1}})
+	g := countingGetter{values: map[string]interface{}{"Score": 70}, calls: map[string]int{}}
+	got := EvaluateAll(queries, &g)
+	if got.Count() != 70 || !got.Has(0) || !got.Has(69) || got.Has(70) || got.Has(200) {
+		t.Errorf("unexpected result %v", got.Indices())
+	}
+	if g.calls["Score"] != 1 || g.calls["Missing"] != 1 {
+		t.Errorf("fields resolved more than once: %v", g.calls)
+	}
+}
+
+func TestEvaluateAllStructAndMap(t *testing.T) {
+	type rec struct {
+		Name string
+		Tags []string
+	}
+	queries := []Query{
+		{Expression: &IsExpression{Field: "Name", Value: "bob"}},
+		{Expression: &ContainsExpression{Field: "Tags", Value: "x"}},
+		{Expression: &IsExpression{Field: "Name", Value: "alice"}},
+	}
+	for _, in := range []interface{}{
+		&rec{Name: "bob", Tags: []string{"x"}},
+		map[string]interface{}{"Name": "bob", "Tags": []string{"x"}},
+	} {
+		if got := fmt.Sprint(EvaluateAll(queries, in).Indices()); got != "[0 1]" {
+			t.Errorf("%T: got %s", in, got)
+		}
+	}
+	value := rec{Name: "bob", Tags: []string{"x"}}
+	if got := fmt.Sprint(EvaluateAll(queries, value).Indices()); got != "[0 1]" {
+		t.Errorf("struct value: got %s", got)
+	}
+	if got := EvaluateAll(queries, value, &Context{RequirePointer: true}); got.Count() != 0 {
+		t.Errorf("struct value with RequirePointer: got %v", got.Indices())
+	}
+	if got := fmt.Sprint(EvaluateAll(queries, &value, &Context{RequirePointer: true}).Indices()); got != "[0 1]" {
+		t.Errorf("pointer with RequirePointer: got %s", got)
+	}
+}
+
+func TestEvaluateAllErrors(t *testing.T) {
+	var errs int
+	got := EvaluateAll([]Query{{Expression: failingExpression{}}}, map[string]interface{}{}, ErrorHandler(func(error) { errs++ }))
+	if got.Has(0) || errs != 1 {
+		t.Errorf("got %v with %d errors", got.Indices(), errs)
+	}
+}
+
+func BenchmarkEvaluateAll(b *testing.B) {
+	var queries []Query
+	for n := 0; n < 200; n++ {
+		queries = append(queries, Query{Expression: &GreaterThanExpression{Field: "Score", Value: n}})
+	}
+	rec := map[string]interface{}{"Score": 70}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EvaluateAll(queries, rec)
+	}
+}
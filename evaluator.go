@@ -8,16 +8,67 @@ package evaluator
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand/v2"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
+
+	"github.com/arran4/go-evaluator/core"
 )
 
 // Context holds execution context for the evaluator, including variables and functions.
 type Context struct {
 	Functions map[string]Function
 	Variables map[string]interface{}
+	// Clock returns the current time for time-dependent evaluation. It
+	// defaults to time.Now and can be replaced for deterministic tests.
+	Clock func() time.Time
+	// Rand is the random source used by RandomTerm. Set it with
+	// NewSeededRand for reproducible sampling.
+	Rand *rand.Rand
+	// Rates converts between currencies when MoneyValues in different
+	// currencies are compared. Without it such comparisons fail.
+	Rates RateProvider
+	// CacheFields makes Query.Evaluate resolve each field once per
+	// evaluation, which helps when a query refers to the same field, or an
+	// expensive Getter, many times. Expressions, Terms and Self defined
+	// outside this package still receive the original record.
+	CacheFields bool
+	// MaxDepth limits how deeply And, Or, Xor, Implies, Case, Not and the
+	// list quantifiers may nest before evaluation fails with ErrMaxDepth. Zero uses
+	// DefaultMaxDepth and a negative value removes the limit.
+	MaxDepth int
+	// RequirePointer keeps the behaviour of earlier releases, where
+	// Query.Evaluate does not match a struct record passed by value. By
+	// default such records are evaluated through an addressable copy, the
+	// same as a pointer to the record.
+	RequirePointer bool
+	// Decimal compares numbers exactly in the ordering comparisons and
+	// Between, rather than converting both sides to float64, which cannot
+	// tell apart integers beyond 2^53 such as uint64 IDs. Values may also be
+	// *big.Int, *big.Rat, *big.Float, json.Number or numeric strings, which
+	// keep large literals and amounts exact. Comparisons that are not between
+	// two integers then allocate.
+	Decimal bool
+	// FoldFields matches field names case-insensitively when a record has no
+	// field of the exact name, so name finds Name or NAME, at each step of a
+	// path such as address.city. As with CacheFields, custom Expressions and
+	// Terms receive the original record.
+	FoldFields bool
+	// Formats adds validators for IsFormatExpression by format name,
+	// replacing any built-in format of the same name.
+	Formats map[string]func(string) bool
+}
+
+// Now returns the current time according to the context clock.
+func (c *Context) Now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
 }
 
 // GetContext extracts the Context from the variadic options, or returns a default one.
@@ -33,6 +84,17 @@ func GetContext(opts ...any) *Context {
 	}
 }
 
+// findContext returns the Context in opts, or nil without allocating a
+// default one when there is none.
+func findContext(opts []any) *Context {
+	for _, opt := range opts {
+		if ctx, ok := opt.(*Context); ok {
+			return ctx
+		}
+	}
+	return nil
+}
+
 // number represents any built-in numeric type.
 type number interface {
 	~int | ~int8 | ~int16 | ~int32 | ~int64 |
@@ -129,6 +191,10 @@ func stringValue(v interface{}) string {
 	switch s := v.(type) {
 	case string:
 		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(s), 'f', -1, 32)
 	default:
 		return fmt.Sprint(v)
 	}
@@ -136,16 +202,20 @@ func stringValue(v interface{}) string {
 
 // derefValue dereferences pointer inputs and returns the underlying value.
 // It supports structs and maps and returns false for all other types.
+// Records that resolve their own fields, such as a Getter or *sync.Map, are
+// returned as they are.
 func derefValue(i interface{}) (reflect.Value, bool) {
 	v := reflect.ValueOf(i)
+	if _, ok := resolver(v); ok {
+		return v, v.Kind() != reflect.Ptr || !v.IsNil()
+	}
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
 			return reflect.Value{}, false
 		}
 		v = v.Elem()
 	} else if v.Kind() == reflect.Struct {
-		// maintain backward behaviour: require pointer for structs
-		return reflect.Value{}, false
+		return derefValue(addressable(i))
 	}
 	switch v.Kind() {
 	case reflect.Struct, reflect.Map:
@@ -155,30 +225,96 @@ func derefValue(i interface{}) (reflect.Value, bool) {
 	}
 }
 
-// Getter interface allows for dynamic field retrieval.
-type Getter interface {
-	Get(name string) (interface{}, error)
+// addressable returns a pointer to a copy of i when i is a struct value, so
+// that it is evaluated like &i, including Get methods on the pointer type.
+// Other values are returned unchanged.
+func addressable(i interface{}) interface{} {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Struct {
+		return i
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface()
 }
 
+// Getter interface allows for dynamic field retrieval.
+type Getter = core.FieldResolver
+
 // getField retrieves a field value from either a struct, map, or Getter.
 // For structs it uses FieldByName, for maps it looks up the key by name,
-// and for Getter it calls Get.
+// and for Getter it calls Get. A path that is not found as a whole, such as
+// Address.City or Items[0].Price, is resolved one segment at a time.
 func getField(v reflect.Value, name string) (reflect.Value, bool) {
 	if v.Kind() == reflect.Invalid {
 		return reflect.Value{}, false
 	}
-	if v.CanInterface() {
-		if g, ok := v.Interface().(Getter); ok {
-			val, err := g.Get(name)
-			if err == nil {
-				if val == nil {
-					// Handle nil interface return
-					return reflect.Zero(reflect.TypeOf((*interface{})(nil)).Elem()), true
-				}
-				return reflect.ValueOf(val), true
+	if v.Type() == fieldCacheType && v.CanAddr() {
+		return v.Addr().Interface().(*fieldCache).lookup(name)
+	}
+	if v.Type() == foldedFieldsType && v.CanAddr() {
+		return v.Addr().Interface().(*foldedFields).lookup(name)
+	}
+	if f, ok := fieldByName(v, name); ok || !strings.ContainsAny(name, ".[") {
+		return f, ok
+	}
+	return fieldPath(v, name)
+}
+
+// fieldPath resolves a dotted path through nested structs, maps, Getters,
+// pointers and interfaces. Segments applied to slices and arrays are indexes,
+// written either as Items.0.Name or Items[0].Name. Each segment descends one
+// level, so cyclic data such as a map holding itself is safe to walk; paths
+// with more than DefaultMaxDepth segments are not resolved.
+func fieldPath(v reflect.Value, path string) (reflect.Value, bool) {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	segs := strings.Split(path, ".")
+	if len(segs) > DefaultMaxDepth {
+		return reflect.Value{}, false
+	}
+	for _, seg := range segs {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			if _, ok := resolver(v); ok {
+				break
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			n, err := strconv.Atoi(seg)
+			if err != nil || n < 0 || n >= v.Len() {
+				return reflect.Value{}, false
+			}
+			v = v.Index(n)
+		default:
+			f, ok := fieldByName(v, seg)
+			if !ok {
+				return reflect.Value{}, false
 			}
+			v = f
+		}
+	}
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// fieldByName looks name up directly on v.
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	if r, ok := resolver(v); ok {
+		val, found := r.get(name)
+		if !found {
 			return reflect.Value{}, false
 		}
+		if val == nil {
+			// Handle nil interface return
+			return reflect.Zero(reflect.TypeOf((*interface{})(nil)).Elem()), true
+		}
+		return reflect.ValueOf(val), true
 	}
 
 	switch v.Kind() {
@@ -252,14 +388,11 @@ func lessOrEqual[T number](f T, v interface{}) bool {
 	return f <= n
 }
 
-type Term interface {
-	Evaluate(i interface{}, opts ...any) (interface{}, error)
-}
+// Term produces a value from the record being evaluated.
+type Term = core.Term
 
 // Function defines the interface for a function that can be called by FunctionExpression.
-type Function interface {
-	Call(args ...interface{}) (interface{}, error)
-}
+type Function = core.Function
 
 // FunctionExpression represents a function call.
 type FunctionExpression struct {
@@ -286,7 +419,7 @@ func (f FunctionExpression) Evaluate(i interface{}, opts ...any) (interface{}, e
 
 	args := make([]interface{}, len(f.Args))
 	for idx, arg := range f.Args {
-		val, err := arg.Evaluate(i, opts...)
+		val, err := evalTerm(arg, i, opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -295,7 +428,10 @@ func (f FunctionExpression) Evaluate(i interface{}, opts ...any) (interface{}, e
 	return fn.Call(args...)
 }
 
-// Field represents a field lookup term.
+// Field evaluates to a field of the record, so functions and comparisons can
+// work on record data. Name may be a dotted path such as Customer.Address.City
+// or Items[0].Price. A missing field is an error, which fails the enclosing
+// FunctionExpression.
 type Field struct {
 	Name string
 }
@@ -328,7 +464,7 @@ func (c Constant) Evaluate(_ interface{}, _ ...any) (interface{}, error) {
 type Self struct{}
 
 func (s Self) Evaluate(i interface{}, _ ...any) (interface{}, error) {
-	return i, nil
+	return unwrapRecord(i), nil
 }
 
 // BoolType converts the term result to a boolean.
@@ -337,7 +473,7 @@ type BoolType struct {
 }
 
 func (b BoolType) Evaluate(i interface{}, opts ...any) (interface{}, error) {
-	val, err := b.Term.Evaluate(i, opts...)
+	val, err := evalTerm(b.Term, i, opts...)
 	if err != nil {
 		return false, err
 	}
@@ -386,7 +522,7 @@ type If struct {
 }
 
 func (e If) Evaluate(i interface{}, opts ...any) (interface{}, error) {
-	condVal, err := e.Condition.Evaluate(i, opts...)
+	condVal, err := evalTerm(e.Condition, i, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -395,10 +531,10 @@ func (e If) Evaluate(i interface{}, opts ...any) (interface{}, error) {
 		return nil, err
 	}
 	if b {
-		return e.Then.Evaluate(i, opts...)
+		return evalTerm(e.Then, i, opts...)
 	}
 	if e.Else != nil {
-		return e.Else.Evaluate(i, opts...)
+		return evalTerm(e.Else, i, opts...)
 	}
 	return nil, nil // Or appropriate zero value
 }
@@ -411,15 +547,56 @@ type ComparisonExpression struct {
 }
 
 func (e ComparisonExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
-	lhs, err := e.LHS.Evaluate(i, opts...)
+	lhs, err := evalTerm(e.LHS, i, opts...)
 	if err != nil {
 		return false, err
 	}
-	rhs, err := e.RHS.Evaluate(i, opts...)
+	rhs, err := evalTerm(e.RHS, i, opts...)
 	if err != nil {
 		return false, err
 	}
+	if c, ok, err := moneyCompare(reflect.ValueOf(lhs), rhs, opts); ok {
+		switch e.Operation {
+		case "eq":
+			return err == nil && c == 0, err
+		case "neq":
+			return err == nil && c != 0, err
+		case "gt":
+			return err == nil && c > 0, err
+		case "gte":
+			return err == nil && c >= 0, err
+		case "lt":
+			return err == nil && c < 0, err
+		case "lte":
+			return err == nil && c <= 0, err
+		}
+	}
+
+	if c, ok := decimalCompare(reflect.ValueOf(lhs), rhs, opts); ok {
+		switch e.Operation {
+		case "eq":
+			return c == 0, nil
+		case "neq":
+			return c != 0, nil
+		case "gt":
+			return c > 0, nil
+		case "gte":
+			return c >= 0, nil
+		case "lt":
+			return c < 0, nil
+		case "lte":
+			return c <= 0, nil
+		}
+	}
 
+	// A missing value, such as the sum of a list that is not there, has no
+	// order, so it is neither greater nor less than anything.
+	if lhs == nil || rhs == nil {
+		switch e.Operation {
+		case "gt", "gte", "lt", "lte":
+			return false, nil
+		}
+	}
 	switch e.Operation {
 	case "eq":
 		cmp, err := Compare(lhs, rhs)
@@ -452,17 +629,19 @@ func (e ComparisonExpression) Evaluate(i interface{}, opts ...any) (bool, error)
 }
 
 // Expression represents a single boolean expression that can be evaluated
-// against a struct value.
-type Expression interface {
-	// Evaluate returns true if the expression matches the supplied value.
-	Evaluate(i interface{}, opts ...any) (bool, error)
-}
+// against a struct value. Evaluate returns true if the expression matches the
+// supplied value.
+type Expression = core.Expression
 
 // ContainsExpression checks whether a slice field contains the given Value,
-// or if a string field contains the given substring.
+// whether a map field holds it as one of its values, or if a string field
+// contains the given substring. Pointer and interface fields, such as
+// *string, are looked through. HasKeyExpression tests the keys of a map.
 type ContainsExpression struct {
 	Field string
 	Value interface{}
+	// Fold matches substrings and string elements case-insensitively.
+	Fold bool `json:",omitempty"`
 }
 
 func (e ContainsExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
@@ -474,26 +653,59 @@ func (e ContainsExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 	if !ok {
 		return false, nil
 	}
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return false, nil
+		}
+		f = f.Elem()
+	}
 	if f.Kind() == reflect.String {
 		sval := stringValue(e.Value)
+		if e.Fold {
+			return strings.Contains(strings.ToLower(f.String()), strings.ToLower(sval)), nil
+		}
 		return strings.Contains(f.String(), sval), nil
 	}
-	if f.Kind() != reflect.Slice {
+	if f.Kind() != reflect.Slice && f.Kind() != reflect.Map {
 		return false, nil
 	}
 	cv := reflect.ValueOf(e.Value)
 	if !cv.IsValid() {
 		return false, nil
 	}
-	if f.Type().Elem().Kind() != cv.Type().Kind() {
+	if e.Fold && cv.Kind() == reflect.String {
+		return anyElement(f, func(el reflect.Value) bool {
+			if el.Kind() == reflect.Interface {
+				el = el.Elem()
+			}
+			return el.Kind() == reflect.String && strings.EqualFold(el.String(), cv.String())
+		}), nil
+	}
+	if ek := f.Type().Elem().Kind(); ek != reflect.Interface && ek != cv.Type().Kind() {
 		return false, nil
 	}
+	return anyElement(f, func(el reflect.Value) bool {
+		return reflect.DeepEqual(el.Interface(), cv.Interface())
+	}), nil
+}
+
+// anyElement reports whether match holds for an element of the slice f or
+// for one of the values of the map f.
+func anyElement(f reflect.Value, match func(el reflect.Value) bool) bool {
+	if f.Kind() == reflect.Map {
+		for it := f.MapRange(); it.Next(); {
+			if match(it.Value()) {
+				return true
+			}
+		}
+		return false
+	}
 	for i := 0; i < f.Len(); i++ {
-		if reflect.DeepEqual(f.Index(i).Interface(), cv.Interface()) {
-			return true, nil
+		if match(f.Index(i)) {
+			return true
 		}
 	}
-	return false, nil
+	return false
 }
 
 // IContainsExpression checks whether a string field contains the given substring (case-insensitive).
@@ -518,13 +730,93 @@ func (e IContainsExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 	return false, nil
 }
 
+// StartsWithExpression succeeds when the string Field begins with Value.
+type StartsWithExpression struct {
+	Field string
+	Value interface{}
+}
+
+func (e StartsWithExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || f.Kind() != reflect.String {
+		return false, nil
+	}
+	return strings.HasPrefix(f.String(), stringValue(e.Value)), nil
+}
+
+// EndsWithExpression succeeds when the string Field ends with Value.
+type EndsWithExpression struct {
+	Field string
+	Value interface{}
+}
+
+func (e EndsWithExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || f.Kind() != reflect.String {
+		return false, nil
+	}
+	return strings.HasSuffix(f.String(), stringValue(e.Value)), nil
+}
+
+// MatchesExpression succeeds when the string Field matches the regular
+// expression Pattern. The compiled pattern is cached after the first
+// evaluation; an invalid Pattern is reported as an error.
+type MatchesExpression struct {
+	Field   string
+	Pattern string
+	re      atomic.Pointer[compiledPattern]
+}
+
+type compiledPattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func (e *MatchesExpression) regexp() (*regexp.Regexp, error) {
+	if c := e.re.Load(); c != nil && c.pattern == e.Pattern {
+		return c.re, nil
+	}
+	re, err := regexp.Compile(e.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	e.re.Store(&compiledPattern{pattern: e.Pattern, re: re})
+	return re, nil
+}
+
+func (e *MatchesExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	re, err := e.regexp()
+	if err != nil {
+		return false, err
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || f.Kind() != reflect.String {
+		return false, nil
+	}
+	return re.MatchString(f.String()), nil
+}
+
 // IsNotExpression succeeds when the specified Field does not equal Value.
 type IsNotExpression struct {
 	Field string
 	Value interface{}
+	// Fold compares strings case-insensitively.
+	Fold bool `json:",omitempty"`
 }
 
-func (e IsNotExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+func (e IsNotExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 	v, ok := derefValue(i)
 	if !ok {
 		return false, nil
@@ -533,6 +825,12 @@ func (e IsNotExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 	if !ok {
 		return false, nil
 	}
+	if c, ok, err := moneyCompare(f, e.Value, opts); ok {
+		return err == nil && c != 0, err
+	}
+	if e.Fold && f.Kind() == reflect.String {
+		return !strings.EqualFold(f.String(), stringValue(e.Value)), nil
+	}
 	return !reflect.DeepEqual(f.Interface(), e.Value), nil
 }
 
@@ -540,9 +838,11 @@ func (e IsNotExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 type IsExpression struct {
 	Field string
 	Value interface{}
+	// Fold compares strings case-insensitively.
+	Fold bool `json:",omitempty"`
 }
 
-func (e IsExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+func (e IsExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 	v, ok := derefValue(i)
 	if !ok {
 		return false, nil
@@ -551,6 +851,12 @@ func (e IsExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 	if !ok {
 		return false, nil
 	}
+	if c, ok, err := moneyCompare(f, e.Value, opts); ok {
+		return err == nil && c == 0, err
+	}
+	if e.Fold && f.Kind() == reflect.String {
+		return strings.EqualFold(f.String(), stringValue(e.Value)), nil
+	}
 	if e.Value == nil {
 		switch f.Kind() {
 		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
@@ -601,6 +907,46 @@ func (e OrExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 	return false, nil
 }
 
+// XorExpression evaluates to true when exactly one of the child Expressions
+// does, which suits rules whose options are mutually exclusive. Evaluation
+// stops at the second match.
+type XorExpression struct {
+	Expressions []Query `json:"Expressions"`
+}
+
+func (e XorExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	found := false
+	for _, q := range e.Expressions {
+		matched, err := q.Evaluate(i, opts...)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			if found {
+				return false, nil
+			}
+			found = true
+		}
+	}
+	return found, nil
+}
+
+// ImpliesExpression evaluates to true unless If matches and Then does not,
+// the shape of a policy rule such as "if Env is prod then Replicas >= 3".
+// Then is only evaluated when If matches.
+type ImpliesExpression struct {
+	If   Query `json:"If"`
+	Then Query `json:"Then"`
+}
+
+func (e ImpliesExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	matched, err := e.If.Evaluate(i, opts...)
+	if err != nil || !matched {
+		return err == nil, err
+	}
+	return e.Then.Evaluate(i, opts...)
+}
+
 // NotExpression inverts the result of a single child Expression.
 type NotExpression struct {
 	Expression Query `json:"Expression"`
@@ -619,10 +965,12 @@ func (e NotExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 type GreaterThanExpression struct {
 	Field string
 	Value interface{}
-	sVal  atomic.Pointer[string]
+	// Fold compares strings case-insensitively.
+	Fold bool `json:",omitempty"`
+	sVal atomic.Pointer[string]
 }
 
-func (e *GreaterThanExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+func (e *GreaterThanExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 	v, ok := derefValue(i)
 	if !ok {
 		return false, nil
@@ -631,6 +979,12 @@ func (e *GreaterThanExpression) Evaluate(i interface{}, _ ...any) (bool, error)
 	if !ok {
 		return false, nil
 	}
+	if c, ok, err := moneyCompare(f, e.Value, opts); ok {
+		return err == nil && c > 0, err
+	}
+	if c, ok := decimalCompare(f, e.Value, opts); ok {
+		return c > 0, nil
+	}
 	switch f.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return greater[int64](f.Int(), e.Value), nil
@@ -639,6 +993,9 @@ func (e *GreaterThanExpression) Evaluate(i interface{}, _ ...any) (bool, error)
 	case reflect.Float32, reflect.Float64:
 		return greater[float64](f.Float(), e.Value), nil
 	case reflect.String:
+		if e.Fold {
+			return strings.Compare(strings.ToLower(f.String()), strings.ToLower(stringValue(e.Value))) > 0, nil
+		}
 		if s, ok := e.Value.(string); ok {
 			return strings.Compare(f.String(), s) > 0, nil
 		}
@@ -661,10 +1018,12 @@ func (e *GreaterThanExpression) Evaluate(i interface{}, _ ...any) (bool, error)
 type GreaterThanOrEqualExpression struct {
 	Field string
 	Value interface{}
-	sVal  atomic.Pointer[string]
+	// Fold compares strings case-insensitively.
+	Fold bool `json:",omitempty"`
+	sVal atomic.Pointer[string]
 }
 
-func (e *GreaterThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+func (e *GreaterThanOrEqualExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 	v, ok := derefValue(i)
 	if !ok {
 		return false, nil
@@ -673,6 +1032,12 @@ func (e *GreaterThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool,
 	if !ok {
 		return false, nil
 	}
+	if c, ok, err := moneyCompare(f, e.Value, opts); ok {
+		return err == nil && c >= 0, err
+	}
+	if c, ok := decimalCompare(f, e.Value, opts); ok {
+		return c >= 0, nil
+	}
 	switch f.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return greaterOrEqual[int64](f.Int(), e.Value), nil
@@ -681,6 +1046,9 @@ func (e *GreaterThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool,
 	case reflect.Float32, reflect.Float64:
 		return greaterOrEqual[float64](f.Float(), e.Value), nil
 	case reflect.String:
+		if e.Fold {
+			return strings.Compare(strings.ToLower(f.String()), strings.ToLower(stringValue(e.Value))) >= 0, nil
+		}
 		if s, ok := e.Value.(string); ok {
 			return strings.Compare(f.String(), s) >= 0, nil
 		}
@@ -702,10 +1070,12 @@ func (e *GreaterThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool,
 type LessThanExpression struct {
 	Field string
 	Value interface{}
-	sVal  atomic.Pointer[string]
+	// Fold compares strings case-insensitively.
+	Fold bool `json:",omitempty"`
+	sVal atomic.Pointer[string]
 }
 
-func (e *LessThanExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+func (e *LessThanExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 	v, ok := derefValue(i)
 	if !ok {
 		return false, nil
@@ -714,6 +1084,12 @@ func (e *LessThanExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 	if !ok {
 		return false, nil
 	}
+	if c, ok, err := moneyCompare(f, e.Value, opts); ok {
+		return err == nil && c < 0, err
+	}
+	if c, ok := decimalCompare(f, e.Value, opts); ok {
+		return c < 0, nil
+	}
 	switch f.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return less[int64](f.Int(), e.Value), nil
@@ -722,6 +1098,9 @@ func (e *LessThanExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 	case reflect.Float32, reflect.Float64:
 		return less[float64](f.Float(), e.Value), nil
 	case reflect.String:
+		if e.Fold {
+			return strings.Compare(strings.ToLower(f.String()), strings.ToLower(stringValue(e.Value))) < 0, nil
+		}
 		if s, ok := e.Value.(string); ok {
 			return strings.Compare(f.String(), s) < 0, nil
 		}
@@ -743,10 +1122,12 @@ func (e *LessThanExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
 type LessThanOrEqualExpression struct {
 	Field string
 	Value interface{}
-	sVal  atomic.Pointer[string]
+	// Fold compares strings case-insensitively.
+	Fold bool `json:",omitempty"`
+	sVal atomic.Pointer[string]
 }
 
-func (e *LessThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+func (e *LessThanOrEqualExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
 	v, ok := derefValue(i)
 	if !ok {
 		return false, nil
@@ -755,6 +1136,12 @@ func (e *LessThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool, err
 	if !ok {
 		return false, nil
 	}
+	if c, ok, err := moneyCompare(f, e.Value, opts); ok {
+		return err == nil && c <= 0, err
+	}
+	if c, ok := decimalCompare(f, e.Value, opts); ok {
+		return c <= 0, nil
+	}
 	switch f.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return lessOrEqual[int64](f.Int(), e.Value), nil
@@ -763,6 +1150,9 @@ func (e *LessThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool, err
 	case reflect.Float32, reflect.Float64:
 		return lessOrEqual[float64](f.Float(), e.Value), nil
 	case reflect.String:
+		if e.Fold {
+			return strings.Compare(strings.ToLower(f.String()), strings.ToLower(stringValue(e.Value))) <= 0, nil
+		}
 		if s, ok := e.Value.(string); ok {
 			return strings.Compare(f.String(), s) <= 0, nil
 		}
@@ -785,6 +1175,22 @@ func (e *LessThanOrEqualExpression) Evaluate(i interface{}, _ ...any) (bool, err
 type QueryRaw struct {
 	Expression        Expression      `json:"-"`
 	ExpressionRawJSON json.RawMessage `json:"Expression"`
+	// Metadata carries optional provenance information. It is preserved
+	// through marshaling but never affects evaluation or Hash.
+	Metadata *Metadata `json:"Metadata,omitempty"`
+	// OnEvalError sets the ErrorPolicy applied when a leaf expression in
+	// this query fails. Nested queries may override it.
+	OnEvalError ErrorPolicy `json:"OnEvalError,omitempty"`
+}
+
+// Metadata records who wrote a query, when and why.
+type Metadata struct {
+	Author      string     `json:"Author,omitempty"`
+	Created     *time.Time `json:"Created,omitempty"`
+	Updated     *time.Time `json:"Updated,omitempty"`
+	Description string     `json:"Description,omitempty"`
+	Version     string     `json:"Version,omitempty"`
+	Tags        []string   `json:"Tags,omitempty"`
 }
 
 // Query wraps QueryRaw and provides evaluation and JSON unmarshalling helpers.
@@ -812,6 +1218,106 @@ func marshalExpression(e Expression) ([]byte, error) {
 			Type:       "IContains",
 			Expression: expr,
 		})
+	case *StartsWithExpression:
+		return json.Marshal(typedExpression[*StartsWithExpression]{
+			Type:       "StartsWith",
+			Expression: expr,
+		})
+	case *EndsWithExpression:
+		return json.Marshal(typedExpression[*EndsWithExpression]{
+			Type:       "EndsWith",
+			Expression: expr,
+		})
+	case *GlobExpression:
+		return json.Marshal(typedExpression[*GlobExpression]{
+			Type:       "Glob",
+			Expression: expr,
+		})
+	case *FuzzyMatchExpression:
+		return json.Marshal(typedExpression[*FuzzyMatchExpression]{
+			Type:       "FuzzyMatch",
+			Expression: expr,
+		})
+	case *SoundsLikeExpression:
+		return json.Marshal(typedExpression[*SoundsLikeExpression]{
+			Type:       "SoundsLike",
+			Expression: expr,
+		})
+	case *HasBitsExpression:
+		return json.Marshal(typedExpression[*HasBitsExpression]{
+			Type:       "HasBits",
+			Expression: expr,
+		})
+	case *ModExpression:
+		return json.Marshal(typedExpression[*ModExpression]{
+			Type:       "Mod",
+			Expression: expr,
+		})
+	case *ScoreQuery:
+		return json.Marshal(typedExpression[*ScoreQuery]{
+			Type:       "Score",
+			Expression: expr,
+		})
+	case *JSONPathExpression:
+		return json.Marshal(typedExpression[*JSONPathExpression]{
+			Type:       "JSONPath",
+			Expression: expr,
+		})
+	case *IsFormatExpression:
+		return json.Marshal(typedExpression[*IsFormatExpression]{
+			Type:       "IsFormat",
+			Expression: expr,
+		})
+	case *GeoWithinExpression:
+		return json.Marshal(typedExpression[*GeoWithinExpression]{
+			Type:       "GeoWithin",
+			Expression: expr,
+		})
+	case *ComparisonExpression:
+		return json.Marshal(typedExpression[*ComparisonExpression]{
+			Type:       "Comparison",
+			Expression: expr,
+		})
+	case *MatchesExpression:
+		return json.Marshal(typedExpression[*MatchesExpression]{
+			Type:       "Matches",
+			Expression: expr,
+		})
+	case *TypeOfExpression:
+		return json.Marshal(typedExpression[*TypeOfExpression]{
+			Type:       "TypeOf",
+			Expression: expr,
+		})
+	case *LengthExpression:
+		return json.Marshal(typedExpression[*LengthExpression]{
+			Type:       "Length",
+			Expression: expr,
+		})
+	case *IsEmptyExpression:
+		return json.Marshal(typedExpression[*IsEmptyExpression]{
+			Type:       "IsEmpty",
+			Expression: expr,
+		})
+	case *IsNotEmptyExpression:
+		return json.Marshal(typedExpression[*IsNotEmptyExpression]{
+			Type:       "IsNotEmpty",
+			Expression: expr,
+		})
+	case *BetweenExpression:
+		return json.Marshal(typedExpression[*BetweenExpression]{
+			Type:       "Between",
+			Expression: expr,
+		})
+	case *InExpression:
+		return json.Marshal(typedExpression[*InExpression]{
+			Type:       "In",
+			Expression: expr,
+		})
+	case *InFileExpression:
+		return json.Marshal(typedExpression[*InFileExpression]{
+			Type:       "InFile",
+			Expression: expr,
+		})
 	case *IsNotExpression:
 		return json.Marshal(typedExpression[*IsNotExpression]{
 			Type:       "IsNot",
@@ -832,11 +1338,81 @@ func marshalExpression(e Expression) ([]byte, error) {
 			Type:       "Or",
 			Expression: expr,
 		})
+	case *XorExpression:
+		return json.Marshal(typedExpression[*XorExpression]{
+			Type:       "Xor",
+			Expression: expr,
+		})
+	case *ImpliesExpression:
+		return json.Marshal(typedExpression[*ImpliesExpression]{
+			Type:       "Implies",
+			Expression: expr,
+		})
+	case *CaseExpression:
+		return json.Marshal(typedExpression[*CaseExpression]{
+			Type:       "Case",
+			Expression: expr,
+		})
 	case *NotExpression:
 		return json.Marshal(typedExpression[*NotExpression]{
 			Type:       "Not",
 			Expression: expr,
 		})
+	case *AnyExpression:
+		return json.Marshal(typedExpression[*AnyExpression]{
+			Type:       "Any",
+			Expression: expr,
+		})
+	case *AllExpression:
+		return json.Marshal(typedExpression[*AllExpression]{
+			Type:       "All",
+			Expression: expr,
+		})
+	case *CountExpression:
+		return json.Marshal(typedExpression[*CountExpression]{
+			Type:       "Count",
+			Expression: expr,
+		})
+	case *FieldCompareExpression:
+		return json.Marshal(typedExpression[*FieldCompareExpression]{
+			Type:       "FieldCompare",
+			Expression: expr,
+		})
+	case *BeforeExpression:
+		return json.Marshal(typedExpression[*BeforeExpression]{
+			Type:       "Before",
+			Expression: expr,
+		})
+	case *AfterExpression:
+		return json.Marshal(typedExpression[*AfterExpression]{
+			Type:       "After",
+			Expression: expr,
+		})
+	case *WithinDurationExpression:
+		return json.Marshal(typedExpression[*WithinDurationExpression]{
+			Type:       "Within",
+			Expression: expr,
+		})
+	case *CIDRContainsExpression:
+		return json.Marshal(typedExpression[*CIDRContainsExpression]{
+			Type:       "CIDRContains",
+			Expression: expr,
+		})
+	case *HasKeyExpression:
+		return json.Marshal(typedExpression[*HasKeyExpression]{
+			Type:       "HasKey",
+			Expression: expr,
+		})
+	case *ContainsAllExpression:
+		return json.Marshal(typedExpression[*ContainsAllExpression]{
+			Type:       "ContainsAll",
+			Expression: expr,
+		})
+	case *ContainsAnyExpression:
+		return json.Marshal(typedExpression[*ContainsAnyExpression]{
+			Type:       "ContainsAny",
+			Expression: expr,
+		})
 	case *GreaterThanExpression:
 		return json.Marshal(typedExpression[*GreaterThanExpression]{
 			Type:       "GT",
@@ -857,6 +1433,11 @@ func marshalExpression(e Expression) ([]byte, error) {
 			Type:       "LTE",
 			Expression: expr,
 		})
+	case *RolloutExpression:
+		return json.Marshal(typedExpression[*RolloutExpression]{
+			Type:       "Rollout",
+			Expression: expr,
+		})
 	default:
 		return nil, fmt.Errorf("unknown expression type %T", e)
 	}
@@ -869,6 +1450,9 @@ func unmarshalExpression(data []byte) (Expression, error) {
 	if err := json.Unmarshal(data, &hdr); err != nil {
 		return nil, err
 	}
+	if current, ok := legacyTypeNames[hdr.Type]; ok {
+		hdr.Type = current
+	}
 	switch hdr.Type {
 	case "Contains":
 		var te typedExpression[*ContainsExpression]
@@ -882,6 +1466,126 @@ func unmarshalExpression(data []byte) (Expression, error) {
 			return nil, err
 		}
 		return te.Expression, nil
+	case "StartsWith":
+		var te typedExpression[*StartsWithExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "EndsWith":
+		var te typedExpression[*EndsWithExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Glob":
+		var te typedExpression[*GlobExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "FuzzyMatch":
+		var te typedExpression[*FuzzyMatchExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "SoundsLike":
+		var te typedExpression[*SoundsLikeExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "HasBits":
+		var te typedExpression[*HasBitsExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Mod":
+		var te typedExpression[*ModExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Score":
+		var te typedExpression[*ScoreQuery]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "JSONPath":
+		var te typedExpression[*JSONPathExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "IsFormat":
+		var te typedExpression[*IsFormatExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "GeoWithin":
+		var te typedExpression[*GeoWithinExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Comparison":
+		var te typedExpression[*ComparisonExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Matches":
+		var te typedExpression[*MatchesExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "TypeOf":
+		var te typedExpression[*TypeOfExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Length":
+		var te typedExpression[*LengthExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "IsEmpty":
+		var te typedExpression[*IsEmptyExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "IsNotEmpty":
+		var te typedExpression[*IsNotEmptyExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Between":
+		var te typedExpression[*BetweenExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "In":
+		var te typedExpression[*InExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "InFile":
+		var te typedExpression[*InFileExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
 	case "IsNot":
 		var te typedExpression[*IsNotExpression]
 		if err := json.Unmarshal(data, &te); err != nil {
@@ -906,12 +1610,96 @@ func unmarshalExpression(data []byte) (Expression, error) {
 			return nil, err
 		}
 		return te.Expression, nil
+	case "Xor":
+		var te typedExpression[*XorExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Implies":
+		var te typedExpression[*ImpliesExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Case":
+		var te typedExpression[*CaseExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
 	case "Not":
 		var te typedExpression[*NotExpression]
 		if err := json.Unmarshal(data, &te); err != nil {
 			return nil, err
 		}
 		return te.Expression, nil
+	case "Any":
+		var te typedExpression[*AnyExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "All":
+		var te typedExpression[*AllExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Count":
+		var te typedExpression[*CountExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "FieldCompare":
+		var te typedExpression[*FieldCompareExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Before":
+		var te typedExpression[*BeforeExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "After":
+		var te typedExpression[*AfterExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "Within":
+		var te typedExpression[*WithinDurationExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "CIDRContains":
+		var te typedExpression[*CIDRContainsExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "HasKey":
+		var te typedExpression[*HasKeyExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "ContainsAll":
+		var te typedExpression[*ContainsAllExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
+	case "ContainsAny":
+		var te typedExpression[*ContainsAnyExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
 	case "GT":
 		var te typedExpression[*GreaterThanExpression]
 		if err := json.Unmarshal(data, &te); err != nil {
@@ -936,16 +1724,77 @@ func unmarshalExpression(data []byte) (Expression, error) {
 			return nil, err
 		}
 		return te.Expression, nil
+	case "Rollout":
+		var te typedExpression[*RolloutExpression]
+		if err := json.Unmarshal(data, &te); err != nil {
+			return nil, err
+		}
+		return te.Expression, nil
 	default:
 		return nil, fmt.Errorf("unrecognized type value %q", hdr.Type)
 	}
 }
 
 func (q *Query) Evaluate(i interface{}, opts ...any) (bool, error) {
-	if q.Expression != nil {
-		return q.Expression.Evaluate(i, opts...)
+	return evaluateQuery(q, i, opts, func(e Expression, i interface{}, opts []any) (bool, error) {
+		return e.Evaluate(i, opts...)
+	}, func(b bool) bool { return b })
+}
+
+// prepareRecord readies i for evaluation as Query.Evaluate does: struct
+// values are evaluated through an addressable copy and wrapped for folded
+// and, when cache is set or opts ask for it, cached field lookups. It
+// reports false when opts require records to be pointers and i is not.
+func prepareRecord(i interface{}, opts []any, cache bool) (interface{}, bool) {
+	if reflect.ValueOf(i).Kind() == reflect.Struct {
+		if ctx := findContext(opts); ctx != nil && ctx.RequirePointer {
+			return nil, false
+		}
+		i = addressable(i)
 	}
-	return false, nil
+	if foldFields(opts) {
+		i = withFoldedFields(i)
+	}
+	if cache || cacheFields(opts) {
+		i = withFieldCache(i)
+	}
+	return i, true
+}
+
+// evaluateQuery runs eval on the expression of q with the record prepared,
+// the error policy of q applied to leaves and the nesting depth enforced,
+// so that Query.Evaluate and Query.Score treat queries alike. fromBool
+// converts the result of an error policy.
+func evaluateQuery[T any](q *Query, i interface{}, opts []any, eval func(Expression, interface{}, []any) (T, error), fromBool func(bool) T) (T, error) {
+	var zero T
+	if q.Expression == nil {
+		return zero, nil
+	}
+	if debugChecks {
+		debugCheckQuery(q, opts)
+	}
+	i, ok := prepareRecord(i, opts, false)
+	if !ok {
+		return zero, nil
+	}
+	if q.OnEvalError != FailError {
+		opts = withErrorPolicy(opts, q.OnEvalError)
+	}
+	leaf := isLeaf(q.Expression)
+	if !leaf {
+		var depth *evalDepth
+		var err error
+		if opts, depth, err = enterDepth(opts); err != nil {
+			return zero, err
+		}
+		defer depth.leave()
+	}
+	v, err := eval(q.Expression, recordFor(q.Expression, i), opts)
+	if err != nil && leaf {
+		b, err := applyErrorPolicy(err, opts)
+		return fromBool(b), err
+	}
+	return v, err
 }
 
 func (q *Query) UnmarshalJSON(data []byte) error {
@@ -959,6 +1808,7 @@ func (q *Query) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
+	decodeExpressionMoney(expr)
 	q.Expression = expr
 	return nil
 }
@@ -969,7 +1819,7 @@ func (q Query) MarshalJSON() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		return json.Marshal(&QueryRaw{ExpressionRawJSON: data})
+		return json.Marshal(&QueryRaw{ExpressionRawJSON: data, Metadata: q.Metadata, OnEvalError: q.OnEvalError})
 	}
-	return json.Marshal(&QueryRaw{ExpressionRawJSON: q.ExpressionRawJSON})
+	return json.Marshal(&QueryRaw{ExpressionRawJSON: q.ExpressionRawJSON, Metadata: q.Metadata, OnEvalError: q.OnEvalError})
 }
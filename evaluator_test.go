@@ -20,6 +20,84 @@ func TestContainsExpression(t *testing.T) {
 	if v, err := (ContainsExpression{Field: "Tags", Value: "c"}.Evaluate(u)); err != nil || v {
 		t.Errorf("expected false, got %v, %v", v, err)
 	}
+	m := map[string]interface{}{"Tags": []interface{}{"a", 1}}
+	if v, err := (ContainsExpression{Field: "Tags", Value: "a"}.Evaluate(m)); err != nil || !v {
+		t.Errorf("expected true for interface slice, got %v, %v", v, err)
+	}
+}
+
+func TestContainsMapValues(t *testing.T) {
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"labels": {"env": "prod", "team": "Payments"}}`), &rec); err != nil {
+		t.Fatal(err)
+	}
+	type host struct{ Ports map[string]int }
+	h := &host{Ports: map[string]int{"http": 80, "https": 443}}
+	tests := []struct {
+		name string
+		in   interface{}
+		e    ContainsExpression
+		want bool
+	}{
+		{"value", rec, ContainsExpression{Field: "labels", Value: "prod"}, true},
+		{"key is not a value", rec, ContainsExpression{Field: "labels", Value: "env"}, false},
+		{"fold", rec, ContainsExpression{Field: "labels", Value: "payments", Fold: true}, true},
+		{"case sensitive", rec, ContainsExpression{Field: "labels", Value: "payments"}, false},
+		{"typed map", h, ContainsExpression{Field: "Ports", Value: 443}, true},
+		{"typed map mismatch", h, ContainsExpression{Field: "Ports", Value: "443"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.Evaluate(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsSubstring(t *testing.T) {
+	msg := "connection timeout after 30s"
+	type logLine struct {
+		Message string
+		Detail  *string
+		Extra   interface{}
+		Missing *string
+	}
+	l := &logLine{Message: msg, Detail: &msg, Extra: msg}
+	var jsonl map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"msg": "connection timeout after 30s"}`), &jsonl); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   interface{}
+		e    ContainsExpression
+		want bool
+	}{
+		{"string", l, ContainsExpression{Field: "Message", Value: "timeout"}, true},
+		{"absent", l, ContainsExpression{Field: "Message", Value: "refused"}, false},
+		{"fold", l, ContainsExpression{Field: "Message", Value: "TIMEOUT", Fold: true}, true},
+		{"case sensitive", l, ContainsExpression{Field: "Message", Value: "TIMEOUT"}, false},
+		{"pointer", l, ContainsExpression{Field: "Detail", Value: "after"}, true},
+		{"interface", l, ContainsExpression{Field: "Extra", Value: "30s"}, true},
+		{"nil pointer", l, ContainsExpression{Field: "Missing", Value: ""}, false},
+		{"jsonl", jsonl, ContainsExpression{Field: "msg", Value: "timeout"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.Evaluate(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
 }
 
 func TestIsAndIsNot(t *testing.T) {
@@ -91,14 +169,116 @@ func TestLogicalExpressions(t *testing.T) {
 	}
 }
 
+func TestImpliesExpression(t *testing.T) {
+	rule := Query{Expression: &ImpliesExpression{
+		If:   Query{Expression: &IsExpression{Field: "Name", Value: "bob"}},
+		Then: Query{Expression: &GreaterThanExpression{Field: "Age", Value: 40}},
+	}}
+	tests := []struct {
+		u    *testUser
+		want bool
+	}{
+		{&testUser{Name: "alice", Age: 20}, true},
+		{&testUser{Name: "bob", Age: 41}, true},
+		{&testUser{Name: "bob", Age: 20}, false},
+	}
+	for _, tt := range tests {
+		if v, err := rule.Evaluate(tt.u); err != nil || v != tt.want {
+			t.Errorf("%+v: got %v %v, want %v", tt.u, v, err, tt.want)
+		}
+	}
+	data, err := json.Marshal(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := back.Evaluate(&testUser{Name: "bob", Age: 20}); err != nil || v {
+		t.Errorf("round trip %s: got %v %v", data, v, err)
+	}
+	failing := ImpliesExpression{
+		If:   Query{Expression: &IsExpression{Field: "Name", Value: "alice"}},
+		Then: Query{Expression: failingExpression{}},
+	}
+	if v, err := failing.Evaluate(&testUser{Name: "bob"}); err != nil || !v {
+		t.Errorf("Then should not run when If fails to match: %v %v", v, err)
+	}
+}
+
+func TestXorExpression(t *testing.T) {
+	u := &testUser{Name: "bob", Age: 41}
+	isBob := Query{Expression: &IsExpression{Field: "Name", Value: "bob"}}
+	isOld := Query{Expression: &GreaterThanExpression{Field: "Age", Value: 40}}
+	isAlice := Query{Expression: &IsExpression{Field: "Name", Value: "alice"}}
+	tests := []struct {
+		name string
+		qs   []Query
+		want bool
+	}{
+		{"none", []Query{isAlice}, false},
+		{"one", []Query{isAlice, isBob}, true},
+		{"two", []Query{isBob, isOld}, false},
+		{"two of three", []Query{isBob, isAlice, isOld}, false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if v, err := (XorExpression{Expressions: tt.qs}).Evaluate(u); err != nil || v != tt.want {
+			t.Errorf("%s: got %v %v, want %v", tt.name, v, err, tt.want)
+		}
+	}
+	q := Query{Expression: &XorExpression{Expressions: []Query{isAlice, isBob}}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := back.Expression.(*XorExpression); !ok {
+		t.Fatalf("unexpected round trip %s", data)
+	}
+	if v, err := back.Evaluate(u); err != nil || !v {
+		t.Errorf("round trip: got %v %v", v, err)
+	}
+}
+
 func TestNonPointerInput(t *testing.T) {
 	u := testUser{Tags: []string{"a"}, Name: "bob"}
-	if v, err := (ContainsExpression{Field: "Tags", Value: "a"}).Evaluate(u); err != nil || v {
-		t.Errorf("expected false for non-pointer input: %v %v", v, err)
+	if v, err := (ContainsExpression{Field: "Tags", Value: "a"}).Evaluate(u); err != nil || !v {
+		t.Errorf("expected true for non-pointer input: %v %v", v, err)
 	}
-	if v, err := (IsExpression{Field: "Name", Value: "bob"}).Evaluate(u); err != nil || v {
-		t.Errorf("expected false for non-pointer input: %v %v", v, err)
+	if v, err := (IsExpression{Field: "Name", Value: "bob"}).Evaluate(u); err != nil || !v {
+		t.Errorf("expected true for non-pointer input: %v %v", v, err)
 	}
+	q := Query{Expression: &IsExpression{Field: "Name", Value: "bob"}}
+	if v, err := q.Evaluate(u); err != nil || !v {
+		t.Errorf("expected query to match non-pointer input: %v %v", v, err)
+	}
+	if v, err := q.Evaluate(u, &Context{RequirePointer: true}); err != nil || v {
+		t.Errorf("expected false for non-pointer input with RequirePointer: %v %v", v, err)
+	}
+	if v, err := q.Evaluate(&u, &Context{RequirePointer: true}); err != nil || !v {
+		t.Errorf("expected pointer input to match with RequirePointer: %v %v", v, err)
+	}
+}
+
+func TestNonPointerGetter(t *testing.T) {
+	r := ptrGetter{values: map[string]interface{}{"Name": "bob"}}
+	q := Query{Expression: &IsExpression{Field: "Name", Value: "bob"}}
+	if v, err := q.Evaluate(r); err != nil || !v {
+		t.Errorf("expected pointer receiver Get to be used: %v %v", v, err)
+	}
+}
+
+type ptrGetter struct {
+	values map[string]interface{}
+}
+
+func (g *ptrGetter) Get(name string) (interface{}, error) {
+	return g.values[name], nil
 }
 
 func TestQueryUnmarshalAndEvaluate(t *testing.T) {
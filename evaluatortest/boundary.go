@@ -0,0 +1,156 @@
+// Package evaluatortest provides helpers for testing queries.
+package evaluatortest
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Case is a single generated boundary test case.
+type Case struct {
+	// Name identifies the comparison and position, such as "Age gt 18/below".
+	Name string
+	// Field and Value are the input the case exercises.
+	Field string
+	Value interface{}
+	// Want is whether the comparison the case was derived from holds for
+	// Value on its own. Other parts of the query are not considered.
+	Want bool
+}
+
+// Input returns a record holding only the case field, suitable for passing
+// to Evaluate.
+func (c Case) Input() map[string]interface{} {
+	return map[string]interface{}{c.Field: c.Value}
+}
+
+// BoundaryCases walks q and, for every comparison of a field against a
+// literal, generates cases with the field at, just below and just above the
+// literal. Integers step by one, floats by the nearest representable value,
+// times by a nanosecond and money by a cent. Values with no natural ordering,
+// such as strings, only produce the "at" case.
+func BoundaryCases(q evaluator.Query) []Case {
+	var cases []Case
+	walk(q.Expression, &cases)
+	return cases
+}
+
+func walk(e evaluator.Expression, cases *[]Case) {
+	switch ex := e.(type) {
+	case *evaluator.AndExpression:
+		for _, sub := range ex.Expressions {
+			walk(sub.Expression, cases)
+		}
+	case *evaluator.OrExpression:
+		for _, sub := range ex.Expressions {
+			walk(sub.Expression, cases)
+		}
+	case *evaluator.XorExpression:
+		for _, sub := range ex.Expressions {
+			walk(sub.Expression, cases)
+		}
+	case *evaluator.ImpliesExpression:
+		walk(ex.If.Expression, cases)
+		walk(ex.Then.Expression, cases)
+	case *evaluator.CaseExpression:
+		for _, c := range ex.Cases {
+			walk(c.When.Expression, cases)
+			walk(c.Then.Expression, cases)
+		}
+		walk(ex.Else.Expression, cases)
+	case *evaluator.NotExpression:
+		walk(ex.Expression.Expression, cases)
+	case *evaluator.IsExpression:
+		add(cases, ex, ex.Field, "is", ex.Value)
+	case *evaluator.IsNotExpression:
+		add(cases, ex, ex.Field, "is not", ex.Value)
+	case *evaluator.GreaterThanExpression:
+		add(cases, ex, ex.Field, "gt", ex.Value)
+	case *evaluator.GreaterThanOrEqualExpression:
+		add(cases, ex, ex.Field, "gte", ex.Value)
+	case *evaluator.LessThanExpression:
+		add(cases, ex, ex.Field, "lt", ex.Value)
+	case *evaluator.LessThanOrEqualExpression:
+		add(cases, ex, ex.Field, "lte", ex.Value)
+	case *evaluator.ComparisonExpression:
+		if f, ok := ex.LHS.(evaluator.Field); ok {
+			if c, ok := ex.RHS.(evaluator.Constant); ok {
+				add(cases, ex, f.Name, ex.Operation, c.Value)
+			}
+		} else if f, ok := ex.RHS.(evaluator.Field); ok {
+			if c, ok := ex.LHS.(evaluator.Constant); ok {
+				add(cases, ex, f.Name, ex.Operation, c.Value)
+			}
+		}
+	}
+}
+
+type point struct {
+	name  string
+	value interface{}
+}
+
+func add(cases *[]Case, e evaluator.Expression, field, op string, value interface{}) {
+	prefix := fmt.Sprintf("%s %s %v", field, op, value)
+	for _, p := range append([]point{{"at", value}}, neighbours(value)...) {
+		c := Case{Name: prefix + "/" + p.name, Field: field, Value: p.value}
+		c.Want, _ = e.Evaluate(c.Input())
+		*cases = append(*cases, c)
+	}
+}
+
+// neighbours returns the points just below and just above v. A side is left
+// out when v is already at the limit of its type, rather than wrapping round
+// to the other end.
+func neighbours(v interface{}) []point {
+	switch x := v.(type) {
+	case time.Time:
+		return []point{{"below", x.Add(-time.Nanosecond)}, {"above", x.Add(time.Nanosecond)}}
+	case evaluator.MoneyValue:
+		return []point{
+			{"below", evaluator.MoneyValue{Amount: x.Amount - 0.01, Currency: x.Currency}},
+			{"above", evaluator.MoneyValue{Amount: x.Amount + 0.01, Currency: x.Currency}},
+		}
+	}
+	var points []point
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := rv.Int()
+		if n > math.MinInt64 && !rv.OverflowInt(n-1) {
+			points = append(points, point{"below", reflect.ValueOf(n - 1).Convert(rv.Type()).Interface()})
+		}
+		if n < math.MaxInt64 && !rv.OverflowInt(n+1) {
+			points = append(points, point{"above", reflect.ValueOf(n + 1).Convert(rv.Type()).Interface()})
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := rv.Uint()
+		if n > 0 {
+			points = append(points, point{"below", reflect.ValueOf(n - 1).Convert(rv.Type()).Interface()})
+		}
+		if n < math.MaxUint64 && !rv.OverflowUint(n+1) {
+			points = append(points, point{"above", reflect.ValueOf(n + 1).Convert(rv.Type()).Interface()})
+		}
+	case reflect.Float32:
+		f := float32(rv.Float())
+		if b := math.Nextafter32(f, float32(math.Inf(-1))); b != f {
+			points = append(points, point{"below", b})
+		}
+		if a := math.Nextafter32(f, float32(math.Inf(1))); a != f {
+			points = append(points, point{"above", a})
+		}
+	case reflect.Float64:
+		f := rv.Float()
+		if b := math.Nextafter(f, math.Inf(-1)); b != f {
+			points = append(points, point{"below", b})
+		}
+		if a := math.Nextafter(f, math.Inf(1)); a != f {
+			points = append(points, point{"above", a})
+		}
+	}
+	return points
+}
@@ -0,0 +1,100 @@
+package evaluatortest
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+func TestBoundaryCases(t *testing.T) {
+	q, err := simple.Parse(`age >= 18 and (score < 2.5 or name is "bob")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := BoundaryCases(q)
+	want := map[string]bool{
+		"age gte 18/at":      true,
+		"age gte 18/below":   false,
+		"age gte 18/above":   true,
+		"score lt 2.5/at":    false,
+		"score lt 2.5/below": true,
+		"score lt 2.5/above": false,
+		"name is bob/at":     true,
+	}
+	if len(cases) != len(want) {
+		t.Fatalf("expected %d cases, got %d: %+v", len(want), len(cases), cases)
+	}
+	for _, c := range cases {
+		w, ok := want[c.Name]
+		if !ok {
+			t.Errorf("unexpected case %q", c.Name)
+			continue
+		}
+		if c.Want != w {
+			t.Errorf("%s: Want = %v, expected %v", c.Name, c.Want, w)
+		}
+	}
+	if cases[1].Value != 17 || cases[2].Value != 19 {
+		t.Errorf("unexpected integer neighbours %v, %v", cases[1].Value, cases[2].Value)
+	}
+}
+
+func TestNeighboursAtTypeLimits(t *testing.T) {
+	for _, tc := range []struct {
+		v    interface{}
+		want string
+	}{
+		{int8(math.MaxInt8), "[{below 126}]"},
+		{int8(math.MinInt8), "[{above -127}]"},
+		{int64(math.MaxInt64), "[{below 9223372036854775806}]"},
+		{int64(math.MinInt64), "[{above -9223372036854775807}]"},
+		{uint8(0), "[{above 1}]"},
+		{uint8(math.MaxUint8), "[{below 254}]"},
+		{uint64(math.MaxUint64), "[{below 18446744073709551614}]"},
+		{math.Inf(1), "[{below 1.7976931348623157e+308}]"},
+		{5, "[{below 4} {above 6}]"},
+	} {
+		if got := fmt.Sprint(neighbours(tc.v)); got != tc.want {
+			t.Errorf("%T %v: got %s, want %s", tc.v, tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestBoundaryCasesTerms(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := evaluator.Query{Expression: &evaluator.ComparisonExpression{
+		LHS:       evaluator.Constant{Value: at},
+		Operation: "lt",
+		RHS:       evaluator.Field{Name: "when"},
+	}}
+	cases := BoundaryCases(q)
+	if len(cases) != 3 {
+		t.Fatalf("expected 3 cases, got %+v", cases)
+	}
+	if cases[0].Want || cases[1].Want || !cases[2].Want {
+		t.Errorf("unexpected expectations %+v", cases)
+	}
+	if !cases[2].Value.(time.Time).Equal(at.Add(time.Nanosecond)) {
+		t.Errorf("unexpected above value %v", cases[2].Value)
+	}
+}
+
+func TestBoundaryCasesAgainstQuery(t *testing.T) {
+	q, err := simple.Parse(`price > 100`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range BoundaryCases(q) {
+		got, err := q.Evaluate(c.Input())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.Want {
+			t.Errorf("%s: got %v, want %v", c.Name, got, c.Want)
+		}
+	}
+}
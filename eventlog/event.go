@@ -0,0 +1,149 @@
+// Package eventlog reads Windows event log records so queries can filter
+// them. Records are decoded from the XML that wevtutil writes, which this
+// file handles on every platform; running wevtutil needs Windows.
+package eventlog
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Record is an event keyed by field name:
+//
+//   - Provider, Channel and Computer: strings
+//   - EventID, Level, RecordID, Task and Opcode: numbers
+//   - LevelName, TaskName and OpcodeName: the rendered names, such as Error
+//   - Message: the rendered message
+//   - User: the security identifier of the user, such as S-1-5-18
+//   - Time: the creation time in RFC 3339 form
+//   - Keywords: a list of rendered keyword names
+//   - Data: the named event data values, or a list when they are unnamed
+//
+// Fields the event does not carry are left out.
+type Record map[string]interface{}
+
+// event mirrors the parts of the event XML schema that Record exposes.
+type event struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     string `xml:"EventID"`
+		Level       string `xml:"Level"`
+		Task        string `xml:"Task"`
+		Opcode      string `xml:"Opcode"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID string `xml:"EventRecordID"`
+		Channel       string `xml:"Channel"`
+		Computer      string `xml:"Computer"`
+		Security      struct {
+			UserID string `xml:"UserID,attr"`
+		} `xml:"Security"`
+	} `xml:"System"`
+	EventData struct {
+		Data []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"EventData"`
+	RenderingInfo struct {
+		Message  string   `xml:"Message"`
+		Level    string   `xml:"Level"`
+		Task     string   `xml:"Task"`
+		Opcode   string   `xml:"Opcode"`
+		Keywords []string `xml:"Keywords>Keyword"`
+	} `xml:"RenderingInfo"`
+}
+
+// Decode reads a sequence of Event elements, as written by wevtutil qe with
+// /f:RenderedXml or /f:xml, calling fn with each in turn. An error from fn
+// stops decoding and is returned.
+func Decode(r io.Reader, fn func(Record) error) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("eventlog: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "Event" {
+			if err := dec.Skip(); err != nil {
+				return fmt.Errorf("eventlog: %w", err)
+			}
+			continue
+		}
+		var e event
+		if err := dec.DecodeElement(&e, &start); err != nil {
+			return fmt.Errorf("eventlog: %w", err)
+		}
+		if err := fn(e.record()); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *event) record() Record {
+	s, ri := &e.System, &e.RenderingInfo
+	rec := Record{}
+	setString(rec, "Provider", s.Provider.Name)
+	setString(rec, "Channel", s.Channel)
+	setString(rec, "Computer", s.Computer)
+	setNumber(rec, "EventID", s.EventID)
+	setNumber(rec, "Level", s.Level)
+	setNumber(rec, "RecordID", s.EventRecordID)
+	setNumber(rec, "Task", s.Task)
+	setNumber(rec, "Opcode", s.Opcode)
+	setString(rec, "LevelName", ri.Level)
+	setString(rec, "TaskName", ri.Task)
+	setString(rec, "OpcodeName", ri.Opcode)
+	setString(rec, "Message", ri.Message)
+	setString(rec, "User", s.Security.UserID)
+	setString(rec, "Time", s.TimeCreated.SystemTime)
+	if len(ri.Keywords) > 0 {
+		kw := make([]interface{}, len(ri.Keywords))
+		for i, k := range ri.Keywords {
+			kw[i] = k
+		}
+		rec["Keywords"] = kw
+	}
+	if d := e.EventData.Data; len(d) > 0 {
+		named := make(map[string]interface{}, len(d))
+		list := make([]interface{}, len(d))
+		for i, v := range d {
+			if v.Name != "" {
+				named[v.Name] = v.Value
+			}
+			list[i] = v.Value
+		}
+		if len(named) == len(d) {
+			rec["Data"] = named
+		} else {
+			rec["Data"] = list
+		}
+	}
+	return rec
+}
+
+func setString(rec Record, key, v string) {
+	if v = strings.TrimSpace(v); v != "" {
+		rec[key] = v
+	}
+}
+
+func setNumber(rec Record, key, v string) {
+	if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+		rec[key] = n
+	}
+}
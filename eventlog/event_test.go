@@ -0,0 +1,63 @@
+package eventlog
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const events = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Microsoft-Windows-Security-Auditing' Guid='{54849625-5478-4994-a5ba-3e3b0328c30d}'/><EventID>4625</EventID><Version>0</Version><Level>0</Level><Task>12544</Task><Opcode>0</Opcode><Keywords>0x8010000000000000</Keywords><TimeCreated SystemTime='2024-03-01T10:15:30.1234567Z'/><EventRecordID>81234</EventRecordID><Channel>Security</Channel><Computer>dc01.example.com</Computer><Security/></System><EventData><Data Name='TargetUserName'>alice</Data><Data Name='IpAddress'>10.0.0.7</Data></EventData><RenderingInfo Culture='en-US'><Message>An account failed to log on.
+
+Subject: ...</Message><Level>Information</Level><Task>Logon</Task><Opcode>Info</Opcode><Channel>Security</Channel><Provider>Microsoft Windows security auditing.</Provider><Keywords><Keyword>Audit Failure</Keyword></Keywords></RenderingInfo></Event>
+<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Service Control Manager'/><EventID Qualifiers='16384'>7036</EventID><Level>2</Level><Security UserID='S-1-5-18'/></System><EventData><Data>Print Spooler</Data><Data>stopped</Data></EventData></Event>
+`
+
+func TestDecode(t *testing.T) {
+	var got []Record
+	if err := Decode(strings.NewReader(events), func(r Record) error {
+		got = append(got, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{
+			"Provider":   "Microsoft-Windows-Security-Auditing",
+			"Channel":    "Security",
+			"Computer":   "dc01.example.com",
+			"EventID":    int64(4625),
+			"Level":      int64(0),
+			"RecordID":   int64(81234),
+			"Task":       int64(12544),
+			"Opcode":     int64(0),
+			"LevelName":  "Information",
+			"TaskName":   "Logon",
+			"OpcodeName": "Info",
+			"Message":    "An account failed to log on.\n\nSubject: ...",
+			"Time":       "2024-03-01T10:15:30.1234567Z",
+			"Keywords":   []interface{}{"Audit Failure"},
+			"Data":       map[string]interface{}{"TargetUserName": "alice", "IpAddress": "10.0.0.7"},
+		},
+		{
+			"Provider": "Service Control Manager",
+			"EventID":  int64(7036),
+			"Level":    int64(2),
+			"User":     "S-1-5-18",
+			"Data":     []interface{}{"Print Spooler", "stopped"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	stop := errors.New("stop")
+	if err := Decode(strings.NewReader(events), func(Record) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+	if err := Decode(strings.NewReader("<Event><System>"), func(Record) error { return nil }); err == nil {
+		t.Error("expected an error for truncated XML")
+	}
+}
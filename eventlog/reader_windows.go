@@ -0,0 +1,84 @@
+//go:build windows
+
+package eventlog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Reader queries an event log with wevtutil.
+type Reader struct {
+	// Log is the log to read, such as Security, System or
+	// Microsoft-Windows-Sysmon/Operational.
+	Log string
+	// Query is an XPath filter evaluated by the event log service before
+	// the records reach the query, for example
+	// *[System[TimeCreated[timediff(@SystemTime) <= 86400000]]].
+	Query string
+	// Count, when positive, limits the number of records read.
+	Count int
+	// Newest reads the most recent records first.
+	Newest bool
+	// Command is the wevtutil binary. It defaults to "wevtutil".
+	Command string
+}
+
+// Run calls fn with each record in turn. An error from fn stops wevtutil
+// and is returned.
+func (r *Reader) Run(ctx context.Context, fn func(Record) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, r.command(), r.args()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	runErr := Decode(out, fn)
+	if runErr != nil {
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case runErr != nil:
+		return runErr
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case waitErr != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("wevtutil: %w: %s", waitErr, msg)
+		}
+		return fmt.Errorf("wevtutil: %w", waitErr)
+	}
+	return nil
+}
+
+func (r *Reader) command() string {
+	if r.Command == "" {
+		return "wevtutil"
+	}
+	return r.Command
+}
+
+func (r *Reader) args() []string {
+	args := []string{"qe", r.Log, "/f:RenderedXml"}
+	if r.Query != "" {
+		args = append(args, "/q:"+r.Query)
+	}
+	if r.Count > 0 {
+		args = append(args, "/c:"+strconv.Itoa(r.Count))
+	}
+	if r.Newest {
+		args = append(args, "/rd:true")
+	}
+	return args
+}
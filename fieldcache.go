@@ -0,0 +1,99 @@
+package evaluator
+
+import (
+	"reflect"
+)
+
+// fieldCache wraps a record so that each field is resolved at most once.
+// getField recognizes it and answers from the cache, so expressions see
+// exactly the values they would have seen on the record itself.
+type fieldCache struct {
+	v      reflect.Value
+	fields map[string]cachedField
+	// rec is the value wrapped, returned by unwrapRecord.
+	rec interface{}
+}
+
+type cachedField struct {
+	v     reflect.Value
+	found bool
+}
+
+var fieldCacheType = reflect.TypeOf(fieldCache{})
+
+// withFieldCache wraps i in a fieldCache unless it already is one or is not
+// a record.
+func withFieldCache(i interface{}) interface{} {
+	if _, ok := i.(*fieldCache); ok {
+		return i
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return i
+	}
+	return &fieldCache{v: v, fields: make(map[string]cachedField, 4), rec: i}
+}
+
+func (c *fieldCache) lookup(name string) (reflect.Value, bool) {
+	if cf, ok := c.fields[name]; ok {
+		return cf.v, cf.found
+	}
+	f, found := getField(c.v, name)
+	c.fields[name] = cachedField{v: f, found: found}
+	return f, found
+}
+
+// unwrapRecord returns the record wrapped by fieldCache or foldedFields, for
+// code that walks the record itself rather than looking fields up through
+// getField.
+func unwrapRecord(i interface{}) interface{} {
+	for {
+		switch r := i.(type) {
+		case *fieldCache:
+			i = r.rec
+		case fieldCache:
+			i = r.rec
+		case *foldedFields:
+			i = r.rec
+		case foldedFields:
+			i = r.rec
+		default:
+			return i
+		}
+	}
+}
+
+// pkgPath is the import path of this package.
+var pkgPath = queryType.PkgPath()
+
+// recordFor returns the record to pass to x, an Expression or Term. Types
+// defined in this package understand fieldCache and foldedFields; any other
+// type is given the record it would have received without them, so custom
+// Expressions and Terms may type assert their input.
+func recordFor(x, i interface{}) interface{} {
+	switch i.(type) {
+	case *fieldCache, *foldedFields:
+	default:
+		return i
+	}
+	t := reflect.TypeOf(x)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.PkgPath() == pkgPath {
+		return i
+	}
+	return unwrapRecord(i)
+}
+
+// evalTerm evaluates t against the record i.
+func evalTerm(t Term, i interface{}, opts ...any) (interface{}, error) {
+	return t.Evaluate(recordFor(t, i), opts...)
+}
+
+// cacheFields reports whether opts carry a Context asking for field caching.
+// It avoids GetContext so evaluations without a Context do not allocate one.
+func cacheFields(opts []any) bool {
+	ctx := findContext(opts)
+	return ctx != nil && ctx.CacheFields
+}
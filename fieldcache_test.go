@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"testing"
+)
+
+func TestCacheFields(t *testing.T) {
+	q := Query{Expression: &OrExpression{Expressions: []Query{
+		{Expression: &IsExpression{Field: "Status", Value: "a"}},
+		{Expression: &IsExpression{Field: "Status", Value: "b"}},
+		{Expression: &AndExpression{Expressions: []Query{
+			{Expression: &StartsWithExpression{Field: "Status", Value: "c"}},
+			{Expression: &ComparisonExpression{LHS: Field{Name: "Status"}, Operation: "neq", RHS: Constant{Value: "x"}}},
+		}}},
+	}}}
+	for _, cache := range []bool{false, true} {
+		g := countingGetter{values: map[string]interface{}{"Status": "cd"}, calls: map[string]int{}}
+		ok, err := q.Evaluate(&g, &Context{CacheFields: cache})
+		if err != nil || !ok {
+			t.Fatalf("cache=%v: got %v, %v", cache, ok, err)
+		}
+		want := 4
+		if cache {
+			want = 1
+		}
+		if g.calls["Status"] != want {
+			t.Errorf("cache=%v: Status resolved %d times, want %d", cache, g.calls["Status"], want)
+		}
+	}
+}
+
+func TestCacheFieldsPreservesValues(t *testing.T) {
+	type rec struct {
+		Age   int
+		Money MoneyValue
+	}
+	r := &rec{Age: 30, Money: MoneyValue{Amount: 5, Currency: "USD"}}
+	ctx := &Context{CacheFields: true}
+	for _, e := range []Expression{
+		&GreaterThanExpression{Field: "Age", Value: 18},
+		&IsExpression{Field: "Age", Value: 30},
+		&LessThanExpression{Field: "Money", Value: MoneyValue{Amount: 6, Currency: "USD"}},
+	} {
+		q := Query{Expression: &AndExpression{Expressions: []Query{{Expression: e}, {Expression: e}}}}
+		plain, err1 := q.Evaluate(r)
+		cached, err2 := q.Evaluate(r, ctx)
+		if plain != cached || (err1 == nil) != (err2 == nil) {
+			t.Errorf("%T: plain %v/%v, cached %v/%v", e, plain, err1, cached, err2)
+		}
+	}
+}
+
+func BenchmarkCacheFields(b *testing.B) {
+	var qs []Query
+	for n := 0; n < 20; n++ {
+		qs = append(qs, Query{Expression: &GreaterThanExpression{Field: "Score", Value: n}})
+	}
+	q := Query{Expression: &AndExpression{Expressions: qs}}
+	rec := map[string]interface{}{"Score": 70}
+	ctx := &Context{CacheFields: true}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = q.Evaluate(rec, ctx)
+	}
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import "fmt"
+
+// FieldCompareExpression compares FieldA with FieldB of the same record
+// using Op, one of eq, neq, gt, gte, lt or lte, as in EndTime > StartTime.
+// Numbers, strings, times and money compare as they do against a literal
+// Value. The expression does not match when either field is missing or the
+// two cannot be compared.
+type FieldCompareExpression struct {
+	FieldA string
+	Op     string
+	FieldB string
+}
+
+func (e FieldCompareExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	if _, known := orderResult(0, e.Op); !known {
+		return false, fmt.Errorf("compare %s with %s: unknown operation %q", e.FieldA, e.FieldB, e.Op)
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	a, ok := getField(v, e.FieldA)
+	if !ok {
+		return false, nil
+	}
+	b, ok := getField(v, e.FieldB)
+	if !ok || !b.CanInterface() {
+		return false, nil
+	}
+	c, ok, err := rangeCompare(a, b.Interface(), opts)
+	if err != nil || !ok {
+		return false, err
+	}
+	res, _ := orderResult(c, e.Op)
+	return res, nil
+}
+
+// orderResult applies op to the result c of a three way comparison. known is
+// false for an unknown op.
+func orderResult(c int, op string) (ok, known bool) {
+	switch op {
+	case "eq":
+		return c == 0, true
+	case "neq":
+		return c != 0, true
+	case "gt":
+		return c > 0, true
+	case "gte":
+		return c >= 0, true
+	case "lt":
+		return c < 0, true
+	case "lte":
+		return c <= 0, true
+	}
+	return false, false
+}
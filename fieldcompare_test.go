@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFieldCompareExpression(t *testing.T) {
+	type booking struct {
+		StartTime time.Time
+		EndTime   time.Time
+		Min, Max  int
+		Limit     float64
+		Name      string
+		Alias     string
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	b := &booking{StartTime: start, EndTime: start.Add(time.Hour), Min: 2, Max: 5, Limit: 5, Name: "a", Alias: "a"}
+	tests := []struct {
+		e    FieldCompareExpression
+		want bool
+	}{
+		{FieldCompareExpression{FieldA: "EndTime", Op: "gt", FieldB: "StartTime"}, true},
+		{FieldCompareExpression{FieldA: "StartTime", Op: "gte", FieldB: "EndTime"}, false},
+		{FieldCompareExpression{FieldA: "Min", Op: "lt", FieldB: "Max"}, true},
+		{FieldCompareExpression{FieldA: "Max", Op: "eq", FieldB: "Limit"}, true},
+		{FieldCompareExpression{FieldA: "Name", Op: "eq", FieldB: "Alias"}, true},
+		{FieldCompareExpression{FieldA: "Name", Op: "neq", FieldB: "Alias"}, false},
+		{FieldCompareExpression{FieldA: "StartTime", Op: "lt", FieldB: "Min"}, false},
+		{FieldCompareExpression{FieldA: "Missing", Op: "eq", FieldB: "Name"}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(b)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.e, err)
+		}
+		if got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.e, got, tt.want)
+		}
+	}
+	if _, err := (FieldCompareExpression{FieldA: "Min", Op: "near", FieldB: "Max"}).Evaluate(b); err == nil {
+		t.Errorf("expected error for unknown op")
+	}
+}
+
+func TestFieldCompareExpressionJSON(t *testing.T) {
+	q := Query{Expression: &FieldCompareExpression{FieldA: "EndTime", Op: "gt", FieldB: "StartTime"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"FieldCompare","Expression":{"FieldA":"EndTime","Op":"gt","FieldB":"StartTime"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	rec := map[string]interface{}{"StartTime": "2024-01-01T09:00:00Z", "EndTime": "2024-01-01T08:00:00Z"}
+	if ok, err := back.Evaluate(rec); err != nil || ok {
+		t.Errorf("expected no match: %v %v", ok, err)
+	}
+}
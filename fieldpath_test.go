@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type pathAddress struct {
+	City string
+	Zip  *string
+}
+
+type pathUser struct {
+	Name    string
+	Address *pathAddress
+	Home    pathAddress
+	Tags    []string
+	Extra   map[string]interface{}
+	Props   Getter
+}
+
+type mapGetter map[string]interface{}
+
+func (m mapGetter) Get(name string) (interface{}, error) {
+	if v, ok := m[name]; ok {
+		return v, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestDottedFieldPaths(t *testing.T) {
+	u := &pathUser{
+		Address: &pathAddress{City: "Paris"},
+		Home:    pathAddress{City: "Lyon"},
+		Tags:    []string{"a", "b"},
+		Extra:   map[string]interface{}{"plan": map[string]interface{}{"tier": "gold"}},
+		Props:   mapGetter{"team": "core"},
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"user": {"address": {"city": "Oslo"}, "roles": [{"name": "admin"}]}, "a.b": 1}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   interface{}
+		e    Expression
+		want bool
+	}{
+		{"pointer struct", u, IsExpression{Field: "Address.City", Value: "Paris"}, true},
+		{"value struct", u, IsExpression{Field: "Home.City", Value: "Lyon"}, true},
+		{"slice index", u, IsExpression{Field: "Tags.1", Value: "b"}, true},
+		{"slice out of range", u, IsEmptyExpression{Field: "Tags.5"}, true},
+		{"struct map", u, IsExpression{Field: "Extra.plan.tier", Value: "gold"}, true},
+		{"getter", u, IsExpression{Field: "Props.team", Value: "core"}, true},
+		{"nil pointer", u, IsEmptyExpression{Field: "Address.Zip"}, true},
+		{"missing", u, IsExpression{Field: "Address.Street", Value: ""}, false},
+		{"json", doc, IsExpression{Field: "user.address.city", Value: "Oslo"}, true},
+		{"json array", doc, IsExpression{Field: "user.roles.0.name", Value: "admin"}, true},
+		{"bracket index", doc, IsExpression{Field: "user.roles[0].name", Value: "admin"}, true},
+		{"bracket slice", u, IsExpression{Field: "Tags[1]", Value: "b"}, true},
+		{"bracket out of range", u, IsExpression{Field: "Tags[2]", Value: "b"}, false},
+		{"whole name first", doc, IsExpression{Field: "a.b", Value: 1}, true},
+		{"json missing", doc, IsExpression{Field: "user.address.zip", Value: nil}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	var nested pathUser
+	if ok, _ := (IsExpression{Field: "Address.City", Value: ""}).Evaluate(&nested); ok {
+		t.Errorf("nil intermediate pointer should not match")
+	}
+}
@@ -0,0 +1,73 @@
+package evaluator
+
+// FoldCase returns a copy of q in which Is, IsNot, the Contains family and
+// the ordering comparisons compare strings case-insensitively, as if each had
+// Fold set. The original query is left untouched.
+func FoldCase(q Query) Query {
+	if q.Expression == nil {
+		return q
+	}
+	q.Expression = foldExpr(q.Expression)
+	return q
+}
+
+func foldQueries(qs []Query) []Query {
+	out := make([]Query, len(qs))
+	for i, q := range qs {
+		out[i] = FoldCase(q)
+	}
+	return out
+}
+
+func foldExpr(e Expression) Expression {
+	switch ex := e.(type) {
+	case *IsExpression:
+		return &IsExpression{Field: ex.Field, Value: ex.Value, Fold: true}
+	case *IsNotExpression:
+		return &IsNotExpression{Field: ex.Field, Value: ex.Value, Fold: true}
+	case *ContainsExpression:
+		return &ContainsExpression{Field: ex.Field, Value: ex.Value, Fold: true}
+	case *ContainsAllExpression:
+		return &ContainsAllExpression{Field: ex.Field, Values: ex.Values, Fold: true}
+	case *ContainsAnyExpression:
+		return &ContainsAnyExpression{Field: ex.Field, Values: ex.Values, Fold: true}
+	case *GreaterThanExpression:
+		return &GreaterThanExpression{Field: ex.Field, Value: ex.Value, Fold: true}
+	case *GreaterThanOrEqualExpression:
+		return &GreaterThanOrEqualExpression{Field: ex.Field, Value: ex.Value, Fold: true}
+	case *LessThanExpression:
+		return &LessThanExpression{Field: ex.Field, Value: ex.Value, Fold: true}
+	case *LessThanOrEqualExpression:
+		return &LessThanOrEqualExpression{Field: ex.Field, Value: ex.Value, Fold: true}
+	case *AndExpression:
+		return &AndExpression{Expressions: foldQueries(ex.Expressions)}
+	case *OrExpression:
+		return &OrExpression{Expressions: foldQueries(ex.Expressions)}
+	case *XorExpression:
+		return &XorExpression{Expressions: foldQueries(ex.Expressions)}
+	case *ImpliesExpression:
+		return &ImpliesExpression{If: FoldCase(ex.If), Then: FoldCase(ex.Then)}
+	case *CaseExpression:
+		out := &CaseExpression{Cases: make([]CaseBranch, len(ex.Cases)), Else: FoldCase(ex.Else)}
+		for i, c := range ex.Cases {
+			out.Cases[i] = CaseBranch{When: FoldCase(c.When), Then: FoldCase(c.Then)}
+		}
+		return out
+	case *NotExpression:
+		return &NotExpression{Expression: FoldCase(ex.Expression)}
+	case *AnyExpression:
+		return &AnyExpression{Field: ex.Field, Query: FoldCase(ex.Query)}
+	case *AllExpression:
+		return &AllExpression{Field: ex.Field, Query: FoldCase(ex.Query)}
+	case *CountExpression:
+		return &CountExpression{Field: ex.Field, Query: FoldCase(ex.Query), Op: ex.Op, Value: ex.Value}
+	case *ScoreQuery:
+		out := &ScoreQuery{Expressions: make([]WeightedExpression, len(ex.Expressions)), Threshold: ex.Threshold}
+		for i, w := range ex.Expressions {
+			out.Expressions[i] = WeightedExpression{Query: FoldCase(w.Query), Weight: w.Weight}
+		}
+		return out
+	default:
+		return e
+	}
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFoldOption(t *testing.T) {
+	rec := map[string]interface{}{"City": "New York", "Tags": []interface{}{"VIP", "new"}}
+	tests := []struct {
+		name string
+		e    Expression
+		want bool
+	}{
+		{"is", &IsExpression{Field: "City", Value: "NEW YORK", Fold: true}, true},
+		{"is exact", &IsExpression{Field: "City", Value: "NEW YORK"}, false},
+		{"is not", &IsNotExpression{Field: "City", Value: "new york", Fold: true}, false},
+		{"contains substring", &ContainsExpression{Field: "City", Value: "YORK", Fold: true}, true},
+		{"contains element", &ContainsExpression{Field: "Tags", Value: "vip", Fold: true}, true},
+		{"contains missing element", &ContainsExpression{Field: "Tags", Value: "old", Fold: true}, false},
+		{"gt", &GreaterThanExpression{Field: "City", Value: "NEW", Fold: true}, true},
+		{"gt exact", &GreaterThanExpression{Field: "City", Value: "a"}, false},
+		{"gte", &GreaterThanOrEqualExpression{Field: "City", Value: "new york", Fold: true}, true},
+		{"lt", &LessThanExpression{Field: "City", Value: "a", Fold: true}, false},
+		{"lte", &LessThanOrEqualExpression{Field: "City", Value: "NEW YORK", Fold: true}, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(rec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	q := Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &IsExpression{Field: "City", Value: "ny"}},
+		{Expression: &NotExpression{Expression: Query{Expression: &ContainsExpression{Field: "Name", Value: "BOT"}}}},
+	}}}
+	rec := map[string]interface{}{"City": "NY", "Name": "alice"}
+	if ok, _ := q.Evaluate(rec); ok {
+		t.Fatalf("original query should be case sensitive")
+	}
+	folded := FoldCase(q)
+	if ok, _ := folded.Evaluate(rec); !ok {
+		t.Errorf("folded query should match")
+	}
+	if ok, _ := q.Evaluate(rec); ok {
+		t.Errorf("original query modified")
+	}
+	data, err := json.Marshal(folded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := back.Evaluate(rec); !ok {
+		t.Errorf("Fold lost in JSON round trip: %s", data)
+	}
+}
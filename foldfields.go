@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// foldedFields wraps a record so that field names match case-insensitively
+// when no field has the exact name. getField recognizes it, as it does
+// fieldCache.
+type foldedFields struct {
+	v reflect.Value
+	// rec is the value wrapped, returned by unwrapRecord.
+	rec interface{}
+}
+
+var foldedFieldsType = reflect.TypeOf(foldedFields{})
+
+// withFoldedFields wraps i in foldedFields unless it already is one, is
+// already cached, and so wrapped by an enclosing query, or is not a record.
+func withFoldedFields(i interface{}) interface{} {
+	switch i.(type) {
+	case *foldedFields, *fieldCache:
+		return i
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return i
+	}
+	return &foldedFields{v: v, rec: i}
+}
+
+func (r *foldedFields) lookup(name string) (reflect.Value, bool) {
+	if f, ok := getField(r.v, name); ok {
+		return f, true
+	}
+	segs := strings.Split(strings.NewReplacer("[", ".", "]", "").Replace(name), ".")
+	if len(segs) > DefaultMaxDepth {
+		return reflect.Value{}, false
+	}
+	v := r.v
+	for _, seg := range segs {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			if _, ok := resolver(v); ok {
+				break
+			}
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			n, err := strconv.Atoi(seg)
+			if err != nil || n < 0 || n >= v.Len() {
+				return reflect.Value{}, false
+			}
+			v = v.Index(n)
+			continue
+		}
+		f, ok := fieldByName(v, seg)
+		if !ok {
+			if f, ok = fieldByFoldedName(v, seg); !ok {
+				return reflect.Value{}, false
+			}
+		}
+		v = f
+	}
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// fieldByFoldedName finds the field of a map or struct whose name equals name
+// ignoring case. Of several map keys that differ only in case the one that
+// sorts first is used; a struct with several such fields has none.
+func fieldByFoldedName(v reflect.Value, name string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		var key, found reflect.Value
+		for it := v.MapRange(); it.Next(); {
+			k := it.Key()
+			if strings.EqualFold(k.String(), name) && (!key.IsValid() || k.String() < key.String()) {
+				key, found = k, it.Value()
+			}
+		}
+		if !found.IsValid() {
+			return reflect.Value{}, false
+		}
+		if found.Kind() == reflect.Interface && !found.IsNil() {
+			found = found.Elem()
+		}
+		return found, true
+	case reflect.Struct:
+		f := v.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		return f, f.IsValid()
+	}
+	return reflect.Value{}, false
+}
+
+// foldFields reports whether opts carry a Context asking for case-insensitive
+// field names.
+func foldFields(opts []any) bool {
+	ctx := findContext(opts)
+	return ctx != nil && ctx.FoldFields
+}
@@ -0,0 +1,45 @@
+package evaluator
+
+import "testing"
+
+func TestFoldFields(t *testing.T) {
+	rec := map[string]interface{}{
+		"NAME":    "bob",
+		"Address": map[string]interface{}{"City": "Wellington"},
+		"Tags":    []interface{}{map[string]interface{}{"Label": "x"}},
+	}
+	ctx := &Context{FoldFields: true}
+	for _, tt := range []struct {
+		q    Query
+		want bool
+	}{
+		{Query{Expression: &IsExpression{Field: "name", Value: "bob"}}, true},
+		{Query{Expression: &IsExpression{Field: "address.city", Value: "Wellington"}}, true},
+		{Query{Expression: &IsExpression{Field: "tags[0].label", Value: "x"}}, true},
+		{Query{Expression: &AnyExpression{Field: "tags", Query: Query{Expression: &IsExpression{Field: "LABEL", Value: "x"}}}}, true},
+		{Query{Expression: &IsExpression{Field: "nickname", Value: "bob"}}, false},
+	} {
+		got, err := tt.q.Evaluate(rec, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%T %+v: got %v, want %v", tt.q.Expression, tt.q.Expression, got, tt.want)
+		}
+		if got, _ := tt.q.Evaluate(rec, &Context{FoldFields: true, CacheFields: true}); got != tt.want {
+			t.Errorf("%+v with CacheFields: got %v, want %v", tt.q.Expression, got, tt.want)
+		}
+	}
+	q := Query{Expression: &IsExpression{Field: "name", Value: "bob"}}
+	if ok, _ := q.Evaluate(rec); ok {
+		t.Errorf("expected exact field names without FoldFields")
+	}
+	type person struct{ FirstName string }
+	if ok, _ := (&Query{Expression: &IsExpression{Field: "firstname", Value: "Ann"}}).Evaluate(&person{FirstName: "Ann"}, ctx); !ok {
+		t.Errorf("expected a struct field to match")
+	}
+	both := map[string]interface{}{"Name": "first", "name": "exact", "NAME": "last"}
+	if ok, _ := (&Query{Expression: &IsExpression{Field: "nAmE", Value: "last"}}).Evaluate(both, ctx); !ok {
+		t.Errorf("expected the key sorting first to win")
+	}
+}
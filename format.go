@@ -0,0 +1,116 @@
+package evaluator
+
+import (
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// IsFormatExpression succeeds when the string Field is written in Format,
+// one of email, uuid, url, date, datetime, time, ipv4, ipv6 or hostname, or
+// a name in Context.Formats. Fields that are missing or not strings do not
+// match, and an unknown Format is an error.
+type IsFormatExpression struct {
+	Field  string
+	Format string
+}
+
+func (e IsFormatExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	valid, ok := formats[e.Format]
+	if ctx := findContext(opts); ctx != nil && ctx.Formats[e.Format] != nil {
+		valid, ok = ctx.Formats[e.Format], true
+	}
+	if !ok {
+		return false, fmt.Errorf("%s is format: unknown format %q", e.Field, e.Format)
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	for ok && (f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface) && !f.IsNil() {
+		f = f.Elem()
+	}
+	if !ok || f.Kind() != reflect.String {
+		return false, nil
+	}
+	return valid(f.String()), nil
+}
+
+// formats holds the built-in validators of IsFormatExpression.
+var formats = map[string]func(string) bool{
+	// email is a bare address such as a@example.com, without a display name.
+	"email": func(s string) bool {
+		a, err := mail.ParseAddress(s)
+		return err == nil && a.Address == s
+	},
+	// uuid is 8-4-4-4-12 hexadecimal digits, in either case.
+	"uuid": isUUID,
+	// url is an absolute URL with a scheme and a host.
+	"url": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	},
+	// date is an ISO 8601 calendar date, 2006-01-02.
+	"date": timeFormat(time.DateOnly),
+	// datetime is an RFC 3339 timestamp, 2006-01-02T15:04:05Z07:00, with
+	// optional fractional seconds.
+	"datetime": timeFormat(time.RFC3339Nano),
+	// time is a time of day, 15:04:05, with optional fractional seconds.
+	"time":     timeFormat("15:04:05.999999999"),
+	"ipv4":     func(s string) bool { a, err := netip.ParseAddr(s); return err == nil && a.Is4() },
+	"ipv6":     func(s string) bool { a, err := netip.ParseAddr(s); return err == nil && a.Is6() },
+	"hostname": isHostname,
+}
+
+func timeFormat(layout string) func(string) bool {
+	return func(s string) bool {
+		_, err := time.Parse(layout, s)
+		return err == nil
+	}
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isHostname reports whether s is a DNS name of letters, digits and hyphens
+// in dot-separated labels of at most 63 characters that do not start or end
+// with a hyphen.
+func isHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
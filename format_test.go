@@ -0,0 +1,71 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIsFormatExpression(t *testing.T) {
+	q := Query{Expression: &IsFormatExpression{Field: "Email", Format: "email"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"IsFormat","Expression":{"Field":"Email","Format":"email"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := back.Evaluate(map[string]interface{}{"Email": "a@example.com"}); err != nil || !ok {
+		t.Errorf("round trip: got %v, %v", ok, err)
+	}
+	for _, tt := range []struct {
+		format string
+		value  interface{}
+		want   bool
+	}{
+		{"email", "jo.bloggs+tag@example.co.nz", true},
+		{"email", "Jo <jo@example.com>", false},
+		{"email", "not an email", false},
+		{"uuid", "123e4567-E89B-12d3-a456-426614174000", true},
+		{"uuid", "123e4567e89b12d3a456426614174000", false},
+		{"uuid", "123e4567-e89b-12d3-a456-42661417400g", false},
+		{"url", "https://example.com/a?b=c", true},
+		{"url", "/relative/path", false},
+		{"url", "example.com", false},
+		{"date", "2024-02-29", true},
+		{"date", "2023-02-29", false},
+		{"datetime", "2024-03-01T10:15:30.5+13:00", true},
+		{"datetime", "2024-03-01 10:15:30", false},
+		{"time", "23:59:01", true},
+		{"time", "24:00:00", false},
+		{"ipv4", "192.168.0.1", true},
+		{"ipv4", "::1", false},
+		{"ipv6", "2001:db8::1", true},
+		{"ipv6", "10.0.0.1", false},
+		{"hostname", "api-1.example.com", true},
+		{"hostname", "-bad.example.com", false},
+		{"hostname", "under_score.com", false},
+		{"email", 42, false},
+		{"email", nil, false},
+	} {
+		q := Query{Expression: &IsFormatExpression{Field: "V", Format: tt.format}}
+		got, err := q.Evaluate(map[string]interface{}{"V": tt.value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v is format %q: got %v, want %v", tt.value, tt.format, got, tt.want)
+		}
+	}
+	unknown := Query{Expression: &IsFormatExpression{Field: "V", Format: "postcode"}}
+	if _, err := unknown.Evaluate(map[string]interface{}{"V": "6011"}); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+	ctx := &Context{Formats: map[string]func(string) bool{"postcode": func(s string) bool { return len(s) == 4 }}}
+	if ok, err := unknown.Evaluate(map[string]interface{}{"V": "6011"}, ctx); err != nil || !ok {
+		t.Errorf("context format: got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package math provides numeric functions for FunctionExpression. Register
+// adds them to a Context under lower-case names, so the simple parser's calls
+// such as abs(Delta) > 5 find them.
+//
+// Arguments are coerced as comparisons coerce them: any integer or float
+// type, json.Number and numeric strings are accepted. Results are float64.
+package math
+
+import (
+	"encoding/json"
+	"fmt"
+	gomath "math"
+	"reflect"
+	"strconv"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Functions returns the functions of this package keyed by the names
+// Register uses.
+func Functions() map[string]evaluator.Function {
+	return map[string]evaluator.Function{
+		"abs":   Abs{},
+		"round": Round{},
+		"floor": Floor{},
+		"ceil":  Ceil{},
+		"min":   Min{},
+		"max":   Max{},
+		"pow":   Pow{},
+	}
+}
+
+// Register adds the functions of this package to ctx, replacing functions of
+// the same name.
+func Register(ctx *evaluator.Context) {
+	if ctx.Functions == nil {
+		ctx.Functions = map[string]evaluator.Function{}
+	}
+	for name, fn := range Functions() {
+		ctx.Functions[name] = fn
+	}
+}
+
+// Abs returns the absolute value of its argument.
+type Abs struct{}
+
+func (Abs) Call(args ...interface{}) (interface{}, error) {
+	ns, err := numbers("abs", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return gomath.Abs(ns[0]), nil
+}
+
+// Round rounds its first argument half away from zero, to the number of
+// decimal places given by the optional second argument.
+type Round struct{}
+
+func (Round) Call(args ...interface{}) (interface{}, error) {
+	ns, err := numbers("round", args, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(ns) == 1 || ns[1] == 0 {
+		return gomath.Round(ns[0]), nil
+	}
+	if ns[1] != gomath.Trunc(ns[1]) {
+		return nil, fmt.Errorf("round expects a whole number of places, got %v", args[1])
+	}
+	scale := gomath.Pow(10, ns[1])
+	return gomath.Round(ns[0]*scale) / scale, nil
+}
+
+// Floor returns the greatest integer value less than or equal to its
+// argument.
+type Floor struct{}
+
+func (Floor) Call(args ...interface{}) (interface{}, error) {
+	ns, err := numbers("floor", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return gomath.Floor(ns[0]), nil
+}
+
+// Ceil returns the least integer value greater than or equal to its
+// argument.
+type Ceil struct{}
+
+func (Ceil) Call(args ...interface{}) (interface{}, error) {
+	ns, err := numbers("ceil", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return gomath.Ceil(ns[0]), nil
+}
+
+// Min returns the smallest of its arguments.
+type Min struct{}
+
+func (Min) Call(args ...interface{}) (interface{}, error) {
+	ns, err := numbers("min", args, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	m := ns[0]
+	for _, n := range ns[1:] {
+		m = gomath.Min(m, n)
+	}
+	return m, nil
+}
+
+// Max returns the largest of its arguments.
+type Max struct{}
+
+func (Max) Call(args ...interface{}) (interface{}, error) {
+	ns, err := numbers("max", args, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	m := ns[0]
+	for _, n := range ns[1:] {
+		m = gomath.Max(m, n)
+	}
+	return m, nil
+}
+
+// Pow returns its first argument raised to the power of the second.
+type Pow struct{}
+
+func (Pow) Call(args ...interface{}) (interface{}, error) {
+	ns, err := numbers("pow", args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	return gomath.Pow(ns[0], ns[1]), nil
+}
+
+// numbers checks that name was given between lo and hi arguments, or at
+// least lo when hi is negative, and converts them to float64.
+func numbers(name string, args []interface{}, lo, hi int) ([]float64, error) {
+	switch {
+	case len(args) >= lo && (hi < 0 || len(args) <= hi):
+	case hi < 0:
+		return nil, fmt.Errorf("%s expects at least %d argument", name, lo)
+	case lo != hi:
+		return nil, fmt.Errorf("%s expects %d or %d arguments", name, lo, hi)
+	case lo == 1:
+		return nil, fmt.Errorf("%s expects 1 argument", name)
+	default:
+		return nil, fmt.Errorf("%s expects %d arguments", name, lo)
+	}
+	ns := make([]float64, len(args))
+	for i, a := range args {
+		n, ok := number(a)
+		if !ok {
+			return nil, fmt.Errorf("%s expects numbers, got %v", name, a)
+		}
+		ns[i] = n
+	}
+	return ns, nil
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,84 @@
+package math
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		fn   evaluator.Function
+		args []interface{}
+		want float64
+	}{
+		{Abs{}, []interface{}{-3}, 3},
+		{Abs{}, []interface{}{int8(-4)}, 4},
+		{Abs{}, []interface{}{json.Number("-2.5")}, 2.5},
+		{Round{}, []interface{}{2.5}, 3},
+		{Round{}, []interface{}{-2.5}, -3},
+		{Round{}, []interface{}{3.14159, 2}, 3.14},
+		{Round{}, []interface{}{1234, -2}, 1200},
+		{Round{}, []interface{}{"2.345", uint(1)}, 2.3},
+		{Floor{}, []interface{}{float32(1.5)}, 1},
+		{Floor{}, []interface{}{-1.5}, -2},
+		{Ceil{}, []interface{}{uint64(7)}, 7},
+		{Ceil{}, []interface{}{"1.2"}, 2},
+		{Min{}, []interface{}{3, uint16(1), 2.5}, 1},
+		{Min{}, []interface{}{json.Number("9")}, 9},
+		{Max{}, []interface{}{3, int64(-1), json.Number("4.5")}, 4.5},
+		{Pow{}, []interface{}{2, uint8(10)}, 1024},
+		{Pow{}, []interface{}{"9", 0.5}, 3},
+	}
+	for _, tt := range tests {
+		got, err := tt.fn.Call(tt.args...)
+		if err != nil {
+			t.Errorf("%T%v: %v", tt.fn, tt.args, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%T%v = %v, want %v", tt.fn, tt.args, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		fn   evaluator.Function
+		args []interface{}
+	}{
+		{Abs{}, nil},
+		{Abs{}, []interface{}{"x"}},
+		{Abs{}, []interface{}{nil}},
+		{Round{}, []interface{}{1.5, 0.5}},
+		{Min{}, nil},
+		{Max{}, []interface{}{1, true}},
+		{Pow{}, []interface{}{2}},
+	} {
+		if _, err := tt.fn.Call(tt.args...); err == nil {
+			t.Errorf("%T%v: expected an error", tt.fn, tt.args)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	ctx := &evaluator.Context{}
+	Register(ctx)
+	rec := map[string]interface{}{"Delta": -7, "Price": 19.99, "Limit": json.Number("10")}
+	for expr, want := range map[string]bool{
+		`abs(Delta) > 5`:          true,
+		`round(Price) == 20`:      true,
+		`floor(Price) == 19`:      true,
+		`max(Delta, Limit) == 10`: true,
+		`min(Price, Limit) < 10`:  false,
+		`pow(Limit, 2) >= 100`:    true,
+	} {
+		q, err := simple.Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		got, err := q.Evaluate(rec, ctx)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v %v, want %v", expr, got, err, want)
+		}
+	}
+}
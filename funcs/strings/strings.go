@@ -0,0 +1,151 @@
+// Package strings provides string functions for FunctionExpression. Register
+// adds them to a Context under lower-case names, so the simple parser's calls
+// such as lower(Name) == "bob" find them.
+//
+// Arguments that are not strings are formatted with fmt.Sprint, and nil is
+// the empty string.
+package strings
+
+import (
+	"fmt"
+	"reflect"
+	gostrings "strings"
+	"unicode/utf8"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Functions returns the functions of this package keyed by the names
+// Register uses.
+func Functions() map[string]evaluator.Function {
+	return map[string]evaluator.Function{
+		"lower":   Lower{},
+		"upper":   Upper{},
+		"trim":    Trim{},
+		"split":   Split{},
+		"replace": Replace{},
+		"strlen":  StrLen{},
+		"concat":  Concat{},
+	}
+}
+
+// Register adds the functions of this package to ctx, replacing functions of
+// the same name.
+func Register(ctx *evaluator.Context) {
+	if ctx.Functions == nil {
+		ctx.Functions = map[string]evaluator.Function{}
+	}
+	for name, fn := range Functions() {
+		ctx.Functions[name] = fn
+	}
+}
+
+// Lower returns its argument in lower case.
+type Lower struct{}
+
+func (Lower) Call(args ...interface{}) (interface{}, error) {
+	if err := arity("lower", args, 1, 1); err != nil {
+		return nil, err
+	}
+	return gostrings.ToLower(str(args[0])), nil
+}
+
+// Upper returns its argument in upper case.
+type Upper struct{}
+
+func (Upper) Call(args ...interface{}) (interface{}, error) {
+	if err := arity("upper", args, 1, 1); err != nil {
+		return nil, err
+	}
+	return gostrings.ToUpper(str(args[0])), nil
+}
+
+// Trim removes leading and trailing white space from its first argument or,
+// given a second, the characters in it.
+type Trim struct{}
+
+func (Trim) Call(args ...interface{}) (interface{}, error) {
+	if err := arity("trim", args, 1, 2); err != nil {
+		return nil, err
+	}
+	if len(args) == 2 {
+		return gostrings.Trim(str(args[0]), str(args[1])), nil
+	}
+	return gostrings.TrimSpace(str(args[0])), nil
+}
+
+// Split splits its first argument around each occurrence of the second,
+// returning a list that Contains and the list quantifiers accept.
+type Split struct{}
+
+func (Split) Call(args ...interface{}) (interface{}, error) {
+	if err := arity("split", args, 2, 2); err != nil {
+		return nil, err
+	}
+	parts := gostrings.Split(str(args[0]), str(args[1]))
+	list := make([]interface{}, len(parts))
+	for i, p := range parts {
+		list[i] = p
+	}
+	return list, nil
+}
+
+// Replace replaces every occurrence of its second argument in the first with
+// the third.
+type Replace struct{}
+
+func (Replace) Call(args ...interface{}) (interface{}, error) {
+	if err := arity("replace", args, 3, 3); err != nil {
+		return nil, err
+	}
+	return gostrings.ReplaceAll(str(args[0]), str(args[1]), str(args[2])), nil
+}
+
+// StrLen returns the number of characters in a string, or the number of
+// elements of a list or map such as the result of Split. It is not named len,
+// as the simple parser reads len(Field) as a LengthExpression.
+type StrLen struct{}
+
+func (StrLen) Call(args ...interface{}) (interface{}, error) {
+	if err := arity("strlen", args, 1, 1); err != nil {
+		return nil, err
+	}
+	if v := reflect.ValueOf(args[0]); v.Kind() == reflect.Slice || v.Kind() == reflect.Array || v.Kind() == reflect.Map {
+		return v.Len(), nil
+	}
+	return utf8.RuneCountInString(str(args[0])), nil
+}
+
+// Concat joins its arguments.
+type Concat struct{}
+
+func (Concat) Call(args ...interface{}) (interface{}, error) {
+	var b gostrings.Builder
+	for _, a := range args {
+		b.WriteString(str(a))
+	}
+	return b.String(), nil
+}
+
+func str(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func arity(name string, args []interface{}, lo, hi int) error {
+	switch {
+	case len(args) >= lo && len(args) <= hi:
+		return nil
+	case lo != hi:
+		return fmt.Errorf("%s expects %d or %d arguments", name, lo, hi)
+	case lo == 1:
+		return fmt.Errorf("%s expects 1 argument", name)
+	}
+	return fmt.Errorf("%s expects %d arguments", name, lo)
+}
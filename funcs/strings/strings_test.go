@@ -0,0 +1,86 @@
+package strings
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		fn   evaluator.Function
+		args []interface{}
+		want interface{}
+	}{
+		{Lower{}, []interface{}{"ÀBC"}, "àbc"},
+		{Upper{}, []interface{}{"abc"}, "ABC"},
+		{Upper{}, []interface{}{nil}, ""},
+		{Trim{}, []interface{}{"  a b \n"}, "a b"},
+		{Trim{}, []interface{}{"--a-", "-"}, "a"},
+		{Split{}, []interface{}{"a,b,,c", ","}, []interface{}{"a", "b", "", "c"}},
+		{Replace{}, []interface{}{"a-b-c", "-", "+"}, "a+b+c"},
+		{StrLen{}, []interface{}{"héllo"}, 5},
+		{StrLen{}, []interface{}{[]interface{}{1, 2}}, 2},
+		{StrLen{}, []interface{}{42}, 2},
+		{Concat{}, []interface{}{"a", 1, nil, true}, "a1true"},
+		{Concat{}, nil, ""},
+	}
+	for _, tt := range tests {
+		got, err := tt.fn.Call(tt.args...)
+		if err != nil {
+			t.Errorf("%T%v: %v", tt.fn, tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%T%v = %#v, want %#v", tt.fn, tt.args, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		fn   evaluator.Function
+		args []interface{}
+	}{
+		{Lower{}, nil},
+		{Trim{}, []interface{}{"a", "b", "c"}},
+		{Split{}, []interface{}{"a"}},
+		{Replace{}, []interface{}{"a", "b"}},
+	} {
+		if _, err := tt.fn.Call(tt.args...); err == nil {
+			t.Errorf("%T%v: expected an arity error", tt.fn, tt.args)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	ctx := &evaluator.Context{}
+	Register(ctx)
+	rec := map[string]interface{}{"Name": "  Bob ", "Tags": "go,eval"}
+	for expr, want := range map[string]bool{
+		`lower(Name) == "  bob "`:              true,
+		`trim(Name) == "Bob"`:                  true,
+		`concat(trim(Name), "!") == "Bob!"`:    true,
+		`replace(Tags, ",", ";") == "go;eval"`: true,
+		`upper(Name) == "BOB"`:                 false,
+		`strlen(trim(Name)) == 3`:              true,
+	} {
+		q, err := simple.Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		got, err := q.Evaluate(rec, ctx)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v %v, want %v", expr, got, err, want)
+		}
+	}
+	q := evaluator.Query{Expression: &evaluator.ComparisonExpression{
+		LHS: evaluator.FunctionExpression{Name: "strlen", Args: []evaluator.Term{
+			evaluator.FunctionExpression{Name: "split", Args: []evaluator.Term{evaluator.Field{Name: "Tags"}, evaluator.Constant{Value: ","}}},
+		}},
+		RHS:       evaluator.Constant{Value: 2},
+		Operation: "eq",
+	}}
+	if ok, err := q.Evaluate(rec, ctx); err != nil || !ok {
+		t.Errorf("strlen(split(Tags)): %v %v", ok, err)
+	}
+}
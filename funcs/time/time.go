@@ -0,0 +1,151 @@
+// Package time provides time functions for FunctionExpression. Register adds
+// them to a Context under lower-case names, so the simple parser's calls such
+// as weekday(CreatedAt) == "Saturday" find them.
+//
+// Times may be given as time.Time, *time.Time or strings in RFC 3339 form.
+// Now and Age read their Clock, which Register sets to the Context clock so
+// that Context.Clock fixes the current time in tests.
+package time
+
+import (
+	"fmt"
+	gotime "time"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Functions returns the functions of this package keyed by the names
+// Register uses. Now and Age use clock, or time.Now when it is nil.
+func Functions(clock func() gotime.Time) map[string]evaluator.Function {
+	return map[string]evaluator.Function{
+		"now":         Now{Clock: clock},
+		"parsetime":   ParseTime{},
+		"age":         Age{Clock: clock},
+		"truncateday": TruncateDay{},
+		"weekday":     Weekday{},
+	}
+}
+
+// Register adds the functions of this package to ctx, replacing functions of
+// the same name. Now and Age follow ctx.Clock, including later changes to it.
+func Register(ctx *evaluator.Context) {
+	if ctx.Functions == nil {
+		ctx.Functions = map[string]evaluator.Function{}
+	}
+	for name, fn := range Functions(ctx.Now) {
+		ctx.Functions[name] = fn
+	}
+}
+
+// Now returns the current time.
+type Now struct {
+	// Clock returns the current time. It defaults to time.Now.
+	Clock func() gotime.Time
+}
+
+func (f Now) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("now expects no arguments")
+	}
+	return now(f.Clock), nil
+}
+
+// ParseTime parses its first argument as RFC 3339 or, given a second, with
+// that Go layout such as "2006-01-02".
+type ParseTime struct{}
+
+func (ParseTime) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("parsetime expects 1 or 2 arguments")
+	}
+	if len(args) == 1 {
+		return timeArg("parsetime", args[0])
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("parsetime expects a string, got %v", args[0])
+	}
+	layout, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("parsetime expects a layout string, got %v", args[1])
+	}
+	t, err := gotime.Parse(layout, s)
+	if err != nil {
+		return nil, fmt.Errorf("parsetime: %w", err)
+	}
+	return t, nil
+}
+
+// Age returns the seconds elapsed since its argument as a float64, negative
+// for times in the future.
+type Age struct {
+	// Clock returns the current time. It defaults to time.Now.
+	Clock func() gotime.Time
+}
+
+func (f Age) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("age expects 1 argument")
+	}
+	t, err := timeArg("age", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return now(f.Clock).Sub(t).Seconds(), nil
+}
+
+// TruncateDay returns midnight at the start of the day of its argument, in
+// the argument's location.
+type TruncateDay struct{}
+
+func (TruncateDay) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("truncateday expects 1 argument")
+	}
+	t, err := timeArg("truncateday", args[0])
+	if err != nil {
+		return nil, err
+	}
+	y, m, d := t.Date()
+	return gotime.Date(y, m, d, 0, 0, 0, 0, t.Location()), nil
+}
+
+// Weekday returns the English name of the day of the week of its argument,
+// such as "Monday".
+type Weekday struct{}
+
+func (Weekday) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("weekday expects 1 argument")
+	}
+	t, err := timeArg("weekday", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return t.Weekday().String(), nil
+}
+
+func now(clock func() gotime.Time) gotime.Time {
+	if clock != nil {
+		return clock()
+	}
+	return gotime.Now()
+}
+
+func timeArg(name string, v interface{}) (gotime.Time, error) {
+	switch t := v.(type) {
+	case gotime.Time:
+		return t, nil
+	case *gotime.Time:
+		if t != nil {
+			return *t, nil
+		}
+	case string:
+		p, err := gotime.Parse(gotime.RFC3339Nano, t)
+		if err != nil {
+			return gotime.Time{}, fmt.Errorf("%s: %w", name, err)
+		}
+		return p, nil
+	}
+	return gotime.Time{}, fmt.Errorf("%s expects a time, got %v", name, v)
+}
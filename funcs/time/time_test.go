@@ -0,0 +1,91 @@
+package time
+
+import (
+	"testing"
+	gotime "time"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+var fixed = gotime.Date(2024, 3, 2, 15, 30, 0, 0, gotime.UTC)
+
+func clock() gotime.Time { return fixed }
+
+func TestFunctions(t *testing.T) {
+	auckland := gotime.FixedZone("NZDT", 13*60*60)
+	local := gotime.Date(2024, 3, 2, 0, 30, 0, 0, auckland)
+	tests := []struct {
+		fn   evaluator.Function
+		args []interface{}
+		want interface{}
+	}{
+		{Now{Clock: clock}, nil, fixed},
+		{ParseTime{}, []interface{}{"2024-03-01T10:00:00Z"}, gotime.Date(2024, 3, 1, 10, 0, 0, 0, gotime.UTC)},
+		{ParseTime{}, []interface{}{"01/03/2024", "02/01/2006"}, gotime.Date(2024, 3, 1, 0, 0, 0, 0, gotime.UTC)},
+		{ParseTime{}, []interface{}{fixed}, fixed},
+		{Age{Clock: clock}, []interface{}{"2024-03-02T15:00:00Z"}, 1800.0},
+		{Age{Clock: clock}, []interface{}{fixed.Add(gotime.Hour)}, -3600.0},
+		{Age{Clock: clock}, []interface{}{&fixed}, 0.0},
+		{TruncateDay{}, []interface{}{fixed}, gotime.Date(2024, 3, 2, 0, 0, 0, 0, gotime.UTC)},
+		{TruncateDay{}, []interface{}{local}, gotime.Date(2024, 3, 2, 0, 0, 0, 0, auckland)},
+		{Weekday{}, []interface{}{"2024-03-02T15:30:00Z"}, "Saturday"},
+		{Weekday{}, []interface{}{local}, "Saturday"},
+	}
+	for _, tt := range tests {
+		got, err := tt.fn.Call(tt.args...)
+		if err != nil {
+			t.Errorf("%T%v: %v", tt.fn, tt.args, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%T%v = %v, want %v", tt.fn, tt.args, got, tt.want)
+		}
+	}
+	if got, _ := (Now{}).Call(); gotime.Since(got.(gotime.Time)) > gotime.Minute {
+		t.Errorf("Now without a clock returned %v", got)
+	}
+	for _, tt := range []struct {
+		fn   evaluator.Function
+		args []interface{}
+	}{
+		{Now{}, []interface{}{1}},
+		{ParseTime{}, nil},
+		{ParseTime{}, []interface{}{"yesterday"}},
+		{ParseTime{}, []interface{}{"2024-03-01", 5}},
+		{ParseTime{}, []interface{}{"2024-13-01", "2006-01-02"}},
+		{Age{}, []interface{}{42}},
+		{Age{}, []interface{}{(*gotime.Time)(nil)}},
+		{TruncateDay{}, nil},
+		{Weekday{}, []interface{}{nil}},
+	} {
+		if _, err := tt.fn.Call(tt.args...); err == nil {
+			t.Errorf("%T%v: expected an error", tt.fn, tt.args)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	ctx := &evaluator.Context{}
+	Register(ctx)
+	ctx.Clock = clock
+	rec := map[string]interface{}{"CreatedAt": "2024-03-02T09:30:00Z", "Due": fixed.Add(-48 * gotime.Hour)}
+	for expr, want := range map[string]bool{
+		`age(CreatedAt) == 21600`:                                    true,
+		`age(CreatedAt) > 86400`:                                     false,
+		`age(Due) > 86400`:                                           true,
+		`weekday(CreatedAt) == "Saturday"`:                           true,
+		`weekday(now()) == "Saturday"`:                               true,
+		`weekday(parsetime("2024-03-04", "2006-01-02")) == "Monday"`: true,
+		`age(truncateday(now())) == 55800`:                           true,
+	} {
+		q, err := simple.Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		got, err := q.Evaluate(rec, ctx)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v %v, want %v", expr, got, err, want)
+		}
+	}
+}
@@ -55,3 +55,38 @@ func TestFunctionExpression(t *testing.T) {
 		t.Errorf("expected 35.0, got %v", result2)
 	}
 }
+
+func TestFunctionExpressionFieldArgs(t *testing.T) {
+	expr := FunctionExpression{
+		Func: SumFunc{},
+		Args: []Term{
+			Field{Name: "Shipping"},
+			Field{Name: "Order.Items[1].Price"},
+			Constant{Value: 1},
+		},
+	}
+	rec := map[string]interface{}{
+		"Shipping": 4,
+		"Order": map[string]interface{}{
+			"Items": []interface{}{
+				map[string]interface{}{"Price": 10},
+				map[string]interface{}{"Price": 25.5},
+			},
+		},
+	}
+	result, err := expr.Evaluate(rec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != 30.5 {
+		t.Errorf("expected 30.5, got %v", result)
+	}
+
+	if result, err := expr.Evaluate(map[string]interface{}{"Shipping": 4}); err == nil {
+		t.Errorf("expected an error for a missing field, got %v", result)
+	}
+	q := Query{Expression: &ComparisonExpression{LHS: expr, RHS: Constant{Value: 30}, Operation: "gt"}}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("comparison: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,66 @@
+package evaluator
+
+import "reflect"
+
+// FuzzyMatchExpression succeeds when the string Field is within MaxDistance
+// edits of Value, counting the single character insertions, deletions and
+// substitutions of the Levenshtein distance. It suits data where names may be
+// misspelt: "Jon Smith" is within 1 of "John Smith".
+type FuzzyMatchExpression struct {
+	Field       string
+	Value       string
+	MaxDistance int
+}
+
+func (e FuzzyMatchExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || f.Kind() != reflect.String {
+		return false, nil
+	}
+	return withinDistance(f.String(), e.Value, e.MaxDistance), nil
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b,
+// counted in runes, is at most max. It keeps two rows of the distance table
+// and stops once every entry of a row exceeds max.
+func withinDistance(a, b string, max int) bool {
+	if max < 0 {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return false
+	}
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i, ca := range ra {
+		cur[0] = i + 1
+		best := cur[0]
+		for j, cb := range rb {
+			cost := 1
+			if ca == cb {
+				cost = 0
+			}
+			cur[j+1] = min(prev[j]+cost, prev[j+1]+1, cur[j]+1)
+			best = min(best, cur[j+1])
+		}
+		if best > max {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)] <= max
+}
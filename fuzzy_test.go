@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWithinDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want bool
+	}{
+		{"", "", 0, true},
+		{"Smith", "Smith", 0, true},
+		{"Smith", "Smyth", 0, false},
+		{"Smith", "Smyth", 1, true},
+		{"Jon Smith", "John Smith", 1, true},
+		{"kitten", "sitting", 2, false},
+		{"kitten", "sitting", 3, true},
+		{"", "abc", 2, false},
+		{"abc", "", 3, true},
+		{"Zoë", "Zoe", 1, true},
+		{"a", "a", -1, false},
+	}
+	for _, tt := range tests {
+		if got := withinDistance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("withinDistance(%q, %q, %d) = %v, want %v", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyMatchExpression(t *testing.T) {
+	q := Query{Expression: &FuzzyMatchExpression{Field: "Name", Value: "John Smith", MaxDistance: 2}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"FuzzyMatch","Expression":{"Field":"Name","Value":"John Smith","MaxDistance":2}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"John Smith": true, "Jon Smyth": true, "Jane Smyth": false, "": false} {
+		if ok, _ := back.Evaluate(map[string]interface{}{"Name": name}); ok != want {
+			t.Errorf("%q: got %v, want %v", name, ok, want)
+		}
+	}
+	if ok, _ := back.Evaluate(map[string]interface{}{"Name": 1}); ok {
+		t.Errorf("non-string matched")
+	}
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+)
+
+// earthRadiusMeters is the mean radius of the Earth used for great-circle
+// distances.
+const earthRadiusMeters = 6371008.8
+
+// GeoWithinExpression succeeds when the point at the latitude LatField and
+// longitude LonField, in degrees, lies within RadiusMeters of Lat, Lon along
+// the surface of the Earth, treated as a sphere. Coordinates may be numbers
+// or numeric strings; records whose coordinates are missing, not numbers or
+// out of range do not match. A centre out of range or a negative radius is
+// an error.
+type GeoWithinExpression struct {
+	LatField     string
+	LonField     string
+	Lat          float64
+	Lon          float64
+	RadiusMeters float64
+}
+
+func (e GeoWithinExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	if !validLatLon(e.Lat, e.Lon) {
+		return false, fmt.Errorf("geo within: centre %v, %v is not a valid latitude and longitude", e.Lat, e.Lon)
+	}
+	if !(e.RadiusMeters >= 0) {
+		return false, fmt.Errorf("geo within: radius %v is negative", e.RadiusMeters)
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	var coords [2]float64
+	for n, name := range [2]string{e.LatField, e.LonField} {
+		f, ok := getField(v, name)
+		if !ok || !f.CanInterface() {
+			return false, nil
+		}
+		if coords[n], ok = numeric[float64](f.Interface()); !ok {
+			return false, nil
+		}
+	}
+	if !validLatLon(coords[0], coords[1]) {
+		return false, nil
+	}
+	return haversine(e.Lat, e.Lon, coords[0], coords[1]) <= e.RadiusMeters, nil
+}
+
+func validLatLon(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// haversine returns the great-circle distance in meters between two points
+// given in degrees.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(min(a, 1)))
+}
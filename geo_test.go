@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestGeoWithinExpression(t *testing.T) {
+	// Within 5km of Wellington's Parliament buildings.
+	q := Query{Expression: &GeoWithinExpression{LatField: "lat", LonField: "lon", Lat: -41.2784, Lon: 174.7767, RadiusMeters: 5000}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"GeoWithin","Expression":{"LatField":"lat","LonField":"lon","Lat":-41.2784,"Lon":174.7767,"RadiusMeters":5000}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		lat, lon interface{}
+		want     bool
+	}{
+		{"Te Papa", -41.2905, 174.7821, true},
+		{"airport", -41.3272, 174.8053, false},
+		{"Auckland", -36.8485, 174.7633, false},
+		{"strings", "-41.2865", "174.7762", true},
+		{"not a number", "north", 174.7762, false},
+		{"out of range", -141.2865, 174.7762, false},
+		{"missing", nil, nil, false},
+	} {
+		rec := map[string]interface{}{}
+		if tt.lat != nil {
+			rec["lat"], rec["lon"] = tt.lat, tt.lon
+		}
+		got, err := back.Evaluate(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	bad := Query{Expression: &GeoWithinExpression{LatField: "lat", LonField: "lon", Lat: 91, RadiusMeters: 1}}
+	if _, err := bad.Evaluate(map[string]interface{}{"lat": 0, "lon": 0}); err == nil {
+		t.Errorf("expected an error for an invalid centre")
+	}
+}
+
+func TestHaversine(t *testing.T) {
+	// London to Paris is about 343.5km.
+	if d := haversine(51.5074, -0.1278, 48.8566, 2.3522); math.Abs(d-343.5e3) > 1e3 {
+		t.Errorf("got %v", d)
+	}
+	// Points either side of the antimeridian are close.
+	if d := haversine(0, 179.999, 0, -179.999); d > 300 {
+		t.Errorf("got %v", d)
+	}
+}
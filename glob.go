@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"errors"
+	"reflect"
+	"unicode/utf8"
+)
+
+// ErrBadGlob is returned when a GlobExpression pattern ends in an unfinished
+// escape.
+var ErrBadGlob = errors.New("glob pattern has trailing backslash")
+
+// GlobExpression succeeds when the string Field matches the shell style
+// wildcard Pattern. '*' matches any run of characters, including '/', '?'
+// matches a single character and '\' escapes the next character.
+type GlobExpression struct {
+	Field   string
+	Pattern string
+}
+
+func (e GlobExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || f.Kind() != reflect.String {
+		return false, nil
+	}
+	return globMatch(e.Pattern, f.String())
+}
+
+// globMatch matches s against pattern, backtracking only to the most recent
+// '*' so the cost stays proportional to len(pattern)*len(s).
+func globMatch(pattern, s string) (bool, error) {
+	px, sx := 0, 0
+	starP, starS := -1, -1
+	for px < len(pattern) || sx < len(s) {
+		if px < len(pattern) {
+			switch c := pattern[px]; c {
+			case '*':
+				starP, starS = px, sx
+				px++
+				continue
+			case '?':
+				if sx < len(s) {
+					_, n := utf8.DecodeRuneInString(s[sx:])
+					px++
+					sx += n
+					continue
+				}
+			default:
+				lit := c
+				next := px + 1
+				if c == '\\' {
+					if px+1 >= len(pattern) {
+						return false, ErrBadGlob
+					}
+					lit = pattern[px+1]
+					next = px + 2
+				}
+				if sx < len(s) && s[sx] == lit {
+					px = next
+					sx++
+					continue
+				}
+			}
+		}
+		if starP >= 0 && starS < len(s) {
+			_, n := utf8.DecodeRuneInString(s[starS:])
+			starS += n
+			px, sx = starP+1, starS
+			continue
+		}
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"/api/*", "/api/v1/users", true},
+		{"/api/*", "/static/x", false},
+		{"*.json", "a/b.json", true},
+		{"*.json", "a/b.jsonx", false},
+		{"h?llo", "héllo", true},
+		{"h?llo", "hllo", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{`\*literal`, "*literal", true},
+		{`\*literal`, "xliteral", false},
+		{"", "", true},
+		{"", "x", false},
+	}
+	for _, tt := range tests {
+		got, err := globMatch(tt.pattern, tt.s)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.pattern, err)
+		}
+		if got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+	if _, err := globMatch(`abc\`, "abc"); !errors.Is(err, ErrBadGlob) {
+		t.Errorf("expected ErrBadGlob, got %v", err)
+	}
+}
+
+func TestGlobExpression(t *testing.T) {
+	q := Query{Expression: &GlobExpression{Field: "Path", Pattern: "/api/*.json"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"Glob","Expression":{"Field":"Path","Pattern":"/api/*.json"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := back.Evaluate(map[string]interface{}{"Path": "/api/v1/a.json"}); !ok {
+		t.Errorf("expected match")
+	}
+	if ok, _ := back.Evaluate(map[string]interface{}{"Path": 1}); ok {
+		t.Errorf("non-string matched")
+	}
+}
@@ -0,0 +1,45 @@
+package evaluator
+
+import "reflect"
+
+// HasBitsExpression succeeds when the integer Field has any of the bits of
+// Mask set, or all of them when All is set, for status flags packed into a
+// bitfield. Floats and numeric strings holding whole numbers are read as
+// integers, negative values by their two's complement bits. A zero Mask
+// matches nothing.
+type HasBitsExpression struct {
+	Field string
+	Mask  uint64
+	All   bool `json:",omitempty"`
+}
+
+func (e HasBitsExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok || e.Mask == 0 {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok {
+		return false, nil
+	}
+	var bits uint64
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits = uint64(f.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bits = f.Uint()
+	default:
+		if !f.CanInterface() {
+			return false, nil
+		}
+		n, ok := integer(f.Interface())
+		if !ok {
+			return false, nil
+		}
+		bits = uint64(n)
+	}
+	if e.All {
+		return bits&e.Mask == e.Mask, nil
+	}
+	return bits&e.Mask != 0, nil
+}
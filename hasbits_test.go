@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHasBitsExpression(t *testing.T) {
+	q := Query{Expression: &HasBitsExpression{Field: "Flags", Mask: 0b101, All: true}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"HasBits","Expression":{"Field":"Flags","Mask":5,"All":true}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var all Query
+	if err := json.Unmarshal(data, &all); err != nil {
+		t.Fatal(err)
+	}
+	anyBits := Query{Expression: &HasBitsExpression{Field: "Flags", Mask: 0b101}}
+	tests := []struct {
+		flags    interface{}
+		all, any bool
+	}{
+		{0b111, true, true},
+		{uint8(0b101), true, true},
+		{0b100, false, true},
+		{0b010, false, false},
+		{float64(5), true, true},
+		{"4", false, true},
+		{int64(-1), true, true},
+		{uint64(1<<63 | 1), false, true},
+		{2.5, false, false},
+		{"x", false, false},
+		{nil, false, false},
+	}
+	for _, tt := range tests {
+		rec := map[string]interface{}{"Flags": tt.flags}
+		if got, _ := all.Evaluate(rec); got != tt.all {
+			t.Errorf("all %v: got %v", tt.flags, got)
+		}
+		if got, _ := anyBits.Evaluate(rec); got != tt.any {
+			t.Errorf("any %v: got %v", tt.flags, got)
+		}
+	}
+	zero := Query{Expression: &HasBitsExpression{Field: "Flags", All: true}}
+	if ok, _ := zero.Evaluate(map[string]interface{}{"Flags": 7}); ok {
+		t.Errorf("zero mask matched")
+	}
+}
@@ -0,0 +1,74 @@
+package evaluator
+
+import (
+	"math"
+	"reflect"
+)
+
+// HasKeyExpression succeeds when the map Field contains Key, whatever its
+// value, even nil. Key is converted to the map's key type, so a JSON number
+// finds an int key. Fields that are not maps do not match.
+type HasKeyExpression struct {
+	Field string
+	Key   interface{}
+}
+
+func (e HasKeyExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok {
+		return false, nil
+	}
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return false, nil
+		}
+		f = f.Elem()
+	}
+	if f.Kind() != reflect.Map {
+		return false, nil
+	}
+	k, ok := mapKey(f.Type().Key(), e.Key)
+	if !ok {
+		return false, nil
+	}
+	return f.MapIndex(k).IsValid(), nil
+}
+
+// mapKey converts key to a value of type t, reporting false when it cannot.
+func mapKey(t reflect.Type, key interface{}) (reflect.Value, bool) {
+	kv := reflect.ValueOf(key)
+	switch {
+	case !kv.IsValid():
+		return reflect.Value{}, false
+	case kv.Type().AssignableTo(t):
+		return kv, true
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf(stringValue(key)).Convert(t), true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := numeric[int64](key); ok && wholeNumber(key) {
+			return reflect.ValueOf(n).Convert(t), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := numeric[uint64](key); ok && wholeNumber(key) {
+			return reflect.ValueOf(n).Convert(t), true
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, ok := numeric[float64](key); ok {
+			return reflect.ValueOf(n).Convert(t), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// wholeNumber reports whether key has no fractional part, so 1.5 does not
+// find the key 1.
+func wholeNumber(key interface{}) bool {
+	f, ok := numeric[float64](key)
+	return ok && f == math.Trunc(f)
+}
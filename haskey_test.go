@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHasKeyExpression(t *testing.T) {
+	type rec struct {
+		Attrs  map[string]interface{}
+		Scores map[int]float64
+		Name   string
+	}
+	r := &rec{
+		Attrs:  map[string]interface{}{"color": "red", "size": nil},
+		Scores: map[int]float64{3: 1.5},
+		Name:   "x",
+	}
+	tests := []struct {
+		name string
+		e    HasKeyExpression
+		want bool
+	}{
+		{"present", HasKeyExpression{Field: "Attrs", Key: "color"}, true},
+		{"nil value", HasKeyExpression{Field: "Attrs", Key: "size"}, true},
+		{"absent", HasKeyExpression{Field: "Attrs", Key: "weight"}, false},
+		{"int key from float", HasKeyExpression{Field: "Scores", Key: float64(3)}, true},
+		{"int key from string", HasKeyExpression{Field: "Scores", Key: "3"}, true},
+		{"fractional key", HasKeyExpression{Field: "Scores", Key: 3.5}, false},
+		{"not a map", HasKeyExpression{Field: "Name", Key: "x"}, false},
+		{"missing field", HasKeyExpression{Field: "Other", Key: "x"}, false},
+		{"nil key", HasKeyExpression{Field: "Attrs"}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHasKeyExpressionJSON(t *testing.T) {
+	var q Query
+	if err := json.Unmarshal([]byte(`{"Expression":{"Type":"HasKey","Expression":{"Field":"address","Key":"zip"}}}`), &q); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"address":{"zip":null}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := q.Evaluate(doc); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"HasKey","Expression":{"Field":"address","Key":"zip"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+}
@@ -0,0 +1,61 @@
+package evaluator
+
+import "reflect"
+
+// InExpression succeeds when Field equals any of Values. Numbers are compared
+// by value, so an int field matches 18, 18.0 or json.Number("18"), in the
+// same way as the comparison expressions.
+type InExpression struct {
+	Field  string
+	Values []interface{}
+}
+
+func (e InExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || !f.IsValid() || !f.CanInterface() {
+		return false, nil
+	}
+	for _, want := range e.Values {
+		eq, err := inEqual(f, want, opts)
+		if err != nil {
+			return false, err
+		}
+		if eq {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// inEqual reports whether f equals want using the IsExpression rules with
+// numeric coercion added for numeric fields.
+func inEqual(f reflect.Value, want interface{}, opts []any) (bool, error) {
+	if c, ok, err := moneyCompare(f, want, opts); ok {
+		return err == nil && c == 0, err
+	}
+	if want == nil {
+		switch f.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			return f.IsNil(), nil
+		}
+		return false, nil
+	}
+	fv := f.Interface()
+	if reflect.DeepEqual(fv, want) {
+		return true, nil
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if n, ok := numeric[float64](want); ok {
+			fn, _ := numeric[float64](fv)
+			return fn == n, nil
+		}
+	}
+	return stringValue(fv) == stringValue(want), nil
+}
@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInExpression(t *testing.T) {
+	type rec struct {
+		Age    int
+		Status string
+		Score  float64
+		Ref    *string
+	}
+	tests := []struct {
+		name string
+		e    InExpression
+		want bool
+	}{
+		{"string", InExpression{Field: "Status", Values: []interface{}{"new", "active"}}, true},
+		{"string miss", InExpression{Field: "Status", Values: []interface{}{"new", "closed"}}, false},
+		{"int as float", InExpression{Field: "Age", Values: []interface{}{float64(30), float64(40)}}, true},
+		{"int as json.Number", InExpression{Field: "Age", Values: []interface{}{json.Number("3e1")}}, true},
+		{"float as int", InExpression{Field: "Score", Values: []interface{}{1, 2}}, true},
+		{"float as string", InExpression{Field: "Score", Values: []interface{}{"2.0"}}, true},
+		{"nil", InExpression{Field: "Ref", Values: []interface{}{"x", nil}}, true},
+		{"empty", InExpression{Field: "Status"}, false},
+		{"missing field", InExpression{Field: "Other", Values: []interface{}{"active"}}, false},
+	}
+	r := &rec{Age: 30, Status: "active", Score: 2}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInExpressionJSON(t *testing.T) {
+	q := Query{Expression: &InExpression{Field: "Age", Values: []interface{}{18, "21"}}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"In","Expression":{"Field":"Age","Values":[18,"21"]}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for _, age := range []int{18, 21} {
+		if ok, err := back.Evaluate(map[string]interface{}{"Age": age}); err != nil || !ok {
+			t.Errorf("age %d: got %v, %v", age, ok, err)
+		}
+	}
+	if ok, _ := back.Evaluate(map[string]interface{}{"Age": 20}); ok {
+		t.Errorf("age 20 matched")
+	}
+}
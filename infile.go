@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"bufio"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// InFileExpression succeeds when the string form of Field is one of the
+// values listed, one per line, in the file at Path. Blank lines are ignored.
+// The file is read on first evaluation and cached. With Bloom set the values
+// are kept in a bloom filter instead of a set, using a small fraction of the
+// memory for very large lists at the cost of rare false positives.
+type InFileExpression struct {
+	Field string
+	Path  string
+	Bloom bool `json:",omitempty"`
+
+	once sync.Once
+	set  memberSet
+	err  error
+}
+
+type memberSet interface {
+	has(s string) bool
+}
+
+type stringSet map[string]struct{}
+
+func (s stringSet) has(v string) bool {
+	_, ok := s[v]
+	return ok
+}
+
+func (e *InFileExpression) load() (memberSet, error) {
+	e.once.Do(func() {
+		e.set, e.err = loadMemberSet(e.Path, e.Bloom)
+	})
+	return e.set, e.err
+}
+
+func (e *InFileExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	set, err := e.load()
+	if err != nil {
+		return false, err
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || !f.IsValid() || !f.CanInterface() {
+		return false, nil
+	}
+	return set.has(stringValue(f.Interface())), nil
+}
+
+func loadMemberSet(path string, bloom bool) (memberSet, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	if !bloom {
+		set := stringSet{}
+		err := scanMembers(fh, func(v string) { set[v] = struct{}{} })
+		return set, err
+	}
+	// The filter is sized from a first pass counting the values, so that
+	// files too large to hold in memory are never buffered.
+	n := 0
+	if err := scanMembers(fh, func(string) { n++ }); err != nil {
+		return nil, err
+	}
+	if _, err := fh.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b := newBloomFilter(n, 0.001)
+	if err := scanMembers(fh, b.add); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// scanMembers calls fn with each non-blank line of r, trimmed of spaces.
+func scanMembers(r io.Reader, fn func(string)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			fn(line)
+		}
+	}
+	return sc.Err()
+}
+
+// bloomFilter is a fixed size bloom filter using double hashing.
+type bloomFilter struct {
+	bits []uint64
+	k    uint64
+}
+
+// newBloomFilter sizes a filter for n values at false positive rate p.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+	return &bloomFilter{bits: make([]uint64, (uint64(m)+63)/64), k: uint64(k)}
+}
+
+func (b *bloomFilter) hashes(s string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	h1 := h.Sum64()
+	return h1, h1>>33 | h1<<31 | 1
+}
+
+func (b *bloomFilter) add(s string) {
+	h1, h2 := b.hashes(s)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *bloomFilter) has(s string) bool {
+	h1, h2 := b.hashes(s)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInFileExpression(t *testing.T) {
+	path := writeList(t, "10.0.0.1", "", "  42  ", "bob", "1234567", "0.5")
+	for _, bloom := range []bool{false, true} {
+		e := &InFileExpression{Field: "V", Path: path, Bloom: bloom}
+		for v, want := range map[interface{}]bool{
+			"10.0.0.1":  true,
+			"bob":       true,
+			42:          true,
+			float64(42): true,
+			// Numbers decoded from JSON are float64 and must not be
+			// written as 1.234567e+06.
+			float64(1234567): true,
+			float32(0.5):     true,
+			"alice":          false,
+			"":               false,
+		} {
+			got, err := e.Evaluate(map[string]interface{}{"V": v})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("bloom=%v %v: got %v, want %v", bloom, v, got, want)
+			}
+		}
+		if got, _ := e.Evaluate(map[string]interface{}{}); got {
+			t.Errorf("missing field matched")
+		}
+	}
+}
+
+func TestInFileExpressionMissingFile(t *testing.T) {
+	e := &InFileExpression{Field: "V", Path: filepath.Join(t.TempDir(), "none")}
+	if _, err := e.Evaluate(map[string]interface{}{"V": "x"}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestBloomFilterFalsePositives(t *testing.T) {
+	b := newBloomFilter(10000, 0.001)
+	for i := 0; i < 10000; i++ {
+		b.add(fmt.Sprint("in-", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if !b.has(fmt.Sprint("in-", i)) {
+			t.Fatalf("false negative for %d", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		if b.has(fmt.Sprint("out-", i)) {
+			fp++
+		}
+	}
+	if fp > 50 {
+		t.Errorf("%d false positives in 10000", fp)
+	}
+}
+
+func TestInFileExpressionJSON(t *testing.T) {
+	q := Query{Expression: &InFileExpression{Field: "IP", Path: "deny.txt"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"InFile","Expression":{"Field":"IP","Path":"deny.txt"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := back.Expression.(*InFileExpression); !ok || e.Path != "deny.txt" {
+		t.Errorf("unexpected expression %#v", back.Expression)
+	}
+}
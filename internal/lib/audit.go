@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"time"
+)
+
+// auditEnv names the environment variable that turns on the audit log for the
+// filter and test commands. It holds the path of a file that each invocation
+// appends a JSON line to, or "syslog" to send the line to the system logger.
+const auditEnv = "EVALUATOR_AUDIT_LOG"
+
+// auditRecord is the line written for an invocation.
+type auditRecord struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Host       string    `json:"host,omitempty"`
+	Command    string    `json:"command"`
+	Args       []string  `json:"args"`
+	Expression string    `json:"expression,omitempty"`
+	Files      []string  `json:"files,omitempty"`
+	Records    int64     `json:"records"`
+	Matched    int64     `json:"matched"`
+	DurationMS int64     `json:"duration_ms"`
+	Exit       int       `json:"exit"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// audit records one invocation of a command. Commands count what they read
+// in records and matched, or set tally when something else keeps the counts,
+// and end through done, fatal or exit so that the record is written however
+// they finish. Without auditEnv set nothing is written.
+type audit struct {
+	w       io.WriteCloser
+	rec     auditRecord
+	records int64
+	matched int64
+	tally   func() (records, matched int64)
+}
+
+// startAudit opens the audit log, if one is configured, for an invocation of
+// command. A log that cannot be opened stops the command before it reads
+// anything.
+func startAudit(command, expr string, files []string) *audit {
+	a := &audit{rec: auditRecord{
+		Time:       time.Now().UTC(),
+		Command:    command,
+		Args:       os.Args[1:],
+		Expression: expr,
+		Files:      files,
+	}}
+	dest := os.Getenv(auditEnv)
+	if dest == "" {
+		return a
+	}
+	var err error
+	if dest == "syslog" {
+		a.w, err = openSyslog()
+	} else {
+		a.w, err = os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	}
+	if err != nil {
+		log.Fatalf("audit: %v", err)
+	}
+	a.rec.User = currentUser()
+	a.rec.Host, _ = os.Hostname()
+	return a
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// write appends the record with the exit status and error message given.
+func (a *audit) write(code int, msg string) {
+	if a.w == nil {
+		return
+	}
+	a.rec.Records, a.rec.Matched = a.records, a.matched
+	if a.tally != nil {
+		a.rec.Records, a.rec.Matched = a.tally()
+	}
+	a.rec.DurationMS = time.Since(a.rec.Time).Milliseconds()
+	a.rec.Exit, a.rec.Error = code, msg
+	// Expressions are kept readable rather than escaped for HTML.
+	var line bytes.Buffer
+	enc := json.NewEncoder(&line)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(a.rec)
+	if err == nil {
+		_, err = a.w.Write(line.Bytes())
+	}
+	if cerr := a.w.Close(); err == nil {
+		err = cerr
+	}
+	a.w = nil
+	if err != nil {
+		log.Fatalf("audit: %v", err)
+	}
+}
+
+// done records a successful invocation.
+func (a *audit) done() {
+	a.write(0, "")
+}
+
+// fatal records the failure and then exits as log.Fatal does.
+func (a *audit) fatal(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	a.write(1, msg)
+	log.Fatal(msg)
+}
+
+// fatalf is fatal with a format.
+func (a *audit) fatalf(format string, v ...interface{}) {
+	a.fatal(fmt.Sprintf(format, v...))
+}
+
+// exit records the exit status and then exits, as the test commands do when
+// a document does not match.
+func (a *audit) exit(code int) {
+	a.write(code, "")
+	os.Exit(code)
+}
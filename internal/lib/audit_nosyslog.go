@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package lib
+
+import (
+	"errors"
+	"io"
+)
+
+// openSyslog fails, as there is no system logger to send the audit log to;
+// set the audit log to a file instead.
+func openSyslog() (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"errors"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// errNoColumn is returned by csvRow.Get for a field that is not a column of
+// the current row. It is shared so that missing fields cost no allocation.
+var errNoColumn = errors.New("no such column")
+
+// csvRow presents a CSV record to the evaluator as a Getter, reading cells
+// straight from the record by column index instead of copying them into a
+// map for every row. Cells are coerced to numbers at most once per row, on
+// first use.
+type csvRow struct {
+	index   map[string]int
+	numbers *evaluator.NumberFormat
+	rec     []string
+	vals    []interface{}
+	done    []bool
+}
+
+// newCSVRow returns a csvRow resolving the headers at cols, which csvColumns
+// chooses. A header repeated in cols resolves to its last column, as it did
+// when rows were maps.
+func newCSVRow(headers []string, cols []int, numbers *evaluator.NumberFormat) *csvRow {
+	r := &csvRow{
+		index:   make(map[string]int, len(cols)),
+		numbers: numbers,
+		vals:    make([]interface{}, len(headers)),
+		done:    make([]bool, len(headers)),
+	}
+	for _, i := range cols {
+		r.index[headers[i]] = i
+	}
+	return r
+}
+
+// reset makes rec the current row.
+func (r *csvRow) reset(rec []string) {
+	r.rec = rec
+	clear(r.done)
+}
+
+func (r *csvRow) Get(name string) (interface{}, error) {
+	i, ok := r.index[name]
+	if !ok || i >= len(r.rec) {
+		return nil, errNoColumn
+	}
+	if r.done[i] {
+		return r.vals[i], nil
+	}
+	var v interface{} = r.rec[i]
+	if r.numbers != nil {
+		if f, ok := r.numbers.Parse(r.rec[i]); ok {
+			v = f
+		}
+	}
+	r.vals[i], r.done[i] = v, true
+	return v, nil
+}
@@ -0,0 +1,267 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/store"
+)
+
+// doctorConfig holds what Doctor checks the stored queries against. Empty
+// fields skip the corresponding check.
+type doctorConfig struct {
+	hashes  map[string]string
+	fields  map[string]struct{}
+	samples []map[string]interface{}
+}
+
+// Doctor checks every query in the store directory: that it loads, that it
+// only refers to fields in schema, that its hash matches the one recorded in
+// hashes and that it evaluates against each sample record without error. It
+// prints a report and exits with status 1 when any check fails.
+func Doctor(storeDir, keyFile, hashes, schema, samples string) {
+	if storeDir == "" {
+		log.Fatal("-store is required")
+	}
+	d := &store.Dir{Path: storeDir}
+	if keyFile != "" {
+		key, err := readKey(keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.Sealer = evaluator.AESGCM{Key: key}
+	}
+	var cfg doctorConfig
+	var err error
+	if hashes != "" {
+		if err = readJSONFile(hashes, &cfg.hashes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if schema != "" {
+		if cfg.fields, err = readSchema(schema); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if samples != "" {
+		if cfg.samples, err = readSamples(samples); err != nil {
+			log.Fatal(err)
+		}
+	}
+	ok, err := doctor(os.Stdout, d, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// doctor writes a line per stored query to w and reports whether all of
+// them passed.
+func doctor(w io.Writer, s store.Store, cfg doctorConfig) (bool, error) {
+	names, err := s.List()
+	if err != nil {
+		return false, err
+	}
+	failed := 0
+	for _, name := range names {
+		status, problems := checkQuery(s, name, cfg)
+		if len(problems) > 0 {
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %s\n", name, strings.Join(problems, "; "))
+			continue
+		}
+		fmt.Fprintf(w, "ok   %s%s\n", name, status)
+	}
+	var orphans []string
+	for name := range cfg.hashes {
+		if i := sort.SearchStrings(names, name); i == len(names) || names[i] != name {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+	for _, name := range orphans {
+		failed++
+		fmt.Fprintf(w, "FAIL %s: hash recorded but query is missing\n", name)
+	}
+	fmt.Fprintf(w, "%d queries, %d failed\n", len(names), failed)
+	return failed == 0, nil
+}
+
+// checkQuery returns a short status for a healthy query and the problems
+// found with an unhealthy one.
+func checkQuery(s store.Store, name string, cfg doctorConfig) (string, []string) {
+	q, err := s.Load(name)
+	if err != nil {
+		return "", []string{err.Error()}
+	}
+	var problems []string
+	if cfg.hashes != nil {
+		if want, ok := cfg.hashes[name]; !ok {
+			problems = append(problems, "no recorded hash")
+		} else if err := evaluator.VerifyHash(q, want); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if cfg.fields != nil {
+		for _, f := range referencedFields(q.Expression) {
+			if _, ok := cfg.fields[f]; !ok {
+				problems = append(problems, fmt.Sprintf("unknown field %q", f))
+			}
+		}
+	}
+	if len(cfg.samples) == 0 {
+		return "", problems
+	}
+	matched := 0
+	for i, rec := range cfg.samples {
+		ok, err := q.Evaluate(rec)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("sample %d: %v", i+1, err))
+			break
+		}
+		if ok {
+			matched++
+		}
+	}
+	return fmt.Sprintf(" (matched %d/%d samples)", matched, len(cfg.samples)), problems
+}
+
+// referencedFields returns the sorted, distinct field names used by e. It
+// looks for strings named like Field, KeyField or LatField on each
+// expression and term and for Field terms, descending into And, Or, Not and
+// comparisons.
+func referencedFields(e evaluator.Expression) []string {
+	seen := map[string]struct{}{}
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			if f, ok := v.Interface().(evaluator.Field); ok {
+				seen[f.Name] = struct{}{}
+				return
+			}
+			// The sub-queries of Any, All and Count address list elements,
+			// not the record; FieldCompare names two fields.
+			switch q := v.Interface().(type) {
+			case evaluator.AnyExpression:
+				seen[q.Field] = struct{}{}
+				return
+			case evaluator.AllExpression:
+				seen[q.Field] = struct{}{}
+				return
+			case evaluator.CountExpression:
+				seen[q.Field] = struct{}{}
+				return
+			case evaluator.FieldCompareExpression:
+				seen[q.FieldA] = struct{}{}
+				seen[q.FieldB] = struct{}{}
+				return
+			}
+			for i := 0; i < v.NumField(); i++ {
+				sf := v.Type().Field(i)
+				if !sf.IsExported() || sf.Name == "ExpressionRawJSON" || sf.Name == "Metadata" {
+					continue
+				}
+				if strings.HasSuffix(sf.Name, "Field") && sf.Type.Kind() == reflect.String {
+					seen[v.Field(i).String()] = struct{}{}
+					continue
+				}
+				walk(v.Field(i))
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(e))
+	out := make([]string, 0, len(seen))
+	for f := range seen {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func readKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// readSchema reads a JSON object whose keys are the known field names, such
+// as {"Name": "string", "Age": "number"}. Nested objects also contribute
+// their dotted paths.
+func readSchema(path string) (map[string]struct{}, error) {
+	var schema map[string]interface{}
+	if err := readJSONFile(path, &schema); err != nil {
+		return nil, err
+	}
+	fields := map[string]struct{}{}
+	var add func(prefix string, m map[string]interface{})
+	add = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			fields[prefix+k] = struct{}{}
+			if nested, ok := v.(map[string]interface{}); ok {
+				add(prefix+k+".", nested)
+			}
+		}
+	}
+	add("", schema)
+	return fields, nil
+}
+
+func readSamples(path string) ([]map[string]interface{}, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var out []map[string]interface{}
+	dec := json.NewDecoder(bufio.NewReader(fh))
+	for {
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			if errors.Is(err, io.EOF) {
+				return out, nil
+			}
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		out = append(out, m)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package lib
+
+import "log"
+
+// EventLogFilter is only supported on Windows, where the event log is.
+func EventLogFilter(name string, expr string, ignoreCase bool, xpath string, count int, newest bool, stats bool) {
+	log.Fatal("eventlogfilter is only supported on Windows")
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/arran4/go-evaluator/eventlog"
+	"github.com/arran4/go-evaluator/stream"
+)
+
+// EventLogFilter writes the records of the Windows event log name matching
+// the expression to stdout as JSON Lines. xpath is a filter applied by the
+// event log service before the expression; count and newest limit and order
+// the records read.
+func EventLogFilter(name string, expr string, ignoreCase bool, xpath string, count int, newest bool, stats bool) {
+	a := startAudit("eventlogfilter", expr, nil)
+	q, err := filterQuery(expr, ignoreCase, "", "", false, false)
+	if err != nil {
+		a.fatal(err)
+	}
+	if name == "" {
+		name = "System"
+	}
+	r := &eventlog.Reader{Log: name, Query: xpath, Count: count, Newest: newest}
+	sink := stream.NewWriterSink(os.Stdout)
+	var records, matched int64
+	a.tally = func() (int64, int64) { return records, matched }
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = r.Run(ctx, func(rec eventlog.Record) error {
+		records++
+		ok := true
+		if q.Expression != nil {
+			var err error
+			if ok, err = q.Evaluate(map[string]interface{}(rec)); err != nil {
+				return err
+			}
+		}
+		if !ok {
+			return nil
+		}
+		matched++
+		return sink.Write(stream.Record(rec))
+	})
+	if ferr := sink.Flush(); err == nil {
+		err = ferr
+	}
+	if stats {
+		fmt.Fprintf(os.Stderr, "records=%d matched=%d\n", records, matched)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		a.fatal(err)
+	}
+	a.done()
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/arran4/go-evaluator/journal"
+	"github.com/arran4/go-evaluator/stream"
+)
+
+// JournalFilter writes the systemd journal entries matching the expression to
+// stdout as JSON Lines. units is a comma-separated list of units; matches are
+// passed to journalctl. With follow it runs until interrupted, writing each
+// match as it arrives.
+func JournalFilter(expr string, ignoreCase bool, follow bool, units string, since string, lines int, stats bool, matches ...string) {
+	a := startAudit("journalfilter", expr, matches)
+	q, err := filterQuery(expr, ignoreCase, "", "", false, false)
+	if err != nil {
+		a.fatal(err)
+	}
+	r := &journal.Reader{Follow: follow, Since: since, Lines: lines, Args: matches}
+	for _, u := range strings.Split(units, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			r.Units = append(r.Units, u)
+		}
+	}
+	sink := stream.NewWriterSink(os.Stdout)
+	var entries, matched int64
+	a.tally = func() (int64, int64) { return entries, matched }
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = r.Run(ctx, func(e journal.Entry) error {
+		entries++
+		ok := true
+		if q.Expression != nil {
+			var err error
+			if ok, err = q.Evaluate(map[string]interface{}(e)); err != nil {
+				return err
+			}
+		}
+		if !ok {
+			return nil
+		}
+		matched++
+		if err := sink.Write(stream.Record(e)); err != nil {
+			return err
+		}
+		if follow {
+			return sink.Flush()
+		}
+		return nil
+	})
+	if ferr := sink.Flush(); err == nil {
+		err = ferr
+	}
+	if stats {
+		fmt.Fprintf(os.Stderr, "entries=%d matched=%d\n", entries, matched)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		a.fatal(err)
+	}
+	a.done()
+}
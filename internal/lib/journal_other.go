@@ -0,0 +1,10 @@
+//go:build !linux
+
+package lib
+
+import "log"
+
+// JournalFilter is only supported on Linux, where the systemd journal is.
+func JournalFilter(expr string, ignoreCase bool, follow bool, units string, since string, lines int, stats bool, matches ...string) {
+	log.Fatal("journalfilter is only supported on Linux")
+}
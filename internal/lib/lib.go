@@ -1,61 +1,228 @@
 package lib
 
 import (
+	"bufio"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/anonymize"
+	"github.com/arran4/go-evaluator/mqttfilter"
+	"github.com/arran4/go-evaluator/natsfilter"
 	"github.com/arran4/go-evaluator/parser/simple"
+	"github.com/arran4/go-evaluator/pcap"
+	"github.com/arran4/go-evaluator/redisfilter"
+	"github.com/arran4/go-evaluator/stream"
+	"github.com/arran4/go-evaluator/webhookroute"
 )
 
-// CsvFilter filters CSV rows matching the expression.
-func CsvFilter(expr string, files ...string) {
-	if expr == "" {
-		log.Fatal("-e expression required")
-	}
-	q, err := simple.Parse(expr)
+// csvOptions controls how CSV records are turned into evaluation values.
+type csvOptions struct {
+	// numbers, when set, coerces cells that are numbers in this format to
+	// float64 before evaluation.
+	numbers *evaluator.NumberFormat
+	// classifiers add a true or false column per query to each row.
+	classifiers []stream.Classifier
+	// audit, when set, counts the rows read and matched.
+	audit *audit
+}
+
+// CsvFilter filters CSV rows matching the expression. When locale is set,
+// cells holding numbers written in that locale are compared numerically.
+// inFile and notInFile restrict rows by value lists; see withFileSets.
+// ignoreCase compares strings case-insensitively. classify names a file of
+// queries whose results are appended as columns; see loadClassifiers.
+func CsvFilter(expr string, ignoreCase bool, locale string, inFile string, notInFile string, bloom bool, classify string, files ...string) {
+	a := startAudit("csvfilter", expr, files)
+	opts := csvOptions{audit: a}
+	q, err := filterQuery(expr, ignoreCase, inFile, notInFile, bloom, classify != "")
 	if err != nil {
-		log.Fatalf("parse expression: %v", err)
+		a.fatal(err)
+	}
+	if classify != "" {
+		if opts.classifiers, err = loadClassifiers(classify, ignoreCase); err != nil {
+			a.fatal(err)
+		}
+	}
+	if locale != "" {
+		nf, err := evaluator.LookupNumberFormat(locale)
+		if err != nil {
+			a.fatal(err)
+		}
+		opts.numbers = &nf
 	}
 	writeHeader := true
 	if len(files) == 0 {
-		if err := processCSV(os.Stdin, os.Stdout, q, &writeHeader); err != nil {
-			log.Fatal(err)
+		if err := processCSV(os.Stdin, os.Stdout, q, &writeHeader, opts); err != nil {
+			a.fatal(err)
 		}
+		a.done()
 		return
 	}
 	for _, f := range files {
 		fh, err := os.Open(f)
 		if err != nil {
-			log.Fatal(err)
+			a.fatal(err)
 		}
-		if err := processCSV(fh, os.Stdout, q, &writeHeader); err != nil {
+		if err := processCSV(fh, os.Stdout, q, &writeHeader, opts); err != nil {
 			_ = fh.Close()
-			log.Fatal(err)
+			a.fatal(err)
 		}
 		_ = fh.Close()
 	}
+	a.done()
+}
+
+// filterQuery builds the query for the filter commands from the expression
+// and the -in-file and -not-in-file lists. At least one of them is required
+// unless classifying, when an empty query keeps every record. With
+// ignoreCase the expression compares strings case-insensitively.
+func filterQuery(expr string, ignoreCase bool, inFile, notInFile string, bloom bool, classifying bool) (evaluator.Query, error) {
+	if expr == "" && inFile == "" && notInFile == "" {
+		if classifying {
+			return evaluator.Query{}, nil
+		}
+		return evaluator.Query{}, errors.New("-e expression required")
+	}
+	var parts []evaluator.Query
+	if expr != "" {
+		q, err := simple.Parse(expr)
+		if err != nil {
+			return evaluator.Query{}, fmt.Errorf("parse expression: %w", err)
+		}
+		if ignoreCase {
+			q = evaluator.FoldCase(q)
+		}
+		parts = append(parts, q)
+	}
+	sets, err := fileSets(inFile, bloom)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	parts = append(parts, sets...)
+	sets, err = fileSets(notInFile, bloom)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	for _, q := range sets {
+		parts = append(parts, evaluator.Query{Expression: &evaluator.NotExpression{Expression: q}})
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return evaluator.Query{Expression: &evaluator.AndExpression{Expressions: parts}}, nil
 }
 
-func processCSV(r io.Reader, w io.Writer, q evaluator.Query, writeHeader *bool) error {
+// loadClassifiers reads the queries for -classify from path, one NAME=EXPRESSION
+// per line. Blank lines and lines starting with # are skipped.
+func loadClassifiers(path string, ignoreCase bool) ([]stream.Classifier, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var cs []stream.Classifier
+	sc := bufio.NewScanner(fh)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, expr, ok := strings.Cut(text, "=")
+		name, expr = strings.TrimSpace(name), strings.TrimSpace(expr)
+		if !ok || !validClassName(name) || expr == "" {
+			return nil, fmt.Errorf("%s:%d: expected NAME=EXPRESSION", path, line)
+		}
+		q, err := simple.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: parse expression: %w", path, line, err)
+		}
+		if ignoreCase {
+			q = evaluator.FoldCase(q)
+		}
+		cs = append(cs, stream.Classifier{Name: name, Query: q})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, fmt.Errorf("%s: no queries", path)
+	}
+	return cs, nil
+}
+
+// validClassName reports whether name is a single word of letters, digits,
+// '_', '-' or '.', so that a line missing its name, such as age >= 18, is not
+// mistaken for one.
+func validClassName(name string) bool {
+	return name != "" && strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_-.", r)
+	}) < 0
+}
+
+// fileSets parses FIELD=PATH specs separated by semicolons into InFile
+// expressions.
+func fileSets(specs string, bloom bool) ([]evaluator.Query, error) {
+	var qs []evaluator.Query
+	for _, spec := range strings.Split(specs, ";") {
+		if spec == "" {
+			continue
+		}
+		field, path, ok := strings.Cut(spec, "=")
+		if !ok || field == "" || path == "" {
+			return nil, fmt.Errorf("file set %q: expected FIELD=PATH", spec)
+		}
+		qs = append(qs, evaluator.Query{Expression: &evaluator.InFileExpression{Field: field, Path: path, Bloom: bloom}})
+	}
+	return qs, nil
+}
+
+func processCSV(r io.Reader, w io.Writer, q evaluator.Query, writeHeader *bool, opts csvOptions) error {
 	cr := csv.NewReader(r)
 	headers, err := cr.Read()
 	if err != nil {
 		return err
 	}
+	// Rows are done with before the next is read, so the reader may reuse
+	// them; headers are kept and so read first.
+	cr.ReuseRecord = true
 	cw := csv.NewWriter(w)
 	if *writeHeader {
-		if err := cw.Write(headers); err != nil {
+		out := headers
+		for _, c := range opts.classifiers {
+			out = append(out[:len(out):len(out)], c.Name)
+		}
+		if err := cw.Write(out); err != nil {
 			return err
 		}
 		*writeHeader = false
 	}
-	m := make(map[string]interface{}, len(headers))
+	classes := make([]evaluator.Query, len(opts.classifiers))
+	for n, c := range opts.classifiers {
+		classes[n] = c.Query
+	}
+	var classErr error
+	onError := evaluator.ErrorHandler(func(err error) {
+		if classErr == nil {
+			classErr = err
+		}
+	})
+	row := newCSVRow(headers, csvColumns(headers, append(classes, q)...), opts.numbers)
 	for {
 		rec, err := cr.Read()
 		if err == io.EOF {
@@ -64,37 +231,400 @@ func processCSV(r io.Reader, w io.Writer, q evaluator.Query, writeHeader *bool)
 		if err != nil {
 			return err
 		}
-		clear(m)
-		for i, h := range headers {
-			if i < len(rec) {
-				m[h] = rec[i]
+		if opts.audit != nil {
+			opts.audit.records++
+		}
+		row.reset(rec)
+		matched := q.Expression == nil
+		if !matched {
+			if matched, err = q.Evaluate(row); err != nil {
+				return err
 			}
 		}
-		matched, err := q.Evaluate(m)
-		if err != nil {
-			return err
+		if !matched {
+			continue
 		}
-		if matched {
-			if err := cw.Write(rec); err != nil {
-				return err
+		if opts.audit != nil {
+			opts.audit.matched++
+		}
+		if len(classes) > 0 {
+			bits := evaluator.EvaluateAll(classes, row, onError)
+			if classErr != nil {
+				return fmt.Errorf("classify: %w", classErr)
+			}
+			for n := range classes {
+				rec = append(rec, strconv.FormatBool(bits.Has(n)))
 			}
 		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
 	}
 	cw.Flush()
 	return cw.Error()
 }
 
-// JsonlFilter filters JSON Lines records matching the expression.
-func JsonlFilter(expr string, files ...string) {
-	if expr == "" {
-		log.Fatal("-e expression required")
+// csvColumns returns the indexes of the headers that qs refer to, either by
+// name or as the first segment of a path such as addr.city, so that only
+// those cells are resolved for each row.
+func csvColumns(headers []string, qs ...evaluator.Query) []int {
+	used := map[string]struct{}{}
+	for _, q := range qs {
+		for _, f := range referencedFields(q.Expression) {
+			used[f] = struct{}{}
+			if i := strings.IndexAny(f, ".["); i > 0 {
+				used[f[:i]] = struct{}{}
+			}
+		}
 	}
-	q, err := simple.Parse(expr)
+	var cols []int
+	for i, h := range headers {
+		if _, ok := used[h]; ok {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
+
+// JsonlFilter filters JSON Lines records matching the expression. Record
+// size, in-flight records and total buffered bytes can be bounded so that
+// oversized input fails with an error rather than exhausting memory. When
+// post is set matches are sent to that URL in batches instead of stdout.
+// lookup lists NAME=FIELD:FILE tables, separated by semicolons, that are
+// joined to each record before evaluation. ignoreCase compares strings
+// case-insensitively. classify names a file of queries whose results are
+// added to each record as boolean fields; see loadClassifiers.
+func JsonlFilter(expr string, ignoreCase bool, maxRecordSize int, maxInFlight int, memoryBudget int, workers int, stats bool, post string, batch int, lookup string, inFile string, notInFile string, bloom bool, classify string, files ...string) {
+	a := startAudit("jsonlfilter", expr, files)
+	q, err := filterQuery(expr, ignoreCase, inFile, notInFile, bloom, classify != "")
+	if err != nil {
+		a.fatal(err)
+	}
+	f := &stream.Filter{
+		Query: q,
+		Limits: stream.Limits{
+			MaxRecordSize: int64(maxRecordSize),
+			MaxInFlight:   maxInFlight,
+			MemoryBudget:  int64(memoryBudget),
+		},
+		Workers: workers,
+	}
+	if classify != "" {
+		if f.Classifiers, err = loadClassifiers(classify, ignoreCase); err != nil {
+			a.fatal(err)
+		}
+	}
+	for _, spec := range strings.Split(lookup, ";") {
+		if spec == "" {
+			continue
+		}
+		l, err := stream.ParseLookup(spec)
+		if err != nil {
+			a.fatal(err)
+		}
+		f.Lookups = append(f.Lookups, l)
+	}
+	a.tally = func() (int64, int64) {
+		s := f.Stats()
+		return s.Records, s.Matched
+	}
+	if stats {
+		defer printStats(os.Stderr, f.Stats)
+	}
+	var sink stream.Sink = stream.NewWriterSink(os.Stdout)
+	if post != "" {
+		sink = stream.Batch(&stream.HTTPSink{URL: post}, batch)
+	}
+	if len(files) == 0 {
+		if err := processJSONL(os.Stdin, sink, f); err != nil {
+			a.fatal(err)
+		}
+		a.done()
+		return
+	}
+	for _, fn := range files {
+		fh, err := os.Open(fn)
+		if err != nil {
+			a.fatal(err)
+		}
+		if err := processJSONL(fh, sink, f); err != nil {
+			_ = fh.Close()
+			a.fatal(err)
+		}
+		_ = fh.Close()
+	}
+	a.done()
+}
+
+func processJSONL(r io.Reader, s stream.Sink, f *stream.Filter) error {
+	return f.Run(r, s)
+}
+
+func printStats(w io.Writer, stats func() stream.Stats) {
+	s := stats()
+	fmt.Fprintf(w, "records=%d matched=%d bytes=%d peak_in_flight=%d peak_memory=%d stalls=%d\n",
+		s.Records, s.Matched, s.Bytes, s.PeakInFlight, s.PeakMemory, s.Stalls)
+}
+
+// RedisFilter republishes the JSON messages read from the Redis channel or
+// stream from that match the expression to to, until interrupted. Endpoints
+// are written channel:NAME or stream:NAME; field names the stream entry
+// field holding the payload. Lost connections are retried.
+func RedisFilter(addr string, password string, from string, to string, field string, expr string, ignoreCase bool, stats bool) {
+	q, err := filterQuery(expr, ignoreCase, "", "", false, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	b := &redisfilter.Bridge{Addr: addr, Password: password, Field: field, Query: q, Logf: log.Printf}
+	if b.From, err = redisfilter.ParseEndpoint(from); err != nil {
+		log.Fatal(err)
+	}
+	if b.To, err = redisfilter.ParseEndpoint(to); err != nil {
+		log.Fatal(err)
+	}
+	if b.From == b.To {
+		log.Fatal("-from and -to must differ")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = b.Run(ctx)
+	if stats {
+		s := b.Stats()
+		fmt.Fprintf(os.Stderr, "received=%d matched=%d skipped=%d reconnects=%d\n", s.Received, s.Matched, s.Skipped, s.Reconnects)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
+
+// NatsFilter republishes the messages on subject, or from the JetStream pull
+// consumer named by stream and consumer, that match the expression to to,
+// until interrupted. With headers the expression sees the message headers
+// instead of the JSON payload. JetStream messages are acknowledged, using
+// noMatch (ack, nak or term) for those that do not match.
+func NatsFilter(server string, subject string, queue string, stream string, consumer string, to string, headers bool, noMatch string, expr string, ignoreCase bool, stats bool) {
+	q, err := filterQuery(expr, ignoreCase, "", "", false, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if server == "" {
+		server = "localhost:4222"
+	}
+	if (stream == "") != (consumer == "") {
+		log.Fatal("-stream and -consumer must be given together")
+	}
+	if consumer == "" && subject == "" {
+		log.Fatal("-subject or -consumer required")
+	}
+	b := &natsfilter.Bridge{
+		Server:   server,
+		Subject:  subject,
+		Queue:    queue,
+		Stream:   stream,
+		Consumer: consumer,
+		To:       to,
+		Headers:  headers,
+		Query:    q,
+		Logf:     log.Printf,
+	}
+	if noMatch != "" {
+		if b.NoMatch, err = natsfilter.ParseAction(noMatch); err != nil {
+			log.Fatal(err)
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = b.Run(ctx)
+	if stats {
+		s := b.Stats()
+		fmt.Fprintf(os.Stderr, "received=%d matched=%d skipped=%d reconnects=%d\n", s.Received, s.Matched, s.Skipped, s.Reconnects)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
+
+// MqttFilter forwards MQTT messages matching the rules in config until
+// interrupted.
+func MqttFilter(server string, config string, clientID string, username string, password string, ignoreCase bool, stats bool) {
+	if config == "" {
+		log.Fatal("-c config required")
+	}
+	data, err := os.ReadFile(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rules, err := mqttfilter.ParseRules(data, ignoreCase)
+	if err != nil {
+		log.Fatalf("%s: %v", config, err)
+	}
+	if server == "" {
+		server = "localhost:1883"
+	}
+	b := &mqttfilter.Bridge{
+		Server:   server,
+		ClientID: clientID,
+		Username: username,
+		Password: password,
+		Rules:    rules,
+		Logf:     log.Printf,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = b.Run(ctx)
+	if stats {
+		s := b.Stats()
+		fmt.Fprintf(os.Stderr, "received=%d forwarded=%d skipped=%d reconnects=%d\n", s.Received, s.Forwarded, s.Skipped, s.Reconnects)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
+
+// WebhookRoute serves webhooks on listen, forwarding each to the destinations
+// of the rules in config it matches, until interrupted. At most maxConcurrent
+// webhooks are handled at once when it is positive; others wait up to
+// queueTimeout, a duration such as 2s, and are then refused with 429.
+func WebhookRoute(listen string, config string, retries int, maxConcurrent int, queueTimeout string, stats bool) {
+	if config == "" {
+		log.Fatal("-c config required")
+	}
+	fh, err := os.Open(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg, err := webhookroute.LoadConfig(fh)
+	_ = fh.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", config, err)
+	}
+	if listen == "" {
+		listen = ":8080"
+	}
+	rt := &webhookroute.Router{Config: cfg, Retries: retries, MaxConcurrent: maxConcurrent, Logf: log.Printf}
+	if queueTimeout != "" {
+		if rt.QueueTimeout, err = time.ParseDuration(queueTimeout); err != nil {
+			log.Fatalf("-queue-timeout: %v", err)
+		}
+	}
+	srv := &http.Server{Addr: listen, Handler: rt, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+	err = srv.ListenAndServe()
+	if stats {
+		s := rt.Stats()
+		fmt.Fprintf(os.Stderr, "received=%d forwarded=%d unmatched=%d failed=%d rejected=%d peak_in_flight=%d\n", s.Received, s.Forwarded, s.Unmatched, s.Failed, s.Rejected, s.PeakInFlight)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// PcapFilter writes the packets of pcap captures matching the expression to
+// stdout as JSON Lines, reading stdin when no files are given. With flows the
+// packets are first aggregated and the expression selects flow records.
+// Matching packets read from stdin are flushed at once, so a live capture
+// piped from tcpdump -U -w - is filtered as it arrives.
+func PcapFilter(expr string, ignoreCase bool, flows bool, stats bool, files ...string) {
+	a := startAudit("pcapfilter", expr, files)
+	q, err := filterQuery(expr, ignoreCase, "", "", false, false)
 	if err != nil {
-		log.Fatalf("parse expression: %v", err)
+		a.fatal(err)
+	}
+	sink := stream.NewWriterSink(os.Stdout)
+	var packets, matched int64
+	a.tally = func() (int64, int64) { return packets, matched }
+	write := func(p pcap.Packet, flush bool) error {
+		if q.Expression != nil {
+			ok, err := q.Evaluate(map[string]interface{}(p))
+			if err != nil || !ok {
+				return err
+			}
+		}
+		matched++
+		if err := sink.Write(stream.Record(p)); err != nil {
+			return err
+		}
+		if flush {
+			return sink.Flush()
+		}
+		return nil
+	}
+	var fl pcap.Flows
+	process := func(r io.Reader, live bool) error {
+		return pcap.Decode(r, func(p pcap.Packet) error {
+			packets++
+			if flows {
+				fl.Add(p)
+				return nil
+			}
+			return write(p, live)
+		})
 	}
 	if len(files) == 0 {
-		if err := processJSONL(os.Stdin, q); err != nil {
+		err = process(os.Stdin, true)
+	}
+	for _, f := range files {
+		fh, ferr := os.Open(f)
+		if ferr != nil {
+			a.fatal(ferr)
+		}
+		err = process(fh, false)
+		_ = fh.Close()
+		if err != nil {
+			err = fmt.Errorf("%s: %w", f, err)
+			break
+		}
+	}
+	if err == nil && flows {
+		err = fl.Each(func(p pcap.Packet) error { return write(p, false) })
+	}
+	if ferr := sink.Flush(); err == nil {
+		err = ferr
+	}
+	if stats {
+		if flows {
+			fmt.Fprintf(os.Stderr, "packets=%d flows=%d matched=%d\n", packets, fl.Len(), matched)
+		} else {
+			fmt.Fprintf(os.Stderr, "packets=%d matched=%d\n", packets, matched)
+		}
+	}
+	if err != nil {
+		a.fatal(err)
+	}
+	a.done()
+}
+
+// Anonymize applies anonymization rules to JSON Lines records and writes the
+// results to stdout.
+func Anonymize(rules string, salt string, files ...string) {
+	if rules == "" {
+		log.Fatal("-r rules required")
+	}
+	rs, err := anonymize.ParseRules(rules)
+	if err != nil {
+		log.Fatalf("parse rules: %v", err)
+	}
+	if salt == "" {
+		for _, r := range rs {
+			if r.Action == anonymize.Hash {
+				log.Print("warning: hashing without -salt; hashed values can be recovered by hashing guesses")
+				break
+			}
+		}
+	}
+	a := &anonymize.Anonymizer{Rules: rs, Salt: salt}
+	if len(files) == 0 {
+		if err := processAnonymize(os.Stdin, os.Stdout, a); err != nil {
 			log.Fatal(err)
 		}
 		return
@@ -104,7 +634,7 @@ func JsonlFilter(expr string, files ...string) {
 		if err != nil {
 			log.Fatal(err)
 		}
-		if err := processJSONL(fh, q); err != nil {
+		if err := processAnonymize(fh, os.Stdout, a); err != nil {
 			_ = fh.Close()
 			log.Fatal(err)
 		}
@@ -112,66 +642,125 @@ func JsonlFilter(expr string, files ...string) {
 	}
 }
 
-func processJSONL(r io.Reader, q evaluator.Query) error {
+func processAnonymize(r io.Reader, w io.Writer, a *anonymize.Anonymizer) error {
 	dec := json.NewDecoder(r)
-	enc := json.NewEncoder(os.Stdout)
+	dec.UseNumber()
+	enc := json.NewEncoder(w)
 	for {
 		var m map[string]interface{}
 		if err := dec.Decode(&m); err != nil {
 			if err == io.EOF {
-				break
+				return nil
 			}
 			return err
 		}
-		matched, err := q.Evaluate(m)
-		if err != nil {
+		if err := a.Apply(m); err != nil {
 			return err
 		}
-		if matched {
-			if err := enc.Encode(m); err != nil {
-				return err
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+}
+
+// SetOp combines the keys of JSON Lines records matching two expressions
+// with a set operation and prints the resulting keys one per line. All
+// files are read once, as a single input.
+func SetOp(op string, key string, a string, b string, files ...string) {
+	if key == "" || a == "" || b == "" {
+		log.Fatal("-key, -a and -b required")
+	}
+	qa, err := simple.Parse(a)
+	if err != nil {
+		log.Fatalf("parse -a: %v", err)
+	}
+	qb, err := simple.Parse(b)
+	if err != nil {
+		log.Fatalf("parse -b: %v", err)
+	}
+	var r io.Reader = os.Stdin
+	if len(files) > 0 {
+		var readers []io.Reader
+		for _, f := range files {
+			fh, err := os.Open(f)
+			if err != nil {
+				log.Fatal(err)
 			}
+			defer fh.Close()
+			readers = append(readers, fh)
+		}
+		r = io.MultiReader(readers...)
+	}
+	if err := processSetOp(r, os.Stdout, stream.SetOp(op), key, qa, qb); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func processSetOp(r io.Reader, w io.Writer, op stream.SetOp, key string, a, b evaluator.Query) error {
+	sets, err := stream.CollectKeySets(r, key, a, b)
+	if err != nil {
+		return err
+	}
+	keys, err := sets.Result(op)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := fmt.Fprintln(w, k); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// JSONTest evaluates a JSON document against the expression.
-func JSONTest(expr string, files ...string) {
+// JSONTest evaluates a JSON document against the expression. When path is set
+// the document is streamed and the test passes if the value at path, or any
+// element of it when it is an array, matches.
+func JSONTest(expr string, path string, files ...string) {
+	a := startAudit("jsontest", expr, files)
 	if expr == "" {
-		log.Fatal("-e expression required")
+		a.fatal("-e expression required")
 	}
 	q, err := simple.Parse(expr)
 	if err != nil {
-		log.Fatalf("parse expression: %v", err)
+		a.fatalf("parse expression: %v", err)
 	}
 	if len(files) == 0 {
-		ok, err := evaluateJSON(os.Stdin, q)
+		ok, err := evaluateJSON(os.Stdin, q, path)
 		if err != nil {
-			log.Fatal(err)
+			a.fatal(err)
 		}
+		a.records++
 		if ok {
+			a.matched++
+			a.done()
 			return
 		}
-		os.Exit(1)
+		a.exit(1)
 	}
 	for _, f := range files {
 		fh, err := os.Open(f)
 		if err != nil {
-			log.Fatal(err)
+			a.fatal(err)
 		}
-		ok, err := evaluateJSON(fh, q)
+		ok, err := evaluateJSON(fh, q, path)
 		_ = fh.Close()
 		if err != nil {
-			log.Fatal(err)
+			a.fatal(err)
 		}
+		a.records++
 		if !ok {
-			os.Exit(1)
+			a.exit(1)
 		}
+		a.matched++
 	}
+	a.done()
 }
 
-func evaluateJSON(r io.Reader, q evaluator.Query) (bool, error) {
+func evaluateJSON(r io.Reader, q evaluator.Query, path string) (bool, error) {
+	if path != "" {
+		return stream.AnyJSON(r, path, q)
+	}
 	dec := json.NewDecoder(r)
 	var m map[string]interface{}
 	if err := dec.Decode(&m); err != nil {
@@ -182,37 +771,44 @@ func evaluateJSON(r io.Reader, q evaluator.Query) (bool, error) {
 
 // YamlTest evaluates a YAML document against the expression.
 func YamlTest(expr string, files ...string) {
+	a := startAudit("yamltest", expr, files)
 	if expr == "" {
-		log.Fatal("-e expression required")
+		a.fatal("-e expression required")
 	}
 	q, err := simple.Parse(expr)
 	if err != nil {
-		log.Fatalf("parse expression: %v", err)
+		a.fatalf("parse expression: %v", err)
 	}
 	if len(files) == 0 {
 		ok, err := evaluateYAML(os.Stdin, q)
 		if err != nil {
-			log.Fatal(err)
+			a.fatal(err)
 		}
+		a.records++
 		if ok {
+			a.matched++
+			a.done()
 			return
 		}
-		os.Exit(1)
+		a.exit(1)
 	}
 	for _, f := range files {
 		fh, err := os.Open(f)
 		if err != nil {
-			log.Fatal(err)
+			a.fatal(err)
 		}
 		ok, err := evaluateYAML(fh, q)
 		_ = fh.Close()
 		if err != nil {
-			log.Fatal(err)
+			a.fatal(err)
 		}
+		a.records++
 		if !ok {
-			os.Exit(1)
+			a.exit(1)
 		}
+		a.matched++
 	}
+	a.done()
 }
 
 func evaluateYAML(r io.Reader, q evaluator.Query) (bool, error) {
@@ -223,3 +819,68 @@ func evaluateYAML(r io.Reader, q evaluator.Query) (bool, error) {
 	}
 	return q.Evaluate(m)
 }
+
+// loadQuery parses expr, or reads a JSON encoded query from file when expr is
+// empty.
+func loadQuery(expr, file string) (evaluator.Query, error) {
+	if expr != "" && file != "" {
+		return evaluator.Query{}, fmt.Errorf("-e and -q are mutually exclusive")
+	}
+	if file == "" {
+		if expr == "" {
+			return evaluator.Query{}, fmt.Errorf("-e expression or -q query file required")
+		}
+		q, err := simple.Parse(expr)
+		if err != nil {
+			return evaluator.Query{}, fmt.Errorf("parse expression: %w", err)
+		}
+		return q, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	var q evaluator.Query
+	if err := json.Unmarshal(data, &q); err != nil {
+		return evaluator.Query{}, fmt.Errorf("parse query file: %w", err)
+	}
+	return q, nil
+}
+
+// Fmt prints the query in the simple syntax after Optimize, with only the
+// parentheses it needs. simplify adds the PushNot rewrite.
+func Fmt(expr, queryFile string, simplify bool) {
+	q, err := loadQuery(expr, queryFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var opts []any
+	if simplify {
+		opts = append(opts, evaluator.PushNot)
+	}
+	fmt.Println(simple.Stringify(evaluator.Optimize(q, opts...), simple.MinimalParens))
+}
+
+// Verify prints the canonical hash of the query, or when expectHash is set
+// checks the query against it and exits with status 1 on a mismatch.
+func Verify(expr, queryFile, expectHash string) {
+	q, err := loadQuery(expr, queryFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if expectHash == "" {
+		h, err := evaluator.Hash(q)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(h)
+		return
+	}
+	if err := evaluator.VerifyHash(q, expectHash); err != nil {
+		if errors.Is(err, evaluator.ErrHashMismatch) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+}
@@ -2,11 +2,21 @@ package lib
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/anonymize"
 	"github.com/arran4/go-evaluator/parser/simple"
+	"github.com/arran4/go-evaluator/store"
+	"github.com/arran4/go-evaluator/stream"
 )
 
 func TestProcessCSV(t *testing.T) {
@@ -25,7 +35,7 @@ charlie,35`
 	var w bytes.Buffer
 	writeHeader := true
 
-	if err := processCSV(r, &w, q, &writeHeader); err != nil {
+	if err := processCSV(r, &w, q, &writeHeader, csvOptions{}); err != nil {
 		t.Fatalf("processCSV error: %v", err)
 	}
 
@@ -35,6 +45,84 @@ charlie,35`
 	}
 }
 
+func TestCSVColumns(t *testing.T) {
+	q, err := simple.Parse(`age > 28 and addr.city is "NZ"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	class, err := simple.Parse(`vip is "yes"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := []string{"name", "age", "notes", "addr", "vip"}
+	if got, want := csvColumns(headers, q, class), []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCSVRow(t *testing.T) {
+	nf, err := evaluator.LookupNumberFormat("de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := []string{"name", "amount", "notes"}
+	row := newCSVRow(headers, []int{0, 1}, &nf)
+	row.reset([]string{"alice", "1.234,5", "x"})
+	if v, err := row.Get("amount"); err != nil || v != 1234.5 {
+		t.Errorf("amount: got %v, %v", v, err)
+	}
+	if v, err := row.Get("name"); err != nil || v != "alice" {
+		t.Errorf("name: got %v, %v", v, err)
+	}
+	if _, err := row.Get("notes"); err == nil {
+		t.Errorf("expected an unreferenced column to be missing")
+	}
+	row.reset([]string{"bob"})
+	if _, err := row.Get("amount"); err == nil {
+		t.Errorf("expected a short row to lack amount")
+	}
+	if v, err := row.Get("name"); err != nil || v != "bob" {
+		t.Errorf("name after reset: got %v, %v", v, err)
+	}
+}
+
+func TestProcessCSVWritesWholeRow(t *testing.T) {
+	q, err := simple.Parse("age > 28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w bytes.Buffer
+	writeHeader := false
+	in := strings.NewReader("name,age,notes\nalice,30,long text\nbob,25,other\n")
+	if err := processCSV(in, &w, q, &writeHeader, csvOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.String(); got != "alice,30,long text\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestProcessCSVLocaleNumbers(t *testing.T) {
+	q, err := simple.Parse("amount > 1000")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	nf, err := evaluator.LookupNumberFormat("de")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	var w bytes.Buffer
+	writeHeader := true
+	in := strings.NewReader("name,amount\nalice,\"1.234,56\"\nbob,\"999,99\"\ncarol,12.000\n")
+	if err := processCSV(in, &w, q, &writeHeader, csvOptions{numbers: &nf}); err != nil {
+		t.Fatalf("processCSV error: %v", err)
+	}
+	expected := "name,amount\nalice,\"1.234,56\"\ncarol,12.000\n"
+	if w.String() != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, w.String())
+	}
+}
+
 func BenchmarkProcessCSV(b *testing.B) {
 	// Prepare a large-ish CSV input
 	var buf bytes.Buffer
@@ -54,7 +142,7 @@ func BenchmarkProcessCSV(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		r := bytes.NewReader(inputData)
 		writeHeader := true
-		if err := processCSV(r, io.Discard, q, &writeHeader); err != nil {
+		if err := processCSV(r, io.Discard, q, &writeHeader, csvOptions{}); err != nil {
 			b.Fatalf("processCSV error: %v", err)
 		}
 	}
@@ -67,7 +155,7 @@ func TestEvaluateJSON(t *testing.T) {
 		t.Fatalf("Parse error: %v", err)
 	}
 	r := bytes.NewReader([]byte(input))
-	val, err := evaluateJSON(r, q)
+	val, err := evaluateJSON(r, q, "")
 	if err != nil {
 		t.Fatalf("evaluateJSON error: %v", err)
 	}
@@ -79,6 +167,21 @@ func TestEvaluateJSON(t *testing.T) {
 	}
 }
 
+func TestEvaluateJSONPath(t *testing.T) {
+	input := `{"meta": {"count": 2}, "data": {"items": [{"name": "bob"}, {"name": "alice"}]}}`
+	q, err := simple.Parse(`name is "alice"`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	val, err := evaluateJSON(strings.NewReader(input), q, "data.items")
+	if err != nil {
+		t.Fatalf("evaluateJSON error: %v", err)
+	}
+	if !val {
+		t.Errorf("Expected true")
+	}
+}
+
 func TestEvaluateYAML(t *testing.T) {
 	input := `name: alice
 age: 30`
@@ -104,10 +207,14 @@ func TestProcessJSONL(t *testing.T) {
 		t.Fatalf("Parse error: %v", err)
 	}
 	r := bytes.NewReader([]byte(input))
-	err = processJSONL(r, q)
+	var w bytes.Buffer
+	err = processJSONL(r, stream.NewWriterSink(&w), &stream.Filter{Query: q})
 	if err != nil {
 		t.Fatalf("processJSONL error: %v", err)
 	}
+	if got := w.String(); got != "{\"age\":30,\"name\":\"alice\"}\n" {
+		t.Errorf("unexpected output %q", got)
+	}
 }
 
 func TestProcessJSONLEOF(t *testing.T) {
@@ -118,8 +225,322 @@ func TestProcessJSONLEOF(t *testing.T) {
 		t.Fatalf("Parse error: %v", err)
 	}
 	r := bytes.NewReader([]byte(input))
-	err = processJSONL(r, q)
+	var w bytes.Buffer
+	err = processJSONL(r, stream.NewWriterSink(&w), &stream.Filter{Query: q})
 	if err != nil {
 		t.Fatalf("processJSONL error: %v", err)
 	}
+	if got := w.String(); got != "{\"age\":30,\"name\":\"alice\"}\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestProcessJSONLLimits(t *testing.T) {
+	input := `{"name": "alice", "age": 30}
+{"name": "` + strings.Repeat("x", 1000) + `", "age": 40}
+`
+	q, err := simple.Parse(`age > 28`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	f := &stream.Filter{Query: q, Limits: stream.Limits{MaxRecordSize: 100}}
+	var w bytes.Buffer
+	err = processJSONL(strings.NewReader(input), stream.NewWriterSink(&w), f)
+	if !errors.Is(err, stream.ErrRecordTooLarge) {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", err)
+	}
+	var stats bytes.Buffer
+	printStats(&stats, f.Stats)
+	if !strings.HasPrefix(stats.String(), "records=1 matched=1 ") {
+		t.Errorf("unexpected stats %q", stats.String())
+	}
+}
+
+func TestProcessAnonymize(t *testing.T) {
+	rules, err := anonymize.ParseRules(`mask Name where Country is not "US"; drop SSN`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `{"Name": "Ann", "Country": "NZ", "SSN": "1"}
+{"Name": "Bob", "Country": "US"}
+`
+	var w bytes.Buffer
+	if err := processAnonymize(strings.NewReader(input), &w, &anonymize.Anonymizer{Rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Country":"NZ","Name":"***"}
+{"Country":"US","Name":"Bob"}
+`
+	if w.String() != want {
+		t.Errorf("got %q", w.String())
+	}
+}
+
+func TestProcessAnonymizeKeepsNumbers(t *testing.T) {
+	rules, err := anonymize.ParseRules(`mask Name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `{"ID": 12345678901234567891, "Amount": 0.1, "Name": "Ann"}
+`
+	var w bytes.Buffer
+	if err := processAnonymize(strings.NewReader(input), &w, &anonymize.Anonymizer{Rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Amount":0.1,"ID":12345678901234567891,"Name":"***"}
+`
+	if w.String() != want {
+		t.Errorf("got %q", w.String())
+	}
+}
+
+func TestFilterQueryFileSets(t *testing.T) {
+	dir := t.TempDir()
+	deny := filepath.Join(dir, "deny.txt")
+	if err := os.WriteFile(deny, []byte("10.0.0.1\n10.0.0.2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	q, err := filterQuery(`status is "ok"`, false, "", "ip="+deny, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `{"ip": "10.0.0.1", "status": "ok"}
+{"ip": "10.0.0.3", "status": "ok"}
+{"ip": "10.0.0.4", "status": "bad"}
+`
+	var w bytes.Buffer
+	if err := processJSONL(strings.NewReader(input), stream.NewWriterSink(&w), &stream.Filter{Query: q}); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != `{"ip":"10.0.0.3","status":"ok"}`+"\n" {
+		t.Errorf("unexpected output %q", w.String())
+	}
+
+	q, err = filterQuery("", false, "ip="+deny, "", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"ip": "10.0.0.2"}); !ok {
+		t.Errorf("expected listed value to match")
+	}
+	if _, err := filterQuery("", false, "", "", false, false); err == nil {
+		t.Errorf("expected error without an expression or file set")
+	}
+	if _, err := filterQuery("", false, "ip", "", false, false); err == nil {
+		t.Errorf("expected error for malformed file set")
+	}
+}
+
+func TestFilterQueryIgnoreCase(t *testing.T) {
+	q, err := filterQuery(`city is "NY"`, true, "", "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w bytes.Buffer
+	writeHeader := true
+	if err := processCSV(strings.NewReader("name,city\nalice,ny\nbob,SF\ncarol,Ny\n"), &w, q, &writeHeader, csvOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "name,city\nalice,ny\ncarol,Ny\n" {
+		t.Errorf("unexpected output %q", w.String())
+	}
+}
+
+func TestProcessSetOp(t *testing.T) {
+	input := `{"id": 1, "tag": "x"}
+{"id": 2, "tag": "y"}
+{"id": 1, "tag": "y"}
+`
+	a, _ := simple.Parse(`tag is "x"`)
+	b, _ := simple.Parse(`tag is "y"`)
+	var w bytes.Buffer
+	if err := processSetOp(strings.NewReader(input), &w, stream.Intersection, "id", a, b); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "1\n" {
+		t.Errorf("got %q", w.String())
+	}
+	if err := processSetOp(strings.NewReader(input), &w, "bogus", "id", a, b); err == nil {
+		t.Errorf("expected error for unknown operation")
+	}
+}
+
+func TestLoadQuery(t *testing.T) {
+	fromExpr, err := loadQuery(`Name is "bob"`, "")
+	if err != nil {
+		t.Fatalf("loadQuery expr: %v", err)
+	}
+	data, err := json.Marshal(fromExpr)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	fn := filepath.Join(t.TempDir(), "q.json")
+	if err := os.WriteFile(fn, data, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	fromFile, err := loadQuery("", fn)
+	if err != nil {
+		t.Fatalf("loadQuery file: %v", err)
+	}
+	h1, _ := evaluator.Hash(fromExpr)
+	h2, _ := evaluator.Hash(fromFile)
+	if h1 != h2 {
+		t.Errorf("expression and file hashes differ: %s vs %s", h1, h2)
+	}
+	if _, err := loadQuery("", ""); err == nil {
+		t.Errorf("expected error without a query")
+	}
+	if _, err := loadQuery(`Name is "bob"`, fn); err == nil {
+		t.Errorf("expected error when both -e and -q are given")
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	d := &store.Dir{Path: t.TempDir()}
+	adults, _ := simple.Parse("Age >= 18")
+	typo, _ := simple.Parse(`Nmae is "bob"`)
+	for name, q := range map[string]evaluator.Query{"adults": adults, "typo": typo} {
+		if err := d.Save(name, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, _ := evaluator.Hash(adults)
+	typoHash, _ := evaluator.Hash(typo)
+	cfg := doctorConfig{
+		hashes: map[string]string{"adults": h, "typo": "0000", "gone": h},
+		fields: map[string]struct{}{"Name": {}, "Age": {}},
+		samples: []map[string]interface{}{
+			{"Name": "bob", "Age": 30},
+			{"Name": "amy", "Age": 12},
+		},
+	}
+	var w bytes.Buffer
+	ok, err := doctor(&w, d, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("expected failures")
+	}
+	want := fmt.Sprintf(`ok   adults (matched 1/2 samples)
+FAIL typo: query hash mismatch: expected 0000, got %s; unknown field "Nmae"
+FAIL gone: hash recorded but query is missing
+2 queries, 2 failed
+`, typoHash)
+	if w.String() != want {
+		t.Errorf("unexpected report:\n%s", w.String())
+	}
+}
+
+func TestReferencedFields(t *testing.T) {
+	q, err := simple.Parse(`(a is 1 or not b > 2) and bucket(c, 10) is 3 and d between 1 and 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(referencedFields(q.Expression), ","); got != "a,b,c,d" {
+		t.Errorf("got %s", got)
+	}
+	geo := &evaluator.GeoWithinExpression{LatField: "lat", LonField: "lng", RadiusMeters: 10}
+	if got := strings.Join(referencedFields(geo), ","); got != "lat,lng" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "classes.txt")
+	classes := "# feature flags\nadult = age >= 18\n\nsenior=age >= 65\n"
+	if err := os.WriteFile(path, []byte(classes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := loadClassifiers(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := filterQuery("", false, "", "", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w bytes.Buffer
+	writeHeader := true
+	in := strings.NewReader("name,age\nalice,30\nbob,70\ncarol,12\n")
+	if err := processCSV(in, &w, q, &writeHeader, csvOptions{classifiers: cs}); err != nil {
+		t.Fatal(err)
+	}
+	want := "name,age,adult,senior\nalice,30,true,false\nbob,70,true,true\ncarol,12,false,false\n"
+	if w.String() != want {
+		t.Errorf("csv: got %q, want %q", w.String(), want)
+	}
+
+	q, err = filterQuery(`name is not "bob"`, false, "", "", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Reset()
+	input := `{"name": "alice", "age": 30}
+{"name": "bob", "age": 70}
+`
+	if err := processJSONL(strings.NewReader(input), stream.NewWriterSink(&w), &stream.Filter{Query: q, Classifiers: cs}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"adult":true,"age":30,"name":"alice","senior":false}` + "\n"; w.String() != want {
+		t.Errorf("jsonl: got %q, want %q", w.String(), want)
+	}
+
+	if err := os.WriteFile(path, []byte("adult age >= 18\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadClassifiers(path, false); err == nil {
+		t.Error("expected error for a line without NAME=")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	t.Setenv(auditEnv, "")
+	if a := startAudit("csvfilter", "age > 28", nil); a.w != nil {
+		t.Fatal("audit log opened without " + auditEnv)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv(auditEnv, path)
+	doc := filepath.Join(t.TempDir(), "doc.json")
+	if err := os.WriteFile(doc, []byte(`{"age": 30}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	JSONTest("age > 28", "", doc)
+
+	a := startAudit("csvfilter", "age > 28", []string{"people.csv"})
+	q, err := simple.Parse("age > 28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeHeader := true
+	if err := processCSV(strings.NewReader("name,age\nalice,30\nbob,25\n"), io.Discard, q, &writeHeader, csvOptions{audit: a}); err != nil {
+		t.Fatal(err)
+	}
+	a.done()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d audit lines: %q", len(lines), data)
+	}
+	var recs [2]auditRecord
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &recs[i]); err != nil {
+			t.Fatal(err)
+		}
+		if recs[i].User == "" || recs[i].Time.IsZero() || recs[i].Exit != 0 || recs[i].Error != "" {
+			t.Errorf("line %d: %s", i, line)
+		}
+	}
+	if r := recs[0]; r.Command != "jsontest" || r.Expression != "age > 28" || fmt.Sprint(r.Files) != fmt.Sprint([]string{doc}) || r.Records != 1 || r.Matched != 1 {
+		t.Errorf("jsontest record %+v", r)
+	}
+	if r := recs[1]; r.Command != "csvfilter" || r.Records != 2 || r.Matched != 1 {
+		t.Errorf("csvfilter record %+v", r)
+	}
 }
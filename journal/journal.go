@@ -0,0 +1,186 @@
+//go:build linux
+
+// Package journal reads systemd journal entries through journalctl, so
+// queries can filter them with more than journald's own field matches. It is
+// only built on Linux.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Entry is a journal entry keyed by field name, such as MESSAGE, PRIORITY or
+// _SYSTEMD_UNIT. Values are strings, as journalctl writes them, or lists of
+// strings for fields that appear more than once.
+type Entry map[string]interface{}
+
+// Reader runs journalctl -o json and decodes the entries it writes.
+type Reader struct {
+	// Follow keeps reading new entries until the context is cancelled.
+	Follow bool
+	// Units restricts entries to these systemd units.
+	Units []string
+	// Since is passed to --since, for example "1 hour ago" or "today".
+	Since string
+	// Lines, when positive, starts with only the most recent entries.
+	Lines int
+	// Args are added to the command line, for example journald matches such
+	// as _TRANSPORT=kernel or --directory to read another journal.
+	Args []string
+	// Command is the journalctl binary. It defaults to "journalctl".
+	Command string
+}
+
+// Run calls fn with each entry in turn until the journal is exhausted or,
+// when following, until ctx is cancelled, when it returns ctx.Err(). An
+// error from fn stops journalctl and is returned.
+func (r *Reader) Run(ctx context.Context, fn func(Entry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, r.command(), r.args()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &limitedWriter{w: &stderr, n: 4096}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	runErr := decode(out, fn)
+	if runErr != nil {
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case runErr != nil:
+		return runErr
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case waitErr != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("journalctl: %w: %s", waitErr, msg)
+		}
+		return fmt.Errorf("journalctl: %w", waitErr)
+	}
+	return nil
+}
+
+func (r *Reader) command() string {
+	if r.Command == "" {
+		return "journalctl"
+	}
+	return r.Command
+}
+
+func (r *Reader) args() []string {
+	args := []string{"--output=json", "--no-pager"}
+	if r.Follow {
+		args = append(args, "--follow")
+	}
+	for _, u := range r.Units {
+		args = append(args, "--unit="+u)
+	}
+	if r.Since != "" {
+		args = append(args, "--since="+r.Since)
+	}
+	if r.Lines > 0 {
+		args = append(args, "--lines="+strconv.Itoa(r.Lines))
+	}
+	return append(args, r.Args...)
+}
+
+// decode reads one JSON object per line. A line that does not decode, which
+// journalctl may leave truncated when it is stopped, ends the stream with an
+// error.
+func decode(rd io.Reader, fn func(Entry) error) error {
+	br := bufio.NewReader(rd)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var raw map[string]interface{}
+			if jerr := json.Unmarshal(line, &raw); jerr != nil {
+				return fmt.Errorf("journalctl: decode entry: %w", jerr)
+			}
+			if ferr := fn(normalize(raw)); ferr != nil {
+				return ferr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// normalize turns the byte arrays journalctl writes for binary or non-UTF-8
+// values into strings, and nulls, written for values too large to show, into
+// missing fields.
+func normalize(raw map[string]interface{}) Entry {
+	e := make(Entry, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case []interface{}:
+			if s, ok := byteString(v); ok {
+				e[k] = s
+				continue
+			}
+			list := make([]interface{}, 0, len(v))
+			for _, el := range v {
+				if arr, ok := el.([]interface{}); ok {
+					if s, ok := byteString(arr); ok {
+						el = s
+					}
+				}
+				list = append(list, el)
+			}
+			e[k] = list
+		default:
+			e[k] = v
+		}
+	}
+	return e
+}
+
+// byteString converts a JSON array of byte values to a string, replacing
+// invalid UTF-8.
+func byteString(v []interface{}) (string, bool) {
+	b := make([]byte, len(v))
+	for i, el := range v {
+		n, ok := el.(float64)
+		if !ok || n < 0 || n > 255 || n != float64(int(n)) {
+			return "", false
+		}
+		b[i] = byte(n)
+	}
+	return strings.ToValidUTF8(string(b), string(utf8.RuneError)), true
+}
+
+// limitedWriter keeps the first n bytes written to it.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		k := min(len(p), l.n)
+		l.n -= k
+		_, _ = l.w.Write(p[:k])
+	}
+	return len(p), nil
+}
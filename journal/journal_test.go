@@ -0,0 +1,112 @@
+//go:build linux
+
+package journal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJournalctl writes a script that records its arguments in args and then
+// runs body.
+func fakeJournalctl(t *testing.T, body string) (command, args string) {
+	t.Helper()
+	dir := t.TempDir()
+	args = filepath.Join(dir, "args")
+	command = filepath.Join(dir, "journalctl")
+	script := "#!/bin/sh\necho \"$@\" > " + args + "\n" + body + "\n"
+	if err := os.WriteFile(command, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return command, args
+}
+
+const entries = `{"MESSAGE":"started","PRIORITY":"6","_SYSTEMD_UNIT":"nginx.service"}
+{"MESSAGE":[104,105,255],"PRIORITY":"3","TAG":["a",[98]],"HUGE":null}
+`
+
+func TestReader(t *testing.T) {
+	command, argsFile := fakeJournalctl(t, "cat <<'EOF'\n"+entries+"EOF")
+	r := &Reader{
+		Units:   []string{"nginx.service", "sshd.service"},
+		Since:   "1 hour ago",
+		Lines:   50,
+		Args:    []string{"_TRANSPORT=journal"},
+		Command: command,
+	}
+	var got []Entry
+	if err := r.Run(context.Background(), func(e Entry) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{"MESSAGE": "started", "PRIORITY": "6", "_SYSTEMD_UNIT": "nginx.service"},
+		{"MESSAGE": "hi�", "PRIORITY": "3", "TAG": []interface{}{"a", "b"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := "--output=json --no-pager --unit=nginx.service --unit=sshd.service --since=1 hour ago --lines=50 _TRANSPORT=journal"
+	if s := strings.TrimSpace(string(args)); s != wantArgs {
+		t.Errorf("arguments %q, want %q", s, wantArgs)
+	}
+}
+
+func TestReaderFollow(t *testing.T) {
+	command, argsFile := fakeJournalctl(t, "cat <<'EOF'\n"+entries+"EOF\nexec sleep 60")
+	r := &Reader{Follow: true, Command: command}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, func(Entry) error {
+			if n++; n == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if args, _ := os.ReadFile(argsFile); !strings.Contains(string(args), "--follow") {
+		t.Errorf("missing --follow in %q", args)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	command, _ := fakeJournalctl(t, "echo 'No journal files were found.' >&2\nexit 1")
+	err := (&Reader{Command: command}).Run(context.Background(), func(Entry) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "No journal files were found.") {
+		t.Errorf("expected the journalctl error, got %v", err)
+	}
+
+	command, _ = fakeJournalctl(t, "cat <<'EOF'\n"+entries+"EOF")
+	stop := errors.New("stop")
+	if err := (&Reader{Command: command}).Run(context.Background(), func(Entry) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+
+	command, _ = fakeJournalctl(t, "echo '{\"MESSAGE\":'")
+	if err := (&Reader{Command: command}).Run(context.Background(), func(Entry) error { return nil }); err == nil {
+		t.Error("expected a decode error")
+	}
+}
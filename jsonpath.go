@@ -0,0 +1,652 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// JSONPathExpression selects values from the record with the JSONPath Path
+// and succeeds when any of them compares with Value using Op, one of eq, neq,
+// gt, gte, lt, lte, contains or icontains as for ComparisonExpression. An
+// empty Op succeeds when Path selects anything at all.
+//
+// Paths start at $ and support .name and ['name'] members, [n] indexes
+// (negative from the end), [start:end:step] slices, * wildcards, unions such
+// as ['a','b'] or [0,2], .. recursive descent and [?(...)] filters. Filters
+// compare @-relative paths and literals with ==, !=, <, <=, > and >=, test
+// that a path exists, and combine tests with &&, || and !, as in
+// $..book[?(@.price < 10 && @.isbn)].title. Paths read maps, structs and
+// lists, so records decoded from JSON work as they are; members of a Getter
+// can be named but not listed by a wildcard.
+type JSONPathExpression struct {
+	Path  string
+	Op    string      `json:",omitempty"`
+	Value interface{} `json:",omitempty"`
+	path  atomic.Pointer[compiledJSONPath]
+}
+
+type compiledJSONPath struct {
+	path  string
+	steps []jsonPathStep
+}
+
+func (e *JSONPathExpression) steps() ([]jsonPathStep, error) {
+	if c := e.path.Load(); c != nil && c.path == e.Path {
+		return c.steps, nil
+	}
+	steps, err := parseJSONPath(e.Path)
+	if err != nil {
+		return nil, err
+	}
+	e.path.Store(&compiledJSONPath{path: e.Path, steps: steps})
+	return steps, nil
+}
+
+func (e *JSONPathExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	steps, err := e.steps()
+	if err != nil {
+		return false, err
+	}
+	if !jsonPathOps[e.Op] {
+		return false, fmt.Errorf("jsonpath %s: unknown operation %q", e.Path, e.Op)
+	}
+	for _, v := range selectJSONPath(steps, reflect.ValueOf(unwrapRecord(i)), opts) {
+		if e.Op == "" {
+			return true, nil
+		}
+		ok, err := ComparisonExpression{LHS: Constant{Value: v}, RHS: Constant{Value: e.Value}, Operation: e.Op}.Evaluate(nil, opts...)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// jsonPathField returns the member a path ends by naming, such as price for
+// $.items[*].price, or "" when it ends otherwise.
+func jsonPathField(path string) string {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return ""
+	}
+	return jsonPathStepsField(steps)
+}
+
+// jsonPathStepsField is jsonPathField for parsed steps.
+func jsonPathStepsField(steps []jsonPathStep) string {
+	if len(steps) == 0 || len(steps[len(steps)-1].names) != 1 {
+		return ""
+	}
+	return steps[len(steps)-1].names[0]
+}
+
+// jsonPathOps holds the operations JSONPathExpression accepts.
+var jsonPathOps = map[string]bool{
+	"": true, "eq": true, "neq": true, "gt": true, "gte": true, "lt": true, "lte": true,
+	"contains": true, "icontains": true,
+}
+
+// jsonPathStep selects from each value reached so far, or with descend from
+// each of them and all their descendants.
+type jsonPathStep struct {
+	descend  bool
+	wildcard bool
+	names    []string
+	indexes  []int
+	slice    *[3]*int
+	filter   jsonPathFilter
+}
+
+// jsonPathFilter is a [?(...)] test applied to candidate values.
+type jsonPathFilter interface {
+	match(v reflect.Value, opts []any) bool
+}
+
+type jsonPathOr struct{ l, r jsonPathFilter }
+
+func (f jsonPathOr) match(v reflect.Value, opts []any) bool {
+	return f.l.match(v, opts) || f.r.match(v, opts)
+}
+
+type jsonPathAnd struct{ l, r jsonPathFilter }
+
+func (f jsonPathAnd) match(v reflect.Value, opts []any) bool {
+	return f.l.match(v, opts) && f.r.match(v, opts)
+}
+
+type jsonPathNot struct{ f jsonPathFilter }
+
+func (f jsonPathNot) match(v reflect.Value, opts []any) bool {
+	return !f.f.match(v, opts)
+}
+
+// jsonPathTest compares two operands with op, one of the ComparisonExpression
+// operations, or with an empty op tests that lhs selects something.
+type jsonPathTest struct {
+	lhs, rhs jsonPathOperand
+	op       string
+}
+
+func (f jsonPathTest) match(v reflect.Value, opts []any) bool {
+	ls := f.lhs.values(v, opts)
+	if f.op == "" {
+		if f.lhs.steps == nil {
+			return len(ls) == 1 && ls[0] == true
+		}
+		return len(ls) > 0
+	}
+	rs := f.rhs.values(v, opts)
+	for _, l := range ls {
+		for _, r := range rs {
+			if ok, _ := (ComparisonExpression{LHS: Constant{Value: l}, RHS: Constant{Value: r}, Operation: f.op}).Evaluate(nil, opts...); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonPathOperand is an @-relative path, when steps is set, or a literal.
+// start and end locate a literal in the path text.
+type jsonPathOperand struct {
+	steps      []jsonPathStep
+	literal    interface{}
+	start, end int
+}
+
+func (o jsonPathOperand) values(v reflect.Value, opts []any) []interface{} {
+	if o.steps == nil {
+		return []interface{}{o.literal}
+	}
+	return selectJSONPath(o.steps, v, opts)
+}
+
+// selectJSONPath applies steps to root and returns the values selected.
+func selectJSONPath(steps []jsonPathStep, root reflect.Value, opts []any) []interface{} {
+	cur := []reflect.Value{root}
+	for _, s := range steps {
+		var next []reflect.Value
+		for _, v := range cur {
+			if s.descend {
+				for _, d := range descendants(v, nil, 0) {
+					next = s.apply(d, next, opts)
+				}
+			} else {
+				next = s.apply(v, next, opts)
+			}
+		}
+		cur = next
+	}
+	out := make([]interface{}, 0, len(cur))
+	for _, v := range cur {
+		if v.IsValid() && v.CanInterface() {
+			out = append(out, v.Interface())
+		}
+	}
+	return out
+}
+
+// apply appends the values s selects from v to out.
+func (s jsonPathStep) apply(v reflect.Value, out []reflect.Value, opts []any) []reflect.Value {
+	v = jsonPathIndirect(v)
+	if !v.IsValid() {
+		return out
+	}
+	switch {
+	case s.wildcard:
+		return append(out, jsonPathChildren(v)...)
+	case s.names != nil:
+		for _, name := range s.names {
+			f, ok := fieldByName(v, name)
+			if !ok && foldFields(opts) {
+				f, ok = fieldByFoldedName(v, name)
+			}
+			if ok {
+				out = append(out, f)
+			}
+		}
+	case s.filter != nil:
+		for _, c := range jsonPathChildren(v) {
+			if s.filter.match(c, opts) {
+				out = append(out, c)
+			}
+		}
+	case v.Kind() != reflect.Slice && v.Kind() != reflect.Array:
+	case s.slice != nil:
+		for _, n := range sliceIndexes(*s.slice, v.Len()) {
+			out = append(out, v.Index(n))
+		}
+	default:
+		for _, n := range s.indexes {
+			if n < 0 {
+				n += v.Len()
+			}
+			if n >= 0 && n < v.Len() {
+				out = append(out, v.Index(n))
+			}
+		}
+	}
+	return out
+}
+
+// sliceIndexes returns the indexes a [start:end:step] slice selects from a
+// list of length n.
+func sliceIndexes(b [3]*int, n int) []int {
+	step := 1
+	if b[2] != nil {
+		step = *b[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += n
+		}
+		return min(max(i, -1), n)
+	}
+	var out []int
+	if step > 0 {
+		for i := max(bound(b[0], 0), 0); i < bound(b[1], n); i += step {
+			out = append(out, i)
+		}
+		return out
+	}
+	for i := min(bound(b[0], n-1), n-1); i > bound(b[1], -1); i += step {
+		out = append(out, i)
+	}
+	return out
+}
+
+// jsonPathIndirect looks through pointers and interfaces, stopping at records
+// that resolve their own fields.
+func jsonPathIndirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		if _, ok := resolver(v); ok {
+			break
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// jsonPathChildren returns the elements of a list, the values of a map in key
+// order or the exported fields of a struct.
+func jsonPathChildren(v reflect.Value) []reflect.Value {
+	var out []reflect.Value
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for n := 0; n < v.Len(); n++ {
+			out = append(out, v.Index(n))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(a, b int) bool {
+			return fmt.Sprint(keys[a].Interface()) < fmt.Sprint(keys[b].Interface())
+		})
+		for _, k := range keys {
+			out = append(out, v.MapIndex(k))
+		}
+	case reflect.Struct:
+		for n := 0; n < v.NumField(); n++ {
+			if v.Type().Field(n).IsExported() {
+				out = append(out, v.Field(n))
+			}
+		}
+	}
+	return out
+}
+
+// descendants appends v and everything below it to out, going no deeper than
+// DefaultMaxDepth so that cyclic data is safe to walk.
+func descendants(v reflect.Value, out []reflect.Value, depth int) []reflect.Value {
+	out = append(out, v)
+	if depth >= DefaultMaxDepth {
+		return out
+	}
+	if v = jsonPathIndirect(v); v.IsValid() {
+		for _, c := range jsonPathChildren(v) {
+			out = descendants(c, out, depth+1)
+		}
+	}
+	return out
+}
+
+// jsonPathParser reads a JSONPath, or the filter expressions within one.
+type jsonPathParser struct {
+	s string
+	p int
+}
+
+func parseJSONPath(s string) ([]jsonPathStep, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("jsonpath %q: must start with $", s)
+	}
+	p := &jsonPathParser{s: s, p: 1}
+	steps, err := p.steps()
+	if err == nil && p.p < len(s) {
+		err = p.errorf("unexpected %q", s[p.p])
+	}
+	return steps, err
+}
+
+func (p *jsonPathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("jsonpath %q at %d: %s", p.s, p.p, fmt.Sprintf(format, args...))
+}
+
+func (p *jsonPathParser) peek(prefix string) bool {
+	return strings.HasPrefix(p.s[p.p:], prefix)
+}
+
+func (p *jsonPathParser) skipSpace() {
+	for p.p < len(p.s) && p.s[p.p] == ' ' {
+		p.p++
+	}
+}
+
+// steps reads selectors until one cannot start. The result is never nil, so
+// that an operand of just @ is told apart from a literal.
+func (p *jsonPathParser) steps() ([]jsonPathStep, error) {
+	steps := []jsonPathStep{}
+	for p.p < len(p.s) {
+		var s jsonPathStep
+		switch {
+		case p.peek(".."):
+			p.p += 2
+			s.descend = true
+			if p.peek("[") {
+				break
+			}
+			if err := p.member(&s); err != nil {
+				return nil, err
+			}
+			steps = append(steps, s)
+			continue
+		case p.peek("."):
+			p.p++
+			if err := p.member(&s); err != nil {
+				return nil, err
+			}
+			steps = append(steps, s)
+			continue
+		case p.peek("["):
+		default:
+			return steps, nil
+		}
+		p.p++
+		if err := p.bracket(&s); err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// member reads the name or * following a dot.
+func (p *jsonPathParser) member(s *jsonPathStep) error {
+	if p.peek("*") {
+		p.p++
+		s.wildcard = true
+		return nil
+	}
+	start := p.p
+	for p.p < len(p.s) && !strings.ContainsRune(".[]()'\" =!<>&|,", rune(p.s[p.p])) {
+		p.p++
+	}
+	if p.p == start {
+		return p.errorf("expected a member name")
+	}
+	s.names = []string{p.s[start:p.p]}
+	return nil
+}
+
+// bracket reads the contents of [...] after the opening bracket.
+func (p *jsonPathParser) bracket(s *jsonPathStep) error {
+	p.skipSpace()
+	switch {
+	case p.peek("*"):
+		p.p++
+		s.wildcard = true
+	case p.peek("?"):
+		p.p++
+		p.skipSpace()
+		paren := p.peek("(")
+		if paren {
+			p.p++
+		}
+		f, err := p.or()
+		if err != nil {
+			return err
+		}
+		if paren {
+			p.skipSpace()
+			if !p.peek(")") {
+				return p.errorf("expected )")
+			}
+			p.p++
+		}
+		s.filter = f
+	case p.peek("'") || p.peek(`"`):
+		for {
+			name, err := p.quoted()
+			if err != nil {
+				return err
+			}
+			s.names = append(s.names, name)
+			if !p.comma() {
+				break
+			}
+		}
+	default:
+		if err := p.indexes(s); err != nil {
+			return err
+		}
+	}
+	p.skipSpace()
+	if !p.peek("]") {
+		return p.errorf("expected ]")
+	}
+	p.p++
+	return nil
+}
+
+// comma consumes a comma separating union members.
+func (p *jsonPathParser) comma() bool {
+	p.skipSpace()
+	if !p.peek(",") {
+		return false
+	}
+	p.p++
+	p.skipSpace()
+	return true
+}
+
+// indexes reads a union of indexes or a slice.
+func (p *jsonPathParser) indexes(s *jsonPathStep) error {
+	var bounds [3]*int
+	part := 0
+	for {
+		p.skipSpace()
+		if n, ok := p.integer(); ok {
+			bounds[part] = &n
+		}
+		p.skipSpace()
+		switch {
+		case p.peek(":") && part < 2:
+			p.p++
+			part++
+			continue
+		case part > 0:
+			s.slice = &bounds
+			return nil
+		case bounds[0] == nil:
+			return p.errorf("expected an index")
+		}
+		s.indexes = append(s.indexes, *bounds[0])
+		if !p.comma() {
+			return nil
+		}
+		bounds[0] = nil
+	}
+}
+
+func (p *jsonPathParser) integer() (int, bool) {
+	start := p.p
+	if p.peek("-") {
+		p.p++
+	}
+	for p.p < len(p.s) && p.s[p.p] >= '0' && p.s[p.p] <= '9' {
+		p.p++
+	}
+	n, err := strconv.Atoi(p.s[start:p.p])
+	if err != nil {
+		p.p = start
+		return 0, false
+	}
+	return n, true
+}
+
+// quoted reads a string in single or double quotes, with backslash escapes.
+func (p *jsonPathParser) quoted() (string, error) {
+	q := p.s[p.p]
+	var b strings.Builder
+	for p.p++; p.p < len(p.s); p.p++ {
+		c := p.s[p.p]
+		switch {
+		case c == q:
+			p.p++
+			return b.String(), nil
+		case c == '\\' && p.p+1 < len(p.s):
+			p.p++
+			b.WriteByte(p.s[p.p])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsonPathParser) or() (jsonPathFilter, error) {
+	l, err := p.and()
+	for err == nil {
+		p.skipSpace()
+		if !p.peek("||") {
+			return l, nil
+		}
+		p.p += 2
+		var r jsonPathFilter
+		if r, err = p.and(); err == nil {
+			l = jsonPathOr{l, r}
+		}
+	}
+	return nil, err
+}
+
+func (p *jsonPathParser) and() (jsonPathFilter, error) {
+	l, err := p.unary()
+	for err == nil {
+		p.skipSpace()
+		if !p.peek("&&") {
+			return l, nil
+		}
+		p.p += 2
+		var r jsonPathFilter
+		if r, err = p.unary(); err == nil {
+			l = jsonPathAnd{l, r}
+		}
+	}
+	return nil, err
+}
+
+// jsonPathTestOps maps filter operators to ComparisonExpression operations,
+// longest first.
+var jsonPathTestOps = []struct{ tok, op string }{
+	{"==", "eq"}, {"!=", "neq"}, {"<=", "lte"}, {">=", "gte"}, {"<", "lt"}, {">", "gt"},
+}
+
+func (p *jsonPathParser) unary() (jsonPathFilter, error) {
+	p.skipSpace()
+	if p.peek("!") && !p.peek("!=") {
+		p.p++
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathNot{f}, nil
+	}
+	if p.peek("(") {
+		p.p++
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.peek(")") {
+			return nil, p.errorf("expected )")
+		}
+		p.p++
+		return f, nil
+	}
+	lhs, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, o := range jsonPathTestOps {
+		if p.peek(o.tok) {
+			p.p += len(o.tok)
+			rhs, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return jsonPathTest{lhs: lhs, rhs: rhs, op: o.op}, nil
+		}
+	}
+	return jsonPathTest{lhs: lhs}, nil
+}
+
+// operand reads an @-relative path or a literal: a quoted string, a number,
+// true, false or null.
+func (p *jsonPathParser) operand() (jsonPathOperand, error) {
+	p.skipSpace()
+	start := p.p
+	switch {
+	case p.peek("@"):
+		p.p++
+		steps, err := p.steps()
+		return jsonPathOperand{steps: steps}, err
+	case p.peek("'") || p.peek(`"`):
+		s, err := p.quoted()
+		return jsonPathOperand{literal: s, start: start, end: p.p}, err
+	}
+	for _, w := range []struct {
+		word string
+		v    interface{}
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		if p.peek(w.word) {
+			p.p += len(w.word)
+			return jsonPathOperand{literal: w.v, start: start, end: p.p}, nil
+		}
+	}
+	for p.p < len(p.s) && strings.ContainsRune("+-.0123456789eE", rune(p.s[p.p])) {
+		p.p++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.p], 64)
+	if err != nil {
+		p.p = start
+		return jsonPathOperand{}, p.errorf("expected a path or literal")
+	}
+	return jsonPathOperand{literal: f, start: start, end: p.p}, nil
+}
@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const jsonPathStore = `{"store": {
+	"book": [
+		{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+		{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+		{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+		{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+	],
+	"bicycle": {"color": "red", "price": 19.95}
+}}`
+
+func TestJSONPathExpression(t *testing.T) {
+	q := Query{Expression: &JSONPathExpression{Path: "$.store.bicycle.color", Op: "eq", Value: "red"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"JSONPath","Expression":{"Path":"$.store.bicycle.color","Op":"eq","Value":"red"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonPathStore), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := back.Evaluate(doc); err != nil || !ok {
+		t.Errorf("round trip: got %v, %v", ok, err)
+	}
+	for _, tt := range []struct {
+		path  string
+		op    string
+		value interface{}
+		want  bool
+	}{
+		{"$.store.book[0].author", "eq", "Nigel Rees", true},
+		{"$['store']['book'][-1].price", "gt", 20, true},
+		{"$..author", "eq", "Herman Melville", true},
+		{"$..author", "eq", "Nobody", false},
+		{"$.store.*.color", "eq", "red", true},
+		{"$.store.book[*].isbn", "", nil, true},
+		{"$.store.book[0].isbn", "", nil, false},
+		{"$.store.book[1:3].title", "eq", "Moby Dick", true},
+		{"$.store.book[1:3].title", "eq", "The Lord of the Rings", false},
+		{"$.store.book[::-2].author", "eq", "J. R. R. Tolkien", true},
+		{"$.store.book[0,3].category", "eq", "fiction", true},
+		{"$..book[?(@.price < 10 && @.isbn)].title", "eq", "Moby Dick", true},
+		{"$..book[?(@.price < 10 && @.isbn)].title", "eq", "Sayings of the Century", false},
+		{"$..book[?(@.category == 'reference' || @.price > 20)].author", "eq", "J. R. R. Tolkien", true},
+		{"$..book[?(!@.isbn)].title", "icontains", "sword", true},
+		{"$..[?(@.color)].price", "lt", 20, true},
+		{"$.missing", "", nil, false},
+	} {
+		q := Query{Expression: &JSONPathExpression{Path: tt.path, Op: tt.op, Value: tt.value}}
+		got, err := q.Evaluate(doc)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %s %v: got %v, want %v", tt.path, tt.op, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestJSONPathStructs(t *testing.T) {
+	type item struct {
+		Name string
+		Tags []string
+	}
+	rec := struct{ Items []item }{Items: []item{{Name: "a", Tags: []string{"x"}}, {Name: "b", Tags: []string{"y", "z"}}}}
+	q := Query{Expression: &JSONPathExpression{Path: "$.Items[?(@.Tags[1])].Name", Op: "eq", Value: "b"}}
+	if ok, err := q.Evaluate(&rec); err != nil || !ok {
+		t.Errorf("got %v, %v", ok, err)
+	}
+}
+
+func TestJSONPathWrappedRecord(t *testing.T) {
+	type item struct{ Price float64 }
+	rec := &struct{ Items []item }{Items: []item{{Price: 5}, {Price: 12}}}
+	q := Query{Expression: &JSONPathExpression{Path: "$.Items[?(@.Price > 10)].Price", Op: "eq", Value: 12}}
+	for _, ctx := range []*Context{{CacheFields: true}, {FoldFields: true}, {CacheFields: true, FoldFields: true}} {
+		if ok, err := q.Evaluate(rec, ctx); err != nil || !ok {
+			t.Errorf("%+v: got %v, %v", *ctx, ok, err)
+		}
+	}
+	if got := EvaluateAll([]Query{q}, rec); !got.Has(0) {
+		t.Errorf("EvaluateAll: got %v", got)
+	}
+	folded := Query{Expression: &JSONPathExpression{Path: "$.items[*].price", Op: "gt", Value: 10}}
+	if ok, err := folded.Evaluate(rec, &Context{FoldFields: true}); err != nil || !ok {
+		t.Errorf("folded names: got %v, %v", ok, err)
+	}
+	if ok, err := folded.Evaluate(rec); err != nil || ok {
+		t.Errorf("names should not fold without FoldFields: got %v, %v", ok, err)
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, e := range []*JSONPathExpression{
+		{Path: "store.book"},
+		{Path: "$.store[0"},
+		{Path: "$[?(@.a ==)]"},
+		{Path: "$.a", Op: "like"},
+	} {
+		q := Query{Expression: e}
+		if _, err := q.Evaluate(map[string]interface{}{"a": 1}); err == nil {
+			t.Errorf("%s %s: expected an error", e.Path, e.Op)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// LengthExpression compares the length of Field with Value using Op, one of
+// the ComparisonExpression operations eq, neq, gt, gte, lt or lte. Strings
+// are measured in characters; slices, arrays and maps in elements. Fields of
+// any other kind do not match.
+type LengthExpression struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+func (e LengthExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	n, ok := numeric[float64](e.Value)
+	if !ok {
+		return false, fmt.Errorf("length of %s: value %v is not a number", e.Field, e.Value)
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok {
+		return false, nil
+	}
+	l, ok := fieldLength(f)
+	if !ok {
+		return false, nil
+	}
+	if ok, known := compareCount(l, e.Op, n); known {
+		return ok, nil
+	}
+	return false, fmt.Errorf("length of %s: unknown operation %q", e.Field, e.Op)
+}
+
+func fieldLength(f reflect.Value) (int, bool) {
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return 0, false
+		}
+		f = f.Elem()
+	}
+	switch f.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(f.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return f.Len(), true
+	}
+	return 0, false
+}
+
+// compareCount compares l with n using op. known is false for an unknown op.
+func compareCount(l int, op string, n float64) (ok, known bool) {
+	return orderResult(cmp.Compare(float64(l), n), op)
+}
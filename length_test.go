@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLengthExpression(t *testing.T) {
+	type rec struct {
+		Name string
+		Tags []string
+		Meta map[string]int
+		Ptr  *[]string
+		Age  int
+	}
+	r := &rec{Name: "zoë", Tags: []string{"a", "b", "c"}, Meta: map[string]int{"x": 1}}
+	tests := []struct {
+		e    LengthExpression
+		want bool
+	}{
+		{LengthExpression{Field: "Tags", Op: "gte", Value: 3}, true},
+		{LengthExpression{Field: "Tags", Op: "gt", Value: 3}, false},
+		{LengthExpression{Field: "Name", Op: "eq", Value: 3}, true},
+		{LengthExpression{Field: "Meta", Op: "lt", Value: 2.5}, true},
+		{LengthExpression{Field: "Meta", Op: "neq", Value: "1"}, false},
+		{LengthExpression{Field: "Tags", Op: "lte", Value: json.Number("3")}, true},
+		{LengthExpression{Field: "Ptr", Op: "eq", Value: 0}, false},
+		{LengthExpression{Field: "Age", Op: "eq", Value: 0}, false},
+		{LengthExpression{Field: "Other", Op: "eq", Value: 0}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(r)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.e, err)
+		}
+		if got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.e, got, tt.want)
+		}
+	}
+	if _, err := (LengthExpression{Field: "Tags", Op: "like", Value: 1}).Evaluate(r); err == nil {
+		t.Errorf("expected error for unknown operation")
+	}
+	if _, err := (LengthExpression{Field: "Tags", Op: "eq", Value: "many"}).Evaluate(r); err == nil {
+		t.Errorf("expected error for non-numeric value")
+	}
+}
+
+func TestLengthExpressionJSON(t *testing.T) {
+	q := Query{Expression: &LengthExpression{Field: "Tags", Op: "gte", Value: 3}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"Length","Expression":{"Field":"Tags","Op":"gte","Value":3}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := back.Evaluate(map[string]interface{}{"Tags": []interface{}{1, 2, 3}}); err != nil || !ok {
+		t.Errorf("got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,118 @@
+package lint
+
+import (
+	"strings"
+	"time"
+)
+
+// kind is the kind of values an interval holds.
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindString
+	kindTime
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindTime:
+		return "time"
+	}
+	return "number"
+}
+
+// bound is one end of an interval. A nil v leaves that end open, except that
+// the lower end of strings is always "".
+type bound struct {
+	v    interface{}
+	incl bool
+}
+
+// interval is the set of values between lo and hi.
+type interval struct {
+	kind   kind
+	lo, hi bound
+}
+
+// universe returns the interval holding every value of k.
+func universe(k kind) interval {
+	return interval{kind: k}.normal()
+}
+
+// normal gives strings their least value as the lower bound, so that
+// intervals of every kind compare alike.
+func (iv interval) normal() interval {
+	if iv.kind == kindString && iv.lo.v == nil {
+		iv.lo = bound{v: "", incl: true}
+	}
+	return iv
+}
+
+// intersect returns the values in both iv and o.
+func (iv interval) intersect(o interval) interval {
+	if lowerLess(iv.lo, o.lo) {
+		iv.lo = o.lo
+	}
+	if upperLess(o.hi, iv.hi) {
+		iv.hi = o.hi
+	}
+	return iv
+}
+
+// empty reports whether iv holds no values.
+func (iv interval) empty() bool {
+	if iv.lo.v == nil || iv.hi.v == nil {
+		return false
+	}
+	c := compare(iv.lo.v, iv.hi.v)
+	return c > 0 || c == 0 && !(iv.lo.incl && iv.hi.incl)
+}
+
+// contains reports whether every value of o is in iv.
+func (iv interval) contains(o interval) bool {
+	if o.empty() {
+		return true
+	}
+	return !lowerLess(o.lo, iv.lo) && !upperLess(iv.hi, o.hi)
+}
+
+// lowerLess reports whether the lower bound a admits values below b.
+func lowerLess(a, b bound) bool {
+	if a.v == nil || b.v == nil {
+		return a.v == nil && b.v != nil
+	}
+	c := compare(a.v, b.v)
+	return c < 0 || c == 0 && a.incl && !b.incl
+}
+
+// upperLess reports whether the upper bound a stops short of b.
+func upperLess(a, b bound) bool {
+	if a.v == nil || b.v == nil {
+		return a.v != nil && b.v == nil
+	}
+	c := compare(a.v, b.v)
+	return c < 0 || c == 0 && !a.incl && b.incl
+}
+
+// compare orders two values of the same kind.
+func compare(a, b interface{}) int {
+	switch x := a.(type) {
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	}
+	return 0
+}
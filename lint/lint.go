@@ -0,0 +1,405 @@
+// Package lint finds clauses of a query that are contradictory, always true
+// or redundant, the cruft that stored filters accumulate as they are edited.
+//
+// Comparisons of a field against a literal, such as Age > 10, are read as
+// intervals of the values the field may take. Within an and, clauses on the
+// same field whose intervals do not overlap can never match together, and a
+// clause whose interval holds the overlap of the others adds nothing. Within
+// an or, clauses on one field whose intervals cover every value always
+// match, and a clause inside another's interval adds nothing.
+//
+// Numbers, strings and times are analysed separately. Strings that look like
+// numbers are skipped, since they compare as numbers against numeric fields
+// and as text against strings, and so are case-insensitive comparisons.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+// Finding is a problem found in a query.
+type Finding struct {
+	// Code is "contradiction", "tautology" or "redundant".
+	Code string
+	// Path locates the clause, or the and/or group for contradictions and
+	// tautologies, as a JSON path such as
+	// $.Expression.Expression.Expressions[1].
+	Path    string
+	Message string
+	// Fix suggests how to change the query.
+	Fix string
+}
+
+func (f Finding) String() string {
+	return f.Code + " at " + f.Path + ": " + f.Message + "; " + f.Fix
+}
+
+// Check returns the findings for q in the order their groups appear.
+func Check(q evaluator.Query) []Finding {
+	var out []Finding
+	check(q, "$", &out)
+	return out
+}
+
+func check(q evaluator.Query, path string, out *[]Finding) {
+	ep := path + ".Expression.Expression"
+	switch ex := q.Expression.(type) {
+	case *evaluator.AndExpression:
+		children := group(ex.Expressions, ep, isAnd)
+		checkAnd(children, path, out)
+		for _, c := range children {
+			check(c.q, c.path, out)
+		}
+	case *evaluator.OrExpression:
+		children := group(ex.Expressions, ep, isOr)
+		checkOr(children, path, out)
+		for _, c := range children {
+			check(c.q, c.path, out)
+		}
+	case *evaluator.XorExpression:
+		for i, c := range ex.Expressions {
+			check(c, fmt.Sprintf("%s.Expressions[%d]", ep, i), out)
+		}
+	case *evaluator.ImpliesExpression:
+		check(ex.If, ep+".If", out)
+		check(ex.Then, ep+".Then", out)
+	case *evaluator.CaseExpression:
+		for i, c := range ex.Cases {
+			check(c.When, fmt.Sprintf("%s.Cases[%d].When", ep, i), out)
+			check(c.Then, fmt.Sprintf("%s.Cases[%d].Then", ep, i), out)
+		}
+		check(ex.Else, ep+".Else", out)
+	case *evaluator.NotExpression:
+		check(ex.Expression, ep+".Expression", out)
+	case *evaluator.AnyExpression:
+		check(ex.Query, ep+".Query", out)
+	case *evaluator.AllExpression:
+		check(ex.Query, ep+".Query", out)
+	case *evaluator.CountExpression:
+		check(ex.Query, ep+".Query", out)
+	}
+}
+
+func isAnd(e evaluator.Expression) ([]evaluator.Query, bool) {
+	if a, ok := e.(*evaluator.AndExpression); ok {
+		return a.Expressions, true
+	}
+	return nil, false
+}
+
+func isOr(e evaluator.Expression) ([]evaluator.Query, bool) {
+	if o, ok := e.(*evaluator.OrExpression); ok {
+		return o.Expressions, true
+	}
+	return nil, false
+}
+
+// child is a member of an and/or group.
+type child struct {
+	q    evaluator.Query
+	path string
+}
+
+// group returns the members of a group, taking the members of nested groups
+// of the same kind as its own.
+func group(qs []evaluator.Query, path string, same func(evaluator.Expression) ([]evaluator.Query, bool)) []child {
+	var out []child
+	for i, q := range qs {
+		p := fmt.Sprintf("%s.Expressions[%d]", path, i)
+		if inner, ok := same(q.Expression); ok && q.OnEvalError == evaluator.FailError {
+			out = append(out, group(inner, p+".Expression.Expression", same)...)
+			continue
+		}
+		out = append(out, child{q: q, path: p})
+	}
+	return out
+}
+
+// clause is a group member that limits a field to an interval.
+type clause struct {
+	child
+	field string
+	kind  kind
+	iv    interval
+}
+
+// clauses returns the members of a group that are intervals, keyed by field
+// and kind in the order each key first appears.
+func clauses(children []child) [][]clause {
+	index := map[string]int{}
+	var out [][]clause
+	for _, c := range children {
+		field, iv, ok := constraint(c.q.Expression)
+		if !ok {
+			continue
+		}
+		key := field + "\x00" + strconv.Itoa(int(iv.kind))
+		n, ok := index[key]
+		if !ok {
+			n = len(out)
+			index[key] = n
+			out = append(out, nil)
+		}
+		out[n] = append(out[n], clause{child: c, field: field, kind: iv.kind, iv: iv})
+	}
+	return out
+}
+
+func checkAnd(children []child, path string, out *[]Finding) {
+	for _, cs := range clauses(children) {
+		all := universe(cs[0].kind)
+		for _, c := range cs {
+			all = all.intersect(c.iv)
+		}
+		if all.empty() {
+			*out = append(*out, Finding{
+				Code:    "contradiction",
+				Path:    path,
+				Message: fmt.Sprintf("%s can never match", join(cs, "and")),
+				Fix:     "remove the clause that is wrong, or the whole query if it is unused",
+			})
+			continue
+		}
+		removed := make([]bool, len(cs))
+		for i, c := range cs {
+			others := universe(c.kind)
+			var by []clause
+			for j, o := range cs {
+				if j != i && !removed[j] {
+					others = others.intersect(o.iv)
+					by = append(by, o)
+				}
+			}
+			if len(by) == 0 || !c.iv.contains(others) {
+				continue
+			}
+			removed[i] = true
+			*out = append(*out, Finding{
+				Code:    "redundant",
+				Path:    c.path,
+				Message: fmt.Sprintf("%s is implied by %s", show(c.q), join(by, "and")),
+				Fix:     "remove " + show(c.q),
+			})
+		}
+	}
+}
+
+func checkOr(children []child, path string, out *[]Finding) {
+	for _, cs := range clauses(children) {
+		if len(cs) > 1 && covers(cs) {
+			*out = append(*out, Finding{
+				Code:    "tautology",
+				Path:    path,
+				Message: fmt.Sprintf("%s matches every record whose %s is a %s", join(cs, "or"), cs[0].field, cs[0].kind),
+				Fix:     fmt.Sprintf("remove these clauses, or replace them with a check that %s is set", cs[0].field),
+			})
+			continue
+		}
+		removed := make([]bool, len(cs))
+		for i, c := range cs {
+			for j, o := range cs {
+				if j == i || removed[j] || !o.iv.contains(c.iv) {
+					continue
+				}
+				removed[i] = true
+				*out = append(*out, Finding{
+					Code:    "redundant",
+					Path:    c.path,
+					Message: fmt.Sprintf("%s is covered by %s", show(c.q), show(o.q)),
+					Fix:     "remove " + show(c.q),
+				})
+				break
+			}
+		}
+	}
+}
+
+// covers reports whether the intervals of cs together hold every value of
+// their kind.
+func covers(cs []clause) bool {
+	ivs := make([]interval, len(cs))
+	for i, c := range cs {
+		ivs[i] = c.iv
+	}
+	sort.Slice(ivs, func(a, b int) bool { return lowerLess(ivs[a].lo, ivs[b].lo) })
+	if !sameBound(ivs[0].lo, universe(ivs[0].kind).lo) {
+		return false
+	}
+	reach := ivs[0].hi
+	for _, iv := range ivs[1:] {
+		if reach.v == nil {
+			return true
+		}
+		if iv.lo.v != nil {
+			c := compare(iv.lo.v, reach.v)
+			if c > 0 || c == 0 && !iv.lo.incl && !reach.incl {
+				return false
+			}
+		}
+		if upperLess(reach, iv.hi) {
+			reach = iv.hi
+		}
+	}
+	return reach.v == nil
+}
+
+func sameBound(a, b bound) bool {
+	if a.v == nil || b.v == nil {
+		return a.v == nil && b.v == nil
+	}
+	return compare(a.v, b.v) == 0 && a.incl == b.incl
+}
+
+func show(q evaluator.Query) string {
+	return simple.Stringify(q)
+}
+
+func join(cs []clause, op string) string {
+	parts := make([]string, len(cs))
+	for i, c := range cs {
+		parts[i] = show(c.q)
+	}
+	return strings.Join(parts, " "+op+" ")
+}
+
+// constraint returns the field e compares and the interval of values that
+// satisfy it, or false when e is not a comparison lint understands.
+func constraint(e evaluator.Expression) (string, interval, bool) {
+	switch ex := e.(type) {
+	case *evaluator.IsExpression:
+		if ex.Fold {
+			return "", interval{}, false
+		}
+		return point(ex.Field, ex.Value)
+	case *evaluator.GreaterThanExpression:
+		return half(ex.Field, ex.Value, ex.Fold, true, false)
+	case *evaluator.GreaterThanOrEqualExpression:
+		return half(ex.Field, ex.Value, ex.Fold, true, true)
+	case *evaluator.LessThanExpression:
+		return half(ex.Field, ex.Value, ex.Fold, false, false)
+	case *evaluator.LessThanOrEqualExpression:
+		return half(ex.Field, ex.Value, ex.Fold, false, true)
+	case *evaluator.BeforeExpression:
+		if t, ok := timeOf(ex.Value); ok {
+			return half(ex.Field, t, false, false, false)
+		}
+	case *evaluator.AfterExpression:
+		if t, ok := timeOf(ex.Value); ok {
+			return half(ex.Field, t, false, true, false)
+		}
+	case *evaluator.BetweenExpression:
+		k, lo, ok := literal(ex.Low)
+		if !ok {
+			break
+		}
+		k2, hi, ok := literal(ex.High)
+		if !ok || k2 != k {
+			break
+		}
+		return ex.Field, interval{
+			kind: k,
+			lo:   bound{v: lo, incl: ex.Inclusive},
+			hi:   bound{v: hi, incl: ex.Inclusive},
+		}.normal(), true
+	case *evaluator.ComparisonExpression:
+		f, ok := ex.LHS.(evaluator.Field)
+		c, cok := ex.RHS.(evaluator.Constant)
+		op := ex.Operation
+		if !ok || !cok {
+			f, ok = ex.RHS.(evaluator.Field)
+			c, cok = ex.LHS.(evaluator.Constant)
+			op = map[string]string{"gt": "lt", "gte": "lte", "lt": "gt", "lte": "gte", "eq": "eq"}[op]
+		}
+		if !ok || !cok {
+			break
+		}
+		switch op {
+		case "eq":
+			return point(f.Name, c.Value)
+		case "gt", "gte", "lt", "lte":
+			return half(f.Name, c.Value, false, op[0] == 'g', strings.HasSuffix(op, "e"))
+		}
+	}
+	return "", interval{}, false
+}
+
+func point(field string, v interface{}) (string, interval, bool) {
+	k, x, ok := literal(v)
+	if !ok {
+		return "", interval{}, false
+	}
+	return field, interval{kind: k, lo: bound{v: x, incl: true}, hi: bound{v: x, incl: true}}, true
+}
+
+func half(field string, v interface{}, fold, lower, incl bool) (string, interval, bool) {
+	if fold {
+		return "", interval{}, false
+	}
+	k, x, ok := literal(v)
+	if !ok {
+		return "", interval{}, false
+	}
+	iv := interval{kind: k}
+	if lower {
+		iv.lo = bound{v: x, incl: incl}
+	} else {
+		iv.hi = bound{v: x, incl: incl}
+	}
+	return field, iv.normal(), true
+}
+
+func timeOf(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		p, err := time.Parse(time.RFC3339Nano, t)
+		return p, err == nil
+	}
+	return time.Time{}, false
+}
+
+// literal returns the kind of v and v as a float64, string or time.Time.
+func literal(v interface{}) (kind, interface{}, bool) {
+	switch x := v.(type) {
+	case string:
+		if _, err := strconv.ParseFloat(x, 64); err == nil {
+			return 0, nil, false
+		}
+		return kindString, x, true
+	case time.Time:
+		return kindTime, x, true
+	case int:
+		return kindNumber, float64(x), true
+	case int8:
+		return kindNumber, float64(x), true
+	case int16:
+		return kindNumber, float64(x), true
+	case int32:
+		return kindNumber, float64(x), true
+	case int64:
+		return kindNumber, float64(x), true
+	case uint:
+		return kindNumber, float64(x), true
+	case uint8:
+		return kindNumber, float64(x), true
+	case uint16:
+		return kindNumber, float64(x), true
+	case uint32:
+		return kindNumber, float64(x), true
+	case uint64:
+		return kindNumber, float64(x), true
+	case float32:
+		return kindNumber, float64(x), true
+	case float64:
+		return kindNumber, x, true
+	}
+	return 0, nil, false
+}
@@ -0,0 +1,80 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`Age > 10 and Age < 5`, []string{
+			"contradiction at $: Age > 10 and Age < 5 can never match; remove the clause that is wrong, or the whole query if it is unused",
+		}},
+		{`Age >= 5 and Age <= 5`, nil},
+		{`Age > 5 and Age <= 5`, []string{
+			"contradiction at $: Age > 5 and Age <= 5 can never match; remove the clause that is wrong, or the whole query if it is unused",
+		}},
+		{`Name is "bob" and (Active and Name is "alice")`, []string{
+			`contradiction at $: Name is "bob" and Name is "alice" can never match; remove the clause that is wrong, or the whole query if it is unused`,
+		}},
+		{`Age > 3 and Age > 5 and Active`, []string{
+			"redundant at $.Expression.Expression.Expressions[0].Expression.Expression.Expressions[0]: Age > 3 is implied by Age > 5; remove Age > 3",
+		}},
+		{`Name is "bob" and Name > "a"`, []string{
+			`redundant at $.Expression.Expression.Expressions[1]: Name > "a" is implied by Name is "bob"; remove Name > "a"`,
+		}},
+		{`Age > 5 or Age <= 5`, []string{
+			"tautology at $: Age > 5 or Age <= 5 matches every record whose Age is a number; remove these clauses, or replace them with a check that Age is set",
+		}},
+		{`Age < 5 or Age > 5`, nil},
+		{`Name < "m" or Name >= "m"`, []string{
+			`tautology at $: Name < "m" or Name >= "m" matches every record whose Name is a string; remove these clauses, or replace them with a check that Name is set`,
+		}},
+		{`Age > 10 or Age > 5`, []string{
+			"redundant at $.Expression.Expression.Expressions[0]: Age > 10 is covered by Age > 5; remove Age > 10",
+		}},
+		{`not (Age > 1 and Age > 2)`, []string{
+			"redundant at $.Expression.Expression.Expression.Expression.Expression.Expressions[0]: Age > 1 is implied by Age > 2; remove Age > 1",
+		}},
+		{`Age > 10 and Age < "5"`, nil},
+		{`Age > 1 and Score < 0 and Age < 100`, nil},
+	}
+	for _, tt := range tests {
+		q, err := simple.Parse(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		var got []string
+		for _, f := range Check(q) {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTimes(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := evaluator.Query{Expression: &evaluator.AndExpression{Expressions: []evaluator.Query{
+		{Expression: &evaluator.AfterExpression{Field: "At", Value: jan.AddDate(0, 1, 0).Format(time.RFC3339)}},
+		{Expression: &evaluator.LessThanExpression{Field: "At", Value: jan}},
+	}}}
+	if got := Check(q); len(got) != 1 || got[0].Code != "contradiction" {
+		t.Errorf("got %v", got)
+	}
+	q = evaluator.Query{Expression: &evaluator.AndExpression{Expressions: []evaluator.Query{
+		{Expression: &evaluator.BetweenExpression{Field: "At", Low: jan, High: jan.AddDate(1, 0, 0), Inclusive: true}},
+		{Expression: &evaluator.AfterExpression{Field: "At", Value: jan.AddDate(0, -1, 0)}},
+	}}}
+	if got := Check(q); len(got) != 1 || got[0].Code != "redundant" || got[0].Path != "$.Expression.Expression.Expressions[1]" {
+		t.Errorf("got %v", got)
+	}
+}
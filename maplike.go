@@ -0,0 +1,55 @@
+package evaluator
+
+import "reflect"
+
+// MapGetter is implemented by map-like containers, such as ordered maps,
+// whose Get reports whether the key is present. Records implementing it, or
+// Getter, are read through Get without being converted to a map.
+type MapGetter interface {
+	Get(key string) (interface{}, bool)
+}
+
+// loader is implemented by *sync.Map, which is evaluated in place.
+type loader interface {
+	Load(key any) (any, bool)
+}
+
+// fieldResolver looks fields up in a record that resolves its own fields. It
+// is a value rather than a closure so that finding one allocates nothing.
+type fieldResolver struct {
+	r interface{}
+}
+
+// resolver returns the field lookup of a record that resolves its own
+// fields: a Getter, a MapGetter or a *sync.Map.
+func resolver(v reflect.Value) (fieldResolver, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return fieldResolver{}, false
+	}
+	switch r := v.Interface().(type) {
+	case Getter, MapGetter, loader:
+		return fieldResolver{r: r}, true
+	}
+	return fieldResolver{}, false
+}
+
+// get returns the field name and whether the record has it.
+func (f fieldResolver) get(name string) (interface{}, bool) {
+	switch r := f.r.(type) {
+	case Getter:
+		val, err := r.Get(name)
+		if debugChecks {
+			debugCheckResolve(name, val, err == nil)
+		}
+		return val, err == nil
+	case MapGetter:
+		val, ok := r.Get(name)
+		if debugChecks {
+			debugCheckResolve(name, val, ok)
+		}
+		return val, ok
+	case loader:
+		return r.Load(name)
+	}
+	return nil, false
+}
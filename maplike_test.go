@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"sync"
+	"testing"
+)
+
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+func TestMapLikeRecords(t *testing.T) {
+	var cache sync.Map
+	cache.Store("Status", "open")
+	cache.Store("Hits", 12)
+	cache.Store("Owner", nil)
+	om := &orderedMap{
+		keys:   []string{"Status", "Nested"},
+		values: map[string]interface{}{"Status": "closed", "Nested": &cache},
+	}
+	tests := []struct {
+		name string
+		in   interface{}
+		e    Expression
+		want bool
+	}{
+		{"sync.Map", &cache, IsExpression{Field: "Status", Value: "open"}, true},
+		{"sync.Map number", &cache, &GreaterThanExpression{Field: "Hits", Value: 10}, true},
+		{"sync.Map nil value", &cache, IsEmptyExpression{Field: "Owner"}, true},
+		{"sync.Map missing", &cache, IsExpression{Field: "Missing", Value: ""}, false},
+		{"MapGetter", om, IsExpression{Field: "Status", Value: "closed"}, true},
+		{"MapGetter missing", om, IsExpression{Field: "Hits", Value: 12}, false},
+		{"nested sync.Map", om, IsExpression{Field: "Nested.Status", Value: "open"}, true},
+		{"nil sync.Map", (*sync.Map)(nil), IsExpression{Field: "Status", Value: "open"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.Evaluate(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// MarshalFormat is a MarshalQuery option selecting the output layout.
+type MarshalFormat int
+
+const (
+	// Compact writes the query on a single line, for wire use.
+	Compact MarshalFormat = iota
+	// Pretty indents the query by two spaces per level and ends it with a
+	// newline, for files that are reviewed as diffs.
+	Pretty
+)
+
+// MarshalQuery encodes q as JSON deterministically. Map values are written
+// with sorted keys, including maps with non-string keys such as those
+// decoded from YAML, byte slices are written as strings rather than Base64
+// and <, > and & are not escaped. Pass Pretty for indented output; the
+// default is Compact.
+func MarshalQuery(q Query, opts ...any) ([]byte, error) {
+	format := Compact
+	for _, o := range opts {
+		if f, ok := o.(MarshalFormat); ok {
+			format = f
+		}
+	}
+	nq, ok := normalizeForJSON(reflect.ValueOf(q)).Interface().(Query)
+	if !ok {
+		return nil, fmt.Errorf("marshal query: unexpected normalized type")
+	}
+	data, err := json.Marshal(nq)
+	if err != nil {
+		return nil, err
+	}
+	data = unescapeHTML(data)
+	if format != Pretty {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// htmlEscapes maps the escapes json.Marshal uses for HTML characters back to
+// the characters.
+var htmlEscapes = map[string]byte{`\u003c`: '<', `\u003e`: '>', `\u0026`: '&'}
+
+var (
+	bytesType     = reflect.TypeOf([]byte(nil))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	queryType     = reflect.TypeOf(Query{})
+)
+
+// normalizeForJSON returns a copy of v with []byte values replaced by
+// strings and maps with non-string keys replaced by map[string]interface{}
+// wherever the enclosing type allows. Values that marshal themselves, other
+// than Query, are returned unchanged.
+func normalizeForJSON(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	t := v.Type()
+	if t != queryType && t.Kind() != reflect.Interface && (t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType)) {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(t).Elem()
+		setNormalized(out, v.Elem())
+		return out
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return v
+		}
+		out := reflect.New(t.Elem())
+		out.Elem().Set(normalizeForJSON(v.Elem()))
+		return out
+	case reflect.Struct:
+		out := reflect.New(t).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				setNormalized(out.Field(i), v.Field(i))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if t == bytesType {
+			return reflect.ValueOf(string(v.Bytes()))
+		}
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			setNormalized(out.Index(i), v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		if t.Key().Kind() == reflect.String {
+			out := reflect.MakeMapWithSize(t, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				el := reflect.New(t.Elem()).Elem()
+				setNormalized(el, iter.Value())
+				out.SetMapIndex(iter.Key(), el)
+			}
+			return out
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = normalizeForJSON(iter.Value()).Interface()
+		}
+		return reflect.ValueOf(out)
+	}
+	return v
+}
+
+// setNormalized stores the normalized form of v in dst, or v itself when
+// the normalized form does not fit dst's type.
+func setNormalized(dst, v reflect.Value) {
+	if n := normalizeForJSON(v); n.IsValid() && n.Type().AssignableTo(dst.Type()) {
+		dst.Set(n)
+		return
+	}
+	if v.IsValid() {
+		dst.Set(v)
+	}
+}
+
+// unescapeHTML undoes the \u003c, \u003e and \u0026 escapes json.Marshal
+// writes for <, > and & inside strings.
+func unescapeHTML(data []byte) []byte {
+	if !bytes.Contains(data, []byte(`\u00`)) {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\\' || i+1 >= len(data) {
+			out = append(out, data[i])
+			continue
+		}
+		switch esc := string(data[i:min(i+6, len(data))]); esc {
+		case `\u003c`, `\u003e`, `\u0026`:
+			out = append(out, htmlEscapes[esc])
+			i += 5
+		default:
+			// Copy the escape, including an escaped backslash, whole.
+			out = append(out, data[i], data[i+1])
+			i++
+		}
+	}
+	return out
+}
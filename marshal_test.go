@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMarshalQuery(t *testing.T) {
+	q := Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &IsExpression{Field: "Body", Value: []byte("<b>&</b>")}},
+		{Expression: &InExpression{Field: "Attrs", Values: []interface{}{
+			map[interface{}]interface{}{"z": 1, "a": 2},
+			map[string]interface{}{"y": `a<b`, "b": nil},
+		}}},
+	}}}
+	compact, err := MarshalQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Expression":{"Type":"And","Expression":{"Expressions":[` +
+		`{"Expression":{"Type":"Is","Expression":{"Field":"Body","Value":"<b>&</b>"}}},` +
+		`{"Expression":{"Type":"In","Expression":{"Field":"Attrs","Values":[{"a":2,"z":1},{"b":null,"y":"a<b"}]}}}]}}}`
+	if string(compact) != want {
+		t.Errorf("compact:\n got %s\nwant %s", compact, want)
+	}
+	for i := 0; i < 5; i++ {
+		again, err := MarshalQuery(q)
+		if err != nil || string(again) != string(compact) {
+			t.Fatalf("output not stable: %s %v", again, err)
+		}
+	}
+	var back Query
+	if err := json.Unmarshal(compact, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if v := back.Expression.(*AndExpression).Expressions[0].Expression.(*IsExpression).Value; v != "<b>&</b>" {
+		t.Errorf("byte slice value decoded as %#v", v)
+	}
+
+	pretty, err := MarshalQuery(q, Pretty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b interface{}
+	if err := json.Unmarshal(pretty, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(compact, &b); err != nil {
+		t.Fatal(err)
+	}
+	if string(pretty[:16]) != "{\n  \"Expression\"" || pretty[len(pretty)-1] != '\n' {
+		t.Errorf("unexpected pretty layout:\n%s", pretty)
+	}
+	if ja, _ := json.Marshal(a); string(ja) != mustMarshal(t, b) {
+		t.Errorf("pretty and compact encode different values")
+	}
+}
+
+func TestMarshalQueryKeepsOriginal(t *testing.T) {
+	gt := &GreaterThanExpression{Field: "Name", Value: []byte("m")}
+	q := Query{Expression: gt}
+	if _, err := MarshalQuery(q); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gt.Value.([]byte); !ok {
+		t.Errorf("original query modified: %#v", gt.Value)
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMarshalComparisonTerms(t *testing.T) {
+	type rec struct {
+		UserID string
+		X      int
+		Region string
+		Items  []struct{ Price float64 }
+		A, B   int
+	}
+	r := rec{UserID: "u42", X: -7, Items: []struct{ Price float64 }{{4}, {8}}, A: 6, B: 4}
+	ctx := &Context{Rand: NewSeededRand(1), Functions: map[string]Function{"upper": upperFunc{}}}
+	tests := []struct {
+		name string
+		expr *ComparisonExpression
+		want string
+	}{
+		{"bucket", &ComparisonExpression{LHS: BucketTerm{KeyField: "UserID", Buckets: 10}, RHS: Constant{Value: 3}, Operation: "eq"},
+			`{"LHS":{"Type":"Bucket","Term":{"KeyField":"UserID","Buckets":10}},"RHS":{"Type":"Constant","Term":{"Value":3}},"Operation":"eq"}`},
+		{"hash mod", &ComparisonExpression{LHS: &ModTerm{LHS: HashTerm{Term: Field{Name: "UserID"}, Salt: "s"}, RHS: Constant{Value: 100}}, RHS: Constant{Value: 50}, Operation: "lt"},
+			`{"LHS":{"Type":"Mod","Term":{"LHS":{"Type":"Hash","Term":{"Term":{"Type":"Field","Term":{"Name":"UserID"}},"Salt":"s"}},"RHS":{"Type":"Constant","Term":{"Value":100}}}},"RHS":{"Type":"Constant","Term":{"Value":50}},"Operation":"lt"}`},
+		{"random", &ComparisonExpression{LHS: RandomTerm{}, RHS: Constant{Value: 2}, Operation: "lt"},
+			`{"LHS":{"Type":"Random","Term":{}},"RHS":{"Type":"Constant","Term":{"Value":2}},"Operation":"lt"}`},
+		{"coalesce", &ComparisonExpression{LHS: CoalesceTerm{Terms: []Term{Field{Name: "Region"}, Constant{Value: "unknown"}}}, RHS: Constant{Value: "unknown"}, Operation: "eq"},
+			`{"LHS":{"Type":"Coalesce","Term":{"Terms":[{"Type":"Field","Term":{"Name":"Region"}},{"Type":"Constant","Term":{"Value":"unknown"}}]}},"RHS":{"Type":"Constant","Term":{"Value":"unknown"}},"Operation":"eq"}`},
+		{"aggregates", &ComparisonExpression{LHS: AddTerm{LHS: SumTerm{Field: "Items.Price"}, RHS: AvgTerm{Field: "Items.Price"}}, RHS: SubTerm{LHS: MaxTerm{Field: "Items.Price"}, RHS: MinTerm{Field: "Items.Price"}}, Operation: "gt"},
+			`{"LHS":{"Type":"Add","Term":{"LHS":{"Type":"Sum","Term":{"Field":"Items.Price"}},"RHS":{"Type":"Avg","Term":{"Field":"Items.Price"}}}},"RHS":{"Type":"Sub","Term":{"LHS":{"Type":"Max","Term":{"Field":"Items.Price"}},"RHS":{"Type":"Min","Term":{"Field":"Items.Price"}}}},"Operation":"gt"}`},
+		{"mod", &ComparisonExpression{LHS: ModTerm{LHS: Field{Name: "X"}, RHS: Constant{Value: 10}}, RHS: Constant{Value: 0}, Operation: "gt"},
+			`{"LHS":{"Type":"Mod","Term":{"LHS":{"Type":"Field","Term":{"Name":"X"}},"RHS":{"Type":"Constant","Term":{"Value":10}}}},"RHS":{"Type":"Constant","Term":{"Value":0}},"Operation":"gt"}`},
+		{"function", &ComparisonExpression{LHS: FunctionExpression{Name: "upper", Args: []Term{Field{Name: "UserID"}}}, RHS: If{Condition: BoolType{Term: Constant{Value: "true"}}, Then: Constant{Value: "U42"}, Else: Self{}}, Operation: "eq"},
+			`{"LHS":{"Type":"Function","Term":{"Name":"upper","Args":[{"Type":"Field","Term":{"Name":"UserID"}}]}},"RHS":{"Type":"If","Term":{"Condition":{"Type":"Bool","Term":{"Term":{"Type":"Constant","Term":{"Value":"true"}}}},"Then":{"Type":"Constant","Term":{"Value":"U42"}},"Else":{"Type":"Self","Term":{}}}},"Operation":"eq"}`},
+		{"mul div", &ComparisonExpression{LHS: MulTerm{LHS: Field{Name: "A"}, RHS: Field{Name: "B"}}, RHS: DivTerm{LHS: Constant{Value: 48}, RHS: Constant{Value: 2}}, Operation: "eq"},
+			`{"LHS":{"Type":"Mul","Term":{"LHS":{"Type":"Field","Term":{"Name":"A"}},"RHS":{"Type":"Field","Term":{"Name":"B"}}}},"RHS":{"Type":"Div","Term":{"LHS":{"Type":"Constant","Term":{"Value":48}},"RHS":{"Type":"Constant","Term":{"Value":2}}}},"Operation":"eq"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Query{Expression: tt.expr}
+			data, err := MarshalQuery(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `{"Expression":{"Type":"Comparison","Expression":` + tt.want + `}}`; string(data) != want {
+				t.Errorf("marshal:\n got %s\nwant %s", data, want)
+			}
+			var back Query
+			if err := json.Unmarshal(data, &back); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			want, err := q.Evaluate(&r, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := back.Evaluate(&r, ctx)
+			if err != nil || got != want {
+				t.Errorf("decoded query evaluates to %v, %v; want %v", got, err, want)
+			}
+			again, err := MarshalQuery(back)
+			if err != nil || string(again) != string(data) {
+				t.Errorf("re-marshal:\n got %s %v\nwant %s", again, err, data)
+			}
+		})
+	}
+	if _, err := MarshalQuery(Query{Expression: &ComparisonExpression{LHS: FunctionExpression{Func: upperFunc{}}, RHS: Constant{}, Operation: "eq"}}); err == nil {
+		t.Error("expected an error marshaling an unnamed function")
+	}
+}
+
+type upperFunc struct{}
+
+func (upperFunc) Call(args ...interface{}) (interface{}, error) {
+	return strings.ToUpper(fmt.Sprint(args...)), nil
+}
@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMatchesExpression(t *testing.T) {
+	e := &MatchesExpression{Field: "Email", Pattern: `@example\.(com|org)$`}
+	for in, want := range map[string]bool{
+		"a@example.com": true,
+		"a@example.net": false,
+		"example.org":   false,
+	} {
+		got, err := e.Evaluate(map[string]interface{}{"Email": in})
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %v, want %v", in, got, want)
+		}
+	}
+	if got, _ := e.Evaluate(map[string]interface{}{"Email": 3}); got {
+		t.Errorf("non-string field matched")
+	}
+	first := e.re.Load()
+	_, _ = e.Evaluate(map[string]interface{}{"Email": "x"})
+	if e.re.Load() != first {
+		t.Errorf("pattern recompiled")
+	}
+	e.Pattern = "^x$"
+	if got, _ := e.Evaluate(map[string]interface{}{"Email": "x"}); !got {
+		t.Errorf("changed pattern not used")
+	}
+}
+
+func TestMatchesExpressionInvalid(t *testing.T) {
+	e := &MatchesExpression{Field: "A", Pattern: "("}
+	if _, err := e.Evaluate(map[string]interface{}{"A": "("}); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+}
+
+func TestMatchesExpressionJSON(t *testing.T) {
+	q := Query{Expression: &MatchesExpression{Field: "Name", Pattern: "^b"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"Matches","Expression":{"Field":"Name","Pattern":"^b"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := back.Evaluate(&struct{ Name string }{"bob"}); !ok {
+		t.Errorf("round-tripped query did not match")
+	}
+}
@@ -0,0 +1,218 @@
+package evaluator
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rangeBound is one end of a merged range.
+type rangeBound struct {
+	value interface{}
+	incl  bool
+}
+
+// fieldRange collects the comparisons of an and group on one field.
+type fieldRange struct {
+	first  int
+	n      int
+	lo, hi *rangeBound
+}
+
+// mergeRanges replaces the comparisons of qs, the members of an and group,
+// that limit the same field with at most two: a BetweenExpression when the
+// tightest bounds are both inclusive or both exclusive, and otherwise the
+// tightest lower and upper comparisons. Numbers, strings and times are
+// merged separately, and strings that look like numbers not at all.
+func mergeRanges(qs []Query) []Query {
+	ranges := map[string]*fieldRange{}
+	keys := make([]string, len(qs))
+	for i, q := range qs {
+		field, lo, hi, ok := rangeOf(q)
+		if !ok {
+			continue
+		}
+		kind, ok := rangeKind(lo, hi)
+		if !ok {
+			continue
+		}
+		key := field + "\x00" + kind
+		keys[i] = key
+		r := ranges[key]
+		if r == nil {
+			r = &fieldRange{first: i}
+			ranges[key] = r
+		}
+		r.n++
+		if lo != nil && (r.lo == nil || tighter(*lo, *r.lo, 1)) {
+			r.lo = lo
+		}
+		if hi != nil && (r.hi == nil || tighter(*hi, *r.hi, -1)) {
+			r.hi = hi
+		}
+	}
+	out := make([]Query, 0, len(qs))
+	for i, q := range qs {
+		r := ranges[keys[i]]
+		if keys[i] == "" || r.n < 2 {
+			out = append(out, q)
+			continue
+		}
+		if r.first != i {
+			continue
+		}
+		field := keys[i][:strings.IndexByte(keys[i], 0)]
+		out = append(out, rangeQueries(field, r.lo, r.hi)...)
+	}
+	return out
+}
+
+// rangeOf returns the field q limits and its bounds, or false when q is not
+// a plain comparison.
+func rangeOf(q Query) (string, *rangeBound, *rangeBound, bool) {
+	if q.OnEvalError != FailError {
+		return "", nil, nil, false
+	}
+	switch ex := q.Expression.(type) {
+	case *GreaterThanExpression:
+		return ex.Field, &rangeBound{ex.Value, false}, nil, !ex.Fold
+	case *GreaterThanOrEqualExpression:
+		return ex.Field, &rangeBound{ex.Value, true}, nil, !ex.Fold
+	case *LessThanExpression:
+		return ex.Field, nil, &rangeBound{ex.Value, false}, !ex.Fold
+	case *LessThanOrEqualExpression:
+		return ex.Field, nil, &rangeBound{ex.Value, true}, !ex.Fold
+	case *BetweenExpression:
+		return ex.Field, &rangeBound{ex.Low, ex.Inclusive}, &rangeBound{ex.High, ex.Inclusive}, true
+	}
+	return "", nil, nil, false
+}
+
+// rangeKind returns the kind shared by the bounds given, or false when they
+// differ or cannot be ordered.
+func rangeKind(bounds ...*rangeBound) (string, bool) {
+	kind := ""
+	for _, b := range bounds {
+		if b == nil {
+			continue
+		}
+		k := valueKind(b.value)
+		if k == "" || kind != "" && k != kind {
+			return "", false
+		}
+		kind = k
+	}
+	return kind, kind != ""
+}
+
+func valueKind(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		if _, err := strconv.ParseFloat(x, 64); err == nil {
+			return ""
+		}
+		return "string"
+	case time.Time:
+		return "time"
+	case bool, nil:
+		return ""
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return ""
+}
+
+// tighter reports whether a limits a range more than b, where dir is 1 for
+// lower bounds and -1 for upper bounds.
+func tighter(a, b rangeBound, dir int) bool {
+	var c int
+	switch x := a.value.(type) {
+	case string:
+		c = strings.Compare(x, b.value.(string))
+	case time.Time:
+		c = x.Compare(b.value.(time.Time))
+	default:
+		fa, _ := numeric[float64](a.value)
+		fb, _ := numeric[float64](b.value)
+		switch {
+		case fa < fb:
+			c = -1
+		case fa > fb:
+			c = 1
+		}
+	}
+	return c*dir > 0 || c == 0 && !a.incl && b.incl
+}
+
+func rangeQueries(field string, lo, hi *rangeBound) []Query {
+	if lo != nil && hi != nil && lo.incl == hi.incl {
+		return []Query{{Expression: &BetweenExpression{Field: field, Low: lo.value, High: hi.value, Inclusive: lo.incl}}}
+	}
+	var out []Query
+	switch {
+	case lo == nil:
+	case lo.incl:
+		out = append(out, Query{Expression: &GreaterThanOrEqualExpression{Field: field, Value: lo.value}})
+	default:
+		out = append(out, Query{Expression: &GreaterThanExpression{Field: field, Value: lo.value}})
+	}
+	switch {
+	case hi == nil:
+	case hi.incl:
+		out = append(out, Query{Expression: &LessThanOrEqualExpression{Field: field, Value: hi.value}})
+	default:
+		out = append(out, Query{Expression: &LessThanExpression{Field: field, Value: hi.value}})
+	}
+	return out
+}
+
+// mergeInLists replaces the InExpressions of qs, the members of an or group,
+// that test the same field with one holding all their values, at the place
+// of the first.
+func mergeInLists(qs []Query) []Query {
+	merged := map[string]*InExpression{}
+	count := map[string]int{}
+	for _, q := range qs {
+		if in, ok := q.Expression.(*InExpression); ok && q.OnEvalError == FailError {
+			count[in.Field]++
+		}
+	}
+	out := make([]Query, 0, len(qs))
+	for _, q := range qs {
+		in, ok := q.Expression.(*InExpression)
+		if !ok || q.OnEvalError != FailError || count[in.Field] < 2 {
+			out = append(out, q)
+			continue
+		}
+		m := merged[in.Field]
+		if m == nil {
+			m = &InExpression{Field: in.Field}
+			merged[in.Field] = m
+			out = append(out, Query{Expression: m})
+		}
+		m.Values = appendDistinct(m.Values, in.Values)
+	}
+	return out
+}
+
+// appendDistinct appends the values of vs not already in list.
+func appendDistinct(list, vs []interface{}) []interface{} {
+	for _, v := range vs {
+		dup := false
+		for _, have := range list {
+			if reflect.DeepEqual(have, v) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueryMetadataRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	q := Query{
+		Expression: &IsExpression{Field: "Name", Value: "bob"},
+		Metadata: &Metadata{
+			Author:      "alice",
+			Created:     &created,
+			Description: "VIP routing",
+			Version:     "3",
+			Tags:        []string{"routing", "vip"},
+		},
+	}
+	b, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var q2 Query
+	if err := json.Unmarshal(b, &q2); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(q.Metadata, q2.Metadata) {
+		t.Errorf("metadata lost in round trip: %+v vs %+v", q.Metadata, q2.Metadata)
+	}
+	if v, err := q2.Evaluate(&testUser{Name: "bob"}); err != nil || !v {
+		t.Errorf("evaluate failed: %v %v", v, err)
+	}
+
+	h1, _ := Hash(q)
+	h2, _ := Hash(Query{Expression: q.Expression})
+	if h1 != h2 {
+		t.Errorf("metadata changed the query hash")
+	}
+	if r := Redact(q, []string{"Name"}); r.Metadata != q.Metadata {
+		t.Errorf("redaction dropped metadata")
+	}
+}
+
+func TestQueryWithoutMetadataOmitsField(t *testing.T) {
+	b, err := json.Marshal(Query{Expression: &IsExpression{Field: "Name", Value: "bob"}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if _, ok := m["Metadata"]; ok {
+		t.Errorf("unexpected Metadata in %s", b)
+	}
+}
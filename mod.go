@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ModExpression succeeds when the integer Field leaves Remainder when divided
+// by Divisor, as in UserID % 10 is 3, which samples every Nth record or
+// splits IDs into buckets. The remainder is never negative, so negative IDs
+// fall in the same 0 to Divisor-1 buckets as positive ones. Floats and
+// numeric strings holding whole numbers are read as integers; other values
+// do not match. A zero Divisor is an error.
+type ModExpression struct {
+	Field     string
+	Divisor   int64
+	Remainder int64
+}
+
+func (e ModExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	if e.Divisor == 0 {
+		return false, fmt.Errorf("%s %% 0: modulo by zero", e.Field)
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok {
+		return false, nil
+	}
+	d := e.Divisor
+	if d < 0 {
+		d = -d
+	}
+	var r int64
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		r = int64(f.Uint() % uint64(d))
+	default:
+		if !f.CanInterface() {
+			return false, nil
+		}
+		n, ok := integer(f.Interface())
+		if !ok {
+			return false, nil
+		}
+		if r = n % d; r < 0 {
+			r += d
+		}
+	}
+	return r == e.Remainder, nil
+}
@@ -0,0 +1,48 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestModExpression(t *testing.T) {
+	q := Query{Expression: &ModExpression{Field: "UserID", Divisor: 10, Remainder: 3}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"Mod","Expression":{"Field":"UserID","Divisor":10,"Remainder":3}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		id   interface{}
+		want bool
+	}{
+		{13, true},
+		{int64(3), true},
+		{uint64(1<<63 + 5), true},
+		{float64(23), true},
+		{"33", true},
+		{-7, true},
+		{14, false},
+		{13.5, false},
+		{"x", false},
+		{nil, false},
+	} {
+		got, err := back.Evaluate(map[string]interface{}{"UserID": tt.id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.id, got, tt.want)
+		}
+	}
+	zero := Query{Expression: &ModExpression{Field: "UserID"}}
+	if _, err := zero.Evaluate(map[string]interface{}{"UserID": 1}); err == nil {
+		t.Errorf("expected an error for a zero divisor")
+	}
+}
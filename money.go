@@ -0,0 +1,275 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrCurrencyMismatch is returned when money in different currencies is
+// compared and no RateProvider is available to convert between them.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// MoneyValue is an amount in a specific ISO 4217 currency. Comparisons
+// involving a MoneyValue refuse to compare different currencies unless the
+// evaluation Context supplies a RateProvider.
+type MoneyValue struct {
+	Amount   float64
+	Currency string
+}
+
+// RateProvider converts between currencies.
+type RateProvider interface {
+	// Rate returns the multiplier converting an amount in from into to.
+	Rate(from, to string) (float64, error)
+}
+
+// Rates is a RateProvider backed by a table of rates keyed by "FROM/TO".
+// Inverse rates are derived automatically.
+type Rates map[string]float64
+
+func (r Rates) Rate(from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	if v, ok := r[from+"/"+to]; ok {
+		return v, nil
+	}
+	if v, ok := r[to+"/"+from]; ok && v != 0 {
+		return 1 / v, nil
+	}
+	return 0, fmt.Errorf("%w: no rate from %s to %s", ErrCurrencyMismatch, from, to)
+}
+
+var currencySymbols = map[string]string{
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"¥": "JPY",
+}
+
+// ParseMoney parses amounts such as "€10.50", "USD 3.20" or "3.20 USD".
+func ParseMoney(s string) (MoneyValue, error) {
+	s = strings.TrimSpace(s)
+	for sym, code := range currencySymbols {
+		if rest, ok := strings.CutPrefix(s, sym); ok {
+			return parseMoneyAmount(code, rest, s)
+		}
+	}
+	if code, rest, ok := strings.Cut(s, " "); ok && isCurrencyCode(code) {
+		return parseMoneyAmount(code, rest, s)
+	}
+	if rest, code, ok := strings.Cut(s, " "); ok && isCurrencyCode(code) {
+		return parseMoneyAmount(code, rest, s)
+	}
+	return MoneyValue{}, fmt.Errorf("invalid money value %q", s)
+}
+
+func parseMoneyAmount(code, amount, orig string) (MoneyValue, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil {
+		return MoneyValue{}, fmt.Errorf("invalid money value %q", orig)
+	}
+	return MoneyValue{Amount: f, Currency: code}, nil
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the value as "USD 3.20".
+func (m MoneyValue) String() string {
+	return m.Currency + " " + strconv.FormatFloat(m.Amount, 'f', 2, 64)
+}
+
+// MarshalText encodes the value as "USD 3.205", keeping every digit of the
+// amount.
+func (m MoneyValue) MarshalText() ([]byte, error) {
+	return []byte(m.Currency + " " + strconv.FormatFloat(m.Amount, 'f', -1, 64)), nil
+}
+
+// UnmarshalText parses the String form.
+func (m *MoneyValue) UnmarshalText(b []byte) error {
+	v, err := ParseMoney(string(b))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// moneyJSON is the JSON form of a MoneyValue. The Type tag lets money held in
+// an interface{}, such as the Value of a comparison, be told apart from a
+// string when a query is unmarshaled, and Amount is a decimal string so no
+// digits are lost.
+type moneyJSON struct {
+	Type     string
+	Amount   string
+	Currency string
+}
+
+// MarshalJSON encodes the value as {"Type":"Money","Amount":"3.205","Currency":"USD"}.
+func (m MoneyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Type: "Money", Amount: strconv.FormatFloat(m.Amount, 'f', -1, 64), Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes the MarshalJSON form or a string in the String form.
+func (m *MoneyValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return m.UnmarshalText([]byte(s))
+	}
+	var mj moneyJSON
+	if err := json.Unmarshal(b, &mj); err != nil {
+		return err
+	}
+	v, ok := mj.money()
+	if !ok {
+		return fmt.Errorf("invalid money value %s", b)
+	}
+	*m = v
+	return nil
+}
+
+func (mj moneyJSON) money() (MoneyValue, bool) {
+	if mj.Type != "Money" || !isCurrencyCode(mj.Currency) {
+		return MoneyValue{}, false
+	}
+	f, err := strconv.ParseFloat(mj.Amount, 64)
+	if err != nil {
+		return MoneyValue{}, false
+	}
+	return MoneyValue{Amount: f, Currency: mj.Currency}, true
+}
+
+// decodeMoney returns v with every object in the MarshalJSON form of a
+// MoneyValue, as json.Unmarshal leaves it in an interface{}, replaced by the
+// MoneyValue.
+func decodeMoney(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if len(x) == 3 {
+			amount, _ := x["Amount"].(string)
+			typ, _ := x["Type"].(string)
+			currency, _ := x["Currency"].(string)
+			if m, ok := (moneyJSON{Type: typ, Amount: amount, Currency: currency}).money(); ok {
+				return m
+			}
+		}
+		for k, e := range x {
+			x[k] = decodeMoney(e)
+		}
+	case []interface{}:
+		for n, e := range x {
+			x[n] = decodeMoney(e)
+		}
+	}
+	return v
+}
+
+// decodeExpressionMoney applies decodeMoney to the interface{} and
+// []interface{} fields of an unmarshaled expression.
+func decodeExpressionMoney(e Expression) {
+	v := reflect.ValueOf(e)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	v = v.Elem()
+	for n := 0; n < v.NumField(); n++ {
+		f := v.Field(n)
+		if !f.CanSet() {
+			continue
+		}
+		switch f.Type() {
+		case interfaceType, interfaceSliceType:
+			if !f.IsNil() {
+				f.Set(reflect.ValueOf(decodeMoney(f.Interface())))
+			}
+		}
+	}
+}
+
+var (
+	interfaceType      = reflect.TypeOf((*interface{})(nil)).Elem()
+	interfaceSliceType = reflect.TypeOf([]interface{}(nil))
+)
+
+// Compare implements Comparator. other may be a MoneyValue or a string
+// accepted by ParseMoney; different currencies give ErrCurrencyMismatch.
+func (m MoneyValue) Compare(other interface{}) (int, error) {
+	return compareMoney(m, other, nil)
+}
+
+// toMoney converts v to a MoneyValue when it is one or parses as one.
+func toMoney(v interface{}) (MoneyValue, bool) {
+	switch x := v.(type) {
+	case MoneyValue:
+		return x, true
+	case *MoneyValue:
+		if x != nil {
+			return *x, true
+		}
+	case string:
+		if mv, err := ParseMoney(x); err == nil {
+			return mv, true
+		}
+	}
+	return MoneyValue{}, false
+}
+
+func compareMoney(a MoneyValue, other interface{}, rates RateProvider) (int, error) {
+	b, ok := toMoney(other)
+	if !ok {
+		return 0, fmt.Errorf("%w: cannot compare %s with %v", ErrCurrencyMismatch, a, other)
+	}
+	if a.Currency != b.Currency {
+		if rates == nil {
+			return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+		}
+		r, err := rates.Rate(b.Currency, a.Currency)
+		if err != nil {
+			return 0, err
+		}
+		b = MoneyValue{Amount: b.Amount * r, Currency: a.Currency}
+	}
+	switch {
+	case a.Amount < b.Amount:
+		return -1, nil
+	case a.Amount > b.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// moneyCompare compares a field value against an expression value when
+// either of them is a MoneyValue, converting currencies with the Context
+// RateProvider. It reports false when neither side is money.
+func moneyCompare(f reflect.Value, value interface{}, opts []any) (int, bool, error) {
+	if m, ok := value.(MoneyValue); ok {
+		var fv interface{}
+		if f.IsValid() && f.CanInterface() {
+			fv = f.Interface()
+		}
+		c, err := compareMoney(m, fv, GetContext(opts...).Rates)
+		return -c, true, err
+	}
+	if f.IsValid() && f.Type() == moneyType && f.CanInterface() {
+		c, err := compareMoney(f.Interface().(MoneyValue), value, GetContext(opts...).Rates)
+		return c, true, err
+	}
+	return 0, false, nil
+}
+
+var moneyType = reflect.TypeOf(MoneyValue{})
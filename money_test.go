@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type invoice struct {
+	Total MoneyValue
+}
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]MoneyValue{
+		"€10.50":   {Amount: 10.5, Currency: "EUR"},
+		"USD 3.20": {Amount: 3.2, Currency: "USD"},
+		"3.20 GBP": {Amount: 3.2, Currency: "GBP"},
+		"$5":       {Amount: 5, Currency: "USD"},
+	}
+	for in, want := range cases {
+		got, err := ParseMoney(in)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %v, got %v (%v)", in, want, got, err)
+		}
+	}
+	for _, bad := range []string{"10.50", "usd 3", "EUR ten", ""} {
+		if _, err := ParseMoney(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMoneyComparisons(t *testing.T) {
+	inv := &invoice{Total: MoneyValue{Amount: 12, Currency: "EUR"}}
+	if v, err := (&GreaterThanExpression{Field: "Total", Value: MoneyValue{Amount: 10.5, Currency: "EUR"}}).Evaluate(inv); err != nil || !v {
+		t.Errorf("gt failed: %v %v", v, err)
+	}
+	if v, err := (IsExpression{Field: "Total", Value: "€12"}).Evaluate(inv); err != nil || !v {
+		t.Errorf("is failed: %v %v", v, err)
+	}
+	v, err := (&LessThanExpression{Field: "Total", Value: MoneyValue{Amount: 20, Currency: "USD"}}).Evaluate(inv)
+	if !errors.Is(err, ErrCurrencyMismatch) || v {
+		t.Errorf("expected currency mismatch, got %v %v", v, err)
+	}
+
+	ctx := &Context{Rates: Rates{"USD/EUR": 0.5}}
+	if v, err := (&LessThanExpression{Field: "Total", Value: MoneyValue{Amount: 20, Currency: "USD"}}).Evaluate(inv, ctx); err != nil || v {
+		t.Errorf("20 USD converts to 10 EUR so lt should be false: %v %v", v, err)
+	}
+	if v, err := (&GreaterThanOrEqualExpression{Field: "Total", Value: MoneyValue{Amount: 24, Currency: "USD"}}).Evaluate(inv, ctx); err != nil || !v {
+		t.Errorf("gte after conversion failed: %v %v", v, err)
+	}
+
+	cmp := ComparisonExpression{LHS: Field{Name: "Total"}, RHS: Constant{Value: MoneyValue{Amount: 12, Currency: "JPY"}}, Operation: "eq"}
+	if _, err := cmp.Evaluate(inv); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected currency mismatch, got %v", err)
+	}
+}
+
+func TestMoneyQueryRoundTrip(t *testing.T) {
+	q := Query{Expression: &GreaterThanExpression{Field: "Total", Value: MoneyValue{Amount: 10, Currency: "EUR"}}}
+	b, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var q2 Query
+	if err := json.Unmarshal(b, &q2); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if v, err := q2.Evaluate(&invoice{Total: MoneyValue{Amount: 11, Currency: "EUR"}}); err != nil || !v {
+		t.Errorf("round trip evaluate failed: %v %v (%s)", v, err, b)
+	}
+}
+
+func TestMoneyJSONKeepsPrecision(t *testing.T) {
+	q := Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &GreaterThanExpression{Field: "Price", Value: MoneyValue{Amount: 10.505, Currency: "EUR"}}},
+		{Expression: &InExpression{Field: "Price", Values: []interface{}{MoneyValue{Amount: 20.125, Currency: "EUR"}}}},
+		{Expression: &ComparisonExpression{LHS: Field{Name: "Price"}, RHS: Constant{Value: MoneyValue{Amount: 0.001, Currency: "EUR"}}, Operation: "gt"}},
+	}}}
+	data, err := MarshalQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Value":{"Type":"Money","Amount":"10.505","Currency":"EUR"}`) {
+		t.Errorf("money not encoded at full precision: %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	exprs := back.Expression.(*AndExpression).Expressions
+	if v := exprs[0].Expression.(*GreaterThanExpression).Value; v != (MoneyValue{Amount: 10.505, Currency: "EUR"}) {
+		t.Errorf("GT value decoded as %#v", v)
+	}
+	if v := exprs[1].Expression.(*InExpression).Values[0]; v != (MoneyValue{Amount: 20.125, Currency: "EUR"}) {
+		t.Errorf("In value decoded as %#v", v)
+	}
+	if v := exprs[2].Expression.(*ComparisonExpression).RHS.(Constant).Value; v != (MoneyValue{Amount: 0.001, Currency: "EUR"}) {
+		t.Errorf("constant decoded as %#v", v)
+	}
+	gt := Query{Expression: exprs[0].Expression}
+	if ok, err := gt.Evaluate(map[string]interface{}{"Price": "EUR 10.51"}); err != nil || !ok {
+		t.Errorf("EUR 10.51 > EUR 10.505: %v %v", ok, err)
+	}
+	if ok, err := gt.Evaluate(map[string]interface{}{"Price": "EUR 10.505"}); err != nil || ok {
+		t.Errorf("EUR 10.505 > EUR 10.505: %v %v", ok, err)
+	}
+	if _, err := gt.Evaluate(map[string]interface{}{"Price": "USD 50"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected currency mismatch after reload, got %v", err)
+	}
+
+	var m MoneyValue
+	if err := json.Unmarshal([]byte(`"EUR 10.505"`), &m); err != nil || m != (MoneyValue{Amount: 10.505, Currency: "EUR"}) {
+		t.Errorf("string form decoded as %v, %v", m, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Type":"Money","Amount":"ten","Currency":"EUR"}`), &m); err == nil {
+		t.Error("expected an error for an invalid amount")
+	}
+}
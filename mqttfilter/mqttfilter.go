@@ -0,0 +1,384 @@
+// Package mqttfilter forwards MQTT messages whose JSON payloads match a
+// query, with a query per topic filter, as used by edge gateways that relay
+// only the interesting telemetry. It speaks MQTT 3.1.1 directly, so it needs
+// no client library.
+package mqttfilter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+// Rule forwards the messages on topics matching Topic, an MQTT topic filter
+// that may use the + and # wildcards, whose payload matches Query. They are
+// published to To, in which {topic} is replaced by the topic the message
+// arrived on. A Query without an Expression forwards every message.
+type Rule struct {
+	Topic string
+	Query evaluator.Query
+	To    string
+}
+
+// ruleConfig is a rule as written in a configuration file.
+type ruleConfig struct {
+	Topic string `yaml:"topic"`
+	Expr  string `yaml:"expr"`
+	To    string `yaml:"to"`
+}
+
+// ParseRules reads rules from YAML such as
+//
+//	rules:
+//	  - topic: sensors/+/temperature
+//	    expr: celsius > 30
+//	    to: alerts/{topic}
+//
+// parsing each expr with the simple parser. With ignoreCase expressions
+// compare strings case-insensitively.
+func ParseRules(data []byte, ignoreCase bool) ([]Rule, error) {
+	var cfg struct {
+		Rules []ruleConfig `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("no rules")
+	}
+	rules := make([]Rule, len(cfg.Rules))
+	for i, rc := range cfg.Rules {
+		if err := validTopic(rc.Topic, true); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if err := validTopic(strings.ReplaceAll(rc.To, "{topic}", "t"), false); err != nil {
+			return nil, fmt.Errorf("rule %d: to: %w", i+1, err)
+		}
+		r := Rule{Topic: rc.Topic, To: rc.To}
+		if rc.Expr != "" {
+			q, err := simple.Parse(rc.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			if ignoreCase {
+				q = evaluator.FoldCase(q)
+			}
+			r.Query = q
+		}
+		rules[i] = r
+	}
+	return rules, nil
+}
+
+// validTopic reports whether t is a valid topic name or, with filter, topic
+// filter.
+func validTopic(t string, filter bool) error {
+	if t == "" {
+		return errors.New("empty topic")
+	}
+	levels := strings.Split(t, "/")
+	for i, l := range levels {
+		if !strings.ContainsAny(l, "+#") {
+			continue
+		}
+		if !filter {
+			return fmt.Errorf("topic %q: wildcards are only allowed in filters", t)
+		}
+		if l != "+" && (l != "#" || i != len(levels)-1) {
+			return fmt.Errorf("topic %q: misplaced wildcard", t)
+		}
+	}
+	return nil
+}
+
+// matchTopic reports whether topic matches filter.
+func matchTopic(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, l := range f {
+		if l == "#" {
+			return true
+		}
+		if i == len(t) || (l != "+" && l != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// Stats describes the work done by a Bridge.
+type Stats struct {
+	Received   int64 // messages read
+	Forwarded  int64 // messages published, once per matching rule
+	Skipped    int64 // messages that were not JSON or failed to evaluate
+	Reconnects int64 // times the connection was re-established
+}
+
+// Bridge subscribes to the Topic of every rule and forwards each message to
+// the rules it matches. Messages are forwarded at most once (QoS 0): a
+// gateway relaying telemetry prefers dropping a reading during an outage to
+// stalling. Lost connections are re-established with exponential backoff.
+type Bridge struct {
+	// Server is the broker address, host:port.
+	Server             string
+	ClientID           string
+	Username, Password string
+	Rules              []Rule
+	// KeepAlive is how often the connection is checked. It defaults to 60s.
+	KeepAlive time.Duration
+	// Backoff is the first reconnect delay, doubled after each failed
+	// attempt up to MaxBackoff. They default to 100ms and 30s.
+	Backoff, MaxBackoff time.Duration
+	// Logf, when set, reports skipped messages and reconnects.
+	Logf func(format string, args ...any)
+
+	stats struct {
+		received, forwarded, skipped, reconnects atomic.Int64
+	}
+}
+
+// Stats returns a snapshot of the bridge statistics. It is safe to call while
+// Run is running.
+func (b *Bridge) Stats() Stats {
+	return Stats{
+		Received:   b.stats.received.Load(),
+		Forwarded:  b.stats.forwarded.Load(),
+		Skipped:    b.stats.skipped.Load(),
+		Reconnects: b.stats.reconnects.Load(),
+	}
+}
+
+// Run forwards messages until ctx is cancelled, when it returns ctx.Err(), or
+// until the broker refuses the connection or a subscription with an Error.
+// opts are passed to each Rule's Query.Evaluate.
+func (b *Bridge) Run(ctx context.Context, opts ...any) error {
+	if len(b.Rules) == 0 {
+		return errors.New("mqttfilter: no rules")
+	}
+	delay := b.backoff()
+	for {
+		connected, err := b.session(ctx, opts)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var me Error
+		if errors.As(err, &me) {
+			return err
+		}
+		if connected {
+			delay = b.backoff()
+		}
+		b.logf("mqtt %s: %v; reconnecting in %s", b.Server, err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > b.maxBackoff() {
+			delay = b.maxBackoff()
+		}
+		b.stats.reconnects.Add(1)
+	}
+}
+
+// session runs one connection to the broker until it fails, reporting
+// whether it was established.
+func (b *Bridge) session(ctx context.Context, opts []any) (bool, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", b.Server)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { _ = c.Close() })
+	defer stop()
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	if err := b.connect(r, w); err != nil {
+		return false, err
+	}
+	if err := b.subscribe(r, w); err != nil {
+		return true, err
+	}
+	// Ping when the broker has been quiet for half the keep alive, and give
+	// up when the ping goes unanswered as long again.
+	idle := b.keepAlive() / 2
+	waiting := false
+	for {
+		_ = c.SetReadDeadline(time.Now().Add(idle))
+		if _, err := r.Peek(1); err != nil {
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() || waiting {
+				return true, err
+			}
+			if err := writePacket(w, packetPingreq, nil); err != nil {
+				return true, err
+			}
+			waiting = true
+			continue
+		}
+		waiting = false
+		_ = c.SetReadDeadline(time.Time{})
+		p, err := readPacket(r)
+		if err != nil {
+			return true, err
+		}
+		if p.kind() != packetPublish {
+			continue
+		}
+		m, err := parsePublish(p)
+		if err != nil {
+			return true, err
+		}
+		if err := b.handle(w, m, opts); err != nil {
+			return true, err
+		}
+	}
+}
+
+func (b *Bridge) connect(r *bufio.Reader, w *bufio.Writer) error {
+	flags := byte(0x02) // clean session
+	body := appendString(nil, "MQTT")
+	body = append(body, 4)
+	if b.Username != "" {
+		flags |= 0x80
+	}
+	if b.Password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(b.keepAlive()/time.Second))
+	body = appendString(body, b.ClientID)
+	if b.Username != "" {
+		body = appendString(body, b.Username)
+	}
+	if b.Password != "" {
+		body = appendString(body, b.Password)
+	}
+	if err := writePacket(w, packetConnect, body); err != nil {
+		return err
+	}
+	p, err := readPacket(r)
+	if err != nil {
+		return err
+	}
+	if p.kind() != packetConnack {
+		return fmt.Errorf("mqtt: expected connack, got packet type %d", p.kind()>>4)
+	}
+	return connackError(p.body)
+}
+
+func (b *Bridge) subscribe(r *bufio.Reader, w *bufio.Writer) error {
+	body := binary.BigEndian.AppendUint16(nil, 1)
+	seen := map[string]bool{}
+	var topics []string
+	for _, rule := range b.Rules {
+		if seen[rule.Topic] {
+			continue
+		}
+		seen[rule.Topic] = true
+		topics = append(topics, rule.Topic)
+		body = append(appendString(body, rule.Topic), 0)
+	}
+	if err := writePacket(w, packetSubscribe|0x02, body); err != nil {
+		return err
+	}
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+		// Brokers may deliver messages before the acknowledgement. They are
+		// dropped, as they would be had they arrived a moment earlier.
+		if p.kind() != packetSuback {
+			continue
+		}
+		for i, code := range p.body[min(2, len(p.body)):] {
+			if code == 0x80 && i < len(topics) {
+				return Error(fmt.Sprintf("subscription to %q refused", topics[i]))
+			}
+		}
+		return nil
+	}
+}
+
+// handle evaluates a message against every rule whose topic matches,
+// forwarding it for each match. Only failures to publish are returned.
+func (b *Bridge) handle(w *bufio.Writer, m publish, opts []any) error {
+	b.stats.received.Add(1)
+	if m.qos == 1 {
+		if err := writePacket(w, packetPuback, binary.BigEndian.AppendUint16(nil, m.id)); err != nil {
+			return err
+		}
+	}
+	var v interface{}
+	decoded := false
+	for _, rule := range b.Rules {
+		if !matchTopic(rule.Topic, m.topic) {
+			continue
+		}
+		if !decoded {
+			if err := json.Unmarshal(m.payload, &v); err != nil {
+				b.stats.skipped.Add(1)
+				b.logf("skipping message on %s: %v", m.topic, err)
+				return nil
+			}
+			decoded = true
+		}
+		if rule.Query.Expression != nil {
+			ok, err := rule.Query.Evaluate(v, opts...)
+			if err != nil {
+				b.stats.skipped.Add(1)
+				b.logf("skipping message on %s for %s: %v", m.topic, rule.Topic, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+		}
+		out := publish{topic: strings.ReplaceAll(rule.To, "{topic}", m.topic), payload: m.payload}
+		header, body := out.encode()
+		if err := writePacket(w, header, body); err != nil {
+			return fmt.Errorf("publish to %s: %w", out.topic, err)
+		}
+		b.stats.forwarded.Add(1)
+	}
+	return nil
+}
+
+func (b *Bridge) keepAlive() time.Duration {
+	if b.KeepAlive <= 0 {
+		return 60 * time.Second
+	}
+	return b.KeepAlive
+}
+
+func (b *Bridge) backoff() time.Duration {
+	if b.Backoff <= 0 {
+		return 100 * time.Millisecond
+	}
+	return b.Backoff
+}
+
+func (b *Bridge) maxBackoff() time.Duration {
+	if b.MaxBackoff <= 0 {
+		return 30 * time.Second
+	}
+	return b.MaxBackoff
+}
+
+func (b *Bridge) logf(format string, args ...any) {
+	if b.Logf != nil {
+		b.Logf(format, args...)
+	}
+}
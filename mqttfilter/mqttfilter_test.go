@@ -0,0 +1,330 @@
+package mqttfilter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker implements enough of an MQTT broker for a Bridge. Messages
+// published by clients are reported as events rather than routed.
+type fakeBroker struct {
+	ln     net.Listener
+	events chan string
+	// code is the CONNACK return code and refuse a filter whose
+	// subscription is refused.
+	code   byte
+	refuse string
+
+	mu sync.Mutex
+	// silent stops the broker answering pings.
+	silent  bool
+	clients map[*fakeClient][]string
+}
+
+type fakeClient struct {
+	c  net.Conn
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeBroker{ln: ln, events: make(chan string, 100), clients: map[*fakeClient][]string{}}
+	t.Cleanup(func() {
+		_ = ln.Close()
+		f.drop()
+	})
+	go f.serve()
+	return f
+}
+
+func (f *fakeBroker) addr() string {
+	return f.ln.Addr().String()
+}
+
+func (f *fakeBroker) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		fc := &fakeClient{c: c, w: bufio.NewWriter(c)}
+		f.mu.Lock()
+		f.clients[fc] = nil
+		f.mu.Unlock()
+		go f.handle(fc)
+	}
+}
+
+// drop closes every client connection.
+func (f *fakeBroker) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for fc := range f.clients {
+		_ = fc.c.Close()
+	}
+	f.clients = map[*fakeClient][]string{}
+}
+
+func (f *fakeBroker) notify(event string) {
+	select {
+	case f.events <- event:
+	default:
+	}
+}
+
+// await waits until the broker reports the event, such as "SUBSCRIBE a/#".
+func (f *fakeBroker) await(t *testing.T, event string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-f.events:
+			if got == event {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", event)
+		}
+	}
+}
+
+func (f *fakeBroker) handle(fc *fakeClient) {
+	r := bufio.NewReader(fc.c)
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.kind() {
+		case packetConnect:
+			fc.send(packetConnack, []byte{0, f.code})
+			f.notify("CONNECT")
+		case packetSubscribe:
+			id, b := p.body[:2], p.body[2:]
+			codes := append([]byte(nil), id...)
+			var filters []string
+			for len(b) > 2 {
+				n := int(binary.BigEndian.Uint16(b))
+				filter := string(b[2 : 2+n])
+				b = b[3+n:]
+				code := byte(0)
+				if filter == f.refuse {
+					code = 0x80
+				} else {
+					filters = append(filters, filter)
+				}
+				codes = append(codes, code)
+			}
+			f.mu.Lock()
+			f.clients[fc] = append(f.clients[fc], filters...)
+			f.mu.Unlock()
+			fc.send(packetSuback, codes)
+			f.notify("SUBSCRIBE " + strings.Join(filters, ","))
+		case packetPublish:
+			m, err := parsePublish(p)
+			if err != nil {
+				return
+			}
+			f.notify(fmt.Sprintf("PUBLISH %s %s", m.topic, m.payload))
+		case packetPuback:
+			f.notify(fmt.Sprintf("PUBACK %d", binary.BigEndian.Uint16(p.body)))
+		case packetPingreq:
+			f.mu.Lock()
+			silent := f.silent
+			f.mu.Unlock()
+			if !silent {
+				fc.send(packetPingresp, nil)
+			}
+			f.notify("PINGREQ")
+		}
+	}
+}
+
+// publish delivers a message to the clients subscribed to topic.
+func (f *fakeBroker) publish(topic string, qos byte, id uint16, payload string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	header, body := publish{topic: topic, qos: qos, id: id, payload: []byte(payload)}.encode()
+	for fc, filters := range f.clients {
+		for _, filter := range filters {
+			if matchTopic(filter, topic) {
+				fc.send(header, body)
+				break
+			}
+		}
+	}
+}
+
+func (fc *fakeClient) send(header byte, body []byte) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	_ = writePacket(fc.w, header, body)
+}
+
+func start(t *testing.T, b *Bridge) (cancel func() error) {
+	t.Helper()
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(stop)
+	return func() error {
+		stop()
+		return <-done
+	}
+}
+
+func mustRules(t *testing.T, config string) []Rule {
+	t.Helper()
+	rules, err := ParseRules([]byte(config), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestBridge(t *testing.T) {
+	f := newFakeBroker(t)
+	b := &Bridge{
+		Server:   f.addr(),
+		ClientID: "gateway",
+		Backoff:  time.Millisecond,
+		Rules: mustRules(t, `
+rules:
+  - topic: sensors/+/temperature
+    expr: celsius > 30
+    to: alerts/{topic}
+  - topic: sensors/#
+    expr: battery < 10
+    to: maintenance
+  - topic: doors/front
+    to: all/doors
+`),
+	}
+	cancel := start(t, b)
+	f.await(t, "SUBSCRIBE sensors/+/temperature,sensors/#,doors/front")
+	f.publish("sensors/a/temperature", 0, 0, `{"celsius": 20, "battery": 50}`)
+	f.publish("sensors/b/temperature", 1, 7, `{"celsius": 35, "battery": 5}`)
+	f.publish("sensors/c/temperature", 0, 0, `not json`)
+	f.publish("doors/front", 0, 0, `{"open": true}`)
+	f.await(t, "PUBACK 7")
+	f.await(t, `PUBLISH alerts/sensors/b/temperature {"celsius": 35, "battery": 5}`)
+	f.await(t, `PUBLISH maintenance {"celsius": 35, "battery": 5}`)
+	f.await(t, `PUBLISH all/doors {"open": true}`)
+
+	f.drop()
+	f.await(t, "SUBSCRIBE sensors/+/temperature,sensors/#,doors/front")
+	f.publish("sensors/d/humidity", 0, 0, `{"battery": 1}`)
+	f.await(t, `PUBLISH maintenance {"battery": 1}`)
+	if err := cancel(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+	if s := b.Stats(); s.Received != 5 || s.Forwarded != 4 || s.Skipped != 1 || s.Reconnects < 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestBridgeKeepAlive(t *testing.T) {
+	f := newFakeBroker(t)
+	b := &Bridge{
+		Server:    f.addr(),
+		KeepAlive: 50 * time.Millisecond,
+		Backoff:   time.Millisecond,
+		Rules:     []Rule{{Topic: "a", To: "b"}},
+	}
+	cancel := start(t, b)
+	f.await(t, "PINGREQ")
+	f.await(t, "PINGREQ")
+	if s := b.Stats(); s.Reconnects != 0 {
+		t.Errorf("reconnected while pings were answered: %+v", s)
+	}
+	f.mu.Lock()
+	f.silent = true
+	f.mu.Unlock()
+	f.await(t, "CONNECT")
+	if err := cancel(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+}
+
+func TestBridgeErrors(t *testing.T) {
+	f := newFakeBroker(t)
+	f.code = 5
+	b := &Bridge{Server: f.addr(), Rules: []Rule{{Topic: "a", To: "b"}}}
+	var me Error
+	if err := b.Run(context.Background()); !errors.As(err, &me) || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("expected a refused connection, got %v", err)
+	}
+	f.code = 0
+	f.refuse = "secret/#"
+	b.Rules = append(b.Rules, Rule{Topic: "secret/#", To: "b"})
+	if err := b.Run(context.Background()); !errors.As(err, &me) || !strings.Contains(err.Error(), `"secret/#"`) {
+		t.Errorf("expected a refused subscription, got %v", err)
+	}
+	if err := (&Bridge{Server: f.addr()}).Run(context.Background()); err == nil {
+		t.Error("expected an error without rules")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+rules:
+  - topic: sensors/#
+    expr: status is "ALARM"
+    to: alarms
+`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Topic != "sensors/#" || rules[0].To != "alarms" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	if ok, err := rules[0].Query.Evaluate(map[string]interface{}{"status": "alarm"}); err != nil || !ok {
+		t.Errorf("expected a case-insensitive match, got %v %v", ok, err)
+	}
+	for name, config := range map[string]string{
+		"no rules":       `rules: []`,
+		"bad yaml":       `rules: [`,
+		"bad expression": "rules:\n  - topic: a\n    expr: 'x >'\n    to: b\n",
+		"no topic":       "rules:\n  - to: b\n",
+		"bad wildcard":   "rules:\n  - topic: a/#/b\n    to: b\n",
+		"wildcard to":    "rules:\n  - topic: a\n    to: b/+\n",
+	} {
+		if _, err := ParseRules([]byte(config), false); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	for _, tc := range []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"+/+/c", "a/b/c", true},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"a/b/c", "a/b", false},
+	} {
+		if got := matchTopic(tc.filter, tc.topic); got != tc.want {
+			t.Errorf("matchTopic(%q, %q) = %v", tc.filter, tc.topic, got)
+		}
+	}
+}
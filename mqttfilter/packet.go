@@ -0,0 +1,152 @@
+package mqttfilter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types, shifted into the high nibble of the first
+// header byte.
+const (
+	packetConnect     = 1 << 4
+	packetConnack     = 2 << 4
+	packetPublish     = 3 << 4
+	packetPuback      = 4 << 4
+	packetSubscribe   = 8 << 4
+	packetSuback      = 9 << 4
+	packetPingreq     = 12 << 4
+	packetPingresp    = 13 << 4
+	maxRemainingBytes = 4
+)
+
+// Error is a refusal from the broker, such as a rejected connection or
+// subscription. Reconnecting does not help, so Bridge.Run returns it.
+type Error string
+
+func (e Error) Error() string {
+	return "mqtt: " + string(e)
+}
+
+// connackErrors describes the CONNACK return codes.
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// packet is a control packet: the first header byte and the remaining bytes.
+type packet struct {
+	header byte
+	body   []byte
+}
+
+func (p packet) kind() byte {
+	return p.header & 0xf0
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		if i == maxRemainingBytes {
+			return packet{}, errors.New("mqtt: malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		n += int(b&0x7f) * mult
+		if b&0x80 == 0 {
+			break
+		}
+		mult *= 128
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{header: h, body: body}, nil
+}
+
+func writePacket(w *bufio.Writer, header byte, body []byte) error {
+	w.WriteByte(header)
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		w.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	w.Write(body)
+	return w.Flush()
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// publish holds the fields of a PUBLISH packet.
+type publish struct {
+	topic   string
+	qos     byte
+	id      uint16
+	payload []byte
+}
+
+func parsePublish(p packet) (publish, error) {
+	m := publish{qos: p.header >> 1 & 3}
+	b := p.body
+	if len(b) < 2 {
+		return m, errors.New("mqtt: short publish")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < n {
+		return m, errors.New("mqtt: short publish topic")
+	}
+	m.topic, b = string(b[:n]), b[n:]
+	if m.qos > 0 {
+		if len(b) < 2 {
+			return m, errors.New("mqtt: short publish packet id")
+		}
+		m.id, b = binary.BigEndian.Uint16(b), b[2:]
+	}
+	m.payload = b
+	return m, nil
+}
+
+func (m publish) encode() (byte, []byte) {
+	body := appendString(nil, m.topic)
+	if m.qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, m.id)
+	}
+	return packetPublish | m.qos<<1, append(body, m.payload...)
+}
+
+// connackError returns the refusal in a CONNACK body, or nil.
+func connackError(body []byte) error {
+	if len(body) != 2 {
+		return fmt.Errorf("mqtt: malformed connack")
+	}
+	if body[1] == 0 {
+		return nil
+	}
+	if msg, ok := connackErrors[body[1]]; ok {
+		return Error("connection refused: " + msg)
+	}
+	return Error(fmt.Sprintf("connection refused: code %d", body[1]))
+}
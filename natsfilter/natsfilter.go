@@ -0,0 +1,307 @@
+// Package natsfilter filters the messages on a NATS subject or JetStream
+// consumer, republishing the matches and acknowledging JetStream messages
+// according to the result. It speaks the NATS protocol directly, so it needs
+// no client library.
+package natsfilter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Action acknowledges a JetStream message.
+type Action string
+
+const (
+	// Ack marks the message as processed.
+	Ack Action = "+ACK"
+	// Nak asks the server to redeliver the message.
+	Nak Action = "-NAK"
+	// Term stops the message from being redelivered.
+	Term Action = "+TERM"
+)
+
+// ParseAction parses ack, nak or term.
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(s) {
+	case "ack":
+		return Ack, nil
+	case "nak":
+		return Nak, nil
+	case "term":
+		return Term, nil
+	}
+	return "", fmt.Errorf("unknown action %q: want ack, nak or term", s)
+}
+
+// Stats describes the work done by a Bridge.
+type Stats struct {
+	Received   int64 // messages read
+	Matched    int64 // messages that matched, republished when To is set
+	Skipped    int64 // messages that could not be evaluated
+	Reconnects int64 // times the connection was re-established
+}
+
+// Bridge evaluates Query against each message from Subject, or from the
+// JetStream pull consumer Consumer on Stream, and republishes the matches,
+// headers included, to To.
+//
+// JetStream messages, whether fetched from a pull consumer or delivered to
+// the subject of a push consumer, are acknowledged: matches with Ack once
+// republished, other messages with NoMatch, and messages that cannot be
+// evaluated with Term. A message whose republish fails is not acknowledged,
+// so JetStream redelivers it. Plain NATS messages keep their reply subject
+// when republished, so requests can be filtered on their way to a service.
+//
+// Lost connections are re-established with exponential backoff.
+type Bridge struct {
+	// Server is host:port or a nats:// URL. User information in the URL is
+	// sent as a user and password or, without a password, as a token.
+	Server string
+	// Subject is subscribed to, in the queue group Queue when set, unless
+	// Consumer is set.
+	Subject, Queue string
+	// Stream and Consumer name a JetStream pull consumer to fetch from.
+	Stream, Consumer string
+	// To is the subject matches are republished to. When empty matches are
+	// only acknowledged.
+	To string
+	// Headers evaluates Query against the message headers instead of the
+	// JSON payload. Each header is a string field, or a list when repeated.
+	Headers bool
+	Query   evaluator.Query
+	// NoMatch acknowledges JetStream messages that do not match. It defaults
+	// to Ack.
+	NoMatch Action
+	// Batch is the number of messages requested from a pull consumer at a
+	// time. It defaults to 100.
+	Batch int
+	// Backoff is the first reconnect delay, doubled after each failed
+	// attempt up to MaxBackoff. They default to 100ms and 30s.
+	Backoff, MaxBackoff time.Duration
+	// Logf, when set, reports skipped messages and reconnects.
+	Logf func(format string, args ...any)
+
+	stats struct {
+		received, matched, skipped, reconnects atomic.Int64
+	}
+}
+
+// Stats returns a snapshot of the bridge statistics. It is safe to call while
+// Run is running.
+func (b *Bridge) Stats() Stats {
+	return Stats{
+		Received:   b.stats.received.Load(),
+		Matched:    b.stats.matched.Load(),
+		Skipped:    b.stats.skipped.Load(),
+		Reconnects: b.stats.reconnects.Load(),
+	}
+}
+
+// Run bridges messages until ctx is cancelled, when it returns ctx.Err(), or
+// until the server reports an Error. opts are passed to Query.Evaluate.
+func (b *Bridge) Run(ctx context.Context, opts ...any) error {
+	if b.Consumer == "" && b.Subject == "" {
+		return errors.New("natsfilter: Subject or Consumer required")
+	}
+	delay := b.backoff()
+	for {
+		connected, err := b.session(ctx, opts)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var ne Error
+		if errors.As(err, &ne) {
+			return err
+		}
+		if connected {
+			delay = b.backoff()
+		}
+		b.logf("nats %s: %v; reconnecting in %s", b.Server, err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > b.maxBackoff() {
+			delay = b.maxBackoff()
+		}
+		b.stats.reconnects.Add(1)
+	}
+}
+
+// session runs one connection to the server until it fails, reporting
+// whether it was established.
+func (b *Bridge) session(ctx context.Context, opts []any) (bool, error) {
+	c, err := dial(ctx, b.Server, "evaluator natsfilter")
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { _ = c.Close() })
+	defer stop()
+	if b.Consumer != "" {
+		return true, b.pull(c, opts)
+	}
+	if err := c.subscribe(b.Subject, b.Queue, 1); err != nil {
+		return true, err
+	}
+	for {
+		m, err := c.next()
+		if err != nil {
+			return true, err
+		}
+		if err := b.handle(c, m, opts); err != nil {
+			return true, err
+		}
+	}
+}
+
+// pull fetches messages from the pull consumer in batches, asking for the
+// next batch once the last is delivered or the request expires.
+func (b *Bridge) pull(c *conn, opts []any) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	inbox := "_INBOX." + hex.EncodeToString(id)
+	if err := c.subscribe(inbox, "", 1); err != nil {
+		return err
+	}
+	api := "$JS.API.CONSUMER.MSG.NEXT." + b.Stream + "." + b.Consumer
+	req := fmt.Sprintf(`{"batch":%d,"expires":%d}`, b.batch(), int64(30*time.Second))
+	for {
+		if err := c.publish(api, inbox, nil, []byte(req)); err != nil {
+			return err
+		}
+		for n := 0; n < b.batch(); {
+			m, err := c.next()
+			if err != nil {
+				return err
+			}
+			if m.status == 503 {
+				return Error(fmt.Sprintf("no responders for consumer %s on stream %s", b.Consumer, b.Stream))
+			}
+			if m.status == 100 {
+				continue
+			}
+			if m.status != 0 {
+				// 404, 408 and 409 end the request; ask again.
+				break
+			}
+			if err := b.handle(c, m, opts); err != nil {
+				return err
+			}
+			n++
+		}
+	}
+}
+
+// handle evaluates one message, republishes it when it matches and
+// acknowledges it when it came from JetStream. Only failures to publish are
+// returned.
+func (b *Bridge) handle(c *conn, m *msg, opts []any) error {
+	b.stats.received.Add(1)
+	ok := true
+	v, err := b.record(m)
+	if err == nil && b.Query.Expression != nil {
+		ok, err = b.Query.Evaluate(v, opts...)
+	}
+	if err != nil {
+		b.stats.skipped.Add(1)
+		b.logf("skipping message on %s: %v", m.subject, err)
+		return b.ack(c, m, Term)
+	}
+	if !ok {
+		return b.ack(c, m, b.noMatch())
+	}
+	if b.To != "" {
+		reply := m.reply
+		if jetStream(m) {
+			reply = ""
+		}
+		if err := c.publish(b.To, reply, m.rawHeader, m.data); err != nil {
+			return fmt.Errorf("publish to %s: %w", b.To, err)
+		}
+	}
+	b.stats.matched.Add(1)
+	return b.ack(c, m, Ack)
+}
+
+// record returns the value Query is evaluated against.
+func (b *Bridge) record(m *msg) (interface{}, error) {
+	if !b.Headers {
+		var v interface{}
+		err := json.Unmarshal(m.data, &v)
+		return v, err
+	}
+	rec := make(map[string]interface{}, len(m.header))
+	for k, vs := range m.header {
+		if len(vs) == 1 {
+			rec[k] = vs[0]
+			continue
+		}
+		list := make([]interface{}, len(vs))
+		for i, v := range vs {
+			list[i] = v
+		}
+		rec[k] = list
+	}
+	return rec, nil
+}
+
+func (b *Bridge) ack(c *conn, m *msg, a Action) error {
+	if !jetStream(m) {
+		return nil
+	}
+	return c.publish(m.reply, "", nil, []byte(a))
+}
+
+// jetStream reports whether m was delivered by a JetStream consumer and
+// expects an acknowledgement.
+func jetStream(m *msg) bool {
+	return strings.HasPrefix(m.reply, "$JS.ACK.")
+}
+
+func (b *Bridge) noMatch() Action {
+	if b.NoMatch == "" {
+		return Ack
+	}
+	return b.NoMatch
+}
+
+func (b *Bridge) batch() int {
+	if b.Batch <= 0 {
+		return 100
+	}
+	return b.Batch
+}
+
+func (b *Bridge) backoff() time.Duration {
+	if b.Backoff <= 0 {
+		return 100 * time.Millisecond
+	}
+	return b.Backoff
+}
+
+func (b *Bridge) maxBackoff() time.Duration {
+	if b.MaxBackoff <= 0 {
+		return 30 * time.Second
+	}
+	return b.MaxBackoff
+}
+
+func (b *Bridge) logf(format string, args ...any) {
+	if b.Logf != nil {
+		b.Logf(format, args...)
+	}
+}
@@ -0,0 +1,468 @@
+package natsfilter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// fakeNATS implements enough of a NATS server, including a JetStream pull
+// consumer, for a Bridge.
+type fakeNATS struct {
+	ln     net.Listener
+	token  string
+	events chan string
+
+	mu        sync.Mutex
+	clients   map[*fakeClient]bool
+	subs      map[string][]fakeSub
+	consumers map[string]*fakeConsumer
+	acks      map[int]string
+}
+
+type fakeClient struct {
+	c  net.Conn
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+type fakeSub struct {
+	client *fakeClient
+	sid    string
+	queue  string
+}
+
+type fakeConsumer struct {
+	name     string
+	seq      int
+	pending  []fakeMsg
+	inflight map[int]fakeMsg
+	waiting  []*fakePull
+}
+
+type fakeMsg struct {
+	seq          int
+	header, data string
+}
+
+type fakePull struct {
+	inbox string
+	left  int
+}
+
+func newFakeNATS(t *testing.T, token string) *fakeNATS {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeNATS{
+		ln:        ln,
+		token:     token,
+		events:    make(chan string, 100),
+		clients:   map[*fakeClient]bool{},
+		subs:      map[string][]fakeSub{},
+		consumers: map[string]*fakeConsumer{},
+		acks:      map[int]string{},
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+		f.drop()
+	})
+	go f.serve()
+	return f
+}
+
+func (f *fakeNATS) addr() string {
+	return f.ln.Addr().String()
+}
+
+func (f *fakeNATS) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		fc := &fakeClient{c: c, w: bufio.NewWriter(c)}
+		f.mu.Lock()
+		f.clients[fc] = true
+		f.mu.Unlock()
+		go f.handle(fc)
+	}
+}
+
+// drop closes every client connection. Unacknowledged JetStream messages
+// are queued for redelivery.
+func (f *fakeNATS) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for fc := range f.clients {
+		_ = fc.c.Close()
+	}
+	f.clients = map[*fakeClient]bool{}
+	f.subs = map[string][]fakeSub{}
+	for _, c := range f.consumers {
+		for _, m := range c.inflight {
+			c.pending = append([]fakeMsg{m}, c.pending...)
+		}
+		c.inflight = map[int]fakeMsg{}
+		c.waiting = nil
+	}
+}
+
+func (f *fakeNATS) notify(event string) {
+	select {
+	case f.events <- event:
+	default:
+	}
+}
+
+// await waits until the server reports the event, such as "SUB subject".
+func (f *fakeNATS) await(t *testing.T, event string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-f.events:
+			if got == event {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", event)
+		}
+	}
+}
+
+func (f *fakeNATS) handle(fc *fakeClient) {
+	r := bufio.NewReader(fc.c)
+	fc.send("INFO {\"server_id\":\"fake\",\"headers\":true}\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+		args := strings.Fields(rest)
+		switch verb {
+		case "CONNECT":
+			var opts struct {
+				Token string `json:"auth_token"`
+			}
+			_ = json.Unmarshal([]byte(rest), &opts)
+			if opts.Token != f.token {
+				fc.send("-ERR 'Authorization Violation'\r\n")
+				_ = fc.c.Close()
+				return
+			}
+		case "PING":
+			fc.send("PONG\r\n")
+		case "SUB":
+			sub := fakeSub{client: fc, sid: args[len(args)-1]}
+			if len(args) == 3 {
+				sub.queue = args[1]
+			}
+			f.mu.Lock()
+			f.subs[args[0]] = append(f.subs[args[0]], sub)
+			f.mu.Unlock()
+			f.notify("SUB " + args[0])
+		case "PUB", "HPUB":
+			hdrLen := 0
+			if verb == "HPUB" {
+				hdrLen, _ = strconv.Atoi(args[len(args)-2])
+			}
+			total, _ := strconv.Atoi(args[len(args)-1])
+			buf := make([]byte, total+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			reply := ""
+			if n := len(args) - 1; (verb == "PUB" && n == 2) || (verb == "HPUB" && n == 3) {
+				reply = args[1]
+			}
+			f.publish(args[0], reply, string(buf[:hdrLen]), string(buf[hdrLen:total]))
+		}
+	}
+}
+
+// publish routes a message published by a client or by a test.
+func (f *fakeNATS) publish(subject, reply, header, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(subject, "$JS.API.CONSUMER.MSG.NEXT."):
+		name := strings.TrimPrefix(subject, "$JS.API.CONSUMER.MSG.NEXT.")
+		c, ok := f.consumers[name]
+		if !ok {
+			f.deliverLocked(reply, "", "NATS/1.0 503\r\n\r\n", "")
+			return
+		}
+		var req struct {
+			Batch int `json:"batch"`
+		}
+		_ = json.Unmarshal([]byte(data), &req)
+		c.waiting = append(c.waiting, &fakePull{inbox: reply, left: req.Batch})
+		f.dispatchLocked(c)
+	case strings.HasPrefix(subject, "$JS.ACK."):
+		parts := strings.Split(subject, ".")
+		seq, _ := strconv.Atoi(parts[len(parts)-1])
+		f.acks[seq] = data
+		for _, c := range f.consumers {
+			if m, ok := c.inflight[seq]; ok {
+				delete(c.inflight, seq)
+				if data == string(Nak) {
+					c.pending = append(c.pending, m)
+					f.dispatchLocked(c)
+				}
+			}
+		}
+		f.notify("ACK " + strconv.Itoa(seq))
+	default:
+		f.deliverLocked(subject, reply, header, data)
+	}
+}
+
+// deliverLocked sends a message to the subscribers of subject, once per
+// queue group.
+func (f *fakeNATS) deliverLocked(subject, reply, header, data string) {
+	groups := map[string]bool{}
+	for _, s := range f.subs[subject] {
+		if s.queue != "" {
+			if groups[s.queue] {
+				continue
+			}
+			groups[s.queue] = true
+		}
+		verb := "MSG " + subject + " " + s.sid
+		if reply != "" {
+			verb += " " + reply
+		}
+		if header != "" {
+			s.client.send(fmt.Sprintf("H%s %d %d\r\n%s%s\r\n", verb, len(header), len(header)+len(data), header, data))
+		} else {
+			s.client.send(fmt.Sprintf("%s %d\r\n%s\r\n", verb, len(data), data))
+		}
+	}
+}
+
+func (f *fakeNATS) dispatchLocked(c *fakeConsumer) {
+	for len(c.waiting) > 0 && len(c.pending) > 0 {
+		p := c.waiting[0]
+		m := c.pending[0]
+		c.pending = c.pending[1:]
+		c.inflight[m.seq] = m
+		f.deliverLocked(p.inbox, fmt.Sprintf("$JS.ACK.%s.1.%d.%d.0.0.%d", c.name, m.seq, m.seq, m.seq), m.header, m.data)
+		if p.left--; p.left == 0 {
+			c.waiting = c.waiting[1:]
+		}
+	}
+}
+
+// add appends a message to a consumer, creating it if needed, and returns
+// its sequence number.
+func (f *fakeNATS) add(name, header, data string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.consumers[name]
+	if !ok {
+		c = &fakeConsumer{name: name, inflight: map[int]fakeMsg{}}
+		f.consumers[name] = c
+	}
+	c.seq++
+	c.pending = append(c.pending, fakeMsg{seq: c.seq, header: header, data: data})
+	f.dispatchLocked(c)
+	return c.seq
+}
+
+func (f *fakeNATS) ack(seq int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.acks[seq]
+}
+
+func (fc *fakeClient) send(s string) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.w.WriteString(s)
+	_ = fc.w.Flush()
+}
+
+// listen subscribes a test client to subject.
+func listen(t *testing.T, f *fakeNATS, subject string) *conn {
+	t.Helper()
+	c, err := dial(context.Background(), "nats://"+f.token+"@"+f.addr(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	if err := c.subscribe(subject, "", 9); err != nil {
+		t.Fatal(err)
+	}
+	f.await(t, "SUB "+subject)
+	return c
+}
+
+func start(t *testing.T, b *Bridge) (cancel func() error) {
+	t.Helper()
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(stop)
+	return func() error {
+		stop()
+		return <-done
+	}
+}
+
+func TestBridgeSubject(t *testing.T) {
+	f := newFakeNATS(t, "s3cret")
+	out := listen(t, f, "orders.big")
+	b := &Bridge{
+		Server:  "nats://s3cret@" + f.addr(),
+		Subject: "orders",
+		Queue:   "filters",
+		To:      "orders.big",
+		Query:   evaluator.Query{Expression: &evaluator.GreaterThanExpression{Field: "total", Value: 100}},
+	}
+	cancel := start(t, b)
+	f.await(t, "SUB orders")
+	f.publish("orders", "", "", `{"total": 5}`)
+	f.publish("orders", "", "", `not json`)
+	f.publish("orders", "_INBOX.caller", "NATS/1.0\r\nTrace: abc\r\n\r\n", `{"total": 500}`)
+
+	m, err := out.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.data) != `{"total": 500}` || m.header.Get("Trace") != "abc" || m.reply != "_INBOX.caller" {
+		t.Errorf("unexpected message %+v", m)
+	}
+	if err := cancel(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+	if s := b.Stats(); s.Received != 3 || s.Matched != 1 || s.Skipped != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestBridgeHeaders(t *testing.T) {
+	f := newFakeNATS(t, "")
+	out := listen(t, f, "out")
+	b := &Bridge{
+		Server:  f.addr(),
+		Subject: "in",
+		To:      "out",
+		Headers: true,
+		Query: evaluator.Query{Expression: &evaluator.AndExpression{Expressions: []evaluator.Query{
+			{Expression: &evaluator.IsExpression{Field: "Type", Value: "order"}},
+			{Expression: &evaluator.ContainsExpression{Field: "Tag", Value: "eu"}},
+		}}},
+	}
+	cancel := start(t, b)
+	f.await(t, "SUB in")
+	f.publish("in", "", "NATS/1.0\r\nType: order\r\nTag: us\r\n\r\n", "first")
+	f.publish("in", "", "NATS/1.0\r\nType: order\r\nTag: us\r\nTag: eu\r\n\r\n", "second")
+	m, err := out.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.data) != "second" {
+		t.Errorf("unexpected message %q", m.data)
+	}
+	if err := cancel(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+}
+
+func TestBridgePullConsumer(t *testing.T) {
+	f := newFakeNATS(t, "")
+	out := listen(t, f, "big")
+	big := f.add("ORDERS.filter", "", `{"total": 500}`)
+	small := f.add("ORDERS.filter", "", `{"total": 5}`)
+	bad := f.add("ORDERS.filter", "", `{`)
+	b := &Bridge{
+		Server:   f.addr(),
+		Stream:   "ORDERS",
+		Consumer: "filter",
+		To:       "big",
+		Query:    evaluator.Query{Expression: &evaluator.GreaterThanExpression{Field: "total", Value: 100}},
+		NoMatch:  Term,
+		Batch:    2,
+		Backoff:  time.Millisecond,
+	}
+	cancel := start(t, b)
+	f.await(t, "ACK "+strconv.Itoa(bad))
+	for seq, want := range map[int]Action{big: Ack, small: Term, bad: Term} {
+		if got := f.ack(seq); got != string(want) {
+			t.Errorf("message %d: acknowledged with %q, want %q", seq, got, want)
+		}
+	}
+
+	f.drop()
+	later := f.add("ORDERS.filter", "", `{"total": 900}`)
+	f.await(t, "ACK "+strconv.Itoa(later))
+	if got := f.ack(later); got != string(Ack) {
+		t.Errorf("message added while disconnected: acknowledged with %q", got)
+	}
+	if err := cancel(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+	if m, err := out.next(); err != nil || string(m.data) != `{"total": 500}` {
+		t.Errorf("unexpected republished message %v %v", m, err)
+	}
+	if s := b.Stats(); s.Received != 4 || s.Matched != 2 || s.Skipped != 1 || s.Reconnects < 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestBridgeErrors(t *testing.T) {
+	f := newFakeNATS(t, "s3cret")
+	b := &Bridge{Server: "nats://wrong@" + f.addr(), Subject: "in"}
+	var ne Error
+	if err := b.Run(context.Background()); !errors.As(err, &ne) || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("expected an authorization error, got %v", err)
+	}
+	b = &Bridge{Server: "nats://s3cret@" + f.addr(), Stream: "ORDERS", Consumer: "missing"}
+	if err := b.Run(context.Background()); !errors.As(err, &ne) || !strings.Contains(err.Error(), "no responders") {
+		t.Errorf("expected a no responders error, got %v", err)
+	}
+	if err := (&Bridge{Server: f.addr()}).Run(context.Background()); err == nil {
+		t.Error("expected an error without a subject")
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for in, want := range map[string]Action{"ack": Ack, "NAK": Nak, "term": Term} {
+		if got, err := ParseAction(in); err != nil || got != want {
+			t.Errorf("%s: got %q %v", in, got, err)
+		}
+	}
+	if _, err := ParseAction("drop"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestReadMsgMaxPayload(t *testing.T) {
+	c := &conn{r: bufio.NewReader(strings.NewReader("0123456789\r\n")), maxPayload: 10}
+	m, err := c.readMsg([]string{"subj", "1", "10"}, false)
+	if err != nil || string(m.data) != "0123456789" {
+		t.Fatalf("got %v, %v", m, err)
+	}
+	if _, err := c.readMsg([]string{"subj", "1", "11"}, false); err == nil {
+		t.Errorf("expected an error above max_payload")
+	}
+	c = &conn{r: bufio.NewReader(strings.NewReader(""))}
+	if _, err := c.readMsg([]string{"subj", "1", "1000000000000"}, false); err == nil || !strings.Contains(err.Error(), "max_payload") {
+		t.Errorf("expected the default max_payload to apply, got %v", err)
+	}
+}
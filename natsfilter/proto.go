@@ -0,0 +1,272 @@
+package natsfilter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Error is an -ERR sent by the NATS server, such as an authorization
+// violation, or a JetStream request nobody answered. Reconnecting does not
+// help, so Bridge.Run returns it.
+type Error string
+
+func (e Error) Error() string {
+	return "nats: " + string(e)
+}
+
+// msg is a message delivered to a subscription.
+type msg struct {
+	subject string
+	reply   string
+	// rawHeader is the header block as sent, kept so republished messages
+	// carry it unchanged.
+	rawHeader []byte
+	header    http.Header
+	status    int
+	data      []byte
+}
+
+// conn speaks the NATS client protocol over a net.Conn.
+type conn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+	// maxPayload is the largest message the server announced it accepts.
+	// Larger sizes are refused rather than allocated.
+	maxPayload int
+}
+
+// defaultMaxPayload is the max_payload of a NATS server that does not
+// announce one.
+const defaultMaxPayload = 1 << 20
+
+// dial connects to server, which is host:port or a nats:// URL whose user
+// information holds a user and password or, alone, a token.
+func dial(ctx context.Context, server, name string) (*conn, error) {
+	addr := server
+	opts := map[string]interface{}{
+		"verbose":       false,
+		"pedantic":      false,
+		"headers":       true,
+		"no_responders": true,
+		"protocol":      1,
+		"name":          name,
+		"lang":          "go",
+		"version":       "evaluator",
+	}
+	if strings.Contains(server, "://") {
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, err
+		}
+		addr = u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "4222")
+		}
+		if u.User != nil {
+			if pass, ok := u.User.Password(); ok {
+				opts["user"], opts["pass"] = u.User.Username(), pass
+			} else {
+				opts["auth_token"] = u.User.Username()
+			}
+		}
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	if err := cn.handshake(opts); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// handshake reads the server INFO, sends CONNECT and waits for the PONG
+// answering a PING, so rejected credentials are reported before dial
+// returns.
+func (c *conn) handshake(opts map[string]interface{}) error {
+	line, err := c.line()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats: expected INFO, got %q", line)
+	}
+	var info struct {
+		MaxPayload int `json:"max_payload"`
+	}
+	if err := json.Unmarshal([]byte(line[len("INFO "):]), &info); err != nil {
+		return fmt.Errorf("nats: malformed INFO: %w", err)
+	}
+	c.maxPayload = info.MaxPayload
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.w, "CONNECT %s\r\nPING\r\n", data)
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := c.line()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return serverError(line)
+		}
+	}
+}
+
+func (c *conn) Close() error {
+	return c.c.Close()
+}
+
+func (c *conn) subscribe(subject, queue string, sid int) error {
+	if queue != "" {
+		fmt.Fprintf(c.w, "SUB %s %s %d\r\n", subject, queue, sid)
+	} else {
+		fmt.Fprintf(c.w, "SUB %s %d\r\n", subject, sid)
+	}
+	return c.w.Flush()
+}
+
+// publish sends data to subject, with header as the raw header block when
+// it is not empty.
+func (c *conn) publish(subject, reply string, header, data []byte) error {
+	verb := "PUB " + subject
+	if reply != "" {
+		verb += " " + reply
+	}
+	if len(header) > 0 {
+		fmt.Fprintf(c.w, "H%s %d %d\r\n", verb, len(header), len(header)+len(data))
+		c.w.Write(header)
+	} else {
+		fmt.Fprintf(c.w, "%s %d\r\n", verb, len(data))
+	}
+	c.w.Write(data)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+// next returns the next message, answering server PINGs on the way.
+func (c *conn) next() (*msg, error) {
+	for {
+		line, err := c.line()
+		if err != nil {
+			return nil, err
+		}
+		verb, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "PING":
+			c.w.WriteString("PONG\r\n")
+			if err := c.w.Flush(); err != nil {
+				return nil, err
+			}
+		case "-ERR":
+			return nil, serverError(line)
+		case "MSG":
+			return c.readMsg(strings.Fields(args), false)
+		case "HMSG":
+			return c.readMsg(strings.Fields(args), true)
+		}
+	}
+}
+
+// readMsg reads the payload of a MSG (subject sid [reply] size) or HMSG
+// (subject sid [reply] header-size total-size).
+func (c *conn) readMsg(args []string, headers bool) (*msg, error) {
+	sizes := 1
+	if headers {
+		sizes = 2
+	}
+	if n := len(args) - sizes; n != 2 && n != 3 {
+		return nil, fmt.Errorf("nats: malformed message arguments %q", args)
+	}
+	m := &msg{subject: args[0]}
+	if len(args)-sizes == 3 {
+		m.reply = args[2]
+	}
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	hdr := 0
+	if headers {
+		if hdr, err = strconv.Atoi(args[len(args)-2]); err != nil {
+			return nil, err
+		}
+	}
+	if hdr < 0 || hdr > total {
+		return nil, fmt.Errorf("nats: malformed message sizes %q", args)
+	}
+	limit := c.maxPayload
+	if limit <= 0 {
+		limit = defaultMaxPayload
+	}
+	if total > limit {
+		return nil, fmt.Errorf("nats: message of %d bytes exceeds max_payload %d", total, limit)
+	}
+	buf := make([]byte, total+2)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return nil, err
+	}
+	m.data = buf[hdr:total]
+	if hdr > 0 {
+		m.rawHeader = buf[:hdr]
+		if m.header, m.status, err = parseHeader(m.rawHeader); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// parseHeader parses a header block such as "NATS/1.0 408 Request
+// Timeout\r\nKey: Value\r\n\r\n", returning the status code when present.
+func parseHeader(b []byte) (http.Header, int, error) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	first, err := tp.ReadLine()
+	if err != nil {
+		return nil, 0, err
+	}
+	if !strings.HasPrefix(first, "NATS/1.0") {
+		return nil, 0, fmt.Errorf("nats: malformed header %q", first)
+	}
+	status := 0
+	if f := strings.Fields(first); len(f) > 1 {
+		status, _ = strconv.Atoi(f[1])
+	}
+	h, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	return http.Header(h), status, nil
+}
+
+func (c *conn) line() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func serverError(line string) Error {
+	return Error(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+}
@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NumberFormat describes how numbers are written in text input such as CSV
+// files, so values like "1.234,56" can be coerced to numbers before they are
+// compared.
+type NumberFormat struct {
+	// Decimal separates the integer and fractional parts.
+	Decimal rune
+	// Thousands groups integer digits in threes. Zero disables grouping.
+	Thousands rune
+}
+
+var numberFormats = map[string]NumberFormat{
+	"en": {Decimal: '.', Thousands: ','},
+	"eu": {Decimal: ',', Thousands: '.'},
+	"de": {Decimal: ',', Thousands: '.'},
+	"fr": {Decimal: ',', Thousands: ' '},
+	"ch": {Decimal: '.', Thousands: '\''},
+}
+
+// LookupNumberFormat returns the NumberFormat for a locale name: "en"
+// (1,234.56), "eu" or "de" (1.234,56), "fr" (1 234,56) or "ch" (1'234.56).
+func LookupNumberFormat(locale string) (NumberFormat, error) {
+	nf, ok := numberFormats[strings.ToLower(locale)]
+	if !ok {
+		return NumberFormat{}, fmt.Errorf("unknown number locale %q", locale)
+	}
+	return nf, nil
+}
+
+// Parse converts s to a float64. It reports false when s is not a number in
+// this format, including when thousands separators are not placed between
+// groups of three digits.
+func (nf NumberFormat) Parse(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	intPart, frac := s, ""
+	if i := strings.LastIndex(s, string(nf.Decimal)); i >= 0 {
+		intPart, frac = s[:i], s[i+len(string(nf.Decimal)):]
+		if frac == "" || strings.ContainsAny(frac, "+-") {
+			return 0, false
+		}
+	}
+	sign := ""
+	if strings.HasPrefix(intPart, "-") || strings.HasPrefix(intPart, "+") {
+		sign, intPart = intPart[:1], intPart[1:]
+	}
+	if nf.Thousands != 0 && strings.ContainsRune(intPart, nf.Thousands) {
+		groups := strings.Split(intPart, string(nf.Thousands))
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return 0, false
+		}
+		for _, g := range groups[1:] {
+			if len(g) != 3 {
+				return 0, false
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+	if intPart == "" || strings.ContainsAny(intPart, "+-") {
+		return 0, false
+	}
+	norm := sign + intPart
+	if frac != "" {
+		norm += "." + frac
+	}
+	// Reject words such as "inf" or "nan" which ParseFloat would accept.
+	if strings.IndexFunc(norm, func(r rune) bool {
+		return (r < '0' || r > '9') && !strings.ContainsRune(".+-eE", r)
+	}) >= 0 {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(norm, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
@@ -0,0 +1,37 @@
+package evaluator
+
+import "testing"
+
+func TestNumberFormatParse(t *testing.T) {
+	de, _ := LookupNumberFormat("de")
+	en, _ := LookupNumberFormat("en")
+	fr, _ := LookupNumberFormat("fr")
+	cases := []struct {
+		nf   NumberFormat
+		in   string
+		want float64
+		ok   bool
+	}{
+		{de, "1.234,56", 1234.56, true},
+		{de, "-1.234.567", -1234567, true},
+		{de, "999,99", 999.99, true},
+		{de, "12", 12, true},
+		{de, "1.5", 0, false},
+		{de, "1.23,4", 0, false},
+		{de, "abc", 0, false},
+		{de, "nan", 0, false},
+		{en, "1,234.56", 1234.56, true},
+		{en, "1,234,56", 0, false},
+		{fr, "1 234,5", 1234.5, true},
+		{en, "", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := c.nf.Parse(c.in)
+		if ok != c.ok || got != c.want {
+			t.Errorf("%+v %q: expected %v %v, got %v %v", c.nf, c.in, c.want, c.ok, got, ok)
+		}
+	}
+	if _, err := LookupNumberFormat("xx"); err == nil {
+		t.Errorf("expected error for unknown locale")
+	}
+}
@@ -0,0 +1,176 @@
+package evaluator
+
+// OptimizeOption selects rewrites for Optimize beyond those it always makes.
+type OptimizeOption int
+
+const (
+	// PushNot applies De Morgan's laws to move each not down through and and
+	// or, and then replaces the negation of an ordering comparison with the
+	// opposite comparison, so not (A > 5 or B < 2) becomes A <= 5 and B >= 2.
+	// The result differs for records where a compared field is missing or
+	// cannot be compared: the original negation matches them and the
+	// opposite comparison does not. Not in front of other expressions,
+	// including is, stays, since is not compares values more strictly.
+	PushNot OptimizeOption = iota + 1
+	// MergeRanges replaces the comparisons of an and group that limit the
+	// same field with the tightest of them, as a single Between where the
+	// bounds allow, so Age > 5 and Age >= 10 and Age < 65 becomes Age >= 10
+	// and Age < 65. It also merges the In lists of an or group that test the
+	// same field. Numeric bounds are chosen by value, which assumes the field
+	// holds numbers rather than strings compared as text.
+	MergeRanges
+)
+
+// Optimize returns a query equivalent to q that is cheaper to evaluate and
+// shorter to print. It always flattens nested and and or groups as Canonical
+// does, unwraps groups of one and removes double negations; options such as
+// PushNot add rewrites.
+// Queries carrying their own OnEvalError policy are kept as they are
+// relative to their parent. q itself is not modified.
+func Optimize(q Query, opts ...any) Query {
+	var o optimizer
+	for _, opt := range opts {
+		switch opt {
+		case PushNot:
+			o.pushNot = true
+		case MergeRanges:
+			o.mergeRanges = true
+		}
+	}
+	if q.Expression == nil {
+		return q
+	}
+	out := flattenQuery(Query{Expression: o.expr(q.Expression), OnEvalError: q.OnEvalError})
+	out.Metadata = q.Metadata
+	return out
+}
+
+// optimizer holds the OptimizeOptions in effect.
+type optimizer struct {
+	pushNot     bool
+	mergeRanges bool
+}
+
+// query optimizes q, negating it when neg is set.
+func (o optimizer) query(q Query, neg bool) Query {
+	if q.Expression == nil || neg && q.OnEvalError != FailError {
+		if neg {
+			return Query{Expression: &NotExpression{Expression: o.query(q, false)}}
+		}
+		return q
+	}
+	if neg {
+		return Query{Expression: o.negate(q.Expression)}
+	}
+	return Query{Expression: o.expr(q.Expression), OnEvalError: q.OnEvalError}
+}
+
+func (o optimizer) queries(qs []Query, neg bool) []Query {
+	out := make([]Query, len(qs))
+	for i, q := range qs {
+		out[i] = o.query(q, neg)
+	}
+	return out
+}
+
+// expr optimizes the children of e.
+func (o optimizer) expr(e Expression) Expression {
+	switch ex := e.(type) {
+	case *AndExpression:
+		return o.and(o.queries(ex.Expressions, false))
+	case *OrExpression:
+		return o.or(o.queries(ex.Expressions, false))
+	case *XorExpression:
+		return &XorExpression{Expressions: o.queries(ex.Expressions, false)}
+	case *ImpliesExpression:
+		return &ImpliesExpression{If: o.query(ex.If, false), Then: o.query(ex.Then, false)}
+	case *CaseExpression:
+		out := &CaseExpression{Cases: make([]CaseBranch, len(ex.Cases)), Else: o.query(ex.Else, false)}
+		for i, c := range ex.Cases {
+			out.Cases[i] = CaseBranch{When: o.query(c.When, false), Then: o.query(c.Then, false)}
+		}
+		return out
+	case *NotExpression:
+		if ex.Expression.Expression == nil || ex.Expression.OnEvalError != FailError {
+			return &NotExpression{Expression: o.query(ex.Expression, false)}
+		}
+		return o.negate(ex.Expression.Expression)
+	case *AnyExpression:
+		return &AnyExpression{Field: ex.Field, Query: o.query(ex.Query, false)}
+	case *AllExpression:
+		return &AllExpression{Field: ex.Field, Query: o.query(ex.Query, false)}
+	case *CountExpression:
+		return &CountExpression{Field: ex.Field, Query: o.query(ex.Query, false), Op: ex.Op, Value: ex.Value}
+	case *ScoreQuery:
+		out := &ScoreQuery{Expressions: make([]WeightedExpression, len(ex.Expressions)), Threshold: ex.Threshold}
+		for i, w := range ex.Expressions {
+			out.Expressions[i] = WeightedExpression{Query: o.query(w.Query, false), Weight: w.Weight}
+		}
+		return out
+	}
+	return e
+}
+
+// and returns the and of the optimized qs.
+func (o optimizer) and(qs []Query) Expression {
+	e := &AndExpression{Expressions: qs}
+	if o.mergeRanges {
+		e = flatten(e).(*AndExpression)
+		e.Expressions = mergeRanges(e.Expressions)
+	}
+	return single(e, e.Expressions)
+}
+
+// or returns the or of the optimized qs.
+func (o optimizer) or(qs []Query) Expression {
+	e := &OrExpression{Expressions: qs}
+	if o.mergeRanges {
+		e = flatten(e).(*OrExpression)
+		e.Expressions = mergeInLists(e.Expressions)
+	}
+	return single(e, e.Expressions)
+}
+
+// single returns the expression of the only member of a group, or the group
+// e itself.
+func single(e Expression, qs []Query) Expression {
+	if len(qs) == 1 && qs[0].Expression != nil && qs[0].OnEvalError == FailError {
+		return qs[0].Expression
+	}
+	return e
+}
+
+// negate returns the optimized negation of e.
+func (o optimizer) negate(e Expression) Expression {
+	if ex, ok := e.(*NotExpression); ok && ex.Expression.Expression != nil && ex.Expression.OnEvalError == FailError {
+		return o.expr(ex.Expression.Expression)
+	}
+	if o.pushNot {
+		switch ex := e.(type) {
+		case *AndExpression:
+			return o.or(o.queries(ex.Expressions, true))
+		case *OrExpression:
+			return o.and(o.queries(ex.Expressions, true))
+		case *GreaterThanExpression:
+			return &LessThanOrEqualExpression{Field: ex.Field, Value: ex.Value, Fold: ex.Fold}
+		case *GreaterThanOrEqualExpression:
+			return &LessThanExpression{Field: ex.Field, Value: ex.Value, Fold: ex.Fold}
+		case *LessThanExpression:
+			return &GreaterThanOrEqualExpression{Field: ex.Field, Value: ex.Value, Fold: ex.Fold}
+		case *LessThanOrEqualExpression:
+			return &GreaterThanExpression{Field: ex.Field, Value: ex.Value, Fold: ex.Fold}
+		case *ComparisonExpression:
+			if op, ok := oppositeOps[ex.Operation]; ok {
+				return &ComparisonExpression{LHS: ex.LHS, RHS: ex.RHS, Operation: op}
+			}
+		}
+	}
+	return &NotExpression{Expression: Query{Expression: o.expr(e)}}
+}
+
+// oppositeOps maps each ComparisonExpression operation to its negation.
+var oppositeOps = map[string]string{
+	"eq": "neq", "neq": "eq",
+	"gt": "lte", "lte": "gt",
+	"gte": "lt", "lt": "gte",
+}
@@ -0,0 +1,127 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	a := Query{Expression: &GreaterThanExpression{Field: "A", Value: 5}}
+	b := Query{Expression: &LessThanExpression{Field: "B", Value: 2}}
+	c := Query{Expression: &IsExpression{Field: "C", Value: "x"}}
+	not := func(q Query) Query { return Query{Expression: &NotExpression{Expression: q}} }
+	and := func(qs ...Query) Query { return Query{Expression: &AndExpression{Expressions: qs}} }
+	or := func(qs ...Query) Query { return Query{Expression: &OrExpression{Expressions: qs}} }
+	tests := []struct {
+		name string
+		in   Query
+		opts []any
+		want Query
+	}{
+		{"double negation", not(not(a)), nil, a},
+		{"not kept without PushNot", not(or(a, b)), nil, not(or(a, b))},
+		{"flatten", and(a, and(b, c)), nil, and(a, b, c)},
+		{"de morgan", not(or(a, b)), []any{PushNot}, and(
+			Query{Expression: &LessThanOrEqualExpression{Field: "A", Value: 5}},
+			Query{Expression: &GreaterThanOrEqualExpression{Field: "B", Value: 2}},
+		)},
+		{"nested", and(c, not(and(a, not(b)))), []any{PushNot}, and(c, or(
+			Query{Expression: &LessThanOrEqualExpression{Field: "A", Value: 5}},
+			b,
+		))},
+		{"is stays negated", not(and(c, a)), []any{PushNot}, or(not(c),
+			Query{Expression: &LessThanOrEqualExpression{Field: "A", Value: 5}},
+		)},
+		{"comparison", not(Query{Expression: &ComparisonExpression{LHS: Field{Name: "A"}, RHS: Constant{Value: 1}, Operation: "eq"}}), []any{PushNot},
+			Query{Expression: &ComparisonExpression{LHS: Field{Name: "A"}, RHS: Constant{Value: 1}, Operation: "neq"}}},
+		{"policy kept", not(Query{Expression: a.Expression, OnEvalError: FailOpen}), []any{PushNot},
+			not(Query{Expression: a.Expression, OnEvalError: FailOpen})},
+	}
+	for _, tt := range tests {
+		got, _ := json.Marshal(Optimize(tt.in, tt.opts...))
+		want, _ := json.Marshal(tt.want)
+		if string(got) != string(want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestOptimizeEquivalent(t *testing.T) {
+	q := Query{Expression: &NotExpression{Expression: Query{Expression: &OrExpression{Expressions: []Query{
+		{Expression: &GreaterThanExpression{Field: "A", Value: 5}},
+		{Expression: &AndExpression{Expressions: []Query{
+			{Expression: &LessThanExpression{Field: "B", Value: 2}},
+			{Expression: &IsExpression{Field: "C", Value: "x"}},
+		}}},
+	}}}}}
+	opt := Optimize(q, PushNot)
+	for _, a := range []int{4, 5, 6} {
+		for _, b := range []int{1, 2, 3} {
+			for _, c := range []string{"x", "y"} {
+				rec := map[string]interface{}{"A": a, "B": b, "C": c}
+				want, _ := q.Evaluate(rec)
+				got, _ := opt.Evaluate(rec)
+				if got != want {
+					t.Errorf("%v: got %v, want %v", rec, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestOptimizeMergeRanges(t *testing.T) {
+	cmp := func(e Expression) Query { return Query{Expression: e} }
+	and := func(qs ...Query) Query { return Query{Expression: &AndExpression{Expressions: qs}} }
+	or := func(qs ...Query) Query { return Query{Expression: &OrExpression{Expressions: qs}} }
+	active := cmp(&IsExpression{Field: "Active", Value: true})
+	tests := []struct {
+		name string
+		in   Query
+		want Query
+	}{
+		{"between", and(
+			cmp(&GreaterThanOrEqualExpression{Field: "Age", Value: 5}),
+			active,
+			cmp(&GreaterThanOrEqualExpression{Field: "Age", Value: 18}),
+			and(cmp(&LessThanOrEqualExpression{Field: "Age", Value: 65}), cmp(&LessThanOrEqualExpression{Field: "Age", Value: 99.5})),
+		), and(
+			cmp(&BetweenExpression{Field: "Age", Low: 18, High: 65, Inclusive: true}),
+			active,
+		)},
+		{"mixed bounds", and(
+			cmp(&GreaterThanExpression{Field: "Age", Value: 18}),
+			cmp(&GreaterThanOrEqualExpression{Field: "Age", Value: 18}),
+			cmp(&BetweenExpression{Field: "Age", Low: 0, High: 30, Inclusive: true}),
+		), and(
+			cmp(&GreaterThanExpression{Field: "Age", Value: 18}),
+			cmp(&LessThanOrEqualExpression{Field: "Age", Value: 30}),
+		)},
+		{"single kept", and(cmp(&GreaterThanExpression{Field: "Age", Value: 18}), active), and(cmp(&GreaterThanExpression{Field: "Age", Value: 18}), active)},
+		{"kinds apart", and(
+			cmp(&GreaterThanExpression{Field: "Name", Value: "a"}),
+			cmp(&GreaterThanExpression{Field: "Name", Value: 3}),
+		), and(
+			cmp(&GreaterThanExpression{Field: "Name", Value: "a"}),
+			cmp(&GreaterThanExpression{Field: "Name", Value: 3}),
+		)},
+		{"in lists", or(
+			cmp(&InExpression{Field: "Country", Values: []interface{}{"NZ", "AU"}}),
+			active,
+			cmp(&InExpression{Field: "Country", Values: []interface{}{"AU", "GB"}}),
+		), or(
+			cmp(&InExpression{Field: "Country", Values: []interface{}{"NZ", "AU", "GB"}}),
+			active,
+		)},
+		{"after push not", Query{Expression: &NotExpression{Expression: or(
+			cmp(&LessThanExpression{Field: "Age", Value: 18}),
+			cmp(&GreaterThanExpression{Field: "Age", Value: 65}),
+		)}}, cmp(&BetweenExpression{Field: "Age", Low: 18, High: 65, Inclusive: true})},
+	}
+	for _, tt := range tests {
+		got, _ := json.Marshal(Optimize(tt.in, MergeRanges, PushNot))
+		want, _ := json.Marshal(tt.want)
+		if string(got) != string(want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, want)
+		}
+	}
+}
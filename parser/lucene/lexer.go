@@ -0,0 +1,172 @@
+package lucene
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenTerm
+	tokenString
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenTo
+	tokenPlus
+	tokenMinus
+	tokenColon
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenLBrace
+	tokenRBrace
+	tokenGT
+	tokenGTE
+	tokenLT
+	tokenLTE
+)
+
+type token struct {
+	typ tokenType
+	val string
+	// wild is set on a term with an unescaped * or ? wildcard.
+	wild bool
+	// pos is the byte offset of the token in the input.
+	pos int
+}
+
+// keywords are the reserved upper case words of the query syntax. As in
+// Lucene, lower case and, or, not and to are ordinary terms.
+var keywords = map[string]tokenType{
+	"AND": tokenAnd,
+	"OR":  tokenOr,
+	"NOT": tokenNot,
+	"TO":  tokenTo,
+}
+
+// punctuation maps operator spellings to their tokens.
+var punctuation = map[string]tokenType{
+	"&&": tokenAnd,
+	"||": tokenOr,
+	">=": tokenGTE,
+	"<=": tokenLTE,
+	"(":  tokenLParen,
+	")":  tokenRParen,
+	"[":  tokenLBracket,
+	"]":  tokenRBracket,
+	"{":  tokenLBrace,
+	"}":  tokenRBrace,
+	":":  tokenColon,
+	">":  tokenGT,
+	"<":  tokenLT,
+	"!":  tokenNot,
+}
+
+// punctuationLen returns the length of the operator at the start of s, or 0.
+func punctuationLen(s string) int {
+	if len(s) >= 2 {
+		if _, ok := punctuation[s[:2]]; ok {
+			return 2
+		}
+	}
+	if _, ok := punctuation[s[:1]]; ok {
+		return 1
+	}
+	return 0
+}
+
+// isSpecial reports whether r ends a bare term.
+func isSpecial(r byte) bool {
+	return strings.IndexByte(`():[]{}"`, r) >= 0
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		if unicode.IsSpace(rune(c)) {
+			i++
+			continue
+		}
+		if n := punctuationLen(input[i:]); n > 0 {
+			text := input[i : i+n]
+			tokens = append(tokens, token{typ: punctuation[text], val: text, pos: i})
+			i += n
+			continue
+		}
+		switch c {
+		case '+', '-':
+			// A leading + or - marks a clause as required or prohibited,
+			// except where a value is expected, as in age:-5.
+			if !expectsValue(tokens) && i+1 < len(input) && !unicode.IsSpace(rune(input[i+1])) {
+				typ := tokenPlus
+				if c == '-' {
+					typ = tokenMinus
+				}
+				tokens = append(tokens, token{typ: typ, val: string(c), pos: i})
+				i++
+				continue
+			}
+		case '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(input) && input[j] != '"'; j++ {
+				if input[j] == '\\' && j+1 < len(input) {
+					j++
+				}
+				sb.WriteByte(input[j])
+			}
+			if j >= len(input) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{typ: tokenString, val: sb.String(), pos: i})
+			i = j + 1
+			continue
+		}
+		t := token{typ: tokenTerm, pos: i}
+		var sb strings.Builder
+		j := i
+		for ; j < len(input) && !unicode.IsSpace(rune(input[j])) && !isSpecial(input[j]); j++ {
+			switch input[j] {
+			case '\\':
+				if j+1 < len(input) {
+					j++
+				}
+			case '*', '?':
+				t.wild = true
+			}
+			sb.WriteByte(input[j])
+		}
+		t.val = sb.String()
+		if t.wild {
+			// Keep the escapes so the value can be used as a glob pattern.
+			t.val = input[i:j]
+		}
+		if typ, ok := keywords[input[i:j]]; ok {
+			t.typ = typ
+		}
+		tokens = append(tokens, t)
+		i = j
+	}
+	tokens = append(tokens, token{typ: tokenEOF, pos: len(input)})
+	return tokens, nil
+}
+
+// expectsValue reports whether the next token is a field value or range
+// bound rather than the start of a clause.
+func expectsValue(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[len(tokens)-1].typ {
+	case tokenColon, tokenGT, tokenGTE, tokenLT, tokenLTE, tokenLBracket, tokenLBrace, tokenTo:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,359 @@
+// Package lucene parses Lucene and Kibana style query strings, such as
+//
+//	status:active AND age:[30 TO *] AND tags:(go OR rust)
+//
+// into evaluator queries.
+//
+// Supported syntax:
+//
+//   - field:value, field:"a phrase" and field:(a OR b) for equality. Against
+//     a list field the value must be one of its elements.
+//   - field:val* and field:v?l for wildcards, and field:* for presence.
+//   - field:[low TO high] and field:{low TO high} for inclusive and exclusive
+//     ranges, mixed as in [low TO high}, with * for an open end.
+//   - field:>n, field:>=n, field:<n and field:<=n.
+//   - AND, OR, NOT, &&, || and ! with the usual precedence, and ( ) grouping.
+//   - +clause and -clause for required and prohibited clauses.
+//
+// As in Lucene, clauses with no operator between them are optional: the
+// group matches when every required clause does, no prohibited clause does
+// and, when there are no required clauses, at least one optional clause
+// does. So a b means a OR b, and +a b means just a.
+//
+// Terms with no field are rejected unless a DefaultField option is given.
+// Fuzzy (~), boost (^) and regular expression (/.../) syntax is not
+// supported.
+package lucene
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// DefaultField is a Parse option naming the field that terms without a
+// field: prefix are matched against.
+type DefaultField string
+
+// Parse converts a Lucene style query string into a Query.
+func Parse(input string, opts ...any) (evaluator.Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	p := &parser{ts: tokens}
+	for _, o := range opts {
+		if f, ok := o.(DefaultField); ok {
+			p.defaultField = string(f)
+		}
+	}
+	q, err := p.parseOr(p.defaultField)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return evaluator.Query{}, fmt.Errorf("unexpected %q at %d", t.val, t.pos)
+	}
+	return q, nil
+}
+
+type parser struct {
+	ts           []token
+	pos          int
+	defaultField string
+}
+
+func (p *parser) peek() token {
+	return p.ts[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.ts[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(typ tokenType, what string) error {
+	if t := p.next(); t.typ != typ {
+		return fmt.Errorf("expected %s at %d, got %q", what, t.pos, t.val)
+	}
+	return nil
+}
+
+// parseOr parses clause groups joined by OR. field is the field bare terms
+// apply to, which is set inside field:( ... ).
+func (p *parser) parseOr(field string) (evaluator.Query, error) {
+	first, err := p.parseGroup(field)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	qs := []evaluator.Query{first}
+	for p.peek().typ == tokenOr {
+		p.next()
+		q, err := p.parseGroup(field)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		qs = append(qs, q)
+	}
+	if len(qs) == 1 {
+		return first, nil
+	}
+	return evaluator.Query{Expression: &evaluator.OrExpression{Expressions: qs}}, nil
+}
+
+// parseGroup parses a run of clauses with no operator between them and
+// combines them by their + and - markers.
+func (p *parser) parseGroup(field string) (evaluator.Query, error) {
+	var must, mustNot, should []evaluator.Query
+	for {
+		switch p.peek().typ {
+		case tokenEOF, tokenOr, tokenRParen:
+			if len(must)+len(mustNot)+len(should) == 0 {
+				t := p.peek()
+				return evaluator.Query{}, fmt.Errorf("expected clause at %d", t.pos)
+			}
+			return combine(must, mustNot, should), nil
+		}
+		occur := p.peek().typ
+		if occur == tokenPlus || occur == tokenMinus {
+			p.next()
+		}
+		q, err := p.parseAnd(field)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		switch occur {
+		case tokenPlus:
+			must = append(must, q)
+		case tokenMinus:
+			mustNot = append(mustNot, q)
+		default:
+			should = append(should, q)
+		}
+	}
+}
+
+func combine(must, mustNot, should []evaluator.Query) evaluator.Query {
+	if len(must) == 0 && len(mustNot) == 0 && len(should) == 1 {
+		return should[0]
+	}
+	qs := must
+	for _, q := range mustNot {
+		qs = append(qs, not(q))
+	}
+	if len(must) == 0 && len(should) > 0 {
+		if len(should) == 1 {
+			qs = append(qs, should[0])
+		} else {
+			qs = append(qs, evaluator.Query{Expression: &evaluator.OrExpression{Expressions: should}})
+		}
+	}
+	if len(qs) == 1 {
+		return qs[0]
+	}
+	return evaluator.Query{Expression: &evaluator.AndExpression{Expressions: qs}}
+}
+
+func not(q evaluator.Query) evaluator.Query {
+	return evaluator.Query{Expression: &evaluator.NotExpression{Expression: q}}
+}
+
+// parseAnd parses clauses joined by AND.
+func (p *parser) parseAnd(field string) (evaluator.Query, error) {
+	first, err := p.parseNot(field)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	qs := []evaluator.Query{first}
+	for p.peek().typ == tokenAnd {
+		p.next()
+		q, err := p.parseNot(field)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		qs = append(qs, q)
+	}
+	if len(qs) == 1 {
+		return first, nil
+	}
+	return evaluator.Query{Expression: &evaluator.AndExpression{Expressions: qs}}, nil
+}
+
+func (p *parser) parseNot(field string) (evaluator.Query, error) {
+	if p.peek().typ == tokenNot {
+		p.next()
+		q, err := p.parseNot(field)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		return not(q), nil
+	}
+	return p.parseClause(field)
+}
+
+// parseClause parses a parenthesised query, a field:value clause or a bare
+// term matched against field.
+func (p *parser) parseClause(field string) (evaluator.Query, error) {
+	t := p.peek()
+	if t.typ == tokenLParen {
+		p.next()
+		q, err := p.parseOr(field)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		return q, p.expect(tokenRParen, ")")
+	}
+	if t.typ == tokenTerm && !t.wild && p.ts[p.pos+1].typ == tokenColon {
+		p.pos += 2
+		return p.parseValue(t.val)
+	}
+	if field == "" {
+		return evaluator.Query{}, fmt.Errorf("term %q at %d has no field", t.val, t.pos)
+	}
+	return p.parseValue(field)
+}
+
+// parseValue parses what follows field: in a clause.
+func (p *parser) parseValue(field string) (evaluator.Query, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenLParen:
+		q, err := p.parseOr(field)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		return q, p.expect(tokenRParen, ")")
+	case tokenLBracket, tokenLBrace:
+		return p.parseRange(field, t.typ == tokenLBracket)
+	case tokenGT, tokenGTE, tokenLT, tokenLTE:
+		v := p.next()
+		if v.typ != tokenTerm && v.typ != tokenString {
+			return evaluator.Query{}, fmt.Errorf("expected value after %s at %d", t.val, v.pos)
+		}
+		return evaluator.Query{Expression: compare(t.typ, field, literal(v))}, nil
+	case tokenString:
+		return match(field, t.val), nil
+	case tokenTerm:
+		if t.val == "*" {
+			return evaluator.Query{Expression: &evaluator.IsNotEmptyExpression{Field: field}}, nil
+		}
+		if t.wild {
+			return evaluator.Query{Expression: &evaluator.GlobExpression{Field: field, Pattern: t.val}}, nil
+		}
+		return match(field, literal(t)), nil
+	}
+	return evaluator.Query{}, fmt.Errorf("expected value for %s at %d, got %q", field, t.pos, t.val)
+}
+
+// parseRange parses the rest of [low TO high] after the opening bracket.
+func (p *parser) parseRange(field string, lowInclusive bool) (evaluator.Query, error) {
+	low, err := p.parseBound()
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if err := p.expect(tokenTo, "TO"); err != nil {
+		return evaluator.Query{}, err
+	}
+	high, err := p.parseBound()
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	var highInclusive bool
+	switch t := p.next(); t.typ {
+	case tokenRBracket:
+		highInclusive = true
+	case tokenRBrace:
+	default:
+		return evaluator.Query{}, fmt.Errorf("expected ] or } at %d, got %q", t.pos, t.val)
+	}
+	if low != nil && high != nil && lowInclusive == highInclusive {
+		return evaluator.Query{Expression: &evaluator.BetweenExpression{Field: field, Low: low, High: high, Inclusive: lowInclusive}}, nil
+	}
+	var qs []evaluator.Query
+	if low != nil {
+		op := tokenGT
+		if lowInclusive {
+			op = tokenGTE
+		}
+		qs = append(qs, evaluator.Query{Expression: compare(op, field, low)})
+	}
+	if high != nil {
+		op := tokenLT
+		if highInclusive {
+			op = tokenLTE
+		}
+		qs = append(qs, evaluator.Query{Expression: compare(op, field, high)})
+	}
+	switch len(qs) {
+	case 0:
+		return evaluator.Query{Expression: &evaluator.IsNotEmptyExpression{Field: field}}, nil
+	case 1:
+		return qs[0], nil
+	}
+	return evaluator.Query{Expression: &evaluator.AndExpression{Expressions: qs}}, nil
+}
+
+// parseBound returns a range bound, or nil for the open bound *.
+func (p *parser) parseBound() (interface{}, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenString:
+		return t.val, nil
+	case tokenTerm:
+		if t.val == "*" {
+			return nil, nil
+		}
+		return literal(t), nil
+	}
+	return nil, fmt.Errorf("expected range bound at %d, got %q", t.pos, t.val)
+}
+
+// literal converts an unquoted term to a number or bool where it looks like
+// one.
+func literal(t token) interface{} {
+	if t.typ != tokenTerm {
+		return t.val
+	}
+	switch t.val {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseInt(t.val, 10, 64); err == nil {
+		return int(n)
+	}
+	if f, err := strconv.ParseFloat(t.val, 64); err == nil {
+		return f
+	}
+	return t.val
+}
+
+func compare(op tokenType, field string, v interface{}) evaluator.Expression {
+	switch op {
+	case tokenGT:
+		return &evaluator.GreaterThanExpression{Field: field, Value: v}
+	case tokenGTE:
+		return &evaluator.GreaterThanOrEqualExpression{Field: field, Value: v}
+	case tokenLT:
+		return &evaluator.LessThanExpression{Field: field, Value: v}
+	default:
+		return &evaluator.LessThanOrEqualExpression{Field: field, Value: v}
+	}
+}
+
+// match returns a query for field:v. Lucene fields may hold several values,
+// so besides equality it matches a list field with v as an element.
+func match(field string, v interface{}) evaluator.Query {
+	return evaluator.Query{Expression: &evaluator.OrExpression{Expressions: []evaluator.Query{
+		{Expression: &evaluator.IsExpression{Field: field, Value: v}},
+		{Expression: &evaluator.AndExpression{Expressions: []evaluator.Query{
+			{Expression: &evaluator.TypeOfExpression{Field: field, Kind: "slice"}},
+			{Expression: &evaluator.ContainsExpression{Field: field, Value: v}},
+		}}},
+	}}}
+}
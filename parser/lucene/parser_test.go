@@ -0,0 +1,99 @@
+package lucene
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+func TestParse(t *testing.T) {
+	recs := []map[string]interface{}{
+		{"status": "active", "age": 35, "tags": []interface{}{"go", "sql"}, "name": "Alice Smith"},
+		{"status": "active", "age": 25, "tags": []interface{}{"rust"}, "name": "Bob Jones"},
+		{"status": "inactive", "age": 40, "tags": []interface{}{"rust"}, "name": "Carol Smith"},
+		{"status": "active", "age": 50, "name": "Dan"},
+	}
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{`status:active AND age:[30 TO *] AND tags:(go OR rust)`, []bool{true, false, false, false}},
+		{`status:active`, []bool{true, true, false, true}},
+		{`status:"inactive"`, []bool{false, false, true, false}},
+		{`tags:rust`, []bool{false, true, true, false}},
+		{`age:[25 TO 40]`, []bool{true, true, true, false}},
+		{`age:{25 TO 40}`, []bool{true, false, false, false}},
+		{`age:[25 TO 40}`, []bool{true, true, false, false}},
+		{`age:{* TO 35]`, []bool{true, true, false, false}},
+		{`age:>=40`, []bool{false, false, true, true}},
+		{`age:<30 OR age:>45`, []bool{false, true, false, true}},
+		{`name:*Smith`, []bool{true, false, true, false}},
+		{`name:B?b*`, []bool{false, true, false, false}},
+		{`tags:*`, []bool{true, true, true, false}},
+		{`NOT status:active`, []bool{false, false, true, false}},
+		{`!status:active || age:25`, []bool{false, true, true, false}},
+		{`status:active -tags:rust`, []bool{true, false, false, true}},
+		{`+status:active tags:go`, []bool{true, true, false, true}},
+		{`tags:go tags:sql`, []bool{true, false, false, false}},
+		{`tags:go name:Dan`, []bool{true, false, false, true}},
+		{`(status:inactive OR age:50) AND NOT tags:rust`, []bool{false, false, false, true}},
+		{`status:(active AND NOT inactive) && age:25`, []bool{false, true, false, false}},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		for i, rec := range recs {
+			got, err := q.Evaluate(rec)
+			if err != nil {
+				t.Errorf("%s: record %d: %v", tt.query, i, err)
+			}
+			if got != tt.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v (%s)", tt.query, i, got, tt.want[i], simple.Stringify(q))
+			}
+		}
+	}
+}
+
+func TestParseDefaultField(t *testing.T) {
+	if _, err := Parse(`active`); err == nil {
+		t.Errorf("expected error for a term without a field")
+	}
+	q, err := Parse(`active OR pending`, DefaultField("status"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"status": "pending"}); !ok {
+		t.Errorf("expected default field match")
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	q, err := Parse(`status:active AND age:[30 TO 40]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(q); err != nil {
+		t.Errorf("marshal: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		``,
+		`status:`,
+		`status:"open`,
+		`age:[1 2]`,
+		`age:[1 TO 2`,
+		`(status:a`,
+		`status:a)`,
+		`status:a AND`,
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
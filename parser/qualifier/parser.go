@@ -0,0 +1,264 @@
+// Package qualifier parses GitHub style search strings, such as
+//
+//	is:open label:bug author:alice created:>2024-01-01
+//
+// into evaluator queries. A Table maps each qualifier onto a record field.
+//
+// Every term must match. A term is one of:
+//
+//   - name:value, matching when the field equals value, or holds it as an
+//     element when the qualifier is a List.
+//   - name:a,b, matching either value.
+//   - name:>v, name:>=v, name:<v and name:<=v comparisons.
+//   - name:low..high inclusive ranges, with * for an open end.
+//   - a bare word or "quoted phrase", matched by the "" table entry.
+//
+// A leading - negates a term, as in -label:wontfix. Values containing spaces
+// can be quoted, as in label:"good first issue".
+package qualifier
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Qualifier maps a search qualifier onto a record field.
+type Qualifier struct {
+	// Field is the record field searched.
+	Field string
+	// Value, when set, is compared with Field in place of the typed value.
+	// It lets a fixed flag such as is:open be listed under "is:open".
+	Value interface{}
+	// List marks fields holding several values, such as labels.
+	List bool
+}
+
+// Table maps qualifier names to the fields they search. An entry for
+// name:value, such as "is:open", takes precedence over one for name. The
+// entry for "" handles bare words, which match its Field case-insensitively
+// as a substring.
+type Table map[string]Qualifier
+
+// term is one whitespace separated part of the input.
+type term struct {
+	neg  bool
+	name string
+	// values holds the comma separated values after name:, or the bare
+	// word when there is no name.
+	values []value
+	pos    int
+}
+
+type value struct {
+	text   string
+	quoted bool
+}
+
+// Parse converts a qualifier search string into a Query using table.
+func Parse(input string, table Table) (evaluator.Query, error) {
+	terms, err := split(input)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if len(terms) == 0 {
+		return evaluator.Query{}, fmt.Errorf("empty search")
+	}
+	qs := make([]evaluator.Query, 0, len(terms))
+	for _, t := range terms {
+		q, err := table.lower(t)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		if t.neg {
+			q = evaluator.Query{Expression: &evaluator.NotExpression{Expression: q}}
+		}
+		qs = append(qs, q)
+	}
+	if len(qs) == 1 {
+		return qs[0], nil
+	}
+	return evaluator.Query{Expression: &evaluator.AndExpression{Expressions: qs}}, nil
+}
+
+// split breaks input into terms at whitespace outside quotes.
+func split(input string) ([]term, error) {
+	var terms []term
+	i := 0
+	for i < len(input) {
+		if unicode.IsSpace(rune(input[i])) {
+			i++
+			continue
+		}
+		t := term{pos: i}
+		if input[i] == '-' && i+1 < len(input) && !unicode.IsSpace(rune(input[i+1])) {
+			t.neg = true
+			i++
+		}
+		var v value
+		var sb strings.Builder
+		named := false
+		for i < len(input) && !unicode.IsSpace(rune(input[i])) {
+			switch c := input[i]; {
+			case c == '"':
+				end := strings.IndexByte(input[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quote at %d", i)
+				}
+				sb.WriteString(input[i+1 : i+1+end])
+				v.quoted = true
+				i += end + 2
+			case c == ':' && !named && !v.quoted:
+				t.name = sb.String()
+				sb.Reset()
+				named = true
+				i++
+			case c == ',' && named:
+				v.text = sb.String()
+				t.values = append(t.values, v)
+				v = value{}
+				sb.Reset()
+				i++
+			default:
+				sb.WriteByte(c)
+				i++
+			}
+		}
+		v.text = sb.String()
+		t.values = append(t.values, v)
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+func (tb Table) lower(t term) (evaluator.Query, error) {
+	if t.name == "" {
+		q, ok := tb[""]
+		if !ok {
+			return evaluator.Query{}, fmt.Errorf("%q at %d is not a qualifier", t.values[0].text, t.pos)
+		}
+		return query(&evaluator.ContainsExpression{Field: q.Field, Value: t.values[0].text, Fold: true}), nil
+	}
+	if _, ok := tb[t.name]; !ok && !tb.hasFlags(t.name) {
+		return evaluator.Query{}, fmt.Errorf("unknown qualifier %q at %d, expected one of %s", t.name, t.pos, tb.names())
+	}
+	qs := make([]evaluator.Query, len(t.values))
+	for i, v := range t.values {
+		q, err := tb.lowerValue(t.name, v)
+		if err != nil {
+			return evaluator.Query{}, fmt.Errorf("%w at %d", err, t.pos)
+		}
+		qs[i] = q
+	}
+	if len(qs) == 1 {
+		return qs[0], nil
+	}
+	return query(&evaluator.OrExpression{Expressions: qs}), nil
+}
+
+// lowerValue returns the query for a single value of qualifier name.
+func (tb Table) lowerValue(name string, v value) (evaluator.Query, error) {
+	if q, ok := tb[name+":"+v.text]; ok {
+		if q.Value != nil {
+			return match(q, q.Value), nil
+		}
+		return match(q, v.text), nil
+	}
+	q, ok := tb[name]
+	if !ok || q.Value != nil {
+		return evaluator.Query{}, fmt.Errorf("unknown value %q for %s", v.text, name)
+	}
+	if v.text == "" {
+		return evaluator.Query{}, fmt.Errorf("missing value for %s", name)
+	}
+	if v.quoted {
+		return match(q, v.text), nil
+	}
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if rest, ok := strings.CutPrefix(v.text, op); ok {
+			return query(compare(op, q.Field, literal(rest))), nil
+		}
+	}
+	if low, high, ok := strings.Cut(v.text, ".."); ok {
+		return between(q.Field, low, high), nil
+	}
+	return match(q, v.text), nil
+}
+
+// hasFlags reports whether the table has name:value entries for name.
+func (tb Table) hasFlags(name string) bool {
+	for k := range tb {
+		if n, _, ok := strings.Cut(k, ":"); ok && n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// names lists the qualifiers in the table for error messages.
+func (tb Table) names() string {
+	seen := map[string]struct{}{}
+	var names []string
+	for k := range tb {
+		name, _, _ := strings.Cut(k, ":")
+		if _, ok := seen[name]; ok || name == "" {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func query(e evaluator.Expression) evaluator.Query {
+	return evaluator.Query{Expression: e}
+}
+
+func match(q Qualifier, v interface{}) evaluator.Query {
+	if q.List {
+		return query(&evaluator.ContainsExpression{Field: q.Field, Value: v})
+	}
+	return query(&evaluator.IsExpression{Field: q.Field, Value: v})
+}
+
+func between(field, low, high string) evaluator.Query {
+	switch {
+	case low == "*" && high == "*":
+		return query(&evaluator.IsNotEmptyExpression{Field: field})
+	case low == "*":
+		return query(compare("<=", field, literal(high)))
+	case high == "*":
+		return query(compare(">=", field, literal(low)))
+	}
+	return query(&evaluator.BetweenExpression{Field: field, Low: literal(low), High: literal(high), Inclusive: true})
+}
+
+func compare(op, field string, v interface{}) evaluator.Expression {
+	switch op {
+	case ">":
+		return &evaluator.GreaterThanExpression{Field: field, Value: v}
+	case ">=":
+		return &evaluator.GreaterThanOrEqualExpression{Field: field, Value: v}
+	case "<":
+		return &evaluator.LessThanExpression{Field: field, Value: v}
+	default:
+		return &evaluator.LessThanOrEqualExpression{Field: field, Value: v}
+	}
+}
+
+// literal converts a comparison operand to a number where it looks like
+// one. Anything else, such as a date, compares as a string.
+func literal(s string) interface{} {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return int(n)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
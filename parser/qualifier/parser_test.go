@@ -0,0 +1,83 @@
+package qualifier
+
+import (
+	"testing"
+
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+var issues = Table{
+	"is:open":   {Field: "State", Value: "open"},
+	"is:closed": {Field: "State", Value: "closed"},
+	"label":     {Field: "Labels", List: true},
+	"author":    {Field: "Author"},
+	"created":   {Field: "Created"},
+	"comments":  {Field: "Comments"},
+	"":          {Field: "Title"},
+}
+
+type issue struct {
+	State    string
+	Labels   []string
+	Author   string
+	Created  string
+	Comments int
+	Title    string
+}
+
+func TestParse(t *testing.T) {
+	recs := []*issue{
+		{State: "open", Labels: []string{"bug"}, Author: "alice", Created: "2024-03-01T10:00:00Z", Comments: 12, Title: "Crash on start"},
+		{State: "open", Labels: []string{"bug", "ui"}, Author: "bob", Created: "2023-12-01T10:00:00Z", Comments: 1, Title: "Button misaligned"},
+		{State: "closed", Labels: []string{"good first issue"}, Author: "alice", Created: "2024-02-01T10:00:00Z", Comments: 3, Title: "Typo in docs"},
+	}
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{`is:open label:bug author:alice created:>2024-01-01`, []bool{true, false, false}},
+		{`is:closed`, []bool{false, false, true}},
+		{`label:ui,"good first issue"`, []bool{false, true, true}},
+		{`label:"good first issue"`, []bool{false, false, true}},
+		{`-label:bug`, []bool{false, false, true}},
+		{`comments:>=3`, []bool{true, false, true}},
+		{`comments:2..12`, []bool{true, false, true}},
+		{`comments:*..2`, []bool{false, true, false}},
+		{`created:2024-01-01..2024-02-15`, []bool{false, false, true}},
+		{`crash`, []bool{true, false, false}},
+		{`is:open "button"`, []bool{false, true, false}},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query, issues)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		for i, rec := range recs {
+			got, err := q.Evaluate(rec)
+			if err != nil {
+				t.Errorf("%s: record %d: %v", tt.query, i, err)
+			}
+			if got != tt.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v (%s)", tt.query, i, got, tt.want[i], simple.Stringify(q))
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		``,
+		`milestone:v1`,
+		`is:draft`,
+		`author:`,
+		`label:"bug`,
+	} {
+		if _, err := Parse(in, issues); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+	if _, err := Parse(`crash`, Table{"author": {Field: "Author"}}); err == nil {
+		t.Errorf("expected error for a bare word without a \"\" entry")
+	}
+}
@@ -15,27 +15,138 @@ const (
 	tokenNumber
 	tokenAnd
 	tokenOr
+	tokenXor
+	tokenIf
+	tokenThen
 	tokenNot
 	tokenIs
 	tokenIsNot
 	tokenContains
+	tokenStartsWith
+	tokenEndsWith
+	tokenGlob
+	tokenSoundsLike
+	tokenBetween
+	tokenAny
+	tokenAll
+	tokenHasKey
+	tokenBefore
+	tokenAfter
+	tokenWithin
+	tokenIn
+	tokenContainsAll
+	tokenContainsAny
+	tokenLBracket
+	tokenRBracket
 	tokenGT
 	tokenGTE
 	tokenLT
 	tokenLTE
 	tokenLParen
 	tokenRParen
+	tokenComma
+	tokenMod
+	tokenCoalesce
+	tokenColon
+	tokenMoney
+	tokenAt
 )
 
+// currencySymbols may prefix a number to form a money literal such as €10.50.
+var currencySymbols = []string{"$", "€", "£", "¥"}
+
+// moneyLiteral returns the length of a money literal at the start of s, or 0.
+func moneyLiteral(s string) int {
+	for _, sym := range currencySymbols {
+		if !strings.HasPrefix(s, sym) {
+			continue
+		}
+		j := len(sym)
+		for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+			j++
+		}
+		if j > len(sym) {
+			return j
+		}
+	}
+	return 0
+}
+
 type token struct {
 	typ tokenType
 	val string
+	// pos is the byte offset of the token in the input.
+	pos int
+	// legacy holds the original text when a deprecated spelling was used.
+	legacy string
+}
+
+// legacySpellings are retired operator spellings still accepted by the
+// lexer, mapped to the token they produce, so that ParseWithWarnings can
+// report them. No spelling of the grammar has been retired yet.
+var legacySpellings []legacySpelling
+
+type legacySpelling struct {
+	text string
+	tok  token
+}
+
+// matchLegacy returns the token for a deprecated spelling at the start of
+// remain.
+func matchLegacy(remain string) (token, bool) {
+	for _, l := range legacySpellings {
+		if !strings.HasPrefix(remain, l.text) {
+			continue
+		}
+		n := len(l.text)
+		if isDelim(rune(l.text[0])) || len(remain) == n || isDelim(rune(remain[n])) {
+			t := l.tok
+			t.legacy = l.text
+			return t, true
+		}
+	}
+	return token{}, false
 }
 
 func isDelim(r rune) bool {
 	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
 }
 
+// isPathDot reports whether the '.' at input[k] separates the parts of a
+// dotted field name such as lookup.column, given n identifier bytes before it.
+func isPathDot(input string, k, n int) bool {
+	return input[k] == '.' && n > 0 && k+1 < len(input) && !isDelim(rune(input[k+1]))
+}
+
+// pathIndexLen returns the length of an index such as [0] at input[k] that
+// follows n identifier bytes, as in Items[0].Price, or 0 when there is none.
+func pathIndexLen(input string, k, n int) int {
+	if n == 0 || input[k] != '[' {
+		return 0
+	}
+	j := k + 1
+	for j < len(input) && unicode.IsDigit(rune(input[j])) {
+		j++
+	}
+	if j == k+1 || j >= len(input) || input[j] != ']' {
+		return 0
+	}
+	return j + 1 - k
+}
+
+// cidrSuffixLen returns the length of a prefix length such as /8 at the start
+// of s, so that a number like 10.0.0.0/8 lexes as one CIDR value, or 0.
+func cidrSuffixLen(s string) int {
+	j := 1
+	for j < len(s) && unicode.IsDigit(rune(s[j])) {
+		j++
+	}
+	if len(s) == 0 || s[0] != '/' || j == 1 {
+		return 0
+	}
+	return j
+}
+
 func lex(input string) ([]token, error) {
 	var tokens []token
 	i := 0
@@ -47,64 +158,189 @@ func lex(input string) ([]token, error) {
 		}
 
 		remain := input[i:]
+		if t, ok := matchLegacy(remain); ok {
+			t.pos = i
+			tokens = append(tokens, t)
+			i += len(t.legacy)
+			continue
+		}
 		switch {
 		case strings.HasPrefix(remain, "and") && (len(remain) == 3 || isDelim(rune(remain[3]))):
-			tokens = append(tokens, token{typ: tokenAnd, val: "and"})
+			tokens = append(tokens, token{typ: tokenAnd, val: "and", pos: i})
 			i += 3
 			continue
 		case strings.HasPrefix(remain, "or") && (len(remain) == 2 || isDelim(rune(remain[2]))):
-			tokens = append(tokens, token{typ: tokenOr, val: "or"})
+			tokens = append(tokens, token{typ: tokenOr, val: "or", pos: i})
+			i += 2
+			continue
+		case strings.HasPrefix(remain, "xor") && (len(remain) == 3 || isDelim(rune(remain[3]))):
+			tokens = append(tokens, token{typ: tokenXor, val: "xor", pos: i})
+			i += 3
+			continue
+		case strings.HasPrefix(remain, "if") && (len(remain) == 2 || isDelim(rune(remain[2]))):
+			tokens = append(tokens, token{typ: tokenIf, val: "if", pos: i})
 			i += 2
 			continue
+		case strings.HasPrefix(remain, "then") && (len(remain) == 4 || isDelim(rune(remain[4]))):
+			tokens = append(tokens, token{typ: tokenThen, val: "then", pos: i})
+			i += 4
+			continue
 		case strings.HasPrefix(remain, "not") && (len(remain) == 3 || isDelim(rune(remain[3]))):
-			tokens = append(tokens, token{typ: tokenNot, val: "not"})
+			tokens = append(tokens, token{typ: tokenNot, val: "not", pos: i})
 			i += 3
 			continue
 		case strings.HasPrefix(remain, "is not") && (len(remain) == 6 || isDelim(rune(remain[6]))):
-			tokens = append(tokens, token{typ: tokenIsNot, val: "is not"})
+			tokens = append(tokens, token{typ: tokenIsNot, val: "is not", pos: i})
 			i += 6
 			continue
 		case strings.HasPrefix(remain, "is") && (len(remain) == 2 || isDelim(rune(remain[2]))):
-			tokens = append(tokens, token{typ: tokenIs, val: "is"})
+			tokens = append(tokens, token{typ: tokenIs, val: "is", pos: i})
 			i += 2
 			continue
 		case strings.HasPrefix(remain, "contains") && (len(remain) == 8 || isDelim(rune(remain[8]))):
-			tokens = append(tokens, token{typ: tokenContains, val: "contains"})
+			tokens = append(tokens, token{typ: tokenContains, val: "contains", pos: i})
+			i += 8
+			continue
+		case strings.HasPrefix(remain, "startswith") && (len(remain) == 10 || isDelim(rune(remain[10]))):
+			tokens = append(tokens, token{typ: tokenStartsWith, val: "startswith", pos: i})
+			i += 10
+			continue
+		case strings.HasPrefix(remain, "endswith") && (len(remain) == 8 || isDelim(rune(remain[8]))):
+			tokens = append(tokens, token{typ: tokenEndsWith, val: "endswith", pos: i})
 			i += 8
 			continue
+		case strings.HasPrefix(remain, "glob") && (len(remain) == 4 || isDelim(rune(remain[4]))):
+			tokens = append(tokens, token{typ: tokenGlob, val: "glob", pos: i})
+			i += 4
+			continue
+		case strings.HasPrefix(remain, "soundslike") && (len(remain) == 10 || isDelim(rune(remain[10]))):
+			tokens = append(tokens, token{typ: tokenSoundsLike, val: "soundslike", pos: i})
+			i += 10
+			continue
+		case strings.HasPrefix(remain, "between") && (len(remain) == 7 || isDelim(rune(remain[7]))):
+			tokens = append(tokens, token{typ: tokenBetween, val: "between", pos: i})
+			i += 7
+			continue
+		case strings.HasPrefix(remain, "containsall") && (len(remain) == 11 || isDelim(rune(remain[11]))):
+			tokens = append(tokens, token{typ: tokenContainsAll, val: "containsall", pos: i})
+			i += 11
+			continue
+		case strings.HasPrefix(remain, "containsany") && (len(remain) == 11 || isDelim(rune(remain[11]))):
+			tokens = append(tokens, token{typ: tokenContainsAny, val: "containsany", pos: i})
+			i += 11
+			continue
+		case strings.HasPrefix(remain, "["):
+			tokens = append(tokens, token{typ: tokenLBracket, val: "[", pos: i})
+			i++
+			continue
+		case strings.HasPrefix(remain, "]"):
+			tokens = append(tokens, token{typ: tokenRBracket, val: "]", pos: i})
+			i++
+			continue
+		case strings.HasPrefix(remain, "before") && (len(remain) == 6 || isDelim(rune(remain[6]))):
+			tokens = append(tokens, token{typ: tokenBefore, val: "before", pos: i})
+			i += 6
+			continue
+		case strings.HasPrefix(remain, "after") && (len(remain) == 5 || isDelim(rune(remain[5]))):
+			tokens = append(tokens, token{typ: tokenAfter, val: "after", pos: i})
+			i += 5
+			continue
+		case strings.HasPrefix(remain, "within") && (len(remain) == 6 || isDelim(rune(remain[6]))):
+			tokens = append(tokens, token{typ: tokenWithin, val: "within", pos: i})
+			i += 6
+			continue
+		case strings.HasPrefix(remain, "in") && (len(remain) == 2 || isDelim(rune(remain[2]))):
+			tokens = append(tokens, token{typ: tokenIn, val: "in", pos: i})
+			i += 2
+			continue
+		case strings.HasPrefix(remain, "haskey") && (len(remain) == 6 || isDelim(rune(remain[6]))):
+			tokens = append(tokens, token{typ: tokenHasKey, val: "haskey", pos: i})
+			i += 6
+			continue
+		case strings.HasPrefix(remain, "any") && (len(remain) == 3 || isDelim(rune(remain[3]))):
+			tokens = append(tokens, token{typ: tokenAny, val: "any", pos: i})
+			i += 3
+			continue
+		case strings.HasPrefix(remain, "all") && (len(remain) == 3 || isDelim(rune(remain[3]))):
+			tokens = append(tokens, token{typ: tokenAll, val: "all", pos: i})
+			i += 3
+			continue
+		case strings.HasPrefix(remain, "=="):
+			tokens = append(tokens, token{typ: tokenIs, val: "is", pos: i})
+			i += 2
+			continue
+		case strings.HasPrefix(remain, "!="):
+			tokens = append(tokens, token{typ: tokenIsNot, val: "is not", pos: i})
+			i += 2
+			continue
 		case strings.HasPrefix(remain, ">="):
-			tokens = append(tokens, token{typ: tokenGTE, val: ">="})
+			tokens = append(tokens, token{typ: tokenGTE, val: ">=", pos: i})
 			i += 2
 			continue
 		case strings.HasPrefix(remain, "<="):
-			tokens = append(tokens, token{typ: tokenLTE, val: "<="})
+			tokens = append(tokens, token{typ: tokenLTE, val: "<=", pos: i})
 			i += 2
 			continue
 		case strings.HasPrefix(remain, ">"):
-			tokens = append(tokens, token{typ: tokenGT, val: ">"})
+			tokens = append(tokens, token{typ: tokenGT, val: ">", pos: i})
 			i++
 			continue
 		case strings.HasPrefix(remain, "<"):
-			tokens = append(tokens, token{typ: tokenLT, val: "<"})
+			tokens = append(tokens, token{typ: tokenLT, val: "<", pos: i})
 			i++
 			continue
 		case strings.HasPrefix(remain, "("):
-			tokens = append(tokens, token{typ: tokenLParen, val: "("})
+			tokens = append(tokens, token{typ: tokenLParen, val: "(", pos: i})
 			i++
 			continue
 		case strings.HasPrefix(remain, ")"):
-			tokens = append(tokens, token{typ: tokenRParen, val: ")"})
+			tokens = append(tokens, token{typ: tokenRParen, val: ")", pos: i})
+			i++
+			continue
+		case strings.HasPrefix(remain, "??"):
+			tokens = append(tokens, token{typ: tokenCoalesce, val: "??", pos: i})
+			i += 2
+			continue
+		case strings.HasPrefix(remain, ":"):
+			tokens = append(tokens, token{typ: tokenColon, val: ":", pos: i})
+			i++
+			continue
+		case strings.HasPrefix(remain, "@"):
+			tokens = append(tokens, token{typ: tokenAt, val: "@", pos: i})
+			i++
+			continue
+		case strings.HasPrefix(remain, "%"):
+			tokens = append(tokens, token{typ: tokenMod, val: "%", pos: i})
+			i++
+			continue
+		case strings.HasPrefix(remain, ","):
+			tokens = append(tokens, token{typ: tokenComma, val: ",", pos: i})
 			i++
 			continue
-		case remain[0] == '"':
+		case moneyLiteral(remain) > 0:
+			n := moneyLiteral(remain)
+			tokens = append(tokens, token{typ: tokenMoney, val: remain[:n], pos: i})
+			i += n
+			continue
+		case remain[0] == '"' || remain[0] == '\'':
+			// A doubled quote stands for one quote character, as in
+			// "say ""hi""", since backslashes are kept as written.
+			var val strings.Builder
 			j := 1
-			for i+j < len(input) && input[i+j] != '"' {
+			for {
+				if i+j >= len(input) {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				if input[i+j] == remain[0] {
+					if i+j+1 >= len(input) || input[i+j+1] != remain[0] {
+						break
+					}
+					j++
+				}
+				val.WriteByte(input[i+j])
 				j++
 			}
-			if i+j >= len(input) {
-				return nil, fmt.Errorf("unterminated string")
-			}
-			tokens = append(tokens, token{typ: tokenString, val: input[i+1 : i+j]})
+			tokens = append(tokens, token{typ: tokenString, val: val.String(), pos: i})
 			i += j + 1
 			continue
 		default:
@@ -113,22 +349,30 @@ func lex(input string) ([]token, error) {
 				for i+j < len(input) && (unicode.IsDigit(rune(input[i+j])) || input[i+j] == '.') {
 					j++
 				}
-				tokens = append(tokens, token{typ: tokenIdent, val: input[i : i+j]})
+				j += cidrSuffixLen(input[i+j:])
+				tokens = append(tokens, token{typ: tokenIdent, val: input[i : i+j], pos: i})
 				i += j
 				continue
 			}
 			j := 0
-			for i+j < len(input) && !unicode.IsSpace(rune(input[i+j])) && !isDelim(rune(input[i+j])) {
+			for i+j < len(input) && !unicode.IsSpace(rune(input[i+j])) {
+				if n := pathIndexLen(input, i+j, j); n > 0 {
+					j += n
+					continue
+				}
+				if isDelim(rune(input[i+j])) && !isPathDot(input, i+j, j) {
+					break
+				}
 				j++
 			}
 			if j == 0 {
 				return nil, fmt.Errorf("unexpected character %q", input[i])
 			}
-			tokens = append(tokens, token{typ: tokenIdent, val: input[i : i+j]})
+			tokens = append(tokens, token{typ: tokenIdent, val: input[i : i+j], pos: i})
 			i += j
 			continue
 		}
 	}
-	tokens = append(tokens, token{typ: tokenEOF})
+	tokens = append(tokens, token{typ: tokenEOF, pos: len(input)})
 	return tokens, nil
 }
@@ -2,20 +2,84 @@ package simple
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 
 	"github.com/arran4/go-evaluator"
 )
 
-// Parse converts the input expression string into a Query.
-func Parse(input string) (evaluator.Query, error) {
+// Option changes the grammar accepted by Parse.
+type Option int
+
+const (
+	// SearchSyntax accepts search box style input as well as the strict
+	// grammar: predicates next to each other are joined with and, and
+	// key:value is shorthand for key is value, as in Status:"open" Age>30.
+	SearchSyntax Option = iota + 1
+	// LowerFields lower-cases the field names of the query, so that Name and
+	// NAME both refer to the field name. It suits records whose keys are
+	// lower-cased as they are read, as stream.LowerKeys does.
+	LowerFields
+)
+
+// mode holds the Options in effect while parsing.
+type mode struct {
+	search      bool
+	lowerFields bool
+}
+
+func parseMode(opts []any) mode {
+	var m mode
+	for _, o := range opts {
+		switch o {
+		case SearchSyntax:
+			m.search = true
+		case LowerFields:
+			m.lowerFields = true
+		}
+	}
+	return m
+}
+
+// Parse converts the input expression string into a Query. Options such as
+// SearchSyntax relax the grammar.
+func Parse(input string, opts ...any) (evaluator.Query, error) {
+	q, _, err := ParseWithWarnings(input, opts...)
+	return q, err
+}
+
+// ParseWithWarnings is like Parse but also returns warnings for retired
+// spellings that are still accepted.
+func ParseWithWarnings(input string, opts ...any) (evaluator.Query, []evaluator.Warning, error) {
 	tokens, err := lex(input)
 	if err != nil {
-		return evaluator.Query{}, err
+		return evaluator.Query{}, nil, err
 	}
+	var warnings []evaluator.Warning
+	for _, t := range tokens {
+		if t.legacy != "" {
+			warnings = append(warnings, evaluator.Warning{
+				Code:    "deprecated-operator",
+				Path:    strconv.Itoa(t.pos),
+				Message: fmt.Sprintf("%q is deprecated, use %q", t.legacy, t.val),
+			})
+		}
+	}
+	m := parseMode(opts)
+	q, err := parseTokens(tokens, m)
+	if err != nil {
+		return evaluator.Query{}, nil, err
+	}
+	if m.lowerFields {
+		lowerFields(reflect.ValueOf(&q).Elem())
+	}
+	return q, warnings, nil
+}
+
+func parseTokens(tokens []token, m mode) (evaluator.Query, error) {
 	pos := 0
-	q, err := parseExpr(tokens, &pos)
+	q, err := parseExpr(tokens, &pos, m)
 	if err != nil {
 		return evaluator.Query{}, err
 	}
@@ -34,18 +98,18 @@ func Parse(input string) (evaluator.Query, error) {
 // The lint errors suggest they don't, but we verified the file content.
 // We will simply proceed to fix the tests that call Evaluate.
 
-func parseExpr(ts []token, pos *int) (evaluator.Query, error) {
-	return parseOr(ts, pos)
+func parseExpr(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	return parseOr(ts, pos, m)
 }
 
-func parseOr(ts []token, pos *int) (evaluator.Query, error) {
-	left, err := parseAnd(ts, pos)
+func parseOr(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	left, err := parseXor(ts, pos, m)
 	if err != nil {
 		return evaluator.Query{}, err
 	}
 	for ts[*pos].typ == tokenOr {
 		*pos++
-		right, err := parseAnd(ts, pos)
+		right, err := parseXor(ts, pos, m)
 		if err != nil {
 			return evaluator.Query{}, err
 		}
@@ -54,14 +118,39 @@ func parseOr(ts []token, pos *int) (evaluator.Query, error) {
 	return left, nil
 }
 
-func parseAnd(ts []token, pos *int) (evaluator.Query, error) {
-	left, err := parseUnary(ts, pos)
+// parseXor parses operands joined by xor, which binds tighter than or and
+// looser than and. A chain such as a xor b xor c becomes one XorExpression,
+// true when exactly one operand is, rather than nested pairs.
+func parseXor(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	left, err := parseAnd(ts, pos, m)
 	if err != nil {
 		return evaluator.Query{}, err
 	}
-	for ts[*pos].typ == tokenAnd {
+	if ts[*pos].typ != tokenXor {
+		return left, nil
+	}
+	qs := []evaluator.Query{left}
+	for ts[*pos].typ == tokenXor {
 		*pos++
-		right, err := parseUnary(ts, pos)
+		right, err := parseAnd(ts, pos, m)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		qs = append(qs, right)
+	}
+	return evaluator.Query{Expression: &evaluator.XorExpression{Expressions: qs}}, nil
+}
+
+func parseAnd(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	left, err := parseUnary(ts, pos, m)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	for ts[*pos].typ == tokenAnd || (m.search && startsPredicate(ts[*pos])) {
+		if ts[*pos].typ == tokenAnd {
+			*pos++
+		}
+		right, err := parseUnary(ts, pos, m)
 		if err != nil {
 			return evaluator.Query{}, err
 		}
@@ -70,22 +159,81 @@ func parseAnd(ts []token, pos *int) (evaluator.Query, error) {
 	return left, nil
 }
 
-func parseUnary(ts []token, pos *int) (evaluator.Query, error) {
+// startsPredicate reports whether t can begin a predicate, which in search
+// syntax implies an and with the predicate before it.
+func startsPredicate(t token) bool {
+	switch t.typ {
+	case tokenIdent:
+		return !endsCaseBranch(t)
+	case tokenLParen, tokenNot:
+		return true
+	}
+	return false
+}
+
+// caseWord reports whether t is the word w of a case expression. The words
+// case, when, else and end are not reserved: they are only recognised where
+// the case syntax expects them, so fields may still use those names.
+func caseWord(t token, w string) bool {
+	return t.typ == tokenIdent && strings.EqualFold(t.val, w)
+}
+
+// endsCaseBranch reports whether t ends the result of a case branch.
+func endsCaseBranch(t token) bool {
+	return caseWord(t, "when") || caseWord(t, "else") || caseWord(t, "end")
+}
+
+func parseUnary(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	if ts[*pos].typ == tokenIf {
+		return parseImplies(ts, pos, m)
+	}
 	if ts[*pos].typ == tokenNot {
 		*pos++
-		exp, err := parseUnary(ts, pos)
+		exp, err := parseUnary(ts, pos, m)
 		if err != nil {
 			return evaluator.Query{}, err
 		}
 		return evaluator.Query{Expression: &evaluator.NotExpression{Expression: exp}}, nil
 	}
-	return parsePrimary(ts, pos)
+	return parsePrimary(ts, pos, m)
 }
 
-func parsePrimary(ts []token, pos *int) (evaluator.Query, error) {
+// parseImplies parses if COND then RESULT. RESULT extends as far as an or
+// expression does, so the rule is usually parenthesised when combined with
+// other conditions.
+func parseImplies(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	*pos++
+	cond, err := parseOr(ts, pos, m)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if ts[*pos].typ != tokenThen {
+		return evaluator.Query{}, fmt.Errorf("expected then after if condition")
+	}
+	*pos++
+	result, err := parseOr(ts, pos, m)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	return evaluator.Query{Expression: &evaluator.ImpliesExpression{If: cond, Then: result}}, nil
+}
+
+func parsePrimary(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	if ts[*pos].typ == tokenLParen && isCoalesceStart(ts, *pos+1) {
+		*pos++
+		lhs, err := parseCoalesce(ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		if ts[*pos].typ != tokenRParen {
+			return evaluator.Query{}, fmt.Errorf("expected )")
+		}
+		*pos++
+		return finishTermComparison(lhs, ts, pos)
+	}
 	if ts[*pos].typ == tokenLParen {
 		*pos++
-		q, err := parseExpr(ts, pos)
+		q, err := parseExpr(ts, pos, m)
 		if err != nil {
 			return evaluator.Query{}, err
 		}
@@ -95,33 +243,150 @@ func parsePrimary(ts []token, pos *int) (evaluator.Query, error) {
 		*pos++
 		return q, nil
 	}
-	return parseComparison(ts, pos)
+	if caseWord(ts[*pos], "case") && caseWord(ts[*pos+1], "when") {
+		return parseCase(ts, pos, m)
+	}
+	return parseComparison(ts, pos, m)
 }
 
-func parseComparison(ts []token, pos *int) (evaluator.Query, error) {
+// parseCase parses case when COND then RESULT ... [else RESULT] end into a
+// CaseExpression.
+func parseCase(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	*pos++
+	e := &evaluator.CaseExpression{}
+	for caseWord(ts[*pos], "when") {
+		*pos++
+		cond, err := parseOr(ts, pos, m)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		if ts[*pos].typ != tokenThen {
+			return evaluator.Query{}, fmt.Errorf("expected then after when condition")
+		}
+		*pos++
+		result, err := parseOr(ts, pos, m)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		e.Cases = append(e.Cases, evaluator.CaseBranch{When: cond, Then: result})
+	}
+	if caseWord(ts[*pos], "else") {
+		*pos++
+		result, err := parseOr(ts, pos, m)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		e.Else = result
+	}
+	if !caseWord(ts[*pos], "end") {
+		return evaluator.Query{}, fmt.Errorf("expected end to close case")
+	}
+	*pos++
+	return evaluator.Query{Expression: e}, nil
+}
+
+func parseComparison(ts []token, pos *int, m mode) (evaluator.Query, error) {
 	if ts[*pos].typ != tokenIdent {
 		return evaluator.Query{}, fmt.Errorf("expected identifier")
 	}
+	if isCoalesceStart(ts, *pos) {
+		lhs, err := parseCoalesce(ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		return finishTermComparison(lhs, ts, pos)
+	}
+	if ts[*pos+1].typ == tokenLParen {
+		return parseTermComparison(ts, pos, m)
+	}
 	field := ts[*pos].val
 	*pos++
 
+	// A bare field is shorthand for field is true.
+	switch next := ts[*pos]; {
+	case next.typ == tokenAnd, next.typ == tokenOr, next.typ == tokenXor, next.typ == tokenThen, next.typ == tokenRParen, next.typ == tokenEOF, endsCaseBranch(next),
+		m.search && startsPredicate(next):
+		return evaluator.Query{Expression: &evaluator.IsExpression{Field: field, Value: true}}, nil
+	}
+
 	tok := ts[*pos]
 	*pos++
+	if tok.typ == tokenColon && m.search {
+		tok.typ = tokenIs
+	}
+	if tok.typ == tokenBetween {
+		return parseBetween(field, ts, pos)
+	}
+	if tok.typ == tokenIn {
+		val, err := parseValue(ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		cidr, ok := val.(string)
+		if !ok {
+			return evaluator.Query{}, fmt.Errorf("in expects a CIDR range such as 10.0.0.0/8")
+		}
+		e, err := evaluator.NewCIDRContainsExpression(field, cidr)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		return evaluator.Query{Expression: e}, nil
+	}
+	if tok.typ == tokenWithin {
+		return parseWithin(field, ts, pos)
+	}
+	if tok.typ == tokenMod {
+		*pos--
+		q, err := finishTermComparison(evaluator.Field{Name: field}, ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		return modExpression(q), nil
+	}
+	if tok.typ == tokenAny || tok.typ == tokenAll {
+		return parseQuantifier(field, tok, ts, pos, m)
+	}
+	if tok.typ == tokenContainsAll || tok.typ == tokenContainsAny {
+		values, err := parseList(ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		if tok.typ == tokenContainsAll {
+			return evaluator.Query{Expression: &evaluator.ContainsAllExpression{Field: field, Values: values}}, nil
+		}
+		return evaluator.Query{Expression: &evaluator.ContainsAnyExpression{Field: field, Values: values}}, nil
+	}
+
+	if (tok.typ == tokenIs || tok.typ == tokenIsNot) && ts[*pos].typ == tokenIdent && ts[*pos].val == "format" && ts[*pos+1].typ == tokenString {
+		q := evaluator.Query{Expression: &evaluator.IsFormatExpression{Field: field, Format: ts[*pos+1].val}}
+		*pos += 2
+		if tok.typ == tokenIsNot {
+			q = evaluator.Query{Expression: &evaluator.NotExpression{Expression: q}}
+		}
+		return q, nil
+	}
 
 	var op tokenType
 	switch tok.typ {
-	case tokenIs, tokenIsNot, tokenContains, tokenGT, tokenGTE, tokenLT, tokenLTE:
+	case tokenIs, tokenIsNot, tokenContains, tokenHasKey, tokenBefore, tokenAfter, tokenStartsWith, tokenEndsWith, tokenGlob, tokenSoundsLike, tokenGT, tokenGTE, tokenLT, tokenLTE:
 		op = tok.typ
 	default:
 		return evaluator.Query{}, fmt.Errorf("unexpected operator %q", tok.val)
 	}
 
-	valTok := ts[*pos]
-	*pos++
-	if valTok.typ != tokenIdent && valTok.typ != tokenString && valTok.typ != tokenNumber {
-		return evaluator.Query{}, fmt.Errorf("expected value")
+	if ts[*pos].typ == tokenAt {
+		other, err := parseFieldRef(ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		fop, ok := termOperations[op]
+		if !ok || op == tokenContains {
+			return evaluator.Query{}, fmt.Errorf("%s cannot compare two fields", tok.val)
+		}
+		return evaluator.Query{Expression: &evaluator.FieldCompareExpression{FieldA: field, Op: fop, FieldB: other}}, nil
 	}
-	val, err := tokenValue(valTok)
+
+	val, err := parseValue(ts, pos)
 	if err != nil {
 		return evaluator.Query{}, err
 	}
@@ -133,6 +398,28 @@ func parseComparison(ts []token, pos *int) (evaluator.Query, error) {
 		return evaluator.Query{Expression: &evaluator.IsNotExpression{Field: field, Value: val}}, nil
 	case tokenContains:
 		return evaluator.Query{Expression: &evaluator.ContainsExpression{Field: field, Value: val}}, nil
+	case tokenHasKey:
+		return evaluator.Query{Expression: &evaluator.HasKeyExpression{Field: field, Key: val}}, nil
+	case tokenBefore:
+		return evaluator.Query{Expression: &evaluator.BeforeExpression{Field: field, Value: val}}, nil
+	case tokenAfter:
+		return evaluator.Query{Expression: &evaluator.AfterExpression{Field: field, Value: val}}, nil
+	case tokenStartsWith:
+		return evaluator.Query{Expression: &evaluator.StartsWithExpression{Field: field, Value: val}}, nil
+	case tokenEndsWith:
+		return evaluator.Query{Expression: &evaluator.EndsWithExpression{Field: field, Value: val}}, nil
+	case tokenGlob:
+		pattern, ok := val.(string)
+		if !ok {
+			return evaluator.Query{}, fmt.Errorf("glob pattern must be a string")
+		}
+		return evaluator.Query{Expression: &evaluator.GlobExpression{Field: field, Pattern: pattern}}, nil
+	case tokenSoundsLike:
+		name, ok := val.(string)
+		if !ok {
+			return evaluator.Query{}, fmt.Errorf("soundslike value must be a string")
+		}
+		return evaluator.Query{Expression: &evaluator.SoundsLikeExpression{Field: field, Value: name}}, nil
 	case tokenGT:
 		return evaluator.Query{Expression: &evaluator.GreaterThanExpression{Field: field, Value: val}}, nil
 	case tokenGTE:
@@ -146,6 +433,392 @@ func parseComparison(ts []token, pos *int) (evaluator.Query, error) {
 	}
 }
 
+// parseBetween parses the LOW and HIGH of field between LOW and HIGH. Like
+// SQL the range includes both ends.
+func parseBetween(field string, ts []token, pos *int) (evaluator.Query, error) {
+	low, err := parseValue(ts, pos)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if ts[*pos].typ != tokenAnd {
+		return evaluator.Query{}, fmt.Errorf("expected and in between")
+	}
+	*pos++
+	high, err := parseValue(ts, pos)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	return evaluator.Query{Expression: &evaluator.BetweenExpression{Field: field, Low: low, High: high, Inclusive: true}}, nil
+}
+
+// parseWithin parses the DURATION of field within DURATION. The lexer splits
+// a bare duration such as 24h into 24 and h, so adjoining tokens are joined
+// back together; a quoted duration is taken as it is.
+func parseWithin(field string, ts []token, pos *int) (evaluator.Query, error) {
+	tok := ts[*pos]
+	if tok.typ != tokenIdent && tok.typ != tokenString {
+		return evaluator.Query{}, fmt.Errorf("expected duration after within")
+	}
+	*pos++
+	d := tok.val
+	for tok.typ == tokenIdent && ts[*pos].typ == tokenIdent && ts[*pos].pos == tok.pos+len(tok.val) {
+		tok = ts[*pos]
+		d += tok.val
+		*pos++
+	}
+	if _, err := evaluator.ParseDuration(d); err != nil {
+		return evaluator.Query{}, err
+	}
+	return evaluator.Query{Expression: &evaluator.WithinDurationExpression{Field: field, Duration: d}}, nil
+}
+
+// termOperations maps comparison tokens to ComparisonExpression operations.
+var termOperations = map[tokenType]string{
+	tokenIs:       "eq",
+	tokenIsNot:    "neq",
+	tokenGT:       "gt",
+	tokenGTE:      "gte",
+	tokenLT:       "lt",
+	tokenLTE:      "lte",
+	tokenContains: "contains",
+}
+
+// parseTermComparison parses a comparison whose left hand side is a function
+// call such as bucket(UserID, 10) is 3, optionally followed by a modulo as in
+// hash(UserID) % 10 is 3.
+func parseTermComparison(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	switch ts[*pos].val {
+	case "len":
+		return parseLength(ts, pos)
+	case "count":
+		return parseCount(ts, pos, m)
+	case "typeof":
+		return parseTypeOf(ts, pos)
+	}
+	lhs, err := parseCall(ts, pos)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	return finishTermComparison(lhs, ts, pos)
+}
+
+// finishTermComparison parses the optional modulo, operator and value that
+// follow the term lhs.
+func finishTermComparison(lhs evaluator.Term, ts []token, pos *int) (evaluator.Query, error) {
+	if ts[*pos].typ == tokenMod {
+		*pos++
+		rhs, err := parseArg(ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		lhs = evaluator.ModTerm{LHS: lhs, RHS: rhs}
+	}
+	tok := ts[*pos]
+	*pos++
+	op, ok := termOperations[tok.typ]
+	if !ok {
+		return evaluator.Query{}, fmt.Errorf("unexpected operator %q", tok.val)
+	}
+	if ts[*pos].typ == tokenAt {
+		other, err := parseFieldRef(ts, pos)
+		if err != nil {
+			return evaluator.Query{}, err
+		}
+		return evaluator.Query{Expression: &evaluator.ComparisonExpression{LHS: lhs, RHS: evaluator.Field{Name: other}, Operation: op}}, nil
+	}
+	val, err := parseValue(ts, pos)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	return evaluator.Query{Expression: &evaluator.ComparisonExpression{LHS: lhs, RHS: evaluator.Constant{Value: val}, Operation: op}}, nil
+}
+
+// parseFieldRef parses @Name, which names a field of the record where a value
+// is expected, as in EndTime > @StartTime. A bare Name is a string value.
+func parseFieldRef(ts []token, pos *int) (string, error) {
+	at := ts[*pos]
+	*pos++
+	t := ts[*pos]
+	if t.typ != tokenIdent || t.pos != at.pos+1 {
+		return "", fmt.Errorf("expected field name after @")
+	}
+	*pos++
+	return t.val, nil
+}
+
+// modExpression turns Field % N is R, for integers N and R, into a
+// ModExpression and returns other comparisons of a modulo as they are.
+func modExpression(q evaluator.Query) evaluator.Query {
+	c, ok := q.Expression.(*evaluator.ComparisonExpression)
+	if !ok || c.Operation != "eq" {
+		return q
+	}
+	mod, ok := c.LHS.(evaluator.ModTerm)
+	if !ok {
+		return q
+	}
+	field, ok := mod.LHS.(evaluator.Field)
+	if !ok {
+		return q
+	}
+	d, ok := mod.RHS.(evaluator.Constant)
+	if !ok {
+		return q
+	}
+	r, _ := c.RHS.(evaluator.Constant)
+	divisor, ok := d.Value.(int)
+	remainder, rok := r.Value.(int)
+	if !ok || !rok || divisor == 0 {
+		return q
+	}
+	return evaluator.Query{Expression: &evaluator.ModExpression{Field: field.Name, Divisor: int64(divisor), Remainder: int64(remainder)}}
+}
+
+// isCoalesceStart reports whether the tokens at pos begin a coalesce such as
+// Region ?? "unknown".
+func isCoalesceStart(ts []token, pos int) bool {
+	if ts[pos].typ != tokenIdent {
+		return false
+	}
+	if ts[pos+1].typ == tokenCoalesce {
+		return true
+	}
+	if ts[pos+1].typ != tokenLParen {
+		return false
+	}
+	// Skip over a call such as coalesce(a, b) ?? c.
+	depth := 0
+	for i := pos + 1; ts[i].typ != tokenEOF; i++ {
+		switch ts[i].typ {
+		case tokenLParen:
+			depth++
+		case tokenRParen:
+			depth--
+			if depth == 0 {
+				return ts[i+1].typ == tokenCoalesce
+			}
+		}
+	}
+	return false
+}
+
+// parseCoalesce parses terms separated by ??.
+func parseCoalesce(ts []token, pos *int) (evaluator.Term, error) {
+	var terms []evaluator.Term
+	for {
+		t, err := parseArg(ts, pos)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+		if ts[*pos].typ != tokenCoalesce {
+			return evaluator.CoalesceTerm{Terms: terms}, nil
+		}
+		*pos++
+	}
+}
+
+// parseLength parses len(Field) followed by a comparison with a number.
+func parseLength(ts []token, pos *int) (evaluator.Query, error) {
+	*pos += 2
+	if ts[*pos].typ != tokenIdent || ts[*pos+1].typ != tokenRParen {
+		return evaluator.Query{}, fmt.Errorf("len expects a single field")
+	}
+	field := ts[*pos].val
+	*pos += 2
+	tok := ts[*pos]
+	*pos++
+	op, ok := termOperations[tok.typ]
+	if !ok || op == "contains" {
+		return evaluator.Query{}, fmt.Errorf("unexpected operator %q", tok.val)
+	}
+	val, err := parseValue(ts, pos)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	return evaluator.Query{Expression: &evaluator.LengthExpression{Field: field, Op: op, Value: val}}, nil
+}
+
+// parseCount parses count(Field, QUERY) followed by a comparison with a
+// number, where QUERY is matched against each element of Field.
+func parseCount(ts []token, pos *int, m mode) (evaluator.Query, error) {
+	*pos += 2
+	if ts[*pos].typ != tokenIdent || ts[*pos+1].typ != tokenComma {
+		return evaluator.Query{}, fmt.Errorf("count expects a field and a query")
+	}
+	field := ts[*pos].val
+	*pos += 2
+	q, err := parseExpr(ts, pos, m)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if ts[*pos].typ != tokenRParen {
+		return evaluator.Query{}, fmt.Errorf("expected )")
+	}
+	*pos++
+	tok := ts[*pos]
+	*pos++
+	op, ok := termOperations[tok.typ]
+	if !ok || op == "contains" {
+		return evaluator.Query{}, fmt.Errorf("unexpected operator %q", tok.val)
+	}
+	val, err := parseValue(ts, pos)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	return evaluator.Query{Expression: &evaluator.CountExpression{Field: field, Query: q, Op: op, Value: val}}, nil
+}
+
+// parseTypeOf parses typeof(Field) is KIND or typeof(Field) is not KIND.
+func parseTypeOf(ts []token, pos *int) (evaluator.Query, error) {
+	*pos += 2
+	if ts[*pos].typ != tokenIdent || ts[*pos+1].typ != tokenRParen {
+		return evaluator.Query{}, fmt.Errorf("typeof expects a single field")
+	}
+	field := ts[*pos].val
+	*pos += 2
+	tok := ts[*pos]
+	*pos++
+	if tok.typ != tokenIs && tok.typ != tokenIsNot {
+		return evaluator.Query{}, fmt.Errorf("typeof can only be compared with is or is not")
+	}
+	val, err := parseValue(ts, pos)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	kind, ok := val.(string)
+	if !ok {
+		return evaluator.Query{}, fmt.Errorf("typeof must be compared with a type name")
+	}
+	q := evaluator.Query{Expression: &evaluator.TypeOfExpression{Field: field, Kind: kind}}
+	if tok.typ == tokenIsNot {
+		q = evaluator.Query{Expression: &evaluator.NotExpression{Expression: q}}
+	}
+	return q, nil
+}
+
+// parseCall parses name(arg, ...). Built-in names map to their Term types;
+// sum, min, max and avg of a single field are the aggregate terms. Any other
+// call becomes a FunctionExpression resolved from the evaluation Context.
+func parseCall(ts []token, pos *int) (evaluator.Term, error) {
+	name := ts[*pos].val
+	*pos += 2
+	var args []evaluator.Term
+	for ts[*pos].typ != tokenRParen {
+		if len(args) > 0 {
+			if ts[*pos].typ != tokenComma {
+				return nil, fmt.Errorf("expected , or )")
+			}
+			*pos++
+		}
+		arg, err := parseArg(ts, pos)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	*pos++
+
+	switch name {
+	case "bucket":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("bucket expects 2 arguments")
+		}
+		field, ok := args[0].(evaluator.Field)
+		if !ok {
+			return nil, fmt.Errorf("bucket expects a field as its first argument")
+		}
+		c, _ := args[1].(evaluator.Constant)
+		n, ok := c.Value.(int)
+		if !ok || n <= 0 {
+			return nil, fmt.Errorf("bucket expects a positive bucket count")
+		}
+		return evaluator.BucketTerm{KeyField: field.Name, Buckets: n}, nil
+	case "random":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("random expects no arguments")
+		}
+		return evaluator.RandomTerm{}, nil
+	case "coalesce":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("coalesce expects at least 1 argument")
+		}
+		return evaluator.CoalesceTerm{Terms: args}, nil
+	case "hash":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("hash expects 1 argument")
+		}
+		return evaluator.HashTerm{Term: args[0]}, nil
+	case "sum", "min", "max", "avg":
+		// Calls with other arguments are left to Context functions, such as
+		// max(a, b) from funcs/math.
+		if len(args) != 1 {
+			break
+		}
+		field, ok := args[0].(evaluator.Field)
+		if !ok {
+			break
+		}
+		switch name {
+		case "sum":
+			return evaluator.SumTerm{Field: field.Name}, nil
+		case "min":
+			return evaluator.MinTerm{Field: field.Name}, nil
+		case "max":
+			return evaluator.MaxTerm{Field: field.Name}, nil
+		default:
+			return evaluator.AvgTerm{Field: field.Name}, nil
+		}
+	}
+	return evaluator.FunctionExpression{Name: name, Args: args}, nil
+}
+
+// parseArg parses a single call argument. Bare identifiers refer to fields.
+func parseArg(ts []token, pos *int) (evaluator.Term, error) {
+	t := ts[*pos]
+	switch t.typ {
+	case tokenString, tokenNumber:
+		*pos++
+		return evaluator.Constant{Value: t.val}, nil
+	case tokenIdent:
+		if ts[*pos+1].typ == tokenLParen {
+			return parseCall(ts, pos)
+		}
+		*pos++
+		val, err := tokenValue(t)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := val.(string); ok {
+			return evaluator.Field{Name: s}, nil
+		}
+		return evaluator.Constant{Value: val}, nil
+	default:
+		return nil, fmt.Errorf("expected argument")
+	}
+}
+
+// parseValue parses a literal value. Money may be written with a currency
+// symbol ($5, €10.50) or an ISO code followed by an amount (USD 3.20).
+func parseValue(ts []token, pos *int) (interface{}, error) {
+	valTok := ts[*pos]
+	*pos++
+	switch valTok.typ {
+	case tokenMoney:
+		return evaluator.ParseMoney(valTok.val)
+	case tokenIdent, tokenString, tokenNumber:
+	default:
+		return nil, fmt.Errorf("expected value")
+	}
+	if valTok.typ == tokenIdent && ts[*pos].typ == tokenIdent && !endsCaseBranch(ts[*pos]) {
+		if m, err := evaluator.ParseMoney(valTok.val + " " + ts[*pos].val); err == nil {
+			*pos++
+			return m, nil
+		}
+	}
+	return tokenValue(valTok)
+}
+
 func tokenValue(t token) (interface{}, error) {
 	switch t.typ {
 	case tokenString:
@@ -164,6 +837,9 @@ func tokenValue(t token) (interface{}, error) {
 		if n, err := strconv.ParseInt(t.val, 10, 64); err == nil {
 			return int(n), nil
 		}
+		if n, err := strconv.ParseUint(t.val, 10, 64); err == nil {
+			return n, nil
+		}
 		if f, err := strconv.ParseFloat(t.val, 64); err == nil {
 			return f, nil
 		}
@@ -173,58 +849,90 @@ func tokenValue(t token) (interface{}, error) {
 	}
 }
 
-// Stringify returns a canonical expression string from a Query.
-func Stringify(q evaluator.Query) string {
-	if q.Expression == nil {
-		return ""
-	}
-	return stringifyExpr(q.Expression)
-}
-
-func stringifyExpr(e evaluator.Expression) string {
-	switch ex := e.(type) {
-	case *evaluator.ContainsExpression:
-		return ex.Field + " contains " + valToString(ex.Value)
-	case *evaluator.IsExpression:
-		return ex.Field + " is " + valToString(ex.Value)
-	case *evaluator.IsNotExpression:
-		return ex.Field + " is not " + valToString(ex.Value)
-	case *evaluator.GreaterThanExpression:
-		return ex.Field + " > " + valToString(ex.Value)
-	case *evaluator.GreaterThanOrEqualExpression:
-		return ex.Field + " >= " + valToString(ex.Value)
-	case *evaluator.LessThanExpression:
-		return ex.Field + " < " + valToString(ex.Value)
-	case *evaluator.LessThanOrEqualExpression:
-		return ex.Field + " <= " + valToString(ex.Value)
-	case *evaluator.AndExpression:
-		parts := make([]string, len(ex.Expressions))
-		for i, p := range ex.Expressions {
-			parts[i] = stringifyExpr(p.Expression)
-		}
-		return "(" + strings.Join(parts, " and ") + ")"
-	case *evaluator.OrExpression:
-		parts := make([]string, len(ex.Expressions))
-		for i, p := range ex.Expressions {
-			parts[i] = stringifyExpr(p.Expression)
-		}
-		return "(" + strings.Join(parts, " or ") + ")"
-	case *evaluator.NotExpression:
-		return "not " + stringifyExpr(ex.Expression.Expression)
-	default:
-		return ""
+// parseList parses a bracketed, comma separated list of values such as
+// ["go", "cli"].
+func parseList(ts []token, pos *int) ([]interface{}, error) {
+	if ts[*pos].typ != tokenLBracket {
+		return nil, fmt.Errorf("expected [")
+	}
+	*pos++
+	values := []interface{}{}
+	for ts[*pos].typ != tokenRBracket {
+		if len(values) > 0 {
+			if ts[*pos].typ != tokenComma {
+				return nil, fmt.Errorf("expected , or ]")
+			}
+			*pos++
+		}
+		v, err := parseValue(ts, pos)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
 	}
+	*pos++
+	return values, nil
 }
 
-func valToString(v interface{}) string {
-	switch x := v.(type) {
-	case string:
-		return "\"" + x + "\""
-	case int, int64, float64, float32:
-		return fmt.Sprint(x)
-	case bool:
-		return fmt.Sprint(x)
-	default:
-		return fmt.Sprint(x)
+// parseQuantifier parses the parenthesised sub-query of field any (...) or
+// field all (...), which is evaluated against each element of field.
+func parseQuantifier(field string, tok token, ts []token, pos *int, m mode) (evaluator.Query, error) {
+	if ts[*pos].typ != tokenLParen {
+		return evaluator.Query{}, fmt.Errorf("expected ( after %s", tok.val)
+	}
+	q, err := parsePrimary(ts, pos, m)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if tok.typ == tokenAll {
+		return evaluator.Query{Expression: &evaluator.AllExpression{Field: field, Query: q}}, nil
+	}
+	return evaluator.Query{Expression: &evaluator.AnyExpression{Field: field, Query: q}}, nil
+}
+
+var fieldType = reflect.TypeOf(evaluator.Field{})
+
+// lowerFields lower-cases, in place, the Field terms and the string fields
+// naming record fields, such as Field, KeyField or FieldA, below v. Struct
+// values held in interfaces are copied so that they can be changed.
+func lowerFields(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		e := v.Elem()
+		if e.Kind() == reflect.Struct && v.CanSet() {
+			c := reflect.New(e.Type()).Elem()
+			c.Set(e)
+			lowerFields(c)
+			v.Set(c)
+			return
+		}
+		lowerFields(e)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			lowerFields(v.Elem())
+		}
+	case reflect.Struct:
+		if v.Type() == fieldType {
+			v.Field(0).SetString(strings.ToLower(v.Field(0).String()))
+			return
+		}
+		for n := 0; n < v.NumField(); n++ {
+			sf := v.Type().Field(n)
+			if !sf.IsExported() || sf.Name == "Metadata" {
+				continue
+			}
+			if f := v.Field(n); strings.Contains(sf.Name, "Field") && f.Kind() == reflect.String {
+				f.SetString(strings.ToLower(f.String()))
+			} else {
+				lowerFields(f)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for n := 0; n < v.Len(); n++ {
+			lowerFields(v.Index(n))
+		}
 	}
 }
@@ -1,8 +1,12 @@
 package simple
 
 import (
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/arran4/go-evaluator"
 )
 
 type testUser struct {
@@ -112,3 +116,799 @@ func TestValToString(t *testing.T) {
 		}
 	}
 }
+
+func TestStringifyRedact(t *testing.T) {
+	q, err := Parse(`Email is "bob@example.com" and Age > 30`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := Stringify(q, RedactFields{"Email"})
+	want := `(Email is "[REDACTED]" and Age > 30)`
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	q, err := Parse(`bucket(UserID, 10) is 3`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := &evaluator.ComparisonExpression{
+		LHS:       evaluator.BucketTerm{KeyField: "UserID", Buckets: 10},
+		RHS:       evaluator.Constant{Value: 3},
+		Operation: "eq",
+	}
+	if !reflect.DeepEqual(q.Expression, want) {
+		t.Fatalf("unexpected expression %#v", q.Expression)
+	}
+	if s := Stringify(q); s != `bucket(UserID, 10) is 3` {
+		t.Errorf("unexpected stringify %q", s)
+	}
+	counts := map[bool]int{}
+	for id := 0; id < 1000; id++ {
+		v, err := q.Evaluate(map[string]interface{}{"UserID": id})
+		if err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+		counts[v]++
+	}
+	if counts[true] < 50 || counts[true] > 150 {
+		t.Errorf("expected roughly a tenth of users in bucket 3, got %d", counts[true])
+	}
+	for _, bad := range []string{`bucket(UserID) is 1`, `bucket("x", 2) is 1`, `bucket(UserID, 0) is 1`, `bucket(UserID 2) is 1`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseSampling(t *testing.T) {
+	for _, e := range []string{`hash(UserID) % 10 is 3`, `random() < 0.5`} {
+		q, err := Parse(e)
+		if err != nil {
+			t.Fatalf("parse %q: %v", e, err)
+		}
+		if s := Stringify(q); s != e {
+			t.Errorf("expected %q, got %q", e, s)
+		}
+	}
+	q, _ := Parse(`random() < 0.5`)
+	ctx := &evaluator.Context{Rand: evaluator.NewSeededRand(1)}
+	n := 0
+	for i := 0; i < 1000; i++ {
+		if v, err := q.Evaluate(map[string]interface{}{}, ctx); err == nil && v {
+			n++
+		}
+	}
+	if n < 400 || n > 600 {
+		t.Errorf("expected about half the records sampled, got %d", n)
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	type order struct{ Total evaluator.MoneyValue }
+	o := &order{Total: evaluator.MoneyValue{Amount: 12, Currency: "EUR"}}
+	for _, e := range []string{`Total > €10.50`, `Total < EUR 20`, `Total is 12.00 EUR`} {
+		q, err := Parse(e)
+		if err != nil {
+			t.Fatalf("parse %q: %v", e, err)
+		}
+		if v, err := q.Evaluate(o); err != nil || !v {
+			t.Errorf("%q: expected match, got %v %v", e, v, err)
+		}
+		q2, err := Parse(Stringify(q))
+		if err != nil || !reflect.DeepEqual(q, q2) {
+			t.Errorf("%q: round trip via %q failed: %v", e, Stringify(q), err)
+		}
+	}
+	q, _ := Parse(`Total > $10`)
+	if _, err := q.Evaluate(o); err == nil {
+		t.Errorf("expected currency mismatch error")
+	}
+}
+
+func TestParseWithWarnings(t *testing.T) {
+	defer func(saved []legacySpelling) { legacySpellings = saved }(legacySpellings)
+	legacySpellings = append(legacySpellings, legacySpelling{"&&", token{typ: tokenAnd, val: "and"}})
+
+	q, warnings, err := ParseWithWarnings(`Name == "bob" && Age != 3 && AND is 1`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want, _ := Parse(`Name is "bob" and Age is not 3 and AND is 1`)
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("legacy spelling parsed differently: %s", Stringify(q))
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+	if warnings[0].Code != "deprecated-operator" || warnings[0].Path != "14" || warnings[1].Path != "26" {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	if _, warnings, _ := ParseWithWarnings(`Name == "bob" and Age != 3`); len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+}
+
+func TestParseUpperCaseFieldNames(t *testing.T) {
+	q, err := Parse(`AND is 1 and OR is 2 and NOT is 3`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ok, err := q.Evaluate(map[string]interface{}{"AND": 1, "OR": 2, "NOT": 3}); err != nil || !ok {
+		t.Errorf("expected fields named AND, OR and NOT to match: %v %v", ok, err)
+	}
+}
+
+func TestParseDottedField(t *testing.T) {
+	q, err := Parse(`churned.reason is "price" and x > 1.5`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	and := q.Expression.(*evaluator.AndExpression)
+	if f := and.Expressions[0].Expression.(*evaluator.IsExpression).Field; f != "churned.reason" {
+		t.Errorf("unexpected field %q", f)
+	}
+	if _, err := Parse(`name. is "x"`); err == nil {
+		t.Errorf("expected error for trailing dot")
+	}
+}
+
+func TestParseStartsWithEndsWith(t *testing.T) {
+	q, err := Parse(`path startswith "/api/" and not path endswith ".json"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(path startswith "/api/" and not path endswith ".json")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	ok, err := q.Evaluate(map[string]interface{}{"path": "/api/users"})
+	if err != nil || !ok {
+		t.Errorf("expected match, got %v %v", ok, err)
+	}
+}
+
+func TestParseGlob(t *testing.T) {
+	q, err := Parse(`path glob "/api/*.json"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `path glob "/api/*.json"` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"path": "/api/v2/x.json"}); !ok {
+		t.Errorf("expected match")
+	}
+	if _, err := Parse(`path glob 3`); err == nil {
+		t.Errorf("expected error for non-string pattern")
+	}
+}
+
+func TestParseSoundsLike(t *testing.T) {
+	q, err := Parse(`surname soundslike "Smith"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `surname soundslike "Smith"` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"surname": "Smyth"}); !ok {
+		t.Errorf("expected match")
+	}
+	if _, err := Parse(`surname soundslike 3`); err == nil {
+		t.Errorf("expected error for non-string value")
+	}
+}
+
+func TestParseIsFormat(t *testing.T) {
+	q, err := Parse(`Email is format "email" and ID is not format "uuid"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(Email is format "email" and not ID is format "uuid")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"Email": "a@example.com", "ID": "42"}); !ok {
+		t.Errorf("expected match")
+	}
+	q, err = Parse(`Kind is format`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"Kind": "format"}); !ok {
+		t.Errorf("expected a bare format to be a value")
+	}
+}
+
+func TestParseLowerFields(t *testing.T) {
+	q, err := Parse(`Name is "Bob" and Address.City is "NZ" and len(Tags) > 1 and bucket(UserID, 10) is 3`, LowerFields)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(((name is "Bob" and address.city is "NZ") and len(tags) > 1) and bucket(userid, 10) is 3)` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+}
+
+func TestParseTermsMarshal(t *testing.T) {
+	rec := map[string]interface{}{"UserID": "u42", "X": -13, "Region": "", "Items": []interface{}{map[string]interface{}{"Price": 12.5}}}
+	for _, in := range []string{
+		`bucket(UserID, 10) is 3`,
+		`hash(UserID) % 100 < 50`,
+		`random() < 2`,
+		`Region ?? "unknown" is "unknown"`,
+		`sum(Items.Price) > 10`,
+		`X % 10 > 3`,
+	} {
+		q, err := Parse(in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		data, err := evaluator.MarshalQuery(q)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", in, err)
+		}
+		var back evaluator.Query
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatalf("%s: unmarshal %s: %v", in, data, err)
+		}
+		want, err := q.Evaluate(rec)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got, err := back.Evaluate(rec); err != nil || got != want {
+			t.Errorf("%s: decoded query evaluates to %v, %v; want %v", in, got, err, want)
+		}
+	}
+}
+
+func TestParseFieldCompare(t *testing.T) {
+	q, err := Parse(`EndTime > @StartTime and Shipped.At >= @Ordered.At and Nick ?? Name is @Login`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	single, _ := Parse(`EndTime > @StartTime`)
+	want := &evaluator.FieldCompareExpression{FieldA: "EndTime", Op: "gt", FieldB: "StartTime"}
+	if !reflect.DeepEqual(single.Expression, want) {
+		t.Errorf("got %#v, want %#v", single.Expression, want)
+	}
+	if got := Stringify(q, MinimalParens); got != `EndTime > @StartTime and Shipped.At >= @Ordered.At and (Nick ?? Name) is @Login` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	rec := map[string]interface{}{
+		"EndTime": 20, "StartTime": 10,
+		"Shipped": map[string]interface{}{"At": "2024-05-02"}, "Ordered": map[string]interface{}{"At": "2024-05-01"},
+		"Name": "abc", "Login": "abc",
+	}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	rec["StartTime"] = 30
+	if ok, err := q.Evaluate(rec); err != nil || ok {
+		t.Errorf("expected no match: %v %v", ok, err)
+	}
+
+	if q, err := Parse(`Status is open`); err != nil {
+		t.Errorf("bare value: %v", err)
+	} else if is, ok := q.Expression.(*evaluator.IsExpression); !ok || is.Value != "open" {
+		t.Errorf("a bare word is still a string value: %#v", q.Expression)
+	}
+	for _, bad := range []string{`A contains @B`, `A > @`, `A > @ B`, `A > @"B"`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseModSemantics(t *testing.T) {
+	is, _ := Parse(`X % 10 is 3`)
+	between, _ := Parse(`X % 10 > 2 and X % 10 < 4`)
+	if _, ok := is.Expression.(*evaluator.ModExpression); !ok {
+		t.Fatalf("expected a ModExpression, got %T", is.Expression)
+	}
+	for _, x := range []int{3, 13, -7, -17, 7, -3} {
+		rec := map[string]interface{}{"X": x}
+		a, err1 := is.Evaluate(rec)
+		b, err2 := between.Evaluate(rec)
+		if err1 != nil || err2 != nil || a != b {
+			t.Errorf("X=%d: is gives %v %v, range gives %v %v", x, a, err1, b, err2)
+		}
+	}
+}
+
+func TestParseLargeInteger(t *testing.T) {
+	q, err := Parse(`ID > 18446744073709551614`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ok, err := q.Evaluate(map[string]interface{}{"ID": uint64(18446744073709551615)}, &evaluator.Context{Decimal: true})
+	if err != nil || !ok {
+		t.Errorf("expected match, got %v %v", ok, err)
+	}
+}
+
+func TestParseMod(t *testing.T) {
+	q, err := Parse(`UserID % 10 is 3`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, ok := q.Expression.(*evaluator.ModExpression); !ok {
+		t.Fatalf("expected ModExpression, got %T", q.Expression)
+	}
+	if got := Stringify(q); got != `UserID % 10 is 3` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"UserID": 43}); !ok {
+		t.Errorf("expected match")
+	}
+	q, err = Parse(`UserID % 10 < 3`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"UserID": 42}); !ok {
+		t.Errorf("expected match")
+	}
+}
+
+func TestParseBetween(t *testing.T) {
+	q, err := Parse(`age between 18 and 65 and name is "bob"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(age between 18 and 65 and name is "bob")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	for age, want := range map[int]bool{17: false, 18: true, 65: true, 66: false} {
+		if ok, _ := q.Evaluate(map[string]interface{}{"age": age, "name": "bob"}); ok != want {
+			t.Errorf("age %d: got %v, want %v", age, ok, want)
+		}
+	}
+	if _, err := Parse(`age between 18 65`); err == nil {
+		t.Errorf("expected error without and")
+	}
+}
+
+func TestParseLength(t *testing.T) {
+	q, err := Parse(`len(Tags) >= 2 and len(Name) is 3`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(len(Tags) >= 2 and len(Name) is 3)` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	rec := map[string]interface{}{"Tags": []interface{}{"a", "b"}, "Name": "bob"}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	for _, bad := range []string{`len(Tags, 2) > 1`, `len(Tags) contains 1`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseTypeOf(t *testing.T) {
+	q, err := Parse(`typeof(id) is "number" or typeof(id) is not string`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(typeof(id) is "number" or not typeof(id) is "string")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"id": "abc"}); ok {
+		t.Errorf("string id should not match")
+	}
+	if ok, _ := q.Evaluate(map[string]interface{}{"id": 3.0}); !ok {
+		t.Errorf("numeric id should match")
+	}
+	for _, bad := range []string{`typeof(id) > "number"`, `typeof(id) is 3`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseCoalesce(t *testing.T) {
+	for _, src := range []string{
+		`(Region ?? "unknown") is "unknown"`,
+		`Region ?? Country ?? "unknown" is "unknown"`,
+		`coalesce(Region, "unknown") is "unknown"`,
+	} {
+		q, err := Parse(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if ok, err := q.Evaluate(map[string]interface{}{}); err != nil || !ok {
+			t.Errorf("%s: missing field should default: %v %v", src, ok, err)
+		}
+		if ok, _ := q.Evaluate(map[string]interface{}{"Region": "EU"}); ok {
+			t.Errorf("%s: present field should be used", src)
+		}
+	}
+	q, err := Parse(`(Region ?? "unknown") is "EU" and Age > 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Stringify(q); got != `((Region ?? "unknown") is "EU" and Age > 3)` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if _, err := Parse(Stringify(q)); err != nil {
+		t.Errorf("stringified query does not parse: %v", err)
+	}
+}
+
+func TestParseBareBooleanField(t *testing.T) {
+	q, err := Parse(`Active and not Deleted and (Admin or Age > 30)`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `((Active is true and not Deleted is true) and (Admin is true or Age > 30))` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	tests := []struct {
+		rec  map[string]interface{}
+		want bool
+	}{
+		{map[string]interface{}{"Active": true, "Deleted": false, "Admin": true}, true},
+		{map[string]interface{}{"Active": true, "Deleted": true, "Admin": true}, false},
+		{map[string]interface{}{"Active": false, "Deleted": false, "Admin": true}, false},
+		{map[string]interface{}{"Active": true, "Deleted": false, "Admin": false, "Age": 40}, true},
+	}
+	for _, tt := range tests {
+		if ok, _ := q.Evaluate(tt.rec); ok != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.rec, ok, tt.want)
+		}
+	}
+	if _, ok := q.Expression.(*evaluator.AndExpression).Expressions[0].Expression.(*evaluator.AndExpression).Expressions[0].Expression.(*evaluator.IsExpression); !ok {
+		t.Errorf("bare field should desugar to IsExpression")
+	}
+	if _, err := Parse(`Active 3`); err == nil {
+		t.Errorf("expected error for a field followed by a value")
+	}
+	for in, want := range map[string]string{
+		`Trial xor Paid`:            `(Trial is true xor Paid is true)`,
+		`if Prod then Replicas > 2`: `(if Prod is true then Replicas > 2)`,
+	} {
+		q, err := Parse(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+		} else if got := Stringify(q); got != want {
+			t.Errorf("%s: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseIndexedField(t *testing.T) {
+	q, err := Parse(`Items[0].Price > 10 and Tags[1] is "b"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(Items[0].Price > 10 and Tags[1] is "b")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	rec := map[string]interface{}{
+		"Items": []interface{}{map[string]interface{}{"Price": 12.5}},
+		"Tags":  []interface{}{"a", "b"},
+	}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	if _, err := Parse(`Items[x] is 1`); err == nil {
+		t.Errorf("expected error for non-numeric index")
+	}
+}
+
+func TestParseSearchSyntax(t *testing.T) {
+	q, err := Parse(`Status:"open" Age>30 (Team:core or Team:infra) Active`, SearchSyntax)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(((Status is "open" and Age > 30) and (Team is "core" or Team is "infra")) and Active is true)` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	rec := map[string]interface{}{"Status": "open", "Age": 31, "Team": "infra", "Active": true}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	rec["Age"] = 20
+	if ok, _ := q.Evaluate(rec); ok {
+		t.Errorf("expected no match")
+	}
+	if _, err := Parse(`Status:"open" Age>30`); err == nil {
+		t.Errorf("strict grammar should reject search syntax")
+	}
+	if _, err := Parse(`Status:"open"`); err == nil {
+		t.Errorf("strict grammar should reject key:value")
+	}
+	if q, err := Parse(`Price > USD 3 Status:open`, SearchSyntax); err != nil {
+		t.Errorf("money followed by a predicate: %v", err)
+	} else if got := Stringify(q); !strings.HasSuffix(got, ` and Status is "open")`) {
+		t.Errorf("unexpected stringify %q", got)
+	}
+}
+
+func TestParseAny(t *testing.T) {
+	q, err := Parse(`Items any (Price > 100 and Name is "b") or Status is "open"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(Items any (Price > 100 and Name is "b") or Status is "open")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	rec := map[string]interface{}{"Items": []interface{}{
+		map[string]interface{}{"Name": "a", "Price": 150},
+		map[string]interface{}{"Name": "b", "Price": 150},
+	}}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	if _, err := Parse(`Items any Price > 100`); err == nil {
+		t.Errorf("expected error without parentheses")
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	q, err := Parse(`Items all (InStock) and Items any (Price > 100)`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(Items all (InStock is true) and Items any (Price > 100))` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	rec := map[string]interface{}{"Items": []interface{}{
+		map[string]interface{}{"InStock": true, "Price": 150},
+		map[string]interface{}{"InStock": true, "Price": 10},
+	}}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	rec["Items"].([]interface{})[1].(map[string]interface{})["InStock"] = false
+	if ok, _ := q.Evaluate(rec); ok {
+		t.Errorf("expected no match once an item is out of stock")
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	q, err := Parse(`count(Checks, Status is "fail" or Status is "error") >= 2`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `count(Checks, (Status is "fail" or Status is "error")) >= 2` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	rec := map[string]interface{}{"Checks": []interface{}{
+		map[string]interface{}{"Status": "fail"},
+		map[string]interface{}{"Status": "ok"},
+		map[string]interface{}{"Status": "error"},
+	}}
+	if ok, err := q.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	for _, bad := range []string{`count(Checks) > 1`, `count(Checks, Status is "x" > 1`, `count(Checks, Status is "x") contains 1`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseHasKey(t *testing.T) {
+	q, err := Parse(`address haskey "zip" and not address.zip`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(address haskey "zip" and not address.zip is true)` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if ok, err := q.Evaluate(map[string]interface{}{"address": map[string]interface{}{"zip": nil}}); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+}
+
+func TestParseContainsAllAny(t *testing.T) {
+	q, err := Parse(`Tags containsall ["go", "cli"] and Tags containsany []`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(Tags containsall ["go", "cli"] and Tags containsany [])` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	q, err = Parse(`Tags containsall ["go", "cli"] or Tags containsany ["rust", 1]`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for tags, want := range map[string]bool{"go cli": true, "rust": true, "go": false} {
+		rec := map[string]interface{}{"Tags": strings.Fields(tags)}
+		if ok, err := q.Evaluate(rec); err != nil || ok != want {
+			t.Errorf("%s: got %v, %v, want %v", tags, ok, err, want)
+		}
+	}
+	for _, bad := range []string{`Tags containsall "go"`, `Tags containsall ["go" "cli"]`, `Tags containsany ["go",`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseBeforeAfter(t *testing.T) {
+	q, err := Parse(`created after "2024-01-01T00:00:00Z" and created before "2024-02-01T00:00:00+10:00"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(created after "2024-01-01T00:00:00Z" and created before "2024-02-01T00:00:00+10:00")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if ok, err := q.Evaluate(map[string]interface{}{"created": "2024-01-15T08:30:00-05:00"}); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+}
+
+func TestParseWithin(t *testing.T) {
+	for in, want := range map[string]string{
+		`CreatedAt within 24h`:   `CreatedAt within 24h`,
+		`CreatedAt within 1h30m`: `CreatedAt within 1h30m`,
+		`CreatedAt within 1.5d`:  `CreatedAt within 1.5d`,
+		`CreatedAt within "-2h"`: `CreatedAt within "-2h"`,
+		`CreatedAt within "7d"`:  `CreatedAt within 7d`,
+	} {
+		q, err := Parse(in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got := Stringify(q); got != want {
+			t.Errorf("%s: got %q, want %q", in, got, want)
+		}
+	}
+	if _, err := Parse(`CreatedAt within 24 h`); err == nil {
+		t.Error("expected error for split duration")
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	q, err := Parse(`SrcIP in 10.0.0.0/8 or SrcIP in "fd00::/8"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(SrcIP in 10.0.0.0/8 or SrcIP in "fd00::/8")` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	for ip, want := range map[string]bool{"10.2.3.4": true, "fd00::2": true, "8.8.8.8": false} {
+		if ok, err := q.Evaluate(map[string]interface{}{"SrcIP": ip}); err != nil || ok != want {
+			t.Errorf("%s: got %v %v, want %v", ip, ok, err, want)
+		}
+	}
+	for _, bad := range []string{`SrcIP in 10.0.0.1`, `SrcIP in 10.0.0.0/40`, `SrcIP in 8`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseImplies(t *testing.T) {
+	q, err := Parse(`if Env is "prod" and Tier is 1 then Replicas >= 3 or Autoscale`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Stringify(q); got != `(if (Env is "prod" and Tier is 1) then (Replicas >= 3 or Autoscale is true))` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	if got := Stringify(q, MinimalParens); got != `if Env is "prod" and Tier is 1 then Replicas >= 3 or Autoscale is true` {
+		t.Errorf("unexpected minimal stringify %q", got)
+	}
+	for _, tt := range []struct {
+		rec  map[string]interface{}
+		want bool
+	}{
+		{map[string]interface{}{"Env": "dev", "Tier": 1, "Replicas": 1}, true},
+		{map[string]interface{}{"Env": "prod", "Tier": 1, "Replicas": 3}, true},
+		{map[string]interface{}{"Env": "prod", "Tier": 1, "Replicas": 1, "Autoscale": true}, true},
+		{map[string]interface{}{"Env": "prod", "Tier": 1, "Replicas": 1}, false},
+	} {
+		if ok, err := q.Evaluate(tt.rec); err != nil || ok != tt.want {
+			t.Errorf("%v: got %v %v, want %v", tt.rec, ok, err, tt.want)
+		}
+	}
+	nested, err := Parse(`Active and (if a is 1 then (if b is 2 then c is 3))`)
+	if err != nil {
+		t.Fatalf("parse nested: %v", err)
+	}
+	for _, opts := range [][]any{nil, {MinimalParens}} {
+		s := Stringify(nested, opts...)
+		back, err := Parse(s)
+		if err != nil {
+			t.Errorf("%v: reparse %q: %v", opts, s, err)
+			continue
+		}
+		if Stringify(back) != Stringify(nested) {
+			t.Errorf("%v: round trip of %q gave %q", opts, s, Stringify(back))
+		}
+	}
+	if _, err := Parse(`if a is 1 b is 2`); err == nil {
+		t.Error("expected error for missing then")
+	}
+}
+
+func TestParseCase(t *testing.T) {
+	q, err := Parse(`case when Env is "prod" then Replicas >= 3 and Backup when Canary then Replicas >= 1 else Replicas >= 2 end`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := `case when Env is "prod" then (Replicas >= 3 and Backup is true) when Canary is true then Replicas >= 1 else Replicas >= 2 end`
+	if got := Stringify(q); got != want {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	for _, tt := range []struct {
+		rec  map[string]interface{}
+		want bool
+	}{
+		{map[string]interface{}{"Env": "prod", "Replicas": 3, "Backup": true}, true},
+		{map[string]interface{}{"Env": "prod", "Replicas": 3}, false},
+		{map[string]interface{}{"Env": "dev", "Canary": true, "Replicas": 1}, true},
+		{map[string]interface{}{"Env": "dev", "Replicas": 1}, false},
+	} {
+		if ok, err := q.Evaluate(tt.rec); err != nil || ok != tt.want {
+			t.Errorf("%v: got %v %v, want %v", tt.rec, ok, err, tt.want)
+		}
+	}
+	for _, opts := range [][]any{{MinimalParens}} {
+		s := Stringify(q, opts...)
+		back, err := Parse(s)
+		if err != nil {
+			t.Errorf("%v: reparse %q: %v", opts, s, err)
+		} else if Stringify(back) != want {
+			t.Errorf("%v: round trip of %q gave %q", opts, s, Stringify(back))
+		}
+	}
+	// The case words are not reserved.
+	q, err = Parse(`end > 3 and case when when is 1 then else is 2 end`)
+	if err != nil {
+		t.Fatalf("parse fields named after case words: %v", err)
+	}
+	if ok, err := q.Evaluate(map[string]interface{}{"end": 4, "when": 1, "else": 2}); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	if q, err := Parse(`CASE WHEN a is 1 then b >= 2 END`); err != nil {
+		t.Errorf("a number before END is not money: %v", err)
+	} else if got := Stringify(q); got != `case when a is 1 then b >= 2 end` {
+		t.Errorf("unexpected stringify %q", got)
+	}
+	for _, bad := range []string{`case when a is 1 then b is 2`, `case when a is 1 b is 2 end`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseAggregates(t *testing.T) {
+	rec := map[string]interface{}{"Items": []interface{}{
+		map[string]interface{}{"Price": 300, "Qty": 1},
+		map[string]interface{}{"Price": 250, "Qty": 4},
+	}}
+	for e, want := range map[string]bool{
+		`sum(Items.Price) > 500`:  true,
+		`min(Items.Price) < 250`:  false,
+		`max(Items.Qty) >= 4`:     true,
+		`avg(Items.Price) is 275`: true,
+	} {
+		q, err := Parse(e)
+		if err != nil {
+			t.Fatalf("parse %q: %v", e, err)
+		}
+		if s := Stringify(q); s != e {
+			t.Errorf("expected %q, got %q", e, s)
+		}
+		got, err := q.Evaluate(rec)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v %v, want %v", e, got, err, want)
+		}
+	}
+	q, err := Parse(`max(Price, Limit) > 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := q.Expression.(*evaluator.ComparisonExpression); !ok {
+		t.Errorf("unexpected expression %T", q.Expression)
+	} else if _, ok := c.LHS.(evaluator.FunctionExpression); !ok {
+		t.Errorf("max with two arguments should call a function, got %T", c.LHS)
+	}
+}
@@ -0,0 +1,340 @@
+package simple
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// RedactFields is a Stringify option listing fields whose literal values are
+// replaced with evaluator.RedactedValue in the output.
+type RedactFields []string
+
+// Format changes how Stringify writes a query. Without options Stringify
+// wraps every and/or group in parentheses, quotes strings with " and spells
+// operators in lower case words.
+type Format int
+
+const (
+	// MinimalParens only adds the parentheses needed to keep the meaning,
+	// as in a and (b or c).
+	MinimalParens Format = iota + 1
+	// SingleQuotes quotes strings with ' instead of ".
+	SingleQuotes
+	// SymbolicEquality spells is and is not as == and !=.
+	SymbolicEquality
+)
+
+// Original is a Stringify option holding the text a query was parsed from.
+// When the query still parses to the same thing, Stringify returns the text
+// unchanged, keeping the author's spacing, quoting and parentheses.
+type Original string
+
+// precedence levels of the enclosing expression, loosest first.
+const (
+	precTop = iota
+	precOr
+	precXor
+	precAnd
+	precNot
+)
+
+// printer holds the Formats in effect while stringifying.
+type printer struct {
+	minimal  bool
+	quote    byte
+	symbolic bool
+}
+
+func newPrinter(opts []any) printer {
+	p := printer{quote: '"'}
+	for _, o := range opts {
+		switch o {
+		case MinimalParens:
+			p.minimal = true
+		case SingleQuotes:
+			p.quote = '\''
+		case SymbolicEquality:
+			p.symbolic = true
+		}
+	}
+	return p
+}
+
+// Stringify returns a canonical expression string from a Query. Format,
+// Original and RedactFields options change the output.
+func Stringify(q evaluator.Query, opts ...any) string {
+	var original *Original
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case RedactFields:
+			q = evaluator.Redact(q, o)
+		case Original:
+			original = &o
+		}
+	}
+	if q.Expression == nil {
+		return ""
+	}
+	if original != nil {
+		canonical := newPrinter(nil)
+		parsed, err := Parse(string(*original), SearchSyntax)
+		if s := canonical.expr(q.Expression, precTop); err == nil && s != "" && canonical.expr(parsed.Expression, precTop) == s {
+			return string(*original)
+		}
+	}
+	return newPrinter(opts).expr(q.Expression, precTop)
+}
+
+// group joins parts with op, adding parentheses when the group sits inside a
+// tighter binding operator or explicit parentheses are wanted.
+func (p printer) group(parts []string, op string, prec, parent int) string {
+	s := strings.Join(parts, " "+op+" ")
+	if !p.minimal || parent > prec {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (p printer) queries(qs []evaluator.Query, prec int) []string {
+	parts := make([]string, len(qs))
+	for i, q := range qs {
+		parts[i] = p.expr(q.Expression, prec)
+	}
+	return parts
+}
+
+func (p printer) expr(e evaluator.Expression, parent int) string {
+	switch ex := e.(type) {
+	case *evaluator.ContainsExpression:
+		return ex.Field + " contains " + p.value(ex.Value)
+	case *evaluator.ContainsAllExpression:
+		return ex.Field + " containsall " + p.list(ex.Values)
+	case *evaluator.ContainsAnyExpression:
+		return ex.Field + " containsany " + p.list(ex.Values)
+	case *evaluator.HasKeyExpression:
+		return ex.Field + " haskey " + p.value(ex.Key)
+	case *evaluator.BeforeExpression:
+		return ex.Field + " before " + p.value(ex.Value)
+	case *evaluator.AfterExpression:
+		return ex.Field + " after " + p.value(ex.Value)
+	case *evaluator.CIDRContainsExpression:
+		if strings.Contains(ex.CIDR, ":") {
+			return ex.Field + " in " + p.value(ex.CIDR)
+		}
+		return ex.Field + " in " + ex.CIDR
+	case *evaluator.WithinDurationExpression:
+		if strings.HasPrefix(ex.Duration, "-") {
+			return ex.Field + " within " + p.value(ex.Duration)
+		}
+		return ex.Field + " within " + ex.Duration
+	case *evaluator.StartsWithExpression:
+		return ex.Field + " startswith " + p.value(ex.Value)
+	case *evaluator.EndsWithExpression:
+		return ex.Field + " endswith " + p.value(ex.Value)
+	case *evaluator.GlobExpression:
+		return ex.Field + " glob " + p.value(ex.Pattern)
+	case *evaluator.ModExpression:
+		return fmt.Sprintf("%s %% %d %s %d", ex.Field, ex.Divisor, p.spell(tokenIs), ex.Remainder)
+	case *evaluator.SoundsLikeExpression:
+		return ex.Field + " soundslike " + p.value(ex.Value)
+	case *evaluator.BetweenExpression:
+		if !ex.Inclusive {
+			return "(" + ex.Field + " > " + p.value(ex.Low) + " and " + ex.Field + " < " + p.value(ex.High) + ")"
+		}
+		return ex.Field + " between " + p.value(ex.Low) + " and " + p.value(ex.High)
+	case *evaluator.IsExpression:
+		return ex.Field + " " + p.spell(tokenIs) + " " + p.value(ex.Value)
+	case *evaluator.IsNotExpression:
+		return ex.Field + " " + p.spell(tokenIsNot) + " " + p.value(ex.Value)
+	case *evaluator.GreaterThanExpression:
+		return ex.Field + " > " + p.value(ex.Value)
+	case *evaluator.GreaterThanOrEqualExpression:
+		return ex.Field + " >= " + p.value(ex.Value)
+	case *evaluator.LessThanExpression:
+		return ex.Field + " < " + p.value(ex.Value)
+	case *evaluator.LessThanOrEqualExpression:
+		return ex.Field + " <= " + p.value(ex.Value)
+	case *evaluator.AndExpression:
+		return p.group(p.queries(ex.Expressions, precAnd), "and", precAnd, parent)
+	case *evaluator.OrExpression:
+		return p.group(p.queries(ex.Expressions, precOr), "or", precOr, parent)
+	case *evaluator.XorExpression:
+		// A nested xor is parenthesised even with MinimalParens, as
+		// flattening it into the parent would change its meaning.
+		return p.group(p.queries(ex.Expressions, precXor+1), "xor", precXor, parent)
+	case *evaluator.ImpliesExpression:
+		s := "if " + p.expr(ex.If.Expression, precOr) + " then " + p.expr(ex.Then.Expression, precOr)
+		if !p.minimal || parent > precTop {
+			return "(" + s + ")"
+		}
+		return s
+	case *evaluator.CaseExpression:
+		if len(ex.Cases) == 0 {
+			return p.expr(ex.Else.Expression, parent)
+		}
+		var b strings.Builder
+		b.WriteString("case")
+		for _, c := range ex.Cases {
+			b.WriteString(" when " + p.expr(c.When.Expression, precOr) + " then " + p.expr(c.Then.Expression, precOr))
+		}
+		if ex.Else.Expression != nil {
+			b.WriteString(" else " + p.expr(ex.Else.Expression, precOr))
+		}
+		b.WriteString(" end")
+		return b.String()
+	case *evaluator.NotExpression:
+		return "not " + p.expr(ex.Expression.Expression, precNot)
+	case *evaluator.AnyExpression:
+		return ex.Field + " any " + p.parenthesised(ex.Query.Expression)
+	case *evaluator.AllExpression:
+		return ex.Field + " all " + p.parenthesised(ex.Query.Expression)
+	case *evaluator.IsFormatExpression:
+		return ex.Field + " " + p.spell(tokenIs) + " format " + p.value(ex.Format)
+	case *evaluator.TypeOfExpression:
+		return "typeof(" + ex.Field + ") is " + p.value(ex.Kind)
+	case *evaluator.LengthExpression:
+		for tok, op := range termOperations {
+			if op == ex.Op {
+				return "len(" + ex.Field + ") " + p.spell(tok) + " " + p.value(ex.Value)
+			}
+		}
+		return ""
+	case *evaluator.CountExpression:
+		for tok, op := range termOperations {
+			if op == ex.Op {
+				return "count(" + ex.Field + ", " + p.expr(ex.Query.Expression, precTop) + ") " + p.spell(tok) + " " + p.value(ex.Value)
+			}
+		}
+		return ""
+	case *evaluator.ComparisonExpression:
+		rhs := p.term(ex.RHS)
+		if f, ok := ex.RHS.(evaluator.Field); ok {
+			rhs = "@" + f.Name
+		}
+		for tok, op := range termOperations {
+			if op == ex.Operation {
+				return p.term(ex.LHS) + " " + p.spell(tok) + " " + rhs
+			}
+		}
+		return ""
+	case *evaluator.FieldCompareExpression:
+		for tok, op := range termOperations {
+			if op == ex.Op && tok != tokenContains {
+				return ex.FieldA + " " + p.spell(tok) + " @" + ex.FieldB
+			}
+		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+// parenthesised stringifies e wrapped in a single pair of parentheses.
+func (p printer) parenthesised(e evaluator.Expression) string {
+	s := p.expr(e, precTop)
+	switch e.(type) {
+	case *evaluator.AndExpression, *evaluator.OrExpression, *evaluator.XorExpression, *evaluator.ImpliesExpression:
+		if !p.minimal {
+			return s
+		}
+	}
+	return "(" + s + ")"
+}
+
+// tokenSpelling is the canonical source form of comparison tokens.
+var tokenSpelling = map[tokenType]string{
+	tokenIs:       "is",
+	tokenIsNot:    "is not",
+	tokenGT:       ">",
+	tokenGTE:      ">=",
+	tokenLT:       "<",
+	tokenLTE:      "<=",
+	tokenContains: "contains",
+}
+
+func (p printer) spell(tok tokenType) string {
+	if p.symbolic {
+		switch tok {
+		case tokenIs:
+			return "=="
+		case tokenIsNot:
+			return "!="
+		}
+	}
+	return tokenSpelling[tok]
+}
+
+func (p printer) term(t evaluator.Term) string {
+	switch tm := t.(type) {
+	case evaluator.Field:
+		return tm.Name
+	case evaluator.Constant:
+		return p.value(tm.Value)
+	case evaluator.BucketTerm:
+		return fmt.Sprintf("bucket(%s, %d)", tm.KeyField, tm.Buckets)
+	case evaluator.RandomTerm:
+		return "random()"
+	case evaluator.HashTerm:
+		return "hash(" + p.term(tm.Term) + ")"
+	case evaluator.ModTerm:
+		return p.term(tm.LHS) + " % " + p.term(tm.RHS)
+	case evaluator.CoalesceTerm:
+		parts := make([]string, len(tm.Terms))
+		for i, a := range tm.Terms {
+			parts[i] = p.term(a)
+		}
+		return "(" + strings.Join(parts, " ?? ") + ")"
+	case evaluator.SumTerm:
+		return "sum(" + tm.Field + ")"
+	case evaluator.MinTerm:
+		return "min(" + tm.Field + ")"
+	case evaluator.MaxTerm:
+		return "max(" + tm.Field + ")"
+	case evaluator.AvgTerm:
+		return "avg(" + tm.Field + ")"
+	case evaluator.FunctionExpression:
+		args := make([]string, len(tm.Args))
+		for i, a := range tm.Args {
+			args[i] = p.term(a)
+		}
+		return tm.Name + "(" + strings.Join(args, ", ") + ")"
+	default:
+		return ""
+	}
+}
+
+func (p printer) list(vs []interface{}) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		parts[i] = p.value(v)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// value writes a literal. Strings containing the preferred quote use the
+// other one so they still lex, and strings containing both double the
+// preferred quote.
+func (p printer) value(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	q, other := p.quote, byte('"')
+	if q == '"' {
+		other = '\''
+	}
+	if strings.IndexByte(s, q) >= 0 {
+		if strings.IndexByte(s, other) < 0 {
+			q = other
+		} else {
+			s = strings.ReplaceAll(s, string(q), string(q)+string(q))
+		}
+	}
+	return string(q) + s + string(q)
+}
+
+func valToString(v interface{}) string {
+	return printer{quote: '"'}.value(v)
+}
@@ -0,0 +1,149 @@
+package simple
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+)
+
+func TestStringifyFormats(t *testing.T) {
+	q, err := Parse(`a is "x" and (b is 1 or not (c > 2 and d is "it's"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		opts []any
+		want string
+	}{
+		{nil, `(a is "x" and (b is 1 or not (c > 2 and d is "it's")))`},
+		{[]any{MinimalParens}, `a is "x" and (b is 1 or not (c > 2 and d is "it's"))`},
+		{[]any{SingleQuotes}, `(a is 'x' and (b is 1 or not (c > 2 and d is "it's")))`},
+		{[]any{MinimalParens, SymbolicEquality}, `a == "x" and (b == 1 or not (c > 2 and d == "it's"))`},
+	}
+	for _, tt := range tests {
+		got := Stringify(q, tt.opts...)
+		if got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.opts, got, tt.want)
+			continue
+		}
+		back, err := Parse(got)
+		if err != nil {
+			t.Errorf("%v: reparse %q: %v", tt.opts, got, err)
+			continue
+		}
+		if Stringify(back) != Stringify(q) {
+			t.Errorf("%v: round trip changed the query to %q", tt.opts, Stringify(back))
+		}
+	}
+}
+
+func TestStringifyBothQuotes(t *testing.T) {
+	const v = `it's a "test" \d`
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "a", Value: v}}
+	for _, opts := range [][]any{nil, {SingleQuotes}} {
+		s := Stringify(q, opts...)
+		back, err := Parse(s)
+		if err != nil {
+			t.Fatalf("%v: reparse %q: %v", opts, s, err)
+		}
+		if got := back.Expression.(*evaluator.IsExpression).Value; got != v {
+			t.Errorf("%v: %q reparsed as %q", opts, s, got)
+		}
+	}
+	if got := Stringify(q); got != `a is "it's a ""test"" \d"` {
+		t.Errorf("got %q", got)
+	}
+	if _, err := Parse(`a is "x""`); err == nil {
+		t.Errorf("expected an unterminated string error")
+	}
+}
+
+func TestStringifyMinimalParensQuantifier(t *testing.T) {
+	q, err := Parse(`Items any (Price > 1 and Name is "x") or Tags all (Active)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Items any (Price > 1 and Name is "x") or Tags all (Active is true)`
+	if got := Stringify(q, MinimalParens); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStringifyOriginal(t *testing.T) {
+	src := `Name  is 'bob'   and (Age>30)`
+	q, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Stringify(q, Original(src)); got != src {
+		t.Errorf("got %q, want the original text", got)
+	}
+	q.Expression.(*evaluator.AndExpression).Expressions[1].Expression.(*evaluator.GreaterThanExpression).Value = 40
+	if got := Stringify(q, Original(src), MinimalParens); got != `Name is "bob" and Age > 40` {
+		t.Errorf("edited query: got %q", got)
+	}
+	q, _ = Parse(src)
+	if got := Stringify(q, Original(src), RedactFields{"Name"}); got == src {
+		t.Errorf("redacted output must not return the original text")
+	}
+}
+
+func TestStringifyXor(t *testing.T) {
+	q, err := Parse(`a is 1 xor b is 2 and c is 3 xor d is 4 or (e is 5 xor f is 6) xor g is 7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		opts []any
+		want string
+	}{
+		{nil, `((a is 1 xor (b is 2 and c is 3) xor d is 4) or ((e is 5 xor f is 6) xor g is 7))`},
+		{[]any{MinimalParens}, `a is 1 xor b is 2 and c is 3 xor d is 4 or (e is 5 xor f is 6) xor g is 7`},
+	}
+	for _, tt := range tests {
+		got := Stringify(q, tt.opts...)
+		if got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.opts, got, tt.want)
+			continue
+		}
+		back, err := Parse(got)
+		if err != nil {
+			t.Errorf("%v: reparse %q: %v", tt.opts, got, err)
+			continue
+		}
+		if Stringify(back) != Stringify(q) {
+			t.Errorf("%v: round trip changed the query to %q", tt.opts, Stringify(back))
+		}
+	}
+	rec := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+	if ok, err := q.Evaluate(rec); err != nil || ok {
+		t.Errorf("a and (b and c) both matched, expected no match: %v %v", ok, err)
+	}
+}
+
+func TestStringifyFieldCompare(t *testing.T) {
+	q := evaluator.Query{Expression: &evaluator.OrExpression{Expressions: []evaluator.Query{
+		{Expression: &evaluator.FieldCompareExpression{FieldA: "A", Op: "eq", FieldB: "B"}},
+		{Expression: &evaluator.FieldCompareExpression{FieldA: "A", Op: "lte", FieldB: "C.D"}},
+	}}}
+	for _, tt := range []struct {
+		opts []any
+		want string
+	}{
+		{nil, `(A is @B or A <= @C.D)`},
+		{[]any{SymbolicEquality}, `(A == @B or A <= @C.D)`},
+	} {
+		got := Stringify(q, tt.opts...)
+		if got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.opts, got, tt.want)
+			continue
+		}
+		back, err := Parse(got)
+		if err != nil {
+			t.Errorf("%v: reparse %q: %v", tt.opts, got, err)
+		} else if !reflect.DeepEqual(back, q) {
+			t.Errorf("%v: round trip changed the query to %q", tt.opts, Stringify(back))
+		}
+	}
+}
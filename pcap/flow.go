@@ -0,0 +1,107 @@
+package pcap
+
+import "time"
+
+// Flows aggregates packets into flow records, one for each direction of a
+// conversation, so that queries can select whole flows such as
+// Bytes > 1000000 and DstIP in 10.0.0.0/8. A record has the Version, SrcIP,
+// DstIP, Proto, SrcPort, DstPort and VLAN of its packets together with:
+//
+//   - Packets: the number of packets
+//   - Bytes and Payload: the totals of the packet fields
+//   - Start and End: the times of the first and last packet
+//   - Duration: the seconds between them as a float64
+//   - Flags: every TCP flag seen in the flow
+//
+// Packets that are not IP are ignored. The zero value is ready to use.
+type Flows struct {
+	flows map[flowKey]Packet
+	order []flowKey
+}
+
+type flowKey struct {
+	src, dst, proto string
+	sport, dport    int64
+	vlan            int64
+}
+
+// Add counts p towards its flow.
+func (f *Flows) Add(p Packet) {
+	src, _ := p["SrcIP"].(string)
+	if src == "" {
+		return
+	}
+	k := flowKey{src: src}
+	k.dst, _ = p["DstIP"].(string)
+	k.proto, _ = p["Proto"].(string)
+	k.sport, _ = p["SrcPort"].(int64)
+	k.dport, _ = p["DstPort"].(int64)
+	k.vlan, _ = p["VLAN"].(int64)
+	t, _ := p["Time"].(time.Time)
+	fl, ok := f.flows[k]
+	if !ok {
+		if f.flows == nil {
+			f.flows = map[flowKey]Packet{}
+		}
+		fl = Packet{"Packets": int64(0), "Bytes": int64(0), "Start": t, "End": t, "Duration": 0.0}
+		for _, name := range []string{"Version", "SrcIP", "DstIP", "Proto", "SrcPort", "DstPort", "VLAN"} {
+			if v, ok := p[name]; ok {
+				fl[name] = v
+			}
+		}
+		f.flows[k] = fl
+		f.order = append(f.order, k)
+	}
+	fl["Packets"] = fl["Packets"].(int64) + 1
+	n, _ := p["Bytes"].(int64)
+	fl["Bytes"] = fl["Bytes"].(int64) + n
+	if n, ok := p["Payload"].(int64); ok {
+		total, _ := fl["Payload"].(int64)
+		fl["Payload"] = total + n
+	}
+	if t.Before(fl["Start"].(time.Time)) {
+		fl["Start"] = t
+	}
+	if t.After(fl["End"].(time.Time)) {
+		fl["End"] = t
+	}
+	fl["Duration"] = fl["End"].(time.Time).Sub(fl["Start"].(time.Time)).Seconds()
+	if flags, ok := p["Flags"].([]interface{}); ok {
+		seen, _ := fl["Flags"].([]interface{})
+		fl["Flags"] = mergeFlags(seen, flags)
+	}
+}
+
+// Len returns the number of flows.
+func (f *Flows) Len() int {
+	return len(f.order)
+}
+
+// Each calls fn with each flow in the order their first packets were added.
+// An error from fn stops the iteration and is returned.
+func (f *Flows) Each(fn func(Packet) error) error {
+	for _, k := range f.order {
+		if err := fn(f.flows[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFlags returns the flags in either list, in the order of tcpFlags.
+func mergeFlags(a, b []interface{}) []interface{} {
+	set := map[interface{}]bool{}
+	for _, v := range a {
+		set[v] = true
+	}
+	for _, v := range b {
+		set[v] = true
+	}
+	merged := []interface{}{}
+	for _, f := range tcpFlags {
+		if set[f.name] {
+			merged = append(merged, f.name)
+		}
+	}
+	return merged
+}
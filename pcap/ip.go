@@ -0,0 +1,173 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+)
+
+var protoNames = map[uint8]string{
+	1:   "icmp",
+	6:   "tcp",
+	17:  "udp",
+	47:  "gre",
+	50:  "esp",
+	51:  "ah",
+	58:  "icmpv6",
+	132: "sctp",
+}
+
+var tcpFlags = []struct {
+	bit  uint8
+	name string
+}{
+	{0x01, "FIN"},
+	{0x02, "SYN"},
+	{0x04, "RST"},
+	{0x08, "PSH"},
+	{0x10, "ACK"},
+	{0x20, "URG"},
+	{0x40, "ECE"},
+	{0x80, "CWR"},
+}
+
+// decodeIP decodes an IPv4 or IPv6 packet by its version.
+func decodeIP(p Packet, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	switch b[0] >> 4 {
+	case 4:
+		decodeIPv4(p, b)
+	case 6:
+		decodeIPv6(p, b)
+	}
+}
+
+func decodeIPv4(p Packet, b []byte) {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return
+	}
+	hl := int(b[0]&0x0f) * 4
+	if hl < 20 || len(b) < hl {
+		return
+	}
+	total := int(binary.BigEndian.Uint16(b[2:]))
+	p["Version"] = int64(4)
+	p["TTL"] = int64(b[8])
+	p["SrcIP"] = netip.AddrFrom4([4]byte(b[12:16])).String()
+	p["DstIP"] = netip.AddrFrom4([4]byte(b[16:20])).String()
+	proto := b[9]
+	setProto(p, proto)
+	// Only the first fragment carries the transport header.
+	if binary.BigEndian.Uint16(b[6:])&0x1fff != 0 {
+		return
+	}
+	decodeTransport(p, proto, b[hl:], total-hl)
+}
+
+// decodeIPv6 skips the extension headers that precede the transport header.
+func decodeIPv6(p Packet, b []byte) {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return
+	}
+	length := int(binary.BigEndian.Uint16(b[4:]))
+	p["Version"] = int64(6)
+	p["TTL"] = int64(b[7])
+	p["SrcIP"] = netip.AddrFrom16([16]byte(b[8:24])).String()
+	p["DstIP"] = netip.AddrFrom16([16]byte(b[24:40])).String()
+	next, b := b[6], b[40:]
+	for {
+		switch next {
+		case 0, 43, 60:
+			if len(b) < 8 {
+				setProto(p, next)
+				return
+			}
+			n := (int(b[1]) + 1) * 8
+			if len(b) < n {
+				setProto(p, next)
+				return
+			}
+			next, b, length = b[0], b[n:], length-n
+			continue
+		case 44:
+			if len(b) < 8 {
+				setProto(p, next)
+				return
+			}
+			first := binary.BigEndian.Uint16(b[2:])&0xfff8 == 0
+			next, b, length = b[0], b[8:], length-8
+			setProto(p, next)
+			if first {
+				decodeTransport(p, next, b, length)
+			}
+			return
+		}
+		setProto(p, next)
+		decodeTransport(p, next, b, length)
+		return
+	}
+}
+
+func setProto(p Packet, proto uint8) {
+	if name, ok := protoNames[proto]; ok {
+		p["Proto"] = name
+	} else {
+		p["Proto"] = strconv.Itoa(int(proto))
+	}
+}
+
+// decodeTransport reads the transport header in b. length is the size of the
+// transport segment according to the IP header, which is used for Payload so
+// that captures truncated by a snap length still count the bytes sent.
+func decodeTransport(p Packet, proto uint8, b []byte, length int) {
+	hl := 0
+	switch proto {
+	case 6:
+		if len(b) < 20 {
+			return
+		}
+		hl = int(b[12]>>4) * 4
+		var flags []interface{}
+		for _, f := range tcpFlags {
+			if b[13]&f.bit != 0 {
+				flags = append(flags, f.name)
+			}
+		}
+		if flags == nil {
+			flags = []interface{}{}
+		}
+		p["Flags"] = flags
+		setPorts(p, b)
+	case 17:
+		if len(b) < 8 {
+			return
+		}
+		hl = 8
+		setPorts(p, b)
+	case 132:
+		if len(b) < 12 {
+			return
+		}
+		hl = 12
+		setPorts(p, b)
+	case 1, 58:
+		if len(b) < 4 {
+			return
+		}
+		hl = 8
+		p["ICMPType"] = int64(b[0])
+		p["ICMPCode"] = int64(b[1])
+	default:
+		return
+	}
+	if n := length - hl; n >= 0 {
+		p["Payload"] = int64(n)
+	}
+}
+
+func setPorts(p Packet, b []byte) {
+	p["SrcPort"] = int64(binary.BigEndian.Uint16(b[0:]))
+	p["DstPort"] = int64(binary.BigEndian.Uint16(b[2:]))
+}
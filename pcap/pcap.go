@@ -0,0 +1,174 @@
+// Package pcap decodes packet captures into records that queries can filter,
+// for rules that BPF filters cannot express, such as address ranges combined
+// with byte counts or conditions over whole flows.
+//
+// Captures are read in the classic libpcap format written by tcpdump -w, with
+// Ethernet, raw IP, loopback and Linux cooked link types. pcapng files can be
+// converted with editcap -F pcap.
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Packet is a decoded packet keyed by field name:
+//
+//   - Time: the capture time as a time.Time
+//   - Bytes: the length of the frame on the wire
+//   - Version: the IP version, 4 or 6
+//   - SrcIP and DstIP: the addresses, such as 10.0.0.7 or fd00::1
+//   - Proto: tcp, udp, icmp, icmpv6, sctp, gre, esp or ah, otherwise the
+//     protocol number as a string
+//   - TTL: the time to live or hop limit
+//   - SrcPort and DstPort: the ports of TCP, UDP and SCTP packets
+//   - Flags: the TCP flags that are set, such as SYN and ACK
+//   - Payload: the number of bytes after the transport header
+//   - ICMPType and ICMPCode: the ICMP message type and code
+//   - VLAN: the 802.1Q VLAN ID
+//   - EtherType: the EtherType of frames that are not IP
+//
+// Numbers are int64. Fields the packet does not carry, for example the ports
+// of a fragment after the first, are left out.
+type Packet map[string]interface{}
+
+// Link types from https://www.tcpdump.org/linktypes.html.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLoop     = 108
+	linkSLL      = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+// maxRecord bounds the memory a corrupt record length can claim.
+const maxRecord = 1 << 24
+
+// Decode reads a capture, calling fn with each packet in turn. An error from
+// fn stops decoding and is returned. Frames too short to decode still produce
+// a Packet with the fields that could be read.
+func Decode(r io.Reader, fn func(Packet) error) error {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("pcap: read header: %w", err)
+	}
+	var order binary.ByteOrder
+	nano := false
+	switch m := binary.LittleEndian.Uint32(hdr[:4]); m {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		order, nano = binary.LittleEndian, m == 0xa1b23c4d
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		order, nano = binary.BigEndian, m == 0x4d3cb2a1
+	case 0x0a0d0d0a:
+		return errors.New("pcap: pcapng is not supported, convert with editcap -F pcap")
+	default:
+		return fmt.Errorf("pcap: not a capture file (magic %#08x)", m)
+	}
+	link := order.Uint32(hdr[20:]) & 0xffff
+	switch link {
+	case linkNull, linkEthernet, linkRaw, linkLoop, linkSLL, linkIPv4, linkIPv6, linkSLL2:
+	default:
+		return fmt.Errorf("pcap: unsupported link type %d", link)
+	}
+	var rec [16]byte
+	var buf []byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("pcap: read record: %w", err)
+		}
+		sec, frac := order.Uint32(rec[0:]), order.Uint32(rec[4:])
+		incl, orig := order.Uint32(rec[8:]), order.Uint32(rec[12:])
+		if incl > maxRecord {
+			return fmt.Errorf("pcap: record of %d bytes is too large", incl)
+		}
+		if cap(buf) < int(incl) {
+			buf = make([]byte, incl)
+		}
+		buf = buf[:incl]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("pcap: read record: %w", err)
+		}
+		if !nano {
+			frac *= 1000
+		}
+		p := Packet{
+			"Time":  time.Unix(int64(sec), int64(frac)).UTC(),
+			"Bytes": int64(orig),
+		}
+		decodeLink(p, link, buf)
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeLink(p Packet, link uint32, b []byte) {
+	switch link {
+	case linkNull, linkLoop:
+		if len(b) < 4 {
+			return
+		}
+		// The address family is in host order for DLT_NULL and network order
+		// for DLT_LOOP; either way it is in the first or the last byte.
+		family := uint32(b[0]) | uint32(b[3])
+		switch family {
+		case 2:
+			decodeIPv4(p, b[4:])
+		case 24, 28, 30:
+			decodeIPv6(p, b[4:])
+		}
+	case linkEthernet:
+		if len(b) < 14 {
+			return
+		}
+		decodeEther(p, binary.BigEndian.Uint16(b[12:]), b[14:])
+	case linkRaw:
+		decodeIP(p, b)
+	case linkIPv4:
+		decodeIPv4(p, b)
+	case linkIPv6:
+		decodeIPv6(p, b)
+	case linkSLL:
+		if len(b) < 16 {
+			return
+		}
+		decodeEther(p, binary.BigEndian.Uint16(b[14:]), b[16:])
+	case linkSLL2:
+		if len(b) < 20 {
+			return
+		}
+		decodeEther(p, binary.BigEndian.Uint16(b[0:]), b[20:])
+	}
+}
+
+func decodeEther(p Packet, etherType uint16, b []byte) {
+	for etherType == 0x8100 || etherType == 0x88a8 {
+		if len(b) < 4 {
+			return
+		}
+		if _, ok := p["VLAN"]; !ok {
+			p["VLAN"] = int64(binary.BigEndian.Uint16(b) & 0x0fff)
+		}
+		etherType, b = binary.BigEndian.Uint16(b[2:]), b[4:]
+	}
+	switch etherType {
+	case 0x0800:
+		decodeIPv4(p, b)
+	case 0x86dd:
+		decodeIPv6(p, b)
+	default:
+		p["EtherType"] = int64(etherType)
+	}
+}
@@ -0,0 +1,256 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+// capture builds a pcap file with the given byte order, link type and frames,
+// one second apart from t0.
+func capture(order binary.ByteOrder, nano bool, link uint32, frames ...[]byte) []byte {
+	var b bytes.Buffer
+	magic := uint32(0xa1b2c3d4)
+	if nano {
+		magic = 0xa1b23c4d
+	}
+	hdr := make([]byte, 24)
+	order.PutUint32(hdr[0:], magic)
+	order.PutUint16(hdr[4:], 2)
+	order.PutUint16(hdr[6:], 4)
+	order.PutUint32(hdr[16:], 65535)
+	order.PutUint32(hdr[20:], link)
+	b.Write(hdr)
+	for i, f := range frames {
+		rec := make([]byte, 16)
+		order.PutUint32(rec[0:], uint32(t0.Unix())+uint32(i))
+		order.PutUint32(rec[4:], 500)
+		order.PutUint32(rec[8:], uint32(len(f)))
+		order.PutUint32(rec[12:], uint32(len(f)))
+		b.Write(rec)
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+var t0 = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func ether(etherType uint16, payload []byte) []byte {
+	f := make([]byte, 14, 14+len(payload))
+	binary.BigEndian.PutUint16(f[12:], etherType)
+	return append(f, payload...)
+}
+
+func ipv4(src, dst [4]byte, proto uint8, payload []byte) []byte {
+	h := make([]byte, 20, 20+len(payload))
+	h[0] = 0x45
+	binary.BigEndian.PutUint16(h[2:], uint16(20+len(payload)))
+	h[8] = 64
+	h[9] = proto
+	copy(h[12:], src[:])
+	copy(h[16:], dst[:])
+	return append(h, payload...)
+}
+
+func tcp(sport, dport uint16, flags uint8, payload string) []byte {
+	h := make([]byte, 20)
+	binary.BigEndian.PutUint16(h[0:], sport)
+	binary.BigEndian.PutUint16(h[2:], dport)
+	h[12] = 5 << 4
+	h[13] = flags
+	return append(h, payload...)
+}
+
+func udp(sport, dport uint16, payload string) []byte {
+	h := make([]byte, 8)
+	binary.BigEndian.PutUint16(h[0:], sport)
+	binary.BigEndian.PutUint16(h[2:], dport)
+	binary.BigEndian.PutUint16(h[4:], uint16(8+len(payload)))
+	return append(h, payload...)
+}
+
+func decodeAll(t *testing.T, data []byte) []Packet {
+	t.Helper()
+	var got []Packet
+	if err := Decode(bytes.NewReader(data), func(p Packet) error {
+		got = append(got, p)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestDecodeEthernet(t *testing.T) {
+	vlan := []byte{0x00, 0x2a, 0x08, 0x00}
+	ip6 := make([]byte, 40)
+	ip6[0] = 0x60
+	ip6[6] = 0 // hop-by-hop options
+	ip6[7] = 255
+	ip6[23] = 1
+	ip6[8] = 0xfd
+	ip6[39] = 2
+	ip6[24] = 0xfd
+	hop := []byte{17, 0, 0, 0, 0, 0, 0, 0}
+	u := udp(5353, 53, "query")
+	binary.BigEndian.PutUint16(ip6[4:], uint16(len(hop)+len(u)))
+	data := capture(binary.LittleEndian, false, linkEthernet,
+		ether(0x8100, append(vlan, ipv4([4]byte{10, 0, 0, 7}, [4]byte{93, 184, 216, 34}, 6, tcp(51000, 443, 0x12, "hello"))...)),
+		ether(0x86dd, append(append(ip6, hop...), u...)),
+		ether(0x0800, ipv4([4]byte{192, 168, 1, 1}, [4]byte{192, 168, 1, 2}, 1, []byte{8, 0, 0, 0, 0, 0, 0, 0})),
+		ether(0x0806, make([]byte, 28)),
+	)
+	got := decodeAll(t, data)
+	want := []Packet{
+		{
+			"Time": t0.Add(500 * time.Microsecond), "Bytes": int64(63), "VLAN": int64(42),
+			"Version": int64(4), "TTL": int64(64), "SrcIP": "10.0.0.7", "DstIP": "93.184.216.34",
+			"Proto": "tcp", "SrcPort": int64(51000), "DstPort": int64(443),
+			"Flags": []interface{}{"SYN", "ACK"}, "Payload": int64(5),
+		},
+		{
+			"Time": t0.Add(time.Second + 500*time.Microsecond), "Bytes": int64(75),
+			"Version": int64(6), "TTL": int64(255), "SrcIP": "fd00::1", "DstIP": "fd00::2",
+			"Proto": "udp", "SrcPort": int64(5353), "DstPort": int64(53), "Payload": int64(5),
+		},
+		{
+			"Time": t0.Add(2*time.Second + 500*time.Microsecond), "Bytes": int64(42),
+			"Version": int64(4), "TTL": int64(64), "SrcIP": "192.168.1.1", "DstIP": "192.168.1.2",
+			"Proto": "icmp", "ICMPType": int64(8), "ICMPCode": int64(0), "Payload": int64(0),
+		},
+		{
+			"Time": t0.Add(3*time.Second + 500*time.Microsecond), "Bytes": int64(42), "EtherType": int64(0x0806),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDecodeLinkTypes(t *testing.T) {
+	ip := ipv4([4]byte{10, 1, 2, 3}, [4]byte{10, 3, 2, 1}, 17, udp(1000, 2000, ""))
+	sll := append(make([]byte, 16), ip...)
+	binary.BigEndian.PutUint16(sll[14:], 0x0800)
+	loop := append([]byte{2, 0, 0, 0}, ip...)
+	for name, data := range map[string][]byte{
+		"raw big-endian nanosecond": capture(binary.BigEndian, true, linkRaw, ip),
+		"ipv4":                      capture(binary.LittleEndian, false, linkIPv4, ip),
+		"linux cooked":              capture(binary.LittleEndian, false, linkSLL, sll),
+		"loopback":                  capture(binary.LittleEndian, false, linkNull, loop),
+	} {
+		got := decodeAll(t, data)
+		if len(got) != 1 || got[0]["SrcIP"] != "10.1.2.3" || got[0]["DstPort"] != int64(2000) {
+			t.Errorf("%s: got %v", name, got)
+		}
+	}
+	got := decodeAll(t, capture(binary.BigEndian, true, linkRaw, ip))
+	if want := t0.Add(500 * time.Nanosecond); got[0]["Time"] != want {
+		t.Errorf("nanosecond time %v, want %v", got[0]["Time"], want)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	ip := ipv4([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, 6, tcp(1, 2, 0x02, ""))
+	got := decodeAll(t, capture(binary.LittleEndian, false, linkRaw, ip[:30]))
+	want := Packet{
+		"Time": t0.Add(500 * time.Microsecond), "Bytes": int64(30), "Version": int64(4), "TTL": int64(64),
+		"SrcIP": "10.0.0.1", "DstIP": "10.0.0.2", "Proto": "tcp",
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	ip := ipv4([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, 17, udp(1, 2, ""))
+	data := capture(binary.LittleEndian, false, linkRaw, ip, ip)
+	stop := errors.New("stop")
+	if err := Decode(bytes.NewReader(data), func(Packet) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+	if err := Decode(bytes.NewReader(data[:len(data)-3]), func(Packet) error { return nil }); err == nil {
+		t.Error("expected an error for a truncated record")
+	}
+	if err := Decode(bytes.NewReader(nil), func(Packet) error { return nil }); err != nil {
+		t.Errorf("empty input: %v", err)
+	}
+	for name, data := range map[string][]byte{
+		"pcapng":    {0x0a, 0x0d, 0x0d, 0x0a, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		"text":      []byte(strings.Repeat("not a capture ", 2)),
+		"link type": capture(binary.LittleEndian, false, 147),
+	} {
+		if err := Decode(bytes.NewReader(data), func(Packet) error { return nil }); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestFlows(t *testing.T) {
+	client, server := [4]byte{10, 0, 0, 7}, [4]byte{93, 184, 216, 34}
+	data := capture(binary.LittleEndian, false, linkRaw,
+		ipv4(client, server, 6, tcp(51000, 443, 0x02, "")),
+		ipv4(server, client, 6, tcp(443, 51000, 0x12, "")),
+		ipv4(client, server, 6, tcp(51000, 443, 0x18, "GET / HTTP/1.1")),
+		ipv4(client, server, 6, tcp(51000, 443, 0x11, "")),
+	)
+	var f Flows
+	for _, p := range decodeAll(t, data) {
+		f.Add(p)
+	}
+	f.Add(Packet{"Bytes": int64(60), "EtherType": int64(0x0806)})
+	var got []Packet
+	if err := f.Each(func(p Packet) error {
+		got = append(got, p)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []Packet{
+		{
+			"Version": int64(4), "SrcIP": "10.0.0.7", "DstIP": "93.184.216.34", "Proto": "tcp",
+			"SrcPort": int64(51000), "DstPort": int64(443), "Packets": int64(3), "Bytes": int64(134), "Payload": int64(14),
+			"Start": t0.Add(500 * time.Microsecond), "End": t0.Add(3*time.Second + 500*time.Microsecond), "Duration": 3.0,
+			"Flags": []interface{}{"FIN", "SYN", "PSH", "ACK"},
+		},
+		{
+			"Version": int64(4), "SrcIP": "93.184.216.34", "DstIP": "10.0.0.7", "Proto": "tcp",
+			"SrcPort": int64(443), "DstPort": int64(51000), "Packets": int64(1), "Bytes": int64(40), "Payload": int64(0),
+			"Start": t0.Add(time.Second + 500*time.Microsecond), "End": t0.Add(time.Second + 500*time.Microsecond), "Duration": 0.0,
+			"Flags": []interface{}{"SYN", "ACK"},
+		},
+	}
+	if f.Len() != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	data := capture(binary.LittleEndian, false, linkRaw,
+		ipv4([4]byte{10, 0, 0, 7}, [4]byte{93, 184, 216, 34}, 6, tcp(51000, 443, 0x02, "")),
+		ipv4([4]byte{10, 0, 0, 7}, [4]byte{10, 0, 0, 53}, 17, udp(5353, 53, "query")),
+		ipv4([4]byte{172, 16, 0, 1}, [4]byte{93, 184, 216, 34}, 6, tcp(51001, 443, 0x02, "")),
+	)
+	q, err := simple.Parse(`SrcIP in 10.0.0.0/8 and not (DstIP in 10.0.0.0/8) and Flags contains "SYN"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ports []interface{}
+	for _, p := range decodeAll(t, data) {
+		ok, err := q.Evaluate(map[string]interface{}(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			ports = append(ports, p["SrcPort"])
+		}
+	}
+	if !reflect.DeepEqual(ports, []interface{}{int64(51000)}) {
+		t.Errorf("matched %v", ports)
+	}
+}
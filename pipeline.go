@@ -0,0 +1,122 @@
+package evaluator
+
+import (
+	"context"
+)
+
+// ErrorHandler receives evaluation errors raised inside Filter and the Seq
+// helpers. Pass one in their options; without it items that fail to evaluate
+// are dropped silently.
+type ErrorHandler func(error)
+
+// getErrorHandler extracts the ErrorHandler from the variadic options.
+func getErrorHandler(opts []any) ErrorHandler {
+	for _, opt := range opts {
+		if h, ok := opt.(ErrorHandler); ok {
+			return h
+		}
+	}
+	return nil
+}
+
+// matchItem evaluates q against v, reporting errors to onError and treating
+// them as non-matches.
+func matchItem(q Query, v interface{}, onError ErrorHandler, opts []any) bool {
+	matched, err := q.Evaluate(v, opts...)
+	if err != nil {
+		if onError != nil {
+			onError(err)
+		}
+		return false
+	}
+	return matched
+}
+
+// Filter forwards the items received from in that match q. The returned
+// channel is closed once in is closed or ctx is cancelled. opts are passed to
+// q.Evaluate; an ErrorHandler among them is called for items whose evaluation
+// fails, which are not forwarded.
+func Filter[T any](ctx context.Context, in <-chan T, q Query, opts ...any) <-chan T {
+	onError := getErrorHandler(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := receive(ctx, in)
+			if !ok {
+				return
+			}
+			if matchItem(q, v, onError, opts) && !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Map applies fn to every item received from in. The returned channel is
+// closed once in is closed or ctx is cancelled.
+func Map[T, U any](ctx context.Context, in <-chan T, fn func(T) U) <-chan U {
+	out := make(chan U)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := receive(ctx, in)
+			if !ok || !send(ctx, out, fn(v)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Tee copies every item received from in to n channels. Each item is
+// delivered to all outputs before the next is read, so a slow consumer holds
+// back the others. The outputs are closed once in is closed or ctx is
+// cancelled.
+func Tee[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	outs := make([]chan T, n)
+	ro := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		ro[i] = outs[i]
+	}
+	go func() {
+		defer func() {
+			for _, o := range outs {
+				close(o)
+			}
+		}()
+		for {
+			v, ok := receive(ctx, in)
+			if !ok {
+				return
+			}
+			for _, o := range outs {
+				if !send(ctx, o, v) {
+					return
+				}
+			}
+		}
+	}()
+	return ro
+}
+
+func receive[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
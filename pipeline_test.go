@@ -0,0 +1,90 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type pipelineItem struct {
+	N int
+}
+
+type failingExpression struct{}
+
+func (failingExpression) Evaluate(interface{}, ...any) (bool, error) {
+	return false, errors.New("fail")
+}
+
+func feed[T any](items ...T) <-chan T {
+	ch := make(chan T, len(items))
+	for _, it := range items {
+		ch <- it
+	}
+	close(ch)
+	return ch
+}
+
+func TestFilterChannel(t *testing.T) {
+	q := Query{Expression: &GreaterThanExpression{Field: "N", Value: 1}}
+	in := feed(&pipelineItem{1}, &pipelineItem{2}, &pipelineItem{3})
+	var got []int
+	for v := range Filter(context.Background(), in, q) {
+		got = append(got, v.N)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFilterChannelErrorHandler(t *testing.T) {
+	q := Query{Expression: failingExpression{}}
+	var errs []error
+	out := Filter(context.Background(), feed(map[string]interface{}{"a": 1}), q, ErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	for range out {
+		t.Errorf("unexpected item")
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected one error, got %v", errs)
+	}
+}
+
+func TestMapAndTee(t *testing.T) {
+	ctx := context.Background()
+	doubled := Map(ctx, feed(1, 2, 3), func(v int) int { return v * 2 })
+	outs := Tee(ctx, doubled, 2)
+	done := make(chan []int)
+	for _, o := range outs {
+		go func(o <-chan int) {
+			var got []int
+			for v := range o {
+				got = append(got, v)
+			}
+			done <- got
+		}(o)
+	}
+	for range outs {
+		got := <-done
+		if len(got) != 3 || got[0] != 2 || got[2] != 6 {
+			t.Errorf("got %v", got)
+		}
+	}
+}
+
+func TestFilterChannelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan map[string]interface{})
+	out := Filter(ctx, in, Query{Expression: &IsExpression{Field: "a", Value: 1}})
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Errorf("unexpected item")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("output not closed after cancel")
+	}
+}
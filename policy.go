@@ -0,0 +1,75 @@
+package evaluator
+
+import "errors"
+
+// ErrorPolicy decides what happens when a leaf expression of a query returns
+// an error, for example from a bad pattern or a failing Getter. A policy is
+// set with Query.OnEvalError or passed in the evaluation options; the one
+// closest to the failing leaf wins.
+type ErrorPolicy string
+
+const (
+	// FailError, the default, returns the error to the caller.
+	FailError ErrorPolicy = ""
+	// FailClosed treats the failing leaf as not matching.
+	FailClosed ErrorPolicy = "fail-closed"
+	// FailOpen treats the failing leaf as matching.
+	FailOpen ErrorPolicy = "fail-open"
+	// Collect treats the failing leaf as not matching and appends the error
+	// to the *EvalErrors passed in the evaluation options, if any.
+	Collect ErrorPolicy = "collect"
+)
+
+// EvalErrors collects the leaf errors suppressed by the Collect policy. Pass
+// a pointer to one in the evaluation options. It is not safe for concurrent
+// evaluations.
+type EvalErrors struct {
+	Errors []error
+}
+
+// Err joins the collected errors, returning nil when there are none.
+func (e *EvalErrors) Err() error {
+	return errors.Join(e.Errors...)
+}
+
+// withErrorPolicy returns opts with p appended, leaving the caller's slice
+// untouched.
+func withErrorPolicy(opts []any, p ErrorPolicy) []any {
+	return append(opts[:len(opts):len(opts)], p)
+}
+
+// applyErrorPolicy resolves a leaf error using the last ErrorPolicy in opts.
+func applyErrorPolicy(err error, opts []any) (bool, error) {
+	policy := FailError
+	var collected *EvalErrors
+	for _, o := range opts {
+		switch v := o.(type) {
+		case ErrorPolicy:
+			policy = v
+		case *EvalErrors:
+			collected = v
+		}
+	}
+	switch policy {
+	case FailClosed:
+		return false, nil
+	case FailOpen:
+		return true, nil
+	case Collect:
+		if collected != nil {
+			collected.Errors = append(collected.Errors, err)
+		}
+		return false, nil
+	}
+	return false, err
+}
+
+// isLeaf reports whether e is evaluated directly rather than combining
+// child queries.
+func isLeaf(e Expression) bool {
+	switch e.(type) {
+	case *AndExpression, *OrExpression, *XorExpression, *ImpliesExpression, *CaseExpression, *NotExpression, *AnyExpression, *AllExpression, *CountExpression, *ScoreQuery:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestErrorPolicy(t *testing.T) {
+	bad := Query{Expression: &MatchesExpression{Field: "Name", Pattern: "("}}
+	rec := map[string]interface{}{"Name": "bob", "Age": 30}
+	tests := []struct {
+		name    string
+		q       Query
+		want    bool
+		wantErr bool
+	}{
+		{"default", Query{Expression: &OrExpression{Expressions: []Query{bad}}}, false, true},
+		{"fail closed", Query{OnEvalError: FailClosed, Expression: &OrExpression{Expressions: []Query{bad}}}, false, false},
+		{"fail open", Query{OnEvalError: FailOpen, Expression: &AndExpression{Expressions: []Query{
+			bad,
+			{Expression: &IsExpression{Field: "Age", Value: 30}},
+		}}}, true, false},
+		{"fail open under not", Query{OnEvalError: FailOpen, Expression: &NotExpression{Expression: bad}}, false, false},
+		{"nested override", Query{OnEvalError: FailOpen, Expression: &OrExpression{Expressions: []Query{
+			{OnEvalError: FailClosed, Expression: bad.Expression},
+		}}}, false, false},
+		{"leaf root", Query{OnEvalError: FailOpen, Expression: bad.Expression}, true, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.q.Evaluate(rec)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: err = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorPolicyCollect(t *testing.T) {
+	q := Query{OnEvalError: Collect, Expression: &OrExpression{Expressions: []Query{
+		{Expression: &MatchesExpression{Field: "Name", Pattern: "("}},
+		{Expression: &MatchesExpression{Field: "Name", Pattern: "[a-"}},
+		{Expression: &IsExpression{Field: "Name", Value: "bob"}},
+	}}}
+	var errs EvalErrors
+	ok, err := q.Evaluate(map[string]interface{}{"Name": "bob"}, &errs)
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if len(errs.Errors) != 2 || errs.Err() == nil {
+		t.Errorf("collected %v", errs.Errors)
+	}
+	if (&EvalErrors{}).Err() != nil {
+		t.Errorf("empty EvalErrors should have no error")
+	}
+}
+
+func TestErrorPolicyJSON(t *testing.T) {
+	q := Query{OnEvalError: FailClosed, Expression: &IsExpression{Field: "A", Value: 1}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"Is","Expression":{"Field":"A","Value":1}},"OnEvalError":"fail-closed"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.OnEvalError != FailClosed {
+		t.Errorf("policy lost: %q", back.OnEvalError)
+	}
+	a, _ := Hash(q)
+	b, _ := Hash(Query{Expression: q.Expression})
+	if a == b {
+		t.Errorf("policy should change the hash")
+	}
+}
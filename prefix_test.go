@@ -0,0 +1,49 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStartsWithEndsWith(t *testing.T) {
+	rec := map[string]interface{}{"Path": "/api/v1/users.json", "N": 5}
+	tests := []struct {
+		e    Expression
+		want bool
+	}{
+		{&StartsWithExpression{Field: "Path", Value: "/api/"}, true},
+		{&StartsWithExpression{Field: "Path", Value: "/static/"}, false},
+		{&EndsWithExpression{Field: "Path", Value: ".json"}, true},
+		{&EndsWithExpression{Field: "Path", Value: ".xml"}, false},
+		{&StartsWithExpression{Field: "N", Value: "5"}, false},
+		{&EndsWithExpression{Field: "Missing", Value: ""}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%#v: got %v, want %v", tt.e, got, tt.want)
+		}
+	}
+}
+
+func TestStartsWithEndsWithJSON(t *testing.T) {
+	q := Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &StartsWithExpression{Field: "Path", Value: "/api/"}},
+		{Expression: &EndsWithExpression{Field: "Path", Value: ".json"}},
+	}}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	again, _ := json.Marshal(back)
+	if string(again) != string(data) {
+		t.Errorf("round trip changed %s to %s", data, again)
+	}
+}
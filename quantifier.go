@@ -0,0 +1,92 @@
+package evaluator
+
+import "reflect"
+
+// AnyExpression succeeds when Query matches at least one element of the
+// slice or array Field. Each element is evaluated as a record of its own, so
+// Query refers to the element's fields: Items any (Price > 100).
+type AnyExpression struct {
+	Field string
+	Query Query
+}
+
+func (e AnyExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	matched := false
+	_, err := eachElement(i, e.Field, func(el interface{}) (bool, error) {
+		ok, err := e.Query.Evaluate(el, opts...)
+		matched = ok
+		return !ok, err
+	})
+	return matched, err
+}
+
+// AllExpression succeeds when Query matches every element of the slice or
+// array Field. An empty list matches; a missing field or one that is not a
+// list does not.
+type AllExpression struct {
+	Field string
+	Query Query
+}
+
+func (e AllExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	matched := true
+	isList, err := eachElement(i, e.Field, func(el interface{}) (bool, error) {
+		ok, err := e.Query.Evaluate(el, opts...)
+		matched = ok
+		return ok, err
+	})
+	if err != nil || !isList {
+		return false, err
+	}
+	return matched, nil
+}
+
+// eachElement calls fn with each element of the slice or array Field of i
+// until fn returns false or an error. Struct elements are passed by pointer
+// so they can be evaluated like any other record. It reports false without
+// calling fn when the field is missing or not a list.
+func eachElement(i interface{}, field string, fn func(el interface{}) (bool, error)) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, field)
+	if !ok {
+		return false, nil
+	}
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return false, nil
+		}
+		f = f.Elem()
+	}
+	if f.Kind() != reflect.Slice && f.Kind() != reflect.Array {
+		return false, nil
+	}
+	for n := 0; n < f.Len(); n++ {
+		more, err := fn(elementRecord(f.Index(n)))
+		if err != nil || !more {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// elementRecord returns el in a form derefValue accepts.
+func elementRecord(el reflect.Value) interface{} {
+	for el.Kind() == reflect.Interface && !el.IsNil() {
+		el = el.Elem()
+	}
+	if el.Kind() != reflect.Struct {
+		if el.CanInterface() {
+			return el.Interface()
+		}
+		return nil
+	}
+	if el.CanAddr() {
+		return el.Addr().Interface()
+	}
+	p := reflect.New(el.Type())
+	p.Elem().Set(el)
+	return p.Interface()
+}
@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type orderItem struct {
+	Name  string
+	Price float64
+}
+
+type order struct {
+	Items []orderItem
+	Refs  []*orderItem
+}
+
+func TestAnyExpression(t *testing.T) {
+	expensive := Query{Expression: &GreaterThanExpression{Field: "Price", Value: 100}}
+	tests := []struct {
+		name string
+		rec  interface{}
+		e    AnyExpression
+		want bool
+	}{
+		{"struct elements", &order{Items: []orderItem{{"a", 5}, {"b", 150}}}, AnyExpression{Field: "Items", Query: expensive}, true},
+		{"no match", &order{Items: []orderItem{{"a", 5}, {"b", 50}}}, AnyExpression{Field: "Items", Query: expensive}, false},
+		{"pointer elements", &order{Refs: []*orderItem{nil, {"b", 150}}}, AnyExpression{Field: "Refs", Query: expensive}, true},
+		{"empty", &order{}, AnyExpression{Field: "Items", Query: expensive}, false},
+		{"missing field", &order{}, AnyExpression{Field: "Other", Query: expensive}, false},
+		{"map elements", map[string]interface{}{"Items": []interface{}{
+			map[string]interface{}{"Price": 10},
+			map[string]interface{}{"Price": 200},
+		}}, AnyExpression{Field: "Items", Query: expensive}, true},
+		{"not a list", map[string]interface{}{"Items": "x"}, AnyExpression{Field: "Items", Query: expensive}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.e.Evaluate(tt.rec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnyExpressionJSON(t *testing.T) {
+	q := Query{Expression: &AnyExpression{Field: "Items", Query: Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &GreaterThanExpression{Field: "Price", Value: 100}},
+		{Expression: &IsExpression{Field: "Name", Value: "b"}},
+	}}}}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	again, err := json.Marshal(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("round trip changed JSON:\n%s\n%s", data, again)
+	}
+	rec := &order{Items: []orderItem{{"a", 150}, {"b", 150}}}
+	if ok, err := back.Evaluate(rec); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+	rec.Items[1].Name = "c"
+	if ok, _ := back.Evaluate(rec); ok {
+		t.Errorf("expected the conditions to hold on the same element")
+	}
+}
+
+func TestAnyExpressionRedact(t *testing.T) {
+	q := Query{Expression: &AnyExpression{Field: "Items", Query: Query{Expression: &IsExpression{Field: "Name", Value: "secret"}}}}
+	r := Redact(q, []string{"Name"})
+	inner := r.Expression.(*AnyExpression).Query.Expression.(*IsExpression)
+	if inner.Value != RedactedValue {
+		t.Errorf("inner value not redacted: %v", inner.Value)
+	}
+	if q.Expression.(*AnyExpression).Query.Expression.(*IsExpression).Value != "secret" {
+		t.Errorf("original modified")
+	}
+}
+
+func TestAllExpression(t *testing.T) {
+	cheap := Query{Expression: &LessThanExpression{Field: "Price", Value: 100}}
+	tests := []struct {
+		name string
+		rec  interface{}
+		want bool
+	}{
+		{"all match", &order{Items: []orderItem{{"a", 5}, {"b", 50}}}, true},
+		{"one fails", &order{Items: []orderItem{{"a", 5}, {"b", 150}}}, false},
+		{"empty list", &order{Items: []orderItem{}}, true},
+		{"missing field", map[string]interface{}{}, false},
+		{"not a list", map[string]interface{}{"Items": 3}, false},
+	}
+	for _, tt := range tests {
+		got, err := AllExpression{Field: "Items", Query: cheap}.Evaluate(tt.rec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllExpressionJSON(t *testing.T) {
+	q := Query{Expression: &AllExpression{Field: "Items", Query: Query{Expression: &LessThanExpression{Field: "Price", Value: 100}}}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := back.Expression.(*AllExpression); !ok {
+		t.Fatalf("got %T", back.Expression)
+	}
+	if ok, err := back.Evaluate(&order{Items: []orderItem{{"a", 150}}}); err != nil || ok {
+		t.Errorf("expected no match: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,409 @@
+package evaluator
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RedactedValue is the placeholder substituted for literal values by Redact.
+const RedactedValue = "[REDACTED]"
+
+// Redact returns a copy of q in which every literal value compared against one
+// of the named fields is replaced with RedactedValue, including literals in
+// the filters of a JSONPath. Literals held in numeric struct fields, such as
+// the coordinates of GeoWithin or the Mask of HasBits, cannot hold a string
+// and are set to zero instead. The structure of the query is preserved so the result
+// can still be logged, marshaled or stringified, but it should not be
+// evaluated. The original query is left untouched. Redaction fails closed:
+// expressions of types this package does not define have all of their
+// literals redacted, whatever fields they name.
+func Redact(q Query, fields []string) Query {
+	if q.Expression == nil || len(fields) == 0 {
+		return q
+	}
+	set := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		set[f] = struct{}{}
+	}
+	return Query{Expression: redactExpr(q.Expression, set), Metadata: q.Metadata, OnEvalError: q.OnEvalError}
+}
+
+func redactQueries(qs []Query, set map[string]struct{}) []Query {
+	out := make([]Query, len(qs))
+	for i, q := range qs {
+		out[i] = q
+		if q.Expression != nil {
+			out[i] = Query{Expression: redactExpr(q.Expression, set), Metadata: q.Metadata, OnEvalError: q.OnEvalError}
+		}
+	}
+	return out
+}
+
+func redactValue(field string, v interface{}, set map[string]struct{}) interface{} {
+	if _, ok := set[field]; ok {
+		return RedactedValue
+	}
+	return v
+}
+
+func redactValues(field string, vs []interface{}, set map[string]struct{}) []interface{} {
+	out := make([]interface{}, len(vs))
+	for i, v := range vs {
+		out[i] = redactValue(field, v, set)
+	}
+	return out
+}
+
+func redacted(field string, set map[string]struct{}) bool {
+	_, ok := set[field]
+	return ok
+}
+
+// redactString returns RedactedValue in place of s when field is in set.
+func redactString(field, s string, set map[string]struct{}) string {
+	if redacted(field, set) {
+		return RedactedValue
+	}
+	return s
+}
+
+// redactJSONPath replaces the literals that filters in path compare with a
+// named field, as in $.users[?(@.SSN == '123')], with a quoted
+// RedactedValue. A path that does not parse is replaced as a whole, as its
+// literals cannot be found.
+func redactJSONPath(path string, set map[string]struct{}) string {
+	s := strings.TrimSpace(path)
+	steps, err := parseJSONPath(s)
+	if err != nil {
+		return RedactedValue
+	}
+	var spans [][2]int
+	jsonPathLiterals(steps, set, &spans)
+	sort.Slice(spans, func(a, b int) bool { return spans[a][0] < spans[b][0] })
+	for n := len(spans) - 1; n >= 0; n-- {
+		s = s[:spans[n][0]] + "'" + RedactedValue + "'" + s[spans[n][1]:]
+	}
+	if spans == nil {
+		return path
+	}
+	return s
+}
+
+// jsonPathLiterals appends to spans the position of every filter literal in
+// steps compared with a path ending in a named field.
+func jsonPathLiterals(steps []jsonPathStep, set map[string]struct{}, spans *[][2]int) {
+	var walk func(f jsonPathFilter)
+	walk = func(f jsonPathFilter) {
+		switch f := f.(type) {
+		case jsonPathOr:
+			walk(f.l)
+			walk(f.r)
+		case jsonPathAnd:
+			walk(f.l)
+			walk(f.r)
+		case jsonPathNot:
+			walk(f.f)
+		case jsonPathTest:
+			for _, o := range []jsonPathOperand{f.lhs, f.rhs} {
+				jsonPathLiterals(o.steps, set, spans)
+			}
+			if f.op == "" {
+				return
+			}
+			if f.lhs.steps == nil && f.rhs.steps != nil && redacted(jsonPathStepsField(f.rhs.steps), set) {
+				*spans = append(*spans, [2]int{f.lhs.start, f.lhs.end})
+			}
+			if f.rhs.steps == nil && f.lhs.steps != nil && redacted(jsonPathStepsField(f.lhs.steps), set) {
+				*spans = append(*spans, [2]int{f.rhs.start, f.rhs.end})
+			}
+		}
+	}
+	for _, s := range steps {
+		if s.filter != nil {
+			walk(s.filter)
+		}
+	}
+}
+
+// termRefersTo reports whether t reads a field named in set.
+func termRefersTo(t Term, set map[string]struct{}) bool {
+	switch tm := t.(type) {
+	case Field:
+		return redacted(tm.Name, set)
+	case BucketTerm:
+		return redacted(tm.KeyField, set)
+	case SumTerm:
+		return redacted(tm.Field, set)
+	case MinTerm:
+		return redacted(tm.Field, set)
+	case MaxTerm:
+		return redacted(tm.Field, set)
+	case AvgTerm:
+		return redacted(tm.Field, set)
+	case Constant, Self, RandomTerm:
+		return false
+	}
+	for _, sub := range subTerms(t) {
+		if termRefersTo(sub, set) {
+			return true
+		}
+	}
+	return false
+}
+
+// subTerms returns the terms t is built from.
+func subTerms(t Term) []Term {
+	switch tm := t.(type) {
+	case BoolType:
+		return []Term{tm.Term}
+	case If:
+		return []Term{tm.Condition, tm.Then, tm.Else}
+	case FunctionExpression:
+		return tm.Args
+	case AddTerm:
+		return []Term{tm.LHS, tm.RHS}
+	case SubTerm:
+		return []Term{tm.LHS, tm.RHS}
+	case MulTerm:
+		return []Term{tm.LHS, tm.RHS}
+	case DivTerm:
+		return []Term{tm.LHS, tm.RHS}
+	case ModTerm:
+		return []Term{tm.LHS, tm.RHS}
+	case HashTerm:
+		return []Term{tm.Term}
+	case CoalesceTerm:
+		return tm.Terms
+	}
+	return nil
+}
+
+// redactConstants returns a copy of t with every Constant, and the salt of
+// every HashTerm, replaced with RedactedValue. Terms of types this package
+// does not define are replaced whole.
+func redactConstants(t Term) Term {
+	if t == nil {
+		return nil
+	}
+	rc := func(ts ...Term) []Term {
+		out := make([]Term, len(ts))
+		for i, sub := range ts {
+			out[i] = redactConstants(sub)
+		}
+		return out
+	}
+	switch tm := t.(type) {
+	case Constant:
+		return Constant{Value: RedactedValue}
+	case Field, Self, RandomTerm, BucketTerm, SumTerm, MinTerm, MaxTerm, AvgTerm:
+		return t
+	case BoolType:
+		return BoolType{Term: redactConstants(tm.Term)}
+	case If:
+		ts := rc(tm.Condition, tm.Then, tm.Else)
+		return If{Condition: ts[0], Then: ts[1], Else: ts[2]}
+	case FunctionExpression:
+		return FunctionExpression{Name: tm.Name, Func: tm.Func, Args: rc(tm.Args...)}
+	case AddTerm:
+		return AddTerm{LHS: redactConstants(tm.LHS), RHS: redactConstants(tm.RHS)}
+	case SubTerm:
+		return SubTerm{LHS: redactConstants(tm.LHS), RHS: redactConstants(tm.RHS)}
+	case MulTerm:
+		return MulTerm{LHS: redactConstants(tm.LHS), RHS: redactConstants(tm.RHS)}
+	case DivTerm:
+		return DivTerm{LHS: redactConstants(tm.LHS), RHS: redactConstants(tm.RHS)}
+	case ModTerm:
+		return ModTerm{LHS: redactConstants(tm.LHS), RHS: redactConstants(tm.RHS)}
+	case HashTerm:
+		return HashTerm{Term: redactConstants(tm.Term), Salt: RedactedValue}
+	case CoalesceTerm:
+		return CoalesceTerm{Terms: rc(tm.Terms...)}
+	}
+	return Constant{Value: RedactedValue}
+}
+
+// derefTerm returns the term a pointer to a term points to, so that redaction
+// sees &ModTerm{...} as it sees ModTerm{...}.
+func derefTerm(t Term) Term {
+	if v := reflect.ValueOf(t); v.Kind() == reflect.Ptr && !v.IsNil() {
+		if e, ok := v.Elem().Interface().(Term); ok {
+			return e
+		}
+	}
+	return t
+}
+
+func redactExpr(e Expression, set map[string]struct{}) Expression {
+	// Expressions given by value are redacted as pointers to a copy, the
+	// form the cases below, and unmarshaling, use.
+	if v := reflect.ValueOf(e); v.Kind() == reflect.Struct {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		if pe, ok := p.Interface().(Expression); ok {
+			e = pe
+		}
+	}
+	switch ex := e.(type) {
+	case *ContainsExpression:
+		return &ContainsExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Fold: ex.Fold}
+	case *IContainsExpression:
+		return &IContainsExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set)}
+	case *StartsWithExpression:
+		return &StartsWithExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set)}
+	case *EndsWithExpression:
+		return &EndsWithExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set)}
+	case *GlobExpression:
+		return &GlobExpression{Field: ex.Field, Pattern: redactValue(ex.Field, ex.Pattern, set).(string)}
+	case *FuzzyMatchExpression:
+		return &FuzzyMatchExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set).(string), MaxDistance: ex.MaxDistance}
+	case *SoundsLikeExpression:
+		return &SoundsLikeExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set).(string)}
+	case *JSONPathExpression:
+		return &JSONPathExpression{Path: redactJSONPath(ex.Path, set), Op: ex.Op, Value: redactValue(jsonPathField(ex.Path), ex.Value, set)}
+	case *MatchesExpression:
+		return &MatchesExpression{Field: ex.Field, Pattern: redactValue(ex.Field, ex.Pattern, set).(string)}
+	case *IsExpression:
+		return &IsExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Fold: ex.Fold}
+	case *BetweenExpression:
+		return &BetweenExpression{
+			Field:     ex.Field,
+			Low:       redactValue(ex.Field, ex.Low, set),
+			High:      redactValue(ex.Field, ex.High, set),
+			Inclusive: ex.Inclusive,
+		}
+	case *InExpression:
+		return &InExpression{Field: ex.Field, Values: redactValues(ex.Field, ex.Values, set)}
+	case *ContainsAllExpression:
+		return &ContainsAllExpression{Field: ex.Field, Values: redactValues(ex.Field, ex.Values, set), Fold: ex.Fold}
+	case *ContainsAnyExpression:
+		return &ContainsAnyExpression{Field: ex.Field, Values: redactValues(ex.Field, ex.Values, set), Fold: ex.Fold}
+	case *IsNotExpression:
+		return &IsNotExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Fold: ex.Fold}
+	case *GreaterThanExpression:
+		return &GreaterThanExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Fold: ex.Fold}
+	case *GreaterThanOrEqualExpression:
+		return &GreaterThanOrEqualExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Fold: ex.Fold}
+	case *LessThanExpression:
+		return &LessThanExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Fold: ex.Fold}
+	case *LessThanOrEqualExpression:
+		return &LessThanOrEqualExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Fold: ex.Fold}
+	case *ComparisonExpression:
+		lhs, rhs := derefTerm(ex.LHS), derefTerm(ex.RHS)
+		if termRefersTo(lhs, set) || termRefersTo(rhs, set) {
+			lhs, rhs = redactConstants(lhs), redactConstants(rhs)
+		}
+		return &ComparisonExpression{LHS: lhs, RHS: rhs, Operation: ex.Operation}
+	case *GeoWithinExpression:
+		out := *ex
+		if redacted(ex.LatField, set) || redacted(ex.LonField, set) {
+			out.Lat, out.Lon = 0, 0
+		}
+		return &out
+	case *CIDRContainsExpression:
+		return &CIDRContainsExpression{Field: ex.Field, CIDR: redactString(ex.Field, ex.CIDR, set)}
+	case *BeforeExpression:
+		return &BeforeExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Layouts: ex.Layouts}
+	case *AfterExpression:
+		return &AfterExpression{Field: ex.Field, Value: redactValue(ex.Field, ex.Value, set), Layouts: ex.Layouts}
+	case *WithinDurationExpression:
+		return &WithinDurationExpression{
+			Field:    ex.Field,
+			Duration: redactString(ex.Field, ex.Duration, set),
+			From:     redactValue(ex.Field, ex.From, set),
+		}
+	case *HasKeyExpression:
+		return &HasKeyExpression{Field: ex.Field, Key: redactValue(ex.Field, ex.Key, set)}
+	case *RolloutExpression:
+		return &RolloutExpression{KeyField: ex.KeyField, Percent: ex.Percent, Salt: redactString(ex.KeyField, ex.Salt, set)}
+	case *InFileExpression:
+		return &InFileExpression{Field: ex.Field, Path: redactString(ex.Field, ex.Path, set), Bloom: ex.Bloom}
+	case *ModExpression:
+		out := *ex
+		if redacted(ex.Field, set) {
+			out.Remainder = 0
+		}
+		return &out
+	case *HasBitsExpression:
+		out := *ex
+		if redacted(ex.Field, set) {
+			out.Mask = 0
+		}
+		return &out
+	case *TypeOfExpression, *LengthExpression, *IsEmptyExpression, *IsNotEmptyExpression,
+		*FieldCompareExpression, *IsFormatExpression:
+		// These hold no literal taken from the record.
+		return e
+	case *AndExpression:
+		return &AndExpression{Expressions: redactQueries(ex.Expressions, set)}
+	case *OrExpression:
+		return &OrExpression{Expressions: redactQueries(ex.Expressions, set)}
+	case *XorExpression:
+		return &XorExpression{Expressions: redactQueries(ex.Expressions, set)}
+	case *ImpliesExpression:
+		qs := redactQueries([]Query{ex.If, ex.Then}, set)
+		return &ImpliesExpression{If: qs[0], Then: qs[1]}
+	case *CaseExpression:
+		out := &CaseExpression{Cases: make([]CaseBranch, len(ex.Cases)), Else: redactQueries([]Query{ex.Else}, set)[0]}
+		for i, c := range ex.Cases {
+			qs := redactQueries([]Query{c.When, c.Then}, set)
+			out.Cases[i] = CaseBranch{When: qs[0], Then: qs[1]}
+		}
+		return out
+	case *NotExpression:
+		return &NotExpression{Expression: redactQueries([]Query{ex.Expression}, set)[0]}
+	case *ScoreQuery:
+		out := &ScoreQuery{Expressions: make([]WeightedExpression, len(ex.Expressions)), Threshold: ex.Threshold}
+		for i, w := range ex.Expressions {
+			out.Expressions[i] = WeightedExpression{Query: redactQueries([]Query{w.Query}, set)[0], Weight: w.Weight}
+		}
+		return out
+	case *AnyExpression:
+		return &AnyExpression{Field: ex.Field, Query: redactQueries([]Query{ex.Query}, set)[0]}
+	case *AllExpression:
+		return &AllExpression{Field: ex.Field, Query: redactQueries([]Query{ex.Query}, set)[0]}
+	case *CountExpression:
+		return &CountExpression{Field: ex.Field, Query: redactQueries([]Query{ex.Query}, set)[0], Op: ex.Op, Value: ex.Value}
+	default:
+		return redactUnknown(e, set)
+	}
+}
+
+// redactUnknown redacts an expression of a type this package does not
+// define. Which of its fields name record fields cannot be known, so every
+// exported string and interface field whose name does not contain "Field" is
+// replaced with RedactedValue, other literal fields are zeroed and nested
+// queries are redacted in turn.
+func redactUnknown(e Expression, set map[string]struct{}) Expression {
+	v := reflect.ValueOf(e)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return e
+	}
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	st := c.Elem().Type()
+	for n := 0; n < st.NumField(); n++ {
+		sf := st.Field(n)
+		f := c.Elem().Field(n)
+		if !sf.IsExported() || !f.CanSet() {
+			continue
+		}
+		switch {
+		case sf.Type == queryType:
+			f.Set(reflect.ValueOf(redactQueries([]Query{f.Interface().(Query)}, set)[0]))
+		case sf.Type == reflect.TypeOf([]Query(nil)):
+			f.Set(reflect.ValueOf(redactQueries(f.Interface().([]Query), set)))
+		case strings.Contains(sf.Name, "Field") && f.Kind() == reflect.String:
+		case f.Kind() == reflect.String:
+			f.SetString(RedactedValue)
+		case f.Kind() == reflect.Interface && reflect.TypeOf(RedactedValue).AssignableTo(sf.Type):
+			f.Set(reflect.ValueOf(RedactedValue))
+		default:
+			f.SetZero()
+		}
+	}
+	if out, ok := c.Interface().(Expression); ok {
+		return out
+	}
+	return e
+}
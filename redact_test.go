@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	q := Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &IsExpression{Field: "Email", Value: "bob@example.com"}},
+		{Expression: &GreaterThanExpression{Field: "Age", Value: 30}},
+		{Expression: &NotExpression{Expression: Query{Expression: &ContainsExpression{Field: "Email", Value: "@corp"}}}},
+	}}}
+	r := Redact(q, []string{"Email"})
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	s := string(b)
+	if strings.Contains(s, "bob@example.com") || strings.Contains(s, "@corp") {
+		t.Errorf("sensitive value leaked: %s", s)
+	}
+	if !strings.Contains(s, RedactedValue) || !strings.Contains(s, `"Value":30`) {
+		t.Errorf("unexpected redaction: %s", s)
+	}
+
+	// The original query must be left intact.
+	if v, err := q.Evaluate(&struct {
+		Email string
+		Age   int
+	}{Email: "bob@example.com", Age: 40}); err != nil || !v {
+		t.Errorf("original query modified: %v %v", v, err)
+	}
+}
+
+func TestRedactComparisonExpression(t *testing.T) {
+	q := Query{Expression: &ComparisonExpression{LHS: Constant{Value: "secret"}, RHS: Field{Name: "Token"}, Operation: "eq"}}
+	r := Redact(q, []string{"Token"})
+	c := r.Expression.(*ComparisonExpression)
+	if c.LHS != (Constant{Value: RedactedValue}) || c.RHS != (Field{Name: "Token"}) {
+		t.Errorf("unexpected redaction: %#v", c)
+	}
+}
+
+// secretExpression is an expression type Redact knows nothing about.
+type secretExpression struct {
+	Field  string
+	Needle string
+	Limit  int
+}
+
+func (e *secretExpression) Evaluate(interface{}, ...any) (bool, error) { return false, nil }
+
+func TestRedactLiterals(t *testing.T) {
+	fields := []string{"Secret", "Lat", "IP", "When", "Tags", "User", "Path", "Flags"}
+	q := Query{
+		Expression: &AndExpression{Expressions: []Query{
+			{Expression: GeoWithinExpression{LatField: "Lat", LonField: "Lon", Lat: 51.5, Lon: -0.12, RadiusMeters: 100}},
+			{Expression: &CIDRContainsExpression{Field: "IP", CIDR: "10.1.2.0/24"}},
+			{Expression: BeforeExpression{Field: "When", Value: "2031-07-04"}},
+			{Expression: &AfterExpression{Field: "When", Value: "2031-07-04"}},
+			{Expression: &WithinDurationExpression{Field: "When", Duration: "72h", From: "2031-07-04"}},
+			{Expression: HasKeyExpression{Field: "Tags", Key: "vip-lounge"}},
+			{Expression: &RolloutExpression{KeyField: "User", Percent: 10, Salt: "launch-salt"}},
+			{Expression: &InFileExpression{Field: "Path", Path: "/srv/blocked.txt"}},
+			{Expression: &ComparisonExpression{
+				LHS:       FunctionExpression{Name: "upper", Args: []Term{Field{Name: "Secret"}, Constant{Value: "pepper"}}},
+				RHS:       Constant{Value: "HUNTER2"},
+				Operation: "eq",
+			}},
+			{Expression: &IsExpression{Field: "Secret", Value: "hunter2"}, Metadata: &Metadata{Author: "ops"}, OnEvalError: FailOpen},
+		}},
+		Metadata: &Metadata{Description: "audit"},
+	}
+	r := Redact(q, fields)
+
+	s := fmt.Sprintf("%+v", r.Expression)
+	for _, exprs := range r.Expression.(*AndExpression).Expressions {
+		s += fmt.Sprintf(" %+v", exprs.Expression)
+	}
+	for _, leak := range []string{"51.5", "10.1.2.0", "2031-07-04", "72h", "vip-lounge", "launch-salt", "blocked", "pepper", "HUNTER2", "hunter2"} {
+		if strings.Contains(s, leak) {
+			t.Errorf("%q leaked: %s", leak, s)
+		}
+	}
+	exprs := r.Expression.(*AndExpression).Expressions
+	if g := exprs[0].Expression.(*GeoWithinExpression); g.RadiusMeters != 100 || g.LonField != "Lon" {
+		t.Errorf("geo structure lost: %+v", g)
+	}
+	if last := exprs[len(exprs)-1]; last.Metadata == nil || last.Metadata.Author != "ops" || last.OnEvalError != FailOpen {
+		t.Errorf("nested query fields dropped: %+v", last)
+	}
+	if r.Metadata == nil || r.Metadata.Description != "audit" {
+		t.Errorf("metadata dropped: %+v", r.Metadata)
+	}
+}
+
+func TestRedactJSONPath(t *testing.T) {
+	set := map[string]struct{}{"SSN": {}}
+	for path, want := range map[string]string{
+		`$.users[?(@.SSN == '123-45' && @.Country == 'NZ')].Name`: `$.users[?(@.SSN == '[REDACTED]' && @.Country == 'NZ')].Name`,
+		`$.users[?("123" != @.SSN || !(@.SSN == 5))]`:             `$.users[?('[REDACTED]' != @.SSN || !(@.SSN == '[REDACTED]'))]`,
+		`$.a[?(@.b[?(@.SSN == 'x')].c == 'y')]`:                   `$.a[?(@.b[?(@.SSN == '[REDACTED]')].c == 'y')]`,
+		`$.users[*].SSN`:                                          `$.users[*].SSN`,
+		`$.users[?(@.SSN == 'x'`:                                  RedactedValue,
+	} {
+		got := redactJSONPath(path, set)
+		if got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+		if got != RedactedValue {
+			if _, err := parseJSONPath(got); err != nil {
+				t.Errorf("%s: redacted path does not parse: %v", path, err)
+			}
+		}
+	}
+	r := Redact(Query{Expression: &JSONPathExpression{Path: `$.users[?(@.SSN == '123-45')].SSN`, Op: "eq", Value: "678-90"}}, []string{"SSN"})
+	if s := fmt.Sprintf("%+v", r.Expression); strings.Contains(s, "123-45") || strings.Contains(s, "678-90") {
+		t.Errorf("literal leaked: %s", s)
+	}
+}
+
+func TestRedactUnknownExpression(t *testing.T) {
+	q := Query{Expression: &secretExpression{Field: "Other", Needle: "hunter2", Limit: 7}}
+	r := Redact(q, []string{"Secret"})
+	got := r.Expression.(*secretExpression)
+	if got.Field != "Other" || got.Needle != RedactedValue || got.Limit != 0 {
+		t.Errorf("unexpected redaction: %+v", got)
+	}
+	if q.Expression.(*secretExpression).Needle != "hunter2" {
+		t.Errorf("original query modified")
+	}
+}
@@ -0,0 +1,289 @@
+// Package redisfilter filters JSON messages read from a Redis pub/sub channel
+// or stream and republishes the matches to another channel or stream. It
+// speaks the Redis protocol directly, so it needs no client library.
+package redisfilter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Endpoint is a pub/sub channel or, when Stream is set, a stream key.
+type Endpoint struct {
+	Stream bool
+	Name   string
+}
+
+// ParseEndpoint parses "channel:NAME" or "stream:NAME". A name without a
+// prefix is a channel.
+func ParseEndpoint(s string) (Endpoint, error) {
+	kind, name, ok := strings.Cut(s, ":")
+	if !ok {
+		kind, name = "channel", s
+	}
+	if name == "" {
+		return Endpoint{}, fmt.Errorf("endpoint %q: missing name", s)
+	}
+	switch kind {
+	case "channel":
+		return Endpoint{Name: name}, nil
+	case "stream":
+		return Endpoint{Stream: true, Name: name}, nil
+	}
+	return Endpoint{}, fmt.Errorf("endpoint %q: kind must be channel or stream", s)
+}
+
+func (e Endpoint) String() string {
+	if e.Stream {
+		return "stream:" + e.Name
+	}
+	return "channel:" + e.Name
+}
+
+// Stats describes the work done by a Bridge.
+type Stats struct {
+	Received   int64 // messages read from From
+	Matched    int64 // messages republished to To
+	Skipped    int64 // messages that were not JSON or failed to evaluate
+	Reconnects int64 // times the connection was re-established
+}
+
+// Bridge copies the messages on From that match Query to To. Messages are
+// JSON documents: the payload of a channel message, or the Field of a stream
+// entry. Matches are republished unchanged. A Query without an Expression
+// passes every message.
+//
+// Lost connections are re-established with exponential backoff. Reading a
+// stream resumes after the last entry handled, so no entry is missed and an
+// entry whose republish failed is read again. Pub/sub has no history, so
+// channel messages sent while disconnected are lost.
+type Bridge struct {
+	Addr     string
+	Password string
+	From, To Endpoint
+	// Field is the stream entry field holding the payload. It defaults to
+	// "data".
+	Field string
+	Query evaluator.Query
+	// Backoff is the first reconnect delay, doubled after each failed
+	// attempt up to MaxBackoff. They default to 100ms and 30s.
+	Backoff, MaxBackoff time.Duration
+	// Logf, when set, reports skipped messages and reconnects.
+	Logf func(format string, args ...any)
+
+	lastID string
+	stats  struct {
+		received, matched, skipped, reconnects atomic.Int64
+	}
+}
+
+// Stats returns a snapshot of the bridge statistics. It is safe to call while
+// Run is running.
+func (b *Bridge) Stats() Stats {
+	return Stats{
+		Received:   b.stats.received.Load(),
+		Matched:    b.stats.matched.Load(),
+		Skipped:    b.stats.skipped.Load(),
+		Reconnects: b.stats.reconnects.Load(),
+	}
+}
+
+// Run bridges messages until ctx is cancelled, when it returns ctx.Err(), or
+// until the server rejects a command with an Error. opts are passed to
+// Query.Evaluate.
+func (b *Bridge) Run(ctx context.Context, opts ...any) error {
+	delay := b.backoff()
+	for {
+		connected, err := b.session(ctx, opts)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var re Error
+		if errors.As(err, &re) {
+			return err
+		}
+		if connected {
+			delay = b.backoff()
+		}
+		b.logf("redis %s: %v; reconnecting in %s", b.Addr, err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > b.maxBackoff() {
+			delay = b.maxBackoff()
+		}
+		b.stats.reconnects.Add(1)
+	}
+}
+
+// session runs one connection to the server until it fails, reporting
+// whether both connections were established.
+func (b *Bridge) session(ctx context.Context, opts []any) (bool, error) {
+	in, err := dial(ctx, b.Addr, b.Password)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	// A subscribed connection cannot publish, so matches use their own.
+	out, err := dial(ctx, b.Addr, b.Password)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+	stop := context.AfterFunc(ctx, func() {
+		_ = in.Close()
+		_ = out.Close()
+	})
+	defer stop()
+	if b.From.Stream {
+		return true, b.readStream(in, out, opts)
+	}
+	return true, b.subscribe(in, out, opts)
+}
+
+func (b *Bridge) subscribe(in, out *conn, opts []any) error {
+	if err := in.send("SUBSCRIBE", b.From.Name); err != nil {
+		return err
+	}
+	for {
+		reply, err := in.read()
+		if err != nil {
+			return err
+		}
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) != 3 || msg[0] != "message" {
+			continue
+		}
+		payload, _ := msg[2].(string)
+		if err := b.handle(out, payload, opts); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Bridge) readStream(in, out *conn, opts []any) error {
+	if b.lastID == "" {
+		// Start after the newest entry, remembering its ID rather than
+		// reading from "$" so entries added while reconnecting are not
+		// missed.
+		reply, err := in.do("XREVRANGE", b.From.Name, "+", "-", "COUNT", "1")
+		if err != nil {
+			return err
+		}
+		b.lastID = "0-0"
+		if entries, _ := reply.([]interface{}); len(entries) == 1 {
+			if e, _ := entries[0].([]interface{}); len(e) == 2 {
+				b.lastID, _ = e[0].(string)
+			}
+		}
+	}
+	for {
+		reply, err := in.do("XREAD", "BLOCK", "0", "COUNT", "100", "STREAMS", b.From.Name, b.lastID)
+		if err != nil {
+			return err
+		}
+		streams, _ := reply.([]interface{})
+		for _, s := range streams {
+			s, _ := s.([]interface{})
+			if len(s) != 2 {
+				continue
+			}
+			entries, _ := s[1].([]interface{})
+			for _, e := range entries {
+				e, _ := e.([]interface{})
+				if len(e) != 2 {
+					continue
+				}
+				id, _ := e[0].(string)
+				if err := b.handle(out, b.payload(e[1]), opts); err != nil {
+					return err
+				}
+				b.lastID = id
+			}
+		}
+	}
+}
+
+// payload returns the Field value from the field/value list of a stream
+// entry.
+func (b *Bridge) payload(fields interface{}) string {
+	kv, _ := fields.([]interface{})
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i] == b.field() {
+			s, _ := kv[i+1].(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// handle evaluates one message and republishes it when it matches. Messages
+// that cannot be evaluated are counted and skipped; only publish failures
+// are returned.
+func (b *Bridge) handle(out *conn, payload string, opts []any) error {
+	b.stats.received.Add(1)
+	var v interface{}
+	if err := json.Unmarshal([]byte(payload), &v); err != nil {
+		b.stats.skipped.Add(1)
+		b.logf("skipping message from %s: %v", b.From, err)
+		return nil
+	}
+	if b.Query.Expression != nil {
+		ok, err := b.Query.Evaluate(v, opts...)
+		if err != nil {
+			b.stats.skipped.Add(1)
+			b.logf("skipping message from %s: %v", b.From, err)
+			return nil
+		}
+		if !ok {
+			return nil
+		}
+	}
+	var err error
+	if b.To.Stream {
+		_, err = out.do("XADD", b.To.Name, "*", b.field(), payload)
+	} else {
+		_, err = out.do("PUBLISH", b.To.Name, payload)
+	}
+	if err != nil {
+		return fmt.Errorf("publish to %s: %w", b.To, err)
+	}
+	b.stats.matched.Add(1)
+	return nil
+}
+
+func (b *Bridge) field() string {
+	if b.Field == "" {
+		return "data"
+	}
+	return b.Field
+}
+
+func (b *Bridge) backoff() time.Duration {
+	if b.Backoff <= 0 {
+		return 100 * time.Millisecond
+	}
+	return b.Backoff
+}
+
+func (b *Bridge) maxBackoff() time.Duration {
+	if b.MaxBackoff <= 0 {
+		return 30 * time.Second
+	}
+	return b.MaxBackoff
+}
+
+func (b *Bridge) logf(format string, args ...any) {
+	if b.Logf != nil {
+		b.Logf(format, args...)
+	}
+}
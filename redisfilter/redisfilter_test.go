@@ -0,0 +1,392 @@
+package redisfilter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// fakeRedis implements the handful of commands a Bridge uses.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	events   chan string
+
+	mu      sync.Mutex
+	changed *sync.Cond
+	seq     int
+	streams map[string][][]interface{}
+	subs    map[string][]*fakeConn
+	conns   map[*fakeConn]bool
+}
+
+type fakeConn struct {
+	c      net.Conn
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closed bool
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{
+		ln:       ln,
+		password: password,
+		events:   make(chan string, 100),
+		streams:  map[string][][]interface{}{},
+		subs:     map[string][]*fakeConn{},
+		conns:    map[*fakeConn]bool{},
+	}
+	f.changed = sync.NewCond(&f.mu)
+	t.Cleanup(func() {
+		_ = ln.Close()
+		f.drop()
+	})
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		fc := &fakeConn{c: c, w: bufio.NewWriter(c)}
+		f.mu.Lock()
+		f.conns[fc] = true
+		f.mu.Unlock()
+		go f.handle(fc)
+	}
+}
+
+// drop closes every client connection.
+func (f *fakeRedis) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for fc := range f.conns {
+		fc.closed = true
+		_ = fc.c.Close()
+	}
+	f.conns = map[*fakeConn]bool{}
+	f.subs = map[string][]*fakeConn{}
+	f.changed.Broadcast()
+}
+
+func (f *fakeRedis) handle(fc *fakeConn) {
+	in := &conn{c: fc.c, r: bufio.NewReader(fc.c)}
+	authed := f.password == ""
+	for {
+		v, err := in.read()
+		if err != nil {
+			return
+		}
+		items, _ := v.([]interface{})
+		args := make([]string, len(items))
+		for i, it := range items {
+			args[i], _ = it.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+		if args[0] == "AUTH" {
+			if args[1] != f.password {
+				fc.write(Error("WRONGPASS invalid password"))
+				continue
+			}
+			authed = true
+			fc.write("OK")
+			continue
+		}
+		if !authed {
+			fc.write(Error("NOAUTH Authentication required"))
+			continue
+		}
+		fc.write(f.command(fc, args))
+	}
+}
+
+// command runs one command, reporting it on events once it has taken effect
+// or, for XREAD, before it blocks.
+func (f *fakeRedis) command(fc *fakeConn, args []string) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if args[0] != "XREAD" {
+		defer f.notify(args[0])
+	}
+	switch args[0] {
+	case "SUBSCRIBE":
+		f.subs[args[1]] = append(f.subs[args[1]], fc)
+		return []interface{}{"subscribe", args[1], int64(1)}
+	case "PUBLISH":
+		for _, sub := range f.subs[args[1]] {
+			go sub.write([]interface{}{"message", args[1], args[2]})
+		}
+		return int64(len(f.subs[args[1]]))
+	case "XADD":
+		return f.xaddLocked(args[1], args[3:]...)
+	case "XREVRANGE":
+		entries := f.streams[args[1]]
+		if len(entries) == 0 {
+			return []interface{}{}
+		}
+		return []interface{}{entries[len(entries)-1]}
+	case "XREAD":
+		key, after := args[6], seqOf(args[7])
+		f.notify(args[0])
+		for {
+			var out []interface{}
+			for _, e := range f.streams[key] {
+				if seqOf(e[0].(string)) > after {
+					out = append(out, e)
+				}
+			}
+			if len(out) > 0 {
+				return []interface{}{[]interface{}{key, out}}
+			}
+			if fc.closed {
+				return nil
+			}
+			f.changed.Wait()
+		}
+	}
+	return Error("ERR unknown command " + args[0])
+}
+
+func (f *fakeRedis) notify(cmd string) {
+	select {
+	case f.events <- cmd:
+	default:
+	}
+}
+
+func (f *fakeRedis) xadd(key string, fields ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.xaddLocked(key, fields...)
+}
+
+func (f *fakeRedis) xaddLocked(key string, fields ...string) string {
+	f.seq++
+	id := fmt.Sprintf("%d-0", f.seq)
+	kv := make([]interface{}, len(fields))
+	for i, s := range fields {
+		kv[i] = s
+	}
+	f.streams[key] = append(f.streams[key], []interface{}{id, kv})
+	f.changed.Broadcast()
+	return id
+}
+
+func (f *fakeRedis) publish(channel, payload string) {
+	f.command(nil, []string{"PUBLISH", channel, payload})
+}
+
+// payloads returns the data field of each entry in a stream.
+func (f *fakeRedis) payloads(key string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, e := range f.streams[key] {
+		kv := e[1].([]interface{})
+		out = append(out, kv[1].(string))
+	}
+	return out
+}
+
+// await waits until the server receives the named command.
+func (f *fakeRedis) await(t *testing.T, cmd string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-f.events:
+			if got == cmd {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", cmd)
+		}
+	}
+}
+
+func seqOf(id string) int {
+	n, _ := strconv.Atoi(strings.TrimSuffix(id, "-0"))
+	return n
+}
+
+func (fc *fakeConn) write(v interface{}) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	writeReply(fc.w, v)
+	_ = fc.w.Flush()
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("*-1\r\n")
+	case Error:
+		fmt.Fprintf(w, "-%s\r\n", string(v))
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, it := range v {
+			writeReply(w, it)
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func olderThan30() evaluator.Query {
+	return evaluator.Query{Expression: &evaluator.GreaterThanExpression{Field: "age", Value: 30}}
+}
+
+func TestBridgeStream(t *testing.T) {
+	f := newFakeRedis(t, "")
+	f.xadd("in", "data", `{"age": 90}`)
+	b := &Bridge{
+		Addr:    f.ln.Addr().String(),
+		From:    Endpoint{Stream: true, Name: "in"},
+		To:      Endpoint{Stream: true, Name: "out"},
+		Query:   olderThan30(),
+		Backoff: time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	f.await(t, "XREAD")
+	f.xadd("in", "data", `{"age": 40}`)
+	f.xadd("in", "other", "x", "data", `{"age": 20}`)
+	f.xadd("in", "data", `not json`)
+	waitFor(t, "first batch", func() bool { return b.Stats().Received == 3 })
+
+	f.drop()
+	f.xadd("in", "data", `{"age": 50}`)
+	waitFor(t, "entry added while disconnected", func() bool { return len(f.payloads("out")) == 2 })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+
+	if got := f.payloads("out"); strings.Join(got, ",") != `{"age": 40},{"age": 50}` {
+		t.Errorf("unexpected output %q", got)
+	}
+	s := b.Stats()
+	if s.Received != 4 || s.Matched != 2 || s.Skipped != 1 || s.Reconnects < 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestBridgeChannel(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub, err := dial(ctx, f.ln.Addr().String(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if _, err := sub.do("SUBSCRIBE", "out"); err != nil {
+		t.Fatal(err)
+	}
+	f.await(t, "SUBSCRIBE")
+
+	b := &Bridge{
+		Addr:     f.ln.Addr().String(),
+		Password: "secret",
+		From:     Endpoint{Name: "in"},
+		To:       Endpoint{Name: "out"},
+		Query:    olderThan30(),
+	}
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	f.await(t, "SUBSCRIBE")
+	f.publish("in", `{"age": 20}`)
+	f.publish("in", `{"age": 35}`)
+
+	msg, err := sub.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := msg.([]interface{}); len(m) != 3 || m[2] != `{"age": 35}` {
+		t.Errorf("unexpected message %v", msg)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+}
+
+func TestBridgeRejectedPassword(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	b := &Bridge{Addr: f.ln.Addr().String(), Password: "wrong", From: Endpoint{Name: "in"}, To: Endpoint{Name: "out"}}
+	err := b.Run(context.Background())
+	var re Error
+	if !errors.As(err, &re) || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("expected a password error, got %v", err)
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	for in, want := range map[string]Endpoint{
+		"events":         {Name: "events"},
+		"channel:events": {Name: "events"},
+		"stream:orders":  {Stream: true, Name: "orders"},
+		"stream:a:b":     {Stream: true, Name: "a:b"},
+	} {
+		got, err := ParseEndpoint(in)
+		if err != nil || got != want {
+			t.Errorf("%s: got %+v %v, want %+v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "stream:", "queue:jobs"} {
+		if _, err := ParseEndpoint(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestReadLengthLimits(t *testing.T) {
+	for _, reply := range []string{
+		"$1000000000000\r\n",
+		"*1000000000000\r\n:1\r\n",
+	} {
+		c := &conn{r: bufio.NewReader(strings.NewReader(reply))}
+		if v, err := c.read(); err == nil {
+			t.Errorf("%q: expected an error, got %v", reply, v)
+		}
+	}
+	c := &conn{r: bufio.NewReader(strings.NewReader("*2\r\n$2\r\nhi\r\n:3\r\n"))}
+	if v, err := c.read(); err != nil || fmt.Sprint(v) != "[hi 3]" {
+		t.Errorf("got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,131 @@
+package redisfilter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Error is an error reply from the Redis server, such as a rejected
+// password or a command against a key of the wrong type. Reconnecting does
+// not help, so Bridge.Run returns it.
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// maxBulkLen is the largest bulk string read, the default
+// proto-max-bulk-len of a Redis server. Longer lengths are refused rather
+// than allocated.
+const maxBulkLen = 512 << 20
+
+// conn speaks RESP, the Redis serialization protocol, over a net.Conn.
+type conn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func dial(ctx context.Context, addr, password string) (*conn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	if password != "" {
+		if _, err := cn.do("AUTH", password); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *conn) Close() error {
+	return c.c.Close()
+}
+
+// do sends a command and reads its reply.
+func (c *conn) do(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *conn) send(args ...string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return c.w.Flush()
+}
+
+// read returns the next reply: a string for simple and bulk strings, an
+// int64, a []interface{} for arrays or nil for null replies. Error replies
+// are returned as Error.
+func (c *conn) read() (interface{}, error) {
+	line, err := c.line()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+	body := line[1:]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if n > maxBulkLen {
+			return nil, fmt.Errorf("redis: bulk string of %d bytes exceeds %d", n, maxBulkLen)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// Grow the array as elements arrive, so a bogus length cannot
+		// allocate more than the data actually sent.
+		items := make([]interface{}, 0, min(n, 1024))
+		for range n {
+			item, err := c.read()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func (c *conn) line() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed reply %q", line)
+	}
+	return line[:len(line)-2], nil
+}
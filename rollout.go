@@ -0,0 +1,76 @@
+package evaluator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+)
+
+// hashKey returns a stable hash of the string form of v combined with salt.
+// The result is identical across processes and platforms.
+func hashKey(salt string, v interface{}) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(salt))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(stringValue(v)))
+	return h.Sum64()
+}
+
+// RolloutExpression matches a consistent Percent (0-100) of records based on
+// a hash of KeyField. The same key always gets the same result for a given
+// Salt, so raising Percent only ever adds keys to the rollout. Changing Salt
+// selects an independent population.
+type RolloutExpression struct {
+	KeyField string
+	Percent  float64
+	Salt     string
+}
+
+func (e RolloutExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.KeyField)
+	if !ok || !f.IsValid() {
+		return false, nil
+	}
+	switch f.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if f.IsNil() {
+			return false, nil
+		}
+	}
+	// Work in basis points so fractional percentages are honoured.
+	bucket := hashKey(e.Salt, f.Interface()) % 10000
+	return float64(bucket) < e.Percent*100, nil
+}
+
+// BucketTerm assigns a record to one of Buckets buckets numbered from 0 using
+// a stable hash of KeyField, for A/B style experiments. It evaluates to nil
+// when the key field is missing or nil.
+type BucketTerm struct {
+	KeyField string
+	Buckets  int
+}
+
+func (b BucketTerm) Evaluate(i interface{}, _ ...any) (interface{}, error) {
+	if b.Buckets <= 0 {
+		return nil, fmt.Errorf("bucket count must be positive, got %d", b.Buckets)
+	}
+	v, ok := derefValue(i)
+	if !ok {
+		return nil, nil
+	}
+	f, ok := getField(v, b.KeyField)
+	if !ok || !f.IsValid() {
+		return nil, nil
+	}
+	switch f.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if f.IsNil() {
+			return nil, nil
+		}
+	}
+	return int(hashKey("", f.Interface()) % uint64(b.Buckets)), nil
+}
@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestRolloutExpression(t *testing.T) {
+	matched := func(e RolloutExpression, n int) map[int]bool {
+		m := map[int]bool{}
+		for id := 0; id < n; id++ {
+			v, err := e.Evaluate(map[string]interface{}{"UserID": id})
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			if v {
+				m[id] = true
+			}
+		}
+		return m
+	}
+
+	ten := matched(RolloutExpression{KeyField: "UserID", Percent: 10, Salt: "feature"}, 10000)
+	if len(ten) < 900 || len(ten) > 1100 {
+		t.Errorf("expected roughly 10%% of keys, got %d", len(ten))
+	}
+	fifty := matched(RolloutExpression{KeyField: "UserID", Percent: 50, Salt: "feature"}, 10000)
+	for id := range ten {
+		if !fifty[id] {
+			t.Fatalf("key %d dropped out when the rollout grew", id)
+		}
+	}
+	if n := len(matched(RolloutExpression{KeyField: "UserID", Percent: 0}, 1000)); n != 0 {
+		t.Errorf("0%% rollout matched %d keys", n)
+	}
+	if n := len(matched(RolloutExpression{KeyField: "UserID", Percent: 100}, 1000)); n != 1000 {
+		t.Errorf("100%% rollout matched %d keys", n)
+	}
+	if v, err := (RolloutExpression{KeyField: "Missing", Percent: 100}).Evaluate(map[string]interface{}{}); err != nil || v {
+		t.Errorf("missing key should not match: %v %v", v, err)
+	}
+}
+
+func TestRolloutExpressionStableHash(t *testing.T) {
+	// These values pin the hash so results stay consistent across releases.
+	e := RolloutExpression{KeyField: "UserID", Percent: 50, Salt: "s"}
+	var got string
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		v, _ := e.Evaluate(map[string]interface{}{"UserID": id})
+		got += fmt.Sprint(v, " ")
+	}
+	q := Query{Expression: &e}
+	b, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var q2 Query
+	if err := json.Unmarshal(b, &q2); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var got2 string
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		v, _ := q2.Evaluate(map[string]interface{}{"UserID": id})
+		got2 += fmt.Sprint(v, " ")
+	}
+	if want := "true false true false "; got != want || got2 != want {
+		t.Errorf("expected %q, got %q and %q after round trip", want, got, got2)
+	}
+}
+
+func TestBucketTerm(t *testing.T) {
+	b := BucketTerm{KeyField: "UserID", Buckets: 4}
+	seen := map[interface{}]int{}
+	for id := 0; id < 400; id++ {
+		rec := map[string]interface{}{"UserID": id}
+		v1, err := b.Evaluate(rec)
+		if err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+		v2, _ := b.Evaluate(rec)
+		if v1 != v2 {
+			t.Fatalf("bucket for %d not deterministic: %v vs %v", id, v1, v2)
+		}
+		seen[v1]++
+	}
+	if len(seen) != 4 {
+		t.Errorf("expected 4 buckets, got %v", seen)
+	}
+	if v, err := b.Evaluate(map[string]interface{}{}); err != nil || v != nil {
+		t.Errorf("missing key should give nil bucket: %v %v", v, err)
+	}
+	if v, err := b.Evaluate(map[string]interface{}{"UserID": nil}); err != nil || v != nil {
+		t.Errorf("nil key should give nil bucket: %v %v", v, err)
+	}
+	if v, err := b.Evaluate(struct{ UserID *int }{}); err != nil || v != nil {
+		t.Errorf("nil pointer key should give nil bucket: %v %v", v, err)
+	}
+	if _, err := (BucketTerm{KeyField: "UserID"}).Evaluate(map[string]interface{}{"UserID": 1}); err == nil {
+		t.Errorf("expected error for zero buckets")
+	}
+}
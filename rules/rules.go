@@ -0,0 +1,262 @@
+// Package rules groups named queries into rule sets that can be loaded from a
+// shared rules file and evaluated together.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/store"
+)
+
+// Rule is a named query that can be switched on and off.
+type Rule struct {
+	Name     string          `json:"Name"`
+	Query    evaluator.Query `json:"Query"`
+	Disabled bool            `json:"Disabled,omitempty"`
+	// ActiveFrom and ActiveUntil bound the period in which the rule applies.
+	// ActiveUntil is exclusive.
+	ActiveFrom  *time.Time `json:"ActiveFrom,omitempty"`
+	ActiveUntil *time.Time `json:"ActiveUntil,omitempty"`
+	// Windows restricts the rule to times matching at least one cron-style
+	// spec ("minute hour day-of-month month day-of-week"), for example
+	// "* 9-17 * * 1-5" for office hours.
+	Windows []string `json:"Windows,omitempty"`
+	// ACL overrides the rule set ACL for this rule when set.
+	ACL *store.ACL `json:"ACL,omitempty"`
+
+	// windows caches Windows parsed by Load and Put.
+	windows []window
+}
+
+// RuleSet is an ordered collection of rules sharing an owner.
+type RuleSet struct {
+	Name  string    `json:"Name"`
+	ACL   store.ACL `json:"ACL"`
+	Rules []Rule    `json:"Rules"`
+	// OnEvalError is the policy for rules whose queries fail to evaluate. A
+	// rule's own Query.OnEvalError takes precedence.
+	OnEvalError evaluator.ErrorPolicy `json:"OnEvalError,omitempty"`
+	// Audit receives an event for every attempted change when set.
+	Audit store.AuditFunc `json:"-"`
+	// Clock returns the current time for audit events, and for activation
+	// when the options passed to Evaluate carry no evaluator.Context clock.
+	// It defaults to time.Now.
+	Clock func() time.Time `json:"-"`
+}
+
+// Load decodes a JSON rules file, rejecting rules whose windows do not parse.
+func Load(r io.Reader) (*RuleSet, error) {
+	var rs RuleSet
+	if err := json.NewDecoder(r).Decode(&rs); err != nil {
+		return nil, err
+	}
+	if err := rs.Compile(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// Compile parses the windows of every rule, returning the first that does not
+// parse, and caches them for Evaluate. Load compiles the rule sets it
+// returns; rule sets decoded or built otherwise should be compiled before
+// use.
+func (rs *RuleSet) Compile() error {
+	for i := range rs.Rules {
+		if err := rs.Rules[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile parses and caches the windows of the rule.
+func (r *Rule) compile() error {
+	ws, err := r.parseWindows()
+	if err != nil {
+		return err
+	}
+	r.windows = ws
+	return nil
+}
+
+func (r *Rule) parseWindows() ([]window, error) {
+	ws := make([]window, len(r.Windows))
+	for i, spec := range r.Windows {
+		w, err := parseWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		ws[i] = w
+	}
+	return ws, nil
+}
+
+// compiledWindows returns the cached windows while they still match Windows,
+// and otherwise parses Windows afresh without caching them, so that Active
+// does not write to a rule that may be evaluated concurrently.
+func (r *Rule) compiledWindows() ([]window, error) {
+	if len(r.windows) == len(r.Windows) {
+		fresh := true
+		for i, w := range r.windows {
+			fresh = fresh && w.spec == r.Windows[i]
+		}
+		if fresh {
+			return r.windows, nil
+		}
+	}
+	return r.parseWindows()
+}
+
+func (rs *RuleSet) now() time.Time {
+	if rs.Clock != nil {
+		return rs.Clock()
+	}
+	return time.Now()
+}
+
+// Active reports whether the rule is enabled and its activation period and
+// windows include now.
+func (r *Rule) Active(now time.Time) (bool, error) {
+	if r.Disabled {
+		return false, nil
+	}
+	if r.ActiveFrom != nil && now.Before(*r.ActiveFrom) {
+		return false, nil
+	}
+	if r.ActiveUntil != nil && !now.Before(*r.ActiveUntil) {
+		return false, nil
+	}
+	if len(r.Windows) == 0 {
+		return true, nil
+	}
+	ws, err := r.compiledWindows()
+	if err != nil {
+		return false, err
+	}
+	for _, w := range ws {
+		if w.matches(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Evaluate returns the names of the active rules matching i. The current time
+// is taken from the evaluator.Context clock found in opts, or from Clock.
+func (rs *RuleSet) Evaluate(i interface{}, opts ...any) ([]string, error) {
+	var now time.Time
+	if ctx := evaluator.GetContext(opts...); ctx.Clock != nil {
+		now = ctx.Clock()
+	} else {
+		now = rs.now()
+	}
+	if rs.OnEvalError != evaluator.FailError {
+		opts = append(opts[:len(opts):len(opts)], rs.OnEvalError)
+	}
+	var matched []string
+	for idx := range rs.Rules {
+		r := &rs.Rules[idx]
+		active, err := r.Active(now)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			continue
+		}
+		ok, err := r.Query.Evaluate(i, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		if ok {
+			matched = append(matched, r.Name)
+		}
+	}
+	return matched, nil
+}
+
+func (rs *RuleSet) find(name string) int {
+	for i := range rs.Rules {
+		if rs.Rules[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (rs *RuleSet) authorize(principal string, act store.Action, rule string, acl store.ACL) error {
+	allowed := acl.Allows(principal, act)
+	if rs.Audit != nil {
+		rs.Audit(store.AuditEvent{Time: rs.now(), Principal: principal, Action: act, Name: rs.Name + "/" + rule, Allowed: allowed})
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s may not %s %s/%s", store.ErrPermissionDenied, principal, act, rs.Name, rule)
+	}
+	return nil
+}
+
+func (rs *RuleSet) aclFor(idx int) store.ACL {
+	if idx >= 0 && rs.Rules[idx].ACL != nil {
+		return *rs.Rules[idx].ACL
+	}
+	return rs.ACL
+}
+
+// Get returns the named rule when principal may read it.
+func (rs *RuleSet) Get(principal, name string) (Rule, error) {
+	idx := rs.find(name)
+	if idx < 0 {
+		return Rule{}, fmt.Errorf("rule %s not found", name)
+	}
+	if !rs.aclFor(idx).Allows(principal, store.ActionRead) {
+		return Rule{}, fmt.Errorf("%w: %s may not %s %s/%s", store.ErrPermissionDenied, principal, store.ActionRead, rs.Name, name)
+	}
+	return rs.Rules[idx], nil
+}
+
+// Put adds or replaces a rule when principal may modify it and its windows
+// parse.
+func (rs *RuleSet) Put(principal string, r Rule) error {
+	idx := rs.find(r.Name)
+	if err := rs.authorize(principal, store.ActionModify, r.Name, rs.aclFor(idx)); err != nil {
+		return err
+	}
+	if err := r.compile(); err != nil {
+		return err
+	}
+	if idx < 0 {
+		rs.Rules = append(rs.Rules, r)
+		return nil
+	}
+	rs.Rules[idx] = r
+	return nil
+}
+
+// Remove deletes a rule when principal may modify it.
+func (rs *RuleSet) Remove(principal, name string) error {
+	idx := rs.find(name)
+	if idx < 0 {
+		return fmt.Errorf("rule %s not found", name)
+	}
+	if err := rs.authorize(principal, store.ActionModify, name, rs.aclFor(idx)); err != nil {
+		return err
+	}
+	rs.Rules = append(rs.Rules[:idx], rs.Rules[idx+1:]...)
+	return nil
+}
+
+// SetEnabled switches a rule on or off when principal may enable it.
+func (rs *RuleSet) SetEnabled(principal, name string, enabled bool) error {
+	idx := rs.find(name)
+	if idx < 0 {
+		return fmt.Errorf("rule %s not found", name)
+	}
+	if err := rs.authorize(principal, store.ActionEnable, name, rs.aclFor(idx)); err != nil {
+		return err
+	}
+	rs.Rules[idx].Disabled = !enabled
+	return nil
+}
@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/store"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+const rulesFile = `{
+  "Name": "routing",
+  "ACL": {"Owner": "alice", "Enablers": ["ops"]},
+  "Rules": [
+    {"Name": "bob", "Query": {"Expression": {"Type": "Is", "Expression": {"Field": "Name", "Value": "bob"}}}},
+    {"Name": "adult", "Query": {"Expression": {"Type": "GTE", "Expression": {"Field": "Age", "Value": 18}}}, "Disabled": true}
+  ]
+}`
+
+func TestRuleSetEvaluate(t *testing.T) {
+	rs, err := Load(strings.NewReader(rulesFile))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	got, err := rs.Evaluate(&user{Name: "bob", Age: 30})
+	if err != nil || !reflect.DeepEqual(got, []string{"bob"}) {
+		t.Fatalf("evaluate: %v %v", got, err)
+	}
+}
+
+func TestRuleSetACL(t *testing.T) {
+	rs, err := Load(strings.NewReader(rulesFile))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var events []store.AuditEvent
+	rs.Audit = func(e store.AuditEvent) { events = append(events, e) }
+
+	if err := rs.SetEnabled("mallory", "adult", true); !errors.Is(err, store.ErrPermissionDenied) {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+	if err := rs.SetEnabled("ops", "adult", true); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if err := rs.Remove("ops", "bob"); !errors.Is(err, store.ErrPermissionDenied) {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+	if err := rs.Remove("alice", "bob"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	got, err := rs.Evaluate(&user{Name: "bob", Age: 30})
+	if err != nil || !reflect.DeepEqual(got, []string{"adult"}) {
+		t.Errorf("evaluate: %v %v", got, err)
+	}
+	if len(events) != 4 || events[0].Allowed || !events[1].Allowed || events[1].Name != "routing/adult" {
+		t.Errorf("unexpected audit events: %+v", events)
+	}
+}
+
+func TestRuleActivation(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	rs := &RuleSet{Rules: []Rule{
+		{Name: "march", ActiveFrom: &from, ActiveUntil: &until},
+		{Name: "office", Windows: []string{"* 9-17 * * 1-5"}},
+	}}
+	for i := range rs.Rules {
+		rs.Rules[i].Query = evaluator.Query{Expression: &evaluator.IsExpression{Field: "Name", Value: "bob"}}
+	}
+	cases := []struct {
+		now  time.Time
+		want []string
+	}{
+		{time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC), []string{"office"}}, // Thursday, before March
+		{time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), []string{"march"}},   // Saturday
+		{time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC), []string{"march"}},   // Monday evening
+		{time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC), []string{"march", "office"}},
+		{time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), nil}, // Monday midnight, expired
+	}
+	for _, c := range cases {
+		ctx := &evaluator.Context{Clock: func() time.Time { return c.now }}
+		got, err := rs.Evaluate(&user{Name: "bob"}, ctx)
+		if err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: expected %v, got %v", c.now, c.want, got)
+		}
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	w, err := parseWindow("*/15 0 1,15 * 0")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	// 15 March 2024 is a Friday: day of month matches even though day of week does not.
+	if !w.matches(time.Date(2024, 3, 15, 0, 30, 0, 0, time.UTC)) {
+		t.Errorf("expected match on day of month")
+	}
+	if w.matches(time.Date(2024, 3, 15, 0, 31, 0, 0, time.UTC)) {
+		t.Errorf("unexpected match on minute 31")
+	}
+	w, err = parseWindow("5/15 * * * *")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for minute, want := range map[int]bool{5: true, 20: true, 50: true, 0: false, 6: false} {
+		if got := w.matches(time.Date(2024, 3, 15, 0, minute, 0, 0, time.UTC)); got != want {
+			t.Errorf("5/15 at minute %d: got %v, want %v", minute, got, want)
+		}
+	}
+	// A stepped star still restricts both day fields together: 1 March 2024
+	// is an odd day but a Friday, and 11 March 2024 is an odd Monday.
+	w, err = parseWindow("0 0 */2 * 1")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if w.matches(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected match on a Friday")
+	}
+	if !w.matches(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected match on an odd Monday")
+	}
+	for _, bad := range []string{"* * *", "60 * * * *", "* * * * mon", "*/0 * * * *", "60/5 * * * *"} {
+		if _, err := parseWindow(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestRuleSetOnEvalError(t *testing.T) {
+	rs, err := Load(strings.NewReader(`{
+  "Name": "security",
+  "OnEvalError": "fail-open",
+  "Rules": [
+    {"Name": "bad", "Query": {"Expression": {"Type": "Matches", "Expression": {"Field": "Name", "Pattern": "("}}}},
+    {"Name": "strict", "Query": {"OnEvalError": "fail-closed", "Expression": {"Type": "Matches", "Expression": {"Field": "Name", "Pattern": "("}}}}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	got, err := rs.Evaluate(&user{Name: "bob"})
+	if err != nil || !reflect.DeepEqual(got, []string{"bad"}) {
+		t.Fatalf("evaluate: %v %v", got, err)
+	}
+	rs.OnEvalError = evaluator.FailError
+	if _, err := rs.Evaluate(&user{Name: "bob"}); err == nil {
+		t.Errorf("expected error without a policy")
+	}
+}
+
+func TestRuleSetWindowsValidated(t *testing.T) {
+	_, err := Load(strings.NewReader(`{"Name": "routing", "Rules": [{"Name": "bad", "Windows": ["* 25 * * *"]}]}`))
+	if err == nil || !strings.Contains(err.Error(), "rule bad") {
+		t.Errorf("expected Load to reject the window, got %v", err)
+	}
+	rs, err := Load(strings.NewReader(`{"Name": "routing", "ACL": {"Owner": "alice"}, "Rules": [{"Name": "office", "Windows": ["* 9-17 * * 1-5"]}]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(rs.Rules[0].windows) != 1 {
+		t.Errorf("expected Load to cache the parsed window, got %+v", rs.Rules[0].windows)
+	}
+	if err := rs.Put("alice", Rule{Name: "bad", Windows: []string{"* * *"}}); err == nil {
+		t.Errorf("expected Put to reject the window")
+	}
+	if len(rs.Rules) != 1 {
+		t.Errorf("rejected rule was added: %+v", rs.Rules)
+	}
+}
+
+func TestRuleSetClock(t *testing.T) {
+	at := time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC) // Monday morning
+	rs := &RuleSet{
+		Name:  "routing",
+		ACL:   store.ACL{Owner: "alice"},
+		Rules: []Rule{{Name: "office", Windows: []string{"* 9-17 * * 1-5"}}},
+		Clock: func() time.Time { return at },
+	}
+	rs.Rules[0].Query = evaluator.Query{Expression: &evaluator.IsExpression{Field: "Name", Value: "bob"}}
+	var events []store.AuditEvent
+	rs.Audit = func(e store.AuditEvent) { events = append(events, e) }
+
+	if got, err := rs.Evaluate(&user{Name: "bob"}); err != nil || !reflect.DeepEqual(got, []string{"office"}) {
+		t.Errorf("evaluate: %v %v", got, err)
+	}
+	if err := rs.SetEnabled("alice", "office", false); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if len(events) != 1 || !events[0].Time.Equal(at) {
+		t.Errorf("expected the audit event at %v, got %+v", at, events)
+	}
+}
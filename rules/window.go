@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// window is a parsed cron-style activation window made of five fields:
+// minute, hour, day of month, month and day of week. A time is inside the
+// window when every field matches it, using the usual cron rule that a
+// restricted day of month or day of week may match on its own.
+type window struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var windowBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+func parseWindow(spec string) (window, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return window{}, fmt.Errorf("window %q: expected 5 fields", spec)
+	}
+	var sets [5]uint64
+	for i, p := range parts {
+		set, err := parseWindowField(p, windowBounds[i][0], windowBounds[i][1])
+		if err != nil {
+			return window{}, fmt.Errorf("window %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+	// Sunday may be written as 0 or 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return window{
+		spec:   spec,
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domStar: strings.HasPrefix(parts[2], "*"), dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseWindowField parses a comma separated list of values, ranges (a-b) and
+// steps (*/n, a-b/n or a/n, which runs from a to the end of the range) into
+// a bit set.
+func parseWindowField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		i := strings.IndexByte(part, '/')
+		if i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		start, end := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			end = start
+			if i >= 0 && !isRange {
+				end = hi
+			}
+			if isRange {
+				if end, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (w window) matches(t time.Time) bool {
+	has := func(set uint64, v int) bool { return set&(1<<uint(v)) != 0 }
+	if !has(w.minute, t.Minute()) || !has(w.hour, t.Hour()) || !has(w.month, int(t.Month())) {
+		return false
+	}
+	domOK, dowOK := has(w.dom, t.Day()), has(w.dow, int(t.Weekday()))
+	if w.domStar || w.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
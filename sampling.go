@@ -0,0 +1,37 @@
+package evaluator
+
+import "math/rand/v2"
+
+// RandomTerm evaluates to a new pseudo-random float64 in [0, 1) on every
+// evaluation, for example to sample records with random() < 0.1. The numbers
+// come from the Context Rand source when one is set, which makes sampling
+// reproducible; otherwise the global generator is used.
+type RandomTerm struct{}
+
+func (RandomTerm) Evaluate(_ interface{}, opts ...any) (interface{}, error) {
+	if r := GetContext(opts...).Rand; r != nil {
+		return r.Float64(), nil
+	}
+	return rand.Float64(), nil
+}
+
+// NewSeededRand returns a deterministic random source for Context.Rand.
+func NewSeededRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// HashTerm evaluates to a stable, non-negative integer hash of the value of
+// Term combined with Salt. Combined with ModTerm it gives deterministic
+// sampling such as hash(UserID) % 10 is 3. It evaluates to nil when Term does.
+type HashTerm struct {
+	Term Term
+	Salt string
+}
+
+func (h HashTerm) Evaluate(i interface{}, opts ...any) (interface{}, error) {
+	v, err := evalTerm(h.Term, i, opts...)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return int64(hashKey(h.Salt, v) >> 1), nil
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import "testing"
+
+func TestRandomTermSeeded(t *testing.T) {
+	draw := func(opts ...any) []interface{} {
+		var out []interface{}
+		for i := 0; i < 5; i++ {
+			v, err := RandomTerm{}.Evaluate(nil, opts...)
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			f := v.(float64)
+			if f < 0 || f >= 1 {
+				t.Fatalf("random value out of range: %v", f)
+			}
+			out = append(out, v)
+		}
+		return out
+	}
+	a := draw(&Context{Rand: NewSeededRand(42)})
+	b := draw(&Context{Rand: NewSeededRand(42)})
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("seeded sequences differ: %v vs %v", a, b)
+		}
+	}
+	if a[0] == a[1] {
+		t.Errorf("expected a new value per evaluation: %v", a)
+	}
+	draw()
+}
+
+func TestHashModSampling(t *testing.T) {
+	q := Query{Expression: &ComparisonExpression{
+		LHS:       ModTerm{LHS: HashTerm{Term: Field{Name: "UserID"}}, RHS: Constant{Value: 10}},
+		RHS:       Constant{Value: 3},
+		Operation: "eq",
+	}}
+	n := 0
+	for id := 0; id < 1000; id++ {
+		rec := map[string]interface{}{"UserID": id}
+		v1, err := q.Evaluate(rec)
+		if err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+		v2, _ := q.Evaluate(rec)
+		if v1 != v2 {
+			t.Fatalf("hash sampling not deterministic for %d", id)
+		}
+		if v1 {
+			n++
+		}
+	}
+	if n < 50 || n > 150 {
+		t.Errorf("expected roughly a tenth of records, got %d", n)
+	}
+}
@@ -0,0 +1,73 @@
+package evaluator
+
+// ScoreExpression is implemented by expressions that rate a record rather
+// than only match it, for ranking records by relevance.
+type ScoreExpression interface {
+	Score(i interface{}, opts ...any) (float64, error)
+}
+
+// WeightedExpression scores Weight when Query matches and 0 otherwise.
+// Negative weights act as penalties.
+type WeightedExpression struct {
+	Query  Query
+	Weight float64
+}
+
+func (w WeightedExpression) Score(i interface{}, opts ...any) (float64, error) {
+	ok, err := w.Query.Evaluate(i, opts...)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return w.Weight, nil
+}
+
+// ScoreQuery sums the weights of the Expressions that match a record and
+// succeeds when the total reaches Threshold, so that a record can qualify by
+// meeting enough of several criteria rather than all or any of them.
+// Query.Score returns the total itself for ranking.
+type ScoreQuery struct {
+	Expressions []WeightedExpression
+	Threshold   float64
+}
+
+func (s ScoreQuery) Score(i interface{}, opts ...any) (float64, error) {
+	total := 0.0
+	for _, w := range s.Expressions {
+		n, err := w.Score(i, opts...)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (s ScoreQuery) Evaluate(i interface{}, opts ...any) (bool, error) {
+	total, err := s.Score(i, opts...)
+	if err != nil {
+		return false, err
+	}
+	return total >= s.Threshold, nil
+}
+
+// Score rates i against q. Expressions implementing ScoreExpression, such
+// as ScoreQuery, give their score and others 1 when they match and 0 when
+// they do not, so queries of either kind can rank records. The record, error
+// policy and nesting limit are handled as by Query.Evaluate.
+func (q *Query) Score(i interface{}, opts ...any) (float64, error) {
+	return evaluateQuery(q, i, opts, func(e Expression, i interface{}, opts []any) (float64, error) {
+		if s, ok := e.(ScoreExpression); ok {
+			return s.Score(i, opts...)
+		}
+		ok, err := e.Evaluate(i, opts...)
+		if err != nil || !ok {
+			return 0, err
+		}
+		return 1, nil
+	}, func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	})
+}
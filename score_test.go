@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestScoreQuery(t *testing.T) {
+	q := Query{Expression: &ScoreQuery{
+		Expressions: []WeightedExpression{
+			{Query: Query{Expression: &IsExpression{Field: "Country", Value: "NZ"}}, Weight: 2},
+			{Query: Query{Expression: &GreaterThanExpression{Field: "Age", Value: 30}}, Weight: 1.5},
+			{Query: Query{Expression: &IsExpression{Field: "Banned", Value: true}}, Weight: -5},
+		},
+		Threshold: 3,
+	}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"Score","Expression":{"Expressions":[{"Query":{"Expression":{"Type":"Is","Expression":{"Field":"Country","Value":"NZ"}}},"Weight":2},{"Query":{"Expression":{"Type":"GT","Expression":{"Field":"Age","Value":30}}},"Weight":1.5},{"Query":{"Expression":{"Type":"Is","Expression":{"Field":"Banned","Value":true}}},"Weight":-5}],"Threshold":3}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		rec   map[string]interface{}
+		score float64
+		want  bool
+	}{
+		{map[string]interface{}{"Country": "NZ", "Age": 40}, 3.5, true},
+		{map[string]interface{}{"Country": "NZ", "Age": 20}, 2, false},
+		{map[string]interface{}{"Country": "AU", "Age": 40}, 1.5, false},
+		{map[string]interface{}{"Country": "NZ", "Age": 40, "Banned": true}, -1.5, false},
+		{map[string]interface{}{}, 0, false},
+	} {
+		score, err := back.Score(tt.rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score != tt.score {
+			t.Errorf("%v: score %v, want %v", tt.rec, score, tt.score)
+		}
+		got, err := back.Evaluate(tt.rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.rec, got, tt.want)
+		}
+	}
+}
+
+func TestQueryScore(t *testing.T) {
+	q := Query{Expression: &IsExpression{Field: "Country", Value: "NZ"}}
+	for country, want := range map[string]float64{"NZ": 1, "AU": 0} {
+		got, err := q.Score(map[string]interface{}{"Country": country})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %v, want %v", country, got, want)
+		}
+	}
+}
+
+func scoreOf(q Query) Query {
+	return Query{Expression: &ScoreQuery{Expressions: []WeightedExpression{{Query: q, Weight: 1}}, Threshold: 1}}
+}
+
+func TestScoreQueryDepthAndPolicy(t *testing.T) {
+	rec := map[string]interface{}{"A": 1}
+	q := Query{Expression: &IsExpression{Field: "A", Value: 1}}
+	for n := 0; n < 20; n++ {
+		q = scoreOf(q)
+	}
+	ctx := &Context{MaxDepth: 10}
+	if ok, err := q.Evaluate(rec, ctx); !errors.Is(err, ErrMaxDepth) || ok {
+		t.Errorf("Evaluate = %v, %v, want ErrMaxDepth", ok, err)
+	}
+	if _, err := q.Score(rec, ctx); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Score err = %v, want ErrMaxDepth", err)
+	}
+
+	failing := Query{Expression: &MatchesExpression{Field: "A", Pattern: "("}, OnEvalError: FailOpen}
+	if s, err := failing.Score(rec); err != nil || s != 1 {
+		t.Errorf("fail-open Score = %v, %v, want 1", s, err)
+	}
+	type record struct{ A int }
+	leaf := Query{Expression: &IsExpression{Field: "A", Value: 1}}
+	if s, err := leaf.Score(record{A: 1}, &Context{RequirePointer: true}); err != nil || s != 0 {
+		t.Errorf("RequirePointer Score = %v, %v, want 0", s, err)
+	}
+	if s, err := leaf.Score(map[string]interface{}{"a": 1}, &Context{FoldFields: true}); err != nil || s != 1 {
+		t.Errorf("FoldFields Score = %v, %v, want 1", s, err)
+	}
+}
+
+func TestScoreQueryWalkers(t *testing.T) {
+	is := func(f string) Query { return Query{Expression: &IsExpression{Field: f, Value: "x"}} }
+	and := func(qs ...Query) Query { return Query{Expression: &AndExpression{Expressions: qs}} }
+
+	a, _ := Hash(scoreOf(and(and(is("a"), is("b")), is("c"))))
+	b, _ := Hash(scoreOf(and(is("a"), and(is("b"), is("c")))))
+	if a != b {
+		t.Errorf("Hash does not flatten under ScoreQuery: %s != %s", a, b)
+	}
+
+	folded := FoldCase(scoreOf(is("a"))).Expression.(*ScoreQuery)
+	if !folded.Expressions[0].Query.Expression.(*IsExpression).Fold {
+		t.Errorf("FoldCase skipped the ScoreQuery")
+	}
+
+	if err := Validate(scoreOf(Query{Expression: &MatchesExpression{Field: "a", Pattern: "("}})); err == nil {
+		t.Errorf("Validate skipped the ScoreQuery")
+	}
+
+	opt := Optimize(scoreOf(and(is("a")))).Expression.(*ScoreQuery)
+	if _, ok := opt.Expressions[0].Query.Expression.(*IsExpression); !ok {
+		t.Errorf("Optimize skipped the ScoreQuery: %#v", opt.Expressions[0].Query.Expression)
+	}
+}
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Sealer encrypts and decrypts serialized query payloads. AESGCM provides a
+// local implementation; callers can implement Sealer themselves to delegate to
+// a key management service.
+type Sealer interface {
+	// Seal encrypts plaintext, authenticating additionalData alongside it.
+	Seal(plaintext, additionalData []byte) ([]byte, error)
+	// Open reverses Seal.
+	Open(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// AESGCM is a Sealer using AES-GCM with a caller-provided 16, 24 or 32 byte
+// key. A random nonce is generated per Seal and prepended to the ciphertext.
+type AESGCM struct {
+	Key []byte
+}
+
+func (a AESGCM) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(a.Key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (a AESGCM) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := a.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (a AESGCM) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := a.aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, data := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, additionalData)
+}
+
+// SealedQuery is the envelope produced by SealQuery. Type names the root
+// expression and, like Metadata, stays readable; the serialized query itself
+// is only available in encrypted form.
+type SealedQuery struct {
+	Type       string    `json:"Type"`
+	Metadata   *Metadata `json:"Metadata,omitempty"`
+	Ciphertext []byte    `json:"Ciphertext"`
+}
+
+// sealedData returns the additional data authenticated with a sealed query:
+// the readable parts of env and the name the query is stored under, so that
+// neither can be altered and an envelope cannot be replayed under another
+// name.
+func sealedData(env SealedQuery, name string) ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string
+		Metadata *Metadata
+		Name     string
+	}{env.Type, env.Metadata, name})
+}
+
+// SealQuery serializes q and encrypts it with s, returning the JSON encoded
+// SealedQuery envelope. name identifies the query, for example the name it is
+// stored under, and must be given again to OpenQuery.
+func SealQuery(q Query, name string, s Sealer) ([]byte, error) {
+	data, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	var hdr struct {
+		Expression struct{ Type string }
+	}
+	if err := json.Unmarshal(data, &hdr); err != nil {
+		return nil, err
+	}
+	env := SealedQuery{Type: hdr.Expression.Type, Metadata: q.Metadata}
+	ad, err := sealedData(env, name)
+	if err != nil {
+		return nil, err
+	}
+	env.Ciphertext, err = s.Seal(data, ad)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// IsSealed reports whether data holds a SealedQuery envelope rather than a
+// plain Query.
+func IsSealed(data []byte) bool {
+	var hdr struct {
+		Ciphertext json.RawMessage
+	}
+	return json.Unmarshal(data, &hdr) == nil && len(hdr.Ciphertext) > 0
+}
+
+// OpenQuery decrypts a SealedQuery envelope produced by SealQuery under the
+// same name. It fails if the envelope's Type or Metadata have been altered.
+func OpenQuery(data []byte, name string, s Sealer) (Query, error) {
+	var env SealedQuery
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Query{}, err
+	}
+	ad, err := sealedData(env, name)
+	if err != nil {
+		return Query{}, err
+	}
+	plain, err := s.Open(env.Ciphertext, ad)
+	if err != nil {
+		return Query{}, fmt.Errorf("open sealed query: %w", err)
+	}
+	var q Query
+	if err := json.Unmarshal(plain, &q); err != nil {
+		return Query{}, err
+	}
+	return q, nil
+}
@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSealQueryRoundTrip(t *testing.T) {
+	s := AESGCM{Key: bytes.Repeat([]byte{7}, 32)}
+	q := Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &IsExpression{Field: "Name", Value: "bob"}},
+		{Expression: &GreaterThanExpression{Field: "Age", Value: 30}},
+	}}}
+	data, err := SealQuery(q, "adults", s)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(data, []byte("bob")) {
+		t.Errorf("sealed payload leaks plaintext: %s", data)
+	}
+	if !IsSealed(data) {
+		t.Errorf("expected sealed envelope")
+	}
+	var env SealedQuery
+	if err := json.Unmarshal(data, &env); err != nil || env.Type != "And" {
+		t.Errorf("expected visible type And, got %q (%v)", env.Type, err)
+	}
+
+	q2, err := OpenQuery(data, "adults", s)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if v, err := q2.Evaluate(&testUser{Name: "bob", Age: 35}); err != nil || !v {
+		t.Errorf("opened query evaluate failed: %v %v", v, err)
+	}
+
+	if _, err := OpenQuery(data, "adults", AESGCM{Key: bytes.Repeat([]byte{8}, 32)}); err == nil {
+		t.Errorf("expected error opening with the wrong key")
+	}
+	env.Type = "Or"
+	tampered, _ := json.Marshal(env)
+	if _, err := OpenQuery(tampered, "adults", s); err == nil {
+		t.Errorf("expected error when the visible type is tampered with")
+	}
+}
+
+func TestSealQueryBindsMetadataAndName(t *testing.T) {
+	s := AESGCM{Key: bytes.Repeat([]byte{7}, 32)}
+	q := Query{
+		Expression: &IsExpression{Field: "Name", Value: "bob"},
+		Metadata:   &Metadata{Author: "ann", Tags: []string{"prod"}},
+	}
+	data, err := SealQuery(q, "adults", s)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if _, err := OpenQuery(data, "minors", s); err == nil {
+		t.Errorf("expected error opening under another name")
+	}
+
+	var env SealedQuery
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatal(err)
+	}
+	env.Metadata.Tags = []string{"test"}
+	tampered, _ := json.Marshal(env)
+	if _, err := OpenQuery(tampered, "adults", s); err == nil {
+		t.Errorf("expected error when the metadata is tampered with")
+	}
+
+	// Re-encoding the envelope without changing it keeps it valid.
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	reencoded, _ := json.MarshalIndent(raw, "", "  ")
+	if _, err := OpenQuery(reencoded, "adults", s); err != nil {
+		t.Errorf("open re-encoded envelope: %v", err)
+	}
+}
+
+func TestIsSealedPlainQuery(t *testing.T) {
+	data, err := json.Marshal(Query{Expression: &IsExpression{Field: "Name", Value: "bob"}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if IsSealed(data) {
+		t.Errorf("plain query reported as sealed")
+	}
+}
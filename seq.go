@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"iter"
+)
+
+// FilterSeq returns an iterator over the items of seq that match q. opts are
+// passed to q.Evaluate; an ErrorHandler among them is called for items whose
+// evaluation fails, which are skipped.
+func FilterSeq[T any](seq iter.Seq[T], q Query, opts ...any) iter.Seq[T] {
+	onError := getErrorHandler(opts)
+	return func(yield func(T) bool) {
+		for v := range seq {
+			if matchItem(q, v, onError, opts) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// FilterSeq2 returns an iterator over the pairs of seq whose value matches q,
+// such as the entries of maps.All.
+func FilterSeq2[K, V any](seq iter.Seq2[K, V], q Query, opts ...any) iter.Seq2[K, V] {
+	onError := getErrorHandler(opts)
+	return func(yield func(K, V) bool) {
+		for k, v := range seq {
+			if matchItem(q, v, onError, opts) && !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// MatchSeq2 returns an iterator yielding every item of seq together with
+// whether it matches q. Items whose evaluation fails are reported as not
+// matching.
+func MatchSeq2[T any](seq iter.Seq[T], q Query, opts ...any) iter.Seq2[T, bool] {
+	onError := getErrorHandler(opts)
+	return func(yield func(T, bool) bool) {
+		for v := range seq {
+			if !yield(v, matchItem(q, v, onError, opts)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package evaluator
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestFilterSeq(t *testing.T) {
+	items := []*pipelineItem{{1}, {2}, {3}, {4}}
+	q := Query{Expression: &GreaterThanExpression{Field: "N", Value: 1}}
+	got := slices.Collect(FilterSeq(slices.Values(items), q))
+	if len(got) != 3 || got[0].N != 2 {
+		t.Errorf("got %v", got)
+	}
+	n := 0
+	for range FilterSeq(slices.Values(items), q) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("early break not honoured")
+	}
+}
+
+func TestFilterSeq2(t *testing.T) {
+	m := map[string]*pipelineItem{"a": {1}, "b": {5}}
+	q := Query{Expression: &GreaterThanExpression{Field: "N", Value: 1}}
+	got := maps.Collect(FilterSeq2(maps.All(m), q))
+	if len(got) != 1 || got["b"] == nil {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestMatchSeq2(t *testing.T) {
+	items := []*pipelineItem{{1}, {2}}
+	q := Query{Expression: &IsExpression{Field: "N", Value: 2}}
+	var matches []bool
+	for _, ok := range MatchSeq2(slices.Values(items), q) {
+		matches = append(matches, ok)
+	}
+	if len(matches) != 2 || matches[0] || !matches[1] {
+		t.Errorf("got %v", matches)
+	}
+
+	var errs int
+	for _, ok := range MatchSeq2(slices.Values(items), Query{Expression: failingExpression{}}, ErrorHandler(func(error) { errs++ })) {
+		if ok {
+			t.Errorf("failing evaluation reported as a match")
+		}
+	}
+	if errs != 2 {
+		t.Errorf("expected 2 errors, got %d", errs)
+	}
+}
@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SoundsLikeExpression succeeds when the string Field sounds like Value by
+// American Soundex, so "Robert" matches "Rupert" and "Smith" matches "Smyth".
+// Names of several words, split at anything other than an ASCII letter,
+// match when they have as many words and each pair of words sounds alike.
+// Strings without letters match nothing.
+type SoundsLikeExpression struct {
+	Field string
+	Value string
+}
+
+func (e SoundsLikeExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok || f.Kind() != reflect.String {
+		return false, nil
+	}
+	want := soundexWords(e.Value)
+	got := soundexWords(f.String())
+	if len(want) == 0 || len(got) != len(want) {
+		return false, nil
+	}
+	for n := range want {
+		if got[n] != want[n] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// soundexWords returns the Soundex code of each word of s.
+func soundexWords(s string) []string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z')
+	})
+	codes := make([]string, len(words))
+	for n, w := range words {
+		codes[n] = soundex(w)
+	}
+	return codes
+}
+
+// soundexDigits holds the digit of each letter from 'A', with '0' for the
+// vowels and Y, which separate letters of the same digit, and ' ' for H and
+// W, which do not.
+const soundexDigits = "0123012 02245501262301 202"
+
+// soundex returns the four character Soundex code of word, which holds only
+// ASCII letters.
+func soundex(word string) string {
+	word = strings.ToUpper(word)
+	code := []byte{word[0], '0', '0', '0'}
+	n := 1
+	last := soundexDigits[word[0]-'A']
+	for i := 1; i < len(word) && n < len(code); i++ {
+		d := soundexDigits[word[i]-'A']
+		switch d {
+		case ' ':
+			continue
+		case '0':
+		default:
+			if d != last {
+				code[n] = d
+				n++
+			}
+		}
+		last = d
+	}
+	return string(code)
+}
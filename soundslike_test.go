@@ -0,0 +1,55 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSoundex(t *testing.T) {
+	for word, want := range map[string]string{
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Rubin":    "R150",
+		"Ashcraft": "A261",
+		"Ashcroft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Honeyman": "H555",
+		"lee":      "L000",
+	} {
+		if got := soundex(word); got != want {
+			t.Errorf("soundex(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestSoundsLikeExpression(t *testing.T) {
+	q := Query{Expression: &SoundsLikeExpression{Field: "Name", Value: "John Smith"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"SoundsLike","Expression":{"Field":"Name","Value":"John Smith"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"John Smith":   true,
+		"jon smyth":    true,
+		"Jon-Smythe":   true,
+		"John":         false,
+		"Mary Smith":   false,
+		"John Q Smith": false,
+		"":             false,
+	} {
+		if ok, _ := back.Evaluate(map[string]interface{}{"Name": name}); ok != want {
+			t.Errorf("%q: got %v, want %v", name, ok, want)
+		}
+	}
+	if ok, _ := (SoundsLikeExpression{Field: "Name", Value: "42"}).Evaluate(map[string]interface{}{"Name": "7"}); ok {
+		t.Errorf("strings without letters matched")
+	}
+}
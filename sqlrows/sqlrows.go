@@ -0,0 +1,75 @@
+// Package sqlrows evaluates queries against the rows of a database/sql
+// result set. Columns become fields named after the column, so post-filtering
+// query results needs no conversion code.
+package sqlrows
+
+import (
+	"database/sql"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Record returns the current row of rows as a map from column name to value.
+// Columns are scanned as the driver reports them rather than into sql.Null*
+// types: NULL is nil, so it matches IsEmptyExpression, and text read as []byte is
+// converted to a string.
+func Record(rows *sql.Rows) (map[string]interface{}, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	return scan(rows, cols)
+}
+
+// Match evaluates q against the current row of rows.
+func Match(rows *sql.Rows, q evaluator.Query, opts ...any) (bool, error) {
+	rec, err := Record(rows)
+	if err != nil {
+		return false, err
+	}
+	return q.Evaluate(rec, opts...)
+}
+
+// Filter reads the remaining rows and returns those matching q. It does not
+// close rows.
+func Filter(rows *sql.Rows, q evaluator.Query, opts ...any) ([]map[string]interface{}, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]interface{}
+	for rows.Next() {
+		rec, err := scan(rows, cols)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := q.Evaluate(rec, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, rec)
+		}
+	}
+	return out, rows.Err()
+}
+
+func scan(rows *sql.Rows, cols []string) (map[string]interface{}, error) {
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	rec := make(map[string]interface{}, len(cols))
+	for i, c := range cols {
+		if b, ok := vals[i].([]byte); ok {
+			rec[c] = string(b)
+		} else {
+			rec[c] = vals[i]
+		}
+	}
+	return rec, nil
+}
@@ -0,0 +1,109 @@
+package sqlrows
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+// fakeDriver serves a fixed result set for any query.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{data: [][]driver.Value{
+		{int64(1), []byte("alice"), int64(30), nil},
+		{int64(2), []byte("bob"), nil, "ops"},
+		{int64(3), []byte("carol"), int64(41), "dev"},
+	}}, nil
+}
+
+type fakeRows struct {
+	data [][]driver.Value
+	n    int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name", "age", "team"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.n])
+	r.n++
+	return nil
+}
+
+func init() {
+	sql.Register("sqlrows-fake", fakeDriver{})
+}
+
+func query(t *testing.T) *sql.Rows {
+	t.Helper()
+	db, err := sql.Open("sqlrows-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	rows, err := db.Query("SELECT id, name, age, team FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rows.Close() })
+	return rows
+}
+
+func TestFilter(t *testing.T) {
+	q, err := simple.Parse(`age > 25`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = evaluator.Query{Expression: &evaluator.AndExpression{Expressions: []evaluator.Query{
+		q, {Expression: &evaluator.IsNotEmptyExpression{Field: "team"}},
+	}}}
+	got, err := Filter(query(t), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0]["name"] != "carol" || got[0]["id"] != int64(3) {
+		t.Errorf("unexpected rows %v", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	q := evaluator.Query{Expression: &evaluator.IsEmptyExpression{Field: "age"}}
+	rows := query(t)
+	var matched []string
+	for rows.Next() {
+		ok, err := Match(rows, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			rec, err := Record(rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matched = append(matched, rec["name"].(string))
+		}
+	}
+	if len(matched) != 1 || matched[0] != "bob" {
+		t.Errorf("unexpected matches %v", matched)
+	}
+}
@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Stateful is implemented by Expressions that keep state between
+// evaluations, such as counters or de-duplication windows. Snapshot and
+// Restore use it to carry that state across restarts of a long running
+// filter.
+type Stateful interface {
+	SnapshotState() (json.RawMessage, error)
+	RestoreState(data json.RawMessage) error
+}
+
+// Snapshot captures the state of every Stateful expression in q. The result
+// is JSON keyed by each expression's position in the tree, e.g.
+// "$.Expressions[1].Expression", and is empty ("{}") when q holds no state.
+func Snapshot(q Query) ([]byte, error) {
+	state := map[string]json.RawMessage{}
+	err := walkStateful(q.Expression, "$", func(path string, s Stateful) error {
+		data, err := s.SnapshotState()
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %w", path, err)
+		}
+		state[path] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+// Restore loads state produced by Snapshot back into the Stateful
+// expressions of q. It fails without changing anything when the snapshot
+// names a position that is not Stateful in q, which usually means the query
+// has changed since the snapshot was taken. When an expression rejects its
+// state, the expressions already restored are given back the state they had
+// before, so that q is left as it was.
+func Restore(q Query, data []byte) error {
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	targets := map[string]Stateful{}
+	_ = walkStateful(q.Expression, "$", func(path string, s Stateful) error {
+		targets[path] = s
+		return nil
+	})
+	paths := make([]string, 0, len(state))
+	for path := range state {
+		if _, ok := targets[path]; !ok {
+			return fmt.Errorf("restore: no stateful expression at %s", path)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	previous := make(map[string]json.RawMessage, len(paths))
+	for _, path := range paths {
+		data, err := targets[path].SnapshotState()
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", path, err)
+		}
+		previous[path] = data
+	}
+	for n, path := range paths {
+		if err := targets[path].RestoreState(state[path]); err != nil {
+			for _, done := range paths[:n] {
+				_ = targets[done].RestoreState(previous[done])
+			}
+			return fmt.Errorf("restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func walkStateful(e Expression, path string, fn func(string, Stateful) error) error {
+	if s, ok := e.(Stateful); ok {
+		if err := fn(path, s); err != nil {
+			return err
+		}
+	}
+	// Composite expressions given by value share their children with a
+	// pointer to a copy, so walk them in that form.
+	if v := reflect.ValueOf(e); v.Kind() == reflect.Struct {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		if pe, ok := p.Interface().(Expression); ok {
+			e = pe
+		}
+	}
+	var children []Query
+	switch ex := e.(type) {
+	case *AndExpression:
+		children = ex.Expressions
+	case *OrExpression:
+		children = ex.Expressions
+	case *XorExpression:
+		children = ex.Expressions
+	case *ImpliesExpression:
+		if err := walkStateful(ex.If.Expression, path+".If", fn); err != nil {
+			return err
+		}
+		return walkStateful(ex.Then.Expression, path+".Then", fn)
+	case *CaseExpression:
+		for i, c := range ex.Cases {
+			branch := fmt.Sprintf("%s.Cases[%d]", path, i)
+			if err := walkStateful(c.When.Expression, branch+".When", fn); err != nil {
+				return err
+			}
+			if err := walkStateful(c.Then.Expression, branch+".Then", fn); err != nil {
+				return err
+			}
+		}
+		return walkStateful(ex.Else.Expression, path+".Else", fn)
+	case *NotExpression:
+		return walkStateful(ex.Expression.Expression, path+".Expression", fn)
+	case *AnyExpression:
+		return walkStateful(ex.Query.Expression, path+".Query", fn)
+	case *AllExpression:
+		return walkStateful(ex.Query.Expression, path+".Query", fn)
+	case *CountExpression:
+		return walkStateful(ex.Query.Expression, path+".Query", fn)
+	case *ScoreQuery:
+		for i, w := range ex.Expressions {
+			if err := walkStateful(w.Query.Expression, fmt.Sprintf("%s.Expressions[%d].Query", path, i), fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, c := range children {
+		if err := walkStateful(c.Expression, fmt.Sprintf("%s.Expressions[%d]", path, i), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
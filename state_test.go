@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// countExpression matches the first Limit records and then no more.
+type countExpression struct {
+	Limit int
+	seen  int
+}
+
+func (e *countExpression) Evaluate(interface{}, ...any) (bool, error) {
+	e.seen++
+	return e.seen <= e.Limit, nil
+}
+
+func (e *countExpression) SnapshotState() (json.RawMessage, error) {
+	return json.Marshal(e.seen)
+}
+
+func (e *countExpression) RestoreState(data json.RawMessage) error {
+	return json.Unmarshal(data, &e.seen)
+}
+
+func countQuery(c *countExpression) Query {
+	return Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &IsExpression{Field: "A", Value: 1}},
+		{Expression: &NotExpression{Expression: Query{Expression: c}}},
+	}}}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	c := &countExpression{Limit: 2}
+	q := countQuery(c)
+	rec := map[string]interface{}{"A": 1}
+	for i := 0; i < 3; i++ {
+		if _, err := q.Evaluate(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := Snapshot(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"$.Expressions[1].Expression":3}` {
+		t.Errorf("unexpected snapshot %s", data)
+	}
+
+	restored := &countExpression{Limit: 2}
+	if err := Restore(countQuery(restored), data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.seen != 3 {
+		t.Errorf("restored seen = %d, want 3", restored.seen)
+	}
+}
+
+func TestRestoreMismatch(t *testing.T) {
+	c := &countExpression{}
+	q := Query{Expression: c}
+	if err := Restore(q, []byte(`{"$.Expressions[0]":1}`)); err == nil {
+		t.Errorf("expected error for unknown path")
+	}
+	if c.seen != 0 {
+		t.Errorf("state changed on failed restore")
+	}
+	if data, err := Snapshot(Query{Expression: &IsExpression{Field: "A"}}); err != nil || string(data) != "{}" {
+		t.Errorf("stateless snapshot = %s, %v", data, err)
+	}
+}
+
+// failingState rejects every restore.
+type failingState struct{ countExpression }
+
+func (e *failingState) RestoreState(json.RawMessage) error { return errors.New("corrupt") }
+
+func TestRestoreAllOrNothing(t *testing.T) {
+	c := &countExpression{seen: 1}
+	q := Query{Expression: AndExpression{Expressions: []Query{
+		{Expression: c},
+		{Expression: &failingState{}},
+	}}}
+	err := Restore(q, []byte(`{"$.Expressions[0]":5,"$.Expressions[1]":5}`))
+	if err == nil || !strings.Contains(err.Error(), "$.Expressions[1]") {
+		t.Fatalf("expected the second restore to fail, got %v", err)
+	}
+	if c.seen != 1 {
+		t.Errorf("seen = %d after a failed restore, want 1", c.seen)
+	}
+}
+
+func TestSnapshotScoreQuery(t *testing.T) {
+	c := &countExpression{seen: 4}
+	q := Query{Expression: &ScoreQuery{Expressions: []WeightedExpression{
+		{Query: Query{Expression: &IsExpression{Field: "A", Value: 1}}, Weight: 1},
+		{Query: Query{Expression: c}, Weight: 2},
+	}}}
+	data, err := Snapshot(q)
+	if err != nil || string(data) != `{"$.Expressions[1].Query":4}` {
+		t.Errorf("snapshot = %s, %v", data, err)
+	}
+}
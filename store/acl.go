@@ -0,0 +1,238 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// ErrPermissionDenied is returned when a principal is not allowed to perform
+// an action.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Action is an operation guarded by an ACL.
+type Action string
+
+const (
+	// ActionRead allows loading a query or rule.
+	ActionRead Action = "read"
+	// ActionModify allows saving, deleting and changing the ACL.
+	ActionModify Action = "modify"
+	// ActionEnable allows enabling or disabling a rule.
+	ActionEnable Action = "enable"
+)
+
+// ACL records who owns a query or rule set and who else may act on it. The
+// owner may perform every action. The principal "*" matches anyone.
+type ACL struct {
+	Owner    string   `json:"Owner"`
+	Readers  []string `json:"Readers,omitempty"`
+	Editors  []string `json:"Editors,omitempty"`
+	Enablers []string `json:"Enablers,omitempty"`
+}
+
+// Allows reports whether principal may perform act. Editors may also read and
+// enable. The empty principal, an unauthenticated caller, may do nothing.
+func (a ACL) Allows(principal string, act Action) bool {
+	if principal == "" {
+		return false
+	}
+	if principal == a.Owner {
+		return true
+	}
+	in := func(list []string) bool {
+		return slices.Contains(list, principal) || slices.Contains(list, "*")
+	}
+	switch act {
+	case ActionRead:
+		return in(a.Readers) || in(a.Editors) || in(a.Enablers)
+	case ActionModify:
+		return in(a.Editors)
+	case ActionEnable:
+		return in(a.Editors) || in(a.Enablers)
+	}
+	return false
+}
+
+// AuditEvent describes an attempted change to a guarded query or rule.
+type AuditEvent struct {
+	Time      time.Time
+	Principal string
+	Action    Action
+	Name      string
+	Allowed   bool
+}
+
+// AuditFunc receives audit events.
+type AuditFunc func(AuditEvent)
+
+// ACLStore persists ACLs by query name.
+type ACLStore interface {
+	// ACL returns the ACL for name and false when none has been set.
+	ACL(name string) (ACL, bool, error)
+	SetACL(name string, acl ACL) error
+	// DeleteACL removes the ACL for name, if any.
+	DeleteACL(name string) error
+}
+
+var _ ACLStore = (*Dir)(nil)
+
+// ACL reads the NAME.acl file stored alongside the query.
+func (d *Dir) ACL(name string) (ACL, bool, error) {
+	fn, err := d.file(name)
+	if err != nil {
+		return ACL{}, false, err
+	}
+	data, err := os.ReadFile(strings.TrimSuffix(fn, ".json") + ".acl")
+	if errors.Is(err, os.ErrNotExist) {
+		return ACL{}, false, nil
+	}
+	if err != nil {
+		return ACL{}, false, err
+	}
+	var acl ACL
+	if err := json.Unmarshal(data, &acl); err != nil {
+		return ACL{}, false, fmt.Errorf("acl %s: %w", name, err)
+	}
+	return acl, true, nil
+}
+
+// SetACL writes the NAME.acl file stored alongside the query.
+func (d *Dir) SetACL(name string, acl ACL) error {
+	fn, err := d.file(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(acl)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(strings.TrimSuffix(fn, ".json")+".acl", data, 0o600)
+}
+
+// DeleteACL removes the NAME.acl file stored alongside the query.
+func (d *Dir) DeleteACL(name string) error {
+	fn, err := d.file(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(strings.TrimSuffix(fn, ".json") + ".acl"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Guarded enforces ACLs on top of a Store. Queries without an ACL are treated
+// as unowned and may be used by anyone; the first principal to save an
+// unowned query becomes its owner. Only the owner may hand a query to another
+// owner, and the empty principal is always refused. Every change, allowed or denied, is
+// reported to Audit when it is set.
+type Guarded struct {
+	Store Store
+	ACLs  ACLStore
+	Audit AuditFunc
+	// Now returns the time recorded in audit events. It defaults to time.Now.
+	Now func() time.Time
+}
+
+func (g *Guarded) check(principal string, act Action, name string, audit bool) (ACL, bool, error) {
+	acl, found, err := g.ACLs.ACL(name)
+	if err != nil {
+		return ACL{}, false, err
+	}
+	allowed := principal != "" && (!found || acl.Allows(principal, act))
+	if audit {
+		g.emit(principal, act, name, allowed)
+	}
+	if !allowed {
+		return acl, found, fmt.Errorf("%w: %s may not %s %s", ErrPermissionDenied, principal, act, name)
+	}
+	return acl, found, nil
+}
+
+func (g *Guarded) emit(principal string, act Action, name string, allowed bool) {
+	if g.Audit == nil {
+		return
+	}
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+	g.Audit(AuditEvent{Time: now(), Principal: principal, Action: act, Name: name, Allowed: allowed})
+}
+
+// Load returns the named query when principal may read it.
+func (g *Guarded) Load(principal, name string) (evaluator.Query, error) {
+	if _, _, err := g.check(principal, ActionRead, name, false); err != nil {
+		return evaluator.Query{}, err
+	}
+	return g.Store.Load(name)
+}
+
+// Save stores q when principal may modify it, claiming ownership of
+// previously unowned queries.
+func (g *Guarded) Save(principal, name string, q evaluator.Query) error {
+	_, found, err := g.check(principal, ActionModify, name, true)
+	if err != nil {
+		return err
+	}
+	if err := g.Store.Save(name, q); err != nil {
+		return err
+	}
+	if !found {
+		return g.ACLs.SetACL(name, ACL{Owner: principal})
+	}
+	return nil
+}
+
+// Delete removes the named query and its ACL when principal may modify it.
+func (g *Guarded) Delete(principal, name string) error {
+	if _, _, err := g.check(principal, ActionModify, name, true); err != nil {
+		return err
+	}
+	if err := g.Store.Delete(name); err != nil {
+		return err
+	}
+	return g.ACLs.DeleteACL(name)
+}
+
+// SetACL replaces the ACL of the named query when principal may modify it.
+// Only the owner may change the owner; a principal setting the ACL of an
+// unowned query must name itself as owner.
+func (g *Guarded) SetACL(principal, name string, acl ACL) error {
+	cur, found, err := g.check(principal, ActionModify, name, false)
+	if err != nil && !errors.Is(err, ErrPermissionDenied) {
+		return err
+	}
+	if err == nil && (found && acl.Owner != cur.Owner && principal != cur.Owner || !found && acl.Owner != principal) {
+		err = fmt.Errorf("%w: %s may not change the owner of %s", ErrPermissionDenied, principal, name)
+	}
+	g.emit(principal, ActionModify, name, err == nil)
+	if err != nil {
+		return err
+	}
+	return g.ACLs.SetACL(name, acl)
+}
+
+// List returns the names of the queries principal may read.
+func (g *Guarded) List(principal string) ([]string, error) {
+	names, err := g.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, n := range names {
+		if _, _, err := g.check(principal, ActionRead, n, false); err == nil {
+			out = append(out, n)
+		} else if !errors.Is(err, ErrPermissionDenied) {
+			return nil, err
+		}
+	}
+	return out, nil
+}
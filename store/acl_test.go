@@ -0,0 +1,114 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+)
+
+func TestACLAllows(t *testing.T) {
+	acl := ACL{Owner: "alice", Readers: []string{"bob"}, Enablers: []string{"ops"}, Editors: []string{"carol"}}
+	cases := []struct {
+		principal string
+		act       Action
+		want      bool
+	}{
+		{"alice", ActionModify, true},
+		{"bob", ActionRead, true},
+		{"bob", ActionModify, false},
+		{"ops", ActionEnable, true},
+		{"ops", ActionModify, false},
+		{"carol", ActionEnable, true},
+		{"mallory", ActionRead, false},
+	}
+	for _, c := range cases {
+		if got := acl.Allows(c.principal, c.act); got != c.want {
+			t.Errorf("%s %s: expected %v, got %v", c.principal, c.act, c.want, got)
+		}
+	}
+}
+
+func TestGuarded(t *testing.T) {
+	d := &Dir{Path: t.TempDir()}
+	var events []AuditEvent
+	g := &Guarded{Store: d, ACLs: d, Audit: func(e AuditEvent) { events = append(events, e) }}
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "Name", Value: "bob"}}
+
+	if err := g.Save("alice", "vip", q); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if acl, ok, err := d.ACL("vip"); err != nil || !ok || acl.Owner != "alice" {
+		t.Fatalf("expected alice to own vip: %v %v %v", acl, ok, err)
+	}
+	if err := g.Save("bob", "vip", q); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+	if _, err := g.Load("bob", "vip"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+	if err := g.SetACL("alice", "vip", ACL{Owner: "alice", Readers: []string{"bob"}}); err != nil {
+		t.Fatalf("set acl: %v", err)
+	}
+	if _, err := g.Load("bob", "vip"); err != nil {
+		t.Errorf("expected bob to read vip: %v", err)
+	}
+	if names, err := g.List("bob"); err != nil || !reflect.DeepEqual(names, []string{"vip"}) {
+		t.Errorf("list: %v %v", names, err)
+	}
+	if names, err := g.List("mallory"); err != nil || len(names) != 0 {
+		t.Errorf("list: %v %v", names, err)
+	}
+
+	want := []bool{true, false, true}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d audit events, got %v", len(want), events)
+	}
+	for i, e := range events {
+		if e.Allowed != want[i] || e.Name != "vip" {
+			t.Errorf("event %d: %+v", i, e)
+		}
+	}
+}
+
+func TestGuardedOwnership(t *testing.T) {
+	d := &Dir{Path: t.TempDir()}
+	g := &Guarded{Store: d, ACLs: d}
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "Name", Value: "bob"}}
+
+	if err := g.Save("", "vip", q); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected the empty principal to be refused, got %v", err)
+	}
+	if err := g.Save("alice", "vip", q); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := g.SetACL("alice", "vip", ACL{Owner: "alice", Editors: []string{"carol", "*"}}); err != nil {
+		t.Fatalf("set acl: %v", err)
+	}
+	if _, err := g.Load("", "vip"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected the empty principal to be refused, got %v", err)
+	}
+	if err := g.SetACL("carol", "vip", ACL{Owner: "carol"}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected an editor to be refused ownership, got %v", err)
+	}
+	if err := g.SetACL("carol", "vip", ACL{Owner: "alice", Readers: []string{"bob"}}); err != nil {
+		t.Errorf("expected an editor to change readers: %v", err)
+	}
+	if err := g.SetACL("mallory", "unowned", ACL{Owner: "alice"}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected an unowned ACL to name its setter as owner, got %v", err)
+	}
+
+	if err := g.Delete("alice", "vip"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok, err := d.ACL("vip"); err != nil || ok {
+		t.Fatalf("expected the ACL to be deleted: %v %v", ok, err)
+	}
+	if err := g.Save("mallory", "vip", q); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if acl, _, _ := d.ACL("vip"); acl.Owner != "mallory" {
+		t.Errorf("expected a recreated query to be owned afresh, got %+v", acl)
+	}
+}
@@ -0,0 +1,113 @@
+// Package store persists named queries so they can be shared between tools
+// and loaded at runtime.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// ErrNotFound is returned when a named query does not exist.
+var ErrNotFound = errors.New("query not found")
+
+// Store loads and saves named queries.
+type Store interface {
+	Load(name string) (evaluator.Query, error)
+	Save(name string, q evaluator.Query) error
+	Delete(name string) error
+	List() ([]string, error)
+}
+
+// Dir stores each query as NAME.json inside Path. When Sealer is set queries
+// are encrypted on save, and sealed files are decrypted transparently on load.
+// Format selects the layout of unsealed files; evaluator.Pretty suits query
+// files kept under version control.
+type Dir struct {
+	Path   string
+	Sealer evaluator.Sealer
+	Format evaluator.MarshalFormat
+}
+
+var _ Store = (*Dir)(nil)
+
+func (d *Dir) file(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid query name %q", name)
+	}
+	return filepath.Join(d.Path, name+".json"), nil
+}
+
+func (d *Dir) Load(name string) (evaluator.Query, error) {
+	fn, err := d.file(name)
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return evaluator.Query{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return evaluator.Query{}, err
+	}
+	if evaluator.IsSealed(data) {
+		if d.Sealer == nil {
+			return evaluator.Query{}, fmt.Errorf("query %s is sealed and no sealer is configured", name)
+		}
+		return evaluator.OpenQuery(data, name, d.Sealer)
+	}
+	var q evaluator.Query
+	if err := json.Unmarshal(data, &q); err != nil {
+		return evaluator.Query{}, fmt.Errorf("query %s: %w", name, err)
+	}
+	return q, nil
+}
+
+func (d *Dir) Save(name string, q evaluator.Query) error {
+	fn, err := d.file(name)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if d.Sealer != nil {
+		data, err = evaluator.SealQuery(q, name, d.Sealer)
+	} else {
+		data, err = evaluator.MarshalQuery(q, d.Format)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, data, 0o600)
+}
+
+func (d *Dir) Delete(name string) error {
+	fn, err := d.file(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fn); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Dir) List() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(d.Path, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(m), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+)
+
+type user struct {
+	Name string
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	d := &Dir{Path: t.TempDir()}
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "Name", Value: "bob"}}
+	if err := d.Save("bob", q); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	names, err := d.List()
+	if err != nil || !reflect.DeepEqual(names, []string{"bob"}) {
+		t.Fatalf("list: %v %v", names, err)
+	}
+	q2, err := d.Load("bob")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if v, err := q2.Evaluate(&user{Name: "bob"}); err != nil || !v {
+		t.Errorf("loaded query evaluate failed: %v %v", v, err)
+	}
+	if err := d.Delete("bob"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := d.Load("bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := d.Save("../escape", q); err == nil {
+		t.Errorf("expected error for invalid name")
+	}
+}
+
+func TestDirSealed(t *testing.T) {
+	dir := t.TempDir()
+	d := &Dir{Path: dir, Sealer: evaluator.AESGCM{Key: bytes.Repeat([]byte{1}, 32)}}
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "Name", Value: "secret-rule"}}
+	if err := d.Save("rule", q); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "rule.json"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if bytes.Contains(raw, []byte("secret-rule")) {
+		t.Errorf("stored query is not encrypted: %s", raw)
+	}
+	q2, err := d.Load("rule")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if v, err := q2.Evaluate(&user{Name: "secret-rule"}); err != nil || !v {
+		t.Errorf("decrypted query evaluate failed: %v %v", v, err)
+	}
+	if _, err := (&Dir{Path: dir}).Load("rule"); err == nil {
+		t.Errorf("expected error loading sealed query without a sealer")
+	}
+	if err := os.Rename(filepath.Join(dir, "rule.json"), filepath.Join(dir, "other.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Load("other"); err == nil {
+		t.Errorf("expected error loading a sealed query under another name")
+	}
+}
+
+func TestDirPretty(t *testing.T) {
+	d := &Dir{Path: t.TempDir(), Format: evaluator.Pretty}
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "Name", Value: "bob"}}
+	if err := d.Save("bob", q); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(d.Path, "bob.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("{\n  \"Expression\"")) || !bytes.HasSuffix(data, []byte("}\n")) {
+		t.Errorf("expected indented file, got %s", data)
+	}
+	if _, err := d.Load("bob"); err != nil {
+		t.Errorf("load: %v", err)
+	}
+}
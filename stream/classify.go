@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// Classifier is a named query whose result is recorded on each record rather
+// than used to filter it.
+type Classifier struct {
+	Name  string
+	Query evaluator.Query
+}
+
+// classify evaluates the classifiers against v, which is rec or rec joined
+// with lookups, and stores each result in rec under the classifier name.
+func classify(rec Record, v interface{}, cs []Classifier, opts []any) error {
+	if len(cs) == 0 {
+		return nil
+	}
+	qs := make([]evaluator.Query, len(cs))
+	for n, c := range cs {
+		qs[n] = c.Query
+	}
+	var first error
+	onError := evaluator.ErrorHandler(func(err error) {
+		if first == nil {
+			first = err
+		}
+	})
+	matched := evaluator.EvaluateAll(qs, v, append(opts[:len(opts):len(opts)], onError)...)
+	if first != nil {
+		return fmt.Errorf("classify: %w", first)
+	}
+	for n, c := range cs {
+		rec[c.Name] = matched.Has(n)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/arran4/go-evaluator"
+)
+
+var (
+	// ErrRecordTooLarge is returned when a record exceeds Limits.MaxRecordSize.
+	ErrRecordTooLarge = errors.New("record too large")
+	// ErrMemoryBudget is returned when a single record can never fit within
+	// Limits.MemoryBudget.
+	ErrMemoryBudget = errors.New("memory budget exceeded")
+
+	errClosed = errors.New("filter stopped")
+)
+
+// Limits bounds the resources a Filter may use. Sizes are measured in bytes
+// of raw input; zero means unlimited.
+type Limits struct {
+	// MaxRecordSize is the largest single record accepted. Larger records
+	// fail with ErrRecordTooLarge before they are fully buffered.
+	MaxRecordSize int64
+	// MaxInFlight caps the number of records read but not yet written in
+	// parallel mode. It defaults to twice the number of workers.
+	MaxInFlight int
+	// MemoryBudget caps the combined size of all records held at once.
+	// Reading pauses until enough in-flight records have been written.
+	MemoryBudget int64
+}
+
+// Stats describes the work done by a Filter.
+type Stats struct {
+	Records      int64 // records read
+	Matched      int64 // records written
+	Bytes        int64 // bytes of records read
+	PeakInFlight int64 // most records held at once
+	PeakMemory   int64 // most record bytes held at once
+	Stalls       int64 // times reading paused because a limit was reached
+}
+
+// Filter copies the JSON values read from a stream that match Query,
+// respecting Limits. A Query without an Expression passes every record. With
+// Workers greater than one records are evaluated concurrently while output
+// order is preserved.
+type Filter struct {
+	Query   evaluator.Query
+	Limits  Limits
+	Workers int
+	// Transform, when set, rewrites each record before it is joined and
+	// evaluated; matches are written as transformed. See Chain for applying
+	// several.
+	Transform Transform
+	// Lookups are joined to each record before evaluation; see Join.
+	Lookups []*Lookup
+	// Classifiers are evaluated against every record that passes Query and
+	// their results added to it as boolean fields, replacing any field of
+	// the same name.
+	Classifiers []Classifier
+
+	stats struct {
+		records, matched, bytes, peakInFlight, peakMemory, stalls atomic.Int64
+	}
+}
+
+// Stats returns a snapshot of the filter statistics. It is safe to call while
+// JSON is running.
+func (f *Filter) Stats() Stats {
+	return Stats{
+		Records:      f.stats.records.Load(),
+		Matched:      f.stats.matched.Load(),
+		Bytes:        f.stats.bytes.Load(),
+		PeakInFlight: f.stats.peakInFlight.Load(),
+		PeakMemory:   f.stats.peakMemory.Load(),
+		Stalls:       f.stats.stalls.Load(),
+	}
+}
+
+// JSON filters a stream of JSON values, such as JSON Lines, from r and writes
+// matching records to w one per line.
+func (f *Filter) JSON(r io.Reader, w io.Writer, opts ...any) error {
+	return f.Run(r, NewWriterSink(w), opts...)
+}
+
+// Run filters a stream of JSON values from r and writes matching records to
+// s, flushing it once the input is exhausted.
+func (f *Filter) Run(r io.Reader, s Sink, opts ...any) error {
+	br := &boundedReader{r: r, max: f.Limits.MaxRecordSize}
+	dec := json.NewDecoder(br)
+	br.dec = dec
+	b := newBudget(f)
+	var err error
+	if f.Workers > 1 {
+		err = f.parallel(dec, s, b, opts)
+	} else {
+		err = f.sequential(dec, s, b, opts)
+	}
+	if err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func (f *Filter) sequential(dec *json.Decoder, s Sink, b *budget, opts []any) error {
+	for {
+		raw, err := f.next(dec, b)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rec, err := f.evaluate(raw, opts)
+		b.release(int64(len(raw)))
+		if err != nil {
+			return err
+		}
+		if rec != nil {
+			if err := s.Write(rec); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// next reads the next record and reserves room for it in the budget.
+func (f *Filter) next(dec *json.Decoder, b *budget) (json.RawMessage, error) {
+	var raw json.RawMessage
+	err := dec.Decode(&raw)
+	if err == nil && f.Limits.MaxRecordSize > 0 && int64(len(raw)) > f.Limits.MaxRecordSize {
+		err = ErrRecordTooLarge
+	}
+	if err != nil {
+		if errors.Is(err, ErrRecordTooLarge) {
+			return nil, fmt.Errorf("record %d at offset %d: %w (limit %d bytes)", f.stats.records.Load()+1, dec.InputOffset(), err, f.Limits.MaxRecordSize)
+		}
+		return nil, err
+	}
+	f.stats.records.Add(1)
+	f.stats.bytes.Add(int64(len(raw)))
+	if err := b.acquire(int64(len(raw))); err != nil {
+		if errors.Is(err, ErrMemoryBudget) {
+			return nil, fmt.Errorf("record %d of %d bytes: %w (budget %d bytes)", f.stats.records.Load(), len(raw), err, f.Limits.MemoryBudget)
+		}
+		return nil, err
+	}
+	return raw, nil
+}
+
+// evaluate decodes raw and returns it when it matches, or nil otherwise.
+// Numbers are decoded as json.Number, so IDs beyond the precision of float64
+// join exactly with lookups and are written back unchanged.
+func (f *Filter) evaluate(raw json.RawMessage, opts []any) (Record, error) {
+	var rec Record
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&rec); err != nil {
+		return nil, err
+	}
+	if f.Transform != nil {
+		var err error
+		if rec, err = f.Transform(rec); err != nil || rec == nil {
+			return nil, err
+		}
+	}
+	var v interface{} = map[string]interface{}(rec)
+	if len(f.Lookups) > 0 {
+		v = Join(rec, f.Lookups...)
+	}
+	if f.Query.Expression != nil {
+		ok, err := f.Query.Evaluate(v, opts...)
+		if err != nil || !ok {
+			return nil, err
+		}
+	}
+	if err := classify(rec, v, f.Classifiers, opts); err != nil {
+		return nil, err
+	}
+	f.stats.matched.Add(1)
+	return rec, nil
+}
+
+type job struct {
+	raw  json.RawMessage
+	rec  Record
+	err  error
+	done chan struct{}
+}
+
+func (f *Filter) parallel(dec *json.Decoder, s Sink, b *budget, opts []any) error {
+	jobs := make(chan *job)
+	order := make(chan *job, b.maxCount)
+	stop := make(chan struct{})
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		for {
+			raw, err := f.next(dec, b)
+			if err != nil {
+				if err != io.EOF && err != errClosed {
+					readErr <- err
+				}
+				return
+			}
+			j := &job{raw: raw, done: make(chan struct{})}
+			select {
+			case order <- j:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for i := 0; i < f.Workers; i++ {
+		go func() {
+			for j := range jobs {
+				j.rec, j.err = f.evaluate(j.raw, opts)
+				close(j.done)
+			}
+		}()
+	}
+	fail := func(err error) error {
+		close(stop)
+		b.close()
+		return err
+	}
+	for j := range order {
+		<-j.done
+		b.release(int64(len(j.raw)))
+		if j.err != nil {
+			return fail(j.err)
+		}
+		if j.rec != nil {
+			if err := s.Write(j.rec); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	select {
+	case err := <-readErr:
+		return err
+	default:
+		return nil
+	}
+}
+
+// boundedReader stops feeding a json.Decoder once the value it is decoding
+// would exceed max bytes, so oversized records are rejected without being
+// buffered in full.
+type boundedReader struct {
+	r   io.Reader
+	dec *json.Decoder
+	max int64
+	n   int64
+}
+
+func (b *boundedReader) Read(p []byte) (int, error) {
+	if b.max > 0 {
+		// The decoder only reads when its buffer holds an incomplete
+		// value, so everything past InputOffset belongs to that value.
+		room := b.max + 1 - (b.n - b.dec.InputOffset())
+		if room <= 0 {
+			return 0, ErrRecordTooLarge
+		}
+		if int64(len(p)) > room {
+			p = p[:room]
+		}
+	}
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// budget tracks in-flight records and blocks acquire until they fit within
+// the configured limits.
+type budget struct {
+	f        *Filter
+	mu       sync.Mutex
+	cond     *sync.Cond
+	count    int64
+	bytes    int64
+	maxCount int64
+	maxBytes int64
+	closed   bool
+}
+
+func newBudget(f *Filter) *budget {
+	b := &budget{f: f, maxBytes: f.Limits.MemoryBudget, maxCount: 1}
+	if f.Workers > 1 {
+		b.maxCount = int64(f.Limits.MaxInFlight)
+		if b.maxCount <= 0 {
+			b.maxCount = 2 * int64(f.Workers)
+		}
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *budget) acquire(n int64) error {
+	if b.maxBytes > 0 && n > b.maxBytes {
+		return ErrMemoryBudget
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stalled := false
+	for !b.closed && (b.count >= b.maxCount || (b.maxBytes > 0 && b.bytes+n > b.maxBytes)) {
+		if !stalled {
+			stalled = true
+			b.f.stats.stalls.Add(1)
+		}
+		b.cond.Wait()
+	}
+	if b.closed {
+		return errClosed
+	}
+	b.count++
+	b.bytes += n
+	if b.count > b.f.stats.peakInFlight.Load() {
+		b.f.stats.peakInFlight.Store(b.count)
+	}
+	if b.bytes > b.f.stats.peakMemory.Load() {
+		b.f.stats.peakMemory.Store(b.bytes)
+	}
+	return nil
+}
+
+func (b *budget) release(n int64) {
+	b.mu.Lock()
+	b.count--
+	b.bytes -= n
+	b.mu.Unlock()
+	b.cond.Signal()
+}
+
+func (b *budget) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
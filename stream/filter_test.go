@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+)
+
+func records(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "{\"n\":%d}\n", i)
+	}
+	return b.String()
+}
+
+func evenQuery() evaluator.Query {
+	return evaluator.Query{Expression: &evaluator.ComparisonExpression{
+		LHS:       &evaluator.ModTerm{LHS: evaluator.Field{Name: "n"}, RHS: evaluator.Constant{Value: 2}},
+		Operation: "eq",
+		RHS:       evaluator.Constant{Value: 0},
+	}}
+}
+
+func TestFilterSequential(t *testing.T) {
+	f := &Filter{Query: evenQuery()}
+	var w bytes.Buffer
+	if err := f.JSON(strings.NewReader(records(10)), &w); err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	if got := strings.Count(w.String(), "\n"); got != 5 {
+		t.Errorf("expected 5 matches, got %d:\n%s", got, w.String())
+	}
+	s := f.Stats()
+	if s.Records != 10 || s.Matched != 5 || s.PeakInFlight != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestFilterParallelPreservesOrder(t *testing.T) {
+	f := &Filter{Query: evenQuery(), Workers: 4, Limits: Limits{MaxInFlight: 3}}
+	var w bytes.Buffer
+	if err := f.JSON(strings.NewReader(records(200)), &w); err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	var seq Filter
+	var want bytes.Buffer
+	seq.Query = evenQuery()
+	if err := seq.JSON(strings.NewReader(records(200)), &want); err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	if w.String() != want.String() {
+		t.Errorf("parallel output differs from sequential")
+	}
+	if s := f.Stats(); s.PeakInFlight > 3 || s.Records != 200 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestFilterMaxRecordSize(t *testing.T) {
+	input := `{"n":1}` + "\n" + `{"n":2,"pad":"` + strings.Repeat("x", 1<<20) + `"}` + "\n"
+	r := &countingReader{r: strings.NewReader(input)}
+	f := &Filter{Query: evenQuery(), Limits: Limits{MaxRecordSize: 64}}
+	err := f.JSON(r, &bytes.Buffer{})
+	if !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", err)
+	}
+	if r.n > 4096 {
+		t.Errorf("read %d bytes before rejecting the record", r.n)
+	}
+}
+
+func TestFilterMaxRecordSizeExact(t *testing.T) {
+	f := &Filter{Query: evenQuery(), Limits: Limits{MaxRecordSize: 7}}
+	if err := f.JSON(strings.NewReader(`{"n":2}`+"\n"), &bytes.Buffer{}); err != nil {
+		t.Errorf("record at the limit: %v", err)
+	}
+	err := f.JSON(strings.NewReader(`{"n":20}`+"\n"), &bytes.Buffer{})
+	if !errors.Is(err, ErrRecordTooLarge) {
+		t.Errorf("record over the limit: %v", err)
+	}
+}
+
+func TestFilterMemoryBudget(t *testing.T) {
+	f := &Filter{Query: evenQuery(), Workers: 4, Limits: Limits{MemoryBudget: 20}}
+	var w bytes.Buffer
+	if err := f.JSON(strings.NewReader(records(50)), &w); err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	if s := f.Stats(); s.PeakMemory > 20 {
+		t.Errorf("peak memory %d exceeds budget", s.PeakMemory)
+	}
+	err := f.JSON(strings.NewReader(`{"n":2,"pad":"`+strings.Repeat("x", 40)+`"}`), &w)
+	if !errors.Is(err, ErrMemoryBudget) {
+		t.Errorf("expected ErrMemoryBudget, got %v", err)
+	}
+}
+
+func TestFilterParallelError(t *testing.T) {
+	f := &Filter{Query: evenQuery(), Workers: 2}
+	err := f.JSON(strings.NewReader(records(5)+"[1]\n"+records(100)), &bytes.Buffer{})
+	if err == nil {
+		t.Errorf("expected error decoding non-object record")
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,161 @@
+// Package stream evaluates queries over streams of records without loading
+// the whole input into memory.
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// errStop ends EachJSON early without reporting an error.
+var errStop = errors.New("stop")
+
+// EachJSON walks the JSON document read from r to the value at path and calls
+// fn with it. When that value is an array fn is called once per element
+// instead, so arbitrarily large arrays are processed one element at a time.
+// Sibling values along the way are skipped without being decoded.
+//
+// path is a dot separated list of object keys such as "data.items"; an empty
+// path or "$" refers to the document root.
+func EachJSON(r io.Reader, path string, fn func(v interface{}) error) error {
+	dec := json.NewDecoder(r)
+	if err := seek(dec, splitPath(path)); err != nil {
+		return err
+	}
+	// Peek at the next token to decide between array and single value.
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); ok && d == '[' {
+		for dec.More() {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return err
+			}
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	}
+	v, err := finishValue(dec, tok)
+	if err != nil {
+		return err
+	}
+	return fn(v)
+}
+
+// AnyJSON reports whether the value at path, or any element when it is an
+// array, matches q. It stops reading as soon as a match is found.
+func AnyJSON(r io.Reader, path string, q evaluator.Query, opts ...any) (bool, error) {
+	matched := false
+	err := EachJSON(r, path, func(v interface{}) error {
+		ok, err := q.Evaluate(v, opts...)
+		if err != nil {
+			return err
+		}
+		if ok {
+			matched = true
+			return errStop
+		}
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		err = nil
+	}
+	return matched, err
+}
+
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// seek advances dec to the value at path.
+func seek(dec *json.Decoder, path []string) error {
+	for depth, key := range path {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '{' {
+			return fmt.Errorf("path %s: expected object", strings.Join(path[:depth], "."))
+		}
+		for {
+			if !dec.More() {
+				return fmt.Errorf("path %s: key %q not found", strings.Join(path[:depth], "."), key)
+			}
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if tok == key {
+				break
+			}
+			if err := skip(dec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skip consumes the next value from dec without decoding it.
+func skip(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// finishValue decodes the remainder of a value whose first token has already
+// been read.
+func finishValue(dec *json.Decoder, first json.Token) (interface{}, error) {
+	d, ok := first.(json.Delim)
+	if !ok {
+		return first, nil
+	}
+	switch d {
+	case '{':
+		m := map[string]interface{}{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			m[key.(string)] = v
+		}
+		_, err := dec.Token()
+		return m, err
+	default:
+		return nil, fmt.Errorf("unexpected %v", d)
+	}
+}
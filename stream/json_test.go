@@ -0,0 +1,109 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+)
+
+func TestEachJSON(t *testing.T) {
+	doc := `{"skip": {"deep": [1, 2, {"x": [3]}]}, "data": {"other": "x", "items": [{"n": 1}, {"n": 2}, {"n": 3}]}}`
+	var got []float64
+	err := EachJSON(strings.NewReader(doc), "data.items", func(v interface{}) error {
+		got = append(got, v.(map[string]interface{})["n"].(float64))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachJSON: %v", err)
+	}
+	if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestEachJSONRoot(t *testing.T) {
+	for _, path := range []string{"", "$"} {
+		n := 0
+		err := EachJSON(strings.NewReader(`[{"a": 1}, {"a": 2}]`), path, func(v interface{}) error {
+			n++
+			return nil
+		})
+		if err != nil || n != 2 {
+			t.Errorf("path %q: n=%d err=%v", path, n, err)
+		}
+	}
+}
+
+func TestEachJSONObjectValue(t *testing.T) {
+	var got interface{}
+	err := EachJSON(strings.NewReader(`{"cfg": {"env": "prod", "n": [1]}}`), "cfg", func(v interface{}) error {
+		got = v
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachJSON: %v", err)
+	}
+	if m, ok := got.(map[string]interface{}); !ok || m["env"] != "prod" {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestEachJSONMissingPath(t *testing.T) {
+	err := EachJSON(strings.NewReader(`{"a": {"b": 1}}`), "a.c", func(interface{}) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), `"c" not found`) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	err = EachJSON(strings.NewReader(`{"a": 1}`), "a.b", func(interface{}) error { return nil })
+	if err == nil {
+		t.Errorf("expected error for non-object")
+	}
+}
+
+// stopReader fails the test if it is read past limit bytes.
+type stopReader struct {
+	r     io.Reader
+	read  int
+	limit int
+}
+
+func (s *stopReader) Read(p []byte) (int, error) {
+	if s.read > s.limit {
+		return 0, errors.New("read past match")
+	}
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	n, err := s.r.Read(p)
+	s.read += n
+	return n, err
+}
+
+func TestAnyJSONStopsAtMatch(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`{"items": [{"n": 1}, {"n": 42}`)
+	prefix := b.Len()
+	for i := 0; i < 10000; i++ {
+		b.WriteString(`, {"n": 0}`)
+	}
+	b.WriteString(`]}`)
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "n", Value: 42}}
+	r := &stopReader{r: strings.NewReader(b.String()), limit: prefix + 4096}
+	ok, err := AnyJSON(r, "items", q)
+	if err != nil {
+		t.Fatalf("AnyJSON: %v", err)
+	}
+	if !ok {
+		t.Errorf("expected match")
+	}
+}
+
+func TestAnyJSONNoMatch(t *testing.T) {
+	q := evaluator.Query{Expression: &evaluator.IsExpression{Field: "n", Value: 5}}
+	ok, err := AnyJSON(strings.NewReader(`{"items": [{"n": 1}, {"n": 2}]}`), "items", q)
+	if err != nil || ok {
+		t.Errorf("got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,24 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// keyString formats a record value as a join or set key. Numbers are written
+// in full, so an ID of 1234567 decoded from JSON as a float64 is "1234567"
+// rather than "1.234567e+06".
+func keyString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case json.Number:
+		return x.String()
+	}
+	return fmt.Sprint(v)
+}
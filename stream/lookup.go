@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errNoField is returned by joined.Get for names it cannot resolve.
+var errNoField = errors.New("no such field")
+
+// Lookup is a table joined to records by the value of Field. Its rows are
+// exposed to queries as NAME.COLUMN fields, and NAME itself is true when the
+// record has a matching row.
+type Lookup struct {
+	Name  string
+	Field string
+	Rows  map[string]map[string]string
+}
+
+// ParseLookup loads a lookup described as NAME=FIELD:FILE, such as
+// "churned=UserID:churned.csv". FILE is a CSV file with a header row whose
+// first column holds the key.
+func ParseLookup(spec string) (*Lookup, error) {
+	name, rest, ok := strings.Cut(spec, "=")
+	if !ok {
+		return nil, fmt.Errorf("lookup %q: expected NAME=FIELD:FILE", spec)
+	}
+	field, path, ok := strings.Cut(rest, ":")
+	if !ok || name == "" || field == "" || path == "" {
+		return nil, fmt.Errorf("lookup %q: expected NAME=FIELD:FILE", spec)
+	}
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	l, err := ReadLookupCSV(name, field, fh)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// ReadLookupCSV reads a lookup table from CSV with a header row. Rows are
+// keyed by their first column; later duplicates replace earlier ones.
+func ReadLookupCSV(name, field string, r io.Reader) (*Lookup, error) {
+	cr := csv.NewReader(r)
+	headers, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	l := &Lookup{Name: name, Field: field, Rows: map[string]map[string]string{}}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return l, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(headers))
+		for i, h := range headers {
+			if i < len(rec) {
+				row[h] = rec[i]
+			}
+		}
+		l.Rows[rec[0]] = row
+	}
+}
+
+// row returns the row joined to rec.
+func (l *Lookup) row(rec map[string]interface{}) (map[string]string, bool) {
+	v, ok := rec[l.Field]
+	if !ok || v == nil {
+		return nil, false
+	}
+	row, ok := l.Rows[keyString(v)]
+	return row, ok
+}
+
+// Join returns a value that resolves fields from rec first and then from
+// lookups, suitable for passing to Query.Evaluate.
+func Join(rec map[string]interface{}, lookups ...*Lookup) interface{} {
+	return &joined{rec: rec, lookups: lookups}
+}
+
+type joined struct {
+	rec     map[string]interface{}
+	lookups []*Lookup
+}
+
+func (j joined) Get(name string) (interface{}, error) {
+	if v, ok := j.rec[name]; ok {
+		return v, nil
+	}
+	for _, l := range j.lookups {
+		if name == l.Name {
+			_, ok := l.row(j.rec)
+			return ok, nil
+		}
+		col, ok := strings.CutPrefix(name, l.Name+".")
+		if !ok {
+			continue
+		}
+		if row, ok := l.row(j.rec); ok {
+			if v, ok := row[col]; ok {
+				return v, nil
+			}
+		}
+		return nil, errNoField
+	}
+	return nil, errNoField
+}
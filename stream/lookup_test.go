@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+func TestParseLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "churned.csv")
+	if err := os.WriteFile(path, []byte("id,reason\n7,price\n9,support\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := ParseLookup("churned=UserID:" + path)
+	if err != nil {
+		t.Fatalf("ParseLookup: %v", err)
+	}
+	if l.Name != "churned" || l.Field != "UserID" || l.Rows["9"]["reason"] != "support" {
+		t.Errorf("unexpected lookup %+v", l)
+	}
+	for _, bad := range []string{"churned", "churned=UserID", "=UserID:" + path} {
+		if _, err := ParseLookup(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestFilterLookup(t *testing.T) {
+	l, err := ReadLookupCSV("churned", "UserID", strings.NewReader("id,reason\n7,price\n9,support\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `{"UserID": 7, "event": "login"}
+{"UserID": 8, "event": "login"}
+{"UserID": 9, "event": "logout"}
+`
+	for expr, want := range map[string]string{
+		`churned is true`:                                "7,9",
+		`churned is false`:                               "8",
+		`churned.reason is "support"`:                    "9",
+		`event is "login" and churned.reason is "price"`: "7",
+	} {
+		q, err := simple.Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		ch := make(chan Record, 3)
+		f := &Filter{Query: q, Lookups: []*Lookup{l}}
+		if err := f.Run(strings.NewReader(input), ChanSink(ch)); err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		close(ch)
+		var ids []string
+		for r := range ch {
+			ids = append(ids, fmt.Sprint(r["UserID"]))
+		}
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("%s: got %s, want %s", expr, got, want)
+		}
+	}
+}
+
+func TestFilterLookupLargeID(t *testing.T) {
+	l, err := ReadLookupCSV("vip", "UserID", strings.NewReader("id,tier\n1234567,gold\n100000000000,platinum\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := simple.Parse(`vip.tier is "gold" or vip.tier is "platinum"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan Record, 3)
+	f := &Filter{Query: q, Lookups: []*Lookup{l}}
+	input := "{\"UserID\": 1234567}\n{\"UserID\": 7654321}\n{\"UserID\": 100000000000}\n"
+	if err := f.Run(strings.NewReader(input), ChanSink(ch)); err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+	if n := len(ch); n != 2 {
+		t.Errorf("expected the two large IDs to join, got %d records", n)
+	}
+}
+
+func TestFilterLookupBeyondFloat64(t *testing.T) {
+	l, err := ReadLookupCSV("vip", "UserID", strings.NewReader("id,tier\n12345678901234567891,gold\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := simple.Parse(`vip.tier is "gold"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	f := &Filter{Query: q, Lookups: []*Lookup{l}}
+	input := "{\"UserID\": 12345678901234567890}\n{\"UserID\": 12345678901234567891}\n"
+	sink := NewWriterSink(&out)
+	if err := f.Run(strings.NewReader(input), sink); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != `{"UserID":12345678901234567891}` {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/arran4/go-evaluator"
+)
+
+// SetOp names an operation combining the keys matched by two queries.
+type SetOp string
+
+const (
+	Union        SetOp = "union"
+	Intersection SetOp = "intersection"
+	Difference   SetOp = "difference"
+)
+
+// KeySets holds the Key values of the records matched by two queries. A key
+// belongs to a set when any record carrying it matches that set's query.
+type KeySets struct {
+	order []string
+	a, b  map[string]struct{}
+}
+
+// CollectKeySets reads JSON records from r in a single pass and collects the
+// values of key for records matching a and for records matching b. Records
+// without key are ignored. Numbers are decoded as json.Number so that numeric
+// keys beyond the precision of float64 stay distinct.
+func CollectKeySets(r io.Reader, key string, a, b evaluator.Query, opts ...any) (*KeySets, error) {
+	s := &KeySets{a: map[string]struct{}{}, b: map[string]struct{}{}}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return s, nil
+			}
+			return nil, err
+		}
+		kv, ok := m[key]
+		if !ok || kv == nil {
+			continue
+		}
+		k := keyString(kv)
+		inA, err := a.Evaluate(m, opts...)
+		if err != nil {
+			return nil, err
+		}
+		inB, err := b.Evaluate(m, opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.add(k, inA, inB)
+	}
+}
+
+func (s *KeySets) add(k string, inA, inB bool) {
+	if !inA && !inB {
+		return
+	}
+	_, seenA := s.a[k]
+	_, seenB := s.b[k]
+	if !seenA && !seenB {
+		s.order = append(s.order, k)
+	}
+	if inA {
+		s.a[k] = struct{}{}
+	}
+	if inB {
+		s.b[k] = struct{}{}
+	}
+}
+
+// Result returns the keys produced by op in the order they were first seen.
+func (s *KeySets) Result(op SetOp) ([]string, error) {
+	var keep func(inA, inB bool) bool
+	switch op {
+	case Union:
+		keep = func(inA, inB bool) bool { return inA || inB }
+	case Intersection:
+		keep = func(inA, inB bool) bool { return inA && inB }
+	case Difference:
+		keep = func(inA, inB bool) bool { return inA && !inB }
+	default:
+		return nil, fmt.Errorf("unknown set operation %q", op)
+	}
+	var out []string
+	for _, k := range s.order {
+		_, inA := s.a[k]
+		_, inB := s.b[k]
+		if keep(inA, inB) {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arran4/go-evaluator/parser/simple"
+)
+
+func TestKeySets(t *testing.T) {
+	input := `{"user": "a", "event": "signup"}
+{"user": "b", "event": "signup"}
+{"user": "a", "event": "purchase"}
+{"user": "c", "event": "purchase"}
+{"event": "purchase"}
+{"user": "d", "event": "view"}
+`
+	a, _ := simple.Parse(`event is "signup"`)
+	b, _ := simple.Parse(`event is "purchase"`)
+	s, err := CollectKeySets(strings.NewReader(input), "user", a, b)
+	if err != nil {
+		t.Fatalf("CollectKeySets: %v", err)
+	}
+	for op, want := range map[SetOp]string{
+		Union:        "a,b,c",
+		Intersection: "a",
+		Difference:   "b",
+	} {
+		got, err := s.Result(op)
+		if err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("%s: got %v, want %s", op, got, want)
+		}
+	}
+	if _, err := s.Result("xor"); err == nil {
+		t.Errorf("expected error for unknown operation")
+	}
+}
+
+func TestKeySetsLargeNumericKeys(t *testing.T) {
+	input := `{"user": 1234567, "event": "signup"}
+{"user": 1234568, "event": "purchase"}
+{"user": 1234567, "event": "purchase"}
+`
+	a, _ := simple.Parse(`event is "signup"`)
+	b, _ := simple.Parse(`event is "purchase"`)
+	s, err := CollectKeySets(strings.NewReader(input), "user", a, b)
+	if err != nil {
+		t.Fatalf("CollectKeySets: %v", err)
+	}
+	got, err := s.Result(Union)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "1234567,1234568" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestKeySetsKeysBeyondFloat64(t *testing.T) {
+	input := `{"id": 12345678901234567890, "event": "signup"}
+{"id": 12345678901234567891, "event": "purchase"}
+{"id": 12345678901234567891, "event": "signup"}
+`
+	a, _ := simple.Parse(`event is "signup"`)
+	b, _ := simple.Parse(`event is "purchase"`)
+	s, err := CollectKeySets(strings.NewReader(input), "id", a, b)
+	if err != nil {
+		t.Fatalf("CollectKeySets: %v", err)
+	}
+	if got, _ := s.Result(Intersection); strings.Join(got, ",") != "12345678901234567891" {
+		t.Errorf("intersection: got %v", got)
+	}
+	if got, _ := s.Result(Difference); strings.Join(got, ",") != "12345678901234567890" {
+		t.Errorf("difference: got %v", got)
+	}
+}
@@ -0,0 +1,171 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Record is a single decoded record passed to a Sink. Numbers in records
+// decoded by Filter are json.Number, keeping their exact text.
+type Record map[string]interface{}
+
+// Sink receives matching records. Write may buffer; Flush delivers anything
+// buffered. Filter.Run flushes its sink once the input is exhausted.
+type Sink interface {
+	Write(Record) error
+	Flush() error
+}
+
+// WriterSink writes records to an io.Writer as JSON Lines.
+type WriterSink struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewWriterSink returns a Sink writing JSON Lines to w. Output is buffered
+// until Flush.
+func NewWriterSink(w io.Writer) *WriterSink {
+	bw := bufio.NewWriter(w)
+	return &WriterSink{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+func (s *WriterSink) Write(r Record) error {
+	return s.enc.Encode(r)
+}
+
+func (s *WriterSink) Flush() error {
+	return s.bw.Flush()
+}
+
+// FileSink appends records to a file as JSON Lines.
+type FileSink struct {
+	*WriterSink
+	f *os.File
+}
+
+// NewFileSink opens path for appending, creating it if needed.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{WriterSink: NewWriterSink(f), f: f}, nil
+}
+
+// Flush writes buffered records and syncs the file.
+func (s *FileSink) Flush() error {
+	if err := s.WriterSink.Flush(); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// Close flushes and closes the file.
+func (s *FileSink) Close() error {
+	err := s.Flush()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// HTTPSink buffers records and POSTs them as a single JSON Lines body on each
+// Flush. Wrap it with Batch to bound the size of each request.
+type HTTPSink struct {
+	URL string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// Header is added to every request.
+	Header http.Header
+	// Context, when set, is attached to every request.
+	Context context.Context
+
+	buf bytes.Buffer
+}
+
+func (s *HTTPSink) Write(r Record) error {
+	return json.NewEncoder(&s.buf).Encode(r)
+}
+
+func (s *HTTPSink) Flush() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(s.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post %s: %s", s.URL, resp.Status)
+	}
+	s.buf.Reset()
+	return nil
+}
+
+// ChanSink sends each record on a channel. Flush does nothing.
+type ChanSink chan<- Record
+
+func (s ChanSink) Write(r Record) error {
+	s <- r
+	return nil
+}
+
+func (s ChanSink) Flush() error {
+	return nil
+}
+
+// BatchSink groups records and flushes the underlying sink after every Size
+// records.
+type BatchSink struct {
+	Sink Sink
+	Size int
+
+	n int
+}
+
+// Batch wraps s so it is flushed after every size records.
+func Batch(s Sink, size int) *BatchSink {
+	return &BatchSink{Sink: s, Size: size}
+}
+
+func (b *BatchSink) Write(r Record) error {
+	if err := b.Sink.Write(r); err != nil {
+		return err
+	}
+	b.n++
+	if b.Size > 0 && b.n >= b.Size {
+		return b.Flush()
+	}
+	return nil
+}
+
+func (b *BatchSink) Flush() error {
+	b.n = 0
+	return b.Sink.Flush()
+}
@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriterSink(t *testing.T) {
+	var w bytes.Buffer
+	s := NewWriterSink(&w)
+	if err := s.Write(Record{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Len() != 0 {
+		t.Errorf("expected output to be buffered until Flush")
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "{\"a\":1}\n" {
+		t.Errorf("got %q", w.String())
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	for i := 0; i < 2; i++ {
+		s, err := NewFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Write(Record{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{\"i\":0}\n{\"i\":1}\n" {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestHTTPSinkBatches(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("content type %q", ct)
+		}
+		n := 0
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			n++
+		}
+		mu.Lock()
+		bodies = append(bodies, n)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	f := &Filter{Query: evenQuery()}
+	if err := f.Run(strings.NewReader(records(10)), Batch(&HTTPSink{URL: srv.URL}, 2)); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 3 || bodies[0] != 2 || bodies[1] != 2 || bodies[2] != 1 {
+		t.Errorf("unexpected batches %v", bodies)
+	}
+}
+
+func TestHTTPSinkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	s := &HTTPSink{URL: srv.URL}
+	if err := s.Write(Record{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
+
+func TestChanSink(t *testing.T) {
+	ch := make(chan Record, 10)
+	f := &Filter{Query: evenQuery(), Workers: 2}
+	if err := f.Run(strings.NewReader(records(6)), ChanSink(ch)); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	close(ch)
+	var got []string
+	for r := range ch {
+		got = append(got, r["n"].(json.Number).String())
+	}
+	if len(got) != 3 || got[0] != "0" || got[1] != "2" || got[2] != "4" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package stream
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Transform rewrites a record before it is evaluated, so that records from
+// sources that name or nest their fields differently can be filtered by the
+// same query. It may modify rec in place. Returning a nil Record drops the
+// record without evaluating it.
+type Transform func(rec Record) (Record, error)
+
+// Chain returns a Transform applying ts in order, stopping at the first that
+// drops the record or fails.
+func Chain(ts ...Transform) Transform {
+	return func(rec Record) (Record, error) {
+		for _, t := range ts {
+			var err error
+			if rec, err = t(rec); err != nil || rec == nil {
+				return nil, err
+			}
+		}
+		return rec, nil
+	}
+}
+
+// Flatten replaces nested objects with their leaves under dotted keys, so
+// {"user": {"id": 7}} becomes {"user.id": 7}. Lists are kept as they are.
+func Flatten(rec Record) (Record, error) {
+	out := make(Record, len(rec))
+	flattenInto(out, "", rec)
+	return out, nil
+}
+
+func flattenInto(out Record, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenInto(out, prefix+k+".", nested)
+			continue
+		}
+		out[prefix+k] = v
+	}
+}
+
+// LowerKeys lower-cases the keys of the record and of the objects nested in
+// it.
+func LowerKeys(rec Record) (Record, error) {
+	return Record(mapKeys(rec, strings.ToLower)), nil
+}
+
+// SnakeCaseKeys rewrites the keys of the record and of the objects nested in
+// it in snake_case, so userID, UserId and user-id all become user_id.
+func SnakeCaseKeys(rec Record) (Record, error) {
+	return Record(mapKeys(rec, snakeCase)), nil
+}
+
+// Rename returns a Transform that moves each field named by a key of names to
+// the name it maps to, replacing any field already there. Missing fields are
+// left missing.
+func Rename(names map[string]string) Transform {
+	return func(rec Record) (Record, error) {
+		for from, to := range names {
+			if v, ok := rec[from]; ok {
+				delete(rec, from)
+				rec[to] = v
+			}
+		}
+		return rec, nil
+	}
+}
+
+// mapKeys returns m with every key, including those of nested objects and of
+// objects in lists, passed through fn. Where two keys map to the same name
+// the one sorting last wins, so the result does not depend on map order.
+func mapKeys(m map[string]interface{}, fn func(string) string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	from := make(map[string]string, len(m))
+	for k, v := range m {
+		nk := fn(k)
+		if prev, ok := from[nk]; ok && prev > k {
+			continue
+		}
+		from[nk] = k
+		out[nk] = mapValueKeys(v, fn)
+	}
+	return out
+}
+
+func mapValueKeys(v interface{}, fn func(string) string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return mapKeys(v, fn)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = mapValueKeys(e, fn)
+		}
+		return out
+	}
+	return v
+}
+
+// snakeCase splits s into words at spaces, hyphens, underscores and case
+// changes, treating a run of capitals as one word, and joins them lower-cased
+// with underscores.
+func snakeCase(s string) string {
+	rs := []rune(s)
+	var b strings.Builder
+	for i, r := range rs {
+		if r == ' ' || r == '-' || r == '_' {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 && b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
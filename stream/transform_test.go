@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arran4/go-evaluator"
+)
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"userID":       "user_id",
+		"UserId":       "user_id",
+		"user-id":      "user_id",
+		"HTTPStatus":   "http_status",
+		"already_done": "already_done",
+		"Address Line": "address_line",
+		"ip4Address":   "ip4_address",
+	} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	rec := Record{"UserID": 7, "Address": map[string]interface{}{"PostCode": "6011"}, "Tags": []interface{}{map[string]interface{}{"Name": "x"}}}
+	got, err := Chain(SnakeCaseKeys, Flatten, Rename(map[string]string{"user_id": "id"}))(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Record{"id": 7, "address.post_code": "6011", "tags": []interface{}{map[string]interface{}{"name": "x"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	lower, _ := LowerKeys(Record{"Name": "a", "NAME": "b"})
+	if !reflect.DeepEqual(lower, Record{"name": "a"}) {
+		t.Errorf("got %v", lower)
+	}
+}
+
+func TestFilterTransform(t *testing.T) {
+	in := `{"UserName": "alice", "Age": 30}
+{"user_name": "bob", "age": 20}
+{"skip": true}
+`
+	f := &Filter{
+		Query: evaluator.Query{Expression: &evaluator.GreaterThanExpression{Field: "age", Value: 18}},
+		Transform: Chain(func(rec Record) (Record, error) {
+			if rec["skip"] == true {
+				return nil, nil
+			}
+			return rec, nil
+		}, SnakeCaseKeys),
+	}
+	var w bytes.Buffer
+	if err := f.JSON(strings.NewReader(in), &w); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"age\":30,\"user_name\":\"alice\"}\n{\"age\":20,\"user_name\":\"bob\"}\n"; w.String() != want {
+		t.Errorf("got %q, want %q", w.String(), want)
+	}
+	if s := f.Stats(); s.Records != 3 || s.Matched != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	f.Transform = func(Record) (Record, error) { return nil, errors.New("bad record") }
+	if err := f.JSON(strings.NewReader(in), &w); err == nil || err.Error() != "bad record" {
+		t.Errorf("expected the transform error, got %v", err)
+	}
+}
@@ -0,0 +1,340 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// typedTerm couples a Term with a Type field, as typedExpression does for
+// expressions, so terms nested in a ComparisonExpression can be marshaled and
+// unmarshaled.
+type typedTerm[T Term] struct {
+	Type string `json:"Type"`
+	Term T      `json:"Term"`
+}
+
+// jsonTerm marshals and unmarshals the Term it holds as a typedTerm. Terms
+// with Term fields use it to encode them.
+type jsonTerm struct {
+	Term Term
+}
+
+func (t jsonTerm) MarshalJSON() ([]byte, error) {
+	if t.Term == nil {
+		return []byte("null"), nil
+	}
+	return marshalTerm(t.Term)
+}
+
+func (t *jsonTerm) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		t.Term = nil
+		return nil
+	}
+	term, err := unmarshalTerm(data)
+	if err != nil {
+		return err
+	}
+	t.Term = term
+	return nil
+}
+
+// marshalTerm serializes a Term along with its type indicator. Pointers to
+// terms are encoded as the terms they point to. A FunctionExpression is
+// encoded by Name, so it must be registered in Context.Functions under that
+// name to evaluate once decoded.
+func marshalTerm(t Term) ([]byte, error) {
+	if v := reflect.ValueOf(t); v.Kind() == reflect.Ptr && !v.IsNil() {
+		if e, ok := v.Elem().Interface().(Term); ok {
+			t = e
+		}
+	}
+	switch term := t.(type) {
+	case Field:
+		return json.Marshal(typedTerm[Field]{Type: "Field", Term: term})
+	case Constant:
+		return json.Marshal(typedTerm[Constant]{Type: "Constant", Term: term})
+	case Self:
+		return json.Marshal(typedTerm[Self]{Type: "Self", Term: term})
+	case BoolType:
+		return json.Marshal(typedTerm[BoolType]{Type: "Bool", Term: term})
+	case If:
+		return json.Marshal(typedTerm[If]{Type: "If", Term: term})
+	case FunctionExpression:
+		if term.Name == "" {
+			return nil, fmt.Errorf("cannot marshal function without a name")
+		}
+		return json.Marshal(typedTerm[FunctionExpression]{Type: "Function", Term: term})
+	case AddTerm:
+		return json.Marshal(typedTerm[AddTerm]{Type: "Add", Term: term})
+	case SubTerm:
+		return json.Marshal(typedTerm[SubTerm]{Type: "Sub", Term: term})
+	case MulTerm:
+		return json.Marshal(typedTerm[MulTerm]{Type: "Mul", Term: term})
+	case DivTerm:
+		return json.Marshal(typedTerm[DivTerm]{Type: "Div", Term: term})
+	case ModTerm:
+		return json.Marshal(typedTerm[ModTerm]{Type: "Mod", Term: term})
+	case HashTerm:
+		return json.Marshal(typedTerm[HashTerm]{Type: "Hash", Term: term})
+	case RandomTerm:
+		return json.Marshal(typedTerm[RandomTerm]{Type: "Random", Term: term})
+	case BucketTerm:
+		return json.Marshal(typedTerm[BucketTerm]{Type: "Bucket", Term: term})
+	case CoalesceTerm:
+		return json.Marshal(typedTerm[CoalesceTerm]{Type: "Coalesce", Term: term})
+	case SumTerm:
+		return json.Marshal(typedTerm[SumTerm]{Type: "Sum", Term: term})
+	case MinTerm:
+		return json.Marshal(typedTerm[MinTerm]{Type: "Min", Term: term})
+	case MaxTerm:
+		return json.Marshal(typedTerm[MaxTerm]{Type: "Max", Term: term})
+	case AvgTerm:
+		return json.Marshal(typedTerm[AvgTerm]{Type: "Avg", Term: term})
+	default:
+		return nil, fmt.Errorf("unknown term type %T", t)
+	}
+}
+
+// unmarshalTerm decodes json data containing a typedTerm and returns the
+// underlying Term.
+func unmarshalTerm(data []byte) (Term, error) {
+	var hdr struct{ Type string }
+	if err := json.Unmarshal(data, &hdr); err != nil {
+		return nil, err
+	}
+	switch hdr.Type {
+	case "Field":
+		return decodeTerm[Field](data)
+	case "Constant":
+		return decodeTerm[Constant](data)
+	case "Self":
+		return decodeTerm[Self](data)
+	case "Bool":
+		return decodeTerm[BoolType](data)
+	case "If":
+		return decodeTerm[If](data)
+	case "Function":
+		return decodeTerm[FunctionExpression](data)
+	case "Add":
+		return decodeTerm[AddTerm](data)
+	case "Sub":
+		return decodeTerm[SubTerm](data)
+	case "Mul":
+		return decodeTerm[MulTerm](data)
+	case "Div":
+		return decodeTerm[DivTerm](data)
+	case "Mod":
+		return decodeTerm[ModTerm](data)
+	case "Hash":
+		return decodeTerm[HashTerm](data)
+	case "Random":
+		return decodeTerm[RandomTerm](data)
+	case "Bucket":
+		return decodeTerm[BucketTerm](data)
+	case "Coalesce":
+		return decodeTerm[CoalesceTerm](data)
+	case "Sum":
+		return decodeTerm[SumTerm](data)
+	case "Min":
+		return decodeTerm[MinTerm](data)
+	case "Max":
+		return decodeTerm[MaxTerm](data)
+	case "Avg":
+		return decodeTerm[AvgTerm](data)
+	default:
+		return nil, fmt.Errorf("unknown term type %q", hdr.Type)
+	}
+}
+
+func decodeTerm[T Term](data []byte) (Term, error) {
+	var tt typedTerm[T]
+	if err := json.Unmarshal(data, &tt); err != nil {
+		return nil, err
+	}
+	return tt.Term, nil
+}
+
+func (e ComparisonExpression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		LHS, RHS  jsonTerm
+		Operation string
+	}{jsonTerm{e.LHS}, jsonTerm{e.RHS}, e.Operation})
+}
+
+func (e *ComparisonExpression) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		LHS, RHS  jsonTerm
+		Operation string
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = ComparisonExpression{LHS: raw.LHS.Term, RHS: raw.RHS.Term, Operation: raw.Operation}
+	return nil
+}
+
+// UnmarshalJSON decodes Value, restoring money literals to MoneyValues.
+func (c *Constant) UnmarshalJSON(data []byte) error {
+	var raw struct{ Value interface{} }
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Value = decodeMoney(raw.Value)
+	return nil
+}
+
+func (b BoolType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct{ Term jsonTerm }{jsonTerm{b.Term}})
+}
+
+func (b *BoolType) UnmarshalJSON(data []byte) error {
+	var raw struct{ Term jsonTerm }
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	b.Term = raw.Term.Term
+	return nil
+}
+
+func (e If) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct{ Condition, Then, Else jsonTerm }{jsonTerm{e.Condition}, jsonTerm{e.Then}, jsonTerm{e.Else}})
+}
+
+func (e *If) UnmarshalJSON(data []byte) error {
+	var raw struct{ Condition, Then, Else jsonTerm }
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = If{Condition: raw.Condition.Term, Then: raw.Then.Term, Else: raw.Else.Term}
+	return nil
+}
+
+// MarshalJSON encodes the function by Name and Args; Func is not encoded.
+func (f FunctionExpression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name string
+		Args []jsonTerm
+	}{f.Name, jsonTerms(f.Args)})
+}
+
+func (f *FunctionExpression) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name string
+		Args []jsonTerm
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = FunctionExpression{Name: raw.Name, Args: terms(raw.Args)}
+	return nil
+}
+
+func (c CoalesceTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct{ Terms []jsonTerm }{jsonTerms(c.Terms)})
+}
+
+func (c *CoalesceTerm) UnmarshalJSON(data []byte) error {
+	var raw struct{ Terms []jsonTerm }
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Terms = terms(raw.Terms)
+	return nil
+}
+
+func (h HashTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Term jsonTerm
+		Salt string
+	}{jsonTerm{h.Term}, h.Salt})
+}
+
+func (h *HashTerm) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Term jsonTerm
+		Salt string
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*h = HashTerm{Term: raw.Term.Term, Salt: raw.Salt}
+	return nil
+}
+
+// binaryTerm is the encoding of the arithmetic terms.
+type binaryTerm struct {
+	LHS, RHS jsonTerm
+}
+
+func (a AddTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(binaryTerm{jsonTerm{a.LHS}, jsonTerm{a.RHS}})
+}
+
+func (s SubTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(binaryTerm{jsonTerm{s.LHS}, jsonTerm{s.RHS}})
+}
+
+func (m MulTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(binaryTerm{jsonTerm{m.LHS}, jsonTerm{m.RHS}})
+}
+
+func (d DivTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(binaryTerm{jsonTerm{d.LHS}, jsonTerm{d.RHS}})
+}
+
+func (m ModTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(binaryTerm{jsonTerm{m.LHS}, jsonTerm{m.RHS}})
+}
+
+func (a *AddTerm) UnmarshalJSON(data []byte) error {
+	return unmarshalBinaryTerm(data, &a.LHS, &a.RHS)
+}
+
+func (s *SubTerm) UnmarshalJSON(data []byte) error {
+	return unmarshalBinaryTerm(data, &s.LHS, &s.RHS)
+}
+
+func (m *MulTerm) UnmarshalJSON(data []byte) error {
+	return unmarshalBinaryTerm(data, &m.LHS, &m.RHS)
+}
+
+func (d *DivTerm) UnmarshalJSON(data []byte) error {
+	return unmarshalBinaryTerm(data, &d.LHS, &d.RHS)
+}
+
+func (m *ModTerm) UnmarshalJSON(data []byte) error {
+	return unmarshalBinaryTerm(data, &m.LHS, &m.RHS)
+}
+
+func unmarshalBinaryTerm(data []byte, lhs, rhs *Term) error {
+	var raw binaryTerm
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*lhs, *rhs = raw.LHS.Term, raw.RHS.Term
+	return nil
+}
+
+func jsonTerms(ts []Term) []jsonTerm {
+	if ts == nil {
+		return nil
+	}
+	out := make([]jsonTerm, len(ts))
+	for n, t := range ts {
+		out[n] = jsonTerm{t}
+	}
+	return out
+}
+
+func terms(ts []jsonTerm) []Term {
+	if ts == nil {
+		return nil
+	}
+	out := make([]Term, len(ts))
+	for n, t := range ts {
+		out[n] = t.Term
+	}
+	return out
+}
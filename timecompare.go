@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"reflect"
+	"time"
+)
+
+// BeforeExpression succeeds when the timestamp in Field is earlier than
+// Value. Either may be a time.Time or a string, parsed as RFC 3339 or with
+// the first of Layouts that fits, so mixed formats compare as instants
+// rather than as text. Strings without a zone are taken as UTC. A field or
+// value that cannot be parsed does not match.
+type BeforeExpression struct {
+	Field   string
+	Value   interface{}
+	Layouts []string `json:",omitempty"`
+}
+
+func (e BeforeExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	c, ok := compareTimes(i, e.Field, e.Value, e.Layouts)
+	return ok && c < 0, nil
+}
+
+// AfterExpression succeeds when the timestamp in Field is later than Value.
+// Timestamps are parsed as for BeforeExpression.
+type AfterExpression struct {
+	Field   string
+	Value   interface{}
+	Layouts []string `json:",omitempty"`
+}
+
+func (e AfterExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	c, ok := compareTimes(i, e.Field, e.Value, e.Layouts)
+	return ok && c > 0, nil
+}
+
+// compareTimes compares the timestamp in field of i with value, reporting
+// false when either is missing or cannot be parsed.
+func compareTimes(i interface{}, field string, value interface{}, layouts []string) (int, bool) {
+	ft, ok := fieldTime(i, field, layouts)
+	if !ok {
+		return 0, false
+	}
+	vt, ok := timeValue(value, layouts)
+	if !ok {
+		return 0, false
+	}
+	return ft.Compare(vt), true
+}
+
+// fieldTime returns the time.Time or timestamp string held in field of i.
+func fieldTime(i interface{}, field string, layouts []string) (time.Time, bool) {
+	v, ok := derefValue(i)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, ok := getField(v, field)
+	if !ok {
+		return time.Time{}, false
+	}
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return time.Time{}, false
+		}
+		f = f.Elem()
+	}
+	switch {
+	case f.Type() == timeType:
+		return f.Interface().(time.Time), true
+	case f.Kind() == reflect.String:
+		return parseTime(f.String(), layouts)
+	}
+	return time.Time{}, false
+}
+
+// timeValue converts a time.Time or a timestamp string into a time.Time.
+func timeValue(v interface{}, layouts []string) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t != nil {
+			return *t, true
+		}
+	case string:
+		return parseTime(t, layouts)
+	}
+	return time.Time{}, false
+}
+
+// parseTime parses s as RFC 3339 or, failing that, with each of layouts in
+// turn.
+func parseTime(s string, layouts []string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
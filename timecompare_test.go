@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBeforeAfterExpressions(t *testing.T) {
+	type rec struct {
+		At      time.Time
+		Stamp   string
+		Day     string
+		Pointer *time.Time
+		Count   int
+	}
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := &rec{
+		At:      at,
+		Stamp:   "2024-06-01T14:00:00+02:00",
+		Day:     "01/06/2024",
+		Pointer: &at,
+		Count:   3,
+	}
+	dayLayouts := []string{"02/01/2006"}
+	tests := []struct {
+		name string
+		e    Expression
+		want bool
+	}{
+		{"time before", BeforeExpression{Field: "At", Value: at.Add(time.Second)}, true},
+		{"time equal", BeforeExpression{Field: "At", Value: at}, false},
+		{"time after string", AfterExpression{Field: "At", Value: "2024-06-01T11:59:59Z"}, true},
+		{"offset string equal", AfterExpression{Field: "Stamp", Value: at}, false},
+		{"offset string before", BeforeExpression{Field: "Stamp", Value: "2024-06-01T14:00:00+01:00"}, true},
+		{"lexically later", AfterExpression{Field: "Stamp", Value: "2024-06-01T11:00:00Z"}, true},
+		{"layout", BeforeExpression{Field: "Day", Value: "2024-06-02T00:00:00Z", Layouts: dayLayouts}, true},
+		{"layout value", AfterExpression{Field: "At", Value: "31/05/2024", Layouts: dayLayouts}, true},
+		{"no layout", BeforeExpression{Field: "Day", Value: "2024-06-02T00:00:00Z"}, false},
+		{"pointer", BeforeExpression{Field: "Pointer", Value: "2025-01-01T00:00:00Z"}, true},
+		{"bad value", AfterExpression{Field: "At", Value: "yesterday"}, false},
+		{"not a time", AfterExpression{Field: "Count", Value: "2024-01-01T00:00:00Z"}, false},
+		{"missing", AfterExpression{Field: "Other", Value: at}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.Evaluate(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBeforeAfterJSON(t *testing.T) {
+	q := Query{Expression: &AndExpression{Expressions: []Query{
+		{Expression: &AfterExpression{Field: "Day", Value: "2024-01-01T00:00:00Z", Layouts: []string{time.DateOnly}}},
+		{Expression: &BeforeExpression{Field: "Day", Value: "2024-12-31"}},
+	}}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Query
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	and := got.Expression.(*AndExpression)
+	if a, ok := and.Expressions[0].Expression.(*AfterExpression); !ok || a.Layouts[0] != time.DateOnly {
+		t.Fatalf("unexpected after %#v", and.Expressions[0].Expression)
+	}
+	if ok, err := got.Evaluate(map[string]interface{}{"Day": "2024-06-01"}); err != nil || ok {
+		t.Errorf("expected no match without layout on Before: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,43 @@
+package evaluator
+
+import "reflect"
+
+// TypeOfExpression succeeds when the dynamic type of Field matches Kind.
+// Kind may be a reflect kind such as "string", "float64", "slice" or "map",
+// a type name such as "time.Time", "number" for any numeric kind, or "null"
+// for a nil value. Missing fields never match.
+type TypeOfExpression struct {
+	Field string
+	Kind  string
+}
+
+func (e TypeOfExpression) Evaluate(i interface{}, _ ...any) (bool, error) {
+	v, ok := derefValue(i)
+	if !ok {
+		return false, nil
+	}
+	f, ok := getField(v, e.Field)
+	if !ok {
+		return false, nil
+	}
+	for f.Kind() == reflect.Interface && !f.IsNil() {
+		f = f.Elem()
+	}
+	if !f.IsValid() {
+		return e.Kind == "null", nil
+	}
+	switch f.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+		if f.IsNil() && e.Kind == "null" {
+			return true, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if e.Kind == "number" {
+			return true, nil
+		}
+	}
+	t := f.Type()
+	return e.Kind == f.Kind().String() || e.Kind == t.String() || (t.Name() != "" && e.Kind == t.Name()), nil
+}
@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTypeOfExpression(t *testing.T) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"s":"x","n":1.5,"b":true,"l":[1],"o":{"a":1},"z":null}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	m["t"] = time.Now()
+	m["i"] = 3
+	tests := []struct {
+		field, kind string
+		want        bool
+	}{
+		{"s", "string", true},
+		{"s", "number", false},
+		{"n", "number", true},
+		{"n", "float64", true},
+		{"i", "number", true},
+		{"i", "int", true},
+		{"i", "float64", false},
+		{"b", "bool", true},
+		{"l", "slice", true},
+		{"l", "[]interface {}", true},
+		{"o", "map", true},
+		{"z", "null", true},
+		{"z", "string", false},
+		{"t", "time.Time", true},
+		{"t", "Time", true},
+		{"t", "struct", true},
+		{"missing", "null", false},
+	}
+	for _, tt := range tests {
+		got, err := TypeOfExpression{Field: tt.field, Kind: tt.kind}.Evaluate(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: got %v, want %v", tt.field, tt.kind, got, tt.want)
+		}
+	}
+	type rec struct{ P *int }
+	if ok, _ := (TypeOfExpression{Field: "P", Kind: "null"}).Evaluate(&rec{}); !ok {
+		t.Errorf("nil pointer should be null")
+	}
+}
+
+func TestTypeOfExpressionJSON(t *testing.T) {
+	q := Query{Expression: &TypeOfExpression{Field: "id", Kind: "number"}}
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Expression":{"Type":"TypeOf","Expression":{"Field":"id","Kind":"number"}}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var back Query
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := back.Expression.(*TypeOfExpression); !ok || e.Kind != "number" {
+		t.Errorf("unexpected expression %#v", back.Expression)
+	}
+}
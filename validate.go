@@ -0,0 +1,169 @@
+package evaluator
+
+import "fmt"
+
+// NewMatchesExpression returns a MatchesExpression whose pattern has already
+// been compiled and cached, so a bad pattern is reported here rather than by
+// Evaluate.
+func NewMatchesExpression(field, pattern string) (*MatchesExpression, error) {
+	e := &MatchesExpression{Field: field, Pattern: pattern}
+	return e, validateExpr(e)
+}
+
+// NewGlobExpression returns a GlobExpression, or ErrBadGlob when pattern ends
+// in an unfinished escape.
+func NewGlobExpression(field, pattern string) (*GlobExpression, error) {
+	e := &GlobExpression{Field: field, Pattern: pattern}
+	return e, validateExpr(e)
+}
+
+// NewCIDRContainsExpression returns a CIDRContainsExpression whose range has
+// already been parsed, reporting an error for an invalid CIDR.
+func NewCIDRContainsExpression(field, cidr string) (*CIDRContainsExpression, error) {
+	e := &CIDRContainsExpression{Field: field, CIDR: cidr}
+	return e, validateExpr(e)
+}
+
+// NewBeforeExpression returns a BeforeExpression, reporting an error when
+// value is not a time.Time or a timestamp in RFC 3339 or one of layouts.
+func NewBeforeExpression(field string, value interface{}, layouts ...string) (*BeforeExpression, error) {
+	e := &BeforeExpression{Field: field, Value: value, Layouts: layouts}
+	return e, validateExpr(e)
+}
+
+// NewAfterExpression returns an AfterExpression, validating value as
+// NewBeforeExpression does.
+func NewAfterExpression(field string, value interface{}, layouts ...string) (*AfterExpression, error) {
+	e := &AfterExpression{Field: field, Value: value, Layouts: layouts}
+	return e, validateExpr(e)
+}
+
+// NewWithinDurationExpression returns a WithinDurationExpression measured
+// from the Context clock, reporting an error for a malformed duration.
+func NewWithinDurationExpression(field, duration string) (*WithinDurationExpression, error) {
+	e := &WithinDurationExpression{Field: field, Duration: duration}
+	return e, validateExpr(e)
+}
+
+// NewCountExpression returns a CountExpression, reporting an error when op
+// is unknown or value is not a number. The sub-query is validated too.
+func NewCountExpression(field string, q Query, op string, value interface{}) (*CountExpression, error) {
+	e := &CountExpression{Field: field, Query: q, Op: op, Value: value}
+	return e, validateExpr(e)
+}
+
+// NewRolloutExpression returns a RolloutExpression, reporting an error when
+// percent is outside 0-100.
+func NewRolloutExpression(keyField string, percent float64, salt string) (*RolloutExpression, error) {
+	e := &RolloutExpression{KeyField: keyField, Percent: percent, Salt: salt}
+	return e, validateExpr(e)
+}
+
+// NewInFileExpression returns an InFileExpression with the file at path
+// already loaded, so a missing or unreadable file is reported here.
+func NewInFileExpression(field, path string, bloom bool) (*InFileExpression, error) {
+	e := &InFileExpression{Field: field, Path: path, Bloom: bloom}
+	return e, validateExpr(e)
+}
+
+// Validate checks every expression in q the way the constructors do and
+// returns the first problem found. Use it on queries that were decoded from
+// storage rather than built with the constructors, so that a bad pattern or
+// timestamp is caught when the query is loaded instead of when it first runs.
+func Validate(q Query) error {
+	if q.Expression == nil {
+		return nil
+	}
+	return validateExpr(q.Expression)
+}
+
+func validateExpr(e Expression) error {
+	switch ex := e.(type) {
+	case *MatchesExpression:
+		if _, err := ex.regexp(); err != nil {
+			return fmt.Errorf("matches %s: %w", ex.Field, err)
+		}
+	case *GlobExpression:
+		for i := 0; i < len(ex.Pattern); i++ {
+			if ex.Pattern[i] == '\\' {
+				if i == len(ex.Pattern)-1 {
+					return fmt.Errorf("glob %s: %w", ex.Field, ErrBadGlob)
+				}
+				i++
+			}
+		}
+	case *CIDRContainsExpression:
+		if _, err := ex.parsePrefix(); err != nil {
+			return fmt.Errorf("cidr %s: %w", ex.Field, err)
+		}
+	case *BeforeExpression:
+		if _, ok := timeValue(ex.Value, ex.Layouts); !ok {
+			return fmt.Errorf("before %s: invalid time %v", ex.Field, ex.Value)
+		}
+	case *AfterExpression:
+		if _, ok := timeValue(ex.Value, ex.Layouts); !ok {
+			return fmt.Errorf("after %s: invalid time %v", ex.Field, ex.Value)
+		}
+	case *WithinDurationExpression:
+		if _, err := ParseDuration(ex.Duration); err != nil {
+			return fmt.Errorf("within %s: %w", ex.Field, err)
+		}
+		if _, ok := timeValue(ex.From, nil); ex.From != nil && !ok {
+			return fmt.Errorf("within %s: invalid From time %v", ex.Field, ex.From)
+		}
+	case *CountExpression:
+		n, ok := numeric[float64](ex.Value)
+		if !ok {
+			return fmt.Errorf("count of %s: value %v is not a number", ex.Field, ex.Value)
+		}
+		if _, known := compareCount(0, ex.Op, n); !known {
+			return fmt.Errorf("count of %s: unknown operation %q", ex.Field, ex.Op)
+		}
+		return Validate(ex.Query)
+	case *RolloutExpression:
+		if ex.Percent < 0 || ex.Percent > 100 {
+			return fmt.Errorf("rollout %s: percent %v is outside 0-100", ex.KeyField, ex.Percent)
+		}
+	case *InFileExpression:
+		if _, err := ex.load(); err != nil {
+			return fmt.Errorf("infile %s: %w", ex.Field, err)
+		}
+	case *AndExpression:
+		return validateQueries(ex.Expressions)
+	case *OrExpression:
+		return validateQueries(ex.Expressions)
+	case *XorExpression:
+		return validateQueries(ex.Expressions)
+	case *ImpliesExpression:
+		return validateQueries([]Query{ex.If, ex.Then})
+	case *CaseExpression:
+		for _, c := range ex.Cases {
+			if err := validateQueries([]Query{c.When, c.Then}); err != nil {
+				return err
+			}
+		}
+		return Validate(ex.Else)
+	case *NotExpression:
+		return Validate(ex.Expression)
+	case *AnyExpression:
+		return Validate(ex.Query)
+	case *AllExpression:
+		return Validate(ex.Query)
+	case *ScoreQuery:
+		for _, w := range ex.Expressions {
+			if err := Validate(w.Query); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateQueries(qs []Query) error {
+	for _, q := range qs {
+		if err := Validate(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConstructors(t *testing.T) {
+	if _, err := NewMatchesExpression("Name", "^b(o+)b$"); err != nil {
+		t.Errorf("matches: %v", err)
+	}
+	if _, err := NewMatchesExpression("Name", "(unclosed"); err == nil {
+		t.Error("matches: expected error for bad pattern")
+	}
+	if _, err := NewGlobExpression("Path", `/api/\*`); err != nil {
+		t.Errorf("glob: %v", err)
+	}
+	if _, err := NewGlobExpression("Path", `/api/\`); !errors.Is(err, ErrBadGlob) {
+		t.Errorf("glob: got %v, want ErrBadGlob", err)
+	}
+	if _, err := NewBeforeExpression("At", "01/06/2024", "02/01/2006"); err != nil {
+		t.Errorf("before: %v", err)
+	}
+	if _, err := NewAfterExpression("At", "01/06/2024"); err == nil {
+		t.Error("after: expected error without layout")
+	}
+	if _, err := NewWithinDurationExpression("At", "7d"); err != nil {
+		t.Errorf("within: %v", err)
+	}
+	if _, err := NewWithinDurationExpression("At", "a week"); err == nil {
+		t.Error("within: expected error for bad duration")
+	}
+	if _, err := NewCountExpression("Items", Query{}, "gte", 2); err != nil {
+		t.Errorf("count: %v", err)
+	}
+	if _, err := NewCountExpression("Items", Query{}, "atleast", 2); err == nil {
+		t.Error("count: expected error for unknown op")
+	}
+	if _, err := NewRolloutExpression("UserID", 120, ""); err == nil {
+		t.Error("rollout: expected error for percent over 100")
+	}
+	path := filepath.Join(t.TempDir(), "ids.txt")
+	if err := os.WriteFile(path, []byte("a\nb\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := NewInFileExpression("ID", path, false); err != nil {
+		t.Errorf("infile: %v", err)
+	} else if ok, _ := e.Evaluate(map[string]interface{}{"ID": "b"}); !ok {
+		t.Error("infile: expected match")
+	}
+	if _, err := NewInFileExpression("ID", path+".missing", false); err == nil {
+		t.Error("infile: expected error for missing file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	good := `{"Expression":{"Type":"And","Expression":{"Expressions":[
+		{"Expression":{"Type":"Matches","Expression":{"Field":"Name","Pattern":"^a"}}},
+		{"Expression":{"Type":"Not","Expression":{"Expression":{"Expression":{"Type":"Within","Expression":{"Field":"At","Duration":"24h"}}}}}}
+	]}}}`
+	bad := `{"Expression":{"Type":"Or","Expression":{"Expressions":[
+		{"Expression":{"Type":"Is","Expression":{"Field":"Name","Value":"x"}}},
+		{"Expression":{"Type":"Any","Expression":{"Field":"Items","Query":{"Expression":{"Type":"Matches","Expression":{"Field":"Name","Pattern":"["}}}}}}
+	]}}}`
+	var q Query
+	if err := json.Unmarshal([]byte(good), &q); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(q); err != nil {
+		t.Errorf("good: %v", err)
+	}
+	if err := json.Unmarshal([]byte(bad), &q); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(q); err == nil {
+		t.Error("bad: expected error")
+	}
+}
@@ -0,0 +1,87 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Warning describes a non-fatal issue found while parsing or unmarshaling a
+// query, such as a deprecated spelling that is still accepted today but may
+// be removed in a future release.
+type Warning struct {
+	// Code identifies the kind of warning, e.g. "deprecated-type".
+	Code string
+	// Path locates the offending node: a JSON path for unmarshaled queries or
+	// a byte offset for parsed expressions.
+	Path    string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Path == "" {
+		return w.Code + ": " + w.Message
+	}
+	return w.Code + " at " + w.Path + ": " + w.Message
+}
+
+// legacyTypeNames maps retired expression type names, still accepted when
+// unmarshaling, to their current names. No type name has been retired yet.
+var legacyTypeNames = map[string]string{}
+
+// UnmarshalQuery decodes a JSON query like json.Unmarshal, additionally
+// returning warnings for deprecated constructs it contains.
+func UnmarshalQuery(data []byte) (Query, []Warning, error) {
+	var q Query
+	if err := json.Unmarshal(data, &q); err != nil {
+		return Query{}, nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return Query{}, nil, err
+	}
+	var warnings []Warning
+	collectWarnings(tree, "$", &warnings)
+	return q, warnings, nil
+}
+
+func collectWarnings(node interface{}, path string, warnings *[]Warning) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if t, ok := typedExpressionType(n); ok {
+			if repl, ok := legacyTypeNames[t]; ok {
+				*warnings = append(*warnings, Warning{
+					Code:    "deprecated-type",
+					Path:    path,
+					Message: fmt.Sprintf("type %q is deprecated, use %q", t, repl),
+				})
+			}
+		}
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			collectWarnings(n[k], path+"."+k, warnings)
+		}
+	case []interface{}:
+		for i, v := range n {
+			collectWarnings(v, fmt.Sprintf("%s[%d]", path, i), warnings)
+		}
+	}
+}
+
+// typedExpressionType returns the Type of n when n is an expression node, an
+// object holding just a Type and an Expression as marshalExpression writes
+// it, so that literal values with a Type key are not mistaken for one.
+func typedExpressionType(n map[string]interface{}) (string, bool) {
+	if len(n) != 2 {
+		return "", false
+	}
+	if _, ok := n["Expression"]; !ok {
+		return "", false
+	}
+	t, ok := n["Type"].(string)
+	return t, ok
+}
@@ -0,0 +1,39 @@
+package evaluator
+
+import "testing"
+
+func TestUnmarshalQueryWarnings(t *testing.T) {
+	defer func(saved map[string]string) { legacyTypeNames = saved }(legacyTypeNames)
+	legacyTypeNames = map[string]string{"Equals": "Is", "GreaterThan": "GT"}
+
+	js := `{
+        "Expression": {
+            "Type": "And",
+            "Expression": {
+                "Expressions": [
+                    {"Expression": {"Type": "Equals", "Expression": {"Field": "Name", "Value": "bob"}}},
+                    {"Expression": {"Type": "GreaterThan", "Expression": {"Field": "Age", "Value": 30}}},
+                    {"Expression": {"Type": "In", "Expression": {"Field": "Kind", "Values": [{"Type": "Equals"}, {"Type": "Equals", "Expression": 1, "x": 2}]}}}
+                ]
+            }
+        }
+    }`
+	q, warnings, err := UnmarshalQuery([]byte(js))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if v, err := q.Evaluate(map[string]interface{}{"Name": "bob", "Age": 35, "Kind": map[string]interface{}{"Type": "Equals"}}); err != nil || !v {
+		t.Errorf("legacy query evaluate failed: %v %v", v, err)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+	want := Warning{Code: "deprecated-type", Path: "$.Expression.Expression.Expressions[0].Expression", Message: `type "Equals" is deprecated, use "Is"`}
+	if warnings[0] != want {
+		t.Errorf("expected %v, got %v", want, warnings[0])
+	}
+
+	if _, warnings, err := UnmarshalQuery([]byte(`{"Expression": {"Type": "Is", "Expression": {"Field": "Name", "Value": "bob"}}}`)); err != nil || len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v %v", warnings, err)
+	}
+}
@@ -0,0 +1,411 @@
+// Package webhookroute receives HTTP webhooks and forwards each one to the
+// destinations of the rules it matches, retrying failed deliveries.
+package webhookroute
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arran4/go-evaluator"
+	"github.com/arran4/go-evaluator/rules"
+)
+
+// Config is a rule set whose rules are routed to URLs. In JSON it is a rules
+// file with an extra Routes object:
+//
+//	{
+//	  "Name": "github",
+//	  "Rules": [{"Name": "pushes", "Query": {...}}],
+//	  "Routes": {"pushes": "https://ci.example.com/hooks/push"}
+//	}
+type Config struct {
+	rules.RuleSet
+	// Routes maps rule names to the URL requests matching the rule are
+	// forwarded to.
+	Routes map[string]string `json:"Routes"`
+}
+
+// LoadConfig decodes a JSON configuration and checks that every rule window
+// parses and every route names a rule and an http or https URL.
+func LoadConfig(r io.Reader) (*Config, error) {
+	var c Config
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, err
+	}
+	if err := c.Compile(); err != nil {
+		return nil, err
+	}
+	if len(c.Routes) == 0 {
+		return nil, errors.New("no routes")
+	}
+	names := map[string]bool{}
+	for _, rule := range c.Rules {
+		names[rule.Name] = true
+	}
+	for name, dest := range c.Routes {
+		if !names[name] {
+			return nil, fmt.Errorf("route %s: no such rule", name)
+		}
+		u, err := url.Parse(dest)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", name, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("route %s: %q is not an http or https URL", name, dest)
+		}
+	}
+	return &c, nil
+}
+
+// Stats describes the work done by a Router.
+type Stats struct {
+	Received     int64 // webhooks accepted
+	Unmatched    int64 // webhooks that matched no routed rule
+	Forwarded    int64 // deliveries, once per matching rule
+	Failed       int64 // deliveries that failed after every retry
+	Rejected     int64 // webhooks refused because MaxConcurrent were in progress
+	PeakInFlight int64 // most webhooks handled at once
+}
+
+// hopHeaders are not forwarded.
+var hopHeaders = []string{"Connection", "Content-Length", "Host", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// credentialHeaders are not forwarded unless named in Router.ForwardHeaders.
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Router is an http.Handler evaluating Config's rule set against each POSTed
+// webhook and forwarding the body, with the request headers, to the URL
+// routed from every matching rule. The Authorization, Cookie and
+// Proxy-Authorization headers are credentials for the router and are not
+// forwarded unless listed in ForwardHeaders.
+//
+// Rules see a record with the decoded JSON "body", the "headers" keyed by
+// their canonical names, such as X-Github-Event, with the value a string or,
+// when repeated, a list, and the request "method" and "path".
+//
+// Deliveries are attempted in order of the rules. A delivery that fails with
+// a network error, a 429 or a 5xx response is retried with exponential
+// backoff. The webhook is answered with 204 No Content once every delivery
+// succeeds, and with 502 Bad Gateway naming the rules whose deliveries failed
+// otherwise, so the sender's own retries take over. A retried webhook is
+// delivered again to every matching rule, including those that succeeded
+// the first time, so destinations should tolerate duplicates.
+//
+// With MaxConcurrent set, webhooks beyond that many wait up to QueueTimeout
+// for one in progress to finish and are otherwise refused with 429 Too Many
+// Requests and a Retry-After header, so a burst cannot exhaust the router or
+// its destinations.
+//
+// BeforeEval and AfterEval let a service account for the work each webhook
+// costs, for example to bill the teams sending them. The cost of a webhook
+// is the evaluator.Complexity of every rule query times the one record the
+// webhook is.
+type Router struct {
+	Config *Config
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// Retries is the number of times a delivery is retried. It defaults to 3;
+	// a negative value disables retries.
+	Retries int
+	// Backoff is the first retry delay, doubled after each attempt. It
+	// defaults to 100ms.
+	Backoff time.Duration
+	// MaxBody limits the size of webhook bodies. It defaults to 1 MiB.
+	MaxBody int64
+	// ForwardHeaders lists credential headers, such as Authorization, to
+	// forward to every destination rather than drop.
+	ForwardHeaders []string
+	// MaxConcurrent limits the webhooks handled at once. Zero means no
+	// limit.
+	MaxConcurrent int
+	// QueueTimeout is how long a webhook waits for a free slot when
+	// MaxConcurrent are in progress. Zero refuses it at once.
+	QueueTimeout time.Duration
+	// Logf, when set, reports failed evaluations and deliveries.
+	Logf func(format string, args ...any)
+	// BeforeEval, when set, is called with the cost of a webhook before its
+	// rules are evaluated. An error refuses the webhook with 429 Too Many
+	// Requests.
+	BeforeEval func(r *http.Request, cost int) error
+	// AfterEval, when set, is called with the cost of a webhook and the
+	// evaluation error, if any, once its rules have been evaluated.
+	AfterEval func(r *http.Request, cost int, err error)
+
+	slotsOnce sync.Once
+	slots     chan struct{}
+	inFlight  atomic.Int64
+	stats     struct {
+		received, unmatched, forwarded, failed, rejected, peak atomic.Int64
+	}
+}
+
+// Stats returns a snapshot of the router statistics. It is safe to call while
+// requests are being served.
+func (rt *Router) Stats() Stats {
+	return Stats{
+		Received:     rt.stats.received.Load(),
+		Unmatched:    rt.stats.unmatched.Load(),
+		Forwarded:    rt.stats.forwarded.Load(),
+		Failed:       rt.stats.failed.Load(),
+		Rejected:     rt.stats.rejected.Load(),
+		PeakInFlight: rt.stats.peak.Load(),
+	}
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !rt.acquire(r.Context()) {
+		rt.stats.rejected.Add(1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many webhooks in progress", http.StatusTooManyRequests)
+		return
+	}
+	defer rt.release()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, rt.maxBody()))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		http.Error(w, "body is not JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rt.stats.received.Add(1)
+	var cost int
+	if rt.BeforeEval != nil || rt.AfterEval != nil {
+		if cost, err = rt.cost(); err != nil {
+			rt.logf("webhook %s: %v", r.URL.Path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if rt.BeforeEval != nil {
+		if err := rt.BeforeEval(r, cost); err != nil {
+			rt.logf("webhook %s: %v", r.URL.Path, err)
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+	}
+	matched, err := rt.Config.Evaluate(record(r, v))
+	if rt.AfterEval != nil {
+		rt.AfterEval(r, cost, err)
+	}
+	if err != nil {
+		rt.logf("webhook %s: %v", r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var routed []string
+	for _, name := range matched {
+		if _, ok := rt.Config.Routes[name]; ok {
+			routed = append(routed, name)
+		}
+	}
+	if len(routed) == 0 {
+		rt.stats.unmatched.Add(1)
+	}
+	header := rt.forwardHeader(r.Header)
+	var failed []string
+	for _, name := range routed {
+		dest := rt.Config.Routes[name]
+		if err := rt.deliver(r.Context(), dest, header, body); err != nil {
+			rt.stats.failed.Add(1)
+			rt.logf("forward to %s: %v", dest, err)
+			failed = append(failed, name)
+			continue
+		}
+		rt.stats.forwarded.Add(1)
+	}
+	if len(failed) > 0 {
+		http.Error(w, fmt.Sprintf("%d of %d deliveries failed: %s", len(failed), len(routed), strings.Join(failed, ", ")), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cost returns the cost of evaluating the rules against one webhook.
+func (rt *Router) cost() (int, error) {
+	total := 0
+	for _, rule := range rt.Config.Rules {
+		n, err := evaluator.Complexity(rule.Query)
+		if err != nil {
+			return 0, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// acquire takes a slot for a webhook, waiting up to QueueTimeout, and
+// reports whether it got one.
+func (rt *Router) acquire(ctx context.Context) bool {
+	rt.slotsOnce.Do(func() {
+		if rt.MaxConcurrent > 0 {
+			rt.slots = make(chan struct{}, rt.MaxConcurrent)
+		}
+	})
+	if rt.slots != nil {
+		select {
+		case rt.slots <- struct{}{}:
+		default:
+			if rt.QueueTimeout <= 0 {
+				return false
+			}
+			timer := time.NewTimer(rt.QueueTimeout)
+			defer timer.Stop()
+			select {
+			case rt.slots <- struct{}{}:
+			case <-timer.C:
+				return false
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+	n := rt.inFlight.Add(1)
+	for {
+		peak := rt.stats.peak.Load()
+		if n <= peak || rt.stats.peak.CompareAndSwap(peak, n) {
+			return true
+		}
+	}
+}
+
+func (rt *Router) release() {
+	rt.inFlight.Add(-1)
+	if rt.slots != nil {
+		<-rt.slots
+	}
+}
+
+// record returns the value rules are evaluated against.
+func record(r *http.Request, body interface{}) map[string]interface{} {
+	headers := make(map[string]interface{}, len(r.Header))
+	for k, vs := range r.Header {
+		if len(vs) == 1 {
+			headers[k] = vs[0]
+			continue
+		}
+		list := make([]interface{}, len(vs))
+		for i, v := range vs {
+			list[i] = v
+		}
+		headers[k] = list
+	}
+	return map[string]interface{}{
+		"body":    body,
+		"headers": headers,
+		"method":  r.Method,
+		"path":    r.URL.Path,
+	}
+}
+
+// forwardHeader returns the headers of a webhook to send on to destinations.
+func (rt *Router) forwardHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range hopHeaders {
+		out.Del(k)
+	}
+	for _, k := range credentialHeaders {
+		forward := false
+		for _, f := range rt.ForwardHeaders {
+			forward = forward || http.CanonicalHeaderKey(f) == k
+		}
+		if !forward {
+			out.Del(k)
+		}
+	}
+	return out
+}
+
+// deliver POSTs body to dest, retrying temporary failures.
+func (rt *Router) deliver(ctx context.Context, dest string, header http.Header, body []byte) error {
+	delay := rt.backoff()
+	for attempt := 0; ; attempt++ {
+		retry, err := rt.post(ctx, dest, header, body)
+		if err == nil || !retry || attempt == rt.retries() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure is worth
+// retrying.
+func (rt *Router) post(ctx context.Context, dest string, header http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header = header.Clone()
+	client := rt.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, errors.New(resp.Status)
+}
+
+func (rt *Router) retries() int {
+	if rt.Retries < 0 {
+		return 0
+	}
+	if rt.Retries == 0 {
+		return 3
+	}
+	return rt.Retries
+}
+
+func (rt *Router) backoff() time.Duration {
+	if rt.Backoff <= 0 {
+		return 100 * time.Millisecond
+	}
+	return rt.Backoff
+}
+
+func (rt *Router) maxBody() int64 {
+	if rt.MaxBody <= 0 {
+		return 1 << 20
+	}
+	return rt.MaxBody
+}
+
+func (rt *Router) logf(format string, args ...any) {
+	if rt.Logf != nil {
+		rt.Logf(format, args...)
+	}
+}
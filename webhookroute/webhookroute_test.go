@@ -0,0 +1,277 @@
+package webhookroute
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// destination records the webhooks delivered to it, failing the first fail
+// attempts with status.
+type destination struct {
+	*httptest.Server
+	mu     sync.Mutex
+	fail   int
+	status int
+	got    []string
+}
+
+func newDestination(t *testing.T, fail, status int) *destination {
+	t.Helper()
+	d := &destination{fail: fail, status: status}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.fail > 0 {
+			d.fail--
+			w.WriteHeader(d.status)
+			return
+		}
+		d.got = append(d.got, r.Header.Get("X-Github-Event")+" "+string(body))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func (d *destination) received() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.got...)
+}
+
+func loadConfig(t *testing.T, routes map[string]*destination) *Config {
+	t.Helper()
+	r := strings.NewReplacer("PUSHES", routes["pushes"].URL, "RELEASES", routes["releases"].URL, "ANY", routes["any"].URL)
+	c, err := LoadConfig(strings.NewReader(r.Replace(`{
+  "Name": "github",
+  "Rules": [
+    {"Name": "pushes", "Query": {"Expression": {"Type": "Is", "Expression": {"Field": "headers.X-Github-Event", "Value": "push"}}}},
+    {"Name": "releases", "Query": {"Expression": {"Type": "GT", "Expression": {"Field": "body.release.id", "Value": 0}}}},
+    {"Name": "main", "Query": {"Expression": {"Type": "Is", "Expression": {"Field": "body.ref", "Value": "refs/heads/main"}}}},
+    {"Name": "any", "Query": {"Expression": {"Type": "Is", "Expression": {"Field": "method", "Value": "POST"}}}}
+  ],
+  "Routes": {"pushes": "PUSHES", "releases": "RELEASES", "any": "ANY"}
+}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func send(t *testing.T, h http.Handler, event, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRouter(t *testing.T) {
+	pushes := newDestination(t, 2, http.StatusServiceUnavailable)
+	releases := newDestination(t, 0, 0)
+	all := newDestination(t, 0, 0)
+	rt := &Router{
+		Config:  loadConfig(t, map[string]*destination{"pushes": pushes, "releases": releases, "any": all}),
+		Backoff: time.Millisecond,
+	}
+
+	if rec := send(t, rt, "push", `{"ref": "refs/heads/main"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("push: status %d %s", rec.Code, rec.Body)
+	}
+	if rec := send(t, rt, "release", `{"release": {"id": 7}}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("release: status %d %s", rec.Code, rec.Body)
+	}
+	if got := pushes.received(); len(got) != 1 || got[0] != `push {"ref": "refs/heads/main"}` {
+		t.Errorf("pushes received %q", got)
+	}
+	if got := releases.received(); len(got) != 1 || got[0] != `release {"release": {"id": 7}}` {
+		t.Errorf("releases received %q", got)
+	}
+	if got := all.received(); len(got) != 2 {
+		t.Errorf("any received %q", got)
+	}
+
+	for name, tc := range map[string]struct {
+		method, body string
+		want         int
+	}{
+		"get":      {http.MethodGet, "", http.StatusMethodNotAllowed},
+		"not json": {http.MethodPost, "{", http.StatusBadRequest},
+		"too big":  {http.MethodPost, `"` + strings.Repeat("x", 2<<20) + `"`, http.StatusRequestEntityTooLarge},
+	} {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(tc.method, "/hooks", strings.NewReader(tc.body)))
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", name, rec.Code, tc.want)
+		}
+	}
+	if s := rt.Stats(); s.Received != 2 || s.Forwarded != 4 || s.Failed != 0 || s.Unmatched != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestRouterFailures(t *testing.T) {
+	pushes := newDestination(t, 1, http.StatusBadRequest)
+	releases := newDestination(t, 5, http.StatusInternalServerError)
+	all := newDestination(t, 0, 0)
+	rt := &Router{
+		Config:  loadConfig(t, map[string]*destination{"pushes": pushes, "releases": releases, "any": all}),
+		Retries: 2,
+		Backoff: time.Millisecond,
+	}
+	if rec := send(t, rt, "push", `{}`); rec.Code != http.StatusBadGateway {
+		t.Errorf("push: status %d, want a bad gateway", rec.Code)
+	}
+	if rec := send(t, rt, "release", `{"release": {"id": 1}}`); rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "1 of 2 deliveries failed: releases") {
+		t.Errorf("release: status %d %q, want a bad gateway naming releases", rec.Code, rec.Body)
+	}
+	if got := pushes.received(); len(got) != 0 {
+		t.Errorf("a 400 was retried: %q", got)
+	}
+	releases.mu.Lock()
+	left := releases.fail
+	releases.mu.Unlock()
+	if left != 2 {
+		t.Errorf("expected 3 attempts, %d failures left", left)
+	}
+	if got := all.received(); len(got) != 2 {
+		t.Errorf("any received %q", got)
+	}
+	if s := rt.Stats(); s.Forwarded != 2 || s.Failed != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestRouterCredentialHeaders(t *testing.T) {
+	var mu sync.Mutex
+	var got []http.Header
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		mu.Unlock()
+	}))
+	defer dest.Close()
+	c, err := LoadConfig(strings.NewReader(`{
+  "Name": "hooks",
+  "Rules": [{"Name": "all", "Query": {"Expression": {"Type": "Is", "Expression": {"Field": "method", "Value": "POST"}}}}],
+  "Routes": {"all": "` + dest.URL + `"}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rt := range []*Router{{Config: c}, {Config: c, ForwardHeaders: []string{"authorization"}}} {
+		req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer router-token")
+		req.Header.Set("Cookie", "session=1")
+		req.Header.Set("Proxy-Authorization", "Basic eDp5")
+		req.Header.Set("X-Hub-Signature-256", "sha256=abc")
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("status %d %s", rec.Code, rec.Body)
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d deliveries", len(got))
+	}
+	if h := got[0]; h.Get("Authorization") != "" || h.Get("Cookie") != "" || h.Get("Proxy-Authorization") != "" || h.Get("X-Hub-Signature-256") == "" {
+		t.Errorf("default headers %v", h)
+	}
+	if h := got[1]; h.Get("Authorization") != "Bearer router-token" || h.Get("Cookie") != "" {
+		t.Errorf("ForwardHeaders headers %v", h)
+	}
+}
+
+func TestRouterMaxConcurrent(t *testing.T) {
+	arrived, unblock := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+	c, err := LoadConfig(strings.NewReader(`{
+  "Rules": [{"Name": "any", "Query": {"Expression": {"Type": "Is", "Expression": {"Field": "method", "Value": "POST"}}}}],
+  "Routes": {"any": "` + slow.URL + `"}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &Router{Config: c, MaxConcurrent: 1}
+
+	codes := make(chan int, 2)
+	go func() { codes <- send(t, rt, "push", `{}`).Code }()
+	<-arrived
+	rec := send(t, rt, "push", `{}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("saturated: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	rt.QueueTimeout = 10 * time.Second
+	go func() { codes <- send(t, rt, "push", `{}`).Code }()
+	time.Sleep(20 * time.Millisecond)
+	unblock <- struct{}{}
+	<-arrived
+	unblock <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if code := <-codes; code != http.StatusNoContent {
+			t.Errorf("queued webhook: status %d", code)
+		}
+	}
+	if s := rt.Stats(); s.Received != 2 || s.Rejected != 1 || s.PeakInFlight != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestRouterQuota(t *testing.T) {
+	pushes := newDestination(t, 0, 0)
+	releases := newDestination(t, 0, 0)
+	all := newDestination(t, 0, 0)
+	var charged []int
+	budget := 6
+	rt := &Router{
+		Config: loadConfig(t, map[string]*destination{"pushes": pushes, "releases": releases, "any": all}),
+		BeforeEval: func(r *http.Request, cost int) error {
+			if cost > budget {
+				return errors.New("quota exceeded")
+			}
+			budget -= cost
+			return nil
+		},
+		AfterEval: func(r *http.Request, cost int, err error) { charged = append(charged, cost) },
+	}
+	if rec := send(t, rt, "push", `{}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("push: status %d %s", rec.Code, rec.Body)
+	}
+	if rec := send(t, rt, "push", `{}`); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("over quota: status %d, want 429", rec.Code)
+	}
+	if len(charged) != 1 || charged[0] != 4 {
+		t.Errorf("expected one webhook charged 4, got %v", charged)
+	}
+	if got := pushes.received(); len(got) != 1 {
+		t.Errorf("pushes received %q", got)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	for name, config := range map[string]string{
+		"no routes":    `{"Rules": [{"Name": "a"}]}`,
+		"unknown rule": `{"Rules": [{"Name": "a"}], "Routes": {"b": "http://example.com"}}`,
+		"bad url":      `{"Rules": [{"Name": "a"}], "Routes": {"a": "ftp://example.com"}}`,
+		"bad json":     `{`,
+		"bad window":   `{"Rules": [{"Name": "a", "Windows": ["* * *"]}], "Routes": {"a": "http://example.com"}}`,
+	} {
+		if _, err := LoadConfig(strings.NewReader(config)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
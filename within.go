@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithinDurationExpression succeeds when the timestamp in Field lies within
+// Duration before From, inclusive, as in "CreatedAt within 24h". From is a
+// time.Time or RFC 3339 string and defaults to the Context clock. Duration
+// is written as for time.ParseDuration, with an extra d suffix for days such
+// as "7d"; a negative duration looks ahead of From instead. Timestamps are
+// parsed as for BeforeExpression.
+type WithinDurationExpression struct {
+	Field    string
+	Duration string
+	From     interface{} `json:",omitempty"`
+}
+
+func (e WithinDurationExpression) Evaluate(i interface{}, opts ...any) (bool, error) {
+	d, err := ParseDuration(e.Duration)
+	if err != nil {
+		return false, err
+	}
+	var from time.Time
+	if e.From == nil {
+		from = GetContext(opts...).Now()
+	} else if t, ok := timeValue(e.From, nil); ok {
+		from = t
+	} else {
+		return false, fmt.Errorf("invalid From time %v", e.From)
+	}
+	t, ok := fieldTime(i, e.Field, nil)
+	if !ok {
+		return false, nil
+	}
+	start, end := from.Add(-d), from
+	if d < 0 {
+		start, end = from, start
+	}
+	return !t.Before(start) && !t.After(end), nil
+}
+
+// ParseDuration parses a duration such as "90m" or "24h" like
+// time.ParseDuration, additionally accepting whole or fractional days such
+// as "7d".
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
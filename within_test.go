@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWithinDurationExpression(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	ctx := &Context{Clock: func() time.Time { return now }}
+	r := map[string]interface{}{
+		"Recent": now.Add(-3 * time.Hour),
+		"Old":    "2024-05-01T00:00:00Z",
+		"Soon":   now.Add(2 * time.Hour),
+		"Name":   "x",
+	}
+	tests := []struct {
+		name string
+		e    WithinDurationExpression
+		want bool
+	}{
+		{"recent", WithinDurationExpression{Field: "Recent", Duration: "24h"}, true},
+		{"too old", WithinDurationExpression{Field: "Old", Duration: "7d"}, false},
+		{"days", WithinDurationExpression{Field: "Old", Duration: "41d"}, true},
+		{"future", WithinDurationExpression{Field: "Soon", Duration: "24h"}, false},
+		{"look ahead", WithinDurationExpression{Field: "Soon", Duration: "-3h"}, true},
+		{"from", WithinDurationExpression{Field: "Old", Duration: "1h", From: "2024-05-01T00:30:00Z"}, true},
+		{"edge", WithinDurationExpression{Field: "Recent", Duration: "3h"}, true},
+		{"not a time", WithinDurationExpression{Field: "Name", Duration: "1h"}, false},
+		{"missing", WithinDurationExpression{Field: "Other", Duration: "1h"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.Evaluate(r, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, bad := range []WithinDurationExpression{
+		{Field: "Recent", Duration: "soon"},
+		{Field: "Recent", Duration: "1h", From: "yesterday"},
+	} {
+		if _, err := bad.Evaluate(r, ctx); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
+
+func TestWithinDurationJSON(t *testing.T) {
+	data := []byte(`{"Expression":{"Type":"Within","Expression":{"Field":"At","Duration":"1.5d"}}}`)
+	var q Query
+	if err := json.Unmarshal(data, &q); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	r := map[string]interface{}{"At": "2024-06-09T00:00:00Z"}
+	if ok, err := q.Evaluate(r, &Context{Clock: func() time.Time { return now }}); err != nil || !ok {
+		t.Errorf("expected match: %v %v", ok, err)
+	}
+}